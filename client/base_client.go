@@ -58,6 +58,13 @@ type baseClient struct {
 	timeout          time.Duration
 	maxRetryTimes    int
 	enableForwarding bool
+	// enableFollowerHandle sends the read-only region and store requests to the followers,
+	// and falls back to the leader if the followers fail to handle them.
+	enableFollowerHandle bool
+	tsoPriority          string
+	// tsoURLs is the urls of the standalone TSO servers, the Global TSO requests
+	// are sent to the TSO leader instead of the PD leader if it's not empty.
+	tsoURLs atomic.Value // Store as []string
 }
 
 // SecurityOption records options about tls
@@ -91,6 +98,33 @@ func WithForwardingOption(enableForwarding bool) ClientOption {
 	}
 }
 
+// WithFollowerHandleOption configures the client to send the read-only region and store
+// requests to the followers. The followers serve them with the regions synced from the
+// leader, which may be a little stale. The stores served by the followers may be stale
+// for up to a minute, and they carry no heartbeat stats.
+func WithFollowerHandleOption(enableFollowerHandle bool) ClientOption {
+	return func(c *baseClient) {
+		c.enableFollowerHandle = enableFollowerHandle
+	}
+}
+
+// WithTSOPriority configures the priority of the TSO requests sent by the client.
+// It can be "normal" or "low", the requests with low priority, such as the ones of
+// GC or statistics, yield to the normal ones when the PD server batches the requests.
+func WithTSOPriority(priority string) ClientOption {
+	return func(c *baseClient) {
+		c.tsoPriority = priority
+	}
+}
+
+// WithTSOServiceURLs configures the client to get the Global TSO from the standalone
+// TSO servers with the given urls instead of the PD leader.
+func WithTSOServiceURLs(urls []string) ClientOption {
+	return func(c *baseClient) {
+		c.tsoURLs.Store(addrsToUrls(urls))
+	}
+}
+
 // WithMaxErrorRetry configures the client max retry times when connect meets error.
 func WithMaxErrorRetry(count int) ClientOption {
 	return func(c *baseClient) {
@@ -305,6 +339,11 @@ func (c *baseClient) updateMember() error {
 		if err := c.switchLeader(members.GetLeader().GetClientUrls()); err != nil {
 			return err
 		}
+		if c.useTSOService() {
+			if err := c.updateTSOMember(); err != nil {
+				return err
+			}
+		}
 		c.scheduleCheckTSODispatcher()
 
 		// If `switchLeader` succeeds but `switchTSOAllocatorLeader` has an error,
@@ -357,13 +396,92 @@ func (c *baseClient) switchLeader(addrs []string) error {
 		log.Warn("[pd] failed to connect leader", zap.String("leader", addr), errs.ZapError(err))
 		return err
 	}
-	// Set PD leader and Global TSO Allocator (which is also the PD leader)
+	// Set PD leader and Global TSO Allocator (which is also the PD leader
+	// unless the standalone TSO servers are used)
 	c.leader.Store(addr)
-	c.allocators.Store(globalDCLocation, addr)
+	if !c.useTSOService() {
+		c.allocators.Store(globalDCLocation, addr)
+	}
 	log.Info("[pd] switch leader", zap.String("new-leader", addr), zap.String("old-leader", oldLeader))
 	return nil
 }
 
+func (c *baseClient) useTSOService() bool {
+	return len(c.getTSOURLs()) > 0
+}
+
+func (c *baseClient) getTSOURLs() []string {
+	urls, _ := c.tsoURLs.Load().([]string)
+	return urls
+}
+
+// updateTSOMember updates the urls of the TSO servers and switches the Global TSO
+// Allocator to the TSO leader.
+func (c *baseClient) updateTSOMember() error {
+	tsoURLs := c.getTSOURLs()
+	for _, u := range tsoURLs {
+		members, err := c.getMembers(c.ctx, u, updateMemberTimeout)
+		if err == nil {
+			if members.GetHeader().GetClusterId() != c.clusterID {
+				err = errs.ErrClientGetLeader.FastGenByArgs(fmt.Sprintf("mismatch cluster id %d of tso server", members.GetHeader().GetClusterId()))
+			} else if members.GetLeader() == nil || len(members.GetLeader().GetClientUrls()) == 0 {
+				err = errs.ErrClientGetLeader.FastGenByArgs("tso leader address don't exist")
+			}
+		}
+		if err != nil {
+			log.Info("[pd] cannot update tso member from this address",
+				zap.String("address", u),
+				errs.ZapError(err))
+			select {
+			case <-c.ctx.Done():
+				return errors.WithStack(err)
+			default:
+				continue
+			}
+		}
+
+		c.updateTSOURLs(members.GetMembers())
+		return c.switchTSOLeader(members.GetLeader().GetClientUrls())
+	}
+	return errs.ErrClientGetLeader.FastGenByArgs(tsoURLs)
+}
+
+func (c *baseClient) updateTSOURLs(members []*pdpb.Member) {
+	urls := make([]string, 0, len(members))
+	for _, m := range members {
+		urls = append(urls, m.GetClientUrls()...)
+	}
+	if len(urls) == 0 {
+		return
+	}
+
+	sort.Strings(urls)
+	// the url list is same.
+	oldURLs := c.getTSOURLs()
+	if reflect.DeepEqual(oldURLs, urls) {
+		return
+	}
+
+	log.Info("[pd] update tso member urls", zap.Strings("old-urls", oldURLs), zap.Strings("new-urls", urls))
+	c.tsoURLs.Store(urls)
+}
+
+func (c *baseClient) switchTSOLeader(addrs []string) error {
+	addr := addrs[0]
+	oldLeader, _ := c.getAllocatorLeaderAddrByDCLocation(globalDCLocation)
+	if addr == oldLeader {
+		return nil
+	}
+
+	if _, err := c.getOrCreateGRPCConn(addr); err != nil {
+		log.Warn("[pd] failed to connect tso leader", zap.String("leader", addr), errs.ZapError(err))
+		return err
+	}
+	c.allocators.Store(globalDCLocation, addr)
+	log.Info("[pd] switch tso leader", zap.String("new-leader", addr), zap.String("old-leader", oldLeader))
+	return nil
+}
+
 func (c *baseClient) updateFollowers(members []*pdpb.Member, leader *pdpb.Member) {
 	var addrs []string
 	for _, member := range members {
@@ -34,6 +34,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
@@ -175,6 +176,9 @@ const (
 	maxInitClusterRetries = 100
 	retryInterval         = 1 * time.Second
 	maxRetryTimes         = 5
+	// A follower handle takes 1/followerHandleTimeoutRatio of the timeout, the
+	// rest is left for retrying on the leader.
+	followerHandleTimeoutRatio = 3
 )
 
 // LeaderHealthCheckInterval might be chagned in the unit to shorten the testing time.
@@ -415,6 +419,9 @@ func (c *client) createTsoStream(ctx context.Context, cancel context.CancelFunc,
 	done := make(chan struct{})
 	// TODO: we need to handle a conner case that this goroutine is timeout while the stream is successfully created.
 	go c.checkStreamTimeout(ctx, cancel, done)
+	if c.tsoPriority != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, grpcutil.TSOPriorityMetadataKey, c.tsoPriority)
+	}
 	stream, err := client.Tso(ctx)
 	done <- struct{}{}
 	return stream, err
@@ -840,6 +847,40 @@ func (c *client) followerClient() (pdpb.PDClient, string) {
 	return nil, ""
 }
 
+// followerHandle tries to handle the read-only request with a random follower if the follower
+// handle is enabled. It returns false if the request is not handled by the follower, and the
+// caller should send it to the leader with the returned timeout. The follower only has a
+// fraction of the timeout, so the leader has the rest of it to retry.
+func (c *client) followerHandle(ctx context.Context, f func(ctx context.Context, cli pdpb.PDClient, opt grpc.CallOption) error) (bool, time.Duration) {
+	if !c.enableFollowerHandle {
+		return false, c.timeout
+	}
+	addrs := c.GetFollowerAddr()
+	if len(addrs) == 0 {
+		return false, c.timeout
+	}
+	addr := addrs[rand.Intn(len(addrs))]
+	cc, err := c.getOrCreateGRPCConn(addr)
+	if err != nil {
+		return false, c.timeout
+	}
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(grpcutil.BuildFollowerHandleContext(ctx), c.timeout/followerHandleTimeoutRatio)
+	defer cancel()
+	var header metadata.MD
+	if err := f(ctx, pdpb.NewPDClient(cc), grpc.Header(&header)); err != nil {
+		followerHandleFallbackCounter.Inc()
+		log.Debug("[pd] failed to handle the request by follower, fall back to the leader", zap.String("follower", addr), errs.ZapError(err))
+		return false, c.timeout - time.Since(start)
+	}
+	if lag := header.Get(grpcutil.FollowerSyncLagMetadataKey); len(lag) > 0 {
+		if d, err := time.ParseDuration(lag[0]); err == nil {
+			followerHandleSyncLag.Observe(d.Seconds())
+		}
+	}
+	return true, 0
+}
+
 func (c *client) getClient() pdpb.PDClient {
 	if c.enableForwarding && atomic.LoadInt32(&c.leaderNetworkFailure) == 1 {
 		followerClient, addr := c.followerClient()
@@ -962,11 +1003,19 @@ func (c *client) GetRegion(ctx context.Context, key []byte) (*Region, error) {
 	start := time.Now()
 	defer func() { cmdDurationGetRegion.Observe(time.Since(start).Seconds()) }()
 
-	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	req := &pdpb.GetRegionRequest{
 		Header:    c.requestHeader(),
 		RegionKey: key,
 	}
+	var followerResp *pdpb.GetRegionResponse
+	handled, timeout := c.followerHandle(ctx, func(ctx context.Context, cli pdpb.PDClient, opt grpc.CallOption) (err error) {
+		followerResp, err = cli.GetRegion(ctx, req, opt)
+		return err
+	})
+	if handled {
+		return handleRegionResponse(followerResp), nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	ctx = grpcutil.BuildForwardContext(ctx, c.GetLeaderAddr())
 	resp, err := c.getClient().GetRegion(ctx, req)
 	cancel()
@@ -1029,11 +1078,19 @@ func (c *client) GetPrevRegion(ctx context.Context, key []byte) (*Region, error)
 	start := time.Now()
 	defer func() { cmdDurationGetPrevRegion.Observe(time.Since(start).Seconds()) }()
 
-	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	req := &pdpb.GetRegionRequest{
 		Header:    c.requestHeader(),
 		RegionKey: key,
 	}
+	var followerResp *pdpb.GetRegionResponse
+	handled, timeout := c.followerHandle(ctx, func(ctx context.Context, cli pdpb.PDClient, opt grpc.CallOption) (err error) {
+		followerResp, err = cli.GetPrevRegion(ctx, req, opt)
+		return err
+	})
+	if handled {
+		return handleRegionResponse(followerResp), nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	ctx = grpcutil.BuildForwardContext(ctx, c.GetLeaderAddr())
 	resp, err := c.getClient().GetPrevRegion(ctx, req)
 	cancel()
@@ -1054,11 +1111,19 @@ func (c *client) GetRegionByID(ctx context.Context, regionID uint64) (*Region, e
 	start := time.Now()
 	defer func() { cmdDurationGetRegionByID.Observe(time.Since(start).Seconds()) }()
 
-	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	req := &pdpb.GetRegionByIDRequest{
 		Header:   c.requestHeader(),
 		RegionId: regionID,
 	}
+	var followerResp *pdpb.GetRegionResponse
+	handled, timeout := c.followerHandle(ctx, func(ctx context.Context, cli pdpb.PDClient, opt grpc.CallOption) (err error) {
+		followerResp, err = cli.GetRegionByID(ctx, req, opt)
+		return err
+	})
+	if handled {
+		return handleRegionResponse(followerResp), nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	ctx = grpcutil.BuildForwardContext(ctx, c.GetLeaderAddr())
 	resp, err := c.getClient().GetRegionByID(ctx, req)
 	cancel()
@@ -1079,18 +1144,26 @@ func (c *client) ScanRegions(ctx context.Context, key, endKey []byte, limit int)
 	start := time.Now()
 	defer cmdDurationScanRegions.Observe(time.Since(start).Seconds())
 
-	var cancel context.CancelFunc
-	scanCtx := ctx
-	if _, ok := ctx.Deadline(); !ok {
-		scanCtx, cancel = context.WithTimeout(ctx, c.timeout)
-		defer cancel()
-	}
 	req := &pdpb.ScanRegionsRequest{
 		Header:   c.requestHeader(),
 		StartKey: key,
 		EndKey:   endKey,
 		Limit:    int32(limit),
 	}
+	var followerResp *pdpb.ScanRegionsResponse
+	handled, timeout := c.followerHandle(ctx, func(ctx context.Context, cli pdpb.PDClient, opt grpc.CallOption) (err error) {
+		followerResp, err = cli.ScanRegions(ctx, req, opt)
+		return err
+	})
+	if handled {
+		return handleRegionsResponse(followerResp), nil
+	}
+	var cancel context.CancelFunc
+	scanCtx := ctx
+	if _, ok := ctx.Deadline(); !ok {
+		scanCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 	scanCtx = grpcutil.BuildForwardContext(scanCtx, c.GetLeaderAddr())
 	resp, err := c.getClient().ScanRegions(scanCtx, req)
 
@@ -1139,11 +1212,19 @@ func (c *client) GetStore(ctx context.Context, storeID uint64) (*metapb.Store, e
 	start := time.Now()
 	defer func() { cmdDurationGetStore.Observe(time.Since(start).Seconds()) }()
 
-	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	req := &pdpb.GetStoreRequest{
 		Header:  c.requestHeader(),
 		StoreId: storeID,
 	}
+	var followerResp *pdpb.GetStoreResponse
+	handled, timeout := c.followerHandle(ctx, func(ctx context.Context, cli pdpb.PDClient, opt grpc.CallOption) (err error) {
+		followerResp, err = cli.GetStore(ctx, req, opt)
+		return err
+	})
+	if handled {
+		return handleStoreResponse(followerResp)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	ctx = grpcutil.BuildForwardContext(ctx, c.GetLeaderAddr())
 	resp, err := c.getClient().GetStore(ctx, req)
 	cancel()
@@ -1181,11 +1262,19 @@ func (c *client) GetAllStores(ctx context.Context, opts ...GetStoreOption) ([]*m
 	start := time.Now()
 	defer func() { cmdDurationGetAllStores.Observe(time.Since(start).Seconds()) }()
 
-	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	req := &pdpb.GetAllStoresRequest{
 		Header:                 c.requestHeader(),
 		ExcludeTombstoneStores: options.excludeTombstone,
 	}
+	var followerResp *pdpb.GetAllStoresResponse
+	handled, timeout := c.followerHandle(ctx, func(ctx context.Context, cli pdpb.PDClient, opt grpc.CallOption) (err error) {
+		followerResp, err = cli.GetAllStores(ctx, req, opt)
+		return err
+	})
+	if handled {
+		return followerResp.GetStores(), nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	ctx = grpcutil.BuildForwardContext(ctx, c.GetLeaderAddr())
 	resp, err := c.getClient().GetAllStores(ctx, req)
 	cancel()
@@ -58,6 +58,23 @@ var (
 			Name:      "forwarded_status",
 			Help:      "The status to indicate if the request is forwarded",
 		}, []string{"host", "delegate"})
+
+	followerHandleFallbackCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "pd_client",
+			Subsystem: "request",
+			Name:      "follower_handle_fallback_total",
+			Help:      "Counter of the requests failed to be handled by followers and sent to the leader.",
+		})
+
+	followerHandleSyncLag = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: "pd_client",
+			Subsystem: "request",
+			Name:      "follower_handle_sync_lag_seconds",
+			Help:      "Bucketed histogram of the region sync lag (s) of the followers handling the requests.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+		})
 )
 
 var (
@@ -98,4 +115,6 @@ func init() {
 	prometheus.MustRegister(requestDuration)
 	prometheus.MustRegister(tsoBatchSize)
 	prometheus.MustRegister(requestForwarded)
+	prometheus.MustRegister(followerHandleFallbackCounter)
+	prometheus.MustRegister(followerHandleSyncLag)
 }
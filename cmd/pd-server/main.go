@@ -90,19 +90,9 @@ func main() {
 
 	metricutil.Push(&cfg.Metric)
 
-	err = join.PrepareJoinCluster(cfg)
-	if err != nil {
-		log.Fatal("join meet error", errs.ZapError(err))
-	}
-
 	// Creates server.
 	ctx, cancel := context.WithCancel(context.Background())
-	serviceBuilders := []server.HandlerBuilder{api.NewHandler, swaggerserver.NewHandler, autoscaling.NewHandler}
-	serviceBuilders = append(serviceBuilders, dashboard.GetServiceBuilders()...)
-	svr, err := server.CreateServer(ctx, cfg, serviceBuilders...)
-	if err != nil {
-		log.Fatal("create server failed", errs.ZapError(err))
-	}
+	svr := createServer(ctx, cfg)
 
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc,
@@ -133,6 +123,33 @@ func main() {
 	}
 }
 
+type pdServer interface {
+	Run() error
+	Close()
+}
+
+func createServer(ctx context.Context, cfg *config.Config) pdServer {
+	if cfg.Mode == config.ModeTSO {
+		svr, err := server.CreateTSOServer(ctx, cfg)
+		if err != nil {
+			log.Fatal("create tso server failed", errs.ZapError(err))
+		}
+		return svr
+	}
+
+	err := join.PrepareJoinCluster(cfg)
+	if err != nil {
+		log.Fatal("join meet error", errs.ZapError(err))
+	}
+	serviceBuilders := []server.HandlerBuilder{api.NewHandler, swaggerserver.NewHandler, autoscaling.NewHandler}
+	serviceBuilders = append(serviceBuilders, dashboard.GetServiceBuilders()...)
+	svr, err := server.CreateServer(ctx, cfg, serviceBuilders...)
+	if err != nil {
+		log.Fatal("create server failed", errs.ZapError(err))
+	}
+	return svr
+}
+
 func exit(code int) {
 	log.Sync()
 	os.Exit(code)
@@ -23,6 +23,7 @@ import (
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/log"
 	promClient "github.com/prometheus/client_golang/api"
+	"github.com/tikv/pd/pkg/component"
 	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/pkg/typeutil"
 	"github.com/tikv/pd/server/cluster"
@@ -88,7 +89,7 @@ func getPlans(rc *cluster.RaftCluster, querier Querier, strategy *Strategy, comp
 	if component == TiKV {
 		instances = filterTiKVInstances(rc)
 	} else {
-		instances = getTiDBInstances(rc.GetEtcdClient())
+		instances = getTiDBInstances(rc.GetEtcdClient(), rc.GetComponentManager())
 	}
 
 	if len(instances) == 0 {
@@ -143,16 +144,25 @@ func filterTiKVInstances(informer core.StoreSetInformer) []instance {
 	return instances
 }
 
-func getTiDBInstances(etcdClient *clientv3.Client) []instance {
+// getTiDBInstances returns the TiDB instances registered under /topology/tidb by TiDB itself, and
+// the healthy ones registered in the component manager.
+func getTiDBInstances(etcdClient *clientv3.Client, manager *component.Manager) []instance {
+	var instances []instance
+	addresses := make(map[string]struct{})
 	infos, err := GetTiDBs(etcdClient)
 	if err != nil {
-		// TODO: error handling
-		return []instance{}
+		log.Warn("failed to get the tidb instances from the topology", errs.ZapError(err))
 	}
-	instances := make([]instance, 0, len(infos))
 	for _, info := range infos {
+		addresses[info.Address] = struct{}{}
 		instances = append(instances, instance{address: info.Address})
 	}
+	for _, member := range manager.GetHealthyMembers(TiDB.String(), time.Now()) {
+		if _, ok := addresses[member.Address]; !ok {
+			addresses[member.Address] = struct{}{}
+			instances = append(instances, instance{address: member.Address})
+		}
+	}
 	return instances
 }
 
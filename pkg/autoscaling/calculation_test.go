@@ -22,9 +22,14 @@ import (
 	"time"
 
 	. "github.com/pingcap/check"
+	"github.com/tikv/pd/pkg/component"
+	"github.com/tikv/pd/pkg/etcdutil"
 	"github.com/tikv/pd/pkg/mock/mockcluster"
 	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/embed"
 )
 
 func Test(t *testing.T) {
@@ -374,3 +379,30 @@ func (s *calculationTestSuite) TestStrategyChangeCount(c *C) {
 	plans = calculateScaleOutPlan(strategy, TiKV, scaleOutQuota, groups)
 	c.Assert(plans[0].Count, Equals, uint64(1))
 }
+
+func (s *calculationTestSuite) TestGetTiDBInstances(c *C) {
+	cfg := etcdutil.NewTestSingleConfig()
+	etcd, err := embed.StartEtcd(cfg)
+	c.Assert(err, IsNil)
+	defer func() {
+		etcd.Close()
+		etcdutil.CleanConfig(cfg)
+	}()
+	<-etcd.Server.ReadyNotify()
+	client, err := clientv3.New(clientv3.Config{Endpoints: []string{cfg.LCUrls[0].String()}})
+	c.Assert(err, IsNil)
+	defer client.Close()
+
+	// TiDB registers itself under /topology/tidb.
+	for _, addr := range []string{"127.0.0.1:4000", "127.0.0.1:4001"} {
+		_, err = client.Put(client.Ctx(), fmt.Sprintf("/topology/tidb/%s/info", addr), "{}")
+		c.Assert(err, IsNil)
+		_, err = client.Put(client.Ctx(), fmt.Sprintf("/topology/tidb/%s/ttl", addr), "1")
+		c.Assert(err, IsNil)
+	}
+	manager := component.NewManager(core.NewStorage(kv.NewMemoryKV()))
+	now := time.Now()
+	c.Assert(manager.RegisterMember(&component.Member{Component: "tidb", Address: "127.0.0.1:4001", TTL: 10}, now), IsNil)
+	c.Assert(manager.RegisterMember(&component.Member{Component: "tidb", Address: "127.0.0.1:4002", TTL: 10}, now), IsNil)
+	c.Assert(getAddresses(getTiDBInstances(client, manager)), DeepEquals, []string{"127.0.0.1:4000", "127.0.0.1:4001", "127.0.0.1:4002"})
+}
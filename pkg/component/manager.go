@@ -16,26 +16,63 @@ package component
 import (
 	"fmt"
 	"net/url"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/pingcap/log"
 	"github.com/tikv/pd/server/core"
 	"go.uber.org/zap"
 )
 
+// Member is a registered instance of a component.
+type Member struct {
+	Component string `json:"component"`
+	Address   string `json:"address"`
+	Version   string `json:"version,omitempty"`
+	// StartTimestamp is the unix timestamp when the instance started.
+	StartTimestamp int64             `json:"start_timestamp,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	StatusURL      string            `json:"status_url,omitempty"`
+	// TTL is the seconds after the last heartbeat that the instance is considered stale
+	// and expired. 0 means the instance never expires.
+	TTL int64 `json:"ttl,omitempty"`
+}
+
+// MemberStatus is the status of a member in the topology.
+type MemberStatus struct {
+	*Member
+	// LastHeartbeat is the unix timestamp of the last heartbeat, it's 0 if the
+	// member doesn't send heartbeats.
+	LastHeartbeat int64 `json:"last_heartbeat,omitempty"`
+	Healthy       bool  `json:"healthy"`
+}
+
+type heartbeat struct {
+	time    time.Time
+	healthy bool
+}
+
 // Manager is used to manage components.
 type Manager struct {
 	sync.RWMutex
 	storage *core.Storage
 	// component -> addresses
 	Addresses map[string][]string `json:"address"`
+	// component -> address -> member
+	Members map[string]map[string]*Member `json:"members,omitempty"`
+	// The heartbeats are not persisted, a member without heartbeat is considered
+	// alive until its TTL passes since it's first checked by ExpireStaleMembers.
+	heartbeats map[string]map[string]heartbeat
 }
 
 // NewManager creates a new component manager.
 func NewManager(storage *core.Storage) *Manager {
 	return &Manager{
-		storage:   storage,
-		Addresses: make(map[string][]string),
+		storage:    storage,
+		Addresses:  make(map[string][]string),
+		Members:    make(map[string]map[string]*Member),
+		heartbeats: make(map[string]map[string]heartbeat),
 	}
 }
 
@@ -95,14 +132,57 @@ func (c *Manager) Register(component, addr string) error {
 		return fmt.Errorf("component %s address %s has already been registered", component, addr)
 	}
 
-	ca = append(ca, addr)
-	c.Addresses[component] = ca
+	c.Addresses[component] = append(ca, addr)
+	c.setMember(&Member{Component: component, Address: addr})
 	if err := c.storage.SaveComponent(c); err != nil {
 		return fmt.Errorf("failed to save component when registering component %s address %s", component, addr)
 	}
 	return nil
 }
 
+// RegisterMember is used for registering a component instance with its metadata to PD. Unlike
+// Register, registering an existing instance again updates its metadata, which is useful when
+// the instance restarts.
+func (c *Manager) RegisterMember(member *Member, now time.Time) error {
+	c.Lock()
+	defer c.Unlock()
+
+	addr, err := validateAddr(member.Address)
+	if err != nil {
+		return err
+	}
+	if member.TTL < 0 {
+		return fmt.Errorf("ttl of component %s address %s cannot be negative", member.Component, addr)
+	}
+	m := *member
+	m.Address = addr
+	if exist, _ := contains(c.Addresses[m.Component], addr); !exist {
+		c.Addresses[m.Component] = append(c.Addresses[m.Component], addr)
+	}
+	c.setMember(&m)
+	c.setHeartbeat(m.Component, addr, heartbeat{time: now, healthy: true})
+	if err := c.storage.SaveComponent(c); err != nil {
+		return fmt.Errorf("failed to save component when registering component %s address %s", m.Component, addr)
+	}
+	return nil
+}
+
+// Heartbeat refreshes the liveness of a registered component instance.
+func (c *Manager) Heartbeat(component, addr string, healthy bool, now time.Time) error {
+	c.Lock()
+	defer c.Unlock()
+
+	addr, err := validateAddr(addr)
+	if err != nil {
+		return err
+	}
+	if _, ok := c.Members[component][addr]; !ok {
+		return fmt.Errorf("component %s address %s not found", component, addr)
+	}
+	c.setHeartbeat(component, addr, heartbeat{time: now, healthy: healthy})
+	return nil
+}
+
 // UnRegister is used for unregistering a component with an address from PD.
 func (c *Manager) UnRegister(component, addr string) error {
 	c.Lock()
@@ -117,17 +197,8 @@ func (c *Manager) UnRegister(component, addr string) error {
 		return fmt.Errorf("component %s not found", component)
 	}
 
-	if exist, idx := contains(ca, addr); exist {
-		ca = append(ca[:idx], ca[idx+1:]...)
-		if len(ca) == 0 {
-			delete(c.Addresses, component)
-			if err := c.storage.SaveComponent(c); err != nil {
-				return fmt.Errorf("failed to save component when unregistering component %s address %s", component, addr)
-			}
-			return nil
-		}
-
-		c.Addresses[component] = ca
+	if exist, _ := contains(ca, addr); exist {
+		c.removeMember(component, addr)
 		if err := c.storage.SaveComponent(c); err != nil {
 			return fmt.Errorf("failed to save component when unregistering component %s address %s", component, addr)
 		}
@@ -137,6 +208,144 @@ func (c *Manager) UnRegister(component, addr string) error {
 	return fmt.Errorf("address %s not found", addr)
 }
 
+// ExpireStaleMembers removes the members whose last heartbeat is older than their TTL,
+// and returns the number of expired members.
+func (c *Manager) ExpireStaleMembers(now time.Time) (int, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	expired := 0
+	for component, members := range c.Members {
+		for addr, member := range members {
+			if member.TTL == 0 {
+				continue
+			}
+			hb := c.getHeartbeat(component, addr, now)
+			if now.Sub(hb.time) <= time.Duration(member.TTL)*time.Second {
+				continue
+			}
+			log.Info("component member is expired",
+				zap.String("component", component),
+				zap.String("address", addr),
+				zap.Time("last-heartbeat", hb.time))
+			c.removeMember(component, addr)
+			expired++
+		}
+	}
+	if expired > 0 {
+		if err := c.storage.SaveComponent(c); err != nil {
+			return 0, fmt.Errorf("failed to save component when expiring stale members")
+		}
+	}
+	return expired, nil
+}
+
+// GetTopology returns the status of all the members grouped by component, the members
+// are sorted by address.
+func (c *Manager) GetTopology(now time.Time) map[string][]*MemberStatus {
+	c.RLock()
+	defer c.RUnlock()
+
+	topology := make(map[string][]*MemberStatus, len(c.Addresses))
+	for component, ca := range c.Addresses {
+		members := make([]*MemberStatus, 0, len(ca))
+		for _, addr := range ca {
+			member, ok := c.Members[component][addr]
+			if !ok {
+				// It's registered by an older version without the member.
+				member = &Member{Component: component, Address: addr}
+			}
+			m := *member
+			status := &MemberStatus{Member: &m, Healthy: true}
+			if m.TTL > 0 {
+				hb := c.peekHeartbeat(component, addr, now)
+				status.LastHeartbeat = hb.time.Unix()
+				status.Healthy = hb.healthy && now.Sub(hb.time) <= time.Duration(m.TTL)*time.Second
+			}
+			members = append(members, status)
+		}
+		sort.Slice(members, func(i, j int) bool { return members[i].Address < members[j].Address })
+		topology[component] = members
+	}
+	return topology
+}
+
+// GetHealthyMembers returns the healthy members of a component.
+func (c *Manager) GetHealthyMembers(component string, now time.Time) []*MemberStatus {
+	var members []*MemberStatus
+	for _, member := range c.GetTopology(now)[component] {
+		if member.Healthy {
+			members = append(members, member)
+		}
+	}
+	return members
+}
+
+func (c *Manager) setMember(member *Member) {
+	if c.Members == nil {
+		c.Members = make(map[string]map[string]*Member)
+	}
+	if _, ok := c.Members[member.Component]; !ok {
+		c.Members[member.Component] = make(map[string]*Member)
+	}
+	c.Members[member.Component][member.Address] = member
+}
+
+func (c *Manager) removeMember(component, addr string) {
+	if exist, idx := contains(c.Addresses[component], addr); exist {
+		ca := c.Addresses[component]
+		ca = append(ca[:idx], ca[idx+1:]...)
+		if len(ca) == 0 {
+			delete(c.Addresses, component)
+		} else {
+			c.Addresses[component] = ca
+		}
+	}
+	if members, ok := c.Members[component]; ok {
+		delete(members, addr)
+		if len(members) == 0 {
+			delete(c.Members, component)
+		}
+	}
+	if heartbeats, ok := c.heartbeats[component]; ok {
+		delete(heartbeats, addr)
+		if len(heartbeats) == 0 {
+			delete(c.heartbeats, component)
+		}
+	}
+}
+
+func (c *Manager) setHeartbeat(component, addr string, hb heartbeat) {
+	if c.heartbeats == nil {
+		c.heartbeats = make(map[string]map[string]heartbeat)
+	}
+	if _, ok := c.heartbeats[component]; !ok {
+		c.heartbeats[component] = make(map[string]heartbeat)
+	}
+	c.heartbeats[component][addr] = hb
+}
+
+// getHeartbeat returns the last heartbeat of the member, the member is considered to send
+// a heartbeat at now if it has never sent one, such as after the PD leader changes, and the
+// heartbeat is recorded so the TTL starts from now.
+func (c *Manager) getHeartbeat(component, addr string, now time.Time) heartbeat {
+	hb, ok := c.heartbeats[component][addr]
+	if !ok {
+		hb = heartbeat{time: now, healthy: true}
+		c.setHeartbeat(component, addr, hb)
+	}
+	return hb
+}
+
+// peekHeartbeat is the same as getHeartbeat but doesn't record the heartbeat, so it can be
+// called with the read lock.
+func (c *Manager) peekHeartbeat(component, addr string, now time.Time) heartbeat {
+	if hb, ok := c.heartbeats[component][addr]; ok {
+		return hb
+	}
+	return heartbeat{time: now, healthy: true}
+}
+
 func contains(slice []string, item string) (bool, int) {
 	for i, s := range slice {
 		if s == item {
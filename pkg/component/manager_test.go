@@ -16,6 +16,7 @@ package component
 import (
 	"strings"
 	"testing"
+	"time"
 
 	. "github.com/pingcap/check"
 	"github.com/tikv/pd/server/core"
@@ -66,3 +67,66 @@ func (s *testManagerSuite) TestManager(c *C) {
 	all = map[string][]string{"c2": {"127.0.0.1:3"}}
 	c.Assert(m.GetAllComponentAddrs(), DeepEquals, all)
 }
+
+func (s *testManagerSuite) TestMemberHeartbeat(c *C) {
+	storage := core.NewStorage(kv.NewMemoryKV())
+	m := NewManager(storage)
+	now := time.Now()
+	c.Assert(m.Register("tidb", "127.0.0.1:1"), IsNil)
+	member := &Member{
+		Component:      "tidb",
+		Address:        "http://127.0.0.1:2",
+		Version:        "v5.1.0",
+		StartTimestamp: now.Unix(),
+		Labels:         map[string]string{"zone": "z1"},
+		StatusURL:      "http://127.0.0.1:10080",
+		TTL:            10,
+	}
+	c.Assert(m.RegisterMember(member, now), IsNil)
+	// Registering again updates the metadata.
+	member.Version = "v5.1.1"
+	c.Assert(m.RegisterMember(member, now), IsNil)
+	c.Assert(m.GetComponentAddrs("tidb"), DeepEquals, []string{"127.0.0.1:1", "127.0.0.1:2"})
+	member.TTL = -1
+	c.Assert(m.RegisterMember(member, now), NotNil)
+	c.Assert(m.Heartbeat("tidb", "127.0.0.1:3", true, now), NotNil)
+
+	topology := m.GetTopology(now)
+	c.Assert(topology["tidb"], HasLen, 2)
+	c.Assert(topology["tidb"][0].Address, Equals, "127.0.0.1:1")
+	c.Assert(topology["tidb"][0].Healthy, IsTrue)
+	c.Assert(topology["tidb"][1].Version, Equals, "v5.1.1")
+	c.Assert(topology["tidb"][1].Labels, DeepEquals, map[string]string{"zone": "z1"})
+	c.Assert(topology["tidb"][1].LastHeartbeat, Equals, now.Unix())
+
+	// The member reports itself unhealthy.
+	c.Assert(m.Heartbeat("tidb", "127.0.0.1:2", false, now.Add(5*time.Second)), IsNil)
+	c.Assert(m.GetHealthyMembers("tidb", now.Add(5*time.Second)), HasLen, 1)
+	c.Assert(m.Heartbeat("tidb", "127.0.0.1:2", true, now.Add(5*time.Second)), IsNil)
+	c.Assert(m.GetHealthyMembers("tidb", now.Add(5*time.Second)), HasLen, 2)
+
+	// The members are reloaded after the PD leader changes, the heartbeats start from the reloading.
+	m2 := NewManager(storage)
+	_, err := storage.LoadComponent(&m2)
+	c.Assert(err, IsNil)
+	topology = m2.GetTopology(now.Add(20 * time.Second))
+	c.Assert(topology["tidb"], HasLen, 2)
+	c.Assert(topology["tidb"][1].Member, DeepEquals, m.GetTopology(now)["tidb"][1].Member)
+	c.Assert(topology["tidb"][1].LastHeartbeat, Equals, now.Add(20*time.Second).Unix())
+	c.Assert(topology["tidb"][1].Healthy, IsTrue)
+	c.Assert(m.GetHealthyMembers("tidb", now.Add(20*time.Second)), HasLen, 1)
+	expired, err := m2.ExpireStaleMembers(now.Add(25 * time.Second))
+	c.Assert(err, IsNil)
+	c.Assert(expired, Equals, 0)
+
+	// The member without heartbeat is expired, the one without TTL never expires.
+	expired, err = m.ExpireStaleMembers(now.Add(16 * time.Second))
+	c.Assert(err, IsNil)
+	c.Assert(expired, Equals, 1)
+	c.Assert(m.GetComponentAddrs("tidb"), DeepEquals, []string{"127.0.0.1:1"})
+	c.Assert(m.Heartbeat("tidb", "127.0.0.1:2", true, now.Add(16*time.Second)), NotNil)
+	m3 := NewManager(storage)
+	_, err = storage.LoadComponent(&m3)
+	c.Assert(err, IsNil)
+	c.Assert(m3.GetComponentAddrs("tidb"), DeepEquals, []string{"127.0.0.1:1"})
+}
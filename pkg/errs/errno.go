@@ -30,14 +30,20 @@ var (
 
 // tso errors
 var (
-	ErrSetLocalTSOConfig  = errors.Normalize("set local tso config failed, %s", errors.RFCCodeText("PD:tso:ErrSetLocalTSOConfig"))
-	ErrGetAllocator       = errors.Normalize("get allocator failed, %s", errors.RFCCodeText("PD:tso:ErrGetAllocator"))
-	ErrGetLocalAllocator  = errors.Normalize("get local allocator failed, %s", errors.RFCCodeText("PD:tso:ErrGetLocalAllocator"))
-	ErrSyncMaxTS          = errors.Normalize("sync max ts failed, %s", errors.RFCCodeText("PD:tso:ErrSyncMaxTS"))
-	ErrResetUserTimestamp = errors.Normalize("reset user timestamp failed, %s", errors.RFCCodeText("PD:tso:ErrResetUserTimestamp"))
-	ErrGenerateTimestamp  = errors.Normalize("generate timestamp failed, %s", errors.RFCCodeText("PD:tso:ErrGenerateTimestamp"))
-	ErrInvalidTimestamp   = errors.Normalize("invalid timestamp", errors.RFCCodeText("PD:tso:ErrInvalidTimestamp"))
-	ErrLogicOverflow      = errors.Normalize("logic part overflow", errors.RFCCodeText("PD:tso:ErrLogicOverflow"))
+	ErrSetLocalTSOConfig   = errors.Normalize("set local tso config failed, %s", errors.RFCCodeText("PD:tso:ErrSetLocalTSOConfig"))
+	ErrGetAllocator        = errors.Normalize("get allocator failed, %s", errors.RFCCodeText("PD:tso:ErrGetAllocator"))
+	ErrGetLocalAllocator   = errors.Normalize("get local allocator failed, %s", errors.RFCCodeText("PD:tso:ErrGetLocalAllocator"))
+	ErrSyncMaxTS           = errors.Normalize("sync max ts failed, %s", errors.RFCCodeText("PD:tso:ErrSyncMaxTS"))
+	ErrResetUserTimestamp  = errors.Normalize("reset user timestamp failed, %s", errors.RFCCodeText("PD:tso:ErrResetUserTimestamp"))
+	ErrGenerateTimestamp   = errors.Normalize("generate timestamp failed, %s", errors.RFCCodeText("PD:tso:ErrGenerateTimestamp"))
+	ErrInvalidTimestamp    = errors.Normalize("invalid timestamp", errors.RFCCodeText("PD:tso:ErrInvalidTimestamp"))
+	ErrLogicOverflow       = errors.Normalize("logic part overflow", errors.RFCCodeText("PD:tso:ErrLogicOverflow"))
+	ErrMigrateDCLocation   = errors.Normalize("migrate dc-location failed, %s", errors.RFCCodeText("PD:tso:ErrMigrateDCLocation"))
+	ErrRemoveDCLocation    = errors.Normalize("remove dc-location failed, %s", errors.RFCCodeText("PD:tso:ErrRemoveDCLocation"))
+	ErrTSOServiceActive    = errors.Normalize("global tso is served by the tso service", errors.RFCCodeText("PD:tso:ErrTSOServiceActive"))
+	ErrDCLocationNotFound  = errors.Normalize("dc-location %s not found", errors.RFCCodeText("PD:tso:ErrDCLocationNotFound"))
+	ErrDCLocationNotEmpty  = errors.Normalize("dc-location %s still has %d member(s), migrate them first", errors.RFCCodeText("PD:tso:ErrDCLocationNotEmpty"))
+	ErrDCLocationHasLeader = errors.Normalize("dc-location %s still has the local tso allocator leader %d, retry after it steps down", errors.RFCCodeText("PD:tso:ErrDCLocationHasLeader"))
 )
 
 // member errors
@@ -74,6 +80,11 @@ var (
 	ErrCreateOperator           = errors.Normalize("unable to create operator, %s", errors.RFCCodeText("PD:schedule:ErrCreateOperator"))
 )
 
+// replication errors
+var (
+	ErrReplicationStateSwitch = errors.Normalize("failed to switch replication state, %s", errors.RFCCodeText("PD:replication:ErrReplicationStateSwitch"))
+)
+
 // scheduler errors
 var (
 	ErrSchedulerExisted                 = errors.Normalize("scheduler existed", errors.RFCCodeText("PD:scheduler:ErrSchedulerExisted"))
@@ -98,6 +109,12 @@ var (
 var (
 	ErrNotBootstrapped = errors.Normalize("TiKV cluster not bootstrapped, please start TiKV first", errors.RFCCodeText("PD:cluster:ErrNotBootstrapped"))
 	ErrStoreIsUp       = errors.Normalize("store is still up, please remove store gracefully", errors.RFCCodeText("PD:cluster:ErrStoreIsUp"))
+
+	ErrUnsafeRecoveryIsRunning    = errors.Normalize("unsafe recovery is running", errors.RFCCodeText("PD:cluster:ErrUnsafeRecoveryIsRunning"))
+	ErrUnsafeRecoveryInvalidInput = errors.Normalize("invalid input %s", errors.RFCCodeText("PD:cluster:ErrUnsafeRecoveryInvalidInput"))
+
+	ErrRegionStorageNotEnabled = errors.Normalize("region storage is not enabled", errors.RFCCodeText("PD:cluster:ErrRegionStorageNotEnabled"))
+	ErrClusterNotPrepared      = errors.Normalize("cluster is not prepared, the regions in memory are incomplete", errors.RFCCodeText("PD:cluster:ErrClusterNotPrepared"))
 )
 
 // versioninfo errors
@@ -115,6 +132,13 @@ var (
 	ErrEmptyMetricsResult       = errors.Normalize("result from Prometheus is empty, %s", errors.RFCCodeText("PD:autoscaling:ErrEmptyMetricsResult"))
 )
 
+// diagnose errors
+var (
+	ErrUnknownDiagnoseCheck = errors.Normalize("unknown diagnose check %s", errors.RFCCodeText("PD:diagnose:ErrUnknownDiagnoseCheck"))
+	ErrDuplicatedDiagnoser  = errors.Normalize("diagnoser %s is already registered", errors.RFCCodeText("PD:diagnose:ErrDuplicatedDiagnoser"))
+	ErrDiagnoseCheckFailed  = errors.Normalize("diagnose check %s failed, %s", errors.RFCCodeText("PD:diagnose:ErrDiagnoseCheckFailed"))
+)
+
 // apiutil errors
 var (
 	ErrRedirect = errors.Normalize("redirect failed", errors.RFCCodeText("PD:apiutil:ErrRedirect"))
@@ -133,6 +157,16 @@ var (
 	ErrLeaderNil             = errors.Normalize("leader is nil", errors.RFCCodeText("PD:server:ErrLeaderNil"))
 	ErrCancelStartEtcd       = errors.Normalize("etcd start canceled", errors.RFCCodeText("PD:server:ErrCancelStartEtcd"))
 	ErrConfigItem            = errors.Normalize("cannot set invalid configuration", errors.RFCCodeText("PD:server:ErrConfiguration"))
+	ErrClusterIDNotFound     = errors.Normalize("cluster id not found in %s", errors.RFCCodeText("PD:server:ErrClusterIDNotFound"))
+)
+
+// hbcapture errors
+var (
+	ErrHeartbeatCaptureConfig     = errors.Normalize("invalid heartbeat capture config, %s", errors.RFCCodeText("PD:hbcapture:ErrHeartbeatCaptureConfig"))
+	ErrHeartbeatCaptureRunning    = errors.Normalize("heartbeat capture is already running", errors.RFCCodeText("PD:hbcapture:ErrHeartbeatCaptureRunning"))
+	ErrHeartbeatCaptureNotRunning = errors.Normalize("heartbeat capture is not running", errors.RFCCodeText("PD:hbcapture:ErrHeartbeatCaptureNotRunning"))
+	ErrHeartbeatCaptureFile       = errors.Normalize("failed to write heartbeat capture file", errors.RFCCodeText("PD:hbcapture:ErrHeartbeatCaptureFile"))
+	ErrHeartbeatCaptureCorrupted  = errors.Normalize("heartbeat capture file is corrupted", errors.RFCCodeText("PD:hbcapture:ErrHeartbeatCaptureCorrupted"))
 )
 
 // logutil errors
@@ -176,6 +210,7 @@ var (
 	ErrEtcdGrantLease    = errors.Normalize("etcd lease failed", errors.RFCCodeText("PD:etcd:ErrEtcdGrantLease"))
 	ErrEtcdTxnInternal   = errors.Normalize("internal etcd transaction error occurred", errors.RFCCodeText("PD:etcd:ErrEtcdTxnInternal"))
 	ErrEtcdTxnConflict   = errors.Normalize("etcd transaction failed, conflicted and rolled back", errors.RFCCodeText("PD:etcd:ErrEtcdTxnConflict"))
+	ErrEtcdTxnTooManyOps = errors.Normalize("etcd transaction has %d operations, more than the limit %d", errors.RFCCodeText("PD:etcd:ErrEtcdTxnTooManyOps"))
 	ErrEtcdKVPut         = errors.Normalize("etcd KV put failed", errors.RFCCodeText("PD:etcd:ErrEtcdKVPut"))
 	ErrEtcdKVDelete      = errors.Normalize("etcd KV delete failed", errors.RFCCodeText("PD:etcd:ErrEtcdKVDelete"))
 	ErrEtcdKVGet         = errors.Normalize("etcd KV get failed", errors.RFCCodeText("PD:etcd:ErrEtcdKVGet"))
@@ -259,9 +294,10 @@ var (
 
 // leveldb errors
 var (
-	ErrLevelDBClose = errors.Normalize("close leveldb error", errors.RFCCodeText("PD:leveldb:ErrLevelDBClose"))
-	ErrLevelDBWrite = errors.Normalize("leveldb write error", errors.RFCCodeText("PD:leveldb:ErrLevelDBWrite"))
-	ErrLevelDBOpen  = errors.Normalize("leveldb open file error", errors.RFCCodeText("PD:leveldb:ErrLevelDBOpen"))
+	ErrLevelDBClose   = errors.Normalize("close leveldb error", errors.RFCCodeText("PD:leveldb:ErrLevelDBClose"))
+	ErrLevelDBWrite   = errors.Normalize("leveldb write error", errors.RFCCodeText("PD:leveldb:ErrLevelDBWrite"))
+	ErrLevelDBOpen    = errors.Normalize("leveldb open file error", errors.RFCCodeText("PD:leveldb:ErrLevelDBOpen"))
+	ErrLevelDBCompact = errors.Normalize("leveldb compact error", errors.RFCCodeText("PD:leveldb:ErrLevelDBCompact"))
 )
 
 // semver
@@ -29,6 +29,16 @@ import (
 // ForwardMetadataKey is used to record the forwarded host of PD.
 const ForwardMetadataKey = "pd-forwarded-host"
 
+// TSOPriorityMetadataKey is used to record the priority of the TSO requests in a stream.
+const TSOPriorityMetadataKey = "pd-tso-priority"
+
+// FollowerHandleMetadataKey is used to mark the read-only requests which can be handled by followers.
+const FollowerHandleMetadataKey = "pd-allow-follower-handle"
+
+// FollowerSyncLagMetadataKey is used to report the region sync lag of the follower which handles
+// the request in the response header.
+const FollowerSyncLagMetadataKey = "pd-follower-sync-lag"
+
 // TLSConfig is the configuration for supporting tls.
 type TLSConfig struct {
 	// CAPath is the path of file that contains list of trusted SSL CAs. if set, following four settings shouldn't be empty
@@ -114,6 +124,13 @@ func BuildForwardContext(ctx context.Context, addr string) context.Context {
 	return metadata.NewOutgoingContext(ctx, md)
 }
 
+// BuildFollowerHandleContext creates a context which allows the request to be handled by followers.
+// It is used in client side.
+func BuildFollowerHandleContext(ctx context.Context) context.Context {
+	md := metadata.Pairs(FollowerHandleMetadataKey, "true")
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
 // ResetForwardContext is going to reset the forwarded host in metadata.
 func ResetForwardContext(ctx context.Context) context.Context {
 	md, ok := metadata.FromIncomingContext(ctx)
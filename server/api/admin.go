@@ -20,7 +20,9 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/core"
 	"github.com/unrolled/render"
 )
 
@@ -132,3 +134,54 @@ func (h *adminHandler) UpdateWaitAsyncTime(w http.ResponseWriter, r *http.Reques
 	cluster.GetReplicationMode().UpdateMemberWaitAsyncTime(memberID)
 	h.rd.JSON(w, http.StatusOK, nil)
 }
+
+// @Tags admin
+// @Summary Get the report of the last region storage verification, the region storage is verified without repair if it has never been verified.
+// @Produce json
+// @Success 200 {object} core.RegionStorageReport
+// @Failure 412 {string} string "The region storage is not enabled or the cluster is not prepared."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /admin/storage/region/verify [get]
+func (h *adminHandler) GetRegionStorageReport(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	if report := rc.GetRegionStorageReport(); report != nil {
+		h.rd.JSON(w, http.StatusOK, report)
+		return
+	}
+	report, err := rc.VerifyRegionStorage(false)
+	h.writeRegionStorageReport(w, report, err)
+}
+
+// @Tags admin
+// @Summary Verify the region storage against the regions in memory, remove the orphaned records, repair the stale and missing ones and compact the storage.
+// @Param dry-run query bool false "Only verify the region storage without repair and compaction"
+// @Produce json
+// @Success 200 {object} core.RegionStorageReport
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 412 {string} string "The region storage is not enabled or the cluster is not prepared."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /admin/storage/region/verify [post]
+func (h *adminHandler) VerifyRegionStorage(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if value := r.URL.Query().Get("dry-run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	report, err := getCluster(r).VerifyRegionStorage(!dryRun)
+	h.writeRegionStorageReport(w, report, err)
+}
+
+func (h *adminHandler) writeRegionStorageReport(w http.ResponseWriter, report *core.RegionStorageReport, err error) {
+	if err != nil {
+		if errs.ErrRegionStorageNotEnabled.Equal(err) || errs.ErrClusterNotPrepared.Equal(err) {
+			h.rd.JSON(w, http.StatusPreconditionFailed, err.Error())
+			return
+		}
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, report)
+}
@@ -17,13 +17,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/tikv/pd/server"
 	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/hbcapture"
 )
 
 var _ = Suite(&testAdminSuite{})
@@ -88,6 +92,107 @@ func (s *testAdminSuite) TestDropRegion(c *C) {
 	c.Assert(region.GetRegionEpoch().Version, Equals, uint64(50))
 }
 
+func (s *testAdminSuite) TestHeartbeatCapture(c *C) {
+	url := s.urlPrefix + "/admin/heartbeat-capture"
+	err := postJSON(testDialClient, url, []byte(`{"name":"../cap"}`))
+	c.Assert(err, NotNil)
+	c.Assert(strings.Contains(err.Error(), "invalid heartbeat capture config"), IsTrue)
+
+	var status hbcapture.Status
+	err = postJSON(testDialClient, url, []byte(`{"name":"cap","sample-rate":0.5,"duration":"1m"}`), func(res []byte, _ int) {
+		c.Assert(json.Unmarshal(res, &status), IsNil)
+	})
+	c.Assert(err, IsNil)
+	c.Assert(status.Running, IsTrue)
+	c.Assert(status.SampleRate, Equals, 0.5)
+	err = postJSON(testDialClient, url, []byte(`{"name":"cap2"}`))
+	c.Assert(err, NotNil)
+	c.Assert(strings.Contains(err.Error(), "already running"), IsTrue)
+
+	region := s.svr.GetRaftCluster().GetRegionByKey([]byte("foo"))
+	s.svr.GetHeartbeatCapturer().CaptureRegionHeartbeat(&pdpb.RegionHeartbeatRequest{Region: region.GetMeta(), Leader: region.GetLeader()})
+	c.Assert(readJSON(testDialClient, url, &status), IsNil)
+	c.Assert(status.Running, IsTrue)
+
+	res, err := doDelete(testDialClient, url)
+	c.Assert(err, IsNil)
+	c.Assert(res.StatusCode, Equals, http.StatusOK)
+	c.Assert(readJSON(testDialClient, url, &status), IsNil)
+	c.Assert(status.Running, IsFalse)
+	c.Assert(status.Path, Equals, filepath.Join(s.svr.GetConfig().DataDir, "heartbeat-capture", "cap"))
+	_, err = os.Stat(status.Path)
+	c.Assert(err, IsNil)
+
+	res, err = doDelete(testDialClient, url)
+	c.Assert(err, IsNil)
+	c.Assert(res.StatusCode, Equals, http.StatusPreconditionFailed)
+}
+
+var _ = Suite(&testRegionStorageSuite{})
+
+type testRegionStorageSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testRegionStorageSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+}
+
+func (s *testRegionStorageSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testRegionStorageSuite) TestVerifyRegionStorage(c *C) {
+	url := fmt.Sprintf("%s/admin/storage/region/verify", s.urlPrefix)
+	// The regions in memory are incomplete before the cluster is prepared.
+	err := postJSON(testDialClient, url, nil, func(_ []byte, code int) {
+		c.Assert(code, Equals, http.StatusPreconditionFailed)
+	})
+	c.Assert(err, NotNil)
+
+	// The bootstrapped region is replaced, and region 100 is left in the region
+	// storage after it's removed.
+	mustRegionHeartbeat(c, s.svr, newTestRegionInfo(2, 1, []byte(""), []byte(""), core.SetRegionVersion(2)))
+	orphan := &metapb.Region{Id: 100, StartKey: []byte("x"), EndKey: []byte("y")}
+	c.Assert(s.svr.GetStorage().SaveRegion(orphan), IsNil)
+
+	report := &core.RegionStorageReport{}
+	err = postJSON(testDialClient, url+"?dry-run=true", nil, func(res []byte, _ int) {
+		c.Assert(json.Unmarshal(res, report), IsNil)
+	})
+	c.Assert(err, IsNil)
+	c.Assert(report.Orphaned, DeepEquals, []uint64{100})
+	c.Assert(report.Repaired, IsFalse)
+	c.Assert(readJSON(testDialClient, url, report), IsNil)
+	c.Assert(report.Orphaned, DeepEquals, []uint64{100})
+
+	err = postJSON(testDialClient, url, nil, func(res []byte, _ int) {
+		c.Assert(json.Unmarshal(res, report), IsNil)
+	})
+	c.Assert(err, IsNil)
+	c.Assert(report.Repaired, IsTrue)
+	c.Assert(report.Compacted, IsTrue)
+	err = postJSON(testDialClient, url+"?dry-run=true", nil, func(res []byte, _ int) {
+		c.Assert(json.Unmarshal(res, report), IsNil)
+	})
+	c.Assert(err, IsNil)
+	c.Assert(report.OrphanedCount+report.StaleCount+report.MissingCount, Equals, 0)
+	c.Assert(report.StoredCount, Equals, report.RegionCount)
+
+	err = postJSON(testDialClient, url+"?dry-run=x", nil, func(_ []byte, code int) {
+		c.Assert(code, Equals, http.StatusBadRequest)
+	})
+	c.Assert(err, NotNil)
+}
+
 var _ = Suite(&testTSOSuite{})
 
 type testTSOSuite struct {
@@ -16,10 +16,12 @@ package api
 import (
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/pingcap/errcode"
 	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/component"
 	"github.com/tikv/pd/server"
 	"github.com/unrolled/render"
 )
@@ -27,6 +29,9 @@ import (
 // Addresses is mapping from component to addresses.
 type Addresses map[string][]string
 
+// Topology is mapping from component to its instances.
+type Topology map[string][]*component.MemberStatus
+
 type componentHandler struct {
 	svr *server.Server
 	rd  *render.Render
@@ -48,27 +53,89 @@ func newComponentHandler(svr *server.Server, rd *render.Render) *componentHandle
 // @Router /component [post]
 func (h *componentHandler) Register(w http.ResponseWriter, r *http.Request) {
 	rc := getCluster(r)
-	input := make(map[string]string)
+	var input struct {
+		Component      *string           `json:"component"`
+		Addr           *string           `json:"addr"`
+		Version        string            `json:"version"`
+		StartTimestamp int64             `json:"start_timestamp"`
+		Labels         map[string]string `json:"labels"`
+		StatusURL      string            `json:"status_url"`
+		TTL            int64             `json:"ttl"`
+	}
 	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &input); err != nil {
 		return
 	}
-	component, ok := input["component"]
-	if !ok {
+	if input.Component == nil {
 		apiutil.ErrorResp(h.rd, w, errcode.NewInvalidInputErr(errors.New("not set component")))
 		return
 	}
-	addr, ok := input["addr"]
-	if !ok {
+	if input.Addr == nil {
 		apiutil.ErrorResp(h.rd, w, errcode.NewInvalidInputErr(errors.New("not set addr")))
 		return
 	}
-	if err := rc.GetComponentManager().Register(component, addr); err != nil {
+	manager := rc.GetComponentManager()
+	var err error
+	// Only the address is registered if there is no metadata, which is compatible with the older components.
+	if input.Version == "" && input.StartTimestamp == 0 && len(input.Labels) == 0 && input.StatusURL == "" && input.TTL == 0 {
+		err = manager.Register(*input.Component, *input.Addr)
+	} else {
+		err = manager.RegisterMember(&component.Member{
+			Component:      *input.Component,
+			Address:        *input.Addr,
+			Version:        input.Version,
+			StartTimestamp: input.StartTimestamp,
+			Labels:         input.Labels,
+			StatusURL:      input.StatusURL,
+			TTL:            input.TTL,
+		}, time.Now())
+	}
+	if err != nil {
 		h.rd.JSON(w, http.StatusBadRequest, err.Error())
 		return
 	}
 	h.rd.JSON(w, http.StatusOK, "The component address is registered successfully.")
 }
 
+// @Tags component
+// @Summary Send a heartbeat of a registered component instance.
+// @Param component path string true "The component name"
+// @Param addr path string true "The address of the instance"
+// @Param body body object false "json params, such as {\"healthy\": false}"
+// @Produce json
+// @Success 200 {string} string "The heartbeat is received."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The component instance is not registered."
+// @Router /component/{component}/{addr}/heartbeat [post]
+func (h *componentHandler) Heartbeat(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	vars := mux.Vars(r)
+	input := struct {
+		Healthy *bool `json:"healthy"`
+	}{}
+	if r.ContentLength != 0 {
+		if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+			return
+		}
+	}
+	healthy := input.Healthy == nil || *input.Healthy
+	if err := rc.GetComponentManager().Heartbeat(vars["component"], vars["addr"], healthy, time.Now()); err != nil {
+		// The instance should register again if it's not found, such as it's expired.
+		h.rd.JSON(w, http.StatusNotFound, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "The heartbeat is received.")
+}
+
+// @Tags component
+// @Summary List all the component instances with their metadata and health.
+// @Produce json
+// @Success 200 {object} Topology
+// @Router /component/topology [get]
+func (h *componentHandler) GetTopology(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	h.rd.JSON(w, http.StatusOK, Topology(rc.GetComponentManager().GetTopology(time.Now())))
+}
+
 // @Tags component
 // @Summary Unregister component address.
 // @Produce json
@@ -140,4 +140,35 @@ func (s *testComponentSuite) TestComponent(c *C) {
 	err = readJSON(testDialClient, addr, &output)
 	c.Assert(err, IsNil)
 	c.Assert(output, DeepEquals, expected4)
+
+	// register with metadata and send heartbeats
+	postData, err := json.Marshal(map[string]interface{}{
+		"component":       "tidb",
+		"addr":            "127.0.0.1:4000",
+		"version":         "v5.1.0",
+		"start_timestamp": 1600000000,
+		"labels":          map[string]string{"zone": "z1"},
+		"status_url":      "http://127.0.0.1:10080",
+		"ttl":             60,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(postJSON(testDialClient, addr, postData), IsNil)
+	c.Assert(postJSON(testDialClient, addr+"/tidb/127.0.0.1:4000/heartbeat", []byte(`{"healthy":false}`)), IsNil)
+	c.Assert(postJSON(testDialClient, addr+"/tidb/127.0.0.1:4001/heartbeat", nil), NotNil)
+
+	topology := make(Topology)
+	c.Assert(readJSON(testDialClient, addr+"/topology", &topology), IsNil)
+	c.Assert(topology, HasLen, 3)
+	c.Assert(topology["tidb"], HasLen, 1)
+	tidb := topology["tidb"][0]
+	c.Assert(tidb.Version, Equals, "v5.1.0")
+	c.Assert(tidb.StartTimestamp, Equals, int64(1600000000))
+	c.Assert(tidb.Labels, DeepEquals, map[string]string{"zone": "z1"})
+	c.Assert(tidb.TTL, Equals, int64(60))
+	c.Assert(tidb.Healthy, IsFalse)
+	c.Assert(topology["c2"][0].Healthy, IsTrue)
+
+	c.Assert(postJSON(testDialClient, addr+"/tidb/127.0.0.1:4000/heartbeat", nil), IsNil)
+	c.Assert(readJSON(testDialClient, addr+"/topology", &topology), IsNil)
+	c.Assert(topology["tidb"][0].Healthy, IsTrue)
 }
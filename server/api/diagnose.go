@@ -16,37 +16,45 @@ package api
 import (
 	"context"
 	"fmt"
+	"math"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/typeutil"
 	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/diagnose"
+	"github.com/tikv/pd/server/statistics"
+	"github.com/tikv/pd/server/tso"
 	"github.com/unrolled/render"
 )
 
 type diagnoseType int
 
 // Recommendation contains a potential problem and possible way to deal with it.
-type Recommendation struct {
-	Module      string `json:"module"`
-	Level       string `json:"level"`
-	Description string `json:"description"`
-	Instruction string `json:"instruction"`
-}
+type Recommendation = diagnose.Recommendation
 
 // lint:file-ignore U1000 document available levels and modules
 const (
 	// analyze levels
-	levelWarning  = "Warning"
-	levelMinor    = "Minor"
-	levelMajor    = "Major"
-	levelCritical = "Critical"
+	levelWarning  = diagnose.LevelWarning
+	levelMinor    = diagnose.LevelMinor
+	levelMajor    = diagnose.LevelMajor
+	levelCritical = diagnose.LevelCritical
 
 	// analyze modules
-	modMember  = "member"
-	modTiKV    = "TiKV"
-	modDefault = "Default"
+	modMember      = "member"
+	modTiKV        = "TiKV"
+	modSchedule    = "Schedule"
+	modPlacement   = "Placement"
+	modGC          = "GC"
+	modTSO         = "TSO"
+	modReplication = "Replication"
+	modDefault     = "Default"
 
 	memberOneInstance diagnoseType = iota
 	memberEvenInstance
@@ -58,32 +66,95 @@ const (
 	tikvCap90
 	tikvLostPeers
 	tikvLostPeersLongTime
+	tikvDown
+	tikvLowSpaceSoon
+	tikvFullSoon
+	scheduleUnbalancedLeader
+	scheduleUnbalancedRegion
+	scheduleStuckOperator
+	placementMissPeer
+	placementExtraPeer
+	gcSafePointStale
+	gcSafePointLagLongTime
+	tsoDrift
+	replicationAsync
+	replicationSyncRecover
+)
+
+const (
+	// diagnoseCacheTTL is how long the result of a check is reused.
+	diagnoseCacheTTL = 10 * time.Second
+	// tikvLostLongTime is the disconnected time to report tikvLostPeersLongTime.
+	tikvLostLongTime = time.Hour
+	// unbalancedScoreRatio is the ratio of the score difference to the max score to report unbalanced stores.
+	unbalancedScoreRatio = 0.3
+	// stuckOperatorTime is the running time to report a stuck operator.
+	stuckOperatorTime = 5 * time.Minute
+	// gcSafePointLagWarningTime is the lag to report gcSafePointLagLongTime if the max lag is not set.
+	gcSafePointLagWarningTime = 24 * time.Hour
+	// maxTSODrift is the max allowed difference between the TSO and the local time.
+	maxTSODrift = 3 * time.Second
+	// lowSpaceForecastTime is the forecast time to reach the low space ratio to report tikvLowSpaceSoon.
+	lowSpaceForecastTime = 7 * 24 * time.Hour
+	// fullForecastTime is the forecast time to run out of space to report tikvFullSoon.
+	fullForecastTime = 3 * 24 * time.Hour
 )
 
 var (
 	diagnoseMap = map[diagnoseType]Recommendation{
-		memberOneInstance:           {modMember, levelWarning, "only one PD instance is running.", "please add PD instance."},
-		memberEvenInstance:          {modMember, levelMinor, "PD instances is even number.", "the recommended number of PD's instances is odd."},
-		memberLostPeers:             {modMember, levelMajor, "some PD instances is down.", "please check host load and traffic."},
-		memberLostPeersMoreThanHalf: {modMember, levelCritical, "more than half PD instances is down.", "please check host load and traffic."},
-		memberLeaderChanged:         {modMember, levelMinor, "PD cluster leader is changed.", "please check host load and traffic."},
-		tikvCap70:                   {modTiKV, levelWarning, "some TiKV storage used more than 70%.", "please add TiKV node."},
-		tikvCap80:                   {modTiKV, levelMinor, "some TiKV storage used more than 80%.", "please add TiKV node."},
-		tikvCap90:                   {modTiKV, levelMajor, "some TiKV storage used more than 90%.", "please add TiKV node."},
-		tikvLostPeers:               {modTiKV, levelWarning, "some TiKV lost connect.", "please check network."},
-		tikvLostPeersLongTime:       {modTiKV, levelMajor, "some TiKV lost connect more than 1h.", "please check network."},
+		memberOneInstance:           {Module: modMember, Level: levelWarning, Description: "only one PD instance is running.", Instruction: "please add PD instance."},
+		memberEvenInstance:          {Module: modMember, Level: levelMinor, Description: "PD instances is even number.", Instruction: "the recommended number of PD's instances is odd."},
+		memberLostPeers:             {Module: modMember, Level: levelMajor, Description: "some PD instances is down.", Instruction: "please check host load and traffic."},
+		memberLostPeersMoreThanHalf: {Module: modMember, Level: levelCritical, Description: "more than half PD instances is down.", Instruction: "please check host load and traffic."},
+		memberLeaderChanged:         {Module: modMember, Level: levelMinor, Description: "PD cluster leader is changed.", Instruction: "please check host load and traffic."},
+		tikvCap70:                   {Module: modTiKV, Level: levelWarning, Description: "some TiKV storage used more than 70%.", Instruction: "please add TiKV node."},
+		tikvCap80:                   {Module: modTiKV, Level: levelMinor, Description: "some TiKV storage used more than 80%.", Instruction: "please add TiKV node."},
+		tikvCap90:                   {Module: modTiKV, Level: levelMajor, Description: "some TiKV storage used more than 90%.", Instruction: "please add TiKV node."},
+		tikvLostPeers:               {Module: modTiKV, Level: levelWarning, Description: "some TiKV lost connect.", Instruction: "please check network."},
+		tikvLostPeersLongTime:       {Module: modTiKV, Level: levelMajor, Description: "some TiKV lost connect more than 1h.", Instruction: "please check network."},
+		tikvDown:                    {Module: modTiKV, Level: levelMajor, Description: "some TiKV is down longer than max-store-down-time.", Instruction: "please check the TiKV instances, the replicas on them are being repaired."},
+		tikvLowSpaceSoon:            {Module: modTiKV, Level: levelMinor, Description: "some TiKV is forecast to reach the low-space-ratio within 7 days.", Instruction: "please add TiKV node or expand the disk."},
+		tikvFullSoon:                {Module: modTiKV, Level: levelMajor, Description: "some TiKV is forecast to run out of space within 3 days.", Instruction: "please add TiKV node or expand the disk as soon as possible."},
+		scheduleUnbalancedLeader:    {Module: modSchedule, Level: levelMinor, Description: "the leader scores of TiKV are unbalanced.", Instruction: "please check the leader schedulers and the leader-schedule-limit."},
+		scheduleUnbalancedRegion:    {Module: modSchedule, Level: levelMinor, Description: "the region scores of TiKV are unbalanced.", Instruction: "please check the region schedulers and the region-schedule-limit."},
+		scheduleStuckOperator:       {Module: modSchedule, Level: levelMinor, Description: "some operators are running for a long time.", Instruction: "please check the TiKV instances of the operators."},
+		placementMissPeer:           {Module: modPlacement, Level: levelMajor, Description: "some regions miss peers required by the placement rules.", Instruction: "please check the placement rules, store labels and the replica-schedule-limit."},
+		placementExtraPeer:          {Module: modPlacement, Level: levelWarning, Description: "some regions have extra peers beyond the placement rules.", Instruction: "please check the placement rules and the replica-schedule-limit."},
+		gcSafePointStale:            {Module: modGC, Level: levelMajor, Description: "the minimum service GC safepoint lags more than the max lag.", Instruction: "please check the service, or expire it by pd-ctl service-gc-safepoint expire."},
+		gcSafePointLagLongTime:      {Module: modGC, Level: levelWarning, Description: "the minimum service GC safepoint lags more than 24h.", Instruction: "please check whether the service is still running."},
+		tsoDrift:                    {Module: modTSO, Level: levelMajor, Description: "the TSO drifts from the local time of PD leader.", Instruction: "please check the system time and NTP of PD instances."},
+		replicationAsync:            {Module: modReplication, Level: levelMajor, Description: "the DR auto-sync replication is degraded to async.", Instruction: "please check the TiKV instances and network of the DR data center."},
+		replicationSyncRecover:      {Module: modReplication, Level: levelMinor, Description: "the DR auto-sync replication is recovering.", Instruction: "please wait for the regions to be synced."},
 	}
 )
 
 type diagnoseHandler struct {
-	svr *server.Server
-	rd  *render.Render
+	svr      *server.Server
+	rd       *render.Render
+	registry *diagnose.Registry
 }
 
 func newDiagnoseHandler(svr *server.Server, rd *render.Render) *diagnoseHandler {
+	registry := diagnose.NewRegistry(diagnoseCacheTTL)
+	for _, d := range []diagnose.Diagnoser{
+		&memberDiagnoser{svr: svr},
+		&storeStateDiagnoser{svr: svr},
+		&storeSpaceDiagnoser{svr: svr},
+		&capacityForecastDiagnoser{svr: svr},
+		&balanceDiagnoser{svr: svr},
+		&operatorDiagnoser{svr: svr},
+		&placementDiagnoser{svr: svr},
+		&gcSafePointDiagnoser{svr: svr},
+		&tsoDiagnoser{svr: svr},
+		&replicationDiagnoser{svr: svr},
+	} {
+		// The names are unique, so it never fails.
+		_ = registry.Register(d)
+	}
 	return &diagnoseHandler{
-		svr: svr,
-		rd:  rd,
+		svr:      svr,
+		rd:       rd,
+		registry: registry,
 	}
 }
 
@@ -105,13 +176,32 @@ func diagnosePD(key diagnoseType, descAdd, instAdd string) *Recommendation {
 	return &d
 }
 
-func (d *diagnoseHandler) membersDiagnose(rdd *[]*Recommendation) error {
+func joinIDs(ids []uint64) string {
+	strs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		strs = append(strs, fmt.Sprint(id))
+	}
+	return strings.Join(strs, ",")
+}
+
+type memberDiagnoser struct {
+	svr *server.Server
+}
+
+func (d *memberDiagnoser) Name() string { return "members" }
+
+func (d *memberDiagnoser) Description() string {
+	return "check the number and connectivity of PD members and the leader changes"
+}
+
+func (d *memberDiagnoser) Diagnose() ([]*Recommendation, error) {
+	var rdd []*Recommendation
 	var lostMemberIDs, runningMemberIDs []uint64
 	var newLeaderID uint64
 	req := &pdpb.GetMembersRequest{Header: &pdpb.RequestHeader{ClusterId: d.svr.ClusterID()}}
 	members, err := d.svr.GetMembers(context.Background(), req)
 	if err != nil {
-		return errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
 	lenMembers := len(members.Members)
 	if lenMembers > 0 {
@@ -128,15 +218,15 @@ func (d *diagnoseHandler) membersDiagnose(rdd *[]*Recommendation) error {
 			}
 		}
 	} else {
-		return errors.Errorf("get PD member error")
+		return nil, errors.Errorf("get PD member error")
 	}
 	lenLostMembers := len(lostMemberIDs)
 	if newLeaderID != 0 {
-		*rdd = append(*rdd, diagnosePD(memberLeaderChanged, fmt.Sprintf("new leader %d", newLeaderID), ""))
+		rdd = append(rdd, diagnosePD(memberLeaderChanged, fmt.Sprintf("new leader %d", newLeaderID), ""))
 	}
 	if len(runningMemberIDs) == 1 {
 		// only one pd peer running
-		*rdd = append(*rdd, diagnosePD(memberOneInstance, fmt.Sprintf("running PD member ID %d", runningMemberIDs[0]), ""))
+		rdd = append(rdd, diagnosePD(memberOneInstance, fmt.Sprintf("running PD member ID %d", runningMemberIDs[0]), ""))
 	}
 	if lenLostMembers > 0 {
 		// some pd's peers can not be connected
@@ -144,29 +234,349 @@ func (d *diagnoseHandler) membersDiagnose(rdd *[]*Recommendation) error {
 		for _, m := range lostMemberIDs {
 			stringID = fmt.Sprintf("%s %d,", stringID, m)
 		}
-		*rdd = append(*rdd, diagnosePD(memberLostPeers, stringID, ""))
+		rdd = append(rdd, diagnosePD(memberLostPeers, stringID, ""))
 	}
 	if len(runningMemberIDs)%2 == 0 {
 		// alive pd's numbers is even
-		*rdd = append(*rdd, diagnosePD(memberEvenInstance, "", ""))
+		rdd = append(rdd, diagnosePD(memberEvenInstance, "", ""))
 	}
 	if float64(lenMembers)/2 < float64(lenLostMembers) {
-		*rdd = append(*rdd, diagnosePD(memberLostPeersMoreThanHalf, "", ""))
+		rdd = append(rdd, diagnosePD(memberLostPeersMoreThanHalf, "", ""))
+	}
+	return rdd, nil
+}
+
+// getServingStores returns the stores which are not tombstone, or nil if the cluster is not bootstrapped.
+func getServingStores(svr *server.Server) []*core.StoreInfo {
+	rc := svr.GetRaftCluster()
+	if rc == nil {
+		return nil
+	}
+	var stores []*core.StoreInfo
+	for _, store := range rc.GetStores() {
+		if !store.IsTombstone() {
+			stores = append(stores, store)
+		}
+	}
+	return stores
+}
+
+type storeStateDiagnoser struct {
+	svr *server.Server
+}
+
+func (d *storeStateDiagnoser) Name() string { return "store-state" }
+
+func (d *storeStateDiagnoser) Description() string {
+	return "check the disconnected and down stores"
+}
+
+func (d *storeStateDiagnoser) Diagnose() ([]*Recommendation, error) {
+	var rdd []*Recommendation
+	var disconnected, lostLongTime, down []uint64
+	maxStoreDownTime := d.svr.GetPersistOptions().GetMaxStoreDownTime()
+	for _, store := range getServingStores(d.svr) {
+		switch {
+		case store.DownTime() > maxStoreDownTime:
+			down = append(down, store.GetID())
+		case store.DownTime() > tikvLostLongTime:
+			lostLongTime = append(lostLongTime, store.GetID())
+		case store.IsDisconnected():
+			disconnected = append(disconnected, store.GetID())
+		}
+	}
+	if len(down) > 0 {
+		rdd = append(rdd, diagnosePD(tikvDown, "store IDs "+joinIDs(down), ""))
+	}
+	if len(lostLongTime) > 0 {
+		rdd = append(rdd, diagnosePD(tikvLostPeersLongTime, "store IDs "+joinIDs(lostLongTime), ""))
+	}
+	if len(disconnected) > 0 {
+		rdd = append(rdd, diagnosePD(tikvLostPeers, "store IDs "+joinIDs(disconnected), ""))
+	}
+	return rdd, nil
+}
+
+type storeSpaceDiagnoser struct {
+	svr *server.Server
+}
+
+func (d *storeSpaceDiagnoser) Name() string { return "store-space" }
+
+func (d *storeSpaceDiagnoser) Description() string {
+	return "check the stores with low available space"
+}
+
+func (d *storeSpaceDiagnoser) Diagnose() ([]*Recommendation, error) {
+	var rdd []*Recommendation
+	used := make(map[diagnoseType][]uint64)
+	for _, store := range getServingStores(d.svr) {
+		// The capacity is unknown before the first heartbeat.
+		if store.GetCapacity() == 0 {
+			continue
+		}
+		usedRatio := 1 - store.AvailableRatio()
+		switch {
+		case usedRatio > 0.9:
+			used[tikvCap90] = append(used[tikvCap90], store.GetID())
+		case usedRatio > 0.8:
+			used[tikvCap80] = append(used[tikvCap80], store.GetID())
+		case usedRatio > 0.7:
+			used[tikvCap70] = append(used[tikvCap70], store.GetID())
+		}
+	}
+	for _, typ := range []diagnoseType{tikvCap90, tikvCap80, tikvCap70} {
+		if ids := used[typ]; len(ids) > 0 {
+			rdd = append(rdd, diagnosePD(typ, "store IDs "+joinIDs(ids), ""))
+		}
+	}
+	return rdd, nil
+}
+
+type capacityForecastDiagnoser struct {
+	svr *server.Server
+}
+
+func (d *capacityForecastDiagnoser) Name() string { return "capacity-forecast" }
+
+func (d *capacityForecastDiagnoser) Description() string {
+	return "check the stores forecast to run out of space soon with the growth trends"
+}
+
+func (d *capacityForecastDiagnoser) Diagnose() ([]*Recommendation, error) {
+	rc := d.svr.GetRaftCluster()
+	if rc == nil {
+		return nil, nil
+	}
+	var rdd []*Recommendation
+	var lowSpace, full []uint64
+	within := func(t *typeutil.Duration, limit time.Duration) bool {
+		// Zero means the threshold has been reached, which is reported by the store-space check.
+		return t != nil && t.Duration > 0 && t.Duration < limit
+	}
+	for _, store := range rc.GetCapacityForecast("").Stores {
+		switch {
+		case within(store.TimeToFull, fullForecastTime):
+			full = append(full, store.StoreID)
+		case within(store.TimeToLowSpace, lowSpaceForecastTime):
+			lowSpace = append(lowSpace, store.StoreID)
+		}
+	}
+	if len(full) > 0 {
+		rdd = append(rdd, diagnosePD(tikvFullSoon, "store IDs "+joinIDs(full), ""))
+	}
+	if len(lowSpace) > 0 {
+		rdd = append(rdd, diagnosePD(tikvLowSpaceSoon, "store IDs "+joinIDs(lowSpace), ""))
+	}
+	return rdd, nil
+}
+
+type balanceDiagnoser struct {
+	svr *server.Server
+}
+
+func (d *balanceDiagnoser) Name() string { return "balance" }
+
+func (d *balanceDiagnoser) Description() string {
+	return "check whether the leader and region scores of the TiKV stores are balanced"
+}
+
+func (d *balanceDiagnoser) Diagnose() ([]*Recommendation, error) {
+	var rdd []*Recommendation
+	opt := d.svr.GetPersistOptions()
+	var leaderScores, regionScores []float64
+	for _, store := range getServingStores(d.svr) {
+		if !store.IsUp() || store.IsDisconnected() || core.IsTiFlashStore(store.GetMeta()) {
+			continue
+		}
+		leaderScores = append(leaderScores, store.LeaderScore(opt.GetLeaderSchedulePolicy(), 0))
+		regionScores = append(regionScores, store.RegionScore(opt.GetRegionScoreFormulaVersion(), opt.GetHighSpaceRatio(), opt.GetLowSpaceRatio(), 0))
 	}
-	return nil
+	if unbalanced, desc := isUnbalanced(leaderScores); unbalanced {
+		rdd = append(rdd, diagnosePD(scheduleUnbalancedLeader, desc, ""))
+	}
+	if unbalanced, desc := isUnbalanced(regionScores); unbalanced {
+		rdd = append(rdd, diagnosePD(scheduleUnbalancedRegion, desc, ""))
+	}
+	return rdd, nil
+}
+
+func isUnbalanced(scores []float64) (bool, string) {
+	if len(scores) < 2 {
+		return false, ""
+	}
+	min, max := math.MaxFloat64, -math.MaxFloat64
+	for _, score := range scores {
+		min = math.Min(min, score)
+		max = math.Max(max, score)
+	}
+	if max <= 0 || (max-min)/max <= unbalancedScoreRatio {
+		return false, ""
+	}
+	return true, fmt.Sprintf("max score %.2f, min score %.2f", max, min)
+}
+
+type operatorDiagnoser struct {
+	svr *server.Server
+}
+
+func (d *operatorDiagnoser) Name() string { return "operator" }
+
+func (d *operatorDiagnoser) Description() string {
+	return "check the operators which are running for a long time"
+}
+
+func (d *operatorDiagnoser) Diagnose() ([]*Recommendation, error) {
+	rc := d.svr.GetRaftCluster()
+	if rc == nil {
+		return nil, nil
+	}
+	var regionIDs []uint64
+	for _, op := range rc.GetOperatorController().GetOperators() {
+		if op.RunningTime() > stuckOperatorTime {
+			regionIDs = append(regionIDs, op.RegionID())
+		}
+	}
+	if len(regionIDs) == 0 {
+		return nil, nil
+	}
+	return []*Recommendation{diagnosePD(scheduleStuckOperator, "region IDs "+joinIDs(regionIDs), "")}, nil
+}
+
+type placementDiagnoser struct {
+	svr *server.Server
+}
+
+func (d *placementDiagnoser) Name() string { return "placement" }
+
+func (d *placementDiagnoser) Description() string {
+	return "check the regions which violate the replica count or placement rules"
+}
+
+func (d *placementDiagnoser) Diagnose() ([]*Recommendation, error) {
+	rc := d.svr.GetRaftCluster()
+	if rc == nil {
+		return nil, nil
+	}
+	var rdd []*Recommendation
+	if n := len(rc.GetRegionStatsByType(statistics.MissPeer)); n > 0 {
+		rdd = append(rdd, diagnosePD(placementMissPeer, fmt.Sprintf("%d regions", n), ""))
+	}
+	if n := len(rc.GetRegionStatsByType(statistics.ExtraPeer)); n > 0 {
+		rdd = append(rdd, diagnosePD(placementExtraPeer, fmt.Sprintf("%d regions", n), ""))
+	}
+	return rdd, nil
+}
+
+type gcSafePointDiagnoser struct {
+	svr *server.Server
+}
+
+func (d *gcSafePointDiagnoser) Name() string { return "gc-safepoint" }
+
+func (d *gcSafePointDiagnoser) Description() string {
+	return "check whether the minimum service GC safepoint is blocking GC"
+}
+
+func (d *gcSafePointDiagnoser) Diagnose() ([]*Recommendation, error) {
+	min, err := d.svr.GetMinServiceGCSafePointStatus()
+	if err != nil || min == nil {
+		return nil, err
+	}
+	desc := fmt.Sprintf("service %s, lag %s", min.ServiceID, min.Lag.Duration)
+	switch {
+	case min.Stale:
+		return []*Recommendation{diagnosePD(gcSafePointStale, desc, "")}, nil
+	case min.MaxLag.Duration == 0 && min.Lag.Duration > gcSafePointLagWarningTime:
+		return []*Recommendation{diagnosePD(gcSafePointLagLongTime, desc, "")}, nil
+	}
+	return nil, nil
+}
+
+type tsoDiagnoser struct {
+	svr *server.Server
+}
+
+func (d *tsoDiagnoser) Name() string { return "tso" }
+
+func (d *tsoDiagnoser) Description() string {
+	return "check whether the global TSO drifts from the local time"
+}
+
+func (d *tsoDiagnoser) Diagnose() ([]*Recommendation, error) {
+	// Use the drift recorded by the allocator, so the check doesn't consume a timestamp.
+	for _, status := range d.svr.GetTSOAllocatorManager().GetHealthStatus() {
+		if status.DCLocation != tso.GlobalDCLocation {
+			continue
+		}
+		if drift := status.Drift.Duration; drift > maxTSODrift || drift < -maxTSODrift {
+			return []*Recommendation{diagnosePD(tsoDrift, fmt.Sprintf("drift %s", drift), "")}, nil
+		}
+	}
+	return nil, nil
+}
+
+type replicationDiagnoser struct {
+	svr *server.Server
+}
+
+func (d *replicationDiagnoser) Name() string { return "replication-mode" }
+
+func (d *replicationDiagnoser) Description() string {
+	return "check whether the DR auto-sync replication is degraded"
+}
+
+func (d *replicationDiagnoser) Diagnose() ([]*Recommendation, error) {
+	rc := d.svr.GetRaftCluster()
+	if rc == nil {
+		return nil, nil
+	}
+	status := rc.GetReplicationMode().GetReplicationStatusHTTP()
+	if status.Mode != "dr-auto-sync" {
+		return nil, nil
+	}
+	switch status.DrAutoSync.State {
+	case "async":
+		return []*Recommendation{diagnosePD(replicationAsync, "", "")}, nil
+	case "sync_recover":
+		return []*Recommendation{diagnosePD(replicationSyncRecover, fmt.Sprintf("progress %.2f", status.DrAutoSync.RecoverProgress), "")}, nil
+	}
+	return nil, nil
 }
 
 // @Tags diagnose
 // @Summary Diagnostic information of the cluster.
+// @Param checks query string false "The comma-separated checks to run, all the checks are run if it's empty"
 // @Produce json
 // @Success 200 {array} Recommendation
+// @Failure 400 {string} string "The input is invalid."
 // @Failure 500 {string} string "PD server failed to proceed the request."
 // @Router /diagnose [get]
 func (d *diagnoseHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	rdd := []*Recommendation{}
-	if err := d.membersDiagnose(&rdd); err != nil {
+	var checks []string
+	for _, check := range strings.Split(r.URL.Query().Get("checks"), ",") {
+		if check = strings.TrimSpace(check); check != "" {
+			checks = append(checks, check)
+		}
+	}
+	rdd, err := d.registry.Run(checks)
+	if err != nil {
+		if errs.ErrUnknownDiagnoseCheck.Equal(err) {
+			d.rd.JSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		d.rd.JSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	// The failed checks are reported in the recommendations with the error field.
 	d.rd.JSON(w, http.StatusOK, rdd)
 }
+
+// @Tags diagnose
+// @Summary List all the diagnose checks.
+// @Produce json
+// @Success 200 {array} diagnose.CheckInfo
+// @Router /diagnose/checks [get]
+func (d *diagnoseHandler) GetChecks(w http.ResponseWriter, r *http.Request) {
+	d.rd.JSON(w, http.StatusOK, d.registry.GetChecks())
+}
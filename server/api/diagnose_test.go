@@ -16,9 +16,12 @@ package api
 import (
 	"encoding/json"
 	"io"
+	"net/http"
 
 	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/diagnose"
 )
 
 var _ = Suite(&testDiagnoseAPISuite{})
@@ -33,6 +36,7 @@ func checkDiagnoseResponse(c *C, body []byte) {
 		c.Assert(len(r.Level) != 0, IsTrue)
 		c.Assert(len(r.Description) != 0, IsTrue)
 		c.Assert(len(r.Instruction) != 0, IsTrue)
+		c.Assert(r.Error, Equals, "")
 	}
 }
 
@@ -57,3 +61,32 @@ func (s *testDiagnoseAPISuite) TestDiagnoseSlice(c *C) {
 	c.Assert(err, IsNil)
 	checkDiagnoseResponse(c, buf)
 }
+
+func (s *testDiagnoseAPISuite) TestDiagnoseChecks(c *C) {
+	svr, cleanup := mustNewServer(c)
+	defer cleanup()
+	mustWaitLeader(c, []*server.Server{svr})
+	mustBootstrapCluster(c, svr)
+	mustPutStore(c, svr, 1, metapb.StoreState_Up, nil)
+	addr := svr.GetAddr() + apiPrefix + "/api/v1/diagnose"
+
+	var checks []diagnose.CheckInfo
+	c.Assert(readJSON(testDialClient, addr+"/checks", &checks), IsNil)
+	c.Assert(checks, HasLen, 10)
+	for _, check := range checks {
+		var rdd []Recommendation
+		c.Assert(readJSON(testDialClient, addr+"?checks="+check.Name, &rdd), IsNil)
+		for _, r := range rdd {
+			c.Assert(r.Check, Equals, check.Name)
+		}
+	}
+
+	var rdd []Recommendation
+	c.Assert(readJSON(testDialClient, addr+"?checks=store-state,tso", &rdd), IsNil)
+	c.Assert(rdd, HasLen, 0)
+
+	resp, err := testDialClient.Get(addr + "?checks=store-state,unknown")
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusBadRequest)
+	resp.Body.Close()
+}
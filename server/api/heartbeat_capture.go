@@ -0,0 +1,93 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/hbcapture"
+	"github.com/unrolled/render"
+)
+
+type heartbeatCaptureHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newHeartbeatCaptureHandler(svr *server.Server, rd *render.Render) *heartbeatCaptureHandler {
+	return &heartbeatCaptureHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+// @Tags admin
+// @Summary Get the status of the running or the last heartbeat capture.
+// @Produce json
+// @Success 200 {object} hbcapture.Status
+// @Router /admin/heartbeat-capture [get]
+func (h *heartbeatCaptureHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	h.rd.JSON(w, http.StatusOK, h.svr.GetHeartbeatCapturer().GetStatus())
+}
+
+// @Tags admin
+// @Summary Start to capture the region and store heartbeats with the store metas into a file in the data directory, which can be replayed by pd-heartbeat-replay.
+// @Accept json
+// @Param body body hbcapture.Config true "json params"
+// @Produce json
+// @Success 200 {object} hbcapture.Status
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 412 {string} string "A heartbeat capture is already running."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /admin/heartbeat-capture [post]
+func (h *heartbeatCaptureHandler) Start(w http.ResponseWriter, r *http.Request) {
+	var cfg hbcapture.Config
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &cfg); err != nil {
+		return
+	}
+	var stores []*metapb.Store
+	if rc := h.svr.GetRaftCluster(); rc != nil {
+		stores = rc.GetMetaStores()
+	}
+	status, err := h.svr.GetHeartbeatCapturer().Start(cfg, stores)
+	switch {
+	case err == nil:
+		h.rd.JSON(w, http.StatusOK, status)
+	case errs.ErrHeartbeatCaptureConfig.Equal(err):
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+	case errs.ErrHeartbeatCaptureRunning.Equal(err):
+		h.rd.JSON(w, http.StatusPreconditionFailed, err.Error())
+	default:
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// @Tags admin
+// @Summary Stop the running heartbeat capture.
+// @Produce json
+// @Success 200 {object} hbcapture.Status
+// @Failure 412 {string} string "No heartbeat capture is running."
+// @Router /admin/heartbeat-capture [delete]
+func (h *heartbeatCaptureHandler) Stop(w http.ResponseWriter, r *http.Request) {
+	status, err := h.svr.GetHeartbeatCapturer().Stop()
+	if err != nil {
+		h.rd.JSON(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, status)
+}
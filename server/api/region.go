@@ -30,6 +30,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/tikv/pd/pkg/apiutil"
 	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/cluster"
 	"github.com/tikv/pd/server/core"
 	"github.com/tikv/pd/server/schedule/operator"
 	"github.com/tikv/pd/server/statistics"
@@ -332,6 +333,7 @@ func (h *regionsHandler) GetStoreRegions(w http.ResponseWriter, r *http.Request)
 
 // @Tags region
 // @Summary List all regions that miss peer.
+// @Description The region statistics are updated asynchronously after the heartbeats, so the result is eventually consistent.
 // @Produce json
 // @Success 200 {object} RegionsInfo
 // @Failure 500 {string} string "PD server failed to proceed the request."
@@ -349,6 +351,7 @@ func (h *regionsHandler) GetMissPeerRegions(w http.ResponseWriter, r *http.Reque
 
 // @Tags region
 // @Summary List all regions that has extra peer.
+// @Description The region statistics are updated asynchronously after the heartbeats, so the result is eventually consistent.
 // @Produce json
 // @Success 200 {object} RegionsInfo
 // @Failure 500 {string} string "PD server failed to proceed the request."
@@ -366,6 +369,7 @@ func (h *regionsHandler) GetExtraPeerRegions(w http.ResponseWriter, r *http.Requ
 
 // @Tags region
 // @Summary List all regions that has pending peer.
+// @Description The region statistics are updated asynchronously after the heartbeats, so the result is eventually consistent.
 // @Produce json
 // @Success 200 {object} RegionsInfo
 // @Failure 500 {string} string "PD server failed to proceed the request."
@@ -383,6 +387,7 @@ func (h *regionsHandler) GetPendingPeerRegions(w http.ResponseWriter, r *http.Re
 
 // @Tags region
 // @Summary List all regions that has down peer.
+// @Description The region statistics are updated asynchronously after the heartbeats, so the result is eventually consistent.
 // @Produce json
 // @Success 200 {object} RegionsInfo
 // @Failure 500 {string} string "PD server failed to proceed the request."
@@ -400,6 +405,7 @@ func (h *regionsHandler) GetDownPeerRegions(w http.ResponseWriter, r *http.Reque
 
 // @Tags region
 // @Summary List all regions that has learner peer.
+// @Description The region statistics are updated asynchronously after the heartbeats, so the result is eventually consistent.
 // @Produce json
 // @Success 200 {object} RegionsInfo
 // @Failure 500 {string} string "PD server failed to proceed the request."
@@ -417,6 +423,7 @@ func (h *regionsHandler) GetLearnerPeerRegions(w http.ResponseWriter, r *http.Re
 
 // @Tags region
 // @Summary List all regions that has offline peer.
+// @Description The region statistics are updated asynchronously after the heartbeats, so the result is eventually consistent.
 // @Produce json
 // @Success 200 {object} RegionsInfo
 // @Failure 500 {string} string "PD server failed to proceed the request."
@@ -432,8 +439,47 @@ func (h *regionsHandler) GetOfflinePeer(w http.ResponseWriter, r *http.Request)
 	h.rd.JSON(w, http.StatusOK, regionsInfo)
 }
 
+// @Tags region
+// @Summary List the region anomalies found by the last region consistency check, the check is run if it has never run.
+// @Param type query string false "Type of the anomalies" Enums(hole, overlap, stale-epoch, no-leader, tombstone-peer)
+// @Produce json
+// @Success 200 {object} cluster.RegionConsistencyReport
+// @Failure 400 {string} string "The input is invalid."
+// @Router /regions/check/anomalies [get]
+func (h *regionsHandler) GetRegionAnomalies(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	report := rc.GetRegionConsistencyReport()
+	if report == nil {
+		report = rc.CheckRegionConsistency()
+	}
+	h.writeRegionAnomalies(w, r, report)
+}
+
+// @Tags region
+// @Summary Check the region consistency immediately and list the anomalies found.
+// @Param type query string false "Type of the anomalies" Enums(hole, overlap, stale-epoch, no-leader, tombstone-peer)
+// @Produce json
+// @Success 200 {object} cluster.RegionConsistencyReport
+// @Failure 400 {string} string "The input is invalid."
+// @Router /regions/check/anomalies [post]
+func (h *regionsHandler) CheckRegionAnomalies(w http.ResponseWriter, r *http.Request) {
+	h.writeRegionAnomalies(w, r, getCluster(r).CheckRegionConsistency())
+}
+
+func (h *regionsHandler) writeRegionAnomalies(w http.ResponseWriter, r *http.Request, report *cluster.RegionConsistencyReport) {
+	if typ := r.URL.Query().Get("type"); typ != "" {
+		if !cluster.IsValidRegionAnomalyType(typ) {
+			h.rd.JSON(w, http.StatusBadRequest, fmt.Sprintf("unknown anomaly type %s", typ))
+			return
+		}
+		report = report.Filter(typ)
+	}
+	h.rd.JSON(w, http.StatusOK, report)
+}
+
 // @Tags region
 // @Summary List all empty regions.
+// @Description The region statistics are updated asynchronously after the heartbeats, so the result is eventually consistent.
 // @Produce json
 // @Success 200 {object} RegionsInfo
 // @Failure 500 {string} string "PD server failed to proceed the request."
@@ -19,7 +19,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"net/http"
 	"net/url"
+	"reflect"
 	"sort"
 	"testing"
 
@@ -27,7 +29,9 @@ import (
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/pkg/testutil"
 	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/cluster"
 	"github.com/tikv/pd/server/core"
 )
 
@@ -160,16 +164,21 @@ func (s *testRegionSuite) TestRegionCheck(c *C) {
 	c.Assert(r1, DeepEquals, NewRegionInfo(r))
 
 	url = fmt.Sprintf("%s/regions/check/%s", s.urlPrefix, "down-peer")
-	r2 := &RegionsInfo{}
-	c.Assert(readJSON(testDialClient, url, r2), IsNil)
-	r2.Adjust()
-	c.Assert(r2, DeepEquals, &RegionsInfo{Count: 1, Regions: []RegionInfo{*NewRegionInfo(r)}})
+	// The region statistics are updated asynchronously.
+	testutil.WaitUntil(c, func(c *C) bool {
+		r2 := &RegionsInfo{}
+		c.Assert(readJSON(testDialClient, url, r2), IsNil)
+		r2.Adjust()
+		return reflect.DeepEqual(r2, &RegionsInfo{Count: 1, Regions: []RegionInfo{*NewRegionInfo(r)}})
+	})
 
 	url = fmt.Sprintf("%s/regions/check/%s", s.urlPrefix, "pending-peer")
-	r3 := &RegionsInfo{}
-	c.Assert(readJSON(testDialClient, url, r3), IsNil)
-	r3.Adjust()
-	c.Assert(r3, DeepEquals, &RegionsInfo{Count: 1, Regions: []RegionInfo{*NewRegionInfo(r)}})
+	testutil.WaitUntil(c, func(c *C) bool {
+		r3 := &RegionsInfo{}
+		c.Assert(readJSON(testDialClient, url, r3), IsNil)
+		r3.Adjust()
+		return reflect.DeepEqual(r3, &RegionsInfo{Count: 1, Regions: []RegionInfo{*NewRegionInfo(r)}})
+	})
 
 	url = fmt.Sprintf("%s/regions/check/%s", s.urlPrefix, "offline-peer")
 	r4 := &RegionsInfo{}
@@ -180,10 +189,12 @@ func (s *testRegionSuite) TestRegionCheck(c *C) {
 	r = r.Clone(core.SetApproximateSize(1))
 	mustRegionHeartbeat(c, s.svr, r)
 	url = fmt.Sprintf("%s/regions/check/%s", s.urlPrefix, "empty-region")
-	r5 := &RegionsInfo{}
-	c.Assert(readJSON(testDialClient, url, r5), IsNil)
-	r5.Adjust()
-	c.Assert(r5, DeepEquals, &RegionsInfo{Count: 1, Regions: []RegionInfo{*NewRegionInfo(r)}})
+	testutil.WaitUntil(c, func(c *C) bool {
+		r5 := &RegionsInfo{}
+		c.Assert(readJSON(testDialClient, url, r5), IsNil)
+		r5.Adjust()
+		return reflect.DeepEqual(r5, &RegionsInfo{Count: 1, Regions: []RegionInfo{*NewRegionInfo(r)}})
+	})
 
 	r = r.Clone(core.SetApproximateSize(1))
 	mustRegionHeartbeat(c, s.svr, r)
@@ -532,3 +543,57 @@ func BenchmarkHexRegionKeyStr(b *testing.B) {
 		_ = core.HexRegionKeyStr(key)
 	}
 }
+
+var _ = Suite(&testRegionAnomaliesSuite{})
+
+type testRegionAnomaliesSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testRegionAnomaliesSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+}
+
+func (s *testRegionAnomaliesSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testRegionAnomaliesSuite) TestRegionAnomalies(c *C) {
+	url := fmt.Sprintf("%s/regions/check/anomalies", s.urlPrefix)
+	report := &cluster.RegionConsistencyReport{}
+	c.Assert(readJSON(testDialClient, url, report), IsNil)
+	c.Assert(report.RegionCount, Equals, 1)
+	c.Assert(report.Anomalies, HasLen, 0)
+
+	// The bootstrapped region is replaced, so ["", "a") and ["b", "") become holes.
+	mustRegionHeartbeat(c, s.svr, newTestRegionInfo(2, 1, []byte("a"), []byte("b"), core.SetRegionVersion(2)))
+	// The last report is returned until the next check.
+	c.Assert(readJSON(testDialClient, url, report), IsNil)
+	c.Assert(report.Anomalies, HasLen, 0)
+
+	err := postJSON(testDialClient, url, nil, func(res []byte, _ int) {
+		c.Assert(json.Unmarshal(res, report), IsNil)
+	})
+	c.Assert(err, IsNil)
+	c.Assert(report.RegionCount, Equals, 1)
+	c.Assert(report.Counts[cluster.RegionAnomalyHole], Equals, 2)
+	c.Assert(report.Anomalies, HasLen, 2)
+
+	report = &cluster.RegionConsistencyReport{}
+	c.Assert(readJSON(testDialClient, url+"?type=overlap", report), IsNil)
+	c.Assert(report.Counts, DeepEquals, map[string]int{cluster.RegionAnomalyOverlap: 0})
+	c.Assert(report.Anomalies, HasLen, 0)
+
+	resp, err := testDialClient.Get(url + "?type=unknown")
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusBadRequest)
+	resp.Body.Close()
+}
@@ -15,7 +15,11 @@ package api
 
 import (
 	"net/http"
+	"time"
 
+	"github.com/pingcap/errors"
+	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/server"
 	"github.com/unrolled/render"
 )
@@ -40,3 +44,60 @@ func newReplicationModeHandler(svr *server.Server, rd *render.Render) *replicati
 func (h *replicationModeHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
 	h.rd.JSON(w, http.StatusOK, getCluster(r).GetReplicationMode().GetReplicationStatusHTTP())
 }
+
+// @Tags replication_mode
+// @Summary Get state transition history of replication mode
+// @Produce json
+// @Success 200 {array} replication.DRStateTransition
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /replication_mode/history [get]
+func (h *replicationModeHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	history, err := getCluster(r).GetReplicationMode().GetDRStateHistory()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, history)
+}
+
+type switchReplicationStateInput struct {
+	State  string `json:"state"`
+	Reason string `json:"reason"`
+	// Hold is the duration to pause automatic state switching, such as "30m".
+	Hold string `json:"hold,omitempty"`
+}
+
+// @Tags replication_mode
+// @Summary Force replication mode to switch to a state.
+// @Accept json
+// @Param body body switchReplicationStateInput true "target state and reason"
+// @Produce json
+// @Success 200 {string} string "Switch replication state successfully."
+// @Failure 400 {string} string "The input is invalid or the state cannot be switched."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /replication_mode/state [post]
+func (h *replicationModeHandler) SwitchState(w http.ResponseWriter, r *http.Request) {
+	var input switchReplicationStateInput
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	var hold time.Duration
+	if input.Hold != "" {
+		var err error
+		hold, err = time.ParseDuration(input.Hold)
+		if err != nil || hold < 0 {
+			h.rd.JSON(w, http.StatusBadRequest, "invalid hold duration")
+			return
+		}
+	}
+	err := getCluster(r).GetReplicationMode().SwitchDRState(input.State, input.Reason, hold)
+	if err != nil {
+		if errors.ErrorEqual(err, errs.ErrReplicationStateSwitch.FastGenByArgs()) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "Switch replication state successfully.")
+}
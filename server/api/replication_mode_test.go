@@ -0,0 +1,84 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/replication"
+)
+
+var _ = Suite(&testReplicationModeSuite{})
+
+type testReplicationModeSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testReplicationModeSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c, func(cfg *config.Config) {
+		cfg.ReplicationMode.ReplicationMode = "dr-auto-sync"
+		cfg.ReplicationMode.DRAutoSync.LabelKey = "zone"
+		cfg.ReplicationMode.DRAutoSync.Primary = "zone1"
+		cfg.ReplicationMode.DRAutoSync.DR = "zone2"
+		cfg.ReplicationMode.DRAutoSync.PrimaryReplicas = 1
+		cfg.ReplicationMode.DRAutoSync.DRReplicas = 1
+	})
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1/replication_mode", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+}
+
+func (s *testReplicationModeSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testReplicationModeSuite) TestSwitchState(c *C) {
+	switchState := func(state, reason string) error {
+		data, err := json.Marshal(map[string]string{"state": state, "reason": reason})
+		c.Assert(err, IsNil)
+		return postJSON(testDialClient, s.urlPrefix+"/state", data)
+	}
+	getState := func() string {
+		var status replication.HTTPReplicationStatus
+		c.Assert(readJSON(testDialClient, s.urlPrefix+"/status", &status), IsNil)
+		return status.DrAutoSync.State
+	}
+
+	c.Assert(getState(), Equals, "sync")
+	c.Assert(switchState("async", ""), NotNil)
+	c.Assert(switchState("async", "drill"), IsNil)
+	c.Assert(getState(), Equals, "async")
+	c.Assert(switchState("sync", "drill done"), NotNil)
+	c.Assert(switchState("sync_recover", "drill done"), IsNil)
+	c.Assert(getState(), Equals, "sync_recover")
+	c.Assert(postJSON(testDialClient, s.urlPrefix+"/state", []byte(`{"state":"async","reason":"drill","hold":"-1s"}`)), NotNil)
+
+	var history []*replication.DRStateTransition
+	c.Assert(readJSON(testDialClient, s.urlPrefix+"/history", &history), IsNil)
+	c.Assert(history, HasLen, 3)
+	c.Assert(history[1].To, Equals, "async")
+	c.Assert(history[1].Trigger, Equals, "manual")
+	c.Assert(history[1].Reason, Equals, "drill")
+	c.Assert(history[2].From, Equals, "async")
+	c.Assert(history[2].To, Equals, "sync_recover")
+}
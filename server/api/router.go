@@ -131,6 +131,7 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 	clusterRouter.HandleFunc("/stores/limit", storesHandler.SetAllLimit).Methods("POST")
 	clusterRouter.HandleFunc("/stores/limit/scene", storesHandler.SetStoreLimitScene).Methods("POST")
 	clusterRouter.HandleFunc("/stores/limit/scene", storesHandler.GetStoreLimitScene).Methods("GET")
+	clusterRouter.HandleFunc("/stores/capacity-forecast", storesHandler.GetCapacityForecast).Methods("GET")
 
 	labelsHandler := newLabelsHandler(svr, rd)
 	clusterRouter.HandleFunc("/labels", labelsHandler.Get).Methods("GET")
@@ -165,6 +166,8 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 	clusterRouter.HandleFunc("/regions/check/learner-peer", regionsHandler.GetLearnerPeerRegions).Methods("GET")
 	clusterRouter.HandleFunc("/regions/check/empty-region", regionsHandler.GetEmptyRegion).Methods("GET")
 	clusterRouter.HandleFunc("/regions/check/offline-peer", regionsHandler.GetOfflinePeer).Methods("GET")
+	clusterRouter.HandleFunc("/regions/check/anomalies", regionsHandler.GetRegionAnomalies).Methods("GET")
+	clusterRouter.HandleFunc("/regions/check/anomalies", regionsHandler.CheckRegionAnomalies).Methods("POST")
 
 	clusterRouter.HandleFunc("/regions/check/hist-size", regionsHandler.GetSizeHistogram).Methods("GET")
 	clusterRouter.HandleFunc("/regions/check/hist-keys", regionsHandler.GetKeysHistogram).Methods("GET")
@@ -196,19 +199,37 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 	adminHandler := newAdminHandler(svr, rd)
 	clusterRouter.HandleFunc("/admin/cache/region/{id}", adminHandler.HandleDropCacheRegion).Methods("DELETE")
 	clusterRouter.HandleFunc("/admin/reset-ts", adminHandler.ResetTS).Methods("POST")
+	clusterRouter.HandleFunc("/admin/storage/region/verify", adminHandler.GetRegionStorageReport).Methods("GET")
+	clusterRouter.HandleFunc("/admin/storage/region/verify", adminHandler.VerifyRegionStorage).Methods("POST")
 	apiRouter.HandleFunc("/admin/persist-file/{file_name}", adminHandler.persistFile).Methods("POST")
 	clusterRouter.HandleFunc("/admin/replication_mode/wait-async", adminHandler.UpdateWaitAsyncTime).Methods("POST")
 
+	unsafeOperationHandler := newUnsafeOperationHandler(svr, rd)
+	clusterRouter.HandleFunc("/admin/unsafe/remove-failed-stores", unsafeOperationHandler.RemoveFailedStores).Methods("POST")
+	clusterRouter.HandleFunc("/admin/unsafe/remove-failed-stores/show", unsafeOperationHandler.GetFailedStoresRemovalStatus).Methods("GET")
+	clusterRouter.HandleFunc("/admin/unsafe/remove-failed-stores/check", unsafeOperationHandler.CheckFailedStoresRemoval).Methods("POST")
+	clusterRouter.HandleFunc("/admin/unsafe/plan/{id}", unsafeOperationHandler.GetStoreRecoveryPlan).Methods("GET")
+	clusterRouter.HandleFunc("/admin/unsafe/plan/{id}", unsafeOperationHandler.DeliverStoreRecoveryPlan).Methods("POST")
+
+	heartbeatCaptureHandler := newHeartbeatCaptureHandler(svr, rd)
+	apiRouter.HandleFunc("/admin/heartbeat-capture", heartbeatCaptureHandler.GetStatus).Methods("GET")
+	apiRouter.HandleFunc("/admin/heartbeat-capture", heartbeatCaptureHandler.Start).Methods("POST")
+	apiRouter.HandleFunc("/admin/heartbeat-capture", heartbeatCaptureHandler.Stop).Methods("DELETE")
+
 	logHandler := newLogHandler(svr, rd)
 	apiRouter.HandleFunc("/admin/log", logHandler.Handle).Methods("POST")
 
 	replicationModeHandler := newReplicationModeHandler(svr, rd)
 	clusterRouter.HandleFunc("/replication_mode/status", replicationModeHandler.GetStatus)
+	clusterRouter.HandleFunc("/replication_mode/history", replicationModeHandler.GetHistory).Methods("GET")
+	clusterRouter.HandleFunc("/replication_mode/state", replicationModeHandler.SwitchState).Methods("POST")
 
 	componentHandler := newComponentHandler(svr, rd)
 	clusterRouter.HandleFunc("/component", componentHandler.Register).Methods("POST")
 	clusterRouter.HandleFunc("/component/{component}/{addr}", componentHandler.UnRegister).Methods("DELETE")
+	clusterRouter.HandleFunc("/component/{component}/{addr}/heartbeat", componentHandler.Heartbeat).Methods("POST")
 	clusterRouter.HandleFunc("/component", componentHandler.GetAllAddress).Methods("GET")
+	clusterRouter.HandleFunc("/component/topology", componentHandler.GetTopology).Methods("GET")
 	clusterRouter.HandleFunc("/component/{type}", componentHandler.GetAddress).Methods("GET")
 
 	pluginHandler := newPluginHandler(handler, rd)
@@ -216,7 +237,9 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 	apiRouter.HandleFunc("/plugin", pluginHandler.UnloadPlugin).Methods("DELETE")
 
 	apiRouter.Handle("/health", newHealthHandler(svr, rd)).Methods("GET")
-	apiRouter.Handle("/diagnose", newDiagnoseHandler(svr, rd)).Methods("GET")
+	diagnoseHandler := newDiagnoseHandler(svr, rd)
+	apiRouter.Handle("/diagnose", diagnoseHandler).Methods("GET")
+	apiRouter.HandleFunc("/diagnose/checks", diagnoseHandler.GetChecks).Methods("GET")
 	apiRouter.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
 	// metric query use to query metric data, the protocol is compatible with prometheus.
 	apiRouter.Handle("/metric/query", newQueryMetric(svr)).Methods("GET", "POST")
@@ -225,6 +248,10 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 	// tso API
 	tsoHandler := newTSOHandler(svr, rd)
 	apiRouter.HandleFunc("/tso/allocator/transfer/{name}", tsoHandler.TransferLocalTSOAllocator).Methods("POST")
+	apiRouter.HandleFunc("/tso/status", tsoHandler.GetStatus).Methods("GET")
+	apiRouter.HandleFunc("/tso/dc-locations", tsoHandler.GetDCLocations).Methods("GET")
+	apiRouter.HandleFunc("/tso/dc-location/{dc_location}/migrate", tsoHandler.MigrateDCLocation).Methods("POST")
+	apiRouter.HandleFunc("/tso/dc-location/{dc_location}", tsoHandler.RemoveDCLocation).Methods("DELETE")
 
 	// profile API
 	apiRouter.HandleFunc("/debug/pprof/profile", pprof.Profile)
@@ -240,6 +267,8 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 	// service GC safepoint API
 	serviceGCSafepointHandler := newServiceGCSafepointHandler(svr, rd)
 	apiRouter.HandleFunc("/gc/safepoint", serviceGCSafepointHandler.List).Methods("GET")
+	apiRouter.HandleFunc("/gc/safepoint/expire-stale", serviceGCSafepointHandler.ExpireStale).Methods("POST")
+	apiRouter.HandleFunc("/gc/safepoint/{service_id}", serviceGCSafepointHandler.Get).Methods("GET")
 	apiRouter.HandleFunc("/gc/safepoint/{service_id}", serviceGCSafepointHandler.Delete).Methods("DELETE")
 
 	// API to set or unset failpoints
@@ -254,7 +283,7 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 	// Deprecated
 	rootRouter.Handle("/health", newHealthHandler(svr, rd)).Methods("GET")
 	// Deprecated
-	rootRouter.Handle("/diagnose", newDiagnoseHandler(svr, rd)).Methods("GET")
+	rootRouter.Handle("/diagnose", diagnoseHandler).Methods("GET")
 	// Deprecated
 	rootRouter.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
 
@@ -19,10 +19,12 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
 	. "github.com/pingcap/check"
 	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/kv"
 	"github.com/tikv/pd/server/schedule/placement"
 )
 
@@ -336,6 +338,30 @@ func (s *testRuleSuite) TestSetAll(c *C) {
 	}
 }
 
+func (s *testRuleSuite) TestSetManyRules(c *C) {
+	// The rules are more than the max operations of a transaction.
+	n := kv.MaxTxnOps + 100
+	rules := make([]*placement.Rule, 0, n)
+	for i := 0; i < n; i++ {
+		rules = append(rules, &placement.Rule{GroupID: "many", ID: strconv.Itoa(i), StartKeyHex: "1111", EndKeyHex: "3333", Role: "voter", Count: 1})
+	}
+	data, err := json.Marshal(rules)
+	c.Assert(err, IsNil)
+	c.Assert(postJSON(testDialClient, s.urlPrefix+"/rules", data), IsNil)
+
+	var resp []*placement.Rule
+	c.Assert(readJSON(testDialClient, s.urlPrefix+"/rules/group/many", &resp), IsNil)
+	c.Assert(resp, HasLen, n)
+	var persisted int
+	err = s.svr.GetStorage().LoadRules(func(k, v string) {
+		if strings.HasPrefix(k, hex.EncodeToString([]byte("many"))+"-") {
+			persisted++
+		}
+	})
+	c.Assert(err, IsNil)
+	c.Assert(persisted, Equals, n)
+}
+
 func (s *testRuleSuite) TestGetAllByGroup(c *C) {
 	rule := placement.Rule{GroupID: "c", ID: "20", StartKeyHex: "1111", EndKeyHex: "3333", Role: "voter", Count: 1}
 	data, err := json.Marshal(rule)
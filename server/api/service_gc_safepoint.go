@@ -37,6 +37,8 @@ func newServiceGCSafepointHandler(svr *server.Server, rd *render.Render) *servic
 type listServiceGCSafepoint struct {
 	ServiceGCSafepoints []*core.ServiceSafePoint `json:"service_gc_safe_points"`
 	GCSafePoint         uint64                   `json:"gc_safe_point"`
+	// MinServiceGCSafepoint is the service which is blocking GC.
+	MinServiceGCSafepoint *server.ServiceGCSafePointStatus `json:"min_service_gc_safe_point,omitempty"`
 }
 
 // @Tags servicegcsafepoint
@@ -57,13 +59,56 @@ func (h *serviceGCSafepointHandler) List(w http.ResponseWriter, r *http.Request)
 		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	min, err := h.svr.GetMinServiceGCSafePointStatus()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	list := listServiceGCSafepoint{
-		GCSafePoint:         gcSafepoint,
-		ServiceGCSafepoints: ssps,
+		GCSafePoint:           gcSafepoint,
+		ServiceGCSafepoints:   ssps,
+		MinServiceGCSafepoint: min,
 	}
 	h.rd.JSON(w, http.StatusOK, list)
 }
 
+// @Tags servicegcsafepoint
+// @Summary Get the status and the update history of a service GC safepoint.
+// @Param service_id path string true "Service ID"
+// @Produce json
+// @Success 200 {object} server.ServiceGCSafePointStatus
+// @Failure 404 {string} string "The service GC safepoint does not exist."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /gc/safepoint/{service_id} [get]
+func (h *serviceGCSafepointHandler) Get(w http.ResponseWriter, r *http.Request) {
+	serviceID := mux.Vars(r)["service_id"]
+	status, err := h.svr.GetServiceGCSafePointStatus(serviceID)
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if status == nil {
+		h.rd.JSON(w, http.StatusNotFound, "The service GC safepoint does not exist.")
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, status)
+}
+
+// @Tags servicegcsafepoint
+// @Summary Expire the service GC safepoints which lag more than the max lag.
+// @Produce json
+// @Success 200 {array} string "The expired services."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /gc/safepoint/expire-stale [post]
+func (h *serviceGCSafepointHandler) ExpireStale(w http.ResponseWriter, r *http.Request) {
+	expired, err := h.svr.ExpireStaleServiceGCSafePoints()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, expired)
+}
+
 // @Tags servicegcsafepoint
 // @Summary Delete a service GC safepoint.
 // @Param service_id path string true "Service ID"
@@ -73,9 +118,8 @@ func (h *serviceGCSafepointHandler) List(w http.ResponseWriter, r *http.Request)
 // @Router /gc/safepoint/{service_id} [delete]
 // @Tags rule
 func (h *serviceGCSafepointHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	storage := h.svr.GetStorage()
 	serviceID := mux.Vars(r)["service_id"]
-	err := storage.RemoveServiceGCSafePoint(serviceID)
+	err := h.svr.RemoveServiceGCSafePoint(serviceID)
 	if err != nil {
 		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
 		return
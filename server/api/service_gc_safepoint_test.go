@@ -14,14 +14,19 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/typeutil"
 	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/core"
 )
 
@@ -83,13 +88,104 @@ func (s *testServiceGCSafepointSuite) TestRegionStats(c *C) {
 	listResp := &listServiceGCSafepoint{}
 	err = apiutil.ReadJSON(res.Body, listResp)
 	c.Assert(err, IsNil)
+	min := listResp.MinServiceGCSafepoint
+	c.Assert(min, NotNil)
+	c.Assert(min.ServiceSafePoint, DeepEquals, list.ServiceGCSafepoints[0])
+	c.Assert(min.Lag.Duration, Greater, time.Duration(0))
+	c.Assert(min.Stale, IsFalse)
+	listResp.MinServiceGCSafepoint = nil
 	c.Assert(listResp, DeepEquals, list)
 
+	// Update the safepoint of "a" by gRPC to record the history.
+	cfg := s.svr.GetPersistOptions().GetPDServerConfig().Clone()
+	cfg.MaxServiceGCSafePointLag = typeutil.NewDuration(time.Hour)
+	cfg.ServiceGCSafePointPolicies = []config.ServiceGCSafePointPolicy{{ServiceID: "a", MaxTTL: typeutil.NewDuration(time.Minute)}}
+	c.Assert(s.svr.SetPDServerConfig(*cfg), IsNil)
+	_, err = s.svr.UpdateServiceGCSafePoint(context.Background(), &pdpb.UpdateServiceGCSafePointRequest{
+		Header:    &pdpb.RequestHeader{ClusterId: s.svr.ClusterID()},
+		ServiceId: []byte("a"),
+		TTL:       3600,
+		SafePoint: 1,
+	})
+	c.Assert(err, IsNil)
+	status := &server.ServiceGCSafePointStatus{}
+	c.Assert(readJSON(testDialClient, sspURL+"/a", status), IsNil)
+	c.Assert(status.ServiceID, Equals, "a")
+	c.Assert(status.History, HasLen, 1)
+	c.Assert(status.MaxTTL.Duration, Equals, time.Minute)
+	// The history is persisted once the safepoint changes.
+	_, err = s.svr.UpdateServiceGCSafePoint(context.Background(), &pdpb.UpdateServiceGCSafePointRequest{
+		Header:    &pdpb.RequestHeader{ClusterId: s.svr.ClusterID()},
+		ServiceId: []byte("a"),
+		TTL:       3600,
+		SafePoint: 2,
+	})
+	c.Assert(err, IsNil)
+	history, err := s.svr.GetStorage().LoadServiceGCSafePointHistory("a")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 2)
+	c.Assert(readJSON(testDialClient, sspURL+"/a", status), IsNil)
+	c.Assert(status.History, DeepEquals, history)
+	// The TTL is limited by the max TTL.
+	c.Assert(status.ExpiredAt, LessEqual, time.Now().Unix()+60)
+	c.Assert(status.Stale, IsFalse)
+	c.Assert(readJSON(testDialClient, sspURL+"/b", status), IsNil)
+	c.Assert(status.MaxLag.Duration, Equals, time.Hour)
+	c.Assert(status.Stale, IsTrue)
+	res, err = testDialClient.Get(sspURL + "/d")
+	c.Assert(err, IsNil)
+	c.Assert(res.StatusCode, Equals, http.StatusNotFound)
+	res.Body.Close()
+
+	// Expire "b" and "c" which lag more than an hour, "a" is not limited by the max lag.
+	var expired []string
+	c.Assert(postJSON(testDialClient, sspURL+"/expire-stale", nil, func(data []byte, code int) {
+		c.Assert(code, Equals, http.StatusOK)
+		c.Assert(json.Unmarshal(data, &expired), IsNil)
+	}), IsNil)
+	c.Assert(expired, DeepEquals, []string{"b", "c"})
+	cfg.MaxServiceGCSafePointLag = typeutil.NewDuration(0)
+	cfg.ServiceGCSafePointPolicies = nil
+	c.Assert(s.svr.SetPDServerConfig(*cfg), IsNil)
+
+	// The update with the same safepoint only changes the cached history.
+	_, err = s.svr.UpdateServiceGCSafePoint(context.Background(), &pdpb.UpdateServiceGCSafePointRequest{
+		Header:    &pdpb.RequestHeader{ClusterId: s.svr.ClusterID()},
+		ServiceId: []byte("a"),
+		TTL:       3600,
+		SafePoint: 2,
+	})
+	c.Assert(err, IsNil)
 	res, err = doDelete(testDialClient, sspURL+"/a")
 	c.Assert(err, IsNil)
 	c.Assert(res.StatusCode, Equals, http.StatusOK)
+	res.Body.Close()
+	res, err = testDialClient.Get(sspURL + "/a")
+	c.Assert(err, IsNil)
+	c.Assert(res.StatusCode, Equals, http.StatusNotFound)
+	res.Body.Close()
+	// The deleted history doesn't come back when the service is registered again.
+	_, err = s.svr.UpdateServiceGCSafePoint(context.Background(), &pdpb.UpdateServiceGCSafePointRequest{
+		Header:    &pdpb.RequestHeader{ClusterId: s.svr.ClusterID()},
+		ServiceId: []byte("a"),
+		TTL:       3600,
+		SafePoint: 3,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(readJSON(testDialClient, sspURL+"/a", status), IsNil)
+	c.Assert(status.History, HasLen, 1)
+	c.Assert(status.History[0].SafePoint, Equals, uint64(3))
+	res, err = doDelete(testDialClient, sspURL+"/a")
+	c.Assert(err, IsNil)
+	c.Assert(res.StatusCode, Equals, http.StatusOK)
+	res.Body.Close()
+	history, err = storage.LoadServiceGCSafePointHistory("a")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 0)
 
 	left, err := storage.GetAllServiceGCSafePoints()
 	c.Assert(err, IsNil)
-	c.Assert(left, DeepEquals, list.ServiceGCSafepoints[1:])
+	for _, ssp := range left {
+		c.Assert(ssp.ServiceID, Equals, "gc_worker")
+	}
 }
@@ -451,6 +451,17 @@ func (h *storesHandler) RemoveTombStone(w http.ResponseWriter, r *http.Request)
 	h.rd.JSON(w, http.StatusOK, "Remove tombstone successfully.")
 }
 
+// @Tags store
+// @Summary Forecast when the stores run out of space with the growth trends of the used space.
+// @Param label-key query string false "Group the stores by the value of the label"
+// @Produce json
+// @Success 200 {object} cluster.CapacityForecast
+// @Router /stores/capacity-forecast [get]
+func (h *storesHandler) GetCapacityForecast(w http.ResponseWriter, r *http.Request) {
+	labelKey := r.URL.Query().Get("label-key")
+	h.rd.JSON(w, http.StatusOK, getCluster(r).GetCapacityForecast(labelKey))
+}
+
 // FIXME: details of input json body params
 // @Tags store
 // @Summary Set limit of all stores in the cluster.
@@ -29,6 +29,7 @@ import (
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/cluster"
 	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/core"
 )
@@ -491,3 +492,28 @@ func (s *testStoreSuite) TestStoreLimitTTL(c *C) {
 	c.Assert(s.svr.GetPersistOptions().GetStoreLimit(uint64(2)).AddPeer, Not(Equals), float64(997))
 	c.Assert(s.svr.GetPersistOptions().GetStoreLimit(uint64(2)).RemovePeer, Not(Equals), float64(996))
 }
+
+func (s *testStoreSuite) TestCapacityForecast(c *C) {
+	for _, id := range []uint64{1, 4} {
+		_, err := s.svr.StoreHeartbeat(context.Background(), &pdpb.StoreHeartbeatRequest{
+			Header: &pdpb.RequestHeader{ClusterId: s.svr.ClusterID()},
+			Stats:  &pdpb.StoreStats{StoreId: id, Capacity: 100 * units.GiB, Available: 20 * units.GiB},
+		})
+		c.Assert(err, IsNil)
+	}
+	forecast := new(cluster.CapacityForecast)
+	err := readJSON(testDialClient, s.urlPrefix+"/stores/capacity-forecast?label-key=zone", forecast)
+	c.Assert(err, IsNil)
+	c.Assert(forecast.LabelKey, Equals, "zone")
+	c.Assert(forecast.Stores, HasLen, 2)
+	for _, store := range forecast.Stores {
+		c.Assert(store.Samples, Equals, 1)
+		c.Assert(store.UsedRatio, Equals, 0.8)
+		// The low space ratio has been reached, and the trend is unknown.
+		c.Assert(store.TimeToLowSpace, NotNil)
+		c.Assert(store.TimeToLowSpace.Duration, Equals, time.Duration(0))
+		c.Assert(store.TimeToFull, IsNil)
+	}
+	c.Assert(forecast.Groups, HasLen, 1)
+	c.Assert(forecast.Groups[0].StoreIDs, DeepEquals, []uint64{1, 4})
+}
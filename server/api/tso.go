@@ -18,7 +18,10 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/tso"
 	"github.com/unrolled/render"
 )
 
@@ -75,3 +78,141 @@ func (h *tsoHandler) TransferLocalTSOAllocator(w http.ResponseWriter, r *http.Re
 	}
 	h.rd.JSON(w, http.StatusOK, "The transfer command is submitted.")
 }
+
+// @Tags tso
+// @Summary Get health status of the TSO allocators held by this PD server.
+// @Produce json
+// @Success 200 {array} tso.HealthStatus
+// @Router /tso/status [get]
+func (h *tsoHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	h.rd.JSON(w, http.StatusOK, h.svr.GetTSOAllocatorManager().GetHealthStatus())
+}
+
+// DCLocationInfo describes a dc-location with the names of its members.
+type DCLocationInfo struct {
+	*tso.DCLocationDetail
+	MemberNames         []string `json:"member-names"`
+	AllocatorLeaderName string   `json:"allocator-leader-name,omitempty"`
+}
+
+// @Tags tso
+// @Summary Get all dc-locations of the cluster with their members and Local TSO suffixes.
+// @Produce json
+// @Success 200 {array} DCLocationInfo
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /tso/dc-locations [get]
+func (h *tsoHandler) GetDCLocations(w http.ResponseWriter, r *http.Request) {
+	members, err := getMembers(h.svr)
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	details, err := h.svr.GetTSOAllocatorManager().GetDCLocationDetails()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	memberNames := make(map[uint64]string)
+	for _, m := range members.GetMembers() {
+		memberNames[m.GetMemberId()] = m.GetName()
+	}
+	infos := make([]*DCLocationInfo, 0, len(details))
+	for _, detail := range details {
+		info := &DCLocationInfo{
+			DCLocationDetail:    detail,
+			MemberNames:         make([]string, 0, len(detail.ServerIDs)),
+			AllocatorLeaderName: memberNames[detail.AllocatorLeader],
+		}
+		for _, id := range detail.ServerIDs {
+			info.MemberNames = append(info.MemberNames, memberNames[id])
+		}
+		infos = append(infos, info)
+	}
+	h.rd.JSON(w, http.StatusOK, infos)
+}
+
+// @Tags tso
+// @Summary Migrate the members of a dc-location to another dc-location.
+// @Accept json
+// @Param dc_location path string true "The source dc-location"
+// @Param body body object true "json params, e.g. {\"target\": \"dc-2\", \"members\": [\"pd-1\"]}, all members will be migrated if members is empty"
+// @Produce json
+// @Success 200 {string} string "The dc-location members are migrated."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The member or the dc-location does not exist."
+// @Failure 412 {string} string "The dc-location number meets the upper limit or is changed concurrently."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /tso/dc-location/{dc_location}/migrate [post]
+func (h *tsoHandler) MigrateDCLocation(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Target  string   `json:"target"`
+		Members []string `json:"members"`
+	}
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	if len(input.Target) < 1 {
+		h.rd.JSON(w, http.StatusBadRequest, "target is undefined")
+		return
+	}
+	members, err := getMembers(h.svr)
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	memberIDs := make([]uint64, 0, len(input.Members))
+	for _, name := range input.Members {
+		var memberID uint64
+		for _, m := range members.GetMembers() {
+			if m.GetName() == name {
+				memberID = m.GetMemberId()
+				break
+			}
+		}
+		if memberID == 0 {
+			h.rd.JSON(w, http.StatusNotFound, fmt.Sprintf("not found, pd: %s", name))
+			return
+		}
+		memberIDs = append(memberIDs, memberID)
+	}
+	dcLocation := mux.Vars(r)["dc_location"]
+	if err := h.svr.GetTSOAllocatorManager().MigrateDCLocation(dcLocation, input.Target, memberIDs); err != nil {
+		h.rd.JSON(w, dcLocationErrorStatus(err), err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "The dc-location members are migrated.")
+}
+
+// @Tags tso
+// @Summary Remove a dc-location without any member and reclaim its Local TSO suffix.
+// @Param dc_location path string true "The dc-location"
+// @Produce json
+// @Success 200 {string} string "The dc-location is removed."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The dc-location does not exist."
+// @Failure 412 {string} string "The dc-location still has members or an allocator leader, or is changed concurrently."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /tso/dc-location/{dc_location} [delete]
+func (h *tsoHandler) RemoveDCLocation(w http.ResponseWriter, r *http.Request) {
+	dcLocation := mux.Vars(r)["dc_location"]
+	if err := h.svr.GetTSOAllocatorManager().RemoveDCLocation(dcLocation); err != nil {
+		h.rd.JSON(w, dcLocationErrorStatus(err), err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "The dc-location is removed.")
+}
+
+// dcLocationErrorStatus returns the status code of the error of changing the dc-locations.
+func dcLocationErrorStatus(err error) int {
+	switch {
+	case errs.ErrMigrateDCLocation.Equal(err), errs.ErrRemoveDCLocation.Equal(err):
+		return http.StatusBadRequest
+	case errs.ErrDCLocationNotFound.Equal(err):
+		return http.StatusNotFound
+	case errs.ErrDCLocationNotEmpty.Equal(err), errs.ErrDCLocationHasLeader.Equal(err), errs.ErrSetLocalTSOConfig.Equal(err),
+		errs.ErrEtcdTxnConflict.Equal(err):
+		return http.StatusPreconditionFailed
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -15,12 +15,14 @@ package api
 
 import (
 	"fmt"
+	"net/http"
 	"time"
 
 	. "github.com/pingcap/check"
 	"github.com/tikv/pd/pkg/testutil"
 	"github.com/tikv/pd/server"
 	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/tso"
 )
 
 var _ = Suite(&testTsoSuite{})
@@ -56,3 +58,52 @@ func (s *testTsoSuite) TestTransferAllocator(c *C) {
 	err := postJSON(testDialClient, addr, nil)
 	c.Assert(err, IsNil)
 }
+
+func (s *testTsoSuite) TestStatus(c *C) {
+	var status []*tso.HealthStatus
+	testutil.WaitUntil(c, func(c *C) bool {
+		status = nil
+		err := readJSON(testDialClient, s.urlPrefix+"/tso/status", &status)
+		c.Assert(err, IsNil)
+		return len(status) > 0 && status[len(status)-1].DCLocation == tso.GlobalDCLocation
+	})
+	global := status[len(status)-1]
+	c.Assert(global.ClockLagPolicy, Equals, config.TSOClockLagPolicySlowDown)
+	c.Assert(global.ClockLagging, IsFalse)
+	c.Assert(global.Physical.IsZero(), IsFalse)
+	c.Assert(global.LastSavedTime.After(global.Physical), IsTrue)
+	c.Assert(global.SaveWindowHeadroom.Duration, Greater, time.Duration(0))
+}
+
+func (s *testTsoSuite) TestDCLocations(c *C) {
+	var infos []*DCLocationInfo
+	testutil.WaitUntil(c, func(c *C) bool {
+		s.svr.GetTSOAllocatorManager().ClusterDCLocationChecker()
+		infos = nil
+		err := readJSON(testDialClient, s.urlPrefix+"/tso/dc-locations", &infos)
+		c.Assert(err, IsNil)
+		return len(infos) == 1 && infos[0].Suffix > 0
+	})
+	c.Assert(infos[0].DCLocation, Equals, "dc-1")
+	c.Assert(infos[0].MemberNames, DeepEquals, []string{s.svr.Name()})
+
+	checkDelete := func(dcLocation string, statusCode int) {
+		resp, err := doDelete(testDialClient, s.urlPrefix+"/tso/dc-location/"+dcLocation)
+		c.Assert(err, IsNil)
+		c.Assert(resp.StatusCode, Equals, statusCode)
+		resp.Body.Close()
+	}
+	// The dc-location still has a member.
+	checkDelete("dc-1", http.StatusPreconditionFailed)
+	checkDelete("dc-2", http.StatusNotFound)
+	checkDelete(tso.GlobalDCLocation, http.StatusBadRequest)
+
+	err := postJSON(testDialClient, s.urlPrefix+"/tso/dc-location/dc-1/migrate", []byte(`{}`))
+	c.Assert(err, ErrorMatches, "(?s).*target is undefined.*")
+	err = postJSON(testDialClient, s.urlPrefix+"/tso/dc-location/dc-1/migrate", []byte(`{"target": "dc-2", "members": ["unknown"]}`))
+	c.Assert(err, ErrorMatches, "(?s).*not found.*")
+	err = postJSON(testDialClient, s.urlPrefix+"/tso/dc-location/dc-1/migrate", []byte(`{"target": "dc-1"}`))
+	c.Assert(err, ErrorMatches, "(?s).*are the same.*")
+	err = postJSON(testDialClient, s.urlPrefix+"/tso/dc-location/dc-2/migrate", []byte(`{"target": "dc-1"}`))
+	c.Assert(err, ErrorMatches, "(?s).*dc-location dc-2 not found.*")
+}
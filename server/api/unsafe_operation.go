@@ -0,0 +1,136 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/tikv/pd/pkg/apiutil"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/cluster"
+	"github.com/unrolled/render"
+)
+
+const defaultUnsafeRecoveryTimeout = 10 * time.Minute
+
+type unsafeOperationHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newUnsafeOperationHandler(svr *server.Server, rd *render.Render) *unsafeOperationHandler {
+	return &unsafeOperationHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+type removeFailedStoresInput struct {
+	Stores []uint64 `json:"stores"`
+	// Timeout is the timeout of the recovery in seconds.
+	Timeout int64 `json:"timeout"`
+}
+
+// @Tags unsafe
+// @Summary Remove the permanently failed stores and recover the regions which lose the majority of their peers.
+// @Accept json
+// @Param body body removeFailedStoresInput true "json params"
+// @Produce json
+// @Success 200 {string} string "Request has been accepted."
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 500 {string} string "PD server failed to proceed the request."
+// @Router /admin/unsafe/remove-failed-stores [post]
+func (h *unsafeOperationHandler) RemoveFailedStores(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	var input removeFailedStoresInput
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	timeout := defaultUnsafeRecoveryTimeout
+	if input.Timeout < 0 {
+		h.rd.JSON(w, http.StatusBadRequest, "timeout should be positive")
+		return
+	} else if input.Timeout > 0 {
+		timeout = time.Duration(input.Timeout) * time.Second
+	}
+	if err := rc.RemoveFailedStores(input.Stores, timeout); err != nil {
+		if errs.ErrUnsafeRecoveryInvalidInput.Equal(err) || errs.ErrUnsafeRecoveryIsRunning.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "Request has been accepted.")
+}
+
+// @Tags unsafe
+// @Summary Show the status of the unsafe recovery.
+// @Produce json
+// @Success 200 {object} cluster.UnsafeRecoveryStatus
+// @Router /admin/unsafe/remove-failed-stores/show [get]
+func (h *unsafeOperationHandler) GetFailedStoresRemovalStatus(w http.ResponseWriter, r *http.Request) {
+	h.rd.JSON(w, http.StatusOK, getCluster(r).GetUnsafeRecoveryStatus())
+}
+
+// @Tags unsafe
+// @Summary Check the progress of the unsafe recovery immediately and show the status, the failed stores are removed if all the plans are applied.
+// @Produce json
+// @Success 200 {object} cluster.UnsafeRecoveryStatus
+// @Router /admin/unsafe/remove-failed-stores/check [post]
+func (h *unsafeOperationHandler) CheckFailedStoresRemoval(w http.ResponseWriter, r *http.Request) {
+	h.rd.JSON(w, http.StatusOK, getCluster(r).CheckUnsafeRecoveryProgress())
+}
+
+// @Tags unsafe
+// @Summary Show the unsafe recovery plan of the store.
+// @Param id path integer true "Store Id"
+// @Produce json
+// @Success 200 {object} cluster.StoreRecoveryPlan
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The store has no plan to apply."
+// @Router /admin/unsafe/plan/{id} [get]
+func (h *unsafeOperationHandler) GetStoreRecoveryPlan(w http.ResponseWriter, r *http.Request) {
+	h.getStoreRecoveryPlan(w, r, getCluster(r).GetStoreRecoveryPlan)
+}
+
+// @Tags unsafe
+// @Summary Fetch the unsafe recovery plan of the store and mark it delivered, the store applies the plan and reports the regions with heartbeats. The plans are only delivered by this API, not the store heartbeat responses.
+// @Param id path integer true "Store Id"
+// @Produce json
+// @Success 200 {object} cluster.StoreRecoveryPlan
+// @Failure 400 {string} string "The input is invalid."
+// @Failure 404 {string} string "The store has no plan to apply."
+// @Router /admin/unsafe/plan/{id} [post]
+func (h *unsafeOperationHandler) DeliverStoreRecoveryPlan(w http.ResponseWriter, r *http.Request) {
+	h.getStoreRecoveryPlan(w, r, getCluster(r).DeliverStoreRecoveryPlan)
+}
+
+func (h *unsafeOperationHandler) getStoreRecoveryPlan(w http.ResponseWriter, r *http.Request, get func(uint64) *cluster.StoreRecoveryPlan) {
+	storeID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	plan := get(storeID)
+	if plan == nil {
+		h.rd.JSON(w, http.StatusNotFound, "The store has no plan to apply.")
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, plan)
+}
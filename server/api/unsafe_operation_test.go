@@ -0,0 +1,106 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/cluster"
+)
+
+var _ = Suite(&testUnsafeAPISuite{})
+
+type testUnsafeAPISuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testUnsafeAPISuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1/admin/unsafe", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+	mustPutStore(c, s.svr, 2, metapb.StoreState_Up, nil)
+}
+
+func (s *testUnsafeAPISuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testUnsafeAPISuite) TestRemoveFailedStores(c *C) {
+	status := &cluster.UnsafeRecoveryStatus{}
+	c.Assert(readJSON(testDialClient, s.urlPrefix+"/remove-failed-stores/show", status), IsNil)
+	c.Assert(status.Stage, Equals, cluster.UnsafeRecoveryIdle)
+
+	url := s.urlPrefix + "/remove-failed-stores"
+	data, _ := json.Marshal(map[string]interface{}{"stores": []uint64{10}})
+	c.Assert(postJSON(testDialClient, url, data), NotNil)
+	data, _ = json.Marshal(map[string]interface{}{"stores": []uint64{1}, "timeout": -1})
+	c.Assert(postJSON(testDialClient, url, data), NotNil)
+
+	// The bootstrapped region loses its only peer, so an empty region is created on store 2.
+	data, _ = json.Marshal(map[string]interface{}{"stores": []uint64{1}, "timeout": 3600})
+	c.Assert(postJSON(testDialClient, url, data), IsNil)
+	c.Assert(postJSON(testDialClient, url, data), NotNil)
+
+	c.Assert(readJSON(testDialClient, s.urlPrefix+"/remove-failed-stores/show", status), IsNil)
+	c.Assert(status.Stage, Equals, cluster.UnsafeRecoveryRecovering)
+	c.Assert(status.FailedStores, DeepEquals, []uint64{1})
+	c.Assert(status.Plans, HasLen, 1)
+	c.Assert(status.Plans[0].State, Equals, cluster.RecoveryPlanPending)
+
+	// Showing the plan doesn't change its state.
+	plan := &cluster.StoreRecoveryPlan{}
+	c.Assert(readJSON(testDialClient, s.urlPrefix+"/plan/2", plan), IsNil)
+	c.Assert(plan.StoreID, Equals, uint64(2))
+	c.Assert(plan.State, Equals, cluster.RecoveryPlanPending)
+	c.Assert(readJSON(testDialClient, s.urlPrefix+"/remove-failed-stores/show", status), IsNil)
+	c.Assert(status.Plans[0].State, Equals, cluster.RecoveryPlanPending)
+	// The store fetches the plan to apply.
+	c.Assert(postJSON(testDialClient, s.urlPrefix+"/plan/2", nil, func(data []byte, code int) {
+		c.Assert(json.Unmarshal(data, plan), IsNil)
+	}), IsNil)
+	c.Assert(plan.StoreID, Equals, uint64(2))
+	c.Assert(plan.State, Equals, cluster.RecoveryPlanDelivered)
+	c.Assert(plan.Creates, HasLen, 1)
+	c.Assert(plan.Creates[0].GetRegionEpoch().GetVersion(), Equals, region.GetRegionEpoch().GetVersion()+1)
+
+	resp, err := testDialClient.Get(s.urlPrefix + "/plan/1")
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusNotFound)
+	resp.Body.Close()
+	resp, err = testDialClient.Get(s.urlPrefix + "/plan/abc")
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusBadRequest)
+	resp.Body.Close()
+
+	c.Assert(postJSON(testDialClient, s.urlPrefix+"/plan/1", nil), NotNil)
+
+	c.Assert(readJSON(testDialClient, s.urlPrefix+"/remove-failed-stores/show", status), IsNil)
+	c.Assert(status.Plans[0].State, Equals, cluster.RecoveryPlanDelivered)
+	// The plan is not applied yet.
+	c.Assert(postJSON(testDialClient, s.urlPrefix+"/remove-failed-stores/check", nil, func(data []byte, code int) {
+		c.Assert(json.Unmarshal(data, status), IsNil)
+	}), IsNil)
+	c.Assert(status.Stage, Equals, cluster.UnsafeRecoveryRecovering)
+}
@@ -0,0 +1,282 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/server/core"
+)
+
+const (
+	// maxCapacitySamples limits the samples kept for each store, the samples are taken
+	// at most once every history/maxCapacitySamples.
+	maxCapacitySamples = 720
+	// minCapacityForecastSpan is the minimum time span of the samples to fit the trend.
+	minCapacityForecastSpan = time.Minute
+)
+
+// capacitySample is the space usage of a store at a time.
+type capacitySample struct {
+	time time.Time
+	// used is the space not available, including the space used by other processes.
+	used uint64
+}
+
+// SpaceForecast is the predicted time for the space usage to reach the thresholds. A nil
+// duration means the threshold will not be reached with the current trend or the trend is
+// unknown, and zero means it has been reached.
+type SpaceForecast struct {
+	Capacity  uint64  `json:"capacity"`
+	Used      uint64  `json:"used"`
+	Available uint64  `json:"available"`
+	UsedRatio float64 `json:"used_ratio"`
+	// GrowthPerDay is the fitted growth of the used space in bytes per day, it is negative
+	// if the used space is shrinking.
+	GrowthPerDay    int64              `json:"growth_per_day"`
+	TimeToHighSpace *typeutil.Duration `json:"time_to_high_space,omitempty"`
+	TimeToLowSpace  *typeutil.Duration `json:"time_to_low_space,omitempty"`
+	TimeToFull      *typeutil.Duration `json:"time_to_full,omitempty"`
+}
+
+// StoreCapacityForecast is the capacity forecast of a store.
+type StoreCapacityForecast struct {
+	StoreID uint64 `json:"store_id"`
+	Address string `json:"address"`
+	// Group is the value of the label which groups the stores.
+	Group string `json:"group,omitempty"`
+	// Samples is the number of samples to fit the trend, the forecast is not available
+	// if there are not enough samples.
+	Samples int `json:"samples"`
+	SpaceForecast
+}
+
+// GroupCapacityForecast is the capacity forecast of a group of stores with the same label
+// value, the space and the growth of the stores are summed up.
+type GroupCapacityForecast struct {
+	Group    string   `json:"group"`
+	StoreIDs []uint64 `json:"store_ids"`
+	SpaceForecast
+}
+
+// CapacityForecast is the capacity forecast of the cluster.
+type CapacityForecast struct {
+	Time           time.Time                `json:"time"`
+	History        typeutil.Duration        `json:"history"`
+	HighSpaceRatio float64                  `json:"high_space_ratio"`
+	LowSpaceRatio  float64                  `json:"low_space_ratio"`
+	LabelKey       string                   `json:"label_key,omitempty"`
+	Stores         []*StoreCapacityForecast `json:"stores"`
+	Groups         []*GroupCapacityForecast `json:"groups,omitempty"`
+}
+
+// capacityForecaster records the space usage of the stores reported by the store
+// heartbeats and fits the growth trends to forecast when the stores run out of space.
+// The history is only kept in memory, so it is rebuilt after the PD leader changes.
+type capacityForecaster struct {
+	sync.Mutex
+	samples map[uint64][]capacitySample
+}
+
+func newCapacityForecaster() *capacityForecaster {
+	return &capacityForecaster{
+		samples: make(map[uint64][]capacitySample),
+	}
+}
+
+// observe records the space usage of the store if the last sample is old enough.
+func (f *capacityForecaster) observe(store *core.StoreInfo, now time.Time, history time.Duration) {
+	if store.GetCapacity() == 0 {
+		return
+	}
+	f.Lock()
+	defer f.Unlock()
+	samples := f.samples[store.GetID()]
+	if n := len(samples); n > 0 && now.Sub(samples[n-1].time) < history/maxCapacitySamples {
+		return
+	}
+	samples = append(samples, capacitySample{
+		time: now,
+		used: usedSpace(store.GetCapacity(), store.GetAvailable()),
+	})
+	f.samples[store.GetID()] = trimCapacitySamples(samples, now, history)
+}
+
+func trimCapacitySamples(samples []capacitySample, now time.Time, history time.Duration) []capacitySample {
+	i := 0
+	for i < len(samples) && now.Sub(samples[i].time) > history {
+		i++
+	}
+	if len(samples)-i > maxCapacitySamples {
+		i = len(samples) - maxCapacitySamples
+	}
+	return samples[i:]
+}
+
+// forget removes the history of the stores not in the given set.
+func (f *capacityForecaster) forget(storeIDs map[uint64]struct{}) {
+	f.Lock()
+	defer f.Unlock()
+	for id := range f.samples {
+		if _, ok := storeIDs[id]; !ok {
+			delete(f.samples, id)
+		}
+	}
+}
+
+// growthRate fits the used space of the store with linear least squares and returns the
+// growth in bytes per second, and the number of samples fitted.
+func (f *capacityForecaster) growthRate(storeID uint64, now time.Time, history time.Duration) (float64, int, bool) {
+	f.Lock()
+	defer f.Unlock()
+	samples := trimCapacitySamples(f.samples[storeID], now, history)
+	f.samples[storeID] = samples
+	if len(samples) < 2 || samples[len(samples)-1].time.Sub(samples[0].time) < minCapacityForecastSpan {
+		return 0, len(samples), false
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range samples {
+		x := s.time.Sub(samples[0].time).Seconds()
+		y := float64(s.used)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(len(samples))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, len(samples), false
+	}
+	return (n*sumXY - sumX*sumY) / denominator, len(samples), true
+}
+
+func usedSpace(capacity, available uint64) uint64 {
+	if available > capacity {
+		return 0
+	}
+	return capacity - available
+}
+
+// newSpaceForecast predicts the time for the used space to reach the thresholds with the
+// growth rate in bytes per second.
+func newSpaceForecast(capacity, available uint64, rate float64, fitted bool, highSpaceRatio, lowSpaceRatio float64) SpaceForecast {
+	used := usedSpace(capacity, available)
+	forecast := SpaceForecast{
+		Capacity:  capacity,
+		Used:      used,
+		Available: capacity - used,
+	}
+	if capacity > 0 {
+		forecast.UsedRatio = float64(used) / float64(capacity)
+	}
+	if capacity == 0 {
+		return forecast
+	}
+	if fitted {
+		forecast.GrowthPerDay = int64(rate * (24 * time.Hour).Seconds())
+	}
+	timeTo := func(ratio float64) *typeutil.Duration {
+		remaining := ratio*float64(capacity) - float64(used)
+		if remaining <= 0 {
+			return &typeutil.Duration{}
+		}
+		if !fitted || rate <= 0 {
+			return nil
+		}
+		seconds := remaining / rate
+		if seconds > math.MaxInt64/float64(time.Second) {
+			return nil
+		}
+		d := typeutil.NewDuration(time.Duration(seconds * float64(time.Second)).Round(time.Second))
+		return &d
+	}
+	forecast.TimeToHighSpace = timeTo(highSpaceRatio)
+	forecast.TimeToLowSpace = timeTo(lowSpaceRatio)
+	forecast.TimeToFull = timeTo(1)
+	return forecast
+}
+
+// GetCapacityForecast forecasts when the stores run out of space with the growth trends over
+// the configured history. The stores are also grouped by the value of the label if the label
+// key is not empty.
+func (c *RaftCluster) GetCapacityForecast(labelKey string) *CapacityForecast {
+	now := time.Now()
+	history := c.opt.GetPDServerConfig().CapacityForecastHistory.Duration
+	highSpaceRatio, lowSpaceRatio := c.opt.GetHighSpaceRatio(), c.opt.GetLowSpaceRatio()
+	result := &CapacityForecast{
+		Time:           now,
+		History:        typeutil.NewDuration(history),
+		HighSpaceRatio: highSpaceRatio,
+		LowSpaceRatio:  lowSpaceRatio,
+		LabelKey:       labelKey,
+		Stores:         []*StoreCapacityForecast{},
+	}
+
+	type group struct {
+		capacity, available uint64
+		rate                float64
+		fitted              bool
+		storeIDs            []uint64
+	}
+	groups := make(map[string]*group)
+	storeIDs := make(map[uint64]struct{})
+	for _, store := range c.GetStores() {
+		storeIDs[store.GetID()] = struct{}{}
+		if store.GetState() == metapb.StoreState_Tombstone || store.GetCapacity() == 0 {
+			continue
+		}
+		rate, samples, fitted := c.capacityForecaster.growthRate(store.GetID(), now, history)
+		forecast := &StoreCapacityForecast{
+			StoreID:       store.GetID(),
+			Address:       store.GetAddress(),
+			Samples:       samples,
+			SpaceForecast: newSpaceForecast(store.GetCapacity(), store.GetAvailable(), rate, fitted, highSpaceRatio, lowSpaceRatio),
+		}
+		result.Stores = append(result.Stores, forecast)
+		if labelKey == "" {
+			continue
+		}
+		forecast.Group = store.GetLabelValue(labelKey)
+		g, ok := groups[forecast.Group]
+		if !ok {
+			g = &group{fitted: true}
+			groups[forecast.Group] = g
+		}
+		g.capacity += forecast.Capacity
+		g.available += forecast.Available
+		g.rate += rate
+		// The trend of the group is unknown if any store in it is unknown.
+		g.fitted = g.fitted && fitted
+		g.storeIDs = append(g.storeIDs, store.GetID())
+	}
+	c.capacityForecaster.forget(storeIDs)
+
+	sort.Slice(result.Stores, func(i, j int) bool { return result.Stores[i].StoreID < result.Stores[j].StoreID })
+	for value, g := range groups {
+		sort.Slice(g.storeIDs, func(i, j int) bool { return g.storeIDs[i] < g.storeIDs[j] })
+		result.Groups = append(result.Groups, &GroupCapacityForecast{
+			Group:         value,
+			StoreIDs:      g.storeIDs,
+			SpaceForecast: newSpaceForecast(g.capacity, g.available, g.rate, g.fitted, highSpaceRatio, lowSpaceRatio),
+		})
+	}
+	sort.Slice(result.Groups, func(i, j int) bool { return result.Groups[i].Group < result.Groups[j].Group })
+	return result
+}
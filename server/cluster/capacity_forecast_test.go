@@ -0,0 +1,175 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/pkg/mock/mockid"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
+)
+
+var _ = Suite(&testCapacityForecastSuite{})
+
+type testCapacityForecastSuite struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (s *testCapacityForecastSuite) SetUpSuite(c *C) {
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+}
+
+func (s *testCapacityForecastSuite) TearDownSuite(c *C) {
+	s.cancel()
+}
+
+const gb = uint64(1 << 30)
+
+func newCapacityTestStore(id uint64, zone string, capacity, used uint64) *core.StoreInfo {
+	store := core.NewStoreInfo(&metapb.Store{
+		Id:      id,
+		Address: "mock://tikv-" + zone,
+		State:   metapb.StoreState_Up,
+		Labels:  []*metapb.StoreLabel{{Key: "zone", Value: zone}},
+	})
+	return store.Clone(core.SetStoreStats(&pdpb.StoreStats{
+		StoreId:   id,
+		Capacity:  capacity,
+		Available: capacity - used,
+	}))
+}
+
+func checkForecastDuration(c *C, d *typeutil.Duration, expect time.Duration) {
+	c.Assert(d, NotNil)
+	diff := d.Duration - expect
+	if diff < 0 {
+		diff = -diff
+	}
+	c.Assert(diff < time.Minute, IsTrue, Commentf("expect %v, got %v", expect, d.Duration))
+}
+
+func (s *testCapacityForecastSuite) TestForecaster(c *C) {
+	f := newCapacityForecaster()
+	history := 10 * time.Hour
+	now := time.Now()
+	store := newCapacityTestStore(1, "z1", 100*gb, 10*gb)
+	f.observe(store, now, history)
+	// Too close to the last sample.
+	f.observe(store, now.Add(history/maxCapacitySamples/2), history)
+	_, samples, fitted := f.growthRate(1, now, history)
+	c.Assert(samples, Equals, 1)
+	c.Assert(fitted, IsFalse)
+
+	for i := 1; i <= 10; i++ {
+		store = newCapacityTestStore(1, "z1", 100*gb, 10*gb+uint64(i)*gb)
+		f.observe(store, now.Add(time.Duration(i)*time.Hour), history)
+	}
+	rate, samples, fitted := f.growthRate(1, now.Add(10*time.Hour), history)
+	c.Assert(samples, Equals, 11)
+	c.Assert(fitted, IsTrue)
+	c.Assert(rate*3600, Equals, float64(gb))
+
+	// The old samples are dropped.
+	_, samples, _ = f.growthRate(1, now.Add(15*time.Hour), history)
+	c.Assert(samples, Equals, 6)
+
+	f.forget(map[uint64]struct{}{2: {}})
+	_, samples, _ = f.growthRate(1, now.Add(15*time.Hour), history)
+	c.Assert(samples, Equals, 0)
+}
+
+func (s *testCapacityForecastSuite) TestSpaceForecast(c *C) {
+	day := 24 * time.Hour
+	rate := float64(gb) / day.Seconds()
+	forecast := newSpaceForecast(100*gb, 50*gb, rate, true, 0.7, 0.8)
+	c.Assert(forecast.Used, Equals, 50*gb)
+	c.Assert(forecast.UsedRatio, Equals, 0.5)
+	c.Assert(forecast.GrowthPerDay, Equals, int64(gb))
+	checkForecastDuration(c, forecast.TimeToHighSpace, 20*day)
+	checkForecastDuration(c, forecast.TimeToLowSpace, 30*day)
+	checkForecastDuration(c, forecast.TimeToFull, 50*day)
+
+	// The used space is shrinking.
+	forecast = newSpaceForecast(100*gb, 50*gb, -rate, true, 0.7, 0.8)
+	c.Assert(forecast.TimeToHighSpace, IsNil)
+	c.Assert(forecast.TimeToLowSpace, IsNil)
+	c.Assert(forecast.TimeToFull, IsNil)
+
+	// The thresholds have been reached even though the trend is unknown.
+	forecast = newSpaceForecast(100*gb, 15*gb, 0, false, 0.7, 0.8)
+	c.Assert(forecast.GrowthPerDay, Equals, int64(0))
+	checkForecastDuration(c, forecast.TimeToHighSpace, 0)
+	checkForecastDuration(c, forecast.TimeToLowSpace, 0)
+	c.Assert(forecast.TimeToFull, IsNil)
+}
+
+func (s *testCapacityForecastSuite) TestCapacityForecast(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()), core.NewBasicCluster())
+	day := 24 * time.Hour
+	now := time.Now()
+	history := opt.GetPDServerConfig().CapacityForecastHistory.Duration
+	// Store 1 grows 1GB per day and store 2 doesn't grow.
+	for i := 4; i >= 0; i-- {
+		at := now.Add(-time.Duration(i) * day)
+		cluster.capacityForecaster.observe(newCapacityTestStore(1, "z1", 100*gb, 50*gb-uint64(i)*gb), at, history)
+		cluster.capacityForecaster.observe(newCapacityTestStore(2, "z1", 100*gb, 10*gb), at, history)
+	}
+	// Store 3 has only one sample and store 4 is in low space.
+	cluster.capacityForecaster.observe(newCapacityTestStore(3, "z2", 100*gb, 10*gb), now, history)
+	stores := []*core.StoreInfo{
+		newCapacityTestStore(1, "z1", 100*gb, 50*gb),
+		newCapacityTestStore(2, "z1", 100*gb, 10*gb),
+		newCapacityTestStore(3, "z2", 100*gb, 10*gb),
+		newCapacityTestStore(4, "z2", 100*gb, 85*gb),
+	}
+	for _, store := range stores {
+		c.Assert(cluster.putStoreLocked(store), IsNil)
+	}
+
+	forecast := cluster.GetCapacityForecast("")
+	c.Assert(forecast.Groups, HasLen, 0)
+	c.Assert(forecast.Stores, HasLen, 4)
+	s1, s2, s3, s4 := forecast.Stores[0], forecast.Stores[1], forecast.Stores[2], forecast.Stores[3]
+	c.Assert(s1.Samples, Equals, 5)
+	checkForecastDuration(c, s1.TimeToLowSpace, 30*day)
+	checkForecastDuration(c, s1.TimeToFull, 50*day)
+	c.Assert(s2.Samples, Equals, 5)
+	c.Assert(s2.GrowthPerDay, Equals, int64(0))
+	c.Assert(s2.TimeToFull, IsNil)
+	c.Assert(s3.Samples, Equals, 1)
+	c.Assert(s3.TimeToFull, IsNil)
+	c.Assert(s4.Samples, Equals, 0)
+	checkForecastDuration(c, s4.TimeToLowSpace, 0)
+
+	forecast = cluster.GetCapacityForecast("zone")
+	c.Assert(forecast.Stores[0].Group, Equals, "z1")
+	c.Assert(forecast.Groups, HasLen, 2)
+	z1, z2 := forecast.Groups[0], forecast.Groups[1]
+	c.Assert(z1.Group, Equals, "z1")
+	c.Assert(z1.StoreIDs, DeepEquals, []uint64{1, 2})
+	c.Assert(z1.Used, Equals, 60*gb)
+	// 100GB to reach 80% of 200GB with 1GB per day.
+	checkForecastDuration(c, z1.TimeToLowSpace, 100*day)
+	c.Assert(z2.StoreIDs, DeepEquals, []uint64{3, 4})
+	c.Assert(z2.TimeToFull, IsNil)
+}
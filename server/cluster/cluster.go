@@ -105,6 +105,10 @@ type RaftCluster struct {
 	regionStats     *statistics.RegionStatistics
 	hotStat         *statistics.HotStat
 
+	// regionStatsWorkers is nil if the cluster is not started, then the
+	// statistics are updated in place.
+	regionStatsWorkers *regionStatsWorkers
+
 	coordinator      *coordinator
 	suspectRegions   *cache.TTLUint64 // suspectRegions are regions that may need fix
 	suspectKeyRanges *cache.TTLString // suspect key-range regions that may need fix
@@ -122,6 +126,11 @@ type RaftCluster struct {
 
 	// It's used to manage components.
 	componentManager *component.Manager
+
+	regionConsistencyChecker *regionConsistencyChecker
+	unsafeRecoveryController *unsafeRecoveryController
+	capacityForecaster       *capacityForecaster
+	regionStorageVerifier    *regionStorageVerifier
 }
 
 // Status saves some state information.
@@ -210,6 +219,11 @@ func (c *RaftCluster) InitCluster(id id.Allocator, opt *config.PersistOptions, s
 	c.suspectRegions = cache.NewIDTTL(c.ctx, time.Minute, 3*time.Minute)
 	c.suspectKeyRanges = cache.NewStringTTL(c.ctx, time.Minute, 3*time.Minute)
 	c.traceRegionFlow = opt.GetPDServerConfig().TraceRegionFlow
+	c.regionConsistencyChecker = newRegionConsistencyChecker(c)
+	c.unsafeRecoveryController = newUnsafeRecoveryController(c)
+	c.capacityForecaster = newCapacityForecaster()
+	c.regionStorageVerifier = &regionStorageVerifier{}
+	c.regionStatsWorkers = nil
 }
 
 // Start starts a cluster.
@@ -254,7 +268,11 @@ func (c *RaftCluster) Start(s Server) error {
 	c.regionStats = statistics.NewRegionStatistics(c.opt, c.ruleManager)
 	c.limiter = NewStoreLimiter(s.GetPersistOptions())
 
-	c.wg.Add(5)
+	c.regionStatsWorkers = newRegionStatsWorkers(regionStatsWorkerCount, regionStatsQueueSize, c.quit)
+	c.wg.Add(7 + regionStatsWorkerCount)
+	for _, queue := range c.regionStatsWorkers.queues {
+		go c.runRegionStatsWorker(queue)
+	}
 	go c.runCoordinator()
 	failpoint.Inject("highFrequencyClusterJobs", func() {
 		backgroundJobInterval = 100 * time.Microsecond
@@ -263,6 +281,8 @@ func (c *RaftCluster) Start(s Server) error {
 	go c.runStatsBackgroundJobs()
 	go c.syncRegions()
 	go c.runReplicationMode()
+	go c.runRegionConsistencyChecker()
+	go c.runRegionStorageVerifier()
 	c.running = true
 
 	return nil
@@ -322,6 +342,10 @@ func (c *RaftCluster) runBackgroundJobs(interval time.Duration) {
 			c.checkStores()
 			c.collectMetrics()
 			c.coordinator.opController.PruneHistory()
+			if _, err := c.componentManager.ExpireStaleMembers(time.Now()); err != nil {
+				log.Warn("failed to expire stale component members", errs.ZapError(err))
+			}
+			c.unsafeRecoveryController.checkProgress(time.Now())
 		}
 	}
 }
@@ -549,6 +573,7 @@ func (c *RaftCluster) HandleStoreHeartbeat(stats *pdpb.StoreStats) error {
 		statistics.UpdateStoreHeartbeatMetrics(store)
 	}
 	c.core.PutStore(newStore)
+	c.capacityForecaster.observe(newStore, time.Now(), c.opt.GetPDServerConfig().CapacityForecastHistory.Duration)
 	c.hotStat.Observe(newStore.GetID(), newStore.GetStoreStats())
 	c.hotStat.FilterUnhealthyStore(c)
 	reportInterval := stats.GetInterval()
@@ -597,30 +622,25 @@ var regionGuide = core.GenerateRegionGuideFunc(true)
 
 // processRegionHeartbeat updates the region information.
 func (c *RaftCluster) processRegionHeartbeat(region *core.RegionInfo) error {
+	start := time.Now()
 	c.RLock()
 	storage := c.storage
 	coreCluster := c.core
-	hotStat := c.hotStat
+	workers := c.regionStatsWorkers
 	c.RUnlock()
 
 	origin, err := coreCluster.PreCheckPutRegion(region)
 	if err != nil {
 		return err
 	}
-	hotStat.CheckWriteAsync(statistics.NewCheckExpiredItemTask(region))
-	hotStat.CheckReadAsync(statistics.NewCheckExpiredItemTask(region))
-	reportInterval := region.GetInterval()
-	interval := reportInterval.GetEndTimestamp() - reportInterval.GetStartTimestamp()
-	for _, peer := range region.GetPeers() {
-		peerInfo := core.NewPeerInfo(peer, region.GetWriteLoads(), interval)
-		hotStat.CheckWriteAsync(statistics.NewCheckPeerTask(peerInfo, region))
-	}
 
 	// Save to storage if meta is updated.
 	// Save to cache if meta or leader is updated, or contains any down/pending peer.
 	// Mark isNew if the region in cache does not have leader.
 	isNew, saveKV, saveCache, needSync := regionGuide(region, origin)
+	regionHeartbeatStageDuration.WithLabelValues("pre-check").Observe(time.Since(start).Seconds())
 	if !saveKV && !saveCache && !isNew {
+		c.dispatchRegionStats(workers, &regionStatsTask{region: region})
 		return nil
 	}
 
@@ -628,6 +648,7 @@ func (c *RaftCluster) processRegionHeartbeat(region *core.RegionInfo) error {
 		time.Sleep(500 * time.Millisecond)
 	})
 
+	start = time.Now()
 	var overlaps []*core.RegionInfo
 	c.Lock()
 	if saveCache {
@@ -640,12 +661,6 @@ func (c *RaftCluster) processRegionHeartbeat(region *core.RegionInfo) error {
 			return err
 		}
 		overlaps = c.core.PutRegion(region)
-		for _, item := range overlaps {
-			if c.regionStats != nil {
-				c.regionStats.ClearDefunctRegion(item.GetID())
-			}
-			c.labelLevelStats.ClearDefunctRegion(item.GetID())
-		}
 
 		// Update related stores.
 		storeMap := make(map[uint64]struct{})
@@ -667,15 +682,21 @@ func (c *RaftCluster) processRegionHeartbeat(region *core.RegionInfo) error {
 		c.prepareChecker.collect(region)
 	}
 
-	if c.regionStats != nil {
-		c.regionStats.Observe(region, c.getRegionStoresLocked(region))
-	}
-
+	stores := c.getRegionStoresLocked(region)
 	changedRegions := c.changedRegions
 
 	c.Unlock()
+	regionHeartbeatStageDuration.WithLabelValues("update-cache").Observe(time.Since(start).Seconds())
+
+	// The statistics are updated out of the cluster lock, the tasks may be reordered
+	// with the ones of the concurrent heartbeats, see updateRegionStats.
+	for _, item := range overlaps {
+		c.dispatchRegionStats(workers, &regionStatsTask{region: item, defunct: true})
+	}
+	c.dispatchRegionStats(workers, &regionStatsTask{region: region, updated: true, stores: stores})
 
 	if storage != nil {
+		start = time.Now()
 		// If there are concurrent heartbeats from the same region, the last write will win even if
 		// writes to storage in the critical area. So don't use mutex to protect it.
 		// Not successfully saved to storage is not fatal, it only leads to longer warm-up
@@ -697,6 +718,7 @@ func (c *RaftCluster) processRegionHeartbeat(region *core.RegionInfo) error {
 			}
 			regionEventCounter.WithLabelValues("update_kv").Inc()
 		}
+		regionHeartbeatStageDuration.WithLabelValues("save-kv").Observe(time.Since(start).Seconds())
 	}
 
 	if saveKV || needSync {
@@ -1234,7 +1256,7 @@ func (c *RaftCluster) RemoveTombStoneRecords() error {
 
 func (c *RaftCluster) deleteStoreLocked(store *core.StoreInfo) error {
 	if c.storage != nil {
-		if err := c.storage.DeleteStore(store.GetMeta()); err != nil {
+		if err := c.storage.DeleteStoreWithWeight(store.GetMeta()); err != nil {
 			return err
 		}
 	}
@@ -1274,7 +1296,11 @@ func (c *RaftCluster) collectClusterMetrics() {
 	c.regionStats.Collect()
 	c.labelLevelStats.Collect()
 	hotStat := c.hotStat
+	workers := c.regionStatsWorkers
 	c.RUnlock()
+	if workers != nil {
+		workers.collectMetrics()
+	}
 	// collect hot cache metrics
 	hotStat.CollectMetrics()
 }
@@ -1290,6 +1316,7 @@ func (c *RaftCluster) resetClusterMetrics() {
 	c.labelLevelStats.Reset()
 	hotStat := c.hotStat
 	c.RUnlock()
+	regionStatsQueueGauge.Reset()
 	// reset hot cache metrics
 	hotStat.ResetMetrics()
 }
@@ -1334,8 +1361,8 @@ func (c *RaftCluster) GetOfflineRegionStatsByType(typ statistics.RegionStatistic
 }
 
 func (c *RaftCluster) updateRegionsLabelLevelStats(regions []*core.RegionInfo) {
-	c.Lock()
-	defer c.Unlock()
+	c.RLock()
+	defer c.RUnlock()
 	for _, region := range regions {
 		c.labelLevelStats.Observe(region, c.getRegionStoresLocked(region), c.opt.GetLocationLabels())
 	}
@@ -28,6 +28,7 @@ import (
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/pkg/mock/mockid"
+	"github.com/tikv/pd/pkg/testutil"
 	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/core"
 	"github.com/tikv/pd/server/id"
@@ -704,6 +705,125 @@ func heartbeatRegions(c *C, cluster *RaftCluster, regions []*core.RegionInfo) {
 	}
 }
 
+func (s *testClusterInfoSuite) TestRegionStatsWorkers(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()), core.NewBasicCluster())
+	cluster.ruleManager = placement.NewRuleManager(core.NewStorage(kv.NewMemoryKV()), cluster)
+	if opt.IsPlacementRulesEnabled() {
+		c.Assert(cluster.ruleManager.Initialize(opt.GetMaxReplicas(), opt.GetLocationLabels()), IsNil)
+	}
+	cluster.regionStats = statistics.NewRegionStatistics(cluster.GetOpts(), cluster.ruleManager)
+	for _, store := range newTestStores(3, "5.0.0") {
+		c.Assert(cluster.PutStore(store.GetMeta()), IsNil)
+	}
+
+	quit := make(chan struct{})
+	cluster.quit = quit
+	cluster.regionStatsWorkers = newRegionStatsWorkers(2, 16, quit)
+	cluster.wg.Add(2)
+	for _, queue := range cluster.regionStatsWorkers.queues {
+		go cluster.runRegionStatsWorker(queue)
+	}
+	defer func() {
+		close(quit)
+		cluster.wg.Wait()
+	}()
+
+	regions := newTestRegions(4, 3)
+	for _, region := range regions {
+		downPeer := region.GetPeers()[1]
+		region = region.Clone(core.WithDownPeers([]*pdpb.PeerStats{{Peer: downPeer, DownSeconds: 3600}}))
+		c.Assert(cluster.processRegionHeartbeat(region), IsNil)
+	}
+	// The statistics are updated by the workers asynchronously.
+	testutil.WaitUntil(c, func(c *C) bool {
+		return len(cluster.GetRegionStatsByType(statistics.DownPeer)) == len(regions)
+	})
+
+	// The later heartbeats of a region are processed in order.
+	for _, region := range regions {
+		c.Assert(cluster.processRegionHeartbeat(region.Clone(core.SetApproximateSize(10))), IsNil)
+	}
+	testutil.WaitUntil(c, func(c *C) bool {
+		return len(cluster.GetRegionStatsByType(statistics.DownPeer)) == 0
+	})
+}
+
+func (s *testClusterInfoSuite) TestRegionStatsReordered(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := newTestRaftCluster(s.ctx, mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()), core.NewBasicCluster())
+	cluster.ruleManager = placement.NewRuleManager(core.NewStorage(kv.NewMemoryKV()), cluster)
+	if opt.IsPlacementRulesEnabled() {
+		c.Assert(cluster.ruleManager.Initialize(opt.GetMaxReplicas(), opt.GetLocationLabels()), IsNil)
+	}
+	cluster.regionStats = statistics.NewRegionStatistics(cluster.GetOpts(), cluster.ruleManager)
+	for _, store := range newTestStores(3, "5.0.0") {
+		c.Assert(cluster.PutStore(store.GetMeta()), IsNil)
+	}
+	withDownPeer := func(region *core.RegionInfo) *core.RegionInfo {
+		downPeer := region.GetPeers()[1]
+		return region.Clone(core.WithDownPeers([]*pdpb.PeerStats{{Peer: downPeer, DownSeconds: 3600}}))
+	}
+
+	regions := newTestRegions(2, 3)
+	origin := withDownPeer(regions[0])
+	c.Assert(cluster.processRegionHeartbeat(origin), IsNil)
+	c.Assert(cluster.GetRegionStatsByType(statistics.DownPeer), HasLen, 1)
+	// The merged region replaces the origin one.
+	merged := withDownPeer(regions[1].Clone(
+		core.WithStartKey(regions[0].GetStartKey()),
+		core.WithIncVersion(),
+	))
+	c.Assert(cluster.processRegionHeartbeat(merged), IsNil)
+	stats := cluster.GetRegionStatsByType(statistics.DownPeer)
+	c.Assert(stats, HasLen, 1)
+	c.Assert(stats[0].GetID(), Equals, merged.GetID())
+
+	// The stale task of the replaced region doesn't restore its statistics.
+	cluster.updateRegionStats(&regionStatsTask{region: origin, updated: true, stores: cluster.GetRegionStores(origin)})
+	stats = cluster.GetRegionStatsByType(statistics.DownPeer)
+	c.Assert(stats, HasLen, 1)
+	c.Assert(stats[0].GetID(), Equals, merged.GetID())
+	// The late defunct task doesn't clear the statistics of the region in the cache.
+	cluster.updateRegionStats(&regionStatsTask{region: merged, defunct: true})
+	c.Assert(cluster.GetRegionStatsByType(statistics.DownPeer), HasLen, 1)
+
+	// The down peer is recovered in the newer version of the merged region, and
+	// the task of the older version arrives after it.
+	recovered := merged.Clone(core.WithDownPeers(nil), core.WithIncConfVer())
+	c.Assert(cluster.processRegionHeartbeat(recovered), IsNil)
+	c.Assert(cluster.GetRegionStatsByType(statistics.DownPeer), HasLen, 0)
+	cluster.updateRegionStats(&regionStatsTask{region: merged, updated: true, stores: cluster.GetRegionStores(merged)})
+	c.Assert(cluster.GetRegionStatsByType(statistics.DownPeer), HasLen, 0)
+}
+
+func (s *testClusterInfoSuite) TestRegionStatsBackpressure(c *C) {
+	quit := make(chan struct{})
+	workers := newRegionStatsWorkers(1, 1, quit)
+	region := core.NewTestRegionInfo([]byte("a"), []byte("b"))
+	workers.dispatch(&regionStatsTask{region: region})
+
+	// The queue is full, the dispatching is blocked until the workers quit.
+	done := make(chan struct{})
+	go func() {
+		workers.dispatch(&regionStatsTask{region: region})
+		close(done)
+	}()
+	select {
+	case <-done:
+		c.Fatal("dispatch should be blocked")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(quit)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		c.Fatal("dispatch should be released")
+	}
+}
+
 func (s *testClusterInfoSuite) TestHeartbeatSplit(c *C) {
 	_, opt, err := newTestScheduleConfig()
 	c.Assert(err, IsNil)
@@ -15,6 +15,7 @@ package cluster
 
 import (
 	"bytes"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/pingcap/errors"
@@ -35,10 +36,12 @@ func (c *RaftCluster) HandleRegionHeartbeat(region *core.RegionInfo) error {
 		return err
 	}
 
+	start := time.Now()
 	c.RLock()
 	co := c.coordinator
 	c.RUnlock()
 	co.opController.Dispatch(region, schedule.DispatchFromHeartBeat)
+	regionHeartbeatStageDuration.WithLabelValues("dispatch").Observe(time.Since(start).Seconds())
 	return nil
 }
 
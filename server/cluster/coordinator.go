@@ -644,16 +644,11 @@ func (c *coordinator) removeScheduler(name string) error {
 		return err
 	}
 
-	if err := opt.Persist(c.cluster.storage); err != nil {
+	if err := opt.PersistWithoutScheduler(c.cluster.storage, name); err != nil {
 		log.Error("the option can not persist scheduler config", errs.ZapError(err))
 		return err
 	}
 
-	if err := c.cluster.storage.RemoveScheduleConfig(name); err != nil {
-		log.Error("can not remove the scheduler config", errs.ZapError(err))
-		return err
-	}
-
 	s.Stop()
 	schedulerStatusGauge.WithLabelValues(name, "allow").Set(0)
 	delete(c.schedulers, name)
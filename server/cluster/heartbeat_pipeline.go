@@ -0,0 +1,168 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/statistics"
+)
+
+const (
+	// regionStatsWorkerCount is the number of the workers to update the statistics
+	// of the region heartbeats.
+	regionStatsWorkerCount = 4
+	// regionStatsQueueSize is the queue size of each worker, the region heartbeats
+	// are blocked when the queue is full.
+	regionStatsQueueSize = 1024
+)
+
+// regionStatsTask updates the statistics for a region heartbeat.
+type regionStatsTask struct {
+	region *core.RegionInfo
+	// updated is set if the region may be changed, then the region statistics
+	// are updated with the stores of the region. Otherwise only the hot statistics
+	// are updated.
+	updated bool
+	stores  []*core.StoreInfo
+	// defunct is set if the region has been replaced by the overlapping regions.
+	defunct     bool
+	enqueueTime time.Time
+}
+
+// regionStatsWorkers updates the statistics of the region heartbeats out of the
+// cluster lock. The tasks are dispatched to the workers by the region ID, so the
+// tasks of a region are processed by the same worker. The statistics are eventually
+// consistent with the region cache.
+type regionStatsWorkers struct {
+	queues []chan *regionStatsTask
+	quit   <-chan struct{}
+}
+
+func newRegionStatsWorkers(count, queueSize int, quit <-chan struct{}) *regionStatsWorkers {
+	w := &regionStatsWorkers{
+		queues: make([]chan *regionStatsTask, count),
+		quit:   quit,
+	}
+	for i := range w.queues {
+		w.queues[i] = make(chan *regionStatsTask, queueSize)
+	}
+	return w
+}
+
+// dispatch puts the task into the queue of its region. If the queue is full, it
+// blocks until there is room, which slows down the region heartbeat stream as the
+// backpressure.
+func (w *regionStatsWorkers) dispatch(task *regionStatsTask) {
+	queue := w.queues[task.region.GetID()%uint64(len(w.queues))]
+	task.enqueueTime = time.Now()
+	select {
+	case queue <- task:
+		return
+	default:
+	}
+	regionHeartbeatBackpressureCounter.Inc()
+	select {
+	case queue <- task:
+	case <-w.quit:
+	}
+	regionHeartbeatStageDuration.WithLabelValues("backpressure").Observe(time.Since(task.enqueueTime).Seconds())
+}
+
+func (w *regionStatsWorkers) collectMetrics() {
+	for i, queue := range w.queues {
+		regionStatsQueueGauge.WithLabelValues(strconv.Itoa(i)).Set(float64(len(queue)))
+	}
+}
+
+func (c *RaftCluster) runRegionStatsWorker(queue <-chan *regionStatsTask) {
+	defer logutil.LogPanic()
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-c.quit:
+			return
+		case task := <-queue:
+			regionHeartbeatStageDuration.WithLabelValues("stats-queue").Observe(time.Since(task.enqueueTime).Seconds())
+			c.updateRegionStats(task)
+		}
+	}
+}
+
+// dispatchRegionStats updates the statistics by the workers, or in place if the
+// workers are not running.
+func (c *RaftCluster) dispatchRegionStats(workers *regionStatsWorkers, task *regionStatsTask) {
+	if workers == nil {
+		c.updateRegionStats(task)
+		return
+	}
+	workers.dispatch(task)
+}
+
+func (c *RaftCluster) updateRegionStats(task *regionStatsTask) {
+	start := time.Now()
+	c.RLock()
+	coreCluster := c.core
+	hotStat := c.hotStat
+	regionStats := c.regionStats
+	labelStats := c.labelLevelStats
+	c.RUnlock()
+
+	// The tasks are dispatched after the cluster lock is released, so the tasks of a region
+	// from concurrent heartbeats may arrive out of order. The region cache is updated before
+	// dispatching, so the tasks are checked against it: a stale task can't restore the
+	// statistics of a replaced region, and a late defunct task can't clear the statistics
+	// of a region which has come back, and the statistics are observed with the cached
+	// region, so an older heartbeat can't overwrite the statistics of a newer one.
+	region := task.region
+	cachedRegion := coreCluster.GetRegion(region.GetID())
+	cached := cachedRegion != nil
+	if task.defunct {
+		// The region has come back after the task is dispatched.
+		if cached {
+			return
+		}
+		if regionStats != nil {
+			regionStats.ClearDefunctRegion(region.GetID())
+		}
+		labelStats.ClearDefunctRegion(region.GetID())
+		return
+	}
+	// The region has been replaced, its statistics are cleared by the defunct task.
+	if !cached {
+		return
+	}
+
+	hotStat.CheckWriteAsync(statistics.NewCheckExpiredItemTask(region))
+	hotStat.CheckReadAsync(statistics.NewCheckExpiredItemTask(region))
+	reportInterval := region.GetInterval()
+	interval := reportInterval.GetEndTimestamp() - reportInterval.GetStartTimestamp()
+	for _, peer := range region.GetPeers() {
+		peerInfo := core.NewPeerInfo(peer, region.GetWriteLoads(), interval)
+		hotStat.CheckWriteAsync(statistics.NewCheckPeerTask(peerInfo, region))
+	}
+
+	if task.updated && regionStats != nil {
+		stores := task.stores
+		if cachedRegion != region {
+			stores = coreCluster.GetRegionStores(cachedRegion)
+		}
+		regionStats.Observe(cachedRegion, stores)
+	}
+	regionHeartbeatStageDuration.WithLabelValues("stats").Observe(time.Since(start).Seconds())
+}
@@ -78,6 +78,47 @@ var (
 			Name:      "region_list",
 			Help:      "Number of region in waiting list",
 		}, []string{"type"})
+
+	regionAnomalyGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "checker",
+			Name:      "region_anomalies",
+			Help:      "Number of region anomalies found by the region consistency checker",
+		}, []string{"type"})
+
+	regionHeartbeatStageDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "region_heartbeat_stage_duration_seconds",
+			Help:      "Bucketed histogram of processing time (s) of each stage of the region heartbeat pipeline.",
+			Buckets:   prometheus.ExponentialBuckets(0.00001, 2, 20),
+		}, []string{"stage"})
+
+	regionStatsQueueGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "region_stats_queue_length",
+			Help:      "Length of the queues of the region statistics workers.",
+		}, []string{"worker"})
+
+	regionHeartbeatBackpressureCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "region_heartbeat_backpressure_total",
+			Help:      "Counter of the region heartbeats blocked by the full queues of the region statistics workers.",
+		})
+
+	regionStorageInconsistencyGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "region_storage_inconsistencies",
+			Help:      "Number of inconsistent records found by the last region storage verification",
+		}, []string{"type"})
 )
 
 func init() {
@@ -89,4 +130,9 @@ func init() {
 	prometheus.MustRegister(clusterStateCPUGauge)
 	prometheus.MustRegister(clusterStateCurrent)
 	prometheus.MustRegister(regionListGauge)
+	prometheus.MustRegister(regionAnomalyGauge)
+	prometheus.MustRegister(regionStorageInconsistencyGauge)
+	prometheus.MustRegister(regionHeartbeatStageDuration)
+	prometheus.MustRegister(regionStatsQueueGauge)
+	prometheus.MustRegister(regionHeartbeatBackpressureCounter)
 }
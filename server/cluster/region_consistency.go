@@ -0,0 +1,399 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/server/core"
+	"go.uber.org/zap"
+)
+
+// The types of the region anomalies.
+const (
+	// RegionAnomalyHole means a key range is not covered by any region.
+	RegionAnomalyHole = "hole"
+	// RegionAnomalyOverlap means a key range is covered by more than one region.
+	RegionAnomalyOverlap = "overlap"
+	// RegionAnomalyStaleEpoch means a region has not been updated while its neighbour
+	// has a newer epoch.
+	RegionAnomalyStaleEpoch = "stale-epoch"
+	// RegionAnomalyNoLeader means a region has no leader for a long time.
+	RegionAnomalyNoLeader = "no-leader"
+	// RegionAnomalyTombstonePeer means a region has peers on tombstone or removed stores.
+	RegionAnomalyTombstonePeer = "tombstone-peer"
+)
+
+// RegionAnomalyTypes are all the types of the region anomalies.
+var RegionAnomalyTypes = []string{
+	RegionAnomalyHole,
+	RegionAnomalyOverlap,
+	RegionAnomalyStaleEpoch,
+	RegionAnomalyNoLeader,
+	RegionAnomalyTombstonePeer,
+}
+
+const (
+	// maxRegionAnomaliesPerType limits the anomalies listed in a report for each type,
+	// the counts are not limited.
+	maxRegionAnomaliesPerType = 1000
+	// staleRegionHeartbeatDuration is how long a region doesn't report heartbeat before
+	// it's considered stale when its neighbour has a newer epoch.
+	staleRegionHeartbeatDuration = 10 * time.Minute
+	// defaultRegionConsistencyCheckInterval is used if the configured interval
+	// is not positive.
+	defaultRegionConsistencyCheckInterval = time.Minute
+)
+
+// IsValidRegionAnomalyType checks if the type is a known region anomaly type.
+func IsValidRegionAnomalyType(typ string) bool {
+	for _, t := range RegionAnomalyTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// RegionAnomaly is an inconsistency found by the region consistency checker.
+type RegionAnomaly struct {
+	Type      string   `json:"type"`
+	RegionIDs []uint64 `json:"region_ids,omitempty"`
+	StoreIDs  []uint64 `json:"store_ids,omitempty"`
+	// StartKey and EndKey are the affected key range in hex format.
+	StartKey    string `json:"start_key"`
+	EndKey      string `json:"end_key"`
+	Description string `json:"description"`
+}
+
+// RegionConsistencyReport is the result of a round of the region consistency check.
+type RegionConsistencyReport struct {
+	CheckTime   time.Time        `json:"check_time"`
+	RegionCount int              `json:"region_count"`
+	Counts      map[string]int   `json:"counts"`
+	Anomalies   []*RegionAnomaly `json:"anomalies"`
+}
+
+func newRegionConsistencyReport(now time.Time, regionCount int) *RegionConsistencyReport {
+	report := &RegionConsistencyReport{
+		CheckTime:   now,
+		RegionCount: regionCount,
+		Counts:      make(map[string]int, len(RegionAnomalyTypes)),
+		Anomalies:   []*RegionAnomaly{},
+	}
+	for _, typ := range RegionAnomalyTypes {
+		report.Counts[typ] = 0
+	}
+	return report
+}
+
+func (r *RegionConsistencyReport) add(anomaly *RegionAnomaly) {
+	r.Counts[anomaly.Type]++
+	if r.Counts[anomaly.Type] <= maxRegionAnomaliesPerType {
+		r.Anomalies = append(r.Anomalies, anomaly)
+	}
+}
+
+// Filter returns a report only containing the anomalies of the given type.
+func (r *RegionConsistencyReport) Filter(typ string) *RegionConsistencyReport {
+	report := newRegionConsistencyReport(r.CheckTime, r.RegionCount)
+	report.Counts = map[string]int{typ: r.Counts[typ]}
+	for _, anomaly := range r.Anomalies {
+		if anomaly.Type == typ {
+			report.Anomalies = append(report.Anomalies, anomaly)
+		}
+	}
+	return report
+}
+
+type regionConsistencyCluster interface {
+	GetRegions() []*core.RegionInfo
+	GetRegionByKey(regionKey []byte) *core.RegionInfo
+	GetStore(storeID uint64) *core.StoreInfo
+}
+
+// regionConsistencyChecker checks the regions for holes and overlaps in the key space,
+// stale epochs, missing leaders and peers on tombstone stores.
+type regionConsistencyChecker struct {
+	sync.RWMutex
+	cluster regionConsistencyCluster
+	// noLeaderSince records when a region is found without leader for the first time.
+	noLeaderSince map[uint64]time.Time
+	lastReport    *RegionConsistencyReport
+}
+
+func newRegionConsistencyChecker(cluster regionConsistencyCluster) *regionConsistencyChecker {
+	return &regionConsistencyChecker{
+		cluster:       cluster,
+		noLeaderSince: make(map[uint64]time.Time),
+	}
+}
+
+// getLastReport returns the report of the last check, it returns nil if the check has never run.
+func (c *regionConsistencyChecker) getLastReport() *RegionConsistencyReport {
+	c.RLock()
+	defer c.RUnlock()
+	return c.lastReport
+}
+
+// check runs a round of check and updates the metrics.
+func (c *regionConsistencyChecker) check(now time.Time, maxNoLeaderDuration time.Duration) *RegionConsistencyReport {
+	c.Lock()
+	defer c.Unlock()
+	regions := c.cluster.GetRegions()
+	sort.Slice(regions, func(i, j int) bool {
+		if cmp := bytes.Compare(regions[i].GetStartKey(), regions[j].GetStartKey()); cmp != 0 {
+			return cmp < 0
+		}
+		return regions[i].GetID() < regions[j].GetID()
+	})
+	report := newRegionConsistencyReport(now, len(regions))
+	checkRegionRanges(regions, report)
+	c.checkRegionTree(regions, report)
+	checkRegionEpochs(regions, now, report)
+	c.checkRegionLeaders(regions, now, maxNoLeaderDuration, report)
+	c.checkRegionPeers(regions, report)
+
+	for typ, count := range report.Counts {
+		regionAnomalyGauge.WithLabelValues(typ).Set(float64(count))
+	}
+	c.lastReport = report
+	return report
+}
+
+// checkRegionRanges finds the holes and overlaps among the regions sorted by start key.
+func checkRegionRanges(regions []*core.RegionInfo, report *RegionConsistencyReport) {
+	if len(regions) == 0 {
+		return
+	}
+	// last is the region which covers the key space up to end.
+	var last *core.RegionInfo
+	end := []byte("")
+	for _, region := range regions {
+		startKey, endKey := region.GetStartKey(), region.GetEndKey()
+		if last != nil && len(end) == 0 {
+			// The key space has been covered to the end.
+			addOverlap(report, last, region, startKey, endKey)
+			continue
+		}
+		switch cmp := bytes.Compare(startKey, end); {
+		case cmp > 0:
+			report.add(&RegionAnomaly{
+				Type:        RegionAnomalyHole,
+				StartKey:    core.HexRegionKeyStr(end),
+				EndKey:      core.HexRegionKeyStr(startKey),
+				Description: fmt.Sprintf("key range is not covered by any region, the next region is %d", region.GetID()),
+			})
+		case cmp < 0:
+			overlapEnd := end
+			if len(endKey) > 0 && bytes.Compare(endKey, end) < 0 {
+				overlapEnd = endKey
+			}
+			addOverlap(report, last, region, startKey, overlapEnd)
+		}
+		if len(endKey) == 0 || bytes.Compare(endKey, end) > 0 {
+			last, end = region, endKey
+		}
+	}
+	if len(end) > 0 {
+		report.add(&RegionAnomaly{
+			Type:        RegionAnomalyHole,
+			StartKey:    core.HexRegionKeyStr(end),
+			EndKey:      "",
+			Description: fmt.Sprintf("key range is not covered by any region, the previous region is %d", last.GetID()),
+		})
+	}
+}
+
+func addOverlap(report *RegionConsistencyReport, a, b *core.RegionInfo, startKey, endKey []byte) {
+	report.add(&RegionAnomaly{
+		Type:      RegionAnomalyOverlap,
+		RegionIDs: []uint64{a.GetID(), b.GetID()},
+		StartKey:  core.HexRegionKeyStr(startKey),
+		EndKey:    core.HexRegionKeyStr(endKey),
+		Description: fmt.Sprintf("key range is covered by region %d (version %d) and region %d (version %d)",
+			a.GetID(), a.GetRegionEpoch().GetVersion(), b.GetID(), b.GetRegionEpoch().GetVersion()),
+	})
+}
+
+// checkRegionTree finds the regions which are cached but can't be located by key, which means
+// the region tree has been taken over by other regions.
+func (c *regionConsistencyChecker) checkRegionTree(regions []*core.RegionInfo, report *RegionConsistencyReport) {
+	for _, region := range regions {
+		found := c.cluster.GetRegionByKey(region.GetStartKey())
+		if found != nil && found.GetID() == region.GetID() {
+			continue
+		}
+		anomaly := &RegionAnomaly{
+			Type:        RegionAnomalyOverlap,
+			RegionIDs:   []uint64{region.GetID()},
+			StartKey:    core.HexRegionKeyStr(region.GetStartKey()),
+			EndKey:      core.HexRegionKeyStr(region.GetEndKey()),
+			Description: fmt.Sprintf("region %d is cached but not indexed by its start key", region.GetID()),
+		}
+		if found != nil {
+			anomaly.RegionIDs = append(anomaly.RegionIDs, found.GetID())
+			anomaly.Description = fmt.Sprintf("region %d is cached but its start key is indexed to region %d", region.GetID(), found.GetID())
+		}
+		report.add(anomaly)
+	}
+}
+
+// checkRegionEpochs finds the regions which don't report heartbeats for a long time while their
+// adjacent regions have been updated to newer versions.
+func checkRegionEpochs(regions []*core.RegionInfo, now time.Time, report *RegionConsistencyReport) {
+	for i := 1; i < len(regions); i++ {
+		prev, next := regions[i-1], regions[i]
+		if !bytes.Equal(prev.GetEndKey(), next.GetStartKey()) {
+			continue
+		}
+		stale, neighbour := prev, next
+		if prev.GetRegionEpoch().GetVersion() > next.GetRegionEpoch().GetVersion() {
+			stale, neighbour = next, prev
+		} else if prev.GetRegionEpoch().GetVersion() == next.GetRegionEpoch().GetVersion() {
+			continue
+		}
+		staleHeartbeat, neighbourHeartbeat := lastHeartbeat(stale), lastHeartbeat(neighbour)
+		// The regions loaded from storage don't have heartbeat yet.
+		if staleHeartbeat.IsZero() || neighbourHeartbeat.IsZero() {
+			continue
+		}
+		if now.Sub(staleHeartbeat) < staleRegionHeartbeatDuration || !neighbourHeartbeat.After(staleHeartbeat) {
+			continue
+		}
+		report.add(&RegionAnomaly{
+			Type:      RegionAnomalyStaleEpoch,
+			RegionIDs: []uint64{stale.GetID(), neighbour.GetID()},
+			StartKey:  core.HexRegionKeyStr(stale.GetStartKey()),
+			EndKey:    core.HexRegionKeyStr(stale.GetEndKey()),
+			Description: fmt.Sprintf("region %d (version %d) has no heartbeat since %s while its neighbour region %d has version %d",
+				stale.GetID(), stale.GetRegionEpoch().GetVersion(), staleHeartbeat.Format(time.RFC3339),
+				neighbour.GetID(), neighbour.GetRegionEpoch().GetVersion()),
+		})
+	}
+}
+
+func lastHeartbeat(region *core.RegionInfo) time.Time {
+	if ts := region.GetInterval().GetEndTimestamp(); ts > 0 {
+		return time.Unix(int64(ts), 0)
+	}
+	return time.Time{}
+}
+
+// checkRegionLeaders finds the regions without leader for longer than maxNoLeaderDuration.
+func (c *regionConsistencyChecker) checkRegionLeaders(regions []*core.RegionInfo, now time.Time, maxNoLeaderDuration time.Duration, report *RegionConsistencyReport) {
+	noLeaderSince := make(map[uint64]time.Time)
+	for _, region := range regions {
+		if region.GetLeader() != nil {
+			continue
+		}
+		since, ok := c.noLeaderSince[region.GetID()]
+		if !ok {
+			since = now
+		}
+		noLeaderSince[region.GetID()] = since
+		if now.Sub(since) < maxNoLeaderDuration {
+			continue
+		}
+		report.add(&RegionAnomaly{
+			Type:        RegionAnomalyNoLeader,
+			RegionIDs:   []uint64{region.GetID()},
+			StartKey:    core.HexRegionKeyStr(region.GetStartKey()),
+			EndKey:      core.HexRegionKeyStr(region.GetEndKey()),
+			Description: fmt.Sprintf("region %d has no leader since %s", region.GetID(), since.Format(time.RFC3339)),
+		})
+	}
+	c.noLeaderSince = noLeaderSince
+}
+
+// checkRegionPeers finds the regions which have peers on tombstone or removed stores.
+func (c *regionConsistencyChecker) checkRegionPeers(regions []*core.RegionInfo, report *RegionConsistencyReport) {
+	for _, region := range regions {
+		var storeIDs []uint64
+		for _, peer := range region.GetPeers() {
+			store := c.cluster.GetStore(peer.GetStoreId())
+			if store == nil || store.IsTombstone() {
+				storeIDs = append(storeIDs, peer.GetStoreId())
+			}
+		}
+		if len(storeIDs) == 0 {
+			continue
+		}
+		report.add(&RegionAnomaly{
+			Type:        RegionAnomalyTombstonePeer,
+			RegionIDs:   []uint64{region.GetID()},
+			StoreIDs:    storeIDs,
+			StartKey:    core.HexRegionKeyStr(region.GetStartKey()),
+			EndKey:      core.HexRegionKeyStr(region.GetEndKey()),
+			Description: fmt.Sprintf("region %d has peers on tombstone or removed stores %v", region.GetID(), storeIDs),
+		})
+	}
+}
+
+func (c *RaftCluster) runRegionConsistencyChecker() {
+	defer logutil.LogPanic()
+	defer c.wg.Done()
+
+	interval := c.opt.GetPDServerConfig().RegionConsistencyCheckInterval.Duration
+	// The interval is validated, but it may be persisted by an older version.
+	if interval <= 0 {
+		interval = defaultRegionConsistencyCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.quit:
+			regionAnomalyGauge.Reset()
+			log.Info("region consistency checker has been stopped")
+			return
+		case <-ticker.C:
+			// The regions are incomplete before the cluster is prepared.
+			if c.isPrepared() {
+				c.CheckRegionConsistency()
+			}
+			if newInterval := c.opt.GetPDServerConfig().RegionConsistencyCheckInterval.Duration; newInterval > 0 && newInterval != interval {
+				interval = newInterval
+				ticker.Reset(interval)
+			}
+		}
+	}
+}
+
+// CheckRegionConsistency checks the consistency of the regions immediately and returns the report.
+func (c *RaftCluster) CheckRegionConsistency() *RegionConsistencyReport {
+	maxNoLeaderDuration := c.opt.GetPDServerConfig().MaxRegionNoLeaderDuration.Duration
+	report := c.regionConsistencyChecker.check(time.Now(), maxNoLeaderDuration)
+	for _, typ := range RegionAnomalyTypes {
+		if report.Counts[typ] > 0 {
+			log.Warn("found region anomalies",
+				zap.String("type", typ),
+				zap.Int("count", report.Counts[typ]))
+		}
+	}
+	return report
+}
+
+// GetRegionConsistencyReport returns the report of the last region consistency check.
+// It returns nil if the check has never run.
+func (c *RaftCluster) GetRegionConsistencyReport() *RegionConsistencyReport {
+	return c.regionConsistencyChecker.getLastReport()
+}
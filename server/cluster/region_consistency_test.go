@@ -0,0 +1,194 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/server/core"
+)
+
+var _ = Suite(&testRegionConsistencySuite{})
+
+type testRegionConsistencySuite struct{}
+
+// mockConsistencyCluster keeps the regions in a map without the region tree, so the
+// inconsistent regions can be put into it.
+type mockConsistencyCluster struct {
+	regions map[uint64]*core.RegionInfo
+	stores  map[uint64]*core.StoreInfo
+}
+
+func newMockConsistencyCluster() *mockConsistencyCluster {
+	return &mockConsistencyCluster{
+		regions: make(map[uint64]*core.RegionInfo),
+		stores:  make(map[uint64]*core.StoreInfo),
+	}
+}
+
+func (m *mockConsistencyCluster) GetRegions() []*core.RegionInfo {
+	regions := make([]*core.RegionInfo, 0, len(m.regions))
+	for _, region := range m.regions {
+		regions = append(regions, region)
+	}
+	return regions
+}
+
+func (m *mockConsistencyCluster) GetRegionByKey(regionKey []byte) *core.RegionInfo {
+	var found *core.RegionInfo
+	for _, region := range m.regions {
+		contains := bytes.Compare(region.GetStartKey(), regionKey) <= 0 &&
+			(len(region.GetEndKey()) == 0 || bytes.Compare(regionKey, region.GetEndKey()) < 0)
+		if contains && (found == nil || region.GetRegionEpoch().GetVersion() > found.GetRegionEpoch().GetVersion()) {
+			found = region
+		}
+	}
+	return found
+}
+
+func (m *mockConsistencyCluster) GetStore(storeID uint64) *core.StoreInfo {
+	return m.stores[storeID]
+}
+
+func (m *mockConsistencyCluster) putStore(storeID uint64, state metapb.StoreState) {
+	m.stores[storeID] = core.NewStoreInfo(&metapb.Store{Id: storeID, State: state})
+}
+
+func (m *mockConsistencyCluster) putRegion(regionID uint64, startKey, endKey string, version uint64, hasLeader bool, heartbeat time.Time, storeIDs ...uint64) {
+	meta := &metapb.Region{
+		Id:          regionID,
+		StartKey:    []byte(startKey),
+		EndKey:      []byte(endKey),
+		RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: version},
+	}
+	for i, storeID := range storeIDs {
+		meta.Peers = append(meta.Peers, &metapb.Peer{Id: regionID*10 + uint64(i), StoreId: storeID})
+	}
+	var leader *metapb.Peer
+	if hasLeader && len(meta.Peers) > 0 {
+		leader = meta.Peers[0]
+	}
+	var opts []core.RegionCreateOption
+	if !heartbeat.IsZero() {
+		opts = append(opts, core.SetReportInterval(uint64(heartbeat.Unix())))
+	}
+	m.regions[regionID] = core.NewRegionInfo(meta, leader, opts...)
+}
+
+func (s *testRegionConsistencySuite) TestConsistentRegions(c *C) {
+	cluster := newMockConsistencyCluster()
+	cluster.putStore(1, metapb.StoreState_Up)
+	now := time.Now()
+	cluster.putRegion(1, "", "b", 1, true, now, 1)
+	cluster.putRegion(2, "b", "d", 2, true, now, 1)
+	cluster.putRegion(3, "d", "", 1, true, now, 1)
+
+	checker := newRegionConsistencyChecker(cluster)
+	c.Assert(checker.getLastReport(), IsNil)
+	report := checker.check(now, time.Minute)
+	c.Assert(report.RegionCount, Equals, 3)
+	c.Assert(report.Anomalies, HasLen, 0)
+	for _, typ := range RegionAnomalyTypes {
+		c.Assert(report.Counts[typ], Equals, 0)
+	}
+	c.Assert(checker.getLastReport(), Equals, report)
+}
+
+func (s *testRegionConsistencySuite) TestHoleAndOverlap(c *C) {
+	cluster := newMockConsistencyCluster()
+	cluster.putStore(1, metapb.StoreState_Up)
+	now := time.Now()
+	cluster.putRegion(1, "a", "c", 1, true, now, 1)
+	cluster.putRegion(2, "b", "e", 2, true, now, 1)
+	cluster.putRegion(3, "f", "h", 1, true, now, 1)
+
+	report := newRegionConsistencyChecker(cluster).check(now, time.Minute)
+	// ["", "a"), ["e", "f") and ["h", "") are holes.
+	c.Assert(report.Counts[RegionAnomalyHole], Equals, 3)
+	var holes [][2]string
+	for _, anomaly := range report.Anomalies {
+		if anomaly.Type == RegionAnomalyHole {
+			holes = append(holes, [2]string{anomaly.StartKey, anomaly.EndKey})
+		}
+	}
+	c.Assert(holes, DeepEquals, [][2]string{
+		{"", core.HexRegionKeyStr([]byte("a"))},
+		{core.HexRegionKeyStr([]byte("e")), core.HexRegionKeyStr([]byte("f"))},
+		{core.HexRegionKeyStr([]byte("h")), ""},
+	})
+	// Region 1 and 2 overlap in ["b", "c").
+	c.Assert(report.Counts[RegionAnomalyOverlap], Equals, 1)
+	overlap := report.Filter(RegionAnomalyOverlap)
+	c.Assert(overlap.Anomalies, HasLen, 1)
+	c.Assert(overlap.Anomalies[0].RegionIDs, DeepEquals, []uint64{1, 2})
+	c.Assert(overlap.Anomalies[0].StartKey, Equals, core.HexRegionKeyStr([]byte("b")))
+	c.Assert(overlap.Anomalies[0].EndKey, Equals, core.HexRegionKeyStr([]byte("c")))
+
+	// The region covering the whole key space overlaps with all other regions.
+	cluster.putRegion(4, "", "", 3, true, now, 1)
+	report = newRegionConsistencyChecker(cluster).check(now, time.Minute)
+	c.Assert(report.Counts[RegionAnomalyHole], Equals, 0)
+	c.Assert(report.Counts[RegionAnomalyOverlap], Equals, 6)
+}
+
+func (s *testRegionConsistencySuite) TestStaleEpoch(c *C) {
+	cluster := newMockConsistencyCluster()
+	cluster.putStore(1, metapb.StoreState_Up)
+	now := time.Now()
+	cluster.putRegion(1, "", "b", 1, true, now.Add(-time.Hour), 1)
+	cluster.putRegion(2, "b", "d", 3, true, now, 1)
+	// The region loaded from storage doesn't have heartbeat.
+	cluster.putRegion(3, "d", "", 1, true, time.Time{}, 1)
+
+	report := newRegionConsistencyChecker(cluster).check(now, time.Minute)
+	c.Assert(report.Counts[RegionAnomalyStaleEpoch], Equals, 1)
+	c.Assert(report.Anomalies, HasLen, 1)
+	c.Assert(report.Anomalies[0].RegionIDs, DeepEquals, []uint64{1, 2})
+
+	// The region reports heartbeat again.
+	cluster.putRegion(1, "", "b", 1, true, now, 1)
+	report = newRegionConsistencyChecker(cluster).check(now, time.Minute)
+	c.Assert(report.Counts[RegionAnomalyStaleEpoch], Equals, 0)
+}
+
+func (s *testRegionConsistencySuite) TestNoLeaderAndTombstonePeer(c *C) {
+	cluster := newMockConsistencyCluster()
+	cluster.putStore(1, metapb.StoreState_Up)
+	cluster.putStore(2, metapb.StoreState_Tombstone)
+	now := time.Now()
+	cluster.putRegion(1, "", "b", 1, false, now, 1)
+	cluster.putRegion(2, "b", "", 1, true, now, 1, 2, 3)
+
+	checker := newRegionConsistencyChecker(cluster)
+	report := checker.check(now, time.Minute)
+	c.Assert(report.Counts[RegionAnomalyNoLeader], Equals, 0)
+	c.Assert(report.Counts[RegionAnomalyTombstonePeer], Equals, 1)
+	tombstone := report.Filter(RegionAnomalyTombstonePeer).Anomalies[0]
+	c.Assert(tombstone.RegionIDs, DeepEquals, []uint64{2})
+	// Store 3 has been removed.
+	c.Assert(tombstone.StoreIDs, DeepEquals, []uint64{2, 3})
+
+	report = checker.check(now.Add(2*time.Minute), time.Minute)
+	c.Assert(report.Counts[RegionAnomalyNoLeader], Equals, 1)
+	c.Assert(report.Filter(RegionAnomalyNoLeader).Anomalies[0].RegionIDs, DeepEquals, []uint64{1})
+
+	// The leader is elected.
+	cluster.putRegion(1, "", "b", 1, true, now, 1)
+	report = checker.check(now.Add(3*time.Minute), time.Minute)
+	c.Assert(report.Counts[RegionAnomalyNoLeader], Equals, 0)
+	c.Assert(checker.noLeaderSince, HasLen, 0)
+}
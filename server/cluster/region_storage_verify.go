@@ -0,0 +1,112 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/server/core"
+	"go.uber.org/zap"
+)
+
+// defaultRegionStorageVerifyInterval is used if the configured interval is not
+// positive.
+const defaultRegionStorageVerifyInterval = 24 * time.Hour
+
+// regionStorageVerifier serializes the verifications of the region storage and keeps
+// the last report.
+type regionStorageVerifier struct {
+	running sync.Mutex
+	sync.RWMutex
+	lastReport *core.RegionStorageReport
+}
+
+func (c *RaftCluster) runRegionStorageVerifier() {
+	defer logutil.LogPanic()
+	defer c.wg.Done()
+
+	interval := c.opt.GetPDServerConfig().RegionStorageVerifyInterval.Duration
+	// The interval is validated, but it may be persisted by an older version.
+	if interval <= 0 {
+		interval = defaultRegionStorageVerifyInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.quit:
+			regionStorageInconsistencyGauge.Reset()
+			log.Info("region storage verifier has been stopped")
+			return
+		case <-ticker.C:
+			// The regions are incomplete before the cluster is prepared, the records
+			// of the regions not loaded yet would be removed as orphans.
+			if c.isPrepared() && c.opt.IsUseRegionStorage() {
+				if _, err := c.VerifyRegionStorage(true); err != nil {
+					log.Error("failed to verify region storage", errs.ZapError(err))
+				}
+			}
+			if newInterval := c.opt.GetPDServerConfig().RegionStorageVerifyInterval.Duration; newInterval > 0 && newInterval != interval {
+				interval = newInterval
+				ticker.Reset(interval)
+			}
+		}
+	}
+}
+
+// VerifyRegionStorage verifies the region storage against the regions in memory. If
+// repair is true, the inconsistent records are repaired and the storage is compacted.
+func (c *RaftCluster) VerifyRegionStorage(repair bool) (*core.RegionStorageReport, error) {
+	regionStorage := c.storage.GetRegionStorage()
+	if regionStorage == nil || !c.opt.IsUseRegionStorage() {
+		return nil, errs.ErrRegionStorageNotEnabled.FastGenByArgs()
+	}
+	if !c.isPrepared() {
+		return nil, errs.ErrClusterNotPrepared.FastGenByArgs()
+	}
+	c.regionStorageVerifier.running.Lock()
+	defer c.regionStorageVerifier.running.Unlock()
+	report, err := regionStorage.Verify(c.core, repair)
+	if err != nil {
+		return nil, err
+	}
+	regionStorageInconsistencyGauge.WithLabelValues("orphaned").Set(float64(report.OrphanedCount))
+	regionStorageInconsistencyGauge.WithLabelValues("stale").Set(float64(report.StaleCount))
+	regionStorageInconsistencyGauge.WithLabelValues("missing").Set(float64(report.MissingCount))
+	log.Info("region storage is verified",
+		zap.Int("stored", report.StoredCount),
+		zap.Int("regions", report.RegionCount),
+		zap.Int("orphaned", report.OrphanedCount),
+		zap.Int("stale", report.StaleCount),
+		zap.Int("missing", report.MissingCount),
+		zap.Bool("repaired", report.Repaired),
+		zap.Duration("cost", report.Duration))
+	c.regionStorageVerifier.Lock()
+	c.regionStorageVerifier.lastReport = report
+	c.regionStorageVerifier.Unlock()
+	return report, nil
+}
+
+// GetRegionStorageReport returns the report of the last region storage verification.
+// It returns nil if the verification has never run.
+func (c *RaftCluster) GetRegionStorageReport() *core.RegionStorageReport {
+	c.regionStorageVerifier.RLock()
+	defer c.regionStorageVerifier.RUnlock()
+	return c.regionStorageVerifier.lastReport
+}
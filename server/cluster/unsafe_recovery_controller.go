@@ -0,0 +1,456 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/core"
+	"go.uber.org/zap"
+)
+
+// The stages of the unsafe recovery.
+const (
+	UnsafeRecoveryIdle       = "idle"
+	UnsafeRecoveryRecovering = "recovering"
+	UnsafeRecoveryFinished   = "finished"
+	UnsafeRecoveryFailed     = "failed"
+)
+
+// The states of the store recovery plans.
+const (
+	// RecoveryPlanPending means the plan has not been fetched by the store.
+	RecoveryPlanPending = "pending"
+	// RecoveryPlanDelivered means the plan has been fetched by the store.
+	RecoveryPlanDelivered = "delivered"
+	// RecoveryPlanApplied means the regions reported by heartbeats match the plan.
+	RecoveryPlanApplied = "applied"
+)
+
+// StoreRecoveryPlan is the plan a store needs to apply to recover the regions
+// which lose the majority of their peers.
+type StoreRecoveryPlan struct {
+	StoreID uint64 `json:"store_id"`
+	// Updates are the regions whose peers on the failed stores are removed forcibly.
+	Updates []*metapb.Region `json:"updates,omitempty"`
+	// Creates are the empty regions to fill the key ranges whose regions have no
+	// peer left.
+	Creates []*metapb.Region `json:"creates,omitempty"`
+	State   string           `json:"state"`
+}
+
+// UnsafeRecoveryStatus is the status of the unsafe recovery.
+type UnsafeRecoveryStatus struct {
+	Stage        string               `json:"stage"`
+	FailedStores []uint64             `json:"failed_stores,omitempty"`
+	StartTime    time.Time            `json:"start_time"`
+	Deadline     time.Time            `json:"deadline"`
+	FinishTime   time.Time            `json:"finish_time"`
+	Error        string               `json:"error,omitempty"`
+	Plans        []*StoreRecoveryPlan `json:"plans,omitempty"`
+}
+
+// unsafeRecoveryController drives the recovery of the regions which lose the majority
+// of their peers because of the permanently failed stores. It generates a plan for each
+// alive store, the stores fetch their plans and apply them, and the progress is checked
+// with the region heartbeats.
+// The plans are served by the HTTP API POST /admin/unsafe/plan/{id} rather than the store
+// heartbeat responses, because StoreHeartbeatResponse in kvproto has no field to carry
+// them yet. The recovery tool on the store side needs to poll the plan of its store.
+type unsafeRecoveryController struct {
+	sync.RWMutex
+	cluster      *RaftCluster
+	stage        string
+	failedStores map[uint64]struct{}
+	plans        map[uint64]*StoreRecoveryPlan
+	startTime    time.Time
+	deadline     time.Time
+	finishTime   time.Time
+	err          string
+}
+
+func newUnsafeRecoveryController(cluster *RaftCluster) *unsafeRecoveryController {
+	return &unsafeRecoveryController{
+		cluster: cluster,
+		stage:   UnsafeRecoveryIdle,
+	}
+}
+
+// removeFailedStores marks the stores as failed and generates the recovery plans.
+func (u *unsafeRecoveryController) removeFailedStores(storeIDs []uint64, timeout time.Duration, now time.Time) error {
+	u.Lock()
+	if err := u.startLocked(storeIDs, timeout, now); err != nil {
+		u.Unlock()
+		return err
+	}
+	finished := u.checkProgressLocked(now)
+	u.Unlock()
+	u.removeStores(finished)
+	return nil
+}
+
+// startLocked checks the failed stores and starts the recovery with the generated plans.
+func (u *unsafeRecoveryController) startLocked(storeIDs []uint64, timeout time.Duration, now time.Time) error {
+	if u.stage == UnsafeRecoveryRecovering {
+		return errs.ErrUnsafeRecoveryIsRunning.FastGenByArgs()
+	}
+	if len(storeIDs) == 0 {
+		return errs.ErrUnsafeRecoveryInvalidInput.FastGenByArgs("no store specified")
+	}
+	failedStores := make(map[uint64]struct{}, len(storeIDs))
+	for _, storeID := range storeIDs {
+		store := u.cluster.GetStore(storeID)
+		if store == nil {
+			return errs.ErrUnsafeRecoveryInvalidInput.FastGenByArgs(fmt.Sprintf("store %d doesn't exist", storeID))
+		}
+		if store.IsTombstone() {
+			return errs.ErrUnsafeRecoveryInvalidInput.FastGenByArgs(fmt.Sprintf("store %d is tombstone", storeID))
+		}
+		// A store still sending heartbeats is not failed, removing it forcibly may lose data.
+		if !store.IsDisconnected() {
+			return errs.ErrUnsafeRecoveryInvalidInput.FastGenByArgs(fmt.Sprintf("store %d is neither disconnected nor down", storeID))
+		}
+		failedStores[storeID] = struct{}{}
+	}
+	plans, err := u.generatePlans(failedStores)
+	if err != nil {
+		return err
+	}
+
+	u.failedStores = failedStores
+	u.plans = plans
+	u.startTime, u.deadline, u.finishTime = now, now.Add(timeout), time.Time{}
+	u.err = ""
+	u.stage = UnsafeRecoveryRecovering
+	log.Warn("unsafe recovery starts",
+		zap.Uint64s("failed-stores", storeIDs),
+		zap.Int("plan-count", len(plans)),
+		zap.Duration("timeout", timeout))
+	return nil
+}
+
+// generatePlans generates the recovery plans for the alive stores. The regions which lose
+// the majority of their voters remove the failed peers forcibly, and the key ranges without
+// any surviving region are filled with empty regions.
+func (u *unsafeRecoveryController) generatePlans(failedStores map[uint64]struct{}) (map[uint64]*StoreRecoveryPlan, error) {
+	// regionCounts is used to place the empty regions on the alive stores evenly.
+	regionCounts := make(map[uint64]int)
+	for _, store := range u.cluster.GetStores() {
+		if _, ok := failedStores[store.GetID()]; !ok && store.IsUp() {
+			regionCounts[store.GetID()] = u.cluster.GetStoreRegionCount(store.GetID())
+		}
+	}
+	if len(regionCounts) == 0 {
+		return nil, errs.ErrUnsafeRecoveryInvalidInput.FastGenByArgs("no alive store left")
+	}
+
+	plans := make(map[uint64]*StoreRecoveryPlan)
+	getPlan := func(storeID uint64) *StoreRecoveryPlan {
+		plan, ok := plans[storeID]
+		if !ok {
+			plan = &StoreRecoveryPlan{StoreID: storeID, State: RecoveryPlanPending}
+			plans[storeID] = plan
+		}
+		return plan
+	}
+
+	regions := u.cluster.GetRegions()
+	sort.Slice(regions, func(i, j int) bool {
+		return bytes.Compare(regions[i].GetStartKey(), regions[j].GetStartKey()) < 0
+	})
+	var (
+		end                []byte
+		maxLostVersion     uint64
+		maxLostConfVer     uint64
+		coveredToTheEnd    bool
+		createEmptyRegions = func(startKey, endKey []byte) error {
+			storeID := leastRegionStore(regionCounts)
+			region, err := u.newEmptyRegion(startKey, endKey, storeID, maxLostVersion+1, maxLostConfVer+1)
+			if err != nil {
+				return err
+			}
+			regionCounts[storeID]++
+			getPlan(storeID).Creates = append(getPlan(storeID).Creates, region)
+			maxLostVersion, maxLostConfVer = 0, 0
+			return nil
+		}
+	)
+	for _, region := range regions {
+		survivors, lost := recoverRegionPeers(region, failedStores)
+		if lost {
+			if len(survivors) == 0 {
+				// The key range of the region becomes a hole, the version of the empty region
+				// must be larger than the lost ones to replace them.
+				maxLostVersion = maxUint64(maxLostVersion, region.GetRegionEpoch().GetVersion())
+				maxLostConfVer = maxUint64(maxLostConfVer, region.GetRegionEpoch().GetConfVer())
+				continue
+			}
+			meta := region.GetMeta()
+			updated := &metapb.Region{
+				Id:       meta.GetId(),
+				StartKey: meta.GetStartKey(),
+				EndKey:   meta.GetEndKey(),
+				RegionEpoch: &metapb.RegionEpoch{
+					ConfVer: meta.GetRegionEpoch().GetConfVer() + 1,
+					Version: meta.GetRegionEpoch().GetVersion(),
+				},
+				Peers:          survivors,
+				EncryptionMeta: meta.GetEncryptionMeta(),
+			}
+			for _, peer := range survivors {
+				getPlan(peer.GetStoreId()).Updates = append(getPlan(peer.GetStoreId()).Updates, updated)
+			}
+		}
+		if bytes.Compare(region.GetStartKey(), end) > 0 {
+			if err := createEmptyRegions(end, region.GetStartKey()); err != nil {
+				return nil, err
+			}
+		}
+		end = region.GetEndKey()
+		if len(end) == 0 {
+			coveredToTheEnd = true
+			break
+		}
+	}
+	if !coveredToTheEnd {
+		if err := createEmptyRegions(end, nil); err != nil {
+			return nil, err
+		}
+	}
+	return plans, nil
+}
+
+// recoverRegionPeers returns the peers left after removing the peers on the failed stores,
+// lost is true if the region loses the majority of its voters and needs to be recovered.
+func recoverRegionPeers(region *core.RegionInfo, failedStores map[uint64]struct{}) (survivors []*metapb.Peer, lost bool) {
+	var voters, aliveVoters int
+	hasAliveVoter := false
+	for _, peer := range region.GetPeers() {
+		isVoter := !core.IsLearner(peer)
+		if isVoter {
+			voters++
+		}
+		if _, ok := failedStores[peer.GetStoreId()]; ok {
+			continue
+		}
+		if isVoter {
+			aliveVoters++
+			hasAliveVoter = true
+		}
+		// The joint state can't be finished without the failed peers, so it's left directly.
+		role := peer.GetRole()
+		if role == metapb.PeerRole_IncomingVoter || role == metapb.PeerRole_DemotingVoter {
+			role = metapb.PeerRole_Voter
+		}
+		survivors = append(survivors, &metapb.Peer{Id: peer.GetId(), StoreId: peer.GetStoreId(), Role: role})
+	}
+	if voters == 0 || aliveVoters*2 > voters {
+		return nil, false
+	}
+	// The learners are promoted if there is no voter left.
+	if !hasAliveVoter {
+		for _, peer := range survivors {
+			peer.Role = metapb.PeerRole_Voter
+		}
+	}
+	return survivors, true
+}
+
+func (u *unsafeRecoveryController) newEmptyRegion(startKey, endKey []byte, storeID, version, confVer uint64) (*metapb.Region, error) {
+	regionID, err := u.cluster.AllocID()
+	if err != nil {
+		return nil, err
+	}
+	peerID, err := u.cluster.AllocID()
+	if err != nil {
+		return nil, err
+	}
+	return &metapb.Region{
+		Id:          regionID,
+		StartKey:    startKey,
+		EndKey:      endKey,
+		RegionEpoch: &metapb.RegionEpoch{ConfVer: confVer, Version: version},
+		Peers:       []*metapb.Peer{{Id: peerID, StoreId: storeID}},
+	}, nil
+}
+
+func leastRegionStore(regionCounts map[uint64]int) uint64 {
+	var target uint64
+	for storeID, count := range regionCounts {
+		if target == 0 || count < regionCounts[target] || (count == regionCounts[target] && storeID < target) {
+			target = storeID
+		}
+	}
+	return target
+}
+
+func maxUint64(a, b uint64) uint64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// getStorePlan returns the plan of the store, and marks it delivered if deliver is true.
+// It returns nil if the store has nothing to do.
+func (u *unsafeRecoveryController) getStorePlan(storeID uint64, deliver bool) *StoreRecoveryPlan {
+	u.Lock()
+	defer u.Unlock()
+	if u.stage != UnsafeRecoveryRecovering {
+		return nil
+	}
+	plan, ok := u.plans[storeID]
+	if !ok || plan.State == RecoveryPlanApplied {
+		return nil
+	}
+	if deliver {
+		plan.State = RecoveryPlanDelivered
+	}
+	p := *plan
+	return &p
+}
+
+// checkProgress checks if the plans have been applied. The failed stores are removed once
+// all the plans are applied.
+func (u *unsafeRecoveryController) checkProgress(now time.Time) {
+	u.Lock()
+	finished := u.checkProgressLocked(now)
+	u.Unlock()
+	u.removeStores(finished)
+}
+
+// checkProgressLocked updates the states of the plans, it returns the failed stores to
+// remove if the recovery has just finished. The stores are removed without holding the
+// lock, since removing a store needs the lock of the cluster.
+func (u *unsafeRecoveryController) checkProgressLocked(now time.Time) []uint64 {
+	if u.stage != UnsafeRecoveryRecovering {
+		return nil
+	}
+	finished := true
+	for _, plan := range u.plans {
+		if plan.State != RecoveryPlanApplied && u.isPlanApplied(plan) {
+			plan.State = RecoveryPlanApplied
+			log.Info("store recovery plan has been applied", zap.Uint64("store-id", plan.StoreID))
+		}
+		finished = finished && plan.State == RecoveryPlanApplied
+	}
+	if !finished {
+		if now.After(u.deadline) {
+			u.stage, u.finishTime = UnsafeRecoveryFailed, now
+			u.err = "timeout, some plans are not applied"
+			log.Error("unsafe recovery failed", zap.String("error", u.err))
+		}
+		return nil
+	}
+	u.stage, u.finishTime = UnsafeRecoveryFinished, now
+	log.Warn("unsafe recovery finished", zap.Duration("cost", now.Sub(u.startTime)))
+	storeIDs := make([]uint64, 0, len(u.failedStores))
+	for storeID := range u.failedStores {
+		storeIDs = append(storeIDs, storeID)
+	}
+	return storeIDs
+}
+
+func (u *unsafeRecoveryController) removeStores(storeIDs []uint64) {
+	for _, storeID := range storeIDs {
+		if err := u.cluster.RemoveStore(storeID, true); err != nil {
+			log.Warn("failed to remove the failed store", zap.Uint64("store-id", storeID), errs.ZapError(err))
+		}
+	}
+}
+
+func (u *unsafeRecoveryController) isPlanApplied(plan *StoreRecoveryPlan) bool {
+	for _, update := range plan.Updates {
+		region := u.cluster.GetRegion(update.GetId())
+		// The region may have been replaced by others.
+		if region == nil {
+			continue
+		}
+		if region.GetRegionEpoch().GetConfVer() < update.GetRegionEpoch().GetConfVer() {
+			return false
+		}
+		for _, peer := range region.GetPeers() {
+			if _, ok := u.failedStores[peer.GetStoreId()]; ok {
+				return false
+			}
+		}
+	}
+	for _, create := range plan.Creates {
+		if u.cluster.GetRegion(create.GetId()) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (u *unsafeRecoveryController) getStatus() *UnsafeRecoveryStatus {
+	u.RLock()
+	defer u.RUnlock()
+	status := &UnsafeRecoveryStatus{
+		Stage:      u.stage,
+		StartTime:  u.startTime,
+		Deadline:   u.deadline,
+		FinishTime: u.finishTime,
+		Error:      u.err,
+	}
+	for storeID := range u.failedStores {
+		status.FailedStores = append(status.FailedStores, storeID)
+	}
+	sort.Slice(status.FailedStores, func(i, j int) bool { return status.FailedStores[i] < status.FailedStores[j] })
+	for _, plan := range u.plans {
+		p := *plan
+		status.Plans = append(status.Plans, &p)
+	}
+	sort.Slice(status.Plans, func(i, j int) bool { return status.Plans[i].StoreID < status.Plans[j].StoreID })
+	return status
+}
+
+// RemoveFailedStores starts the unsafe recovery to remove the permanently failed stores
+// which make some regions lose the majority of their peers.
+func (c *RaftCluster) RemoveFailedStores(storeIDs []uint64, timeout time.Duration) error {
+	return c.unsafeRecoveryController.removeFailedStores(storeIDs, timeout, time.Now())
+}
+
+// GetStoreRecoveryPlan returns the unsafe recovery plan of the store without changing
+// its state, it returns nil if the store has nothing to do.
+func (c *RaftCluster) GetStoreRecoveryPlan(storeID uint64) *StoreRecoveryPlan {
+	return c.unsafeRecoveryController.getStorePlan(storeID, false)
+}
+
+// DeliverStoreRecoveryPlan returns the unsafe recovery plan of the store and marks it
+// delivered, it's called by the store which is going to apply the plan. It returns nil
+// if the store has nothing to do.
+func (c *RaftCluster) DeliverStoreRecoveryPlan(storeID uint64) *StoreRecoveryPlan {
+	return c.unsafeRecoveryController.getStorePlan(storeID, true)
+}
+
+// GetUnsafeRecoveryStatus returns the status of the unsafe recovery. The progress is
+// checked by the background jobs of the cluster.
+func (c *RaftCluster) GetUnsafeRecoveryStatus() *UnsafeRecoveryStatus {
+	return c.unsafeRecoveryController.getStatus()
+}
+
+// CheckUnsafeRecoveryProgress checks the progress of the unsafe recovery immediately and
+// returns the status. The failed stores are removed if all the plans are applied.
+func (c *RaftCluster) CheckUnsafeRecoveryProgress() *UnsafeRecoveryStatus {
+	c.unsafeRecoveryController.checkProgress(time.Now())
+	return c.unsafeRecoveryController.getStatus()
+}
@@ -0,0 +1,181 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/pkg/mock/mockid"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/id"
+	"github.com/tikv/pd/server/kv"
+)
+
+var _ = Suite(&testUnsafeRecoverySuite{})
+
+type testUnsafeRecoverySuite struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (s *testUnsafeRecoverySuite) SetUpTest(c *C) {
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+}
+
+func (s *testUnsafeRecoverySuite) TearDownTest(c *C) {
+	s.cancel()
+}
+
+// newUnsafeRecoveryTestIDAllocator skips the IDs used by the test regions and peers.
+func newUnsafeRecoveryTestIDAllocator(c *C) id.Allocator {
+	alloc := mockid.NewIDAllocator()
+	for i := 0; i < 100; i++ {
+		_, err := alloc.Alloc()
+		c.Assert(err, IsNil)
+	}
+	return alloc
+}
+
+func newUnsafeRecoveryTestRegion(regionID uint64, startKey, endKey string, peers ...*metapb.Peer) *core.RegionInfo {
+	return core.NewRegionInfo(&metapb.Region{
+		Id:          regionID,
+		StartKey:    []byte(startKey),
+		EndKey:      []byte(endKey),
+		RegionEpoch: &metapb.RegionEpoch{ConfVer: 3, Version: 5},
+		Peers:       peers,
+	}, peers[0])
+}
+
+func (s *testUnsafeRecoverySuite) TestRemoveFailedStores(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := newTestRaftCluster(s.ctx, newUnsafeRecoveryTestIDAllocator(c), opt, core.NewStorage(kv.NewMemoryKV()), core.NewBasicCluster())
+	for _, store := range newTestStores(5, "5.0.0") {
+		c.Assert(cluster.PutStore(store.GetMeta()), IsNil)
+	}
+	regions := []*core.RegionInfo{
+		// Loses the quorum, store 3 is left.
+		newUnsafeRecoveryTestRegion(1, "", "b",
+			&metapb.Peer{Id: 11, StoreId: 1}, &metapb.Peer{Id: 12, StoreId: 2}, &metapb.Peer{Id: 13, StoreId: 3}),
+		// Loses all the voters, the learner on store 4 is promoted.
+		newUnsafeRecoveryTestRegion(2, "b", "c",
+			&metapb.Peer{Id: 21, StoreId: 1}, &metapb.Peer{Id: 22, StoreId: 2}, &metapb.Peer{Id: 24, StoreId: 4, Role: metapb.PeerRole_Learner}),
+		// Loses all the peers.
+		newUnsafeRecoveryTestRegion(3, "c", "d",
+			&metapb.Peer{Id: 31, StoreId: 1}, &metapb.Peer{Id: 32, StoreId: 2}),
+		// Keeps the quorum.
+		newUnsafeRecoveryTestRegion(4, "d", "",
+			&metapb.Peer{Id: 41, StoreId: 1}, &metapb.Peer{Id: 43, StoreId: 3}, &metapb.Peer{Id: 44, StoreId: 4}),
+	}
+	for _, region := range regions {
+		c.Assert(cluster.putRegion(region), IsNil)
+	}
+
+	c.Assert(cluster.RemoveFailedStores(nil, time.Minute), NotNil)
+	c.Assert(cluster.RemoveFailedStores([]uint64{1, 6}, time.Minute), NotNil)
+	// The store still sending heartbeats can't be removed.
+	cluster.core.PutStore(cluster.GetStore(2).Clone(core.SetLastHeartbeatTS(time.Now())))
+	c.Assert(cluster.RemoveFailedStores([]uint64{1, 2}, time.Minute), NotNil)
+	cluster.core.PutStore(cluster.GetStore(2).Clone(core.SetLastHeartbeatTS(time.Now().Add(-time.Minute))))
+	c.Assert(cluster.GetUnsafeRecoveryStatus().Stage, Equals, UnsafeRecoveryIdle)
+
+	c.Assert(cluster.RemoveFailedStores([]uint64{1, 2}, time.Minute), IsNil)
+	c.Assert(cluster.RemoveFailedStores([]uint64{1, 2}, time.Minute), NotNil)
+	status := cluster.GetUnsafeRecoveryStatus()
+	c.Assert(status.Stage, Equals, UnsafeRecoveryRecovering)
+	c.Assert(status.FailedStores, DeepEquals, []uint64{1, 2})
+	c.Assert(status.Plans, HasLen, 3)
+
+	c.Assert(cluster.DeliverStoreRecoveryPlan(1), IsNil)
+	// Getting the plan doesn't change its state.
+	plan := cluster.GetStoreRecoveryPlan(3)
+	c.Assert(plan.State, Equals, RecoveryPlanPending)
+	plan = cluster.DeliverStoreRecoveryPlan(3)
+	c.Assert(plan.State, Equals, RecoveryPlanDelivered)
+	c.Assert(cluster.GetStoreRecoveryPlan(3).State, Equals, RecoveryPlanDelivered)
+	c.Assert(plan.Creates, HasLen, 0)
+	c.Assert(plan.Updates, HasLen, 1)
+	c.Assert(plan.Updates[0].GetId(), Equals, uint64(1))
+	c.Assert(plan.Updates[0].GetRegionEpoch().GetConfVer(), Equals, uint64(4))
+	c.Assert(plan.Updates[0].GetPeers(), DeepEquals, []*metapb.Peer{{Id: 13, StoreId: 3}})
+
+	plan = cluster.GetStoreRecoveryPlan(4)
+	c.Assert(plan.Updates, HasLen, 1)
+	c.Assert(plan.Updates[0].GetPeers(), DeepEquals, []*metapb.Peer{{Id: 24, StoreId: 4, Role: metapb.PeerRole_Voter}})
+
+	// The empty region is placed on the store with the least regions.
+	plan = cluster.GetStoreRecoveryPlan(5)
+	c.Assert(plan.Updates, HasLen, 0)
+	c.Assert(plan.Creates, HasLen, 1)
+	created := plan.Creates[0]
+	c.Assert(created.GetStartKey(), DeepEquals, []byte("c"))
+	c.Assert(created.GetEndKey(), DeepEquals, []byte("d"))
+	c.Assert(created.GetRegionEpoch().GetVersion(), Equals, uint64(6))
+	c.Assert(created.GetPeers(), HasLen, 1)
+	c.Assert(created.GetPeers()[0].GetStoreId(), Equals, uint64(5))
+
+	// Apply the plans by region heartbeats.
+	for _, storeID := range []uint64{3, 4, 5} {
+		plan := status.Plans[storeID-3]
+		c.Assert(plan.StoreID, Equals, storeID)
+		for _, meta := range append(plan.Updates, plan.Creates...) {
+			c.Assert(cluster.processRegionHeartbeat(core.NewRegionInfo(meta, meta.GetPeers()[0])), IsNil)
+		}
+		// Getting the status doesn't check the progress.
+		c.Assert(cluster.GetUnsafeRecoveryStatus().Stage, Equals, UnsafeRecoveryRecovering)
+		status = cluster.CheckUnsafeRecoveryProgress()
+		if storeID != 5 {
+			c.Assert(status.Stage, Equals, UnsafeRecoveryRecovering)
+		}
+	}
+	c.Assert(status.Stage, Equals, UnsafeRecoveryFinished)
+	for _, plan := range status.Plans {
+		c.Assert(plan.State, Equals, RecoveryPlanApplied)
+	}
+	c.Assert(cluster.GetRegion(3), IsNil)
+	for _, storeID := range []uint64{1, 2} {
+		c.Assert(cluster.GetStore(storeID).IsOffline(), IsTrue)
+		c.Assert(cluster.GetStore(storeID).IsPhysicallyDestroyed(), IsTrue)
+	}
+	c.Assert(cluster.GetStoreRecoveryPlan(3), IsNil)
+}
+
+func (s *testUnsafeRecoverySuite) TestFillHolesAndTimeout(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := newTestRaftCluster(s.ctx, newUnsafeRecoveryTestIDAllocator(c), opt, core.NewStorage(kv.NewMemoryKV()), core.NewBasicCluster())
+	for _, store := range newTestStores(3, "5.0.0") {
+		c.Assert(cluster.PutStore(store.GetMeta()), IsNil)
+	}
+	c.Assert(cluster.putRegion(newUnsafeRecoveryTestRegion(1, "b", "c", &metapb.Peer{Id: 12, StoreId: 2})), IsNil)
+
+	// Store 3 is the only alive store to create the empty regions, it has no regions.
+	c.Assert(cluster.RemoveFailedStores([]uint64{1, 2}, 0), IsNil)
+	plan := cluster.GetStoreRecoveryPlan(3)
+	c.Assert(plan.Creates, HasLen, 1)
+	c.Assert(plan.Creates[0].GetStartKey(), HasLen, 0)
+	c.Assert(plan.Creates[0].GetEndKey(), HasLen, 0)
+
+	cluster.unsafeRecoveryController.checkProgress(time.Now().Add(time.Second))
+	status := cluster.GetUnsafeRecoveryStatus()
+	c.Assert(status.Stage, Equals, UnsafeRecoveryFailed)
+	c.Assert(status.Error, Not(Equals), "")
+	c.Assert(cluster.GetStoreRecoveryPlan(3), IsNil)
+
+	// All the stores are failed.
+	c.Assert(cluster.RemoveFailedStores([]uint64{1, 2, 3}, time.Minute), NotNil)
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/tikv/pd/pkg/metricutil"
 	"github.com/tikv/pd/pkg/typeutil"
 	"github.com/tikv/pd/server/core/storelimit"
+	"github.com/tikv/pd/server/kv"
 	"github.com/tikv/pd/server/versioninfo"
 
 	"github.com/BurntSushi/toml"
@@ -69,6 +70,13 @@ type Config struct {
 	InitialClusterState string `toml:"initial-cluster-state" json:"initial-cluster-state"`
 	InitialClusterToken string `toml:"initial-cluster-token" json:"initial-cluster-token"`
 
+	// Mode is the running mode of the server, it can be "pd" or "tso". In the "tso" mode,
+	// the server only provides the TSO service and uses the etcd of the PD cluster specified
+	// by BackendEndpoints to elect its leader and persist the timestamp window.
+	Mode string `toml:"mode" json:"mode"`
+	// BackendEndpoints is the client urls of the PD cluster, only used in the "tso" mode.
+	BackendEndpoints string `toml:"backend-endpoints" json:"backend-endpoints"`
+
 	// Join to an existing pd cluster, a string of endpoints.
 	Join string `toml:"join" json:"join"`
 
@@ -94,12 +102,30 @@ type Config struct {
 	// be automatically clamped to the range.
 	TSOUpdatePhysicalInterval typeutil.Duration `toml:"tso-update-physical-interval" json:"tso-update-physical-interval"`
 
+	// TSOClockLagPolicy decides what the TSO allocator does when the system clock lags behind
+	// the persisted TSO window more than TSOSaveInterval, which usually means the system clock
+	// is incorrect. It can be "slow-down", "continue" or "refuse", and the default is "slow-down".
+	TSOClockLagPolicy string `toml:"tso-clock-lag-policy" json:"tso-clock-lag-policy"`
+
 	// EnableLocalTSO is used to enable the Local TSO Allocator feature,
 	// which allows the PD server to generate Local TSO for certain DC-level transactions.
 	// To make this feature meaningful, user has to set the "zone" label for the PD server
 	// to indicate which DC this PD belongs to.
 	EnableLocalTSO bool `toml:"enable-local-tso" json:"enable-local-tso"`
 
+	// EnableTSOBatch is used to coalesce the concurrent TSO requests of the same dc-location
+	// into one allocation. The requests with low priority, such as the ones from GC, yield to
+	// the transactional requests when the batch is full. It is disabled by default.
+	EnableTSOBatch bool `toml:"enable-tso-batch" json:"enable-tso-batch"`
+
+	// EnableFollowerHandle allows this PD to serve the read-only region and store requests
+	// with the regions synced from the leader when it's a follower, only the requests which
+	// allow follower handle are served.
+	EnableFollowerHandle bool `toml:"enable-follower-handle" json:"enable-follower-handle"`
+	// MaxFollowerHandleLag is the max lag of the region sync for a follower to serve the
+	// requests, the requests are rejected and retried on the leader if it lags more.
+	MaxFollowerHandleLag typeutil.Duration `toml:"max-follower-handle-lag" json:"max-follower-handle-lag"`
+
 	Metric metricutil.MetricConfig `toml:"metric" json:"metric"`
 
 	Schedule ScheduleConfig `toml:"schedule" json:"schedule"`
@@ -185,6 +211,8 @@ func NewConfig() *Config {
 	fs.StringVar(&cfg.AdvertisePeerUrls, "advertise-peer-urls", "", "advertise url for peer traffic (default '${peer-urls}')")
 	fs.StringVar(&cfg.InitialCluster, "initial-cluster", "", "initial cluster configuration for bootstrapping, e,g. pd=http://127.0.0.1:2380")
 	fs.StringVar(&cfg.Join, "join", "", "join to an existing cluster (usage: cluster's '${advertise-client-urls}'")
+	fs.StringVar(&cfg.Mode, "mode", "", "running mode of the server: pd, tso (default 'pd')")
+	fs.StringVar(&cfg.BackendEndpoints, "backend-endpoints", "", "client urls of the PD cluster used by the tso mode, e.g. http://127.0.0.1:2379")
 
 	fs.StringVar(&cfg.Metric.PushAddress, "metrics-addr", "", "prometheus pushgateway address, leaves it empty will disable prometheus push")
 
@@ -233,6 +261,11 @@ const (
 	defaultMaxResetTSGap     = 24 * time.Hour
 	defaultKeyType           = "table"
 
+	defaultRegionConsistencyCheckInterval = time.Minute
+	defaultMaxRegionNoLeaderDuration      = 5 * time.Minute
+	defaultCapacityForecastHistory        = 7 * 24 * time.Hour
+	defaultRegionStorageVerifyInterval    = 24 * time.Hour
+
 	defaultStrictlyMatchLabel   = false
 	defaultEnablePlacementRules = true
 	defaultEnableGRPCGateway    = true
@@ -249,6 +282,28 @@ const (
 	DefaultTSOUpdatePhysicalInterval = 50 * time.Millisecond
 	maxTSOUpdatePhysicalInterval     = 10 * time.Second
 	minTSOUpdatePhysicalInterval     = 50 * time.Millisecond
+
+	defaultMaxFollowerHandleLag = 30 * time.Second
+)
+
+// Running modes of the server.
+const (
+	// ModePD runs a full PD server.
+	ModePD = "pd"
+	// ModeTSO runs a standalone TSO server.
+	ModeTSO = "tso"
+)
+
+// Policies of the config `TSOClockLagPolicy`.
+const (
+	// TSOClockLagPolicySlowDown only increases the physical time when the logical time is going
+	// to be used up, so the TSO goes slower than the system clock until the clock catches up.
+	TSOClockLagPolicySlowDown = "slow-down"
+	// TSOClockLagPolicyContinue increases the physical time as the real time elapses,
+	// so the TSO keeps its pace and the gap to the system clock remains.
+	TSOClockLagPolicyContinue = "continue"
+	// TSOClockLagPolicyRefuse refuses to allocate TSO until the system clock catches up.
+	TSOClockLagPolicyRefuse = "refuse"
 )
 
 // Special keys for Labels
@@ -546,6 +601,29 @@ func (c *Config) Adjust(meta *toml.MetaData, reloading bool) error {
 		c.TSOUpdatePhysicalInterval.Duration = minTSOUpdatePhysicalInterval
 	}
 
+	adjustDuration(&c.MaxFollowerHandleLag, defaultMaxFollowerHandleLag)
+
+	adjustString(&c.TSOClockLagPolicy, TSOClockLagPolicySlowDown)
+	switch c.TSOClockLagPolicy {
+	case TSOClockLagPolicySlowDown, TSOClockLagPolicyContinue, TSOClockLagPolicyRefuse:
+	default:
+		return errors.Errorf("unknown tso-clock-lag-policy %s", c.TSOClockLagPolicy)
+	}
+
+	adjustString(&c.Mode, ModePD)
+	switch c.Mode {
+	case ModePD:
+	case ModeTSO:
+		if c.BackendEndpoints == "" {
+			return errors.New("backend-endpoints should be provided in the tso mode")
+		}
+		if c.EnableLocalTSO {
+			return errors.New("local tso is not supported in the tso mode yet")
+		}
+	default:
+		return errors.Errorf("unknown mode %s", c.Mode)
+	}
+
 	if c.Labels == nil {
 		c.Labels = make(map[string]string)
 	}
@@ -1082,10 +1160,55 @@ type PDServerConfig struct {
 	TraceRegionFlow bool `toml:"trace-region-flow" json:"trace-region-flow,string,omitempty"`
 	// FlowRoundByDigit used to discretization processing flow information.
 	FlowRoundByDigit int `toml:"flow-round-by-digit" json:"flow-round-by-digit"`
+	// MaxServiceGCSafePointTTL is the max TTL of a service GC safepoint, the larger TTL requested
+	// by services will be limited to it. 0 means no limit.
+	MaxServiceGCSafePointTTL typeutil.Duration `toml:"max-service-gc-safepoint-ttl" json:"max-service-gc-safepoint-ttl"`
+	// MaxServiceGCSafePointLag is the max lag of a service GC safepoint behind the TSO, a service
+	// whose safepoint lags more than it is considered stale. 0 means no limit.
+	MaxServiceGCSafePointLag typeutil.Duration `toml:"max-service-gc-safepoint-lag" json:"max-service-gc-safepoint-lag"`
+	// ServiceGCSafePointPolicies overrides the max TTL and max lag for specific services.
+	ServiceGCSafePointPolicies []ServiceGCSafePointPolicy `toml:"service-gc-safepoint-policies" json:"service-gc-safepoint-policies"`
+	// RegionConsistencyCheckInterval is the interval to check the consistency of the regions,
+	// such as holes and overlaps in the key space.
+	RegionConsistencyCheckInterval typeutil.Duration `toml:"region-consistency-check-interval" json:"region-consistency-check-interval"`
+	// MaxRegionNoLeaderDuration is the max duration a region can be without leader before it's
+	// reported as an anomaly.
+	MaxRegionNoLeaderDuration typeutil.Duration `toml:"max-region-no-leader-duration" json:"max-region-no-leader-duration"`
+	// CapacityForecastHistory is how long the history of the store space is kept to fit the
+	// growth trend for the capacity forecast.
+	CapacityForecastHistory typeutil.Duration `toml:"capacity-forecast-history" json:"capacity-forecast-history"`
+	// RegionStorageVerifyInterval is the interval to verify the region storage against the
+	// regions in memory, repair the inconsistent records and compact it.
+	RegionStorageVerifyInterval typeutil.Duration `toml:"region-storage-verify-interval" json:"region-storage-verify-interval"`
+}
+
+// ServiceGCSafePointPolicy is the policy of the service GC safepoint for a specific service.
+type ServiceGCSafePointPolicy struct {
+	ServiceID string            `toml:"service-id" json:"service-id"`
+	MaxTTL    typeutil.Duration `toml:"max-ttl" json:"max-ttl"`
+	MaxLag    typeutil.Duration `toml:"max-lag" json:"max-lag"`
+}
+
+// GetServiceGCSafePointPolicy returns the policy of the service GC safepoint for the service.
+func (c *PDServerConfig) GetServiceGCSafePointPolicy(serviceID string) ServiceGCSafePointPolicy {
+	for _, policy := range c.ServiceGCSafePointPolicies {
+		if policy.ServiceID == serviceID {
+			return policy
+		}
+	}
+	return ServiceGCSafePointPolicy{
+		ServiceID: serviceID,
+		MaxTTL:    c.MaxServiceGCSafePointTTL,
+		MaxLag:    c.MaxServiceGCSafePointLag,
+	}
 }
 
 func (c *PDServerConfig) adjust(meta *configMetaData) error {
 	adjustDuration(&c.MaxResetTSGap, defaultMaxResetTSGap)
+	adjustDuration(&c.RegionConsistencyCheckInterval, defaultRegionConsistencyCheckInterval)
+	adjustDuration(&c.MaxRegionNoLeaderDuration, defaultMaxRegionNoLeaderDuration)
+	adjustDuration(&c.CapacityForecastHistory, defaultCapacityForecastHistory)
+	adjustDuration(&c.RegionStorageVerifyInterval, defaultRegionStorageVerifyInterval)
 	if !meta.IsDefined("use-region-storage") {
 		c.UseRegionStorage = defaultUseRegionStorage
 	}
@@ -1136,8 +1259,10 @@ func (c *PDServerConfig) MigrateDeprecatedFlags() {
 // Clone returns a cloned PD server config.
 func (c *PDServerConfig) Clone() *PDServerConfig {
 	runtimeServices := append(c.RuntimeServices[:0:0], c.RuntimeServices...)
+	policies := append(c.ServiceGCSafePointPolicies[:0:0], c.ServiceGCSafePointPolicies...)
 	cfg := *c
 	cfg.RuntimeServices = runtimeServices
+	cfg.ServiceGCSafePointPolicies = policies
 	return &cfg
 }
 
@@ -1154,6 +1279,28 @@ func (c *PDServerConfig) Validate() error {
 	if c.FlowRoundByDigit < 0 {
 		return errs.ErrConfigItem.GenWithStack("flow round by digit cannot be negative number")
 	}
+	if c.RegionConsistencyCheckInterval.Duration <= 0 {
+		return errs.ErrConfigItem.GenWithStack("region consistency check interval must be positive")
+	}
+	if c.RegionStorageVerifyInterval.Duration <= 0 {
+		return errs.ErrConfigItem.GenWithStack("region storage verify interval must be positive")
+	}
+	if c.MaxServiceGCSafePointTTL.Duration < 0 || c.MaxServiceGCSafePointLag.Duration < 0 {
+		return errs.ErrConfigItem.GenWithStack("max service gc safepoint ttl and lag cannot be negative")
+	}
+	serviceIDs := make(map[string]struct{}, len(c.ServiceGCSafePointPolicies))
+	for _, policy := range c.ServiceGCSafePointPolicies {
+		if policy.ServiceID == "" {
+			return errs.ErrConfigItem.GenWithStack("service id of service gc safepoint policy cannot be empty")
+		}
+		if _, ok := serviceIDs[policy.ServiceID]; ok {
+			return errs.ErrConfigItem.GenWithStack("duplicated service gc safepoint policy for %s", policy.ServiceID)
+		}
+		serviceIDs[policy.ServiceID] = struct{}{}
+		if policy.MaxTTL.Duration < 0 || policy.MaxLag.Duration < 0 {
+			return errs.ErrConfigItem.GenWithStack("max ttl and lag of service gc safepoint policy cannot be negative")
+		}
+	}
 
 	return nil
 }
@@ -1266,6 +1413,7 @@ func (c *Config) GenEmbedEtcdConfig() (*embed.Config, error) {
 	cfg.AutoCompactionMode = c.AutoCompactionMode
 	cfg.AutoCompactionRetention = c.AutoCompactionRetention
 	cfg.QuotaBackendBytes = int64(c.QuotaBackendBytes)
+	cfg.MaxTxnOps = kv.MaxTxnOps
 
 	allowedCN, serr := c.Security.GetOneAllowedCN()
 	if serr != nil {
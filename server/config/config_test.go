@@ -157,6 +157,24 @@ func (s *testConfigSuite) TestValidation(c *C) {
 	c.Assert(cfg.Schedule.Validate(), NotNil)
 	// check quota
 	c.Assert(cfg.QuotaBackendBytes, Equals, defaultQuotaBackendBytes)
+
+	// check tso clock lag policy
+	cfg = NewConfig()
+	cfg.TSOClockLagPolicy = "unknown"
+	c.Assert(cfg.Adjust(nil, false), NotNil)
+	cfg.TSOClockLagPolicy = TSOClockLagPolicyRefuse
+	c.Assert(cfg.Adjust(nil, false), IsNil)
+
+	// check mode
+	cfg = NewConfig()
+	cfg.Mode = "unknown"
+	c.Assert(cfg.Adjust(nil, false), NotNil)
+	cfg.Mode = ModeTSO
+	c.Assert(cfg.Adjust(nil, false), NotNil)
+	cfg.BackendEndpoints = "http://127.0.0.1:2379"
+	c.Assert(cfg.Adjust(nil, false), IsNil)
+	cfg.EnableLocalTSO = true
+	c.Assert(cfg.Adjust(nil, false), NotNil)
 }
 
 func (s *testConfigSuite) TestAdjust(c *C) {
@@ -193,6 +211,8 @@ leader-schedule-limit = 0
 	c.Assert(cfg.PDServerCfg.MetricStorage, Equals, "http://127.0.0.1:9090")
 
 	c.Assert(cfg.TSOUpdatePhysicalInterval.Duration, Equals, DefaultTSOUpdatePhysicalInterval)
+	c.Assert(cfg.TSOClockLagPolicy, Equals, TSOClockLagPolicySlowDown)
+	c.Assert(cfg.Mode, Equals, ModePD)
 
 	// Check undefined config fields
 	cfgData = `
@@ -386,6 +406,69 @@ dashboard-address = "foo"
 	}
 }
 
+func (s *testConfigSuite) TestServiceGCSafePointPolicy(c *C) {
+	cfgData := `
+[pd-server]
+max-service-gc-safepoint-ttl = "24h"
+max-service-gc-safepoint-lag = "48h"
+[[pd-server.service-gc-safepoint-policies]]
+service-id = "br"
+max-ttl = "72h"
+max-lag = "0s"
+`
+	cfg := NewConfig()
+	meta, err := toml.Decode(cfgData, &cfg)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Adjust(&meta, false), IsNil)
+	policy := cfg.PDServerCfg.GetServiceGCSafePointPolicy("br")
+	c.Assert(policy.MaxTTL.Duration, Equals, 72*time.Hour)
+	c.Assert(policy.MaxLag.Duration, Equals, time.Duration(0))
+	policy = cfg.PDServerCfg.GetServiceGCSafePointPolicy("cdc")
+	c.Assert(policy.MaxTTL.Duration, Equals, 24*time.Hour)
+	c.Assert(policy.MaxLag.Duration, Equals, 48*time.Hour)
+
+	// The cloned policies should not be affected.
+	cloned := cfg.PDServerCfg.Clone()
+	cloned.ServiceGCSafePointPolicies[0].MaxLag.Duration = time.Hour
+	c.Assert(cfg.PDServerCfg.ServiceGCSafePointPolicies[0].MaxLag.Duration, Equals, time.Duration(0))
+
+	cfg.PDServerCfg.ServiceGCSafePointPolicies = append(cfg.PDServerCfg.ServiceGCSafePointPolicies, ServiceGCSafePointPolicy{ServiceID: "br"})
+	c.Assert(cfg.PDServerCfg.Validate(), NotNil)
+	cfg.PDServerCfg.ServiceGCSafePointPolicies = []ServiceGCSafePointPolicy{{ServiceID: ""}}
+	c.Assert(cfg.PDServerCfg.Validate(), NotNil)
+	cfg.PDServerCfg.ServiceGCSafePointPolicies = nil
+	cfg.PDServerCfg.MaxServiceGCSafePointTTL.Duration = -time.Hour
+	c.Assert(cfg.PDServerCfg.Validate(), NotNil)
+}
+
+func (s *testConfigSuite) TestPDServerIntervals(c *C) {
+	cfg := NewConfig()
+	c.Assert(cfg.Adjust(nil, false), IsNil)
+	c.Assert(cfg.PDServerCfg.RegionConsistencyCheckInterval.Duration, Equals, defaultRegionConsistencyCheckInterval)
+	c.Assert(cfg.PDServerCfg.RegionStorageVerifyInterval.Duration, Equals, defaultRegionStorageVerifyInterval)
+	for _, interval := range []time.Duration{0, -time.Second} {
+		pdServerCfg := cfg.PDServerCfg.Clone()
+		pdServerCfg.RegionConsistencyCheckInterval.Duration = interval
+		c.Assert(pdServerCfg.Validate(), NotNil)
+		pdServerCfg = cfg.PDServerCfg.Clone()
+		pdServerCfg.RegionStorageVerifyInterval.Duration = interval
+		c.Assert(pdServerCfg.Validate(), NotNil)
+	}
+
+	cfgData := `
+[pd-server]
+region-consistency-check-interval = "-1m"
+region-storage-verify-interval = "-1h"
+`
+	// The non-positive intervals in the config file are replaced with the defaults.
+	cfg = NewConfig()
+	meta, err := toml.Decode(cfgData, &cfg)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Adjust(&meta, false), IsNil)
+	c.Assert(cfg.PDServerCfg.RegionConsistencyCheckInterval.Duration, Equals, defaultRegionConsistencyCheckInterval)
+	c.Assert(cfg.PDServerCfg.RegionStorageVerifyInterval.Duration, Equals, defaultRegionStorageVerifyInterval)
+}
+
 func (s *testConfigSuite) TestDashboardConfig(c *C) {
 	cfgData := `
 [dashboard]
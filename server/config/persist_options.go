@@ -564,9 +564,8 @@ func (o *PersistOptions) DeleteLabelProperty(typ, labelKey, labelValue string) {
 	o.labelProperty.Store(cfg)
 }
 
-// Persist saves the configuration to the storage.
-func (o *PersistOptions) Persist(storage *core.Storage) error {
-	cfg := &Config{
+func (o *PersistOptions) persistedConfig() *Config {
+	return &Config{
 		Schedule:        *o.GetScheduleConfig(),
 		Replication:     *o.GetReplicationConfig(),
 		PDServerCfg:     *o.GetPDServerConfig(),
@@ -574,7 +573,21 @@ func (o *PersistOptions) Persist(storage *core.Storage) error {
 		LabelProperty:   o.GetLabelPropertyConfig(),
 		ClusterVersion:  *o.GetClusterVersion(),
 	}
-	err := storage.SaveConfig(cfg)
+}
+
+// Persist saves the configuration to the storage.
+func (o *PersistOptions) Persist(storage *core.Storage) error {
+	err := storage.SaveConfig(o.persistedConfig())
+	failpoint.Inject("persistFail", func() {
+		err = errors.New("fail to persist")
+	})
+	return err
+}
+
+// PersistWithoutScheduler saves the configuration and removes the config of the
+// scheduler from the storage atomically.
+func (o *PersistOptions) PersistWithoutScheduler(storage *core.Storage, name string) error {
+	err := storage.SaveConfigAndRemoveScheduleConfig(o.persistedConfig(), name)
 	failpoint.Inject("persistFail", func() {
 		err = errors.New("fail to persist")
 	})
@@ -16,15 +16,18 @@ package core
 import (
 	"context"
 	"math"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/log"
+	"github.com/syndtr/goleveldb/leveldb/util"
 	"github.com/tikv/pd/pkg/encryption"
 	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/server/encryptionkm"
 	"github.com/tikv/pd/server/kv"
+	"go.uber.org/zap"
 )
 
 var dirtyFlushTick = time.Second
@@ -126,6 +129,18 @@ func (s *RegionStorage) SaveRegion(region *metapb.Region) error {
 	return nil
 }
 
+// DeleteRegion deletes one region from storage, the unflushed save of the
+// region is dropped, otherwise the region is written back by the next flush.
+func (s *RegionStorage) DeleteRegion(region *metapb.Region) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.batchRegions[regionPath(region.GetId())]; ok {
+		delete(s.batchRegions, regionPath(region.GetId()))
+		s.cacheSize--
+	}
+	return deleteRegion(s.LeveldbKV, region)
+}
+
 func deleteRegion(kv kv.Base, region *metapb.Region) error {
 	return kv.Remove(regionPath(region.GetId()))
 }
@@ -205,3 +220,161 @@ func (s *RegionStorage) Close() error {
 	}
 	return nil
 }
+
+// maxRegionStorageReportIDs limits the region IDs listed in a report for each
+// kind of inconsistency, the counts are not limited.
+const maxRegionStorageReportIDs = 1000
+
+// RegionStorageReport is the result of verifying the region storage against the
+// regions in memory.
+type RegionStorageReport struct {
+	StartTime time.Time     `json:"start_time"`
+	Duration  time.Duration `json:"duration"`
+	// StoredCount is the number of regions in the region storage before repair.
+	StoredCount int `json:"stored_count"`
+	RegionCount int `json:"region_count"`
+	// Orphaned regions are in the region storage but not in memory, they are left
+	// by the merged or removed regions.
+	OrphanedCount int      `json:"orphaned_count"`
+	Orphaned      []uint64 `json:"orphaned"`
+	// Stale regions have a different epoch from the one in memory.
+	StaleCount int      `json:"stale_count"`
+	Stale      []uint64 `json:"stale"`
+	// Missing regions are in memory but not in the region storage.
+	MissingCount int      `json:"missing_count"`
+	Missing      []uint64 `json:"missing"`
+	Repaired     bool     `json:"repaired"`
+	Compacted    bool     `json:"compacted"`
+}
+
+func appendReportID(ids []uint64, count *int, id uint64) []uint64 {
+	*count++
+	if *count > maxRegionStorageReportIDs {
+		return ids
+	}
+	return append(ids, id)
+}
+
+// Verify scans the region storage and compares it with the regions in the basic
+// cluster, which must contain all the regions. If repair is true, the orphaned
+// regions are removed, the stale and missing regions are saved with the ones in
+// memory, and then the storage is compacted.
+func (s *RegionStorage) Verify(cluster *BasicCluster, repair bool) (*RegionStorageReport, error) {
+	report := &RegionStorageReport{
+		StartTime: time.Now(),
+		Orphaned:  []uint64{},
+		Stale:     []uint64{},
+		Missing:   []uint64{},
+	}
+	if err := s.FlushRegion(); err != nil {
+		return nil, err
+	}
+	stored, err := s.loadRegionEpochs()
+	if err != nil {
+		return nil, err
+	}
+	report.StoredCount = len(stored)
+
+	// The regions are got after the storage is scanned, and the regions are always
+	// updated in memory before saved, so a region in the storage must be in memory
+	// unless it has been removed.
+	var orphaned, dirty []uint64
+	regions := cluster.GetRegions()
+	report.RegionCount = len(regions)
+	for _, region := range regions {
+		epoch, ok := stored[region.GetID()]
+		if !ok {
+			report.Missing = appendReportID(report.Missing, &report.MissingCount, region.GetID())
+			dirty = append(dirty, region.GetID())
+			continue
+		}
+		delete(stored, region.GetID())
+		if epoch.GetVersion() != region.GetRegionEpoch().GetVersion() || epoch.GetConfVer() != region.GetRegionEpoch().GetConfVer() {
+			report.Stale = appendReportID(report.Stale, &report.StaleCount, region.GetID())
+			dirty = append(dirty, region.GetID())
+		}
+	}
+	for id := range stored {
+		orphaned = append(orphaned, id)
+	}
+	sort.Slice(orphaned, func(i, j int) bool { return orphaned[i] < orphaned[j] })
+	for _, id := range orphaned {
+		report.Orphaned = appendReportID(report.Orphaned, &report.OrphanedCount, id)
+	}
+
+	if repair {
+		if err := s.repair(cluster, orphaned, dirty); err != nil {
+			return nil, err
+		}
+		report.Repaired = true
+		if err := s.CompactRange(util.Range{}); err != nil {
+			return nil, errs.ErrLevelDBCompact.Wrap(err).GenWithStackByCause()
+		}
+		report.Compacted = true
+	}
+	report.Duration = time.Since(report.StartTime)
+	return report, nil
+}
+
+// loadRegionEpochs loads the epochs of all the regions in the region storage.
+func (s *RegionStorage) loadRegionEpochs() (map[uint64]*metapb.RegionEpoch, error) {
+	epochs := make(map[uint64]*metapb.RegionEpoch)
+	nextID := uint64(0)
+	endKey := regionPath(math.MaxUint64)
+	for {
+		_, res, err := s.LoadRange(regionPath(nextID), endKey, maxKVRangeLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range res {
+			region := &metapb.Region{}
+			if err := region.Unmarshal([]byte(value)); err != nil {
+				return nil, errs.ErrProtoUnmarshal.Wrap(err).GenWithStackByArgs()
+			}
+			epochs[region.GetId()] = region.GetRegionEpoch()
+			nextID = region.GetId() + 1
+		}
+		if len(res) < maxKVRangeLimit {
+			return epochs, nil
+		}
+	}
+}
+
+// repair writes the regions in one batch. It holds the lock to block the saves,
+// and the regions are got from memory again, so the newer saves are not
+// overwritten by the repair.
+func (s *RegionStorage) repair(cluster *BasicCluster, orphaned, dirty []uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.flush(); err != nil {
+		return err
+	}
+	txn := s.NewTxn()
+	for _, id := range orphaned {
+		if cluster.GetRegion(id) == nil {
+			txn.Remove(regionPath(id))
+		}
+	}
+	for _, id := range dirty {
+		region := cluster.GetRegion(id)
+		if region == nil {
+			continue
+		}
+		meta, err := encryption.EncryptRegion(region.GetMeta(), s.encryptionKeyManager)
+		if err != nil {
+			return err
+		}
+		value, err := meta.Marshal()
+		if err != nil {
+			return errs.ErrProtoMarshal.Wrap(err).GenWithStackByCause()
+		}
+		txn.Save(regionPath(id), string(value))
+	}
+	if err := txn.Commit(); err != nil {
+		return err
+	}
+	log.Info("region storage is repaired",
+		zap.Int("orphaned", len(orphaned)),
+		zap.Int("dirty", len(dirty)))
+	return nil
+}
@@ -49,6 +49,9 @@ const (
 	gcWorkerServiceSafePointID = "gc_worker"
 )
 
+// maxServiceSafePointHistory is the max number of distinct safepoints kept in the history of a service.
+const maxServiceSafePointHistory = 16
+
 const (
 	maxKVRangeLimit = 10000
 	minKVRangeLimit = 100
@@ -184,6 +187,16 @@ func (s *Storage) DeleteStore(store *metapb.Store) error {
 	return s.Remove(s.storePath(store.GetId()))
 }
 
+// DeleteStoreWithWeight deletes one store and its leader and region weight from
+// storage in one transaction.
+func (s *Storage) DeleteStoreWithWeight(store *metapb.Store) error {
+	txn := s.NewTxn()
+	txn.Remove(s.storePath(store.GetId()))
+	txn.Remove(s.storeLeaderWeightPath(store.GetId()))
+	txn.Remove(s.storeRegionWeightPath(store.GetId()))
+	return txn.Commit()
+}
+
 // LoadRegion loads one region from storage.
 func (s *Storage) LoadRegion(regionID uint64, region *metapb.Region) (ok bool, err error) {
 	if atomic.LoadInt32(&s.useRegionStorage) > 0 {
@@ -227,7 +240,7 @@ func (s *Storage) SaveRegion(region *metapb.Region) error {
 // DeleteRegion deletes one region from storage.
 func (s *Storage) DeleteRegion(region *metapb.Region) error {
 	if atomic.LoadInt32(&s.useRegionStorage) > 0 {
-		return deleteRegion(s.regionStorage, region)
+		return s.regionStorage.DeleteRegion(region)
 	}
 	return deleteRegion(s.Base, region)
 }
@@ -241,6 +254,19 @@ func (s *Storage) SaveConfig(cfg interface{}) error {
 	return s.Save(configPath, string(value))
 }
 
+// SaveConfigAndRemoveScheduleConfig stores cfg to the configPath and removes the
+// config of the scheduler in one transaction.
+func (s *Storage) SaveConfigAndRemoveScheduleConfig(cfg interface{}, scheduleName string) error {
+	value, err := json.Marshal(cfg)
+	if err != nil {
+		return errs.ErrJSONMarshal.Wrap(err).GenWithStackByCause()
+	}
+	txn := s.NewTxn()
+	txn.Save(configPath, string(value))
+	txn.Remove(path.Join(customScheduleConfigPath, scheduleName))
+	return txn.Commit()
+}
+
 // LoadConfig loads config from configPath then unmarshal it to cfg.
 func (s *Storage) LoadConfig(cfg interface{}) (bool, error) {
 	value, err := s.Load(configPath)
@@ -267,6 +293,39 @@ func (s *Storage) DeleteRule(ruleKey string) error {
 	return s.Remove(path.Join(rulesPath, ruleKey))
 }
 
+// SaveRulesAndGroups saves the placement rules and rule groups in one transaction.
+// The maps are keyed by the rule key and the group ID, and a nil value deletes the
+// rule or the rule group. If there are more than kv.MaxTxnOps writes, they are
+// split into several transactions of at most kv.MaxTxnOps writes, which are not
+// atomic as a whole, so a failure may leave a part of the writes persisted.
+func (s *Storage) SaveRulesAndGroups(rules, groups map[string]interface{}) error {
+	txn, ops := s.NewTxn(), 0
+	for _, batch := range []struct {
+		prefix string
+		items  map[string]interface{}
+	}{{rulesPath, rules}, {ruleGroupPath, groups}} {
+		for key, data := range batch.items {
+			if ops == kv.MaxTxnOps {
+				if err := txn.Commit(); err != nil {
+					return err
+				}
+				txn, ops = s.NewTxn(), 0
+			}
+			ops++
+			if data == nil {
+				txn.Remove(path.Join(batch.prefix, key))
+				continue
+			}
+			value, err := json.Marshal(data)
+			if err != nil {
+				return errs.ErrJSONMarshal.Wrap(err).GenWithStackByCause()
+			}
+			txn.Save(path.Join(batch.prefix, key), string(value))
+		}
+	}
+	return txn.Commit()
+}
+
 // LoadRules loads placement rules from storage.
 func (s *Storage) LoadRules(f func(k, v string)) error {
 	return s.LoadRangeByPrefix(rulesPath+"/", f)
@@ -340,6 +399,26 @@ func (s *Storage) LoadReplicationStatus(mode string, status interface{}) (bool,
 	return true, nil
 }
 
+func replicationHistoryPath(mode string) string {
+	return path.Join(replicationPath, "history", mode) + "/"
+}
+
+// SaveReplicationStateTransition stores a state transition of the replication mode.
+func (s *Storage) SaveReplicationStateTransition(mode string, stateID uint64, transition interface{}) error {
+	return s.SaveJSON(replicationHistoryPath(mode), fmt.Sprintf("%020d", stateID), transition)
+}
+
+// RemoveReplicationStateTransition removes a state transition of the replication mode.
+func (s *Storage) RemoveReplicationStateTransition(mode string, stateID uint64) error {
+	return s.Remove(path.Join(replicationHistoryPath(mode), fmt.Sprintf("%020d", stateID)))
+}
+
+// LoadReplicationStateTransitions loads all state transitions of the replication mode
+// in the order of state ID.
+func (s *Storage) LoadReplicationStateTransitions(mode string, f func(k, v string)) error {
+	return s.LoadRangeByPrefix(replicationHistoryPath(mode), f)
+}
+
 // SaveComponent stores marshallable components to the componentPath.
 func (s *Storage) SaveComponent(component interface{}) error {
 	value, err := json.Marshal(component)
@@ -401,12 +480,10 @@ func (s *Storage) LoadStores(f func(store *StoreInfo)) error {
 
 // SaveStoreWeight saves a store's leader and region weight to storage.
 func (s *Storage) SaveStoreWeight(storeID uint64, leader, region float64) error {
-	leaderValue := strconv.FormatFloat(leader, 'f', -1, 64)
-	if err := s.Save(s.storeLeaderWeightPath(storeID), leaderValue); err != nil {
-		return err
-	}
-	regionValue := strconv.FormatFloat(region, 'f', -1, 64)
-	return s.Save(s.storeRegionWeightPath(storeID), regionValue)
+	txn := s.NewTxn()
+	txn.Save(s.storeLeaderWeightPath(storeID), strconv.FormatFloat(leader, 'f', -1, 64))
+	txn.Save(s.storeRegionWeightPath(storeID), strconv.FormatFloat(region, 'f', -1, 64))
+	return txn.Commit()
 }
 
 func (s *Storage) loadFloatWithDefaultValue(path string, def float64) (float64, error) {
@@ -499,7 +576,10 @@ func (s *Storage) RemoveServiceGCSafePoint(serviceID string) error {
 		return errors.New("cannot remove service safe point of gc_worker")
 	}
 	key := path.Join(gcPath, "safe_point", "service", serviceID)
-	return s.Remove(key)
+	if err := s.Remove(key); err != nil {
+		return err
+	}
+	return s.Remove(serviceSafePointHistoryPath(serviceID))
 }
 
 func (s *Storage) initServiceGCSafePointForGCWorker(initialValue uint64) (*ServiceSafePoint, error) {
@@ -550,6 +630,7 @@ func (s *Storage) LoadMinServiceGCSafePoint(now time.Time) (*ServiceSafePoint, e
 
 		if ssp.ExpiredAt < now.Unix() {
 			s.Remove(key)
+			s.Remove(serviceSafePointHistoryPath(ssp.ServiceID))
 			continue
 		}
 		if ssp.SafePoint < min.SafePoint {
@@ -596,6 +677,64 @@ func (s *Storage) GetAllServiceGCSafePoints() ([]*ServiceSafePoint, error) {
 	return ssps, nil
 }
 
+// ServiceSafePointUpdate is a record in the update history of a service safepoint. The consecutive
+// updates with the same safepoint are merged into one record.
+type ServiceSafePointUpdate struct {
+	SafePoint uint64 `json:"safe_point"`
+	ExpiredAt int64  `json:"expired_at"`
+	// FirstUpdatedAt is the first time the safepoint is updated to this value.
+	FirstUpdatedAt int64 `json:"first_updated_at"`
+	// LastUpdatedAt is the last time the safepoint is updated to this value.
+	LastUpdatedAt int64 `json:"last_updated_at"`
+}
+
+func serviceSafePointHistoryPath(serviceID string) string {
+	return path.Join(gcPath, "safe_point", "history", serviceID)
+}
+
+// MergeServiceSafePointUpdate merges the update of a service safepoint into its history and returns
+// the new history, appended is true if the safepoint is different from the latest one. Only the latest
+// maxServiceSafePointHistory distinct safepoints are kept.
+func MergeServiceSafePointUpdate(history []*ServiceSafePointUpdate, ssp *ServiceSafePoint, updatedAt time.Time) (newHistory []*ServiceSafePointUpdate, appended bool) {
+	if n := len(history); n > 0 && history[n-1].SafePoint == ssp.SafePoint {
+		history[n-1].ExpiredAt = ssp.ExpiredAt
+		history[n-1].LastUpdatedAt = updatedAt.Unix()
+		return history, false
+	}
+	history = append(history, &ServiceSafePointUpdate{
+		SafePoint:      ssp.SafePoint,
+		ExpiredAt:      ssp.ExpiredAt,
+		FirstUpdatedAt: updatedAt.Unix(),
+		LastUpdatedAt:  updatedAt.Unix(),
+	})
+	if len(history) > maxServiceSafePointHistory {
+		history = history[len(history)-maxServiceSafePointHistory:]
+	}
+	return history, true
+}
+
+// SaveServiceGCSafePointHistory saves the update history of a service safepoint.
+func (s *Storage) SaveServiceGCSafePointHistory(serviceID string, history []*ServiceSafePointUpdate) error {
+	value, err := json.Marshal(history)
+	if err != nil {
+		return errs.ErrJSONMarshal.Wrap(err).GenWithStackByCause()
+	}
+	return s.Save(serviceSafePointHistoryPath(serviceID), string(value))
+}
+
+// LoadServiceGCSafePointHistory loads the update history of a service safepoint, from the oldest to the latest.
+func (s *Storage) LoadServiceGCSafePointHistory(serviceID string) ([]*ServiceSafePointUpdate, error) {
+	value, err := s.Load(serviceSafePointHistoryPath(serviceID))
+	if err != nil || value == "" {
+		return nil, err
+	}
+	var history []*ServiceSafePointUpdate
+	if err := json.Unmarshal([]byte(value), &history); err != nil {
+		return nil, errs.ErrJSONUnmarshal.Wrap(err).GenWithStackByCause()
+	}
+	return history, nil
+}
+
 // LoadAllScheduleConfig loads all schedulers' config.
 func (s *Storage) LoadAllScheduleConfig() ([]string, []string, error) {
 	prefix := customScheduleConfigPath + "/"
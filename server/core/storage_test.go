@@ -14,9 +14,11 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
+	"os"
 	"path"
 	"strings"
 	"time"
@@ -123,6 +125,59 @@ func (s *testKVSuite) TestStoreWeight(c *C) {
 	}
 }
 
+func (s *testKVSuite) TestDeleteStoreWithWeight(c *C) {
+	storage := NewStorage(kv.NewMemoryKV())
+	stores := mustSaveStores(c, storage, 3)
+	c.Assert(storage.SaveStoreWeight(1, 2.0, 3.0), IsNil)
+	c.Assert(storage.DeleteStoreWithWeight(stores[1]), IsNil)
+
+	ok, err := storage.LoadStore(1, &metapb.Store{})
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsFalse)
+	v, err := storage.Load(storage.storeLeaderWeightPath(1))
+	c.Assert(err, IsNil)
+	c.Assert(v, Equals, "")
+	v, err = storage.Load(storage.storeRegionWeightPath(1))
+	c.Assert(err, IsNil)
+	c.Assert(v, Equals, "")
+	ok, err = storage.LoadStore(2, &metapb.Store{})
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+}
+
+func (s *testKVSuite) TestSaveRulesAndGroups(c *C) {
+	storage := NewStorage(kv.NewMemoryKV())
+	c.Assert(storage.SaveRule("pd-1", map[string]string{"id": "1"}), IsNil)
+	c.Assert(storage.SaveRuleGroup("g1", map[string]string{"id": "g1"}), IsNil)
+	rules := map[string]interface{}{"pd-1": nil, "pd-2": map[string]string{"id": "2"}}
+	groups := map[string]interface{}{"g1": nil, "g2": map[string]string{"id": "g2"}}
+	c.Assert(storage.SaveRulesAndGroups(rules, groups), IsNil)
+
+	var keys, values []string
+	c.Assert(storage.LoadRules(func(k, v string) {
+		keys, values = append(keys, k), append(values, v)
+	}), IsNil)
+	c.Assert(keys, DeepEquals, []string{"pd-2"})
+	c.Assert(values, DeepEquals, []string{`{"id":"2"}`})
+	keys = keys[:0]
+	c.Assert(storage.LoadRuleGroups(func(k, v string) { keys = append(keys, k) }), IsNil)
+	c.Assert(keys, DeepEquals, []string{"g2"})
+}
+
+func (s *testKVSuite) TestSaveConfigAndRemoveScheduleConfig(c *C) {
+	storage := NewStorage(kv.NewMemoryKV())
+	c.Assert(storage.SaveScheduleConfig("scheduler", []byte("data")), IsNil)
+	c.Assert(storage.SaveConfigAndRemoveScheduleConfig(map[string]int{"a": 1}, "scheduler"), IsNil)
+	data, err := storage.LoadScheduleConfig("scheduler")
+	c.Assert(err, IsNil)
+	c.Assert(data, Equals, "")
+	cfg := make(map[string]int)
+	ok, err := storage.LoadConfig(&cfg)
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+	c.Assert(cfg["a"], Equals, 1)
+}
+
 func mustSaveRegions(c *C, s *Storage, n int) []*metapb.Region {
 	regions := make([]*metapb.Region, 0, n)
 	for i := 0; i < n; i++ {
@@ -265,6 +320,43 @@ func (s *testKVSuite) TestLoadMinServiceGCSafePoint(c *C) {
 	c.Assert(ssp.SafePoint, Equals, uint64(2))
 }
 
+func (s *testKVSuite) TestServiceGCSafePointHistory(c *C) {
+	storage := NewStorage(kv.NewMemoryKV())
+	now := time.Now()
+	ssp := &ServiceSafePoint{ServiceID: "1", ExpiredAt: now.Unix() + 100, SafePoint: 1}
+	c.Assert(storage.SaveServiceGCSafePoint(ssp), IsNil)
+	history, appended := MergeServiceSafePointUpdate(nil, ssp, now)
+	c.Assert(appended, IsTrue)
+	// The updates with the same safepoint are merged.
+	ssp.ExpiredAt = now.Unix() + 200
+	history, appended = MergeServiceSafePointUpdate(history, ssp, now.Add(100*time.Second))
+	c.Assert(appended, IsFalse)
+	c.Assert(storage.SaveServiceGCSafePointHistory("1", history), IsNil)
+	history, err := storage.LoadServiceGCSafePointHistory("1")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 1)
+	c.Assert(history[0].FirstUpdatedAt, Equals, now.Unix())
+	c.Assert(history[0].LastUpdatedAt, Equals, now.Unix()+100)
+	c.Assert(history[0].ExpiredAt, Equals, now.Unix()+200)
+
+	for i := 2; i < maxServiceSafePointHistory+5; i++ {
+		ssp.SafePoint = uint64(i)
+		history, appended = MergeServiceSafePointUpdate(history, ssp, now.Add(time.Duration(i)*time.Second))
+		c.Assert(appended, IsTrue)
+	}
+	c.Assert(storage.SaveServiceGCSafePointHistory("1", history), IsNil)
+	history, err = storage.LoadServiceGCSafePointHistory("1")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, maxServiceSafePointHistory)
+	c.Assert(history[len(history)-1].SafePoint, Equals, uint64(maxServiceSafePointHistory+4))
+
+	// The history is removed with the service safepoint.
+	c.Assert(storage.RemoveServiceGCSafePoint("1"), IsNil)
+	history, err = storage.LoadServiceGCSafePointHistory("1")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 0)
+}
+
 type KVWithMaxRangeLimit struct {
 	kv.Base
 	rangeLimit int
@@ -284,3 +376,60 @@ func newTestRegionMeta(regionID uint64) *metapb.Region {
 		EndKey:   []byte(fmt.Sprintf("%20d", regionID+1)),
 	}
 }
+
+func (s *testKVSuite) TestRegionStorageVerify(c *C) {
+	dir, err := os.MkdirTemp("/tmp", "region_storage")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+	regionStorage, err := NewRegionStorage(context.Background(), dir, nil)
+	c.Assert(err, IsNil)
+	defer regionStorage.Close()
+
+	cluster := NewBasicCluster()
+	for i := uint64(1); i <= 4; i++ {
+		region := newTestRegionMeta(i)
+		region.RegionEpoch = &metapb.RegionEpoch{ConfVer: 1, Version: 1}
+		if i != 4 {
+			c.Assert(regionStorage.SaveRegion(region), IsNil)
+		}
+		if i == 3 {
+			region = newTestRegionMeta(i)
+			region.RegionEpoch = &metapb.RegionEpoch{ConfVer: 1, Version: 2}
+		}
+		cluster.PutRegion(NewRegionInfo(region, nil))
+	}
+	// Region 5 has been removed, but it's written back by the flush.
+	c.Assert(regionStorage.SaveRegion(newTestRegionMeta(5)), IsNil)
+
+	report, err := regionStorage.Verify(cluster, false)
+	c.Assert(err, IsNil)
+	c.Assert(report.StoredCount, Equals, 4)
+	c.Assert(report.RegionCount, Equals, 4)
+	c.Assert(report.Orphaned, DeepEquals, []uint64{5})
+	c.Assert(report.Stale, DeepEquals, []uint64{3})
+	c.Assert(report.Missing, DeepEquals, []uint64{4})
+	c.Assert(report.Repaired, IsFalse)
+
+	report, err = regionStorage.Verify(cluster, true)
+	c.Assert(err, IsNil)
+	c.Assert(report.OrphanedCount+report.StaleCount+report.MissingCount, Equals, 3)
+	c.Assert(report.Repaired, IsTrue)
+	c.Assert(report.Compacted, IsTrue)
+	report, err = regionStorage.Verify(cluster, false)
+	c.Assert(err, IsNil)
+	c.Assert(report.StoredCount, Equals, 4)
+	c.Assert(report.OrphanedCount+report.StaleCount+report.MissingCount, Equals, 0)
+	region := &metapb.Region{}
+	ok, err := loadRegion(regionStorage, nil, 3, region)
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+	c.Assert(region.GetRegionEpoch().GetVersion(), Equals, uint64(2))
+
+	// The unflushed save is dropped when the region is deleted.
+	c.Assert(regionStorage.SaveRegion(newTestRegionMeta(6)), IsNil)
+	c.Assert(regionStorage.DeleteRegion(newTestRegionMeta(6)), IsNil)
+	c.Assert(regionStorage.FlushRegion(), IsNil)
+	ok, err = loadRegion(regionStorage, nil, 6, region)
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsFalse)
+}
@@ -0,0 +1,178 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnose
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tikv/pd/pkg/errs"
+)
+
+// The severity levels of the recommendations, from the lowest to the highest.
+const (
+	LevelWarning  = "Warning"
+	LevelMinor    = "Minor"
+	LevelMajor    = "Major"
+	LevelCritical = "Critical"
+)
+
+// moduleDiagnose is the module of the recommendations reporting the failed checks.
+const moduleDiagnose = "diagnose"
+
+// Levels are all the severity levels.
+var Levels = []string{LevelWarning, LevelMinor, LevelMajor, LevelCritical}
+
+// Recommendation contains a potential problem and possible way to deal with it.
+type Recommendation struct {
+	// Check is the name of the check which finds the problem.
+	Check       string `json:"check,omitempty"`
+	Module      string `json:"module"`
+	Level       string `json:"level"`
+	Description string `json:"description"`
+	Instruction string `json:"instruction"`
+	// Error is set when the check fails to run.
+	Error string `json:"error,omitempty"`
+}
+
+// Diagnoser checks a kind of potential problems of the cluster.
+type Diagnoser interface {
+	// Name is the unique name of the check.
+	Name() string
+	// Description describes what the check does.
+	Description() string
+	// Diagnose runs the check and returns the problems found.
+	Diagnose() ([]*Recommendation, error)
+}
+
+// CheckInfo is the information of a registered check.
+type CheckInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type result struct {
+	recommendations []*Recommendation
+	time            time.Time
+}
+
+// Registry manages the diagnosers and caches their results.
+type Registry struct {
+	sync.Mutex
+	cacheTTL   time.Duration
+	diagnosers []Diagnoser
+	results    map[string]*result
+}
+
+// NewRegistry creates a registry, the results of the checks are reused within cacheTTL.
+func NewRegistry(cacheTTL time.Duration) *Registry {
+	return &Registry{
+		cacheTTL: cacheTTL,
+		results:  make(map[string]*result),
+	}
+}
+
+// Register adds a diagnoser to the registry, the checks are run in the order of registering.
+func (r *Registry) Register(d Diagnoser) error {
+	r.Lock()
+	defer r.Unlock()
+	if r.getDiagnoser(d.Name()) != nil {
+		return errs.ErrDuplicatedDiagnoser.FastGenByArgs(d.Name())
+	}
+	r.diagnosers = append(r.diagnosers, d)
+	return nil
+}
+
+// GetChecks returns all the registered checks.
+func (r *Registry) GetChecks() []CheckInfo {
+	r.Lock()
+	defer r.Unlock()
+	checks := make([]CheckInfo, 0, len(r.diagnosers))
+	for _, d := range r.diagnosers {
+		checks = append(checks, CheckInfo{Name: d.Name(), Description: d.Description()})
+	}
+	return checks
+}
+
+// Run runs the given checks, or all the checks if names is empty. The cached result is
+// returned if the check has been run within the cache TTL. A failed check doesn't fail the
+// others, it's reported as a recommendation with the error instead.
+func (r *Registry) Run(names []string) ([]*Recommendation, error) {
+	r.Lock()
+	diagnosers := append([]Diagnoser(nil), r.diagnosers...)
+	if len(names) > 0 {
+		diagnosers = diagnosers[:0]
+		for _, name := range names {
+			d := r.getDiagnoser(name)
+			if d == nil {
+				r.Unlock()
+				return nil, errs.ErrUnknownDiagnoseCheck.FastGenByArgs(name)
+			}
+			diagnosers = append(diagnosers, d)
+		}
+	}
+	r.Unlock()
+	recommendations := []*Recommendation{}
+	for _, d := range diagnosers {
+		recommendations = append(recommendations, r.run(d)...)
+	}
+	return recommendations, nil
+}
+
+// run runs the check without holding the lock, so a slow check won't block the others.
+func (r *Registry) run(d Diagnoser) []*Recommendation {
+	name := d.Name()
+	r.Lock()
+	res, ok := r.results[name]
+	r.Unlock()
+	if ok && time.Since(res.time) < r.cacheTTL {
+		return res.recommendations
+	}
+	start := time.Now()
+	recommendations, err := d.Diagnose()
+	checkDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		checkFailureCounter.WithLabelValues(name).Inc()
+		err = errs.ErrDiagnoseCheckFailed.FastGenByArgs(name, err.Error())
+		return []*Recommendation{{
+			Check:       name,
+			Module:      moduleDiagnose,
+			Level:       LevelWarning,
+			Description: "the check failed to run, its result is unknown.",
+			Instruction: "please check the error and the log of PD leader.",
+			Error:       err.Error(),
+		}}
+	}
+	counts := make(map[string]int, len(Levels))
+	for _, rd := range recommendations {
+		rd.Check = name
+		counts[rd.Level]++
+	}
+	for _, level := range Levels {
+		recommendationGauge.WithLabelValues(name, level).Set(float64(counts[level]))
+	}
+	r.Lock()
+	r.results[name] = &result{recommendations: recommendations, time: start}
+	r.Unlock()
+	return recommendations
+}
+
+func (r *Registry) getDiagnoser(name string) Diagnoser {
+	for _, d := range r.diagnosers {
+		if d.Name() == name {
+			return d
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnose
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/pkg/errs"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testRegistrySuite{})
+
+type testRegistrySuite struct{}
+
+type mockDiagnoser struct {
+	name            string
+	runs            int
+	err             error
+	recommendations []*Recommendation
+}
+
+func (d *mockDiagnoser) Name() string { return d.name }
+
+func (d *mockDiagnoser) Description() string { return "mock " + d.name }
+
+func (d *mockDiagnoser) Diagnose() ([]*Recommendation, error) {
+	d.runs++
+	return d.recommendations, d.err
+}
+
+func (s *testRegistrySuite) TestRegistry(c *C) {
+	r := NewRegistry(time.Hour)
+	d1 := &mockDiagnoser{name: "d1", recommendations: []*Recommendation{{Module: "m", Level: LevelMinor}}}
+	d2 := &mockDiagnoser{name: "d2"}
+	d3 := &mockDiagnoser{name: "d3", err: errors.New("mock error")}
+	c.Assert(r.Register(d1), IsNil)
+	c.Assert(r.Register(d2), IsNil)
+	c.Assert(r.Register(d3), IsNil)
+	c.Assert(errs.ErrDuplicatedDiagnoser.Equal(r.Register(d1)), IsTrue)
+	c.Assert(r.GetChecks(), DeepEquals, []CheckInfo{{"d1", "mock d1"}, {"d2", "mock d2"}, {"d3", "mock d3"}})
+
+	rdd, err := r.Run([]string{"d1", "d2"})
+	c.Assert(err, IsNil)
+	c.Assert(rdd, HasLen, 1)
+	c.Assert(rdd[0].Check, Equals, "d1")
+	_, err = r.Run([]string{"d4"})
+	c.Assert(errs.ErrUnknownDiagnoseCheck.Equal(err), IsTrue)
+	// The failed check is reported without failing the others.
+	rdd, err = r.Run(nil)
+	c.Assert(err, IsNil)
+	c.Assert(rdd, HasLen, 2)
+	c.Assert(rdd[0].Check, Equals, "d1")
+	c.Assert(rdd[1].Check, Equals, "d3")
+	c.Assert(rdd[1].Error, Matches, ".*ErrDiagnoseCheckFailed.*mock error.*")
+
+	// The results are cached, the failed check is run again.
+	c.Assert(d1.runs, Equals, 1)
+	c.Assert(d2.runs, Equals, 1)
+	c.Assert(d3.runs, Equals, 1)
+	d3.err = nil
+	rdd, err = r.Run(nil)
+	c.Assert(err, IsNil)
+	c.Assert(rdd, HasLen, 1)
+	c.Assert(d1.runs, Equals, 1)
+	c.Assert(d3.runs, Equals, 2)
+
+	r.cacheTTL = 0
+	_, err = r.Run([]string{"d1"})
+	c.Assert(err, IsNil)
+	c.Assert(d1.runs, Equals, 2)
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnose
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	recommendationGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "diagnose",
+			Name:      "recommendations",
+			Help:      "Number of the recommendations found by the last run of the check.",
+		}, []string{"check", "level"})
+
+	checkFailureCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "diagnose",
+			Name:      "check_failures_total",
+			Help:      "Counter of the failed runs of the check.",
+		}, []string{"check"})
+
+	checkDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "pd",
+			Subsystem: "diagnose",
+			Name:      "check_duration_seconds",
+			Help:      "Bucketed histogram of the running time (s) of the check.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+		}, []string{"check"})
+)
+
+func init() {
+	prometheus.MustRegister(recommendationGauge)
+	prometheus.MustRegister(checkFailureCounter)
+	prometheus.MustRegister(checkDuration)
+}
@@ -0,0 +1,360 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/pkg/tsoutil"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/tso"
+	"go.uber.org/zap"
+)
+
+const (
+	gcWorkerServiceID = "gc_worker"
+	// serviceGCSafePointInterval is the interval to flush the service safepoint histories and
+	// refresh the metrics.
+	serviceGCSafePointInterval = time.Minute
+)
+
+// ServiceGCSafePointStatus is the status of a service GC safepoint.
+type ServiceGCSafePointStatus struct {
+	*core.ServiceSafePoint
+	// Lag is how far the safepoint lags behind the current TSO.
+	Lag typeutil.Duration `json:"lag"`
+	// StuckFor is how long the safepoint has not been advanced, it's only
+	// accurate when the history is not truncated.
+	StuckFor typeutil.Duration `json:"stuck_for"`
+	MaxTTL   typeutil.Duration `json:"max_ttl"`
+	MaxLag   typeutil.Duration `json:"max_lag"`
+	// Stale is true if the lag exceeds the max lag.
+	Stale   bool                           `json:"stale"`
+	History []*core.ServiceSafePointUpdate `json:"history,omitempty"`
+}
+
+// getTSONow returns the physical time of a newly allocated global TSO. The local
+// time is used instead if the Global TSO is served by the TSO service.
+func (s *Server) getTSONow() (time.Time, error) {
+	if s.IsTSOServiceActive() {
+		return time.Now(), nil
+	}
+	nowTSO, err := s.tsoAllocatorManager.HandleTSORequest(tso.GlobalDCLocation, 1)
+	if err != nil {
+		return time.Time{}, err
+	}
+	now, _ := tsoutil.ParseTimestamp(nowTSO)
+	return now, nil
+}
+
+// limitServiceGCSafePointTTL limits the TTL requested by the service with the max TTL of its policy.
+func (s *Server) limitServiceGCSafePointTTL(serviceID string, ttl int64) int64 {
+	maxTTL := s.persistOptions.GetPDServerConfig().GetServiceGCSafePointPolicy(serviceID).MaxTTL.Duration
+	if maxTTL <= 0 || ttl <= int64(maxTTL.Seconds()) {
+		return ttl
+	}
+	log.Warn("service GC safepoint TTL exceeds the limit",
+		zap.String("service-id", serviceID),
+		zap.Int64("ttl", ttl),
+		zap.Duration("max-ttl", maxTTL))
+	return int64(maxTTL.Seconds())
+}
+
+func (s *Server) newServiceGCSafePointStatus(ssp *core.ServiceSafePoint, now time.Time) (*ServiceGCSafePointStatus, error) {
+	policy := s.persistOptions.GetPDServerConfig().GetServiceGCSafePointPolicy(ssp.ServiceID)
+	history, err := s.getServiceGCSafePointHistory(ssp.ServiceID)
+	if err != nil {
+		return nil, err
+	}
+	status := &ServiceGCSafePointStatus{
+		ServiceSafePoint: ssp,
+		MaxTTL:           policy.MaxTTL,
+		MaxLag:           policy.MaxLag,
+		History:          history,
+	}
+	// The safepoint 0 means GC has never run, so it's not lagging.
+	if ssp.SafePoint != 0 {
+		physical, _ := tsoutil.ParseTS(ssp.SafePoint)
+		if lag := now.Sub(physical); lag > 0 {
+			status.Lag = typeutil.NewDuration(lag)
+		}
+	}
+	if n := len(history); n > 0 && history[n-1].SafePoint == ssp.SafePoint {
+		if stuckFor := now.Sub(time.Unix(history[n-1].FirstUpdatedAt, 0)); stuckFor > 0 {
+			status.StuckFor = typeutil.NewDuration(stuckFor)
+		}
+	}
+	status.Stale = policy.MaxLag.Duration > 0 && status.Lag.Duration > policy.MaxLag.Duration
+	return status, nil
+}
+
+// updateServiceGCSafePointMetrics updates the metrics with the status of the minimum service
+// safepoint, the metrics are reset if the status is nil.
+func updateServiceGCSafePointMetrics(status *ServiceGCSafePointStatus) {
+	minServiceGCSafePointLagGauge.Reset()
+	staleServiceGCSafePointGauge.Reset()
+	if status == nil {
+		return
+	}
+	minServiceGCSafePointLagGauge.WithLabelValues(status.ServiceID).Set(status.Lag.Seconds())
+	if status.Stale {
+		staleServiceGCSafePointGauge.WithLabelValues(status.ServiceID).Set(1)
+	}
+}
+
+// serviceSafePointHistories caches the update histories of the service safepoints on the PD leader.
+// A history is persisted once a new safepoint is appended, while the updates with the same safepoint
+// only change the cache and are flushed by serviceGCSafePointLoop, so the frequent updates won't add
+// etcd load. The updates not flushed yet may be lost after the leader changes, which only makes the
+// last updated time and the expired time of the latest record older.
+type serviceSafePointHistories struct {
+	sync.Mutex
+	histories map[string][]*core.ServiceSafePointUpdate
+	dirty     map[string]struct{}
+}
+
+// getServiceGCSafePointHistory returns a copy of the update history of the service safepoint.
+func (s *Server) getServiceGCSafePointHistory(serviceID string) ([]*core.ServiceSafePointUpdate, error) {
+	c := &s.serviceSafePointHistories
+	c.Lock()
+	defer c.Unlock()
+	history, err := s.loadServiceGCSafePointHistoryLocked(serviceID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*core.ServiceSafePointUpdate, 0, len(history))
+	for _, update := range history {
+		u := *update
+		result = append(result, &u)
+	}
+	return result, nil
+}
+
+// updateServiceGCSafePointHistory merges the update of the service safepoint into its history.
+func (s *Server) updateServiceGCSafePointHistory(ssp *core.ServiceSafePoint, now time.Time) error {
+	c := &s.serviceSafePointHistories
+	c.Lock()
+	defer c.Unlock()
+	history, err := s.loadServiceGCSafePointHistoryLocked(ssp.ServiceID)
+	if err != nil {
+		return err
+	}
+	history, appended := core.MergeServiceSafePointUpdate(history, ssp, now)
+	c.histories[ssp.ServiceID] = history
+	if !appended {
+		c.dirty[ssp.ServiceID] = struct{}{}
+		return nil
+	}
+	delete(c.dirty, ssp.ServiceID)
+	return s.storage.SaveServiceGCSafePointHistory(ssp.ServiceID, history)
+}
+
+func (s *Server) loadServiceGCSafePointHistoryLocked(serviceID string) ([]*core.ServiceSafePointUpdate, error) {
+	c := &s.serviceSafePointHistories
+	if history, ok := c.histories[serviceID]; ok {
+		return history, nil
+	}
+	history, err := s.storage.LoadServiceGCSafePointHistory(serviceID)
+	if err != nil {
+		return nil, err
+	}
+	if c.histories == nil {
+		c.histories = make(map[string][]*core.ServiceSafePointUpdate)
+		c.dirty = make(map[string]struct{})
+	}
+	c.histories[serviceID] = history
+	return history, nil
+}
+
+// flushServiceGCSafePointHistories persists the dirty histories and drops the ones whose service
+// safepoints don't exist anymore.
+func (s *Server) flushServiceGCSafePointHistories(ssps []*core.ServiceSafePoint) error {
+	c := &s.serviceSafePointHistories
+	c.Lock()
+	defer c.Unlock()
+	exists := make(map[string]struct{}, len(ssps))
+	for _, ssp := range ssps {
+		exists[ssp.ServiceID] = struct{}{}
+	}
+	for serviceID := range c.histories {
+		if _, ok := exists[serviceID]; !ok {
+			delete(c.histories, serviceID)
+			delete(c.dirty, serviceID)
+		}
+	}
+	for serviceID := range c.dirty {
+		if err := s.storage.SaveServiceGCSafePointHistory(serviceID, c.histories[serviceID]); err != nil {
+			return err
+		}
+		delete(c.dirty, serviceID)
+	}
+	return nil
+}
+
+// removeServiceGCSafePoint removes the service safepoint with its history.
+func (s *Server) removeServiceGCSafePoint(serviceID string) error {
+	c := &s.serviceSafePointHistories
+	c.Lock()
+	defer c.Unlock()
+	delete(c.histories, serviceID)
+	delete(c.dirty, serviceID)
+	return s.storage.RemoveServiceGCSafePoint(serviceID)
+}
+
+// RemoveServiceGCSafePoint removes the service safepoint and its history from both the storage
+// and the cache, so the removed history won't come back when the service updates its safepoint again.
+func (s *Server) RemoveServiceGCSafePoint(serviceID string) error {
+	s.serviceSafePointLock.Lock()
+	defer s.serviceSafePointLock.Unlock()
+	return s.removeServiceGCSafePoint(serviceID)
+}
+
+// resetServiceGCSafePointHistories drops the cache, so the histories will be loaded from the storage.
+func (s *Server) resetServiceGCSafePointHistories() {
+	c := &s.serviceSafePointHistories
+	c.Lock()
+	defer c.Unlock()
+	c.histories, c.dirty = nil, nil
+}
+
+// serviceGCSafePointLoop periodically flushes the service safepoint histories and refreshes the
+// metrics on the PD leader, so the lag keeps growing in the metrics even if there is no update.
+func (s *Server) serviceGCSafePointLoop() {
+	defer logutil.LogPanic()
+	defer s.serverLoopWg.Done()
+
+	ctx, cancel := context.WithCancel(s.serverLoopCtx)
+	defer cancel()
+	ticker := time.NewTicker(serviceGCSafePointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !s.member.IsLeader() || s.GetRaftCluster() == nil {
+				s.resetServiceGCSafePointHistories()
+				continue
+			}
+			ssps, err := s.storage.GetAllServiceGCSafePoints()
+			if err == nil {
+				err = s.flushServiceGCSafePointHistories(ssps)
+			}
+			if err == nil {
+				_, err = s.GetMinServiceGCSafePointStatus()
+			}
+			if err != nil {
+				log.Warn("failed to refresh the service GC safepoints", errs.ZapError(err))
+			}
+		case <-ctx.Done():
+			log.Info("server is closed, exit service GC safepoint loop")
+			return
+		}
+	}
+}
+
+// GetMinServiceGCSafePointStatus returns the status of the service whose safepoint is the minimum,
+// which is the one blocking GC. It returns nil if there is no valid service safepoint.
+func (s *Server) GetMinServiceGCSafePointStatus() (*ServiceGCSafePointStatus, error) {
+	now, err := s.getTSONow()
+	if err != nil {
+		return nil, err
+	}
+	ssps, err := s.storage.GetAllServiceGCSafePoints()
+	if err != nil {
+		return nil, err
+	}
+	var min *core.ServiceSafePoint
+	for _, ssp := range ssps {
+		// The expired ones will be removed once the services update their safepoints.
+		if ssp.ExpiredAt < now.Unix() {
+			continue
+		}
+		if min == nil || ssp.SafePoint < min.SafePoint {
+			min = ssp
+		}
+	}
+	if min == nil {
+		updateServiceGCSafePointMetrics(nil)
+		return nil, nil
+	}
+	status, err := s.newServiceGCSafePointStatus(min, now)
+	if err != nil {
+		return nil, err
+	}
+	updateServiceGCSafePointMetrics(status)
+	return status, nil
+}
+
+// GetServiceGCSafePointStatus returns the status of the service GC safepoint of the service.
+// It returns nil if the service safepoint doesn't exist.
+func (s *Server) GetServiceGCSafePointStatus(serviceID string) (*ServiceGCSafePointStatus, error) {
+	now, err := s.getTSONow()
+	if err != nil {
+		return nil, err
+	}
+	ssps, err := s.storage.GetAllServiceGCSafePoints()
+	if err != nil {
+		return nil, err
+	}
+	for _, ssp := range ssps {
+		if ssp.ServiceID == serviceID {
+			return s.newServiceGCSafePointStatus(ssp, now)
+		}
+	}
+	return nil, nil
+}
+
+// ExpireStaleServiceGCSafePoints removes the service safepoints which lag more than the max lag of
+// their policies and returns the IDs of the removed services. The gc_worker is never expired.
+func (s *Server) ExpireStaleServiceGCSafePoints() ([]string, error) {
+	s.serviceSafePointLock.Lock()
+	defer s.serviceSafePointLock.Unlock()
+	now, err := s.getTSONow()
+	if err != nil {
+		return nil, err
+	}
+	ssps, err := s.storage.GetAllServiceGCSafePoints()
+	if err != nil {
+		return nil, err
+	}
+	expired := []string{}
+	for _, ssp := range ssps {
+		// The safepoint of gc_worker never expires.
+		if ssp.ServiceID == gcWorkerServiceID {
+			continue
+		}
+		status, err := s.newServiceGCSafePointStatus(ssp, now)
+		if err != nil {
+			return nil, err
+		}
+		if !status.Stale {
+			continue
+		}
+		if err := s.removeServiceGCSafePoint(ssp.ServiceID); err != nil {
+			return nil, err
+		}
+		log.Warn("expire stale service GC safepoint",
+			zap.String("service-id", ssp.ServiceID),
+			zap.Uint64("safepoint", ssp.SafePoint),
+			zap.Duration("lag", status.Lag.Duration),
+			zap.Duration("max-lag", status.MaxLag.Duration))
+		expired = append(expired, ssp.ServiceID)
+	}
+	return expired, nil
+}
@@ -50,6 +50,8 @@ var (
 	// TODO: work as proxy.
 	ErrNotLeader  = status.Errorf(codes.Unavailable, "not leader")
 	ErrNotStarted = status.Errorf(codes.Unavailable, "server not started")
+	// ErrFollowerSyncLag is returned when the follower lags behind the leader too much to handle the request.
+	ErrFollowerSyncLag = status.Errorf(codes.Unavailable, "follower region sync lags behind the leader")
 )
 
 // GetMembers implements gRPC PDServer.
@@ -101,6 +103,8 @@ func (s *Server) Tso(stream pdpb.PD_TsoServer) error {
 		cancel            context.CancelFunc
 		lastForwardedHost string
 	)
+	priorityName := getTSOPriority(stream.Context())
+	priority := tso.ParseRequestPriority(priorityName)
 	defer func() {
 		// cancel the forward stream
 		if cancel != nil {
@@ -128,7 +132,7 @@ func (s *Server) Tso(stream pdpb.PD_TsoServer) error {
 				}
 				// TODO: change it to the info level once the TiKV doesn't use it in a unary way.
 				log.Debug("create TSO forward stream", zap.String("forwarded-host", forwardedHost))
-				forwardStream, cancel, err = s.createTsoForwardStream(client)
+				forwardStream, cancel, err = s.createTsoForwardStream(client, priorityName)
 				if err != nil {
 					return err
 				}
@@ -159,8 +163,12 @@ func (s *Server) Tso(stream pdpb.PD_TsoServer) error {
 		if request.GetHeader().GetClusterId() != s.clusterID {
 			return status.Errorf(codes.FailedPrecondition, "mismatch cluster id, need %d but got %d", s.clusterID, request.GetHeader().GetClusterId())
 		}
+		dcLocation := request.GetDcLocation()
+		if (dcLocation == "" || dcLocation == tso.GlobalDCLocation) && s.IsTSOServiceActive() {
+			return status.Errorf(codes.FailedPrecondition, errs.ErrTSOServiceActive.FastGenByArgs().Error())
+		}
 		count := request.GetCount()
-		ts, err := s.tsoAllocatorManager.HandleTSORequest(request.GetDcLocation(), count)
+		ts, err := s.tsoAllocatorManager.HandleTSORequestWithPriority(dcLocation, count, priority)
 		if err != nil {
 			return status.Errorf(codes.Unknown, err.Error())
 		}
@@ -280,11 +288,10 @@ func (s *Server) GetStore(ctx context.Context, request *pdpb.GetStoreRequest) (*
 		return pdpb.NewPDClient(client).GetStore(ctx, request)
 	}
 
-	if err := s.validateRequest(request.GetHeader()); err != nil {
+	rc, err := s.getMetadataReader(ctx, request.GetHeader())
+	if err != nil {
 		return nil, err
 	}
-
-	rc := s.GetRaftCluster()
 	if rc == nil {
 		return &pdpb.GetStoreResponse{Header: s.notBootstrappedHeader()}, nil
 	}
@@ -294,10 +301,16 @@ func (s *Server) GetStore(ctx context.Context, request *pdpb.GetStoreRequest) (*
 	if store == nil {
 		return nil, status.Errorf(codes.Unknown, "invalid store ID %d, not found", storeID)
 	}
+	// The followers only have the store metadata, so the stats are left empty rather than
+	// returning the stale ones. Send the request to the leader if the stats are needed.
+	var stats *pdpb.StoreStats
+	if _, ok := rc.(followerReader); !ok {
+		stats = store.GetStoreStats()
+	}
 	return &pdpb.GetStoreResponse{
 		Header: s.header(),
 		Store:  store.GetMeta(),
-		Stats:  store.GetStoreStats(),
+		Stats:  stats,
 	}, nil
 }
 
@@ -377,11 +390,10 @@ func (s *Server) GetAllStores(ctx context.Context, request *pdpb.GetAllStoresReq
 	failpoint.Inject("customTimeout", func() {
 		time.Sleep(5 * time.Second)
 	})
-	if err := s.validateRequest(request.GetHeader()); err != nil {
+	rc, err := s.getMetadataReader(ctx, request.GetHeader())
+	if err != nil {
 		return nil, err
 	}
-
-	rc := s.GetRaftCluster()
 	if rc == nil {
 		return &pdpb.GetAllStoresResponse{Header: s.notBootstrappedHeader()}, nil
 	}
@@ -423,6 +435,7 @@ func (s *Server) StoreHeartbeat(ctx context.Context, request *pdpb.StoreHeartbea
 	if request.GetStats() == nil {
 		return nil, errors.Errorf("invalid store heartbeat command, but %v", request)
 	}
+	s.hbCapturer.CaptureStoreHeartbeat(request)
 	rc := s.GetRaftCluster()
 	if rc == nil {
 		return &pdpb.StoreHeartbeatResponse{Header: s.notBootstrappedHeader()}, nil
@@ -569,6 +582,7 @@ func (s *Server) RegionHeartbeat(stream pdpb.PD_RegionHeartbeatServer) error {
 		if err = s.validateRequest(request.GetHeader()); err != nil {
 			return err
 		}
+		s.hbCapturer.CaptureRegionHeartbeat(request)
 
 		storeID := request.GetLeader().GetStoreId()
 		storeLabel := strconv.FormatUint(storeID, 10)
@@ -639,11 +653,10 @@ func (s *Server) GetRegion(ctx context.Context, request *pdpb.GetRegionRequest)
 		return pdpb.NewPDClient(client).GetRegion(ctx, request)
 	}
 
-	if err := s.validateRequest(request.GetHeader()); err != nil {
+	rc, err := s.getMetadataReader(ctx, request.GetHeader())
+	if err != nil {
 		return nil, err
 	}
-
-	rc := s.GetRaftCluster()
 	if rc == nil {
 		return &pdpb.GetRegionResponse{Header: s.notBootstrappedHeader()}, nil
 	}
@@ -672,11 +685,10 @@ func (s *Server) GetPrevRegion(ctx context.Context, request *pdpb.GetRegionReque
 		return pdpb.NewPDClient(client).GetPrevRegion(ctx, request)
 	}
 
-	if err := s.validateRequest(request.GetHeader()); err != nil {
+	rc, err := s.getMetadataReader(ctx, request.GetHeader())
+	if err != nil {
 		return nil, err
 	}
-
-	rc := s.GetRaftCluster()
 	if rc == nil {
 		return &pdpb.GetRegionResponse{Header: s.notBootstrappedHeader()}, nil
 	}
@@ -706,11 +718,10 @@ func (s *Server) GetRegionByID(ctx context.Context, request *pdpb.GetRegionByIDR
 		return pdpb.NewPDClient(client).GetRegionByID(ctx, request)
 	}
 
-	if err := s.validateRequest(request.GetHeader()); err != nil {
+	rc, err := s.getMetadataReader(ctx, request.GetHeader())
+	if err != nil {
 		return nil, err
 	}
-
-	rc := s.GetRaftCluster()
 	if rc == nil {
 		return &pdpb.GetRegionResponse{Header: s.notBootstrappedHeader()}, nil
 	}
@@ -739,11 +750,10 @@ func (s *Server) ScanRegions(ctx context.Context, request *pdpb.ScanRegionsReque
 		return pdpb.NewPDClient(client).ScanRegions(ctx, request)
 	}
 
-	if err := s.validateRequest(request.GetHeader()); err != nil {
+	rc, err := s.getMetadataReader(ctx, request.GetHeader())
+	if err != nil {
 		return nil, err
 	}
-
-	rc := s.GetRaftCluster()
 	if rc == nil {
 		return &pdpb.ScanRegionsResponse{Header: s.notBootstrappedHeader()}, nil
 	}
@@ -1150,33 +1160,39 @@ func (s *Server) UpdateServiceGCSafePoint(ctx context.Context, request *pdpb.Upd
 		return &pdpb.UpdateServiceGCSafePointResponse{Header: s.notBootstrappedHeader()}, nil
 	}
 	if request.TTL <= 0 {
-		if err := s.storage.RemoveServiceGCSafePoint(string(request.ServiceId)); err != nil {
+		if err := s.removeServiceGCSafePoint(string(request.ServiceId)); err != nil {
 			return nil, err
 		}
 	}
 
-	nowTSO, err := s.tsoAllocatorManager.HandleTSORequest(tso.GlobalDCLocation, 1)
+	now, err := s.getTSONow()
 	if err != nil {
 		return nil, err
 	}
-	now, _ := tsoutil.ParseTimestamp(nowTSO)
 	min, err := s.storage.LoadMinServiceGCSafePoint(now)
 	if err != nil {
 		return nil, err
 	}
 
 	if request.TTL > 0 && request.SafePoint >= min.SafePoint {
+		ttl := request.TTL
+		if string(request.ServiceId) != gcWorkerServiceID {
+			ttl = s.limitServiceGCSafePointTTL(string(request.ServiceId), ttl)
+		}
 		ssp := &core.ServiceSafePoint{
 			ServiceID: string(request.ServiceId),
-			ExpiredAt: now.Unix() + request.TTL,
+			ExpiredAt: now.Unix() + ttl,
 			SafePoint: request.SafePoint,
 		}
-		if math.MaxInt64-now.Unix() <= request.TTL {
+		if math.MaxInt64-now.Unix() <= ttl {
 			ssp.ExpiredAt = math.MaxInt64
 		}
 		if err := s.storage.SaveServiceGCSafePoint(ssp); err != nil {
 			return nil, err
 		}
+		if err := s.updateServiceGCSafePointHistory(ssp, now); err != nil {
+			return nil, err
+		}
 		log.Info("update service GC safe point",
 			zap.String("service-id", ssp.ServiceID),
 			zap.Int64("expire-at", ssp.ExpiredAt),
@@ -1190,6 +1206,10 @@ func (s *Server) UpdateServiceGCSafePoint(ctx context.Context, request *pdpb.Upd
 		}
 	}
 
+	if status, err := s.newServiceGCSafePointStatus(min, now); err == nil {
+		updateServiceGCSafePointMetrics(status)
+	}
+
 	return &pdpb.UpdateServiceGCSafePointResponse{
 		Header:       s.header(),
 		ServiceId:    []byte(min.ServiceID),
@@ -1251,6 +1271,66 @@ func (s *Server) validateRequest(header *pdpb.RequestHeader) error {
 	return nil
 }
 
+// metadataReader serves the read-only region and store requests.
+type metadataReader interface {
+	GetRegionByKey(regionKey []byte) *core.RegionInfo
+	GetPrevRegionByKey(regionKey []byte) *core.RegionInfo
+	GetRegion(regionID uint64) *core.RegionInfo
+	ScanRegions(startKey, endKey []byte, limit int) []*core.RegionInfo
+	GetStore(storeID uint64) *core.StoreInfo
+	GetMetaStores() []*metapb.Store
+}
+
+// followerReader serves the read-only requests on the followers with the regions synced
+// from the leader and the stores persisted by the leader. The stores have no heartbeat
+// stats, and they are reloaded at most once per minute.
+type followerReader struct {
+	*core.BasicCluster
+}
+
+func (r followerReader) GetRegionByKey(regionKey []byte) *core.RegionInfo {
+	return r.SearchRegion(regionKey)
+}
+
+func (r followerReader) GetPrevRegionByKey(regionKey []byte) *core.RegionInfo {
+	return r.SearchPrevRegion(regionKey)
+}
+
+func (r followerReader) ScanRegions(startKey, endKey []byte, limit int) []*core.RegionInfo {
+	return r.ScanRange(startKey, endKey, limit)
+}
+
+// getMetadataReader validates the read-only request and returns the reader to serve it. The
+// followers serve the request only if it allows follower handle, and the reader is nil if the
+// cluster is not bootstrapped.
+func (s *Server) getMetadataReader(ctx context.Context, header *pdpb.RequestHeader) (metadataReader, error) {
+	if s.member.IsLeader() || !isFollowerHandleAllowed(ctx) {
+		if err := s.validateRequest(header); err != nil {
+			return nil, err
+		}
+		rc := s.GetRaftCluster()
+		if rc == nil {
+			return nil, nil
+		}
+		return rc, nil
+	}
+	if s.IsClosed() || !s.cfg.EnableFollowerHandle {
+		return nil, errors.WithStack(ErrNotLeader)
+	}
+	if header.GetClusterId() != s.clusterID {
+		return nil, status.Errorf(codes.FailedPrecondition, "mismatch cluster id, need %d but got %d", s.clusterID, header.GetClusterId())
+	}
+	lag, ok := s.cluster.GetRegionSyncer().GetSyncLag()
+	if !ok || lag > s.cfg.MaxFollowerHandleLag.Duration {
+		followerHandleCounter.WithLabelValues("rejected").Inc()
+		return nil, errors.WithStack(ErrFollowerSyncLag)
+	}
+	followerHandleCounter.WithLabelValues("handled").Inc()
+	// The lag is only informative, the request is served even if it fails to be sent.
+	_ = grpc.SetHeader(ctx, metadata.Pairs(grpcutil.FollowerSyncLagMetadataKey, lag.String()))
+	return followerReader{s.basicCluster}, nil
+}
+
 func (s *Server) header() *pdpb.ResponseHeader {
 	return &pdpb.ResponseHeader{ClusterId: s.clusterID}
 }
@@ -1463,6 +1543,26 @@ func getForwardedHost(ctx context.Context) string {
 	return ""
 }
 
+func isFollowerHandleAllowed(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false
+	}
+	t, ok := md[grpcutil.FollowerHandleMetadataKey]
+	return ok && len(t) > 0 && t[0] == "true"
+}
+
+func getTSOPriority(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if t, ok := md[grpcutil.TSOPriorityMetadataKey]; ok && len(t) > 0 {
+		return t[0]
+	}
+	return ""
+}
+
 func (s *Server) isLocalRequest(forwardedHost string) bool {
 	if forwardedHost == "" {
 		return true
@@ -1476,9 +1576,12 @@ func (s *Server) isLocalRequest(forwardedHost string) bool {
 	return false
 }
 
-func (s *Server) createTsoForwardStream(client *grpc.ClientConn) (pdpb.PD_TsoClient, context.CancelFunc, error) {
+func (s *Server) createTsoForwardStream(client *grpc.ClientConn, priority string) (pdpb.PD_TsoClient, context.CancelFunc, error) {
 	done := make(chan struct{})
 	ctx, cancel := context.WithCancel(s.ctx)
+	if priority != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, grpcutil.TSOPriorityMetadataKey, priority)
+	}
 	go checkStream(ctx, cancel, done)
 	forwardStream, err := pdpb.NewPDClient(client).Tso(ctx)
 	done <- struct{}{}
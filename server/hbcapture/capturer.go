@@ -0,0 +1,305 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hbcapture
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/typeutil"
+	"go.uber.org/zap"
+)
+
+const (
+	// captureQueueSize is the size of the queue between the heartbeat handlers and
+	// the file writer. The records are dropped if the queue is full, so the
+	// capture never slows down the heartbeats.
+	captureQueueSize = 8192
+	// maxCaptureDuration limits the duration of a capture to avoid filling up the disk.
+	maxCaptureDuration = 24 * time.Hour
+	// defaultCaptureDuration is used if the duration is not specified.
+	defaultCaptureDuration = 10 * time.Minute
+)
+
+// Config is the configuration of a capture.
+type Config struct {
+	// Name is the file name of the capture in the capture directory.
+	Name string `json:"name"`
+	// SampleRate is the ratio of the regions whose heartbeats are captured. The
+	// regions are sampled by ID, so all heartbeats of a sampled region are
+	// captured. The store heartbeats are always captured.
+	SampleRate float64 `json:"sample-rate"`
+	// Duration is the max duration of the capture.
+	Duration typeutil.Duration `json:"duration"`
+}
+
+func (c *Config) adjust() error {
+	if c.Name == "" || c.Name != filepath.Base(c.Name) || c.Name == "." || c.Name == ".." {
+		return errs.ErrHeartbeatCaptureConfig.FastGenByArgs("invalid name " + c.Name)
+	}
+	if c.SampleRate == 0 {
+		c.SampleRate = 1
+	}
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return errs.ErrHeartbeatCaptureConfig.FastGenByArgs("sample-rate should be in (0, 1]")
+	}
+	if c.Duration.Duration == 0 {
+		c.Duration.Duration = defaultCaptureDuration
+	}
+	if c.Duration.Duration < 0 || c.Duration.Duration > maxCaptureDuration {
+		return errs.ErrHeartbeatCaptureConfig.FastGenByArgs("duration should be in (0, " + maxCaptureDuration.String() + "]")
+	}
+	return nil
+}
+
+// Status is the status of the running or the last capture.
+type Status struct {
+	Running    bool       `json:"running"`
+	Name       string     `json:"name,omitempty"`
+	Path       string     `json:"path,omitempty"`
+	SampleRate float64    `json:"sample-rate,omitempty"`
+	StartTime  *time.Time `json:"start-time,omitempty"`
+	EndTime    *time.Time `json:"end-time,omitempty"`
+	Captured   uint64     `json:"captured"`
+	Dropped    uint64     `json:"dropped"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Capturer samples the heartbeats received by PD into the capture files, which
+// can be replayed by pd-heartbeat-replay.
+type Capturer struct {
+	dir string
+	// current is the running capture, it is loaded without lock in the
+	// heartbeat handlers.
+	current atomic.Value
+
+	mu   sync.Mutex
+	last Status
+}
+
+// NewCapturer creates a Capturer which writes the captures into the directory.
+func NewCapturer(dir string) *Capturer {
+	c := &Capturer{dir: dir}
+	c.current.Store((*capture)(nil))
+	return c
+}
+
+type capture struct {
+	cfg       Config
+	path      string
+	threshold uint64
+	startTime time.Time
+	records   chan *Record
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+	captured  uint64
+	dropped   uint64
+}
+
+// Start starts a capture with the metas of the stores, which are written at the
+// beginning of the capture. It fails if there is a running capture or the file
+// already exists.
+func (c *Capturer) Start(cfg Config, stores []*metapb.Store) (Status, error) {
+	if err := cfg.adjust(); err != nil {
+		return Status{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.load() != nil {
+		return Status{}, errs.ErrHeartbeatCaptureRunning.FastGenByArgs()
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return Status{}, errs.ErrHeartbeatCaptureFile.Wrap(err).GenWithStackByCause()
+	}
+	path := filepath.Join(c.dir, cfg.Name)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return Status{}, errs.ErrHeartbeatCaptureFile.Wrap(err).GenWithStackByCause()
+	}
+	bw := bufio.NewWriter(file)
+	w, err := NewWriter(bw)
+	if err != nil {
+		file.Close()
+		return Status{}, err
+	}
+	now := time.Now()
+	for _, store := range stores {
+		if err := w.Write(&Record{Time: now, StoreMeta: store}); err != nil {
+			file.Close()
+			return Status{}, err
+		}
+	}
+
+	cp := &capture{
+		cfg:       cfg,
+		path:      path,
+		threshold: sampleThreshold(cfg.SampleRate),
+		startTime: now,
+		records:   make(chan *Record, captureQueueSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	c.current.Store(cp)
+	c.last = cp.status()
+	go c.run(cp, file, bw, w)
+	log.Info("heartbeat capture started", zap.String("path", path), zap.Float64("sample-rate", cfg.SampleRate), zap.Duration("duration", cfg.Duration.Duration))
+	return c.last, nil
+}
+
+// Stop stops the running capture and waits for the file to be closed.
+func (c *Capturer) Stop() (Status, error) {
+	cp := c.load()
+	if cp == nil {
+		return Status{}, errs.ErrHeartbeatCaptureNotRunning.FastGenByArgs()
+	}
+	cp.stopOnce.Do(func() { close(cp.stop) })
+	<-cp.done
+	return c.GetStatus(), nil
+}
+
+// Close stops the running capture if any.
+func (c *Capturer) Close() {
+	if c.load() != nil {
+		_, _ = c.Stop()
+	}
+}
+
+// GetStatus returns the status of the running or the last capture.
+func (c *Capturer) GetStatus() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cp := c.load(); cp != nil {
+		return cp.status()
+	}
+	return c.last
+}
+
+// CaptureRegionHeartbeat captures the region heartbeat if the region is sampled.
+func (c *Capturer) CaptureRegionHeartbeat(request *pdpb.RegionHeartbeatRequest) {
+	cp := c.load()
+	if cp == nil || !cp.sampled(request.GetRegion().GetId()) {
+		return
+	}
+	cp.put(&Record{Time: time.Now(), Region: request})
+}
+
+// CaptureStoreHeartbeat captures the store heartbeat.
+func (c *Capturer) CaptureStoreHeartbeat(request *pdpb.StoreHeartbeatRequest) {
+	cp := c.load()
+	if cp == nil {
+		return
+	}
+	cp.put(&Record{Time: time.Now(), Store: request})
+}
+
+func (c *Capturer) load() *capture {
+	return c.current.Load().(*capture)
+}
+
+func (c *Capturer) run(cp *capture, file *os.File, bw *bufio.Writer, w *Writer) {
+	timer := time.NewTimer(cp.cfg.Duration.Duration)
+	defer timer.Stop()
+
+	var err error
+	write := func(r *Record) {
+		if err != nil {
+			return
+		}
+		if err = w.Write(r); err == nil {
+			atomic.AddUint64(&cp.captured, 1)
+		}
+	}
+loop:
+	for {
+		select {
+		case r := <-cp.records:
+			write(r)
+		case <-timer.C:
+			break loop
+		case <-cp.stop:
+			break loop
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current.Store((*capture)(nil))
+	// Drain the records which have been put before the capture stops.
+	for len(cp.records) > 0 {
+		write(<-cp.records)
+	}
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if flushErr := bw.Flush(); err == nil && flushErr != nil {
+		err = errs.ErrHeartbeatCaptureFile.Wrap(flushErr).GenWithStackByCause()
+	}
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = errs.ErrHeartbeatCaptureFile.Wrap(closeErr).GenWithStackByCause()
+	}
+
+	c.last = cp.status()
+	c.last.Running = false
+	endTime := time.Now()
+	c.last.EndTime = &endTime
+	if err != nil {
+		c.last.Error = err.Error()
+		log.Error("heartbeat capture failed", zap.String("path", cp.path), errs.ZapError(err))
+	}
+	log.Info("heartbeat capture stopped", zap.String("path", cp.path), zap.Uint64("captured", c.last.Captured), zap.Uint64("dropped", c.last.Dropped))
+	close(cp.done)
+}
+
+// sampleThreshold converts the sample rate to the threshold of the region ID hash.
+func sampleThreshold(rate float64) uint64 {
+	if rate >= 1 {
+		return 1<<64 - 1
+	}
+	return uint64(rate * (1 << 63) * 2)
+}
+
+func (cp *capture) sampled(regionID uint64) bool {
+	// Fibonacci hashing spreads the sequential region IDs evenly.
+	return regionID*0x9E3779B97F4A7C15 <= cp.threshold
+}
+
+func (cp *capture) put(r *Record) {
+	select {
+	case cp.records <- r:
+	default:
+		atomic.AddUint64(&cp.dropped, 1)
+	}
+}
+
+func (cp *capture) status() Status {
+	startTime := cp.startTime
+	return Status{
+		Running:    true,
+		Name:       cp.cfg.Name,
+		Path:       cp.path,
+		SampleRate: cp.cfg.SampleRate,
+		StartTime:  &startTime,
+		Captured:   atomic.LoadUint64(&cp.captured),
+		Dropped:    atomic.LoadUint64(&cp.dropped),
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hbcapture
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/testutil"
+	"github.com/tikv/pd/pkg/typeutil"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testCaptureSuite{})
+
+type testCaptureSuite struct{}
+
+func newRegionHeartbeat(id uint64) *pdpb.RegionHeartbeatRequest {
+	peer := &metapb.Peer{Id: id + 100, StoreId: 1}
+	return &pdpb.RegionHeartbeatRequest{
+		Region: &metapb.Region{Id: id, Peers: []*metapb.Peer{peer}},
+		Leader: peer,
+	}
+}
+
+func readRecords(c *C, r io.Reader) []*Record {
+	reader, err := NewReader(r)
+	c.Assert(err, IsNil)
+	defer reader.Close()
+	var records []*Record
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return records
+		}
+		c.Assert(err, IsNil)
+		records = append(records, record)
+	}
+}
+
+func (s *testCaptureSuite) TestRecord(c *C) {
+	now := time.Now()
+	records := []*Record{
+		{Time: now, StoreMeta: &metapb.Store{Id: 1, Address: "mock://tikv-1"}},
+		{Time: now.Add(time.Second), Region: newRegionHeartbeat(2)},
+		{Time: now.Add(2 * time.Second), Store: &pdpb.StoreHeartbeatRequest{Stats: &pdpb.StoreStats{StoreId: 1}}},
+	}
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	c.Assert(err, IsNil)
+	for _, r := range records {
+		c.Assert(w.Write(r), IsNil)
+	}
+	c.Assert(w.Close(), IsNil)
+
+	read := readRecords(c, bytes.NewReader(buf.Bytes()))
+	c.Assert(read, HasLen, len(records))
+	for i, r := range read {
+		c.Assert(r.Type(), Equals, records[i].Type())
+		c.Assert(r.Time.Equal(records[i].Time), IsTrue)
+		c.Assert(r.message(), DeepEquals, records[i].message())
+	}
+
+	// The truncated capture is corrupted.
+	reader, err := NewReader(bytes.NewReader(buf.Bytes()[:buf.Len()-20]))
+	c.Assert(err, IsNil)
+	for err == nil {
+		_, err = reader.Next()
+	}
+	c.Assert(err, ErrorMatches, `\[PD:hbcapture:ErrHeartbeatCaptureCorrupted\].*`)
+
+	_, err = NewReader(bytes.NewReader([]byte("not a capture")))
+	c.Assert(err, ErrorMatches, `\[PD:hbcapture:ErrHeartbeatCaptureCorrupted\].*`)
+}
+
+func (s *testCaptureSuite) TestCapture(c *C) {
+	dir := c.MkDir()
+	capturer := NewCapturer(dir)
+	c.Assert(capturer.GetStatus().Running, IsFalse)
+	_, err := capturer.Stop()
+	c.Assert(errs.ErrHeartbeatCaptureNotRunning.Equal(err), IsTrue)
+
+	for _, cfg := range []Config{
+		{},
+		{Name: "../cap"},
+		{Name: "cap", SampleRate: 2},
+		{Name: "cap", Duration: typeutil.NewDuration(48 * time.Hour)},
+	} {
+		_, err = capturer.Start(cfg, nil)
+		c.Assert(errs.ErrHeartbeatCaptureConfig.Equal(err), IsTrue)
+	}
+
+	stores := []*metapb.Store{{Id: 1}, {Id: 2}}
+	status, err := capturer.Start(Config{Name: "cap"}, stores)
+	c.Assert(err, IsNil)
+	c.Assert(status.Running, IsTrue)
+	c.Assert(status.SampleRate, Equals, 1.0)
+	c.Assert(status.Path, Equals, filepath.Join(dir, "cap"))
+	_, err = capturer.Start(Config{Name: "cap2"}, nil)
+	c.Assert(errs.ErrHeartbeatCaptureRunning.Equal(err), IsTrue)
+
+	for i := uint64(1); i <= 10; i++ {
+		capturer.CaptureRegionHeartbeat(newRegionHeartbeat(i))
+	}
+	capturer.CaptureStoreHeartbeat(&pdpb.StoreHeartbeatRequest{Stats: &pdpb.StoreStats{StoreId: 1}})
+	status, err = capturer.Stop()
+	c.Assert(err, IsNil)
+	c.Assert(status.Running, IsFalse)
+	c.Assert(status.Captured, Equals, uint64(11))
+	c.Assert(status.EndTime, NotNil)
+	c.Assert(status.Error, Equals, "")
+	// The heartbeats are not captured after stopped.
+	capturer.CaptureRegionHeartbeat(newRegionHeartbeat(11))
+	c.Assert(capturer.GetStatus(), DeepEquals, status)
+
+	f, err := os.Open(status.Path)
+	c.Assert(err, IsNil)
+	defer f.Close()
+	records := readRecords(c, f)
+	c.Assert(records, HasLen, 13)
+	c.Assert(records[0].StoreMeta, DeepEquals, stores[0])
+	c.Assert(records[1].StoreMeta, DeepEquals, stores[1])
+	for i := 2; i < 12; i++ {
+		c.Assert(records[i].Region.GetRegion().GetId(), Equals, uint64(i-1))
+	}
+	c.Assert(records[12].Type(), Equals, StoreHeartbeat)
+
+	// The existing file is not overwritten.
+	_, err = capturer.Start(Config{Name: "cap"}, nil)
+	c.Assert(err, ErrorMatches, `\[PD:hbcapture:ErrHeartbeatCaptureFile\].*file exists`)
+}
+
+func (s *testCaptureSuite) TestCaptureDuration(c *C) {
+	capturer := NewCapturer(c.MkDir())
+	_, err := capturer.Start(Config{Name: "cap", Duration: typeutil.NewDuration(100 * time.Millisecond)}, nil)
+	c.Assert(err, IsNil)
+	testutil.WaitUntil(c, func(c *C) bool {
+		return !capturer.GetStatus().Running
+	})
+	_, err = capturer.Stop()
+	c.Assert(errs.ErrHeartbeatCaptureNotRunning.Equal(err), IsTrue)
+}
+
+func (s *testCaptureSuite) TestSample(c *C) {
+	for _, rate := range []float64{0.01, 0.1, 0.5, 1} {
+		cp := &capture{threshold: sampleThreshold(rate)}
+		sampled := 0
+		for id := uint64(1); id <= 10000; id++ {
+			if cp.sampled(id) {
+				sampled++
+			}
+		}
+		c.Assert(float64(sampled), Greater, rate*10000*0.9)
+		c.Assert(float64(sampled), LessEqual, rate*10000*1.1)
+	}
+}
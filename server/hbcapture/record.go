@@ -0,0 +1,192 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hbcapture
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/pkg/errs"
+)
+
+// magic is written at the beginning of a capture file to identify the format.
+const magic = "PDHBCAP1"
+
+// maxRecordSize is the max size of a record, a larger record is treated as corrupted.
+const maxRecordSize = 64 << 20
+
+// RecordType is the type of a captured heartbeat.
+type RecordType uint64
+
+const (
+	// RegionHeartbeat is a captured RegionHeartbeatRequest.
+	RegionHeartbeat RecordType = iota + 1
+	// StoreHeartbeat is a captured StoreHeartbeatRequest.
+	StoreHeartbeat
+	// StoreMeta is the meta of a store when the capture starts.
+	StoreMeta
+)
+
+// Record is a heartbeat request received by PD at the time, or a store meta.
+// Only one of the fields except Time is set.
+type Record struct {
+	Time      time.Time
+	Region    *pdpb.RegionHeartbeatRequest
+	Store     *pdpb.StoreHeartbeatRequest
+	StoreMeta *metapb.Store
+}
+
+// Type returns the type of the record.
+func (r *Record) Type() RecordType {
+	switch {
+	case r.Region != nil:
+		return RegionHeartbeat
+	case r.Store != nil:
+		return StoreHeartbeat
+	default:
+		return StoreMeta
+	}
+}
+
+func (r *Record) message() proto.Message {
+	switch {
+	case r.Region != nil:
+		return r.Region
+	case r.Store != nil:
+		return r.Store
+	default:
+		return r.StoreMeta
+	}
+}
+
+// Writer writes the records to a gzip compressed stream. Each record is encoded
+// as the type, the timestamp in nanoseconds and the length of the request in
+// varints, followed by the request in protobuf.
+type Writer struct {
+	gz  *gzip.Writer
+	buf []byte
+}
+
+// NewWriter creates a Writer and writes the header of the stream.
+func NewWriter(w io.Writer) (*Writer, error) {
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write([]byte(magic)); err != nil {
+		return nil, errs.ErrHeartbeatCaptureFile.Wrap(err).GenWithStackByCause()
+	}
+	return &Writer{gz: gz, buf: make([]byte, 3*binary.MaxVarintLen64)}, nil
+}
+
+// Write writes a record.
+func (w *Writer) Write(r *Record) error {
+	data, err := proto.Marshal(r.message())
+	if err != nil {
+		return errs.ErrProtoMarshal.Wrap(err).GenWithStackByCause()
+	}
+	n := binary.PutUvarint(w.buf, uint64(r.Type()))
+	n += binary.PutVarint(w.buf[n:], r.Time.UnixNano())
+	n += binary.PutUvarint(w.buf[n:], uint64(len(data)))
+	if _, err := w.gz.Write(w.buf[:n]); err != nil {
+		return errs.ErrHeartbeatCaptureFile.Wrap(err).GenWithStackByCause()
+	}
+	if _, err := w.gz.Write(data); err != nil {
+		return errs.ErrHeartbeatCaptureFile.Wrap(err).GenWithStackByCause()
+	}
+	return nil
+}
+
+// Close flushes the records and closes the stream. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if err := w.gz.Close(); err != nil {
+		return errs.ErrHeartbeatCaptureFile.Wrap(err).GenWithStackByCause()
+	}
+	return nil
+}
+
+// Reader reads the records written by Writer.
+type Reader struct {
+	gz *gzip.Reader
+	r  *bufio.Reader
+}
+
+// NewReader creates a Reader and checks the header of the stream.
+func NewReader(r io.Reader) (*Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errs.ErrHeartbeatCaptureCorrupted.Wrap(err).GenWithStackByCause()
+	}
+	br := bufio.NewReader(gz)
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(br, header); err != nil || string(header) != magic {
+		return nil, errs.ErrHeartbeatCaptureCorrupted.FastGenByArgs()
+	}
+	return &Reader{gz: gz, r: br}, nil
+}
+
+// Next reads the next record, it returns io.EOF at the end of the stream.
+func (r *Reader) Next() (*Record, error) {
+	typ, err := binary.ReadUvarint(r.r)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, errs.ErrHeartbeatCaptureCorrupted.Wrap(err).GenWithStackByCause()
+	}
+	ts, err := binary.ReadVarint(r.r)
+	if err != nil {
+		return nil, errs.ErrHeartbeatCaptureCorrupted.Wrap(err).GenWithStackByCause()
+	}
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, errs.ErrHeartbeatCaptureCorrupted.Wrap(err).GenWithStackByCause()
+	}
+	if size > maxRecordSize {
+		return nil, errs.ErrHeartbeatCaptureCorrupted.FastGenByArgs()
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, errs.ErrHeartbeatCaptureCorrupted.Wrap(err).GenWithStackByCause()
+	}
+
+	record := &Record{Time: time.Unix(0, ts)}
+	var msg proto.Message
+	switch RecordType(typ) {
+	case RegionHeartbeat:
+		record.Region = &pdpb.RegionHeartbeatRequest{}
+		msg = record.Region
+	case StoreHeartbeat:
+		record.Store = &pdpb.StoreHeartbeatRequest{}
+		msg = record.Store
+	case StoreMeta:
+		record.StoreMeta = &metapb.Store{}
+		msg = record.StoreMeta
+	default:
+		return nil, errs.ErrHeartbeatCaptureCorrupted.FastGenByArgs()
+	}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, errs.ErrProtoUnmarshal.Wrap(err).GenWithStackByCause()
+	}
+	return record, nil
+}
+
+// Close closes the reader. It does not close the underlying reader.
+func (r *Reader) Close() error {
+	return r.gz.Close()
+}
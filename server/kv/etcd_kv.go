@@ -32,17 +32,42 @@ const (
 	slowRequestTime = 1 * time.Second
 )
 
+// MaxTxnOps is the max number of operations in a transaction, the embedded etcd of PD
+// is configured with it instead of the default 128 of etcd, so a transaction such as
+// a batch of placement rules can be committed at once.
+const MaxTxnOps = 2048
+
 type etcdKVBase struct {
 	client   *clientv3.Client
 	rootPath string
+	// leaderKey and leaderValue guard the transactions, so they are only
+	// committed when the server is still the leader.
+	leaderKey   string
+	leaderValue string
+}
+
+// EtcdKVOption configures the etcd kv.
+type EtcdKVOption func(kv *etcdKVBase)
+
+// WithLeaderGuard makes the transactions only succeed if the value of the
+// leader key is the given one.
+func WithLeaderGuard(leaderKey, leaderValue string) EtcdKVOption {
+	return func(kv *etcdKVBase) {
+		kv.leaderKey = leaderKey
+		kv.leaderValue = leaderValue
+	}
 }
 
 // NewEtcdKVBase creates a new etcd kv.
-func NewEtcdKVBase(client *clientv3.Client, rootPath string) *etcdKVBase {
-	return &etcdKVBase{
+func NewEtcdKVBase(client *clientv3.Client, rootPath string, opts ...EtcdKVOption) *etcdKVBase {
+	kv := &etcdKVBase{
 		client:   client,
 		rootPath: rootPath,
 	}
+	for _, opt := range opts {
+		opt(kv)
+	}
+	return kv
 }
 
 func (kv *etcdKVBase) Load(key string) (string, error) {
@@ -115,6 +140,65 @@ func (kv *etcdKVBase) Remove(key string) error {
 	return nil
 }
 
+func (kv *etcdKVBase) NewTxn() Txn {
+	return &etcdKVTxn{kv: kv}
+}
+
+type etcdKVTxn struct {
+	kv  *etcdKVBase
+	ops []clientv3.Op
+	// index is the position of the op of each key, etcd rejects the transaction
+	// which writes a key more than once, so only the last write is kept.
+	index map[string]int
+}
+
+func (t *etcdKVTxn) Save(key, value string) {
+	key = path.Join(t.kv.rootPath, key)
+	t.put(key, clientv3.OpPut(key, value))
+}
+
+func (t *etcdKVTxn) Remove(key string) {
+	key = path.Join(t.kv.rootPath, key)
+	t.put(key, clientv3.OpDelete(key))
+}
+
+func (t *etcdKVTxn) put(key string, op clientv3.Op) {
+	if i, ok := t.index[key]; ok {
+		t.ops[i] = op
+		return
+	}
+	if t.index == nil {
+		t.index = make(map[string]int)
+	}
+	t.index[key] = len(t.ops)
+	t.ops = append(t.ops, op)
+}
+
+// Commit writes all the keys in a single etcd transaction, which is limited by
+// MaxTxnOps. It fails with ErrEtcdTxnConflict if the server is no longer the leader.
+func (t *etcdKVTxn) Commit() error {
+	if len(t.ops) == 0 {
+		return nil
+	}
+	if len(t.ops) > MaxTxnOps {
+		return errs.ErrEtcdTxnTooManyOps.FastGenByArgs(len(t.ops), MaxTxnOps)
+	}
+	txn := NewSlowLogTxn(t.kv.client)
+	if t.kv.leaderKey != "" {
+		txn = txn.If(clientv3.Compare(clientv3.Value(t.kv.leaderKey), "=", t.kv.leaderValue))
+	}
+	resp, err := txn.Then(t.ops...).Commit()
+	if err != nil {
+		e := errs.ErrEtcdTxnInternal.Wrap(err).GenWithStackByCause()
+		log.Error("commit txn to etcd meet error", zap.Int("ops", len(t.ops)), errs.ZapError(e))
+		return e
+	}
+	if !resp.Succeeded {
+		return errs.ErrEtcdTxnConflict.FastGenByArgs()
+	}
+	return nil
+}
+
 // SlowLogTxn wraps etcd transaction and log slow one.
 type SlowLogTxn struct {
 	clientv3.Txn
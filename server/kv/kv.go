@@ -19,4 +19,21 @@ type Base interface {
 	LoadRange(key, endKey string, limit int) (keys []string, values []string, err error)
 	Save(key, value string) error
 	Remove(key string) error
+	// NewTxn creates a transaction to write multiple keys atomically.
+	NewTxn() Txn
+}
+
+// Txn buffers the writes and applies them atomically when it is committed, so
+// either all or none of the writes are visible. The writes are applied in order,
+// so the later one wins if a key is written more than once.
+type Txn interface {
+	Save(key, value string)
+	Remove(key string)
+	Commit() error
+}
+
+// txnOp is a write buffered by the transactions.
+type txnOp struct {
+	key, value string
+	remove     bool
 }
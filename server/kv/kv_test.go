@@ -23,6 +23,7 @@ import (
 	"testing"
 
 	. "github.com/pingcap/check"
+	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/pkg/tempurl"
 	"go.etcd.io/etcd/clientv3"
 	"go.etcd.io/etcd/embed"
@@ -53,6 +54,28 @@ func (s *testKVSuite) TestEtcd(c *C) {
 	kv := NewEtcdKVBase(client, rootPath)
 	s.testReadWrite(c, kv)
 	s.testRange(c, kv)
+	s.testTxn(c, kv)
+
+	// The transaction fails if the leader key doesn't match.
+	leaderKey := path.Join(rootPath, "leader")
+	_, err = client.Put(client.Ctx(), leaderKey, "member-1")
+	c.Assert(err, IsNil)
+	guarded := NewEtcdKVBase(client, rootPath, WithLeaderGuard(leaderKey, "member-2"))
+	txn := guarded.NewTxn()
+	txn.Save("guarded", "value")
+	c.Assert(errs.ErrEtcdTxnConflict.Equal(txn.Commit()), IsTrue)
+	v, err := kv.Load("guarded")
+	c.Assert(err, IsNil)
+	c.Assert(v, Equals, "")
+	guarded = NewEtcdKVBase(client, rootPath, WithLeaderGuard(leaderKey, "member-1"))
+	txn = guarded.NewTxn()
+	txn.Save("guarded", "value")
+	c.Assert(txn.Commit(), IsNil)
+	v, err = kv.Load("guarded")
+	c.Assert(err, IsNil)
+	c.Assert(v, Equals, "value")
+
+	s.testTxnLimit(c, kv)
 }
 
 func (s *testKVSuite) TestLevelDB(c *C) {
@@ -64,12 +87,36 @@ func (s *testKVSuite) TestLevelDB(c *C) {
 
 	s.testReadWrite(c, kv)
 	s.testRange(c, kv)
+	s.testTxn(c, kv)
 }
 
 func (s *testKVSuite) TestMemKV(c *C) {
 	kv := NewMemoryKV()
 	s.testReadWrite(c, kv)
 	s.testRange(c, kv)
+	s.testTxn(c, kv)
+	s.testTxnLimit(c, kv)
+}
+
+// testTxnLimit checks that the transaction is rejected if it has more operations
+// than the limit, and the writes of the same key are counted once.
+func (s *testKVSuite) testTxnLimit(c *C, kv Base) {
+	txn := kv.NewTxn()
+	for i := 0; i < MaxTxnOps; i++ {
+		txn.Save(fmt.Sprintf("ops-%d", i), "value")
+	}
+	txn.Remove("ops-0")
+	c.Assert(txn.Commit(), IsNil)
+
+	txn = kv.NewTxn()
+	for i := 0; i < MaxTxnOps; i++ {
+		txn.Save(fmt.Sprintf("ops-%d", i), "value")
+	}
+	txn.Save("ops-limit", "value")
+	c.Assert(errs.ErrEtcdTxnTooManyOps.Equal(txn.Commit()), IsTrue)
+	v, err := kv.Load("ops-limit")
+	c.Assert(err, IsNil)
+	c.Assert(v, Equals, "")
 }
 
 func (s *testKVSuite) testReadWrite(c *C, kv Base) {
@@ -90,6 +137,25 @@ func (s *testKVSuite) testReadWrite(c *C, kv Base) {
 	c.Assert(err, IsNil)
 }
 
+func (s *testKVSuite) testTxn(c *C, kv Base) {
+	c.Assert(kv.Save("txn-a", "a"), IsNil)
+	txn := kv.NewTxn()
+	txn.Save("txn-b", "b")
+	txn.Remove("txn-a")
+	txn.Save("txn-c", "c")
+	txn.Save("txn-c", "cc")
+	// Nothing is written before commit.
+	v, err := kv.Load("txn-b")
+	c.Assert(err, IsNil)
+	c.Assert(v, Equals, "")
+	c.Assert(txn.Commit(), IsNil)
+
+	keys, values, err := kv.LoadRange("txn-", clientv3.GetPrefixRangeEnd("txn-"), 100)
+	c.Assert(err, IsNil)
+	c.Assert(keys, DeepEquals, []string{"txn-b", "txn-c"})
+	c.Assert(values, DeepEquals, []string{"b", "cc"})
+}
+
 func (s *testKVSuite) testRange(c *C, kv Base) {
 	keys := []string{
 		"test-a", "test-a/a", "test-a/ab",
@@ -141,6 +207,7 @@ func newTestSingleConfig() *embed.Config {
 	cfg.ACUrls = cfg.LCUrls
 
 	cfg.StrictReconfigCheck = false
+	cfg.MaxTxnOps = MaxTxnOps
 	cfg.InitialCluster = fmt.Sprintf("%s=%s", cfg.Name, &cfg.LPUrls[0])
 	cfg.ClusterState = embed.ClusterStateFlagNew
 	return cfg
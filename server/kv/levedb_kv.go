@@ -76,6 +76,31 @@ func (kv *LeveldbKV) Remove(key string) error {
 	return errors.WithStack(kv.Delete([]byte(key), nil))
 }
 
+// NewTxn creates a transaction which is written as a leveldb batch.
+func (kv *LeveldbKV) NewTxn() Txn {
+	return &leveldbTxn{kv: kv, batch: new(leveldb.Batch)}
+}
+
+type leveldbTxn struct {
+	kv    *LeveldbKV
+	batch *leveldb.Batch
+}
+
+func (t *leveldbTxn) Save(key, value string) {
+	t.batch.Put([]byte(key), []byte(value))
+}
+
+func (t *leveldbTxn) Remove(key string) {
+	t.batch.Delete([]byte(key))
+}
+
+func (t *leveldbTxn) Commit() error {
+	if err := t.kv.Write(t.batch, nil); err != nil {
+		return errs.ErrLevelDBWrite.Wrap(err).GenWithStackByCause()
+	}
+	return nil
+}
+
 // SaveRegions stores some regions.
 func (kv *LeveldbKV) SaveRegions(regions map[string]*metapb.Region) error {
 	batch := new(leveldb.Batch)
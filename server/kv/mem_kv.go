@@ -17,6 +17,7 @@ import (
 	"sync"
 
 	"github.com/google/btree"
+	"github.com/tikv/pd/pkg/errs"
 )
 
 type memoryKV struct {
@@ -79,3 +80,42 @@ func (kv *memoryKV) Remove(key string) error {
 	kv.tree.Delete(memoryKVItem{key, ""})
 	return nil
 }
+
+func (kv *memoryKV) NewTxn() Txn {
+	return &memoryKVTxn{kv: kv}
+}
+
+type memoryKVTxn struct {
+	kv  *memoryKV
+	ops []txnOp
+}
+
+func (t *memoryKVTxn) Save(key, value string) {
+	t.ops = append(t.ops, txnOp{key: key, value: value})
+}
+
+func (t *memoryKVTxn) Remove(key string) {
+	t.ops = append(t.ops, txnOp{key: key, remove: true})
+}
+
+// Commit applies all the writes at once, it is limited by MaxTxnOps like the
+// etcd transaction, and the writes of the same key are counted once.
+func (t *memoryKVTxn) Commit() error {
+	keys := make(map[string]struct{}, len(t.ops))
+	for _, op := range t.ops {
+		keys[op.key] = struct{}{}
+	}
+	if len(keys) > MaxTxnOps {
+		return errs.ErrEtcdTxnTooManyOps.FastGenByArgs(len(keys), MaxTxnOps)
+	}
+	t.kv.Lock()
+	defer t.kv.Unlock()
+	for _, op := range t.ops {
+		if op.remove {
+			t.kv.tree.Delete(memoryKVItem{op.key, ""})
+		} else {
+			t.kv.tree.ReplaceOrInsert(memoryKVItem{op.key, op.value})
+		}
+	}
+	return nil
+}
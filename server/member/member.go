@@ -240,9 +240,28 @@ func (m *Member) MoveEtcdLeader(ctx context.Context, old, new uint64) error {
 
 // GetEtcdLeader returns the etcd leader ID.
 func (m *Member) GetEtcdLeader() uint64 {
+	if m.etcd == nil {
+		return m.getBackendEtcdLeader()
+	}
 	return m.etcd.Server.Lead()
 }
 
+// getBackendEtcdLeader gets the etcd leader ID from the etcd cluster the client connects to,
+// it's used by the member which doesn't embed an etcd server, such as the standalone TSO server.
+func (m *Member) getBackendEtcdLeader() uint64 {
+	for _, endpoint := range m.client.Endpoints() {
+		ctx, cancel := context.WithTimeout(m.client.Ctx(), etcdutil.DefaultRequestTimeout)
+		resp, err := m.client.Status(ctx, endpoint)
+		cancel()
+		if err != nil {
+			log.Warn("failed to get etcd status", zap.String("endpoint", endpoint), errs.ZapError(err))
+			continue
+		}
+		return resp.Leader
+	}
+	return 0
+}
+
 // isSameLeader checks whether a server is the leader itself.
 func (m *Member) isSameLeader(leader *pdpb.Member) bool {
 	return leader.GetMemberId() == m.ID()
@@ -84,6 +84,30 @@ var (
 			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 29), // 0.1ms ~ 7hours
 		}, []string{"address", "store"})
 
+	minServiceGCSafePointLagGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "server",
+			Name:      "min_service_gc_safepoint_lag_seconds",
+			Help:      "The lag (s) of the minimum service GC safepoint behind the TSO.",
+		}, []string{"service"})
+
+	staleServiceGCSafePointGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "server",
+			Name:      "stale_service_gc_safepoint",
+			Help:      "Indicate the minimum service GC safepoint lags more than the max lag.",
+		}, []string{"service"})
+
+	followerHandleCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "server",
+			Name:      "follower_handle_requests_total",
+			Help:      "Counter of the read-only requests handled or rejected by the follower.",
+		}, []string{"status"})
+
 	serverInfo = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: "pd",
@@ -102,5 +126,8 @@ func init() {
 	prometheus.MustRegister(tsoHandleDuration)
 	prometheus.MustRegister(regionHeartbeatHandleDuration)
 	prometheus.MustRegister(storeHeartbeatHandleDuration)
+	prometheus.MustRegister(minServiceGCSafePointLagGauge)
+	prometheus.MustRegister(staleServiceGCSafePointGauge)
+	prometheus.MustRegister(followerHandleCounter)
 	prometheus.MustRegister(serverInfo)
 }
@@ -15,6 +15,7 @@ package syncer
 
 import (
 	"context"
+	"sync/atomic"
 	"time"
 
 	"github.com/pingcap/errors"
@@ -35,11 +36,15 @@ import (
 const (
 	keepaliveTime    = 10 * time.Second
 	keepaliveTimeout = 3 * time.Second
+	// storeReloadInterval is the min interval to reload the stores when receiving the
+	// keepalive from the leader, since the store metadata rarely changes.
+	storeReloadInterval = time.Minute
 )
 
 // StopSyncWithLeader stop to sync the region with leader.
 func (s *RegionSyncer) StopSyncWithLeader() {
 	s.reset()
+	atomic.StoreInt64(&s.lastSyncTime, 0)
 	s.mu.Lock()
 	close(s.mu.closed)
 	s.mu.closed = make(chan struct{})
@@ -47,6 +52,37 @@ func (s *RegionSyncer) StopSyncWithLeader() {
 	s.wg.Wait()
 }
 
+// GetSyncLag returns how long the regions have not been synced with the leader. It returns
+// false if the syncer has never synced with the current leader.
+func (s *RegionSyncer) GetSyncLag() (time.Duration, bool) {
+	last := atomic.LoadInt64(&s.lastSyncTime)
+	if last == 0 {
+		return 0, false
+	}
+	return time.Since(time.Unix(0, last)), true
+}
+
+// loadStores reloads the stores persisted by the leader, so the follower can serve the
+// store requests with them. The stores only contain the metadata, the stats reported by
+// the store heartbeats are not available on the follower.
+func (s *RegionSyncer) loadStores() error {
+	bc := s.server.GetBasicCluster()
+	loaded := make(map[uint64]struct{})
+	err := s.server.GetStorage().LoadStores(func(store *core.StoreInfo) {
+		loaded[store.GetID()] = struct{}{}
+		bc.PutStore(store)
+	})
+	if err != nil {
+		return err
+	}
+	for _, store := range bc.GetStores() {
+		if _, ok := loaded[store.GetID()]; !ok {
+			bc.DeleteStore(store)
+		}
+	}
+	return nil
+}
+
 func (s *RegionSyncer) reset() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -140,6 +176,11 @@ func (s *RegionSyncer) StartSyncWithLeader(addr string) {
 		if err != nil {
 			log.Warn("failed to load regions.", errs.ZapError(err))
 		}
+		if err = s.loadStores(); err != nil {
+			log.Warn("failed to load stores.", errs.ZapError(err))
+		}
+		// The stores are reloaded with the first keepalive, then at most once per storeReloadInterval.
+		var lastStoreLoadTime time.Time
 		// establish client.
 		var conn *grpc.ClientConn
 		for {
@@ -197,6 +238,17 @@ func (s *RegionSyncer) StartSyncWithLeader(addr string) {
 					// reset index
 					s.history.ResetWithIndex(resp.GetStartIndex())
 				}
+				// The keepalive responses carry no regions and are only sent after the history
+				// regions are synced, so the follower is in sync with the leader when receiving them.
+				if len(resp.GetRegions()) == 0 {
+					atomic.StoreInt64(&s.lastSyncTime, time.Now().UnixNano())
+					if time.Since(lastStoreLoadTime) >= storeReloadInterval {
+						if err = s.loadStores(); err != nil {
+							log.Warn("failed to load stores.", errs.ZapError(err))
+						}
+						lastStoreLoadTime = time.Now()
+					}
+				}
 				stats := resp.GetRegionStats()
 				regions := resp.GetRegions()
 				regionLeaders := resp.GetRegionLeaders()
@@ -79,6 +79,9 @@ type RegionSyncer struct {
 	history   *historyBuffer
 	limit     *ratelimit.Bucket
 	tlsConfig *grpcutil.TLSConfig
+	// lastSyncTime is the unix nano time when the follower receives the last keepalive from
+	// the leader, the regions are synced with the leader at that time.
+	lastSyncTime int64
 }
 
 // NewRegionSyncer returns a region syncer.
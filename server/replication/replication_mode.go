@@ -78,6 +78,7 @@ type ModeManager struct {
 	drTotalRegion        int // number of all regions
 
 	drMemberWaitAsyncTime map[uint64]time.Time // last sync time with follower nodes
+	drHoldUntil           time.Time            // automatic state switching is paused until then
 }
 
 // NewReplicationModeManager creates the replicate mode manager.
@@ -108,7 +109,7 @@ func (m *ModeManager) UpdateConfig(config config.ReplicationModeConfig) error {
 	if m.config.ReplicationMode == modeMajority && config.ReplicationMode == modeDRAutoSync {
 		old := m.config
 		m.config = config
-		err := m.drSwitchToSyncRecoverWithLock()
+		err := m.drSwitchToSyncRecoverWithLock(drTriggerConfigChange, "")
 		if err != nil {
 			// restore
 			m.config = old
@@ -119,7 +120,7 @@ func (m *ModeManager) UpdateConfig(config config.ReplicationModeConfig) error {
 	if m.config.ReplicationMode == modeDRAutoSync && config.ReplicationMode == modeDRAutoSync && m.config.DRAutoSync.LabelKey != config.DRAutoSync.LabelKey {
 		old := m.config
 		m.config = config
-		err := m.drSwitchToAsyncWithLock()
+		err := m.drSwitchToAsyncWithLock(drTriggerConfigChange, "")
 		if err != nil {
 			// restore
 			m.config = old
@@ -204,6 +205,19 @@ const (
 	drStateSyncRecover = "sync_recover"
 )
 
+// triggers of dr-auto-sync state transitions.
+const (
+	drTriggerInit         = "init"
+	drTriggerConfigChange = "config-change"
+	drTriggerStoreDown    = "store-down"
+	drTriggerStoreRecover = "store-recover"
+	drTriggerSyncComplete = "sync-complete"
+	drTriggerManual       = "manual"
+)
+
+// drHistoryLimit is the max number of state transitions kept in storage.
+const drHistoryLimit = 256
+
 type drAutoSyncStatus struct {
 	State            string    `json:"state,omitempty"`
 	StateID          uint64    `json:"state_id,omitempty"`
@@ -220,7 +234,7 @@ func (m *ModeManager) loadDRAutoSync() error {
 	}
 	if !ok {
 		// initialize
-		return m.drSwitchToSync()
+		return m.drSwitchToSync(drTriggerInit)
 	}
 	return nil
 }
@@ -242,13 +256,13 @@ func (m *ModeManager) drCheckAsyncTimeout() bool {
 	return time.Since(m.initTime) > timeout
 }
 
-func (m *ModeManager) drSwitchToAsync() error {
+func (m *ModeManager) drSwitchToAsync(trigger string) error {
 	m.Lock()
 	defer m.Unlock()
-	return m.drSwitchToAsyncWithLock()
+	return m.drSwitchToAsyncWithLock(trigger, "")
 }
 
-func (m *ModeManager) drSwitchToAsyncWithLock() error {
+func (m *ModeManager) drSwitchToAsyncWithLock(trigger, reason string) error {
 	id, err := m.cluster.AllocID()
 	if err != nil {
 		log.Warn("failed to switch to async state", zap.String("replicate-mode", modeDRAutoSync), errs.ZapError(err))
@@ -262,18 +276,19 @@ func (m *ModeManager) drSwitchToAsyncWithLock() error {
 		log.Warn("failed to switch to async state", zap.String("replicate-mode", modeDRAutoSync), errs.ZapError(err))
 		return err
 	}
+	m.drRecordTransition(dr, trigger, reason)
 	m.drAutoSync = dr
-	log.Info("switched to async state", zap.String("replicate-mode", modeDRAutoSync))
+	log.Info("switched to async state", zap.String("replicate-mode", modeDRAutoSync), zap.String("trigger", trigger))
 	return nil
 }
 
-func (m *ModeManager) drSwitchToSyncRecover() error {
+func (m *ModeManager) drSwitchToSyncRecover(trigger string) error {
 	m.Lock()
 	defer m.Unlock()
-	return m.drSwitchToSyncRecoverWithLock()
+	return m.drSwitchToSyncRecoverWithLock(trigger, "")
 }
 
-func (m *ModeManager) drSwitchToSyncRecoverWithLock() error {
+func (m *ModeManager) drSwitchToSyncRecoverWithLock(trigger, reason string) error {
 	id, err := m.cluster.AllocID()
 	if err != nil {
 		log.Warn("failed to switch to sync_recover state", zap.String("replicate-mode", modeDRAutoSync), errs.ZapError(err))
@@ -287,15 +302,20 @@ func (m *ModeManager) drSwitchToSyncRecoverWithLock() error {
 		log.Warn("failed to switch to sync_recover state", zap.String("replicate-mode", modeDRAutoSync), errs.ZapError(err))
 		return err
 	}
+	m.drRecordTransition(dr, trigger, reason)
 	m.drAutoSync = dr
 	m.drRecoverKey, m.drRecoverCount = nil, 0
-	log.Info("switched to sync_recover state", zap.String("replicate-mode", modeDRAutoSync))
+	log.Info("switched to sync_recover state", zap.String("replicate-mode", modeDRAutoSync), zap.String("trigger", trigger))
 	return nil
 }
 
-func (m *ModeManager) drSwitchToSync() error {
+func (m *ModeManager) drSwitchToSync(trigger string) error {
 	m.Lock()
 	defer m.Unlock()
+	return m.drSwitchToSyncWithLock(trigger, "")
+}
+
+func (m *ModeManager) drSwitchToSyncWithLock(trigger, reason string) error {
 	id, err := m.cluster.AllocID()
 	if err != nil {
 		log.Warn("failed to switch to sync state", zap.String("replicate-mode", modeDRAutoSync), errs.ZapError(err))
@@ -309,8 +329,9 @@ func (m *ModeManager) drSwitchToSync() error {
 		log.Warn("failed to switch to sync state", zap.String("replicate-mode", modeDRAutoSync), errs.ZapError(err))
 		return err
 	}
+	m.drRecordTransition(dr, trigger, reason)
 	m.drAutoSync = dr
-	log.Info("switched to sync state", zap.String("replicate-mode", modeDRAutoSync))
+	log.Info("switched to sync state", zap.String("replicate-mode", modeDRAutoSync), zap.String("trigger", trigger))
 	return nil
 }
 
@@ -339,6 +360,129 @@ func (m *ModeManager) drGetState() string {
 	return m.drAutoSync.State
 }
 
+// DRStateTransition is a record of dr-auto-sync state transition.
+type DRStateTransition struct {
+	Time    time.Time `json:"time"`
+	From    string    `json:"from,omitempty"`
+	To      string    `json:"to"`
+	StateID uint64    `json:"state_id"`
+	Trigger string    `json:"trigger"`
+	Reason  string    `json:"reason,omitempty"`
+	// recover progress of the previous state when the transition happened.
+	TotalRegions    int     `json:"total_regions,omitempty"`
+	SyncedRegions   int     `json:"synced_regions,omitempty"`
+	RecoverProgress float32 `json:"recover_progress,omitempty"`
+}
+
+// drRecordTransition saves the transition from current state to the new
+// state. The history is best effort, failure will not block state switching.
+func (m *ModeManager) drRecordTransition(to drAutoSyncStatus, trigger, reason string) {
+	t := &DRStateTransition{
+		Time:            time.Now(),
+		From:            m.drAutoSync.State,
+		To:              to.State,
+		StateID:         to.StateID,
+		Trigger:         trigger,
+		Reason:          reason,
+		TotalRegions:    m.drAutoSync.TotalRegions,
+		SyncedRegions:   m.drAutoSync.SyncedRegions,
+		RecoverProgress: m.drAutoSync.RecoverProgress,
+	}
+	if err := m.storage.SaveReplicationStateTransition(modeDRAutoSync, to.StateID, t); err != nil {
+		log.Warn("failed to save state transition", zap.String("replicate-mode", modeDRAutoSync), zap.String("new-state", to.State), errs.ZapError(err))
+		return
+	}
+	history, err := m.loadDRStateHistory()
+	if err != nil {
+		log.Warn("failed to load state transitions", zap.String("replicate-mode", modeDRAutoSync), errs.ZapError(err))
+		return
+	}
+	for i := 0; i < len(history)-drHistoryLimit; i++ {
+		if err := m.storage.RemoveReplicationStateTransition(modeDRAutoSync, history[i].StateID); err != nil {
+			log.Warn("failed to remove stale state transition", zap.String("replicate-mode", modeDRAutoSync), zap.Uint64("state-id", history[i].StateID), errs.ZapError(err))
+			return
+		}
+	}
+}
+
+func (m *ModeManager) loadDRStateHistory() ([]*DRStateTransition, error) {
+	history := make([]*DRStateTransition, 0)
+	var err error
+	if e := m.storage.LoadReplicationStateTransitions(modeDRAutoSync, func(k, v string) {
+		t := &DRStateTransition{}
+		if e := json.Unmarshal([]byte(v), t); e != nil {
+			err = errs.ErrJSONUnmarshal.Wrap(e).GenWithStackByArgs()
+			return
+		}
+		history = append(history, t)
+	}); e != nil {
+		return nil, e
+	}
+	return history, err
+}
+
+// GetDRStateHistory returns the dr-auto-sync state transitions ordered by time.
+func (m *ModeManager) GetDRStateHistory() ([]*DRStateTransition, error) {
+	m.RLock()
+	defer m.RUnlock()
+	return m.loadDRStateHistory()
+}
+
+// SwitchDRState forces dr-auto-sync to switch to the given state. The reason
+// is recorded in history. If hold is not zero, automatic state switching is
+// paused for the duration so that the forced state will not be overwritten.
+func (m *ModeManager) SwitchDRState(state, reason string, hold time.Duration) error {
+	m.Lock()
+	defer m.Unlock()
+	if m.config.ReplicationMode != modeDRAutoSync {
+		return errs.ErrReplicationStateSwitch.FastGenByArgs("replication mode is " + m.config.ReplicationMode)
+	}
+	if reason == "" {
+		return errs.ErrReplicationStateSwitch.FastGenByArgs("reason is required")
+	}
+	current := m.drAutoSync.State
+	if state == current {
+		return errs.ErrReplicationStateSwitch.FastGenByArgs("already in " + state + " state")
+	}
+	var err error
+	switch state {
+	case drStateAsync:
+		err = m.drSwitchToAsyncWithLock(drTriggerManual, reason)
+	case drStateSyncRecover:
+		// sync_recover requires both DCs to be able to replicate.
+		if current != drStateAsync {
+			return errs.ErrReplicationStateSwitch.FastGenByArgs("can only switch to sync_recover from async")
+		}
+		if downPrimary, downDr := m.checkStoreStatusWithLock(); downPrimary >= m.config.DRAutoSync.PrimaryReplicas || downDr >= m.config.DRAutoSync.DRReplicas {
+			return errs.ErrReplicationStateSwitch.FastGenByArgs("too many stores are down")
+		}
+		err = m.drSwitchToSyncRecoverWithLock(drTriggerManual, reason)
+	case drStateSync:
+		// it is not safe to skip recovering.
+		if current != drStateSyncRecover {
+			return errs.ErrReplicationStateSwitch.FastGenByArgs("can only switch to sync from sync_recover")
+		}
+		err = m.drSwitchToSyncWithLock(drTriggerManual, reason)
+	default:
+		return errs.ErrReplicationStateSwitch.FastGenByArgs("unknown state " + state)
+	}
+	if err != nil {
+		return err
+	}
+	if hold > 0 {
+		m.drHoldUntil = time.Now().Add(hold)
+	} else {
+		m.drHoldUntil = time.Time{}
+	}
+	return nil
+}
+
+func (m *ModeManager) drIsHeld() bool {
+	m.RLock()
+	defer m.RUnlock()
+	return time.Now().Before(m.drHoldUntil)
+}
+
 const (
 	idleTimeout  = time.Minute
 	tickInterval = time.Second * 10
@@ -385,13 +529,16 @@ func (m *ModeManager) tickDR() {
 	}
 	hasMajority := upPeers*2 > totalPrimary+totalDr
 
+	// The state is forced by administrator, do not switch it automatically.
+	held := m.drIsHeld()
+
 	// If hasMajority is false, the cluster is always unavailable. Switch to async won't help.
-	if !canSync && hasMajority && m.drGetState() != drStateAsync && m.drCheckAsyncTimeout() {
-		m.drSwitchToAsync()
+	if !held && !canSync && hasMajority && m.drGetState() != drStateAsync && m.drCheckAsyncTimeout() {
+		m.drSwitchToAsync(drTriggerStoreDown)
 	}
 
-	if canSync && m.drGetState() == drStateAsync {
-		m.drSwitchToSyncRecover()
+	if !held && canSync && m.drGetState() == drStateAsync {
+		m.drSwitchToSyncRecover(drTriggerStoreRecover)
 	}
 
 	if m.drGetState() == drStateSyncRecover {
@@ -399,8 +546,8 @@ func (m *ModeManager) tickDR() {
 		progress := m.estimateProgress()
 		drRecoverProgressGauge.Set(float64(progress))
 
-		if progress == 1.0 {
-			m.drSwitchToSync()
+		if progress == 1.0 && !held {
+			m.drSwitchToSync(drTriggerSyncComplete)
 		} else {
 			m.updateRecoverProgress(progress)
 		}
@@ -410,6 +557,10 @@ func (m *ModeManager) tickDR() {
 func (m *ModeManager) checkStoreStatus() (primaryFailCount, drFailCount int) {
 	m.RLock()
 	defer m.RUnlock()
+	return m.checkStoreStatusWithLock()
+}
+
+func (m *ModeManager) checkStoreStatusWithLock() (primaryFailCount, drFailCount int) {
 	for _, s := range m.cluster.GetStores() {
 		if !s.IsTombstone() && s.DownTime() >= m.config.DRAutoSync.WaitStoreTimeout.Duration {
 			labelValue := s.GetLabelValue(m.config.DRAutoSync.LabelKey)
@@ -96,7 +96,7 @@ func (s *testReplicationMode) TestStatus(c *C) {
 		},
 	})
 
-	err = rep.drSwitchToAsync()
+	err = rep.drSwitchToAsync(drTriggerStoreDown)
 	c.Assert(err, IsNil)
 	c.Assert(rep.GetReplicationStatus(), DeepEquals, &pb.ReplicationStatus{
 		Mode: pb.ReplicationMode_DR_AUTO_SYNC,
@@ -108,7 +108,7 @@ func (s *testReplicationMode) TestStatus(c *C) {
 		},
 	})
 
-	err = rep.drSwitchToSyncRecover()
+	err = rep.drSwitchToSyncRecover(drTriggerStoreRecover)
 	c.Assert(err, IsNil)
 	stateID := rep.drAutoSync.StateID
 	c.Assert(rep.GetReplicationStatus(), DeepEquals, &pb.ReplicationStatus{
@@ -126,7 +126,7 @@ func (s *testReplicationMode) TestStatus(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(rep.drAutoSync.State, Equals, drStateSyncRecover)
 
-	err = rep.drSwitchToSync()
+	err = rep.drSwitchToSync(drTriggerSyncComplete)
 	c.Assert(err, IsNil)
 	c.Assert(rep.GetReplicationStatus(), DeepEquals, &pb.ReplicationStatus{
 		Mode: pb.ReplicationMode_DR_AUTO_SYNC,
@@ -193,7 +193,7 @@ func (s *testReplicationMode) TestStateSwitch(c *C) {
 	rep.tickDR()
 	c.Assert(rep.drGetState(), Equals, drStateAsync)
 	assertStateIDUpdate()
-	rep.drSwitchToSync()
+	rep.drSwitchToSync(drTriggerSyncComplete)
 	replicator.err = errors.New("fail to replicate")
 	rep.tickDR()
 	c.Assert(rep.drGetState(), Equals, drStateAsync)
@@ -204,7 +204,7 @@ func (s *testReplicationMode) TestStateSwitch(c *C) {
 	rep.tickDR()
 	c.Assert(rep.drGetState(), Equals, drStateSyncRecover)
 	assertStateIDUpdate()
-	rep.drSwitchToAsync()
+	rep.drSwitchToAsync(drTriggerStoreDown)
 	s.setStoreState(cluster, 1, "down")
 	rep.tickDR()
 	c.Assert(rep.drGetState(), Equals, drStateSyncRecover)
@@ -220,7 +220,7 @@ func (s *testReplicationMode) TestStateSwitch(c *C) {
 	assertStateIDUpdate()
 
 	// sync_recover -> sync
-	rep.drSwitchToSyncRecover()
+	rep.drSwitchToSyncRecover(drTriggerStoreRecover)
 	assertStateIDUpdate()
 	s.setStoreState(cluster, 4, "up")
 	cluster.AddLeaderRegion(1, 1, 2, 5)
@@ -279,7 +279,7 @@ func (s *testReplicationMode) TestAsynctimeout(c *C) {
 	rep.tickDR()
 	c.Assert(rep.drGetState(), Equals, drStateAsync)
 
-	rep.drSwitchToSync()
+	rep.drSwitchToSync(drTriggerSyncComplete)
 	rep.UpdateMemberWaitAsyncTime(42)
 	rep.tickDR()
 	c.Assert(rep.drGetState(), Equals, drStateSync) // cannot switch state due to member not timeout
@@ -289,6 +289,114 @@ func (s *testReplicationMode) TestAsynctimeout(c *C) {
 	c.Assert(rep.drGetState(), Equals, drStateAsync)
 }
 
+func (s *testReplicationMode) TestStateHistory(c *C) {
+	store := core.NewStorage(kv.NewMemoryKV())
+	conf := config.ReplicationModeConfig{ReplicationMode: modeDRAutoSync, DRAutoSync: config.DRAutoSyncReplicationConfig{
+		LabelKey:         "zone",
+		Primary:          "zone1",
+		DR:               "zone2",
+		PrimaryReplicas:  2,
+		DRReplicas:       1,
+		WaitStoreTimeout: typeutil.Duration{Duration: time.Minute},
+		WaitSyncTimeout:  typeutil.Duration{Duration: time.Minute},
+	}}
+	cluster := mockcluster.NewCluster(s.ctx, config.NewTestOptions())
+	rep, err := NewReplicationModeManager(conf, store, cluster, nil)
+	c.Assert(err, IsNil)
+
+	cluster.AddLabelsStore(1, 1, map[string]string{"zone": "zone1"})
+	cluster.AddLabelsStore(2, 1, map[string]string{"zone": "zone1"})
+	cluster.AddLabelsStore(3, 1, map[string]string{"zone": "zone2"})
+
+	s.setStoreState(cluster, 3, "down")
+	rep.tickDR()
+	c.Assert(rep.drGetState(), Equals, drStateAsync)
+	s.setStoreState(cluster, 3, "up")
+	rep.tickDR()
+	c.Assert(rep.drGetState(), Equals, drStateSyncRecover)
+	rep.drAutoSync.TotalRegions, rep.drAutoSync.SyncedRegions, rep.drAutoSync.RecoverProgress = 10, 5, 0.5
+	c.Assert(rep.drSwitchToSync(drTriggerSyncComplete), IsNil)
+
+	history, err := rep.GetDRStateHistory()
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 4)
+	expects := []struct{ from, to, trigger string }{
+		{"", drStateSync, drTriggerInit},
+		{drStateSync, drStateAsync, drTriggerStoreDown},
+		{drStateAsync, drStateSyncRecover, drTriggerStoreRecover},
+		{drStateSyncRecover, drStateSync, drTriggerSyncComplete},
+	}
+	for i, e := range expects {
+		c.Assert(history[i].From, Equals, e.from)
+		c.Assert(history[i].To, Equals, e.to)
+		c.Assert(history[i].Trigger, Equals, e.trigger)
+	}
+	c.Assert(history[3].TotalRegions, Equals, 10)
+	c.Assert(history[3].SyncedRegions, Equals, 5)
+	c.Assert(history[3].RecoverProgress, Equals, float32(0.5))
+
+	// history survives restart and is limited.
+	rep, err = NewReplicationModeManager(conf, store, cluster, nil)
+	c.Assert(err, IsNil)
+	for i := 0; i < drHistoryLimit; i++ {
+		c.Assert(rep.drSwitchToAsync(drTriggerStoreDown), IsNil)
+	}
+	history, err = rep.GetDRStateHistory()
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, drHistoryLimit)
+	c.Assert(history[drHistoryLimit-1].StateID, Equals, rep.drAutoSync.StateID)
+}
+
+func (s *testReplicationMode) TestSwitchDRState(c *C) {
+	store := core.NewStorage(kv.NewMemoryKV())
+	conf := config.ReplicationModeConfig{ReplicationMode: modeMajority}
+	cluster := mockcluster.NewCluster(s.ctx, config.NewTestOptions())
+	rep, err := NewReplicationModeManager(conf, store, cluster, nil)
+	c.Assert(err, IsNil)
+	c.Assert(rep.SwitchDRState(drStateAsync, "drill", 0), NotNil)
+
+	conf = config.ReplicationModeConfig{ReplicationMode: modeDRAutoSync, DRAutoSync: config.DRAutoSyncReplicationConfig{
+		LabelKey:         "zone",
+		Primary:          "zone1",
+		DR:               "zone2",
+		PrimaryReplicas:  2,
+		DRReplicas:       1,
+		WaitStoreTimeout: typeutil.Duration{Duration: time.Minute},
+		WaitSyncTimeout:  typeutil.Duration{Duration: time.Minute},
+	}}
+	rep, err = NewReplicationModeManager(conf, store, cluster, nil)
+	c.Assert(err, IsNil)
+	cluster.AddLabelsStore(1, 1, map[string]string{"zone": "zone1"})
+	cluster.AddLabelsStore(2, 1, map[string]string{"zone": "zone1"})
+	cluster.AddLabelsStore(3, 1, map[string]string{"zone": "zone2"})
+
+	// guards
+	c.Assert(rep.SwitchDRState(drStateAsync, "", 0), NotNil)
+	c.Assert(rep.SwitchDRState(drStateSync, "drill", 0), NotNil)
+	c.Assert(rep.SwitchDRState(drStateSyncRecover, "drill", 0), NotNil)
+	c.Assert(rep.SwitchDRState("unknown", "drill", 0), NotNil)
+	c.Assert(rep.drGetState(), Equals, drStateSync)
+
+	// forced async is held against automatic switching.
+	c.Assert(rep.SwitchDRState(drStateAsync, "drill", time.Hour), IsNil)
+	rep.tickDR()
+	c.Assert(rep.drGetState(), Equals, drStateAsync)
+	history, err := rep.GetDRStateHistory()
+	c.Assert(err, IsNil)
+	last := history[len(history)-1]
+	c.Assert(last.Trigger, Equals, drTriggerManual)
+	c.Assert(last.Reason, Equals, "drill")
+
+	// cannot recover when dr stores are down.
+	s.setStoreState(cluster, 3, "down")
+	c.Assert(rep.SwitchDRState(drStateSyncRecover, "drill done", 0), NotNil)
+	s.setStoreState(cluster, 3, "up")
+	c.Assert(rep.SwitchDRState(drStateSyncRecover, "drill done", 0), IsNil)
+	c.Assert(rep.SwitchDRState(drStateSync, "drill done", 0), IsNil)
+	c.Assert(rep.drIsHeld(), IsFalse)
+	c.Assert(rep.drGetState(), Equals, drStateSync)
+}
+
 func (s *testReplicationMode) setStoreState(cluster *mockcluster.Cluster, id uint64, state string) {
 	store := cluster.GetStore(id)
 	if state == "down" {
@@ -319,7 +427,7 @@ func (s *testReplicationMode) TestRecoverProgress(c *C) {
 	c.Assert(err, IsNil)
 
 	prepare := func(n int, asyncRegions []int) {
-		rep.drSwitchToSyncRecover()
+		rep.drSwitchToSyncRecover(drTriggerStoreRecover)
 		regions := s.genRegions(cluster, rep.drAutoSync.StateID, n)
 		for _, i := range asyncRegions {
 			regions[i] = regions[i].Clone(core.SetReplicationStatus(&pb.RegionReplicationStatus{
@@ -379,7 +487,7 @@ func (s *testReplicationMode) TestRecoverProgressWithSplitAndMerge(c *C) {
 	c.Assert(err, IsNil)
 
 	prepare := func(n int, asyncRegions []int) {
-		rep.drSwitchToSyncRecover()
+		rep.drSwitchToSyncRecover(drTriggerStoreRecover)
 		regions := s.genRegions(cluster, rep.drAutoSync.StateID, n)
 		for _, i := range asyncRegions {
 			regions[i] = regions[i].Clone(core.SetReplicationStatus(&pb.RegionReplicationStatus{
@@ -25,6 +25,7 @@ import (
 	"github.com/tikv/pd/pkg/testutil"
 	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/filter"
 	"github.com/tikv/pd/server/schedule/operator"
 	"github.com/tikv/pd/server/schedule/placement"
 	"github.com/tikv/pd/server/versioninfo"
@@ -250,6 +251,51 @@ func (s *testRuleCheckerSuite) TestFixRoleLeader(c *C) {
 	c.Assert(op.Step(0).(operator.TransferLeader).ToStore, Equals, uint64(3))
 }
 
+func (s *testRuleCheckerSuite) TestFollowerNeverLeader(c *C) {
+	s.cluster.AddLabelsStore(1, 1, map[string]string{"role": "voter"})
+	s.cluster.AddLabelsStore(2, 1, map[string]string{"role": "voter"})
+	s.cluster.AddLabelsStore(3, 1, map[string]string{"role": "follower"})
+	s.cluster.AddLeaderRegionWithRange(1, "", "", 1, 2)
+	s.ruleManager.SetRule(&placement.Rule{
+		GroupID:  "pd",
+		ID:       "default",
+		Override: true,
+		Role:     placement.Voter,
+		Count:    2,
+		LabelConstraints: []placement.LabelConstraint{
+			{Key: "role", Op: "in", Values: []string{"voter"}},
+		},
+	})
+	s.ruleManager.SetRule(&placement.Rule{
+		GroupID: "pd",
+		ID:      "follower",
+		Index:   100,
+		Role:    placement.Follower,
+		Count:   1,
+		LabelConstraints: []placement.LabelConstraint{
+			{Key: "role", Op: "in", Values: []string{"follower"}},
+		},
+	})
+	op := s.rc.Check(s.cluster.GetRegion(1))
+	c.Assert(op, NotNil)
+	c.Assert(op.Desc(), Equals, "add-rule-peer")
+	c.Assert(op.Step(0).(operator.AddLearner).ToStore, Equals, uint64(3))
+
+	// The follower never becomes leader.
+	s.cluster.AddLeaderRegionWithRange(1, "", "", 3, 1, 2)
+	op = s.rc.Check(s.cluster.GetRegion(1))
+	c.Assert(op, NotNil)
+	c.Assert(op.Desc(), Equals, "fix-follower-role")
+	c.Assert(op.Step(0).(operator.TransferLeader).FromStore, Equals, uint64(3))
+	c.Assert(op.Step(0).(operator.TransferLeader).ToStore, Not(Equals), uint64(3))
+
+	s.cluster.AddLeaderRegionWithRange(1, "", "", 1, 2, 3)
+	c.Assert(s.rc.Check(s.cluster.GetRegion(1)), IsNil)
+	leaderFilter := filter.NewPlacementLeaderSafeguard("", s.cluster, s.cluster.GetRegion(1), s.cluster.GetStore(1))
+	c.Assert(leaderFilter.Target(s.cluster.GetOpts(), s.cluster.GetStore(2)), IsTrue)
+	c.Assert(leaderFilter.Target(s.cluster.GetOpts(), s.cluster.GetStore(3)), IsFalse)
+}
+
 func (s *testRuleCheckerSuite) TestFixRoleLeaderIssue3130(c *C) {
 	s.cluster.AddLabelsStore(1, 1, map[string]string{"role": "follower"})
 	s.cluster.AddLabelsStore(2, 1, map[string]string{"role": "leader"})
@@ -33,6 +33,10 @@ const (
 	Follower PeerRoleType = "follower"
 	// Learner matches a learner.
 	Learner PeerRoleType = "learner"
+
+	// legacyWitnessRole is only accepted when loading the persisted rules, they
+	// are converted to Follower which is what the role was scheduled as.
+	legacyWitnessRole PeerRoleType = "witness"
 )
 
 func validateRole(s PeerRoleType) bool {
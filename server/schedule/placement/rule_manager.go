@@ -28,6 +28,7 @@ import (
 	"github.com/tikv/pd/pkg/codec"
 	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/kv"
 	"go.uber.org/zap"
 )
 
@@ -103,6 +104,13 @@ func (m *RuleManager) loadRules() error {
 			toDelete = append(toDelete, k)
 			return
 		}
+		// The witness role was scheduled as a follower, keep the rules instead of
+		// dropping them as bad format.
+		if r.Role == legacyWitnessRole {
+			log.Warn("the witness role is not supported, load the rule as a follower", zap.String("rule-key", k))
+			r.Role = Follower
+			toSave = append(toSave, &r)
+		}
 		if err := m.adjustRule(&r, ""); err != nil {
 			log.Error("rule is in bad format", zap.String("rule-key", k), zap.String("rule-value", v), errs.ZapError(errs.ErrLoadRule, err))
 			toDelete = append(toDelete, k)
@@ -336,35 +344,29 @@ func (m *RuleManager) tryCommitPatch(patch *ruleConfigPatch) error {
 }
 
 func (m *RuleManager) savePatch(p *ruleConfig) error {
-	// TODO: it is not completely safe
-	// 1. in case that half of rules applied, error.. we have to cancel persisted rules
-	// but that may fail too, causing memory/disk inconsistency
-	// either rely a transaction API, or clients to request again until success
-	// 2. in case that PD is suddenly down in the loop, inconsistency again
-	// now we can only rely clients to request again
-	var err error
+	// The rules and groups are saved in one transaction, so the patch is either
+	// fully persisted or not at all, unless it has more writes than kv.MaxTxnOps.
+	rules := make(map[string]interface{}, len(p.rules))
 	for key, r := range p.rules {
 		if r == nil {
-			r = &Rule{GroupID: key[0], ID: key[1]}
-			err = m.storage.DeleteRule(r.StoreKey())
+			rules[(&Rule{GroupID: key[0], ID: key[1]}).StoreKey()] = nil
 		} else {
-			err = m.storage.SaveRule(r.StoreKey(), r)
-		}
-		if err != nil {
-			return err
+			rules[r.StoreKey()] = r
 		}
 	}
+	groups := make(map[string]interface{}, len(p.groups))
 	for id, g := range p.groups {
 		if g.isDefault() {
-			err = m.storage.DeleteRuleGroup(id)
+			groups[id] = nil
 		} else {
-			err = m.storage.SaveRuleGroup(id, g)
-		}
-		if err != nil {
-			return err
+			groups[id] = g
 		}
 	}
-	return nil
+	if len(rules)+len(groups) > kv.MaxTxnOps {
+		log.Warn("placement rules patch is too large to be saved atomically",
+			zap.Int("rules", len(rules)), zap.Int("groups", len(groups)), zap.Int("max-txn-ops", kv.MaxTxnOps))
+	}
+	return m.storage.SaveRulesAndGroups(rules, groups)
 }
 
 // SetRules inserts or updates lots of Rules at once.
@@ -15,6 +15,7 @@ package placement
 
 import (
 	"encoding/hex"
+	"strconv"
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/kvproto/pkg/metapb"
@@ -119,6 +120,35 @@ func (s *testManagerSuite) TestSaveLoad(c *C) {
 	c.Assert(m2.GetRule("foo", "bar").String(), Equals, rules[2].String())
 }
 
+func (s *testManagerSuite) TestLoadWitnessRule(c *C) {
+	rule := &Rule{GroupID: "foo", ID: "witness", Role: legacyWitnessRole, Count: 1}
+	c.Assert(s.store.SaveRule(rule.StoreKey(), rule), IsNil)
+	c.Assert(s.manager.SetRule(&Rule{GroupID: "foo", ID: "witness", Role: legacyWitnessRole, Count: 1}), NotNil)
+
+	m2 := NewRuleManager(s.store, nil)
+	c.Assert(m2.Initialize(3, []string{"no", "labels"}), IsNil)
+	c.Assert(m2.GetRule("foo", "witness").Role, Equals, Follower)
+	m3 := NewRuleManager(s.store, nil)
+	c.Assert(m3.Initialize(3, []string{"no", "labels"}), IsNil)
+	c.Assert(m3.GetRule("foo", "witness").Role, Equals, Follower)
+}
+
+func (s *testManagerSuite) TestSaveManyRules(c *C) {
+	// The rules are more than the max operations of a transaction.
+	n := kv.MaxTxnOps + 100
+	bundle := GroupBundle{ID: "pd"}
+	for i := 0; i < n; i++ {
+		bundle.Rules = append(bundle.Rules, &Rule{GroupID: "pd", ID: strconv.Itoa(i), Role: "voter", Count: 1})
+	}
+	c.Assert(s.manager.SetAllGroupBundles([]GroupBundle{bundle}, true), IsNil)
+	c.Assert(s.manager.GetAllRules(), HasLen, n)
+
+	m2 := NewRuleManager(s.store, nil)
+	c.Assert(m2.Initialize(3, []string{"no", "labels"}), IsNil)
+	c.Assert(m2.GetAllRules(), HasLen, n)
+	c.Assert(m2.GetRule("pd", "default"), IsNil)
+}
+
 // https://github.com/tikv/pd/issues/3886
 func (s *testManagerSuite) TestSetAfterGet(c *C) {
 	rule := s.manager.GetRule("pd", "default")
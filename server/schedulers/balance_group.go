@@ -0,0 +1,181 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedulers
+
+import (
+	"encoding/json"
+
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/filter"
+)
+
+// balanceGroupConfig groups the stores by the value of a label, and the balance
+// schedulers only move leaders or regions between the stores in the same group.
+// The groups listed in TargetRatios are balanced together as one group, and the
+// score of each store is divided by the ratio of its group, so a group with ratio
+// 2 holds twice as many leaders or regions per store as a group with ratio 1.
+type balanceGroupConfig struct {
+	// LabelKey is the label key to group the stores, all stores are in the same
+	// group if it is empty.
+	LabelKey string `json:"label-key,omitempty"`
+	// TargetRatios maps the label value to the target ratio of the group.
+	TargetRatios map[string]float64 `json:"target-ratios,omitempty"`
+}
+
+func (conf *balanceGroupConfig) clone() balanceGroupConfig {
+	ratios := make(map[string]float64, len(conf.TargetRatios))
+	for value, ratio := range conf.TargetRatios {
+		ratios[value] = ratio
+	}
+	if len(ratios) == 0 {
+		ratios = nil
+	}
+	return balanceGroupConfig{
+		LabelKey:     conf.LabelKey,
+		TargetRatios: ratios,
+	}
+}
+
+func (conf *balanceGroupConfig) validate() error {
+	if conf.LabelKey == "" && len(conf.TargetRatios) > 0 {
+		return errs.ErrSchedulerConfig.FastGenByArgs("label-key")
+	}
+	for _, ratio := range conf.TargetRatios {
+		if ratio <= 0 {
+			return errs.ErrSchedulerConfig.FastGenByArgs("target-ratios")
+		}
+	}
+	return nil
+}
+
+// update applies the JSON input to a copy of the config, the fields absent in
+// the input are kept and the target ratios are replaced as a whole.
+func (conf *balanceGroupConfig) update(data []byte) (balanceGroupConfig, error) {
+	var input struct {
+		LabelKey     *string             `json:"label-key"`
+		TargetRatios *map[string]float64 `json:"target-ratios"`
+	}
+	updated := conf.clone()
+	if err := json.Unmarshal(data, &input); err != nil {
+		return updated, errs.ErrSchedulerConfig.Wrap(err).FastGenByArgs("input")
+	}
+	if input.LabelKey != nil {
+		updated.LabelKey = *input.LabelKey
+	}
+	if input.TargetRatios != nil {
+		updated.TargetRatios = *input.TargetRatios
+	}
+	if err := updated.validate(); err != nil {
+		return updated, err
+	}
+	return updated.clone(), nil
+}
+
+// storeGroupKey identifies the group of a store.
+type storeGroupKey struct {
+	value  string
+	pooled bool
+}
+
+func (conf *balanceGroupConfig) groupOf(store *core.StoreInfo) storeGroupKey {
+	if conf.LabelKey == "" {
+		return storeGroupKey{}
+	}
+	value := store.GetLabelValue(conf.LabelKey)
+	if _, ok := conf.TargetRatios[value]; ok {
+		return storeGroupKey{pooled: true}
+	}
+	return storeGroupKey{value: value}
+}
+
+// ratioOf returns the target ratio of the group that the store belongs to.
+func (conf *balanceGroupConfig) ratioOf(store *core.StoreInfo) float64 {
+	if conf.LabelKey == "" {
+		return 1
+	}
+	if ratio, ok := conf.TargetRatios[store.GetLabelValue(conf.LabelKey)]; ok {
+		return ratio
+	}
+	return 1
+}
+
+// storeGroupFilter only allows the stores in the same group as the given store.
+type storeGroupFilter struct {
+	scope  string
+	groups balanceGroupConfig
+	group  storeGroupKey
+}
+
+func newStoreGroupFilter(scope string, groups balanceGroupConfig, store *core.StoreInfo) filter.Filter {
+	return &storeGroupFilter{
+		scope:  scope,
+		groups: groups,
+		group:  groups.groupOf(store),
+	}
+}
+
+func (f *storeGroupFilter) Scope() string {
+	return f.scope
+}
+
+func (f *storeGroupFilter) Type() string {
+	return "store-group-filter"
+}
+
+func (f *storeGroupFilter) Source(opt *config.PersistOptions, store *core.StoreInfo) bool {
+	return f.groups.groupOf(store) == f.group
+}
+
+func (f *storeGroupFilter) Target(opt *config.PersistOptions, store *core.StoreInfo) bool {
+	return f.groups.groupOf(store) == f.group
+}
+
+// groupRegionScoreFilter only allows the target stores whose region score
+// adjusted by the target ratio is lower than the source store's.
+type groupRegionScoreFilter struct {
+	scope  string
+	groups balanceGroupConfig
+	score  float64
+}
+
+func newGroupRegionScoreFilter(scope string, groups balanceGroupConfig, source *core.StoreInfo, opt *config.PersistOptions) filter.Filter {
+	return &groupRegionScoreFilter{
+		scope:  scope,
+		groups: groups,
+		score:  groups.regionScoreOf(source, opt),
+	}
+}
+
+// regionScoreOf returns the region score of the store adjusted by the target ratio.
+func (conf *balanceGroupConfig) regionScoreOf(store *core.StoreInfo, opt *config.PersistOptions) float64 {
+	return store.RegionScore(opt.GetRegionScoreFormulaVersion(), opt.GetHighSpaceRatio(), opt.GetLowSpaceRatio(), 0) / conf.ratioOf(store)
+}
+
+func (f *groupRegionScoreFilter) Scope() string {
+	return f.scope
+}
+
+func (f *groupRegionScoreFilter) Type() string {
+	return "region-score-filter"
+}
+
+func (f *groupRegionScoreFilter) Source(opt *config.PersistOptions, store *core.StoreInfo) bool {
+	return true
+}
+
+func (f *groupRegionScoreFilter) Target(opt *config.PersistOptions, store *core.StoreInfo) bool {
+	return f.groups.regionScoreOf(store, opt) < f.score
+}
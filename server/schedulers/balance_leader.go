@@ -14,9 +14,13 @@
 package schedulers
 
 import (
+	"io"
+	"net/http"
 	"sort"
 	"strconv"
+	"sync"
 
+	"github.com/gorilla/mux"
 	"github.com/pingcap/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tikv/pd/pkg/errs"
@@ -25,6 +29,7 @@ import (
 	"github.com/tikv/pd/server/schedule/filter"
 	"github.com/tikv/pd/server/schedule/operator"
 	"github.com/tikv/pd/server/schedule/opt"
+	"github.com/unrolled/render"
 	"go.uber.org/zap"
 )
 
@@ -55,7 +60,7 @@ func init() {
 	})
 
 	schedule.RegisterScheduler(BalanceLeaderType, func(opController *schedule.OperatorController, storage *core.Storage, decoder schedule.ConfigDecoder) (schedule.Scheduler, error) {
-		conf := &balanceLeaderSchedulerConfig{}
+		conf := &balanceLeaderSchedulerConfig{storage: storage}
 		if err := decoder(conf); err != nil {
 			return nil, err
 		}
@@ -64,8 +69,71 @@ func init() {
 }
 
 type balanceLeaderSchedulerConfig struct {
+	sync.RWMutex
+	storage *core.Storage
+
 	Name   string          `json:"name"`
 	Ranges []core.KeyRange `json:"ranges"`
+	balanceGroupConfig
+}
+
+func (conf *balanceLeaderSchedulerConfig) EncodeConfig() ([]byte, error) {
+	conf.RLock()
+	defer conf.RUnlock()
+	return schedule.EncodeConfig(conf)
+}
+
+func (conf *balanceLeaderSchedulerConfig) getGroupConfig() balanceGroupConfig {
+	conf.RLock()
+	defer conf.RUnlock()
+	return conf.balanceGroupConfig.clone()
+}
+
+func (conf *balanceLeaderSchedulerConfig) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	router := mux.NewRouter()
+	router.HandleFunc("/list", conf.handleGetConfig).Methods("GET")
+	router.HandleFunc("/config", conf.handleSetConfig).Methods("POST")
+	router.ServeHTTP(w, r)
+}
+
+func (conf *balanceLeaderSchedulerConfig) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	conf.RLock()
+	defer conf.RUnlock()
+	rd := render.New(render.Options{IndentJSON: true})
+	rd.JSON(w, http.StatusOK, conf)
+}
+
+func (conf *balanceLeaderSchedulerConfig) handleSetConfig(w http.ResponseWriter, r *http.Request) {
+	rd := render.New(render.Options{IndentJSON: true})
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	conf.Lock()
+	defer conf.Unlock()
+	updated, err := conf.balanceGroupConfig.update(data)
+	if err != nil {
+		rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	old := conf.balanceGroupConfig
+	conf.balanceGroupConfig = updated
+	if err := conf.persist(); err != nil {
+		conf.balanceGroupConfig = old // revert
+		rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rd.JSON(w, http.StatusOK, "success")
+}
+
+func (conf *balanceLeaderSchedulerConfig) persist() error {
+	data, err := schedule.EncodeConfig(conf)
+	if err != nil {
+		return err
+	}
+	return conf.storage.SaveScheduleConfig(conf.Name, data)
 }
 
 type balanceLeaderScheduler struct {
@@ -122,8 +190,12 @@ func (l *balanceLeaderScheduler) GetType() string {
 	return BalanceLeaderType
 }
 
+func (l *balanceLeaderScheduler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.conf.ServeHTTP(w, r)
+}
+
 func (l *balanceLeaderScheduler) EncodeConfig() ([]byte, error) {
-	return schedule.EncodeConfig(l.conf)
+	return l.conf.EncodeConfig()
 }
 
 func (l *balanceLeaderScheduler) IsScheduleAllowed(cluster opt.Cluster) bool {
@@ -141,21 +213,16 @@ func (l *balanceLeaderScheduler) Schedule(cluster opt.Cluster) []*operator.Opera
 	opInfluence := l.opController.GetOpInfluence(cluster)
 	kind := core.NewScheduleKind(core.LeaderKind, leaderSchedulePolicy)
 	plan := newBalancePlan(kind, cluster, opInfluence)
+	plan.groups = l.conf.getGroupConfig()
 
 	stores := cluster.GetStores()
 	sources := filter.SelectSourceStores(stores, l.filters, cluster.GetOpts())
 	targets := filter.SelectTargetStores(stores, l.filters, cluster.GetOpts())
 	sort.Slice(sources, func(i, j int) bool {
-		iOp := plan.GetOpInfluence(sources[i].GetID())
-		jOp := plan.GetOpInfluence(sources[j].GetID())
-		return sources[i].LeaderScore(leaderSchedulePolicy, iOp) >
-			sources[j].LeaderScore(leaderSchedulePolicy, jOp)
+		return plan.leaderScore(sources[i]) > plan.leaderScore(sources[j])
 	})
 	sort.Slice(targets, func(i, j int) bool {
-		iOp := plan.GetOpInfluence(targets[i].GetID())
-		jOp := plan.GetOpInfluence(targets[i].GetID())
-		return targets[i].LeaderScore(leaderSchedulePolicy, iOp) <
-			targets[j].LeaderScore(leaderSchedulePolicy, jOp)
+		return plan.leaderScore(targets[i]) < plan.leaderScore(targets[j])
 	})
 
 	for i := 0; i < len(sources) || i < len(targets); i++ {
@@ -201,16 +268,13 @@ func (l *balanceLeaderScheduler) transferLeaderOut(plan *balancePlan) []*operato
 		return nil
 	}
 	targets := plan.cluster.GetFollowerStores(plan.region)
-	finalFilters := l.filters
+	finalFilters := append(l.filters, newStoreGroupFilter(l.GetName(), plan.groups, plan.source))
 	if leaderFilter := filter.NewPlacementLeaderSafeguard(l.GetName(), plan.cluster, plan.region, plan.source); leaderFilter != nil {
-		finalFilters = append(l.filters, leaderFilter)
+		finalFilters = append(finalFilters, leaderFilter)
 	}
 	targets = filter.SelectTargetStores(targets, finalFilters, plan.cluster.GetOpts())
-	leaderSchedulePolicy := l.opController.GetLeaderSchedulePolicy()
 	sort.Slice(targets, func(i, j int) bool {
-		iOp := plan.GetOpInfluence(targets[i].GetID())
-		jOp := plan.GetOpInfluence(targets[j].GetID())
-		return targets[i].LeaderScore(leaderSchedulePolicy, iOp) < targets[j].LeaderScore(leaderSchedulePolicy, jOp)
+		return plan.leaderScore(targets[i]) < plan.leaderScore(targets[j])
 	})
 	for _, plan.target = range targets {
 		if op := l.createOperator(plan); len(op) > 0 {
@@ -243,9 +307,9 @@ func (l *balanceLeaderScheduler) transferLeaderIn(plan *balancePlan) []*operator
 		schedulerCounter.WithLabelValues(l.GetName(), "no-leader").Inc()
 		return nil
 	}
-	finalFilters := l.filters
+	finalFilters := append(l.filters, newStoreGroupFilter(l.GetName(), plan.groups, plan.source))
 	if leaderFilter := filter.NewPlacementLeaderSafeguard(l.GetName(), plan.cluster, plan.region, plan.source); leaderFilter != nil {
-		finalFilters = append(l.filters, leaderFilter)
+		finalFilters = append(finalFilters, leaderFilter)
 	}
 	target := filter.NewCandidates([]*core.StoreInfo{plan.target}).
 		FilterTarget(plan.cluster.GetOpts(), finalFilters...).
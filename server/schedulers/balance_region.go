@@ -14,9 +14,13 @@
 package schedulers
 
 import (
+	"io"
+	"net/http"
 	"sort"
 	"strconv"
+	"sync"
 
+	"github.com/gorilla/mux"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/log"
 	"github.com/prometheus/client_golang/prometheus"
@@ -26,6 +30,7 @@ import (
 	"github.com/tikv/pd/server/schedule/filter"
 	"github.com/tikv/pd/server/schedule/operator"
 	"github.com/tikv/pd/server/schedule/opt"
+	"github.com/unrolled/render"
 	"go.uber.org/zap"
 )
 
@@ -46,7 +51,7 @@ func init() {
 		}
 	})
 	schedule.RegisterScheduler(BalanceRegionType, func(opController *schedule.OperatorController, storage *core.Storage, decoder schedule.ConfigDecoder) (schedule.Scheduler, error) {
-		conf := &balanceRegionSchedulerConfig{}
+		conf := &balanceRegionSchedulerConfig{storage: storage}
 		if err := decoder(conf); err != nil {
 			return nil, err
 		}
@@ -64,8 +69,71 @@ const (
 )
 
 type balanceRegionSchedulerConfig struct {
+	sync.RWMutex
+	storage *core.Storage
+
 	Name   string          `json:"name"`
 	Ranges []core.KeyRange `json:"ranges"`
+	balanceGroupConfig
+}
+
+func (conf *balanceRegionSchedulerConfig) EncodeConfig() ([]byte, error) {
+	conf.RLock()
+	defer conf.RUnlock()
+	return schedule.EncodeConfig(conf)
+}
+
+func (conf *balanceRegionSchedulerConfig) getGroupConfig() balanceGroupConfig {
+	conf.RLock()
+	defer conf.RUnlock()
+	return conf.balanceGroupConfig.clone()
+}
+
+func (conf *balanceRegionSchedulerConfig) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	router := mux.NewRouter()
+	router.HandleFunc("/list", conf.handleGetConfig).Methods("GET")
+	router.HandleFunc("/config", conf.handleSetConfig).Methods("POST")
+	router.ServeHTTP(w, r)
+}
+
+func (conf *balanceRegionSchedulerConfig) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	conf.RLock()
+	defer conf.RUnlock()
+	rd := render.New(render.Options{IndentJSON: true})
+	rd.JSON(w, http.StatusOK, conf)
+}
+
+func (conf *balanceRegionSchedulerConfig) handleSetConfig(w http.ResponseWriter, r *http.Request) {
+	rd := render.New(render.Options{IndentJSON: true})
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	conf.Lock()
+	defer conf.Unlock()
+	updated, err := conf.balanceGroupConfig.update(data)
+	if err != nil {
+		rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	old := conf.balanceGroupConfig
+	conf.balanceGroupConfig = updated
+	if err := conf.persist(); err != nil {
+		conf.balanceGroupConfig = old // revert
+		rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rd.JSON(w, http.StatusOK, "success")
+}
+
+func (conf *balanceRegionSchedulerConfig) persist() error {
+	data, err := schedule.EncodeConfig(conf)
+	if err != nil {
+		return err
+	}
+	return conf.storage.SaveScheduleConfig(conf.Name, data)
 }
 
 type balanceRegionScheduler struct {
@@ -121,8 +189,12 @@ func (s *balanceRegionScheduler) GetType() string {
 	return BalanceRegionType
 }
 
+func (s *balanceRegionScheduler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.conf.ServeHTTP(w, r)
+}
+
 func (s *balanceRegionScheduler) EncodeConfig() ([]byte, error) {
-	return schedule.EncodeConfig(s.conf)
+	return s.conf.EncodeConfig()
 }
 
 func (s *balanceRegionScheduler) IsScheduleAllowed(cluster opt.Cluster) bool {
@@ -142,12 +214,10 @@ func (s *balanceRegionScheduler) Schedule(cluster opt.Cluster) []*operator.Opera
 	s.OpController.GetFastOpInfluence(cluster, opInfluence)
 	kind := core.NewScheduleKind(core.RegionKind, core.BySize)
 	plan := newBalancePlan(kind, cluster, opInfluence)
+	plan.groups = s.conf.getGroupConfig()
 
 	sort.Slice(stores, func(i, j int) bool {
-		iOp := plan.GetOpInfluence(stores[i].GetID())
-		jOp := plan.GetOpInfluence(stores[j].GetID())
-		return stores[i].RegionScore(opts.GetRegionScoreFormulaVersion(), opts.GetHighSpaceRatio(), opts.GetLowSpaceRatio(), iOp) >
-			stores[j].RegionScore(opts.GetRegionScoreFormulaVersion(), opts.GetHighSpaceRatio(), opts.GetLowSpaceRatio(), jOp)
+		return plan.regionScore(stores[i]) > plan.regionScore(stores[j])
 	})
 	for _, plan.source = range stores {
 		for i := 0; i < balanceRegionRetryLimit; i++ {
@@ -200,14 +270,25 @@ func (s *balanceRegionScheduler) transferPeer(plan *balancePlan) *operator.Opera
 	filters := []filter.Filter{
 		filter.NewExcludedFilter(s.GetName(), nil, plan.region.GetStoreIds()),
 		filter.NewPlacementSafeguard(s.GetName(), plan.cluster, plan.region, plan.source),
-		filter.NewRegionScoreFilter(s.GetName(), plan.source, plan.cluster.GetOpts()),
+		newGroupRegionScoreFilter(s.GetName(), plan.groups, plan.source, plan.cluster.GetOpts()),
 		filter.NewSpecialUseFilter(s.GetName()),
 		&filter.StoreStateFilter{ActionScope: s.GetName(), MoveRegion: true},
+		newStoreGroupFilter(s.GetName(), plan.groups, plan.source),
 	}
 
 	candidates := filter.NewCandidates(plan.cluster.GetStores()).
 		FilterTarget(plan.cluster.GetOpts(), filters...).
-		Sort(filter.RegionScoreComparer(plan.cluster.GetOpts()))
+		Sort(func(a, b *core.StoreInfo) int {
+			sa, sb := plan.regionScore(a), plan.regionScore(b)
+			switch {
+			case sa > sb:
+				return 1
+			case sa < sb:
+				return -1
+			default:
+				return 0
+			}
+		})
 
 	for _, plan.target = range candidates.Stores {
 		regionID := plan.region.GetID()
@@ -18,6 +18,9 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/kvproto/pkg/metapb"
@@ -446,6 +449,51 @@ func (s *testBalanceLeaderSchedulerSuite) TestBalancePolicy(c *C) {
 	testutil.CheckTransferLeader(c, s.schedule()[0], operator.OpKind(0), 1, 4)
 }
 
+func (s *testBalanceLeaderSchedulerSuite) TestBalanceLabelGroup(c *C) {
+	// Stores:     1      2      3      4
+	// Disk:       ssd    ssd    nvme   nvme
+	// Leaders:    5      6      20     10
+	// Region1:    F      F      L      F
+	s.tc.SetTolerantSizeRatio(2.5)
+	s.tc.AddLeaderStore(1, 5)
+	s.tc.AddLeaderStore(2, 6)
+	s.tc.AddLeaderStore(3, 20)
+	s.tc.AddLeaderStore(4, 10)
+	for storeID, disk := range map[uint64]string{1: "ssd", 2: "ssd", 3: "nvme", 4: "nvme"} {
+		s.tc.SetStoreLabel(storeID, map[string]string{"disk": disk})
+	}
+	s.tc.AddLeaderRegion(1, 3, 1, 2, 4)
+	testutil.CheckTransferLeader(c, s.schedule()[0], operator.OpKind(0), 3, 1)
+
+	// The leaders are only balanced between the nvme stores.
+	c.Assert(s.setConfig(c, `{"label-key": "disk"}`), Equals, http.StatusOK)
+	testutil.CheckTransferLeader(c, s.schedule()[0], operator.OpKind(0), 3, 4)
+	s.tc.UpdateLeaderCount(4, 20)
+	c.Assert(s.schedule(), IsNil)
+
+	// The groups with target ratios are balanced together.
+	c.Assert(s.setConfig(c, `{"target-ratios": {"ssd": 1, "nvme": 1}}`), Equals, http.StatusOK)
+	testutil.CheckTransferLeader(c, s.schedule()[0], operator.OpKind(0), 3, 1)
+	c.Assert(s.setConfig(c, `{"target-ratios": {"ssd": 1, "nvme": 4}}`), Equals, http.StatusOK)
+	c.Assert(s.schedule(), IsNil)
+
+	// Invalid configs are rejected.
+	c.Assert(s.setConfig(c, `{"target-ratios": {"ssd": 0}}`), Equals, http.StatusBadRequest)
+	c.Assert(s.setConfig(c, `{"label-key": ""}`), Equals, http.StatusBadRequest)
+	data, err := s.lb.EncodeConfig()
+	c.Assert(err, IsNil)
+	conf := &balanceLeaderSchedulerConfig{}
+	c.Assert(schedule.DecodeConfig(data, conf), IsNil)
+	c.Assert(conf.LabelKey, Equals, "disk")
+	c.Assert(conf.TargetRatios, DeepEquals, map[string]float64{"ssd": 1, "nvme": 4})
+}
+
+func (s *testBalanceLeaderSchedulerSuite) setConfig(c *C, input string) int {
+	w := httptest.NewRecorder()
+	s.lb.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/config", strings.NewReader(input)))
+	return w.Code
+}
+
 func (s *testBalanceLeaderSchedulerSuite) TestBalanceSelector(c *C) {
 	// Stores:     1    2    3    4
 	// Leaders:    1    2    3   16
@@ -883,6 +931,62 @@ func (s *testBalanceRegionSchedulerSuite) TestStoreWeight(c *C) {
 	testutil.CheckTransferPeer(c, sb.Schedule(tc)[0], operator.OpKind(0), 1, 3)
 }
 
+func (s *testBalanceRegionSchedulerSuite) TestLabelGroup(c *C) {
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(s.ctx, opt)
+	tc.SetPlacementRuleEnabled(false)
+	tc.DisableFeature(versioninfo.JointConsensus)
+	oc := schedule.NewOperatorController(s.ctx, nil, nil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+
+	sb, err := schedule.CreateScheduler(BalanceRegionType, oc, storage, schedule.ConfigSliceDecoder(BalanceRegionType, []string{"", ""}))
+	c.Assert(err, IsNil)
+	opt.SetMaxReplicas(1)
+
+	tc.AddLabelsStore(1, 0, map[string]string{"engine": "tiflash"})
+	tc.AddLabelsStore(2, 30, map[string]string{"engine": "tikv"})
+	tc.AddLabelsStore(3, 10, map[string]string{"engine": "tikv"})
+	tc.AddLeaderRegion(1, 2)
+	testutil.CheckTransferPeerWithLeaderTransfer(c, sb.Schedule(tc)[0], operator.OpKind(0), 2, 1)
+
+	w := httptest.NewRecorder()
+	sb.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/config", strings.NewReader(`{"label-key": "engine"}`)))
+	c.Assert(w.Code, Equals, http.StatusOK)
+	testutil.CheckTransferPeerWithLeaderTransfer(c, sb.Schedule(tc)[0], operator.OpKind(0), 2, 3)
+
+	// The config is persisted.
+	_, data, err := storage.LoadAllScheduleConfig()
+	c.Assert(err, IsNil)
+	c.Assert(data, HasLen, 1)
+	conf := &balanceRegionSchedulerConfig{}
+	c.Assert(schedule.DecodeConfig([]byte(data[0]), conf), IsNil)
+	c.Assert(conf.LabelKey, Equals, "engine")
+}
+
+func (s *testBalanceRegionSchedulerSuite) TestTargetRatios(c *C) {
+	opt := config.NewTestOptions()
+	tc := mockcluster.NewCluster(s.ctx, opt)
+	tc.SetPlacementRuleEnabled(false)
+	tc.DisableFeature(versioninfo.JointConsensus)
+	oc := schedule.NewOperatorController(s.ctx, nil, nil)
+
+	sb, err := schedule.CreateScheduler(BalanceRegionType, oc, core.NewStorage(kv.NewMemoryKV()), schedule.ConfigSliceDecoder(BalanceRegionType, []string{"", ""}))
+	c.Assert(err, IsNil)
+	opt.SetMaxReplicas(1)
+
+	// The nvme store has more regions than the ssd store, but less regions
+	// than its target ratio.
+	tc.AddLabelsStore(1, 30, map[string]string{"disk": "ssd"})
+	tc.AddLabelsStore(2, 40, map[string]string{"disk": "nvme"})
+	tc.AddLeaderRegion(1, 1)
+	c.Assert(sb.Schedule(tc), IsNil)
+
+	w := httptest.NewRecorder()
+	sb.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/config", strings.NewReader(`{"label-key": "disk", "target-ratios": {"ssd": 1, "nvme": 4}}`)))
+	c.Assert(w.Code, Equals, http.StatusOK)
+	testutil.CheckTransferPeerWithLeaderTransfer(c, sb.Schedule(tc)[0], operator.OpKind(0), 1, 2)
+}
+
 func (s *testBalanceRegionSchedulerSuite) TestReplacePendingRegion(c *C) {
 	opt := config.NewTestOptions()
 	tc := mockcluster.NewCluster(s.ctx, opt)
@@ -44,6 +44,8 @@ type balancePlan struct {
 	cluster           opt.Cluster
 	opInfluence       operator.OpInfluence
 	tolerantSizeRatio float64
+	// groups is used to adjust the store scores with the target ratios.
+	groups balanceGroupConfig
 
 	source *core.StoreInfo
 	target *core.StoreInfo
@@ -66,6 +68,18 @@ func (p *balancePlan) GetOpInfluence(storeID uint64) int64 {
 	return p.opInfluence.GetStoreInfluence(storeID).ResourceProperty(p.kind)
 }
 
+// leaderScore returns the leader score of the store adjusted by the target ratio.
+func (p *balancePlan) leaderScore(store *core.StoreInfo) float64 {
+	return store.LeaderScore(p.kind.Policy, p.GetOpInfluence(store.GetID())) / p.groups.ratioOf(store)
+}
+
+// regionScore returns the region score of the store adjusted by the target ratio.
+func (p *balancePlan) regionScore(store *core.StoreInfo) float64 {
+	opts := p.cluster.GetOpts()
+	return store.RegionScore(opts.GetRegionScoreFormulaVersion(), opts.GetHighSpaceRatio(), opts.GetLowSpaceRatio(), p.GetOpInfluence(store.GetID())) /
+		p.groups.ratioOf(store)
+}
+
 func (p *balancePlan) SourceStoreID() uint64 {
 	return p.source.GetID()
 }
@@ -115,6 +129,8 @@ func (p *balancePlan) shouldBalance(scheduleName string) bool {
 		p.sourceScore = p.source.RegionScore(opts.GetRegionScoreFormulaVersion(), opts.GetHighSpaceRatio(), opts.GetLowSpaceRatio(), sourceDelta)
 		p.targetScore = p.target.RegionScore(opts.GetRegionScoreFormulaVersion(), opts.GetHighSpaceRatio(), opts.GetLowSpaceRatio(), targetDelta)
 	}
+	p.sourceScore /= p.groups.ratioOf(p.source)
+	p.targetScore /= p.groups.ratioOf(p.target)
 	if opts.IsDebugMetricsEnabled() {
 		opInfluenceStatus.WithLabelValues(scheduleName, strconv.FormatUint(sourceID, 10), "source").Set(float64(sourceInfluence))
 		opInfluenceStatus.WithLabelValues(scheduleName, strconv.FormatUint(targetID, 10), "target").Set(float64(targetInfluence))
@@ -48,6 +48,7 @@ import (
 	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/core"
 	"github.com/tikv/pd/server/encryptionkm"
+	"github.com/tikv/pd/server/hbcapture"
 	"github.com/tikv/pd/server/id"
 	"github.com/tikv/pd/server/kv"
 	"github.com/tikv/pd/server/member"
@@ -91,6 +92,9 @@ type Server struct {
 
 	// Server start timestamp
 	startTimestamp int64
+	// tsoServiceRevision is the create revision of the TSO leader key if the
+	// Global TSO is handed over to the standalone TSO servers, otherwise 0.
+	tsoServiceRevision int64
 
 	// Configs and initial fields.
 	cfg            *config.Config
@@ -129,6 +133,8 @@ type Server struct {
 	cluster *cluster.RaftCluster
 	// For async region heartbeat.
 	hbStreams *hbstream.HeartbeatStreams
+	// For capturing the heartbeats to replay.
+	hbCapturer *hbcapture.Capturer
 	// Zap logger
 	lg       *zap.Logger
 	logProps *log.ZapProperties
@@ -139,6 +145,8 @@ type Server struct {
 
 	// serviceSafePointLock is a lock for UpdateServiceGCSafePoint
 	serviceSafePointLock sync.Mutex
+	// serviceSafePointHistories caches the update histories of the service safepoints.
+	serviceSafePointHistories serviceSafePointHistories
 
 	// Store as map[string]*grpc.ClientConn
 	clientConns sync.Map
@@ -375,7 +383,7 @@ func (s *Server) startServer(ctx context.Context) error {
 		return err
 	}
 	s.encryptionKeyManager = encryptionKeyManager
-	kvBase := kv.NewEtcdKVBase(s.client, s.rootPath)
+	kvBase := kv.NewEtcdKVBase(s.client, s.rootPath, kv.WithLeaderGuard(s.member.GetLeaderPath(), s.member.MemberValue()))
 	path := filepath.Join(s.cfg.DataDir, "region-meta")
 	regionStorage, err := core.NewRegionStorage(ctx, path, encryptionKeyManager)
 	if err != nil {
@@ -390,6 +398,7 @@ func (s *Server) startServer(ctx context.Context) error {
 	s.basicCluster = core.NewBasicCluster()
 	s.cluster = cluster.NewRaftCluster(ctx, s.GetClusterRootPath(), s.clusterID, syncer.NewRegionSyncer(s), s.client, s.httpClient)
 	s.hbStreams = hbstream.NewHeartbeatStreams(ctx, s.clusterID, s.cluster)
+	s.hbCapturer = hbcapture.NewCapturer(filepath.Join(s.cfg.DataDir, "heartbeat-capture"))
 
 	// Run callbacks
 	for _, cb := range s.startCallbacks {
@@ -450,6 +459,9 @@ func (s *Server) Close() {
 	if s.hbStreams != nil {
 		s.hbStreams.Close()
 	}
+	if s.hbCapturer != nil {
+		s.hbCapturer.Close()
+	}
 	if err := s.storage.Close(); err != nil {
 		log.Error("close storage meet error", errs.ZapError(err))
 	}
@@ -497,12 +509,13 @@ func (s *Server) LoopContext() context.Context {
 
 func (s *Server) startServerLoop(ctx context.Context) {
 	s.serverLoopCtx, s.serverLoopCancel = context.WithCancel(ctx)
-	s.serverLoopWg.Add(5)
+	s.serverLoopWg.Add(6)
 	go s.leaderLoop()
 	go s.etcdLeaderLoop()
 	go s.serverMetricsLoop()
 	go s.tsoAllocatorLoop()
 	go s.encryptionKeyManagerLoop()
+	go s.serviceGCSafePointLoop()
 }
 
 func (s *Server) stopServerLoop() {
@@ -723,6 +736,11 @@ func (s *Server) GetHBStreams() *hbstream.HeartbeatStreams {
 	return s.hbStreams
 }
 
+// GetHeartbeatCapturer returns the heartbeat capturer.
+func (s *Server) GetHeartbeatCapturer() *hbcapture.Capturer {
+	return s.hbCapturer
+}
+
 // GetAllocator returns the ID allocator of server.
 func (s *Server) GetAllocator() id.Allocator {
 	return s.idAllocator
@@ -1225,12 +1243,28 @@ func (s *Server) campaignLeader() {
 		log.Error("failed to get the global TSO allocator", errs.ZapError(err))
 		return
 	}
+	// The Global TSO Allocator is not initialized if the TSO service is active.
 	log.Info("initializing the global TSO allocator")
-	if err := alllocator.Initialize(0); err != nil {
+	defer atomic.StoreInt64(&s.tsoServiceRevision, 0)
+	handoff := &tsoHandoff{s: s, allocator: alllocator}
+	revision, err := handoff.sync()
+	if err != nil {
 		log.Error("failed to initialize the global TSO allocator", errs.ZapError(err))
 		return
 	}
 	defer s.tsoAllocatorManager.ResetAllocatorGroup(tso.GlobalDCLocation)
+	var handoffWg sync.WaitGroup
+	handoffWg.Add(1)
+	go func() {
+		defer logutil.LogPanic()
+		defer handoffWg.Done()
+		handoff.watch(ctx, revision)
+	}()
+	// Stop the handoff before resetting the allocator.
+	defer func() {
+		cancel()
+		handoffWg.Wait()
+	}()
 
 	if err := s.reloadConfigFromKV(); err != nil {
 		log.Error("failed to reload configuration", errs.ZapError(err))
@@ -1257,6 +1291,8 @@ func (s *Server) campaignLeader() {
 		log.Error("failed to sync id from etcd", errs.ZapError(err))
 		return
 	}
+	// The service safepoint histories may be changed by the previous leader.
+	s.resetServiceGCSafePointHistories()
 	// EnableLeader to accept the remaining service, such as GetStore, GetRegion.
 	s.member.EnableLeader()
 	// Check the cluster dc-location after the PD leader is elected.
@@ -14,6 +14,7 @@
 package statistics
 
 import (
+	"sync"
 	"time"
 
 	"github.com/pingcap/log"
@@ -45,8 +46,9 @@ type RegionInfo struct {
 	startDownPeerTS      int64
 }
 
-// RegionStatistics is used to record the status of regions.
+// RegionStatistics is used to record the status of regions. It's safe for concurrent use.
 type RegionStatistics struct {
+	sync.RWMutex
 	opt          *config.PersistOptions
 	stats        map[RegionStatisticType]map[uint64]*RegionInfo
 	offlineStats map[RegionStatisticType]map[uint64]*core.RegionInfo
@@ -84,6 +86,8 @@ func NewRegionStatistics(opt *config.PersistOptions, ruleManager *placement.Rule
 
 // GetRegionStatsByType gets the status of the region by types.
 func (r *RegionStatistics) GetRegionStatsByType(typ RegionStatisticType) []*core.RegionInfo {
+	r.RLock()
+	defer r.RUnlock()
 	res := make([]*core.RegionInfo, 0, len(r.stats[typ]))
 	for _, r := range r.stats[typ] {
 		res = append(res, r.RegionInfo)
@@ -93,6 +97,8 @@ func (r *RegionStatistics) GetRegionStatsByType(typ RegionStatisticType) []*core
 
 // GetOfflineRegionStatsByType gets the status of the offline region by types.
 func (r *RegionStatistics) GetOfflineRegionStatsByType(typ RegionStatisticType) []*core.RegionInfo {
+	r.RLock()
+	defer r.RUnlock()
 	res := make([]*core.RegionInfo, 0, len(r.stats[typ]))
 	for _, r := range r.offlineStats[typ] {
 		res = append(res, r)
@@ -164,6 +170,10 @@ func (r *RegionStatistics) Observe(region *core.RegionInfo, stores []*core.Store
 		EmptyRegion: region.GetApproximateSize() <= core.EmptyRegionApproximateSize,
 	}
 
+	// The conditions are computed without the lock, which matters when the rules
+	// are looked up for many regions concurrently.
+	r.Lock()
+	defer r.Unlock()
 	for typ, c := range conditions {
 		if c {
 			if isOffline {
@@ -215,6 +225,8 @@ func (r *RegionStatistics) Observe(region *core.RegionInfo, stores []*core.Store
 
 // ClearDefunctRegion is used to handle the overlap region.
 func (r *RegionStatistics) ClearDefunctRegion(regionID uint64) {
+	r.Lock()
+	defer r.Unlock()
 	if oldIndex, ok := r.index[regionID]; ok {
 		r.deleteEntry(oldIndex, regionID)
 	}
@@ -225,6 +237,8 @@ func (r *RegionStatistics) ClearDefunctRegion(regionID uint64) {
 
 // Collect collects the metrics of the regions' status.
 func (r *RegionStatistics) Collect() {
+	r.RLock()
+	defer r.RUnlock()
 	regionStatusGauge.WithLabelValues("miss-peer-region-count").Set(float64(len(r.stats[MissPeer])))
 	regionStatusGauge.WithLabelValues("extra-peer-region-count").Set(float64(len(r.stats[ExtraPeer])))
 	regionStatusGauge.WithLabelValues("down-peer-region-count").Set(float64(len(r.stats[DownPeer])))
@@ -247,8 +261,9 @@ func (r *RegionStatistics) Reset() {
 	offlineRegionStatusGauge.Reset()
 }
 
-// LabelStatistics is the statistics of the level of labels.
+// LabelStatistics is the statistics of the level of labels. It's safe for concurrent use.
 type LabelStatistics struct {
+	sync.Mutex
 	regionLabelStats map[uint64]string
 	labelCounter     map[string]int
 }
@@ -265,6 +280,8 @@ func NewLabelStatistics() *LabelStatistics {
 func (l *LabelStatistics) Observe(region *core.RegionInfo, stores []*core.StoreInfo, labels []string) {
 	regionID := region.GetID()
 	regionIsolation := getRegionLabelIsolation(stores, labels)
+	l.Lock()
+	defer l.Unlock()
 	if label, ok := l.regionLabelStats[regionID]; ok {
 		if label == regionIsolation {
 			return
@@ -277,6 +294,8 @@ func (l *LabelStatistics) Observe(region *core.RegionInfo, stores []*core.StoreI
 
 // Collect collects the metrics of the label status.
 func (l *LabelStatistics) Collect() {
+	l.Lock()
+	defer l.Unlock()
 	for level, count := range l.labelCounter {
 		regionLabelLevelGauge.WithLabelValues(level).Set(float64(count))
 	}
@@ -289,6 +308,8 @@ func (l *LabelStatistics) Reset() {
 
 // ClearDefunctRegion is used to handle the overlap region.
 func (l *LabelStatistics) ClearDefunctRegion(regionID uint64) {
+	l.Lock()
+	defer l.Unlock()
 	if label, ok := l.regionLabelStats[regionID]; ok {
 		l.labelCounter[label]--
 		delete(l.regionLabelStats, regionID)
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"math"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -117,12 +118,17 @@ type AllocatorManager struct {
 	saveInterval           time.Duration
 	updatePhysicalInterval time.Duration
 	maxResetTSGap          func() time.Duration
+	clockLagPolicy         string
+	enableBatch            bool
 	securityConfig         *grpcutil.TLSConfig
 	// for gRPC use
 	localAllocatorConn struct {
 		sync.RWMutex
 		clientConns map[string]*grpc.ClientConn
 	}
+	// dc-location -> batcher, only used when enableBatch is true. The batcher is
+	// removed when the allocator of the dc-location is reset or deleted.
+	batchers sync.Map
 }
 
 // NewAllocatorManager creates a new TSO Allocator Manager.
@@ -139,6 +145,8 @@ func NewAllocatorManager(
 		saveInterval:           cfg.TSOSaveInterval.Duration,
 		updatePhysicalInterval: cfg.TSOUpdatePhysicalInterval.Duration,
 		maxResetTSGap:          maxResetTSGap,
+		clockLagPolicy:         cfg.TSOClockLagPolicy,
+		enableBatch:            cfg.EnableTSOBatch,
 		securityConfig:         &cfg.Security.TLSConfig,
 	}
 	allocatorManager.mu.allocatorGroups = make(map[string]*allocatorGroup)
@@ -653,7 +661,10 @@ func (am *AllocatorManager) ClusterDCLocationChecker() {
 	newDCLocations := make([]string, 0)
 	// Update the new dc-locations
 	for dcLocation, serverIDs := range newClusterDCLocations {
-		if _, ok := am.mu.clusterDCLocations[dcLocation]; !ok {
+		if info, ok := am.mu.clusterDCLocations[dcLocation]; ok {
+			// The members of a dc-location may be changed by a migration.
+			info.ServerIDs = serverIDs
+		} else {
 			am.mu.clusterDCLocations[dcLocation] = &DCLocationInfo{
 				ServerIDs: serverIDs,
 				Suffix:    -1,
@@ -694,13 +705,14 @@ func (am *AllocatorManager) ClusterDCLocationChecker() {
 }
 
 // getOrCreateLocalTSOSuffix will check whether we have the Local TSO suffix written into etcd.
-// If not, it will write a number into etcd according to the its joining order.
+// If not, it will reuse a reclaimed suffix which is safe to be reused or write a new number
+// into etcd according to the its joining order.
 // If yes, it will just return the previous persisted one.
 func (am *AllocatorManager) getOrCreateLocalTSOSuffix(dcLocation string) (int32, error) {
 	// Try to get the suffix from etcd
 	dcLocationSuffix, err := am.getDCLocationSuffixMapFromEtcd()
 	if err != nil {
-		return -1, nil
+		return -1, err
 	}
 	var maxSuffix int32
 	for curDCLocation, suffix := range dcLocationSuffix {
@@ -713,6 +725,20 @@ func (am *AllocatorManager) getOrCreateLocalTSOSuffix(dcLocation string) (int32,
 			maxSuffix = suffix
 		}
 	}
+	reclaimedSuffixes, err := am.getReclaimedLocalTSOSuffixesFromEtcd()
+	if err != nil {
+		return -1, err
+	}
+	// A reclaimed suffix is still reserved until it can be reused safely,
+	// so it should not be allocated as a new one.
+	for suffix := range reclaimedSuffixes {
+		if suffix > maxSuffix {
+			maxSuffix = suffix
+		}
+	}
+	if suffix, ok := am.pickReclaimedLocalTSOSuffix(reclaimedSuffixes); ok {
+		return am.reuseLocalTSOSuffix(dcLocation, suffix)
+	}
 	maxSuffix++
 	localTSOSuffixKey := am.GetLocalTSOSuffixPath(dcLocation)
 	// The Local TSO suffix is determined by the joining order of this dc-location.
@@ -768,6 +794,16 @@ func (am *AllocatorManager) getMaxLocalTSOSuffix() (int32, error) {
 			maxSuffix = suffix
 		}
 	}
+	// Take the reclaimed suffixes into account to keep the suffix bits stable.
+	reclaimedSuffixes, err := am.getReclaimedLocalTSOSuffixesFromEtcd()
+	if err != nil {
+		return -1, err
+	}
+	for suffix := range reclaimedSuffixes {
+		if suffix > maxSuffix {
+			maxSuffix = suffix
+		}
+	}
 	return maxSuffix, nil
 }
 
@@ -910,6 +946,7 @@ func (am *AllocatorManager) deleteAllocatorGroup(dcLocation string) {
 		allocatorGroup.cancel()
 		delete(am.mu.allocatorGroups, dcLocation)
 	}
+	am.removeBatcher(dcLocation)
 }
 
 // HandleTSORequest forwards TSO allocation requests to correct TSO Allocators.
@@ -925,6 +962,43 @@ func (am *AllocatorManager) HandleTSORequest(dcLocation string, count uint32) (p
 	return allocatorGroup.allocator.GenerateTSO(count)
 }
 
+// HandleTSORequestWithPriority is like HandleTSORequest, but coalesces the concurrent
+// requests of the same dc-location when the TSO batch is enabled. The requests with
+// higher priority are served first if there are too many requests to fit in one batch.
+func (am *AllocatorManager) HandleTSORequestWithPriority(dcLocation string, count uint32, priority RequestPriority) (pdpb.Timestamp, error) {
+	if !am.enableBatch {
+		return am.HandleTSORequest(dcLocation, count)
+	}
+	// Reject the empty request before it joins a batch, or it would get the
+	// timestamp of the other requests.
+	if count == 0 {
+		return pdpb.Timestamp{}, errs.ErrGenerateTimestamp.FastGenByArgs("tso count should be positive")
+	}
+	if dcLocation == "" {
+		dcLocation = GlobalDCLocation
+	}
+	for {
+		b, ok := am.batchers.Load(dcLocation)
+		if !ok {
+			b, _ = am.batchers.LoadOrStore(dcLocation, newTSOBatcher(dcLocation, func(count uint32) (pdpb.Timestamp, error) {
+				return am.HandleTSORequest(dcLocation, count)
+			}))
+		}
+		// The batcher may be removed after it's loaded, retry with a new one.
+		if ts, ok, err := b.(*tsoBatcher).handle(count, priority); ok {
+			return ts, err
+		}
+	}
+}
+
+// removeBatcher removes the batcher of the dc-location and stops it, a new one is
+// created by the next request if the dc-location is still in use.
+func (am *AllocatorManager) removeBatcher(dcLocation string) {
+	if b, ok := am.batchers.LoadAndDelete(dcLocation); ok {
+		b.(*tsoBatcher).stop()
+	}
+}
+
 // ResetAllocatorGroup will reset the allocator's leadership and TSO initialized in memory.
 // It usually should be called before re-triggering an Allocator leader campaign.
 func (am *AllocatorManager) ResetAllocatorGroup(dcLocation string) {
@@ -934,6 +1008,7 @@ func (am *AllocatorManager) ResetAllocatorGroup(dcLocation string) {
 		allocatorGroup.allocator.Reset()
 		allocatorGroup.leadership.Reset()
 	}
+	am.removeBatcher(dcLocation)
 }
 
 func (am *AllocatorManager) getAllocatorGroups(filters ...AllocatorGroupFilter) []*allocatorGroup {
@@ -979,6 +1054,17 @@ func (am *AllocatorManager) GetAllocators(filters ...AllocatorGroupFilter) []All
 	return allocators
 }
 
+// GetHealthStatus returns the health status of all initialized allocators this server holds.
+func (am *AllocatorManager) GetHealthStatus() []*HealthStatus {
+	allocators := am.GetAllocators(FilterUninitialized(), FilterUnavailableLeadership())
+	status := make([]*HealthStatus, 0, len(allocators))
+	for _, allocator := range allocators {
+		status = append(status, allocator.GetHealthStatus())
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].DCLocation < status[j].DCLocation })
+	return status
+}
+
 // GetHoldingLocalAllocatorLeaders returns all Local TSO Allocator leaders this server holds.
 func (am *AllocatorManager) GetHoldingLocalAllocatorLeaders() ([]*LocalTSOAllocator, error) {
 	localAllocators := am.GetAllocators(
@@ -0,0 +1,225 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tso
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/pdpb"
+)
+
+// RequestPriority is the priority class of a TSO request.
+type RequestPriority int
+
+// Priority classes of TSO requests. The requests with lower priority yield
+// to the ones with higher priority when the batch is full.
+const (
+	// PriorityNormal is used by the transactional requests.
+	PriorityNormal RequestPriority = iota
+	// PriorityLow is used by the background requests, such as GC and statistics.
+	PriorityLow
+
+	priorityCount
+)
+
+// String implements fmt.Stringer.
+func (p RequestPriority) String() string {
+	switch p {
+	case PriorityNormal:
+		return "normal"
+	case PriorityLow:
+		return "low"
+	}
+	return "unknown"
+}
+
+// ParseRequestPriority parses the priority of a TSO request, unknown priority is
+// treated as PriorityNormal.
+func ParseRequestPriority(s string) RequestPriority {
+	if s == PriorityLow.String() {
+		return PriorityLow
+	}
+	return PriorityNormal
+}
+
+const (
+	// maxBatchSize is the max number of requests in a batch.
+	maxBatchSize = 10000
+	// maxBatchCount is the max number of timestamps generated by a batch, a request
+	// with larger count is handled in a batch alone.
+	maxBatchCount = uint32(maxLogical / 4)
+)
+
+type batchRequest struct {
+	count    uint32
+	priority RequestPriority
+	start    time.Time
+	// lead is notified when the request is chosen to process the pending batches.
+	lead chan struct{}
+	done chan struct{}
+	ts   pdpb.Timestamp
+	err  error
+}
+
+// tsoBatcher coalesces the concurrent TSO requests of a dc-location into one
+// GenerateTSO call and splits the result back. There is no background goroutine,
+// one of the waiting requests takes the lead to process the pending requests, and
+// hands over the lead to another one after its own request is done.
+type tsoBatcher struct {
+	dcLocation string
+	generate   func(count uint32) (pdpb.Timestamp, error)
+
+	mu      sync.Mutex
+	pending [priorityCount][]*batchRequest
+	// leading is true if there is a request processing the batches.
+	leading bool
+	// stopped is true if the batcher has been removed from the allocator manager,
+	// the new requests can't join it and retry with a new batcher.
+	stopped bool
+}
+
+func newTSOBatcher(dcLocation string, generate func(count uint32) (pdpb.Timestamp, error)) *tsoBatcher {
+	return &tsoBatcher{
+		dcLocation: dcLocation,
+		generate:   generate,
+	}
+}
+
+// handle waits for the request to be processed in a batch. It returns false if
+// the batcher has been stopped before the request joins it.
+func (b *tsoBatcher) handle(count uint32, priority RequestPriority) (pdpb.Timestamp, bool, error) {
+	if priority < 0 || priority >= priorityCount {
+		priority = PriorityNormal
+	}
+	req := &batchRequest{
+		count:    count,
+		priority: priority,
+		start:    time.Now(),
+		lead:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	b.mu.Lock()
+	if b.stopped {
+		b.mu.Unlock()
+		return pdpb.Timestamp{}, false, nil
+	}
+	b.pending[priority] = append(b.pending[priority], req)
+	if b.leading {
+		b.mu.Unlock()
+		select {
+		case <-req.done:
+			return req.ts, true, req.err
+		case <-req.lead:
+		}
+	} else {
+		b.leading = true
+		b.mu.Unlock()
+	}
+	b.lead(req)
+	return req.ts, true, req.err
+}
+
+// stop rejects the new requests, the requests which have joined are still
+// processed.
+func (b *tsoBatcher) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopped = true
+}
+
+// lead processes the pending batches until the given request is done.
+func (b *tsoBatcher) lead(req *batchRequest) {
+	for {
+		b.mu.Lock()
+		batch := b.nextBatchLocked()
+		b.mu.Unlock()
+		b.process(batch)
+		select {
+		case <-req.done:
+		default:
+			continue
+		}
+		b.mu.Lock()
+		next := b.peekLocked()
+		if next == nil {
+			b.leading = false
+		} else {
+			next.lead <- struct{}{}
+		}
+		b.mu.Unlock()
+		return
+	}
+}
+
+// nextBatchLocked takes the requests of the next batch from the pending queues
+// in priority order.
+func (b *tsoBatcher) nextBatchLocked() []*batchRequest {
+	var (
+		batch []*batchRequest
+		count uint32
+	)
+	for p := range b.pending {
+		queue := b.pending[p]
+		i := 0
+		for ; i < len(queue) && len(batch) < maxBatchSize; i++ {
+			if len(batch) > 0 && count+queue[i].count > maxBatchCount {
+				break
+			}
+			batch = append(batch, queue[i])
+			count += queue[i].count
+		}
+		b.pending[p] = queue[i:]
+		if i < len(queue) {
+			break
+		}
+	}
+	return batch
+}
+
+func (b *tsoBatcher) peekLocked() *batchRequest {
+	for _, queue := range b.pending {
+		if len(queue) > 0 {
+			return queue[0]
+		}
+	}
+	return nil
+}
+
+func (b *tsoBatcher) process(batch []*batchRequest) {
+	if len(batch) == 0 {
+		return
+	}
+	var count uint32
+	for _, req := range batch {
+		count += req.count
+	}
+	tsoBatchSize.WithLabelValues(b.dcLocation).Observe(float64(len(batch)))
+	ts, err := b.generate(count)
+	// The logical part of ts is the largest one in this batch, allocate the
+	// timestamps backwards from the end of the batch.
+	after := uint32(0)
+	for i := len(batch) - 1; i >= 0; i-- {
+		req := batch[i]
+		if err != nil {
+			req.err = err
+		} else {
+			req.ts = ts
+			req.ts.Logical = ts.GetLogical() - int64(after)<<ts.GetSuffixBits()
+			after += req.count
+		}
+		tsoBatchWaitDuration.WithLabelValues(b.dcLocation, req.priority.String()).Observe(time.Since(req.start).Seconds())
+		close(req.done)
+	}
+}
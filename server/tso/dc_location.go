@@ -0,0 +1,314 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tso
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/etcdutil"
+	"github.com/tikv/pd/pkg/slice"
+	"github.com/tikv/pd/pkg/tsoutil"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/server/election"
+	"github.com/tikv/pd/server/kv"
+	"go.etcd.io/etcd/clientv3"
+	"go.uber.org/zap"
+)
+
+// reclaimedLocalTSOSuffixEtcdPrefix is the etcd prefix of the suffixes released by the removed dc-locations.
+// The key is the suffix and the value is the time window of the removed dc-location, the suffix
+// can only be reused after the time window to make sure the new dc-location won't generate any
+// TSO which has been generated by the removed one.
+const reclaimedLocalTSOSuffixEtcdPrefix = "reclaimed-local-tso-suffix"
+
+// DCLocationDetail describes a dc-location of the cluster,
+// including its members, Local TSO suffix and allocator leader.
+type DCLocationDetail struct {
+	DCLocation string `json:"dc-location"`
+	// ServerIDs are the IDs of the members configured with this dc-location.
+	ServerIDs []uint64 `json:"server-ids"`
+	// Suffix is the Local TSO suffix persisted in etcd, 0 means it's not allocated yet.
+	Suffix int32 `json:"suffix"`
+	// AllocatorLeader is the member ID of the Local TSO Allocator leader, 0 means no leader.
+	AllocatorLeader uint64 `json:"allocator-leader"`
+}
+
+// GetDCLocationDetails returns the details of all dc-locations persisted in etcd, including
+// the drained dc-locations which have no member anymore but still hold their suffixes.
+func (am *AllocatorManager) GetDCLocationDetails() ([]*DCLocationDetail, error) {
+	clusterDCLocations, err := am.GetClusterDCLocationsFromEtcd()
+	if err != nil {
+		return nil, err
+	}
+	dcLocationSuffix, err := am.getDCLocationSuffixMapFromEtcd()
+	if err != nil {
+		return nil, err
+	}
+	details := make(map[string]*DCLocationDetail)
+	getDetail := func(dcLocation string) *DCLocationDetail {
+		if _, ok := details[dcLocation]; !ok {
+			details[dcLocation] = &DCLocationDetail{DCLocation: dcLocation, ServerIDs: []uint64{}}
+		}
+		return details[dcLocation]
+	}
+	for dcLocation, serverIDs := range clusterDCLocations {
+		detail := getDetail(dcLocation)
+		detail.ServerIDs = append(detail.ServerIDs, serverIDs...)
+		sort.Slice(detail.ServerIDs, func(i, j int) bool { return detail.ServerIDs[i] < detail.ServerIDs[j] })
+	}
+	for dcLocation, suffix := range dcLocationSuffix {
+		getDetail(dcLocation).Suffix = suffix
+	}
+	result := make([]*DCLocationDetail, 0, len(details))
+	for dcLocation, detail := range details {
+		if allocatorGroup, ok := am.getAllocatorGroup(dcLocation); ok {
+			detail.AllocatorLeader = allocatorGroup.allocator.(*LocalTSOAllocator).GetAllocatorLeader().GetMemberId()
+		}
+		result = append(result, detail)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].DCLocation < result[j].DCLocation })
+	return result, nil
+}
+
+// MigrateDCLocation moves the given members from the source dc-location to the target one.
+// All members of the source dc-location will be moved if serverIDs is empty. The Local TSO
+// Allocator of the source dc-location will be handed over to its remaining members by the
+// PriorityChecker, or be stopped if no member is left.
+// Note that a member will write its zone label as dc-location again after restarting,
+// so its configuration should be changed as well to make the migration persistent.
+func (am *AllocatorManager) MigrateDCLocation(source, target string, serverIDs []uint64) error {
+	if target == "" || target == GlobalDCLocation {
+		return errs.ErrMigrateDCLocation.FastGenByArgs(fmt.Sprintf("invalid target dc-location %q", target))
+	}
+	if source == target {
+		return errs.ErrMigrateDCLocation.FastGenByArgs("the source and target dc-location are the same")
+	}
+	clusterDCLocations, err := am.GetClusterDCLocationsFromEtcd()
+	if err != nil {
+		return err
+	}
+	members, ok := clusterDCLocations[source]
+	if !ok {
+		return errs.ErrDCLocationNotFound.FastGenByArgs(source)
+	}
+	if len(serverIDs) == 0 {
+		serverIDs = members
+	}
+	for _, serverID := range serverIDs {
+		if slice.NoneOf(members, func(i int) bool { return members[i] == serverID }) {
+			return errs.ErrMigrateDCLocation.FastGenByArgs(fmt.Sprintf("member %d does not belong to dc-location %s", serverID, source))
+		}
+	}
+	if err := am.checkDCLocationUpperLimit(target); err != nil {
+		return err
+	}
+	cmps := make([]clientv3.Cmp, 0, len(serverIDs))
+	ops := make([]clientv3.Op, 0, len(serverIDs))
+	for _, serverID := range serverIDs {
+		dcLocationKey := am.member.GetDCLocationPath(serverID)
+		cmps = append(cmps, clientv3.Compare(clientv3.Value(dcLocationKey), "=", source))
+		ops = append(ops, clientv3.OpPut(dcLocationKey, target))
+	}
+	resp, err := am.member.GetLeadership().LeaderTxn(cmps...).Then(ops...).Commit()
+	if err != nil {
+		return errs.ErrEtcdTxnInternal.Wrap(err).GenWithStackByCause()
+	}
+	if !resp.Succeeded {
+		return errs.ErrEtcdTxnConflict.FastGenByArgs()
+	}
+	log.Info("migrate dc-location members",
+		zap.String("source-dc-location", source),
+		zap.String("target-dc-location", target),
+		zap.Uint64s("server-ids", serverIDs))
+	go am.ClusterDCLocationChecker()
+	return nil
+}
+
+// RemoveDCLocation retires a dc-location which has no member and no Local TSO Allocator leader
+// anymore. A migrated member may still hold the allocator leadership for a while and keep
+// extending the time window, so the removal is rejected until the leader steps down. Before
+// removing it, the time window of its Local TSO Allocator will be synced into the Global TSO
+// Allocator to make sure the Global TSO is always greater than any Local TSO it has generated.
+// Its suffix will be reclaimed and reused by a new dc-location after the time window passes.
+// It should only be called by the PD leader.
+func (am *AllocatorManager) RemoveDCLocation(dcLocation string) error {
+	if dcLocation == "" || dcLocation == GlobalDCLocation {
+		return errs.ErrRemoveDCLocation.FastGenByArgs(fmt.Sprintf("invalid dc-location %q", dcLocation))
+	}
+	if !am.member.IsLeader() {
+		return errs.ErrLeaderNil.FastGenByArgs()
+	}
+	clusterDCLocations, err := am.GetClusterDCLocationsFromEtcd()
+	if err != nil {
+		return err
+	}
+	if serverIDs := clusterDCLocations[dcLocation]; len(serverIDs) > 0 {
+		return errs.ErrDCLocationNotEmpty.FastGenByArgs(dcLocation, len(serverIDs))
+	}
+	dcLocationSuffix, err := am.getDCLocationSuffixMapFromEtcd()
+	if err != nil {
+		return err
+	}
+	suffix, ok := dcLocationSuffix[dcLocation]
+	if !ok {
+		return errs.ErrDCLocationNotFound.FastGenByArgs(dcLocation)
+	}
+	// The time window can't be extended once there is no allocator leader, the
+	// txn below makes sure no new leader is elected in the meantime.
+	leaderKey := am.getAllocatorPath(dcLocation)
+	leader, _, err := election.GetLeader(am.member.Client(), leaderKey)
+	if err != nil {
+		return err
+	}
+	if leader != nil {
+		return errs.ErrDCLocationHasLeader.FastGenByArgs(dcLocation, leader.GetMemberId())
+	}
+	// Any Local TSO generated by this dc-location is less than its time window.
+	timestampPath := path.Join(am.getAllocatorPath(dcLocation), timestampKey)
+	value, err := etcdutil.GetValue(am.member.Client(), timestampPath)
+	if err != nil {
+		return err
+	}
+	window := time.Now()
+	if len(value) != 0 {
+		savedWindow, err := typeutil.ParseTimestamp(value)
+		if err != nil {
+			return err
+		}
+		if savedWindow.After(window) {
+			window = savedWindow
+		}
+		if err := am.syncWindowToGlobalAllocator(savedWindow); err != nil {
+			return err
+		}
+	}
+	suffixKey := am.GetLocalTSOSuffixPath(dcLocation)
+	resp, err := am.member.GetLeadership().LeaderTxn(
+		clientv3.Compare(clientv3.Value(suffixKey), "=", strconv.FormatInt(int64(suffix), 10)),
+		clientv3.Compare(clientv3.CreateRevision(leaderKey), "=", 0)).
+		Then(
+			clientv3.OpDelete(suffixKey),
+			clientv3.OpDelete(timestampPath),
+			clientv3.OpDelete(am.nextLeaderKey(dcLocation)),
+			clientv3.OpPut(am.getReclaimedLocalTSOSuffixPath(suffix), string(typeutil.Uint64ToBytes(uint64(window.UnixNano())))),
+		).Commit()
+	if err != nil {
+		return errs.ErrEtcdTxnInternal.Wrap(err).GenWithStackByCause()
+	}
+	if !resp.Succeeded {
+		return errs.ErrEtcdTxnConflict.FastGenByArgs()
+	}
+	am.mu.Lock()
+	delete(am.mu.clusterDCLocations, dcLocation)
+	am.mu.Unlock()
+	am.deleteAllocatorGroup(dcLocation)
+	log.Info("remove dc-location and reclaim its local tso suffix",
+		zap.String("dc-location", dcLocation),
+		zap.Int32("suffix", suffix),
+		zap.Time("reusable-after", window.Add(am.saveInterval)))
+	return nil
+}
+
+// syncWindowToGlobalAllocator makes the Global TSO not less than the given time window.
+func (am *AllocatorManager) syncWindowToGlobalAllocator(window time.Time) error {
+	allocator, err := am.GetAllocator(GlobalDCLocation)
+	if err != nil {
+		return err
+	}
+	globalAllocator := allocator.(*GlobalTSOAllocator)
+	ts := tsoutil.GenerateTS(tsoutil.GenerateTimestamp(window, 0))
+	return globalAllocator.timestampOracle.resetUserTimestamp(globalAllocator.leadership, ts, true)
+}
+
+func (am *AllocatorManager) getReclaimedLocalTSOSuffixPath(suffix int32) string {
+	return path.Join(am.rootPath, reclaimedLocalTSOSuffixEtcdPrefix, strconv.FormatInt(int64(suffix), 10))
+}
+
+// getReclaimedLocalTSOSuffixesFromEtcd returns the reclaimed suffixes with the time windows of their removed dc-locations.
+func (am *AllocatorManager) getReclaimedLocalTSOSuffixesFromEtcd() (map[int32]time.Time, error) {
+	resp, err := etcdutil.EtcdKVGet(
+		am.member.Client(),
+		path.Join(am.rootPath, reclaimedLocalTSOSuffixEtcdPrefix)+"/",
+		clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	reclaimedSuffixes := make(map[int32]time.Time)
+	for _, kv := range resp.Kvs {
+		splittedKey := strings.Split(string(kv.Key), "/")
+		suffix, err := strconv.ParseInt(splittedKey[len(splittedKey)-1], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		window, err := typeutil.ParseTimestamp(kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		reclaimedSuffixes[int32(suffix)] = window
+	}
+	return reclaimedSuffixes, nil
+}
+
+// pickReclaimedLocalTSOSuffix returns the smallest reclaimed suffix whose time window has passed.
+// The save interval is also waited to tolerate the clock drift between PD servers.
+func (am *AllocatorManager) pickReclaimedLocalTSOSuffix(reclaimedSuffixes map[int32]time.Time) (int32, bool) {
+	var (
+		picked int32
+		found  bool
+		now    = time.Now()
+	)
+	for suffix, window := range reclaimedSuffixes {
+		if now.Before(window.Add(am.saveInterval)) {
+			continue
+		}
+		if !found || suffix < picked {
+			picked, found = suffix, true
+		}
+	}
+	return picked, found
+}
+
+// reuseLocalTSOSuffix assigns a reclaimed suffix to the given dc-location.
+func (am *AllocatorManager) reuseLocalTSOSuffix(dcLocation string, suffix int32) (int32, error) {
+	localTSOSuffixKey := am.GetLocalTSOSuffixPath(dcLocation)
+	reclaimedSuffixKey := am.getReclaimedLocalTSOSuffixPath(suffix)
+	txnResp, err := kv.NewSlowLogTxn(am.member.Client()).
+		If(
+			clientv3.Compare(clientv3.CreateRevision(localTSOSuffixKey), "=", 0),
+			clientv3.Compare(clientv3.CreateRevision(reclaimedSuffixKey), ">", 0),
+		).
+		Then(
+			clientv3.OpPut(localTSOSuffixKey, strconv.FormatInt(int64(suffix), 10)),
+			clientv3.OpDelete(reclaimedSuffixKey),
+		).
+		Commit()
+	if err != nil {
+		return -1, errs.ErrEtcdTxnInternal.Wrap(err).GenWithStackByCause()
+	}
+	if !txnResp.Succeeded {
+		log.Warn("reuse reclaimed local tso suffix failed",
+			zap.String("dc-location", dcLocation),
+			zap.Int32("local-tso-suffix", suffix))
+		return -1, errs.ErrEtcdTxnConflict.FastGenByArgs()
+	}
+	log.Info("reuse reclaimed local tso suffix", zap.String("dc-location", dcLocation), zap.Int32("local-tso-suffix", suffix))
+	return suffix, nil
+}
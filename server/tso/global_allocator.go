@@ -50,6 +50,8 @@ type Allocator interface {
 	GenerateTSO(count uint32) (pdpb.Timestamp, error)
 	// Reset is used to reset the TSO allocator.
 	Reset()
+	// GetHealthStatus returns the health status of the TSO allocator.
+	GetHealthStatus() *HealthStatus
 }
 
 // GlobalTSOAllocator is the global single point TSO allocator.
@@ -80,6 +82,7 @@ func NewGlobalTSOAllocator(
 			saveInterval:           am.saveInterval,
 			updatePhysicalInterval: am.updatePhysicalInterval,
 			maxResetTSGap:          am.maxResetTSGap,
+			clockLagPolicy:         am.clockLagPolicy,
 			dcLocation:             GlobalDCLocation,
 			tsoMux:                 &tsoObject{},
 		},
@@ -169,6 +172,9 @@ func (gta *GlobalTSOAllocator) GenerateTSO(count uint32) (pdpb.Timestamp, error)
 
 	// Have dc-locations configured in the cluster, use the Global TSO generation way.
 	// (whit synchronization with other Local TSO Allocators)
+	if err := gta.timestampOracle.checkClockLag(); err != nil {
+		return pdpb.Timestamp{}, err
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	for i := 0; i < maxRetryCount; i++ {
@@ -440,3 +446,8 @@ func (gta *GlobalTSOAllocator) Reset() {
 	tsoAllocatorRole.WithLabelValues(gta.timestampOracle.dcLocation).Set(0)
 	gta.timestampOracle.ResetTimestamp()
 }
+
+// GetHealthStatus returns the health status of the TSO allocator.
+func (gta *GlobalTSOAllocator) GetHealthStatus() *HealthStatus {
+	return gta.timestampOracle.getHealthStatus()
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tso
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tikv/pd/pkg/typeutil"
+)
+
+// HealthStatus is the health status of a TSO allocator.
+type HealthStatus struct {
+	DCLocation string    `json:"dc_location"`
+	Physical   time.Time `json:"physical"`
+	Logical    int64     `json:"logical"`
+	// Drift is the physical time of TSO minus the system time. A positive value
+	// means the system clock lags behind the TSO.
+	Drift typeutil.Duration `json:"drift"`
+	// LastSavedTime is the upper bound of the time window persisted in etcd.
+	LastSavedTime time.Time `json:"last_saved_time"`
+	// SaveWindowHeadroom is how far the physical time can go before the time
+	// window needs to be saved again.
+	SaveWindowHeadroom typeutil.Duration `json:"save_window_headroom"`
+	// LogicalUsage is the ratio of the logical time consumed in the last update interval.
+	LogicalUsage float64 `json:"logical_usage"`
+	// UpdateLatency is the time cost of the last timestamp update.
+	UpdateLatency typeutil.Duration `json:"update_latency"`
+	// MaxUpdateLatency is the max time cost of the timestamp updates since the allocator is initialized.
+	MaxUpdateLatency typeutil.Duration `json:"max_update_latency"`
+	ClockLagPolicy   string            `json:"clock_lag_policy"`
+	// ClockLagging is true when the system clock lags behind the persisted TSO window
+	// more than the save interval.
+	ClockLagging bool `json:"clock_lagging"`
+}
+
+// tsoHealth records the health info collected when updating the timestamp.
+type tsoHealth struct {
+	sync.RWMutex
+	// the TSO seen by the last update, used to calculate the logical usage.
+	lastPhysical     time.Time
+	lastLogical      int64
+	logicalUsage     float64
+	updateLatency    time.Duration
+	maxUpdateLatency time.Duration
+	clockLagging     bool
+}
+
+func (h *tsoHealth) observeUpdate(physical time.Time, logical int64, clockLagging bool) {
+	h.Lock()
+	defer h.Unlock()
+	used := logical
+	// The logical time is not reset if the physical time is not increased.
+	if physical.Equal(h.lastPhysical) && logical >= h.lastLogical {
+		used = logical - h.lastLogical
+	}
+	h.lastPhysical, h.lastLogical = physical, logical
+	h.logicalUsage = float64(used) / float64(maxLogical)
+	h.clockLagging = clockLagging
+}
+
+func (h *tsoHealth) observeUpdateLatency(latency time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+	h.updateLatency = latency
+	if latency > h.maxUpdateLatency {
+		h.maxUpdateLatency = latency
+	}
+}
+
+func (h *tsoHealth) getLogicalUsage() float64 {
+	h.RLock()
+	defer h.RUnlock()
+	return h.logicalUsage
+}
+
+func (h *tsoHealth) isClockLagging() bool {
+	h.RLock()
+	defer h.RUnlock()
+	return h.clockLagging
+}
+
+func (h *tsoHealth) reset() {
+	h.Lock()
+	defer h.Unlock()
+	h.lastPhysical, h.lastLogical = typeutil.ZeroTime, 0
+	h.logicalUsage = 0
+	h.updateLatency, h.maxUpdateLatency = 0, 0
+	h.clockLagging = false
+}
+
+func (t *timestampOracle) getHealthStatus() *HealthStatus {
+	physical, logical := t.getTSO()
+	status := &HealthStatus{
+		DCLocation:     t.dcLocation,
+		Physical:       physical,
+		Logical:        logical,
+		ClockLagPolicy: t.clockLagPolicy,
+	}
+	if physical != typeutil.ZeroTime {
+		status.Drift = typeutil.NewDuration(typeutil.SubRealTimeByWallClock(physical, time.Now()))
+	}
+	if lastSavedTime, ok := t.lastSavedTime.Load().(time.Time); ok {
+		status.LastSavedTime = lastSavedTime
+		if physical != typeutil.ZeroTime {
+			status.SaveWindowHeadroom = typeutil.NewDuration(typeutil.SubRealTimeByWallClock(lastSavedTime, physical))
+		}
+	}
+	t.health.RLock()
+	defer t.health.RUnlock()
+	status.LogicalUsage = t.health.logicalUsage
+	status.UpdateLatency = typeutil.NewDuration(t.health.updateLatency)
+	status.MaxUpdateLatency = typeutil.NewDuration(t.health.maxUpdateLatency)
+	status.ClockLagging = t.health.clockLagging
+	return status
+}
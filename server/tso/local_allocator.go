@@ -61,6 +61,7 @@ func NewLocalTSOAllocator(
 			saveInterval:           am.saveInterval,
 			updatePhysicalInterval: am.updatePhysicalInterval,
 			maxResetTSGap:          am.maxResetTSGap,
+			clockLagPolicy:         am.clockLagPolicy,
 			dcLocation:             dcLocation,
 			tsoMux:                 &tsoObject{},
 		},
@@ -106,6 +107,11 @@ func (lta *LocalTSOAllocator) GenerateTSO(count uint32) (pdpb.Timestamp, error)
 	return lta.timestampOracle.getTS(lta.leadership, count, lta.allocatorManager.GetSuffixBits())
 }
 
+// GetHealthStatus returns the health status of the TSO allocator.
+func (lta *LocalTSOAllocator) GetHealthStatus() *HealthStatus {
+	return lta.timestampOracle.getHealthStatus()
+}
+
 // Reset is used to reset the TSO allocator.
 func (lta *LocalTSOAllocator) Reset() {
 	tsoAllocatorRole.WithLabelValues(lta.timestampOracle.dcLocation).Set(0)
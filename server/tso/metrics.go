@@ -45,6 +45,41 @@ var (
 			Help:      "The minimal (non-zero) TSO gap for each DC.",
 		}, []string{dcLabel})
 
+	tsoHealthGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "tso",
+			Name:      "health",
+			Help:      "Record of tso health status, such as the drift against the system clock.",
+		}, []string{typeLabel, dcLabel})
+
+	tsoUpdateDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "pd",
+			Subsystem: "tso",
+			Name:      "update_duration_seconds",
+			Help:      "Bucketed histogram of time cost of updating the timestamp.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 13),
+		}, []string{dcLabel})
+
+	tsoBatchSize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "pd",
+			Subsystem: "tso",
+			Name:      "batch_size",
+			Help:      "Bucketed histogram of the number of requests coalesced in a batch.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 14),
+		}, []string{dcLabel})
+
+	tsoBatchWaitDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "pd",
+			Subsystem: "tso",
+			Name:      "batch_wait_duration_seconds",
+			Help:      "Bucketed histogram of time cost of the batched requests, including the queueing time.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 16),
+		}, []string{dcLabel, "priority"})
+
 	tsoAllocatorRole = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: "pd",
@@ -58,5 +93,9 @@ func init() {
 	prometheus.MustRegister(tsoCounter)
 	prometheus.MustRegister(tsoGauge)
 	prometheus.MustRegister(tsoGap)
+	prometheus.MustRegister(tsoHealthGauge)
+	prometheus.MustRegister(tsoUpdateDuration)
+	prometheus.MustRegister(tsoBatchSize)
+	prometheus.MustRegister(tsoBatchWaitDuration)
 	prometheus.MustRegister(tsoAllocatorRole)
 }
@@ -28,6 +28,7 @@ import (
 	"github.com/tikv/pd/pkg/etcdutil"
 	"github.com/tikv/pd/pkg/tsoutil"
 	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/election"
 	"go.etcd.io/etcd/clientv3"
 	"go.uber.org/zap"
@@ -51,6 +52,8 @@ type tsoObject struct {
 	physical   time.Time
 	logical    int64
 	updateTime time.Time
+	// physicalUpdateTime is the last time when the physical time is changed.
+	physicalUpdateTime time.Time
 }
 
 // timestampOracle is used to maintain the logic of TSO.
@@ -61,12 +64,15 @@ type timestampOracle struct {
 	saveInterval           time.Duration
 	updatePhysicalInterval time.Duration
 	maxResetTSGap          func() time.Duration
+	clockLagPolicy         string
 	// tso info stored in the memory
 	tsoMux *tsoObject
 	// last timestamp window stored in etcd
 	lastSavedTime atomic.Value // stored as time.Time
 	suffix        int
 	dcLocation    string
+	// health info collected when updating the timestamp
+	health tsoHealth
 }
 
 func (t *timestampOracle) setTSOPhysical(next time.Time) {
@@ -76,10 +82,17 @@ func (t *timestampOracle) setTSOPhysical(next time.Time) {
 	if typeutil.SubTSOPhysicalByWallClock(next, t.tsoMux.physical) > 0 {
 		t.tsoMux.physical = next
 		t.tsoMux.logical = 0
-		t.setTSOUpdateTimeLocked(time.Now())
+		t.tsoMux.physicalUpdateTime = time.Now()
+		t.setTSOUpdateTimeLocked(t.tsoMux.physicalUpdateTime)
 	}
 }
 
+func (t *timestampOracle) getPhysicalUpdateTime() time.Time {
+	t.tsoMux.RLock()
+	defer t.tsoMux.RUnlock()
+	return t.tsoMux.physicalUpdateTime
+}
+
 func (t *timestampOracle) setTSOUpdateTimeLocked(updateTime time.Time) {
 	t.tsoMux.updateTime = updateTime
 	tsoGauge.WithLabelValues("tso_update_time", t.dcLocation).Set(float64(updateTime.UnixNano() / int64(time.Millisecond)))
@@ -200,12 +213,15 @@ func (t *timestampOracle) SyncTimestamp(leadership *election.Leadership) error {
 	failpoint.Inject("systemTimeSlow", func() {
 		next = next.Add(-time.Hour)
 	})
+	now := next
 	// If the current system time minus the saved etcd timestamp is less than `UpdateTimestampGuard`,
 	// the timestamp allocation will start from the saved etcd timestamp temporarily.
 	if typeutil.SubRealTimeByWallClock(next, last) < UpdateTimestampGuard {
 		log.Error("system time may be incorrect", zap.Time("last", last), zap.Time("next", next), errs.ZapError(errs.ErrIncorrectSystemTime))
 		next = last.Add(UpdateTimestampGuard)
 	}
+	t.health.reset()
+	t.health.observeUpdate(next, 0, t.isClockLagging(next, now))
 
 	save := next.Add(t.saveInterval)
 	if err = t.saveTimestamp(leadership, save); err != nil {
@@ -277,7 +293,8 @@ func (t *timestampOracle) resetUserTimestamp(leadership *election.Leadership, ts
 	// save into memory only if nextPhysical or nextLogical is greater.
 	t.tsoMux.physical = nextPhysical
 	t.tsoMux.logical = int64(nextLogical)
-	t.setTSOUpdateTimeLocked(time.Now())
+	t.tsoMux.physicalUpdateTime = time.Now()
+	t.setTSOUpdateTimeLocked(t.tsoMux.physicalUpdateTime)
 	tsoCounter.WithLabelValues("reset_tso_ok", t.dcLocation).Inc()
 	return nil
 }
@@ -294,6 +311,12 @@ func (t *timestampOracle) resetUserTimestamp(leadership *election.Leadership, ts
 // 2. The physical time is monotonically increasing.
 // 3. The physical time is always less than the saved timestamp.
 func (t *timestampOracle) UpdateTimestamp(leadership *election.Leadership) error {
+	start := time.Now()
+	defer func() {
+		latency := time.Since(start)
+		t.health.observeUpdateLatency(latency)
+		tsoUpdateDuration.WithLabelValues(t.dcLocation).Observe(latency.Seconds())
+	}()
 	prevPhysical, prevLogical := t.getTSO()
 	tsoGauge.WithLabelValues("tso", t.dcLocation).Set(float64(prevPhysical.UnixNano() / int64(time.Millisecond)))
 	tsoGap.WithLabelValues(t.dcLocation).Set(float64(time.Since(prevPhysical).Milliseconds()))
@@ -318,10 +341,17 @@ func (t *timestampOracle) UpdateTimestamp(leadership *election.Leadership) error
 		tsoCounter.WithLabelValues("system_time_slow", t.dcLocation).Inc()
 	}
 
+	clockLagging := t.isClockLagging(prevPhysical, now)
+	t.observeHealth(prevPhysical, prevLogical, clockLagging, -jetLag)
+
 	var next time.Time
 	// If the system time is greater, it will be synchronized with the system time.
 	if jetLag > UpdateTimestampGuard {
 		next = now
+	} else if elapsed := time.Since(t.getPhysicalUpdateTime()); clockLagging && t.clockLagPolicy == config.TSOClockLagPolicyContinue && elapsed > UpdateTimestampGuard {
+		// Keep the pace with the real time even if the system clock lags behind.
+		tsoCounter.WithLabelValues("clock_lag_continue", t.dcLocation).Inc()
+		next = prevPhysical.Add(elapsed)
 	} else if prevLogical > maxLogical/2 {
 		// The reason choosing maxLogical/2 here is that it's big enough for common cases.
 		// Because there is enough timestamp can be allocated before next update.
@@ -348,6 +378,36 @@ func (t *timestampOracle) UpdateTimestamp(leadership *election.Leadership) error
 	return nil
 }
 
+// isClockLagging checks whether the system clock lags behind the TSO more than the save interval.
+// It can't be caused by the normal leader changes, as a new leader starts from the time
+// window saved by the previous leader, which is at most `saveInterval` ahead.
+func (t *timestampOracle) isClockLagging(physical, now time.Time) bool {
+	return typeutil.SubRealTimeByWallClock(physical, now) > t.saveInterval
+}
+
+func (t *timestampOracle) observeHealth(physical time.Time, logical int64, clockLagging bool, drift time.Duration) {
+	t.health.observeUpdate(physical, logical, clockLagging)
+	tsoHealthGauge.WithLabelValues("drift_ms", t.dcLocation).Set(float64(drift.Milliseconds()))
+	tsoHealthGauge.WithLabelValues("logical_usage", t.dcLocation).Set(t.health.getLogicalUsage())
+	if lastSavedTime, ok := t.lastSavedTime.Load().(time.Time); ok {
+		tsoHealthGauge.WithLabelValues("save_window_headroom_ms", t.dcLocation).Set(float64(typeutil.SubRealTimeByWallClock(lastSavedTime, physical).Milliseconds()))
+	}
+	if clockLagging {
+		tsoHealthGauge.WithLabelValues("clock_lagging", t.dcLocation).Set(1)
+	} else {
+		tsoHealthGauge.WithLabelValues("clock_lagging", t.dcLocation).Set(0)
+	}
+}
+
+// checkClockLag returns an error if TSO should not be allocated due to the lagging system clock.
+func (t *timestampOracle) checkClockLag() error {
+	if t.clockLagPolicy == config.TSOClockLagPolicyRefuse && t.health.isClockLagging() {
+		tsoCounter.WithLabelValues("clock_lag_refuse", t.dcLocation).Inc()
+		return errs.ErrGenerateTimestamp.FastGenByArgs("system clock lags behind the tso, please check ntp time")
+	}
+	return nil
+}
+
 var maxRetryCount = 10
 
 // getTS is used to get a timestamp.
@@ -356,6 +416,9 @@ func (t *timestampOracle) getTS(leadership *election.Leadership, count uint32, s
 	if count == 0 {
 		return resp, errs.ErrGenerateTimestamp.FastGenByArgs("tso count should be positive")
 	}
+	if err := t.checkClockLag(); err != nil {
+		return resp, err
+	}
 	for i := 0; i < maxRetryCount; i++ {
 		currentPhysical, currentLogical := t.getTSO()
 		if currentPhysical == typeutil.ZeroTime {
@@ -403,5 +466,7 @@ func (t *timestampOracle) ResetTimestamp() {
 	log.Info("reset the timestamp in memory")
 	t.tsoMux.physical = typeutil.ZeroTime
 	t.tsoMux.logical = 0
+	t.tsoMux.physicalUpdateTime = typeutil.ZeroTime
 	t.setTSOUpdateTimeLocked(typeutil.ZeroTime)
+	t.health.reset()
 }
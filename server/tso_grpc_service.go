@@ -0,0 +1,234 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/server/tso"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// tsoGrpcServer wraps TSOServer to provide the TSO service over the PD gRPC service.
+// Only GetMembers and Tso are supported, the other requests should be sent to PD.
+type tsoGrpcServer struct {
+	*TSOServer
+}
+
+func (s *tsoGrpcServer) header() *pdpb.ResponseHeader {
+	return &pdpb.ResponseHeader{ClusterId: s.clusterID}
+}
+
+func notSupportedByTSOServer(method string) error {
+	return status.Errorf(codes.Unimplemented, "%s is not supported by the tso server", method)
+}
+
+// GetMembers implements gRPC PDServer. It returns the alive TSO servers and the TSO leader.
+func (s *tsoGrpcServer) GetMembers(context.Context, *pdpb.GetMembersRequest) (*pdpb.GetMembersResponse, error) {
+	if s.IsClosed() {
+		return nil, status.Errorf(codes.Unknown, "server not started")
+	}
+	members, err := s.getMembers()
+	if err != nil {
+		return nil, status.Errorf(codes.Unknown, err.Error())
+	}
+	return &pdpb.GetMembersResponse{
+		Header:  s.header(),
+		Members: members,
+		Leader:  s.member.GetLeader(),
+	}, nil
+}
+
+// Tso implements gRPC PDServer.
+func (s *tsoGrpcServer) Tso(stream pdpb.PD_TsoServer) error {
+	priority := tso.ParseRequestPriority(getTSOPriority(stream.Context()))
+	for {
+		request, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		start := time.Now()
+		if s.IsClosed() {
+			return status.Errorf(codes.Unknown, "server not started")
+		}
+		if request.GetHeader().GetClusterId() != s.clusterID {
+			return status.Errorf(codes.FailedPrecondition, "mismatch cluster id, need %d but got %d", s.clusterID, request.GetHeader().GetClusterId())
+		}
+		count := request.GetCount()
+		ts, err := s.tsoAllocatorManager.HandleTSORequestWithPriority(request.GetDcLocation(), count, priority)
+		if err != nil {
+			return status.Errorf(codes.Unknown, err.Error())
+		}
+
+		elapsed := time.Since(start)
+		if elapsed > slowThreshold {
+			log.Warn("get timestamp too slow", zap.Duration("cost", elapsed))
+		}
+		tsoHandleDuration.Observe(elapsed.Seconds())
+		response := &pdpb.TsoResponse{
+			Header:    s.header(),
+			Timestamp: &ts,
+			Count:     count,
+		}
+		if err := stream.Send(response); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+}
+
+// Bootstrap implements gRPC PDServer.
+func (s *tsoGrpcServer) Bootstrap(context.Context, *pdpb.BootstrapRequest) (*pdpb.BootstrapResponse, error) {
+	return nil, notSupportedByTSOServer("Bootstrap")
+}
+
+// IsBootstrapped implements gRPC PDServer.
+func (s *tsoGrpcServer) IsBootstrapped(context.Context, *pdpb.IsBootstrappedRequest) (*pdpb.IsBootstrappedResponse, error) {
+	return nil, notSupportedByTSOServer("IsBootstrapped")
+}
+
+// AllocID implements gRPC PDServer.
+func (s *tsoGrpcServer) AllocID(context.Context, *pdpb.AllocIDRequest) (*pdpb.AllocIDResponse, error) {
+	return nil, notSupportedByTSOServer("AllocID")
+}
+
+// GetStore implements gRPC PDServer.
+func (s *tsoGrpcServer) GetStore(context.Context, *pdpb.GetStoreRequest) (*pdpb.GetStoreResponse, error) {
+	return nil, notSupportedByTSOServer("GetStore")
+}
+
+// PutStore implements gRPC PDServer.
+func (s *tsoGrpcServer) PutStore(context.Context, *pdpb.PutStoreRequest) (*pdpb.PutStoreResponse, error) {
+	return nil, notSupportedByTSOServer("PutStore")
+}
+
+// GetAllStores implements gRPC PDServer.
+func (s *tsoGrpcServer) GetAllStores(context.Context, *pdpb.GetAllStoresRequest) (*pdpb.GetAllStoresResponse, error) {
+	return nil, notSupportedByTSOServer("GetAllStores")
+}
+
+// StoreHeartbeat implements gRPC PDServer.
+func (s *tsoGrpcServer) StoreHeartbeat(context.Context, *pdpb.StoreHeartbeatRequest) (*pdpb.StoreHeartbeatResponse, error) {
+	return nil, notSupportedByTSOServer("StoreHeartbeat")
+}
+
+// RegionHeartbeat implements gRPC PDServer.
+func (s *tsoGrpcServer) RegionHeartbeat(pdpb.PD_RegionHeartbeatServer) error {
+	return notSupportedByTSOServer("RegionHeartbeat")
+}
+
+// GetRegion implements gRPC PDServer.
+func (s *tsoGrpcServer) GetRegion(context.Context, *pdpb.GetRegionRequest) (*pdpb.GetRegionResponse, error) {
+	return nil, notSupportedByTSOServer("GetRegion")
+}
+
+// GetPrevRegion implements gRPC PDServer.
+func (s *tsoGrpcServer) GetPrevRegion(context.Context, *pdpb.GetRegionRequest) (*pdpb.GetRegionResponse, error) {
+	return nil, notSupportedByTSOServer("GetPrevRegion")
+}
+
+// GetRegionByID implements gRPC PDServer.
+func (s *tsoGrpcServer) GetRegionByID(context.Context, *pdpb.GetRegionByIDRequest) (*pdpb.GetRegionResponse, error) {
+	return nil, notSupportedByTSOServer("GetRegionByID")
+}
+
+// ScanRegions implements gRPC PDServer.
+func (s *tsoGrpcServer) ScanRegions(context.Context, *pdpb.ScanRegionsRequest) (*pdpb.ScanRegionsResponse, error) {
+	return nil, notSupportedByTSOServer("ScanRegions")
+}
+
+// AskSplit implements gRPC PDServer.
+func (s *tsoGrpcServer) AskSplit(context.Context, *pdpb.AskSplitRequest) (*pdpb.AskSplitResponse, error) {
+	return nil, notSupportedByTSOServer("AskSplit")
+}
+
+// ReportSplit implements gRPC PDServer.
+func (s *tsoGrpcServer) ReportSplit(context.Context, *pdpb.ReportSplitRequest) (*pdpb.ReportSplitResponse, error) {
+	return nil, notSupportedByTSOServer("ReportSplit")
+}
+
+// AskBatchSplit implements gRPC PDServer.
+func (s *tsoGrpcServer) AskBatchSplit(context.Context, *pdpb.AskBatchSplitRequest) (*pdpb.AskBatchSplitResponse, error) {
+	return nil, notSupportedByTSOServer("AskBatchSplit")
+}
+
+// ReportBatchSplit implements gRPC PDServer.
+func (s *tsoGrpcServer) ReportBatchSplit(context.Context, *pdpb.ReportBatchSplitRequest) (*pdpb.ReportBatchSplitResponse, error) {
+	return nil, notSupportedByTSOServer("ReportBatchSplit")
+}
+
+// GetClusterConfig implements gRPC PDServer.
+func (s *tsoGrpcServer) GetClusterConfig(context.Context, *pdpb.GetClusterConfigRequest) (*pdpb.GetClusterConfigResponse, error) {
+	return nil, notSupportedByTSOServer("GetClusterConfig")
+}
+
+// PutClusterConfig implements gRPC PDServer.
+func (s *tsoGrpcServer) PutClusterConfig(context.Context, *pdpb.PutClusterConfigRequest) (*pdpb.PutClusterConfigResponse, error) {
+	return nil, notSupportedByTSOServer("PutClusterConfig")
+}
+
+// ScatterRegion implements gRPC PDServer.
+func (s *tsoGrpcServer) ScatterRegion(context.Context, *pdpb.ScatterRegionRequest) (*pdpb.ScatterRegionResponse, error) {
+	return nil, notSupportedByTSOServer("ScatterRegion")
+}
+
+// GetGCSafePoint implements gRPC PDServer.
+func (s *tsoGrpcServer) GetGCSafePoint(context.Context, *pdpb.GetGCSafePointRequest) (*pdpb.GetGCSafePointResponse, error) {
+	return nil, notSupportedByTSOServer("GetGCSafePoint")
+}
+
+// UpdateGCSafePoint implements gRPC PDServer.
+func (s *tsoGrpcServer) UpdateGCSafePoint(context.Context, *pdpb.UpdateGCSafePointRequest) (*pdpb.UpdateGCSafePointResponse, error) {
+	return nil, notSupportedByTSOServer("UpdateGCSafePoint")
+}
+
+// UpdateServiceGCSafePoint implements gRPC PDServer.
+func (s *tsoGrpcServer) UpdateServiceGCSafePoint(context.Context, *pdpb.UpdateServiceGCSafePointRequest) (*pdpb.UpdateServiceGCSafePointResponse, error) {
+	return nil, notSupportedByTSOServer("UpdateServiceGCSafePoint")
+}
+
+// SyncRegions implements gRPC PDServer.
+func (s *tsoGrpcServer) SyncRegions(pdpb.PD_SyncRegionsServer) error {
+	return notSupportedByTSOServer("SyncRegions")
+}
+
+// GetOperator implements gRPC PDServer.
+func (s *tsoGrpcServer) GetOperator(context.Context, *pdpb.GetOperatorRequest) (*pdpb.GetOperatorResponse, error) {
+	return nil, notSupportedByTSOServer("GetOperator")
+}
+
+// SyncMaxTS implements gRPC PDServer.
+func (s *tsoGrpcServer) SyncMaxTS(context.Context, *pdpb.SyncMaxTSRequest) (*pdpb.SyncMaxTSResponse, error) {
+	return nil, notSupportedByTSOServer("SyncMaxTS")
+}
+
+// SplitRegions implements gRPC PDServer.
+func (s *tsoGrpcServer) SplitRegions(context.Context, *pdpb.SplitRegionsRequest) (*pdpb.SplitRegionsResponse, error) {
+	return nil, notSupportedByTSOServer("SplitRegions")
+}
+
+// GetDCLocationInfo implements gRPC PDServer.
+func (s *tsoGrpcServer) GetDCLocationInfo(context.Context, *pdpb.GetDCLocationInfoRequest) (*pdpb.GetDCLocationInfoResponse, error) {
+	return nil, notSupportedByTSOServer("GetDCLocationInfo")
+}
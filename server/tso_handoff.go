@@ -0,0 +1,203 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/etcdutil"
+	"github.com/tikv/pd/pkg/tsoutil"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/server/tso"
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/mvcc/mvccpb"
+	"go.uber.org/zap"
+)
+
+// The Global TSO is served by either the PD leader or the leader of the standalone
+// TSO servers, never both. The handoff goes as follows:
+//   1. The TSO leader is elected under /pd/{cluster_id}/tso_service/leader.
+//   2. The PD leader sees the TSO leader, stops its Global TSO Allocator and writes
+//      the create revision of the TSO leader key to the handoff key.
+//   3. The TSO leader starts serving after it sees the handoff of its own key, the
+//      time window of the PD leader won't move forward from then on.
+//   4. Once the TSO leader key is gone, which means the TSO leader has stepped down
+//      or its lease has expired, the PD leader initializes its Global TSO Allocator,
+//      loads the time window saved by the TSO leader under
+//      /pd/{cluster_id}/tso_service/timestamp and advances the allocator past it
+//      before it resumes serving.
+// The create revision is unique for every TSO leader, so a stale handoff can't be
+// used by a later TSO leader. The TSO doesn't fall back after a handoff as long as
+// each side only allocates the TSO below the time window it saved, and the windows
+// are no further apart than max-reset-ts-gap, otherwise the handoff fails and is
+// retried instead of serving a smaller TSO.
+
+const (
+	tsoHandoffKey = "handoff"
+	// tsoHandoffCheckInterval is the interval for the TSO leader to check the handoff.
+	tsoHandoffCheckInterval = 100 * time.Millisecond
+	// tsoHandoffRetryInterval is the interval to retry the handoff after failure.
+	tsoHandoffRetryInterval = time.Second
+)
+
+func getTSOServiceLeaderPath(rootPath string) string {
+	return path.Join(rootPath, tsoServiceRootPath, "leader")
+}
+
+func getTSOHandoffPath(rootPath string) string {
+	return path.Join(rootPath, tsoServiceRootPath, tsoHandoffKey)
+}
+
+// IsTSOServiceActive returns true if the Global TSO is handed over to the
+// standalone TSO servers.
+func (s *Server) IsTSOServiceActive() bool {
+	return atomic.LoadInt64(&s.tsoServiceRevision) != 0
+}
+
+// tsoHandoff switches the Global TSO between the PD leader and the TSO leader,
+// it's only used by the PD leader.
+type tsoHandoff struct {
+	s         *Server
+	allocator tso.Allocator
+	// serving is true if the Global TSO Allocator of the PD leader is initialized.
+	serving bool
+}
+
+// sync checks the TSO leader and switches the Global TSO accordingly, it returns
+// the revision of the check.
+func (h *tsoHandoff) sync() (int64, error) {
+	resp, err := etcdutil.EtcdKVGet(h.s.client, getTSOServiceLeaderPath(h.s.rootPath))
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Kvs) > 0 {
+		err = h.handOver(resp.Kvs[0].CreateRevision)
+	} else {
+		err = h.takeOver()
+	}
+	return resp.Header.Revision, err
+}
+
+// handOver stops the Global TSO Allocator of the PD leader and acknowledges the
+// TSO leader with the given create revision.
+func (h *tsoHandoff) handOver(revision int64) error {
+	if atomic.LoadInt64(&h.s.tsoServiceRevision) == revision {
+		return nil
+	}
+	// Stop serving before the acknowledgement.
+	atomic.StoreInt64(&h.s.tsoServiceRevision, revision)
+	if h.serving {
+		h.allocator.Reset()
+		h.serving = false
+	}
+	resp, err := h.s.member.GetLeadership().LeaderTxn().
+		Then(clientv3.OpPut(getTSOHandoffPath(h.s.rootPath), strconv.FormatInt(revision, 10))).
+		Commit()
+	if err != nil {
+		return errs.ErrEtcdKVPut.Wrap(err).GenWithStackByCause()
+	}
+	if !resp.Succeeded {
+		return errs.ErrEtcdTxnConflict.FastGenByArgs()
+	}
+	log.Info("hand over the global tso to the tso service", zap.Int64("tso-leader-revision", revision))
+	return nil
+}
+
+// takeOver initializes the Global TSO Allocator of the PD leader and advances it
+// past the time window saved by the TSO leader before serving.
+func (h *tsoHandoff) takeOver() error {
+	if !h.serving {
+		if err := h.allocator.Initialize(0); err != nil {
+			return err
+		}
+		window := path.Join(h.s.rootPath, tsoServiceRootPath, pdTimestampKey)
+		if err := syncTimestampWithWindow(h.s.client, window, h.allocator); err != nil {
+			h.allocator.Reset()
+			return err
+		}
+		h.serving = true
+	}
+	if atomic.SwapInt64(&h.s.tsoServiceRevision, 0) != 0 {
+		log.Info("take over the global tso from the tso service")
+	}
+	return nil
+}
+
+// syncTimestampWithWindow makes the TSO allocated by the allocator greater than
+// the time window saved at the given path, which is always ahead of the TSO
+// allocated by its owner.
+func syncTimestampWithWindow(client *clientv3.Client, windowPath string, allocator tso.Allocator) error {
+	value, err := etcdutil.GetValue(client, windowPath)
+	if err != nil || value == nil {
+		return err
+	}
+	window, err := typeutil.ParseTimestamp(value)
+	if err != nil {
+		return err
+	}
+	if !window.After(allocator.GetHealthStatus().Physical) {
+		return nil
+	}
+	log.Info("sync the timestamp with the saved time window", zap.String("path", windowPath), zap.Time("window", window))
+	return allocator.SetTSO(tsoutil.GenerateTS(tsoutil.GenerateTimestamp(window, 0)))
+}
+
+// watch keeps switching the Global TSO when the TSO leader changes until the
+// context is canceled.
+func (h *tsoHandoff) watch(ctx context.Context, revision int64) {
+	leaderPath := getTSOServiceLeaderPath(h.s.rootPath)
+	for {
+		watchChan := h.s.client.Watch(clientv3.WithRequireLeader(ctx), leaderPath, clientv3.WithRev(revision+1))
+	WatchChan:
+		for wresp := range watchChan {
+			if wresp.CompactRevision != 0 || wresp.Err() != nil {
+				log.Warn("watch tso service leader meets error", errs.ZapError(wresp.Err()))
+				break
+			}
+			for _, ev := range wresp.Events {
+				var err error
+				switch ev.Type {
+				case mvccpb.PUT:
+					err = h.handOver(ev.Kv.CreateRevision)
+				case mvccpb.DELETE:
+					err = h.takeOver()
+				}
+				if err != nil {
+					log.Error("failed to switch the global tso", errs.ZapError(err))
+					break WatchChan
+				}
+			}
+			revision = wresp.Header.Revision
+		}
+		// Check the TSO leader again after the watch is interrupted.
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(tsoHandoffRetryInterval):
+			}
+			var err error
+			if revision, err = h.sync(); err == nil {
+				break
+			}
+			log.Error("failed to sync the global tso with the tso service", errs.ZapError(err))
+		}
+	}
+}
@@ -0,0 +1,485 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/etcdutil"
+	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/pkg/systimemon"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/member"
+	"github.com/tikv/pd/server/tso"
+	"go.etcd.io/etcd/clientv3"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// tsoServiceRootPath is the root path of the standalone TSO servers under the cluster root path.
+	tsoServiceRootPath = "tso_service"
+	tsoMembersPath     = "members"
+	// pdTimestampKey is the key of the Global TSO time window under the root path of
+	// the PD leader or the TSO leader which saves it.
+	pdTimestampKey = "timestamp"
+)
+
+// TSOServer is the standalone TSO server. It only provides the TSO service over the
+// `pdpb.PD/Tso` gRPC service, and uses the etcd of the PD cluster to elect its leader
+// and persist the time window, so the heavy scheduling work of the PD leader won't add
+// latency to the TSO requests. The PD leader stops serving the Global TSO while there
+// is a TSO leader, so there is only one Global TSO allocator in the cluster.
+type TSOServer struct {
+	// Server state.
+	isServing int64
+
+	cfg *config.Config
+	ctx context.Context
+
+	serverLoopCtx    context.Context
+	serverLoopCancel func()
+	serverLoopWg     sync.WaitGroup
+
+	client    *clientv3.Client
+	member    *member.Member
+	clusterID uint64
+	// rootPath is the root path of the PD cluster, /pd/{cluster_id}.
+	rootPath string
+	// tsoRootPath is the root path of the TSO servers, /pd/{cluster_id}/tso_service.
+	tsoRootPath string
+
+	tsoAllocatorManager *tso.AllocatorManager
+
+	grpcServer *grpc.Server
+	listener   net.Listener
+}
+
+// CreateTSOServer creates the UNINITIALIZED TSO server with given configuration.
+func CreateTSOServer(ctx context.Context, cfg *config.Config) (*TSOServer, error) {
+	log.Info("TSO Server Config", zap.Reflect("config", cfg))
+	if cfg.Mode != config.ModeTSO {
+		return nil, errors.Errorf("unexpected mode %s for the tso server", cfg.Mode)
+	}
+	return &TSOServer{
+		cfg: cfg,
+		ctx: ctx,
+	}, nil
+}
+
+// Run runs the TSO server.
+func (s *TSOServer) Run() error {
+	go systimemon.StartMonitor(s.ctx, time.Now, func() {
+		log.Error("system time jumps backward", errs.ZapError(errs.ErrIncorrectSystemTime))
+		timeJumpBackCounter.Inc()
+	})
+	if err := s.initClient(); err != nil {
+		return err
+	}
+	if err := s.startServer(); err != nil {
+		return err
+	}
+	s.startServerLoop()
+	return nil
+}
+
+func (s *TSOServer) initClient() error {
+	tlsConfig, err := s.cfg.Security.ToTLSConfig()
+	if err != nil {
+		return err
+	}
+	endpoints := strings.Split(s.cfg.BackendEndpoints, ",")
+	log.Info("create etcd v3 client", zap.Strings("endpoints", endpoints), zap.Reflect("cert", s.cfg.Security))
+	lgc := zap.NewProductionConfig()
+	lgc.Encoding = log.ZapEncodingName
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: etcdTimeout,
+		TLS:         tlsConfig,
+		LogConfig:   &lgc,
+	})
+	if err != nil {
+		return errs.ErrNewEtcdClient.Wrap(err).GenWithStackByCause()
+	}
+	s.client = client
+	return nil
+}
+
+func (s *TSOServer) startServer() error {
+	// The cluster ID is initialized by the PD servers, the TSO server never creates it.
+	resp, err := etcdutil.EtcdKVGet(s.client, pdClusterIDPath)
+	if err != nil {
+		return err
+	}
+	if len(resp.Kvs) == 0 {
+		return errs.ErrClusterIDNotFound.FastGenByArgs(s.cfg.BackendEndpoints)
+	}
+	if s.clusterID, err = typeutil.BytesToUint64(resp.Kvs[0].Value); err != nil {
+		return err
+	}
+	log.Info("init cluster id", zap.Uint64("cluster-id", s.clusterID))
+
+	s.rootPath = path.Join(pdRootPath, strconv.FormatUint(s.clusterID, 10))
+	s.tsoRootPath = path.Join(s.rootPath, tsoServiceRootPath)
+	s.member = member.NewMember(nil, s.client, s.memberID())
+	s.member.MemberInfo(s.cfg, s.cfg.Name, s.tsoRootPath)
+	s.tsoAllocatorManager = tso.NewAllocatorManager(
+		s.member, s.tsoRootPath, s.cfg,
+		func() time.Duration { return s.cfg.PDServerCfg.MaxResetTSGap.Duration })
+	// Set up the Global TSO Allocator here, it will be initialized once the TSO server campaigns leader successfully.
+	s.tsoAllocatorManager.SetUpAllocator(s.ctx, tso.GlobalDCLocation, s.member.GetLeadership())
+
+	if err := s.startGRPCServer(); err != nil {
+		return err
+	}
+	// Server has started.
+	atomic.StoreInt64(&s.isServing, 1)
+	return nil
+}
+
+// memberID generates the member ID from the name and the advertise client urls, so it
+// won't change after the TSO server restarts.
+func (s *TSOServer) memberID() uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s.cfg.Name + s.cfg.AdvertiseClientUrls))
+	return h.Sum64()
+}
+
+func (s *TSOServer) startGRPCServer() error {
+	u, err := url.Parse(strings.Split(s.cfg.ClientUrls, ",")[0])
+	if err != nil {
+		return errs.ErrURLParse.Wrap(err).GenWithStackByCause()
+	}
+	tlsConfig, err := s.cfg.Security.ToTLSConfig()
+	if err != nil {
+		return err
+	}
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	s.listener, err = net.Listen("tcp", u.Host)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	s.grpcServer = grpc.NewServer(opts...)
+	pdpb.RegisterPDServer(s.grpcServer, &tsoGrpcServer{s})
+	healthpb.RegisterHealthServer(s.grpcServer, health.NewServer())
+	go func() {
+		if err := s.grpcServer.Serve(s.listener); err != nil {
+			log.Info("tso grpc server stopped", errs.ZapError(err))
+		}
+	}()
+	log.Info("tso grpc server is serving", zap.String("address", u.Host))
+	return nil
+}
+
+// Close closes the TSO server.
+func (s *TSOServer) Close() {
+	if !atomic.CompareAndSwapInt64(&s.isServing, 1, 0) {
+		// server is already closed
+		return
+	}
+
+	log.Info("closing tso server")
+	s.stopServerLoop()
+	if s.grpcServer != nil {
+		s.grpcServer.Stop()
+	}
+	if s.client != nil {
+		if err := s.client.Close(); err != nil {
+			log.Error("close etcd client meet error", errs.ZapError(errs.ErrCloseEtcdClient, err))
+		}
+	}
+	log.Info("close tso server")
+}
+
+// IsClosed checks whether server is closed or not.
+func (s *TSOServer) IsClosed() bool {
+	return atomic.LoadInt64(&s.isServing) == 0
+}
+
+// ClusterID returns the cluster ID of the TSO server.
+func (s *TSOServer) ClusterID() uint64 {
+	return s.clusterID
+}
+
+// GetMember returns the member of the TSO server.
+func (s *TSOServer) GetMember() *member.Member {
+	return s.member
+}
+
+// GetTSOAllocatorManager returns the manager of TSO Allocator.
+func (s *TSOServer) GetTSOAllocatorManager() *tso.AllocatorManager {
+	return s.tsoAllocatorManager
+}
+
+// GetAddr returns the server urls for clients.
+func (s *TSOServer) GetAddr() string {
+	return s.cfg.AdvertiseClientUrls
+}
+
+func (s *TSOServer) startServerLoop() {
+	s.serverLoopCtx, s.serverLoopCancel = context.WithCancel(s.ctx)
+	s.serverLoopWg.Add(3)
+	go s.leaderLoop()
+	go s.tsoAllocatorLoop()
+	go s.memberLoop()
+}
+
+func (s *TSOServer) stopServerLoop() {
+	s.serverLoopCancel()
+	s.serverLoopWg.Wait()
+}
+
+func (s *TSOServer) tsoAllocatorLoop() {
+	defer logutil.LogPanic()
+	defer s.serverLoopWg.Done()
+
+	ctx, cancel := context.WithCancel(s.serverLoopCtx)
+	defer cancel()
+	s.tsoAllocatorManager.AllocatorDaemon(ctx)
+	log.Info("tso server is closed, exit allocator loop")
+}
+
+func (s *TSOServer) getMemberPath() string {
+	return path.Join(s.tsoRootPath, tsoMembersPath, fmt.Sprint(s.member.ID()))
+}
+
+// memberLoop registers the TSO server in etcd with a lease, so the clients can
+// discover all alive TSO servers by GetMembers.
+func (s *TSOServer) memberLoop() {
+	defer logutil.LogPanic()
+	defer s.serverLoopWg.Done()
+
+	ctx, cancel := context.WithCancel(s.serverLoopCtx)
+	defer cancel()
+	for {
+		if err := s.registerMember(ctx); err != nil {
+			log.Warn("failed to register tso server member", errs.ZapError(err))
+		}
+		select {
+		case <-ctx.Done():
+			log.Info("tso server is closed, exit member loop")
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// registerMember puts the member info with a lease and keeps it alive until the
+// context is canceled or the lease is lost.
+func (s *TSOServer) registerMember(ctx context.Context) error {
+	lease := clientv3.NewLease(s.client)
+	defer lease.Close()
+	grantCtx, cancel := context.WithTimeout(ctx, etcdutil.DefaultRequestTimeout)
+	grantResp, err := lease.Grant(grantCtx, s.cfg.LeaderLease)
+	cancel()
+	if err != nil {
+		return errs.ErrEtcdGrantLease.Wrap(err).GenWithStackByCause()
+	}
+	putCtx, cancel := context.WithTimeout(ctx, etcdutil.DefaultRequestTimeout)
+	_, err = s.client.Put(putCtx, s.getMemberPath(), s.member.MemberValue(), clientv3.WithLease(grantResp.ID))
+	cancel()
+	if err != nil {
+		return errs.ErrEtcdKVPut.Wrap(err).GenWithStackByCause()
+	}
+	ch, err := lease.KeepAlive(ctx, grantResp.ID)
+	if err != nil {
+		return errs.ErrEtcdGrantLease.Wrap(err).GenWithStackByCause()
+	}
+	for range ch {
+	}
+	if ctx.Err() == nil {
+		log.Warn("the lease of tso server member is lost, register again")
+	} else {
+		// Revoke the lease to remove the member as soon as possible.
+		revokeCtx, cancel := context.WithTimeout(s.client.Ctx(), etcdutil.DefaultRequestTimeout)
+		if _, err := lease.Revoke(revokeCtx, grantResp.ID); err != nil {
+			log.Warn("failed to revoke the lease of tso server member", errs.ZapError(err))
+		}
+		cancel()
+	}
+	return nil
+}
+
+// getMembers returns all alive TSO servers.
+func (s *TSOServer) getMembers() ([]*pdpb.Member, error) {
+	resp, err := etcdutil.EtcdKVGet(s.client, path.Join(s.tsoRootPath, tsoMembersPath)+"/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	members := make([]*pdpb.Member, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		m := &pdpb.Member{}
+		if err := m.Unmarshal(kv.Value); err != nil {
+			return nil, errs.ErrProtoUnmarshal.Wrap(err).GenWithStackByCause()
+		}
+		members = append(members, m)
+	}
+	return members, nil
+}
+
+func (s *TSOServer) leaderLoop() {
+	defer logutil.LogPanic()
+	defer s.serverLoopWg.Done()
+
+	for {
+		if s.IsClosed() {
+			log.Info("tso server is closed, return tso leader loop")
+			return
+		}
+		select {
+		case <-s.serverLoopCtx.Done():
+			log.Info("tso server is closed, return tso leader loop")
+			return
+		default:
+		}
+
+		leader, rev, checkAgain := s.member.CheckLeader()
+		if checkAgain {
+			continue
+		}
+		if leader != nil {
+			log.Info("start to watch tso leader", zap.Stringer("tso-leader", leader))
+			// WatchLeader will keep looping and never return unless the TSO leader has changed.
+			s.member.WatchLeader(s.serverLoopCtx, leader, rev)
+			log.Info("tso leader has changed, try to re-campaign a tso leader")
+		}
+		s.campaignLeader()
+	}
+}
+
+func (s *TSOServer) campaignLeader() {
+	log.Info("start to campaign tso leader", zap.String("campaign-tso-leader-name", s.cfg.Name))
+	if err := s.member.CampaignLeader(s.cfg.LeaderLease); err != nil {
+		if err.Error() == errs.ErrEtcdTxnConflict.Error() {
+			log.Info("campaign tso leader meets error due to txn conflict, another tso server may campaign successfully",
+				zap.String("campaign-tso-leader-name", s.cfg.Name))
+		} else {
+			log.Error("campaign tso leader meets error due to etcd error",
+				zap.String("campaign-tso-leader-name", s.cfg.Name),
+				errs.ZapError(err))
+		}
+		return
+	}
+
+	ctx, cancel := context.WithCancel(s.serverLoopCtx)
+	defer func() {
+		cancel()
+		s.member.ResetLeader()
+	}()
+
+	// maintain the TSO leadership, after this, TSO can be service.
+	go s.member.KeepLeader(ctx)
+	log.Info("campaign tso leader ok", zap.String("campaign-tso-leader-name", s.cfg.Name))
+
+	allocator, err := s.tsoAllocatorManager.GetAllocator(tso.GlobalDCLocation)
+	if err != nil {
+		log.Error("failed to get the global TSO allocator", errs.ZapError(err))
+		return
+	}
+	if err := s.waitHandoff(ctx); err != nil {
+		log.Error("failed to wait for the handoff of the global tso from pd", errs.ZapError(err))
+		return
+	}
+	log.Info("initializing the global TSO allocator")
+	if err := allocator.Initialize(0); err != nil {
+		log.Error("failed to initialize the global TSO allocator", errs.ZapError(err))
+		return
+	}
+	defer s.tsoAllocatorManager.ResetAllocatorGroup(tso.GlobalDCLocation)
+	if err := s.syncTimestampWithPD(allocator); err != nil {
+		log.Error("failed to sync the timestamp with pd", errs.ZapError(err))
+		return
+	}
+
+	s.member.EnableLeader()
+	log.Info("tso leader is ready to serve", zap.String("tso-leader-name", s.cfg.Name))
+
+	leaderTicker := time.NewTicker(leaderTickInterval)
+	defer leaderTicker.Stop()
+
+	for {
+		select {
+		case <-leaderTicker.C:
+			if !s.member.IsLeader() {
+				log.Info("no longer a leader because lease has expired, tso leader will step down")
+				return
+			}
+		case <-ctx.Done():
+			// Server is closed and it should return nil.
+			log.Info("tso server is closed")
+			return
+		}
+	}
+}
+
+// waitHandoff waits until the PD leader stops serving the Global TSO and hands it
+// over to this TSO leader, see tso_handoff.go for the details.
+func (s *TSOServer) waitHandoff(ctx context.Context) error {
+	resp, err := etcdutil.EtcdKVGet(s.client, s.member.GetLeaderPath())
+	if err != nil {
+		return err
+	}
+	if len(resp.Kvs) == 0 || string(resp.Kvs[0].Value) != s.member.MemberValue() {
+		return errs.ErrLeaderNil.FastGenByArgs()
+	}
+	revision := strconv.FormatInt(resp.Kvs[0].CreateRevision, 10)
+	ticker := time.NewTicker(tsoHandoffCheckInterval)
+	defer ticker.Stop()
+	for {
+		value, err := etcdutil.GetValue(s.client, getTSOHandoffPath(s.rootPath))
+		if err != nil {
+			return err
+		}
+		if string(value) == revision {
+			log.Info("the global tso is handed over from pd", zap.String("tso-leader-revision", revision))
+			return nil
+		}
+		select {
+		case <-ticker.C:
+			if !s.member.GetLeadership().Check() {
+				return errs.ErrLeaderNil.FastGenByArgs()
+			}
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		}
+	}
+}
+
+// syncTimestampWithPD makes the TSO allocated by the TSO server greater than the ones
+// allocated by the PD leader before, so the TSO won't fall back after the clients are
+// switched to the TSO server.
+func (s *TSOServer) syncTimestampWithPD(allocator tso.Allocator) error {
+	return syncTimestampWithWindow(s.client, path.Join(s.rootPath, pdTimestampKey), allocator)
+}
@@ -21,6 +21,7 @@ import (
 	"math"
 	"path"
 	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -31,7 +32,9 @@ import (
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	pd "github.com/tikv/pd/client"
+	"github.com/tikv/pd/pkg/grpcutil"
 	"github.com/tikv/pd/pkg/mock/mockid"
+	"github.com/tikv/pd/pkg/tempurl"
 	"github.com/tikv/pd/pkg/testutil"
 	"github.com/tikv/pd/pkg/tsoutil"
 	"github.com/tikv/pd/server"
@@ -40,6 +43,8 @@ import (
 	"github.com/tikv/pd/server/tso"
 	"github.com/tikv/pd/tests"
 	"go.uber.org/goleak"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 const (
@@ -185,6 +190,104 @@ func (s *clientTestSuite) TestLeaderTransfer(c *C) {
 	wg.Wait()
 }
 
+func (s *clientTestSuite) TestTSOServer(c *C) {
+	cluster, err := tests.NewTestCluster(s.ctx, 1)
+	c.Assert(err, IsNil)
+	defer cluster.Destroy()
+
+	endpoints := s.runServer(c, cluster)
+	cli := s.setupCli(c, endpoints, false)
+	physical, logical, err := cli.GetTS(context.TODO())
+	c.Assert(err, IsNil)
+	lastTS := tsoutil.ComposeTS(physical, logical)
+
+	tsoServers := make(map[string]*server.TSOServer)
+	tsoURLs := make([]string, 0, 2)
+	for i := 0; i < 2; i++ {
+		cfg := config.NewConfig()
+		url := tempurl.Alloc()
+		err := cfg.Parse([]string{
+			"--mode=tso",
+			fmt.Sprintf("--name=tso%d", i),
+			"--client-urls=" + url,
+			"--backend-endpoints=" + strings.Join(endpoints, ","),
+		})
+		c.Assert(err, IsNil)
+		svr, err := server.CreateTSOServer(s.ctx, cfg)
+		c.Assert(err, IsNil)
+		c.Assert(svr.Run(), IsNil)
+		defer svr.Close()
+		tsoServers[url] = svr
+		tsoURLs = append(tsoURLs, url)
+	}
+	getTSOLeader := func() string {
+		for url, svr := range tsoServers {
+			if !svr.IsClosed() && svr.GetMember().IsLeader() {
+				return url
+			}
+		}
+		return ""
+	}
+	testutil.WaitUntil(c, func(c *C) bool { return getTSOLeader() != "" })
+
+	tsoCli, err := pd.NewClientWithContext(s.ctx, endpoints, pd.SecurityOption{}, pd.WithTSOServiceURLs(tsoURLs[:1]))
+	c.Assert(err, IsNil)
+	defer tsoCli.Close()
+	c.Assert(tsoCli.GetLeaderAddr(), Equals, endpoints[0])
+	c.Assert(tsoCli.(client).GetAllocatorLeaderURLs()[tso.GlobalDCLocation], Equals, getTSOLeader())
+	checkTS := func() {
+		for i := 0; i < 10; i++ {
+			physical, logical, err := tsoCli.GetTS(context.TODO())
+			c.Assert(err, IsNil)
+			ts := tsoutil.ComposeTS(physical, logical)
+			c.Assert(ts, Greater, lastTS)
+			lastTS = ts
+		}
+	}
+	checkTS()
+
+	// The TSO won't fall back after the TSO leader changes.
+	tsoServers[getTSOLeader()].Close()
+	testutil.WaitUntil(c, func(c *C) bool {
+		leader := getTSOLeader()
+		if leader == "" {
+			return false
+		}
+		tsoCli.(client).ScheduleCheckLeader()
+		return tsoCli.(client).GetAllocatorLeaderURLs()[tso.GlobalDCLocation] == leader
+	})
+	testutil.WaitUntil(c, func(c *C) bool {
+		_, _, err := tsoCli.GetTS(context.TODO())
+		return err == nil
+	})
+	checkTS()
+
+	// The PD leader doesn't serve the Global TSO while the TSO service is active.
+	_, _, err = cli.GetTS(context.TODO())
+	c.Assert(err, NotNil)
+	// Advance the TSO service far beyond the time window of the PD leader.
+	allocator, err := tsoServers[getTSOLeader()].GetTSOAllocatorManager().GetAllocator(tso.GlobalDCLocation)
+	c.Assert(err, IsNil)
+	physical, _, err = tsoCli.GetTS(context.TODO())
+	c.Assert(err, IsNil)
+	lastTS = tsoutil.ComposeTS(physical+time.Hour.Milliseconds(), 0)
+	c.Assert(allocator.SetTSO(lastTS), IsNil)
+	checkTS()
+	// The PD leader takes over the Global TSO after the TSO servers are closed,
+	// and it starts from the time window of the TSO service.
+	for _, svr := range tsoServers {
+		svr.Close()
+	}
+	testutil.WaitUntil(c, func(c *C) bool {
+		physical, logical, err := cli.GetTS(context.TODO())
+		if err != nil {
+			return false
+		}
+		c.Assert(tsoutil.ComposeTS(physical, logical), Greater, lastTS)
+		return true
+	})
+}
+
 func (s *clientTestSuite) TestTSOAllocatorLeader(c *C) {
 	dcLocationConfig := map[string]string{
 		"pd1": "dc-1",
@@ -364,6 +467,59 @@ func (s *clientTestSuite) TestGetRegionFromFollowerClient(c *C) {
 	c.Assert(r, NotNil)
 }
 
+func (s *clientTestSuite) TestFollowerHandle(c *C) {
+	cluster, err := tests.NewTestCluster(s.ctx, 3, func(conf *config.Config, serverName string) {
+		conf.EnableFollowerHandle = true
+	})
+	c.Assert(err, IsNil)
+	defer cluster.Destroy()
+
+	endpoints := s.runServer(c, cluster)
+	leader := cluster.GetServer(cluster.GetLeader())
+	follower := cluster.GetServer(cluster.GetFollower())
+	// The region is synced to the followers after it's updated by the heartbeat.
+	peer := &metapb.Peer{Id: 3, StoreId: 1}
+	region := core.NewRegionInfo(&metapb.Region{Id: 2, Peers: []*metapb.Peer{peer}, RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 1}}, peer)
+	c.Assert(leader.GetRaftCluster().HandleRegionHeartbeat(region), IsNil)
+	grpcClient := testutil.MustNewGrpcClient(c, follower.GetAddr())
+	header := &pdpb.RequestHeader{ClusterId: leader.GetClusterID()}
+	req := &pdpb.GetRegionRequest{Header: header, RegionKey: []byte("a")}
+
+	// The follower rejects the requests which don't allow follower handle.
+	_, err = grpcClient.GetRegion(context.Background(), req)
+	c.Assert(err, NotNil)
+	c.Assert(strings.Contains(err.Error(), "not leader"), IsTrue)
+
+	// The follower serves the requests after it syncs the regions and the stores with the leader.
+	var md metadata.MD
+	testutil.WaitUntil(c, func(c *C) bool {
+		resp, err := grpcClient.GetRegion(grpcutil.BuildFollowerHandleContext(context.Background()), req, grpc.Header(&md))
+		return err == nil && resp.GetRegion().GetId() == 2
+	})
+	c.Assert(md.Get(grpcutil.FollowerSyncLagMetadataKey), HasLen, 1)
+	testutil.WaitUntil(c, func(c *C) bool {
+		resp, err := grpcClient.GetStore(grpcutil.BuildFollowerHandleContext(context.Background()), &pdpb.GetStoreRequest{Header: header, StoreId: 1})
+		// The follower has no heartbeat stats of the stores.
+		return err == nil && resp.GetStore().GetAddress() == "mock://1" && resp.GetStats() == nil
+	})
+
+	cli, err := pd.NewClientWithContext(s.ctx, endpoints, pd.SecurityOption{}, pd.WithFollowerHandleOption(true))
+	c.Assert(err, IsNil)
+	defer cli.Close()
+	r, err := cli.GetRegion(context.Background(), []byte("a"))
+	c.Assert(err, IsNil)
+	c.Assert(r.Meta.GetId(), Equals, uint64(2))
+	regions, err := cli.ScanRegions(context.Background(), []byte(""), []byte(""), 10)
+	c.Assert(err, IsNil)
+	c.Assert(regions, HasLen, 1)
+	stores, err := cli.GetAllStores(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(stores, HasLen, 1)
+	// The unknown store on the followers falls back to the leader.
+	_, err = cli.GetStore(context.Background(), 10)
+	c.Assert(err, NotNil)
+}
+
 // case 1: unreachable -> normal
 func (s *clientTestSuite) TestGetTsoFromFollowerClient1(c *C) {
 	pd.LeaderHealthCheckInterval = 100 * time.Millisecond
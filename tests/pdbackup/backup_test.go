@@ -17,11 +17,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"os"
+	"path"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	. "github.com/pingcap/check"
+	"github.com/tikv/pd/pkg/tempurl"
+	"github.com/tikv/pd/pkg/typeutil"
 	"github.com/tikv/pd/server"
 	"github.com/tikv/pd/tests"
 	"github.com/tikv/pd/tools/pd-backup/pdbackup"
@@ -71,3 +76,104 @@ func (s *backupTestSuite) TestBackup(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(backupInfo, DeepEquals, newInfo)
 }
+
+func (s *backupTestSuite) TestArchive(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cluster, err := tests.NewTestCluster(ctx, 1)
+	c.Assert(err, IsNil)
+	defer cluster.Destroy()
+	err = cluster.RunInitialServers()
+	c.Assert(err, IsNil)
+	leaderServer := cluster.GetServer(cluster.WaitLeader())
+	c.Assert(leaderServer.BootstrapCluster(), IsNil)
+	svr := leaderServer.GetServer()
+	c.Assert(svr.GetPersistOptions().Persist(svr.GetStorage()), IsNil)
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{cluster.GetConfig().GetClientURL()},
+		DialTimeout: 3 * time.Second,
+	})
+	c.Assert(err, IsNil)
+	defer client.Close()
+
+	archive, err := pdbackup.CreateArchive(client, "")
+	c.Assert(err, IsNil)
+	c.Assert(archive.ClusterID, Equals, leaderServer.GetClusterID())
+	c.Assert(archive.ClusterVersion, Equals, svr.GetClusterVersion().String())
+	for _, name := range []string{pdbackup.SectionMeta, pdbackup.SectionStores, pdbackup.SectionConfig, pdbackup.SectionAllocID, pdbackup.SectionTSO} {
+		c.Assert(archive.GetSection(name), NotNil, Commentf("section %s", name))
+	}
+	for _, section := range archive.Sections {
+		for _, entry := range section.Entries {
+			// The leader key is attached to a lease.
+			c.Assert(entry.Key, Not(Equals), "leader")
+		}
+	}
+
+	var buf bytes.Buffer
+	c.Assert(pdbackup.WriteArchive(archive, &buf), IsNil)
+	data := buf.Bytes()
+	newArchive, err := pdbackup.ReadArchive(bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	c.Assert(newArchive.Sections, DeepEquals, archive.Sections)
+
+	// The tampered archive can't pass the verification.
+	newArchive.GetSection(pdbackup.SectionConfig).Entries[0].Value = []byte("{}")
+	c.Assert(newArchive.Verify(), NotNil)
+	c.Assert(archive.CheckCompatibility(archive.ClusterVersion), IsNil)
+	newArchive.ClusterVersion = "5.1.0"
+	c.Assert(newArchive.CheckCompatibility("5.1.2"), IsNil)
+	c.Assert(newArchive.CheckCompatibility("5.2.0"), IsNil)
+	c.Assert(newArchive.CheckCompatibility("4.0.0"), NotNil)
+
+	// Restore into a PD data dir and an etcd which already has a PD cluster.
+	dataDir, err := os.MkdirTemp("", "pd_restore")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dataDir)
+	err = pdbackup.RestoreToDataDir(archive, dataDir, "pd_restore", tempurl.Alloc(), tempurl.Alloc())
+	c.Assert(err, IsNil)
+	c.Assert(pdbackup.RestoreToDataDir(archive, dataDir, "pd_restore", tempurl.Alloc(), tempurl.Alloc()), NotNil)
+	c.Assert(pdbackup.RestoreToEtcd(client, archive, false, false), NotNil)
+	rootPath := path.Join("/pd", strconv.FormatUint(archive.ClusterID, 10))
+	_, err = client.Put(ctx, path.Join(rootPath, "stale"), "stale")
+	c.Assert(err, IsNil)
+	start := time.Now()
+	c.Assert(pdbackup.RestoreToEtcd(client, archive, true, false), IsNil)
+	// The keys missing from the archive are deleted.
+	resp, err := client.Get(ctx, path.Join(rootPath, "stale"))
+	c.Assert(err, IsNil)
+	c.Assert(resp.Kvs, HasLen, 0)
+	// The timestamp is bumped to make sure the TSO won't fall back.
+	resp, err = client.Get(ctx, path.Join(rootPath, "timestamp"))
+	c.Assert(err, IsNil)
+	c.Assert(resp.Kvs, HasLen, 1)
+	ts, err := typeutil.ParseTimestamp(resp.Kvs[0].Value)
+	c.Assert(err, IsNil)
+	c.Assert(ts.After(start), IsTrue)
+
+	// The etcd has a cluster with a different cluster ID.
+	otherID := archive.ClusterID + 1
+	otherRootPath := path.Join("/pd", strconv.FormatUint(otherID, 10))
+	_, err = client.Put(ctx, "/pd/cluster_id", string(typeutil.Uint64ToBytes(otherID)))
+	c.Assert(err, IsNil)
+	_, err = client.Put(ctx, path.Join(otherRootPath, "raft"), "other")
+	c.Assert(err, IsNil)
+	// The other cluster is kept by default.
+	c.Assert(pdbackup.RestoreToEtcd(client, archive, true, false), IsNil)
+	resp, err = client.Get(ctx, path.Join(otherRootPath, "raft"))
+	c.Assert(err, IsNil)
+	c.Assert(resp.Kvs, HasLen, 1)
+	resp, err = client.Get(ctx, "/pd/cluster_id")
+	c.Assert(err, IsNil)
+	c.Assert(resp.Kvs[0].Value, DeepEquals, typeutil.Uint64ToBytes(archive.ClusterID))
+	// It is deleted only if required explicitly.
+	_, err = client.Put(ctx, "/pd/cluster_id", string(typeutil.Uint64ToBytes(otherID)))
+	c.Assert(err, IsNil)
+	c.Assert(pdbackup.RestoreToEtcd(client, archive, true, true), IsNil)
+	resp, err = client.Get(ctx, path.Join(otherRootPath, "raft"))
+	c.Assert(err, IsNil)
+	c.Assert(resp.Kvs, HasLen, 0)
+	resp, err = client.Get(ctx, path.Join(rootPath, "timestamp"))
+	c.Assert(err, IsNil)
+	c.Assert(resp.Kvs, HasLen, 1)
+}
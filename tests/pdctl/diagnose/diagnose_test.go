@@ -0,0 +1,84 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnose_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/pingcap/check"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/diagnose"
+	"github.com/tikv/pd/tests"
+	"github.com/tikv/pd/tests/pdctl"
+	pdctlCmd "github.com/tikv/pd/tools/pd-ctl/pdctl"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&diagnoseTestSuite{})
+
+type diagnoseTestSuite struct{}
+
+func (s *diagnoseTestSuite) SetUpSuite(c *C) {
+	server.EnableZap = true
+}
+
+func (s *diagnoseTestSuite) TestDiagnose(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tc, err := tests.NewTestCluster(ctx, 1)
+	c.Assert(err, IsNil)
+	err = tc.RunInitialServers()
+	c.Assert(err, IsNil)
+	tc.WaitLeader()
+	leaderServer := tc.GetServer(tc.GetLeader())
+	c.Assert(leaderServer.BootstrapCluster(), IsNil)
+	pdAddr := tc.GetConfig().GetClientURL()
+	cmd := pdctlCmd.GetRootCmd()
+	defer tc.Destroy()
+
+	// diagnose checks command
+	args := []string{"-u", pdAddr, "diagnose", "checks"}
+	output, err := pdctl.ExecuteCommand(cmd, args...)
+	c.Assert(err, IsNil)
+	var checks []diagnose.CheckInfo
+	c.Assert(json.Unmarshal(output, &checks), IsNil)
+	c.Assert(len(checks), Greater, 0)
+
+	// diagnose command with a check, there is only one PD instance.
+	args = []string{"-u", pdAddr, "diagnose", "members"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	c.Assert(err, IsNil)
+	var rdd []diagnose.Recommendation
+	c.Assert(json.Unmarshal(output, &rdd), IsNil)
+	found := false
+	for _, r := range rdd {
+		c.Assert(r.Check, Equals, "members")
+		if strings.Contains(r.Description, "only one PD instance") {
+			found = true
+		}
+	}
+	c.Assert(found, IsTrue)
+
+	// diagnose command with an unknown check
+	args = []string{"-u", pdAddr, "diagnose", "unknown"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(output), "unknown diagnose check"), IsTrue)
+}
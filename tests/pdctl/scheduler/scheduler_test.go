@@ -237,8 +237,28 @@ func (s *schedulerTestSuite) TestScheduler(c *C) {
 	mustExec([]string{"-u", pdAddr, "scheduler", "config", "shuffle-region-scheduler"}, &roles)
 	c.Assert(roles, DeepEquals, []string{"learner"})
 
+	// test balance leader group config
+	var groupConf map[string]interface{}
+	mustExec([]string{"-u", pdAddr, "scheduler", "config", "balance-leader-scheduler", "list"}, &groupConf)
+	c.Assert(groupConf["label-key"], IsNil)
+	echo := mustExec([]string{"-u", pdAddr, "scheduler", "config", "balance-leader-scheduler", "set-target-ratios", "ssd=1"}, nil)
+	c.Assert(strings.Contains(echo, "Success!"), IsFalse)
+	echo = mustExec([]string{"-u", pdAddr, "scheduler", "config", "balance-leader-scheduler", "set-label-key", "disk"}, nil)
+	c.Assert(strings.Contains(echo, "Success!"), IsTrue)
+	echo = mustExec([]string{"-u", pdAddr, "scheduler", "config", "balance-leader-scheduler", "set-target-ratios", "ssd=1,nvme=x"}, nil)
+	c.Assert(strings.Contains(echo, "invalid target ratio"), IsTrue)
+	echo = mustExec([]string{"-u", pdAddr, "scheduler", "config", "balance-leader-scheduler", "set-target-ratios", "ssd=1,nvme=2"}, nil)
+	c.Assert(strings.Contains(echo, "Success!"), IsTrue)
+	mustExec([]string{"-u", pdAddr, "scheduler", "config", "balance-leader-scheduler"}, &groupConf)
+	c.Assert(groupConf["label-key"], Equals, "disk")
+	c.Assert(groupConf["target-ratios"], DeepEquals, map[string]interface{}{"ssd": float64(1), "nvme": float64(2)})
+	mustExec([]string{"-u", pdAddr, "scheduler", "config", "balance-leader-scheduler", "set-target-ratios"}, nil)
+	groupConf = nil
+	mustExec([]string{"-u", pdAddr, "scheduler", "config", "balance-leader-scheduler"}, &groupConf)
+	c.Assert(groupConf["target-ratios"], IsNil)
+
 	// test balance region config
-	echo := mustExec([]string{"-u", pdAddr, "scheduler", "add", "balance-region-scheduler"}, nil)
+	echo = mustExec([]string{"-u", pdAddr, "scheduler", "add", "balance-region-scheduler"}, nil)
 	c.Assert(strings.Contains(echo, "Success!"), IsTrue)
 	echo = mustExec([]string{"-u", pdAddr, "scheduler", "remove", "balance-region-scheduler"}, nil)
 	c.Assert(strings.Contains(echo, "Success!"), IsTrue)
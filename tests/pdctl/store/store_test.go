@@ -22,8 +22,10 @@ import (
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/tikv/pd/server"
 	"github.com/tikv/pd/server/api"
+	svrcluster "github.com/tikv/pd/server/cluster"
 	"github.com/tikv/pd/server/core/storelimit"
 	"github.com/tikv/pd/tests"
 	"github.com/tikv/pd/tests/pdctl"
@@ -345,3 +347,41 @@ func (s *storeTestSuite) TestStore(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(scene.Idle, Equals, 100)
 }
+
+func (s *storeTestSuite) TestCapacityForecast(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cluster, err := tests.NewTestCluster(ctx, 1)
+	c.Assert(err, IsNil)
+	err = cluster.RunInitialServers()
+	c.Assert(err, IsNil)
+	cluster.WaitLeader()
+	pdAddr := cluster.GetConfig().GetClientURL()
+	cmd := cmd.GetRootCmd()
+
+	leaderServer := cluster.GetServer(cluster.GetLeader())
+	c.Assert(leaderServer.BootstrapCluster(), IsNil)
+	defer cluster.Destroy()
+	for _, id := range []uint64{1, 2} {
+		store := &metapb.Store{
+			Id:            id,
+			State:         metapb.StoreState_Up,
+			Labels:        []*metapb.StoreLabel{{Key: "zone", Value: "z1"}},
+			LastHeartbeat: time.Now().UnixNano(),
+		}
+		pdctl.MustPutStore(c, leaderServer.GetServer(), store)
+		err = leaderServer.GetRaftCluster().HandleStoreHeartbeat(&pdpb.StoreStats{StoreId: id, Capacity: 100, Available: 50})
+		c.Assert(err, IsNil)
+	}
+
+	args := []string{"-u", pdAddr, "store", "capacity-forecast", "--label-key", "zone"}
+	output, err := pdctl.ExecuteCommand(cmd, args...)
+	c.Assert(err, IsNil)
+	forecast := new(svrcluster.CapacityForecast)
+	c.Assert(json.Unmarshal(output, forecast), IsNil)
+	c.Assert(forecast.Stores, HasLen, 2)
+	c.Assert(forecast.Stores[0].UsedRatio, Equals, 0.5)
+	c.Assert(forecast.Groups, HasLen, 1)
+	c.Assert(forecast.Groups[0].Group, Equals, "z1")
+	c.Assert(forecast.Groups[0].Capacity, Equals, uint64(200))
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unsafe_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/cluster"
+	"github.com/tikv/pd/tests"
+	"github.com/tikv/pd/tests/pdctl"
+	pdctlCmd "github.com/tikv/pd/tools/pd-ctl/pdctl"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&unsafeTestSuite{})
+
+type unsafeTestSuite struct{}
+
+func (s *unsafeTestSuite) SetUpSuite(c *C) {
+	server.EnableZap = true
+}
+
+func (s *unsafeTestSuite) TestRemoveFailedStores(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tc, err := tests.NewTestCluster(ctx, 1)
+	c.Assert(err, IsNil)
+	err = tc.RunInitialServers()
+	c.Assert(err, IsNil)
+	tc.WaitLeader()
+	leaderServer := tc.GetServer(tc.GetLeader())
+	c.Assert(leaderServer.BootstrapCluster(), IsNil)
+	pdctl.MustPutStore(c, leaderServer.GetServer(), &metapb.Store{Id: 2, State: metapb.StoreState_Up})
+	pdAddr := tc.GetConfig().GetClientURL()
+	cmd := pdctlCmd.GetRootCmd()
+	defer tc.Destroy()
+
+	args := []string{"-u", pdAddr, "unsafe", "remove-failed-stores", "show"}
+	output, err := pdctl.ExecuteCommand(cmd, args...)
+	c.Assert(err, IsNil)
+	status := &cluster.UnsafeRecoveryStatus{}
+	c.Assert(json.Unmarshal(output, status), IsNil)
+	c.Assert(status.Stage, Equals, cluster.UnsafeRecoveryIdle)
+
+	args = []string{"-u", pdAddr, "unsafe", "remove-failed-stores", "1,a"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(output), "Invalid store id"), IsTrue)
+
+	args = []string{"-u", pdAddr, "unsafe", "remove-failed-stores", "1", "--timeout", "3600"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(output), "Success!"), IsTrue)
+
+	args = []string{"-u", pdAddr, "unsafe", "remove-failed-stores", "show"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(json.Unmarshal(output, status), IsNil)
+	c.Assert(status.Stage, Equals, cluster.UnsafeRecoveryRecovering)
+	c.Assert(status.FailedStores, DeepEquals, []uint64{1})
+
+	args = []string{"-u", pdAddr, "unsafe", "plan", "2"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	c.Assert(err, IsNil)
+	plan := &cluster.StoreRecoveryPlan{}
+	c.Assert(json.Unmarshal(output, plan), IsNil)
+	c.Assert(plan.StoreID, Equals, uint64(2))
+	c.Assert(plan.Creates, HasLen, 1)
+
+	args = []string{"-u", pdAddr, "unsafe", "plan", "1"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(output), "no plan"), IsTrue)
+}
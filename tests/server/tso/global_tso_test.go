@@ -26,9 +26,12 @@ import (
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/tikv/pd/pkg/grpcutil"
 	"github.com/tikv/pd/pkg/testutil"
+	"github.com/tikv/pd/pkg/tsoutil"
 	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/tso"
 	"github.com/tikv/pd/tests"
+	"google.golang.org/grpc/metadata"
 )
 
 // There are three kinds of ways to generate a TSO:
@@ -203,3 +206,114 @@ func (s *testNormalGlobalTSOSuite) TestDelaySyncTimestamp(c *C) {
 	c.Assert(checkAndReturnTimestampResponse(c, req, resp), NotNil)
 	failpoint.Disable("github.com/tikv/pd/server/tso/delaySyncTimestamp")
 }
+
+func (s *testNormalGlobalTSOSuite) TestClockLagPolicyRefuse(c *C) {
+	cluster, err := tests.NewTestCluster(s.ctx, 1, func(conf *config.Config, serverName string) {
+		conf.TSOClockLagPolicy = config.TSOClockLagPolicyRefuse
+	})
+	defer cluster.Destroy()
+	c.Assert(err, IsNil)
+
+	err = cluster.RunInitialServers()
+	c.Assert(err, IsNil)
+	cluster.WaitLeader()
+
+	leaderServer := cluster.GetServer(cluster.GetLeader())
+	grpcPDClient := testutil.MustNewGrpcClient(c, leaderServer.GetAddr())
+	req := &pdpb.TsoRequest{
+		Header:     testutil.NewRequestHeader(leaderServer.GetClusterID()),
+		Count:      1,
+		DcLocation: tso.GlobalDCLocation,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	testGetTimestamp(c, ctx, grpcPDClient, req)
+
+	// The system clock lags behind the TSO by one hour.
+	c.Assert(failpoint.Enable("github.com/tikv/pd/server/tso/systemTimeSlow", `return(true)`), IsNil)
+	testutil.WaitUntil(c, func(c *C) bool {
+		status := leaderServer.GetServer().GetTSOAllocatorManager().GetHealthStatus()
+		return len(status) == 1 && status[0].ClockLagging
+	})
+	tsoClient, err := grpcPDClient.Tso(ctx)
+	c.Assert(err, IsNil)
+	defer tsoClient.CloseSend()
+	c.Assert(tsoClient.Send(req), IsNil)
+	_, err = tsoClient.Recv()
+	c.Assert(err, NotNil)
+	c.Assert(strings.Contains(err.Error(), "system clock lags behind"), IsTrue)
+
+	c.Assert(failpoint.Disable("github.com/tikv/pd/server/tso/systemTimeSlow"), IsNil)
+	testutil.WaitUntil(c, func(c *C) bool {
+		status := leaderServer.GetServer().GetTSOAllocatorManager().GetHealthStatus()
+		return len(status) == 1 && !status[0].ClockLagging
+	})
+	testGetTimestamp(c, ctx, grpcPDClient, req)
+}
+
+func (s *testNormalGlobalTSOSuite) TestBatchedTSO(c *C) {
+	cluster, err := tests.NewTestCluster(s.ctx, 1, func(conf *config.Config, serverName string) {
+		conf.EnableTSOBatch = true
+	})
+	defer cluster.Destroy()
+	c.Assert(err, IsNil)
+
+	err = cluster.RunInitialServers()
+	c.Assert(err, IsNil)
+	cluster.WaitLeader()
+
+	leaderServer := cluster.GetServer(cluster.GetLeader())
+	grpcPDClient := testutil.MustNewGrpcClient(c, leaderServer.GetAddr())
+	req := &pdpb.TsoRequest{
+		Header:     testutil.NewRequestHeader(leaderServer.GetClusterID()),
+		Count:      uint32(tsoCount),
+		DcLocation: tso.GlobalDCLocation,
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		tsPool = make(map[uint64]struct{})
+	)
+	wg.Add(tsoRequestConcurrencyNumber * 2)
+	for i := 0; i < tsoRequestConcurrencyNumber*2; i++ {
+		priority := tso.PriorityNormal
+		if i%2 == 1 {
+			priority = tso.PriorityLow
+		}
+		go func(priority tso.RequestPriority) {
+			defer wg.Done()
+			ctx, cancel := context.WithCancel(s.ctx)
+			defer cancel()
+			ctx = metadata.AppendToOutgoingContext(ctx, grpcutil.TSOPriorityMetadataKey, priority.String())
+			last := &pdpb.Timestamp{}
+			for j := 0; j < tsoRequestRound; j++ {
+				ts := testGetTimestamp(c, ctx, grpcPDClient, req)
+				c.Assert(tsoutil.CompareTimestamp(ts, last), Equals, 1)
+				last = ts
+				// Every timestamp in the returned range must be unique.
+				mu.Lock()
+				for k := int64(0); k < int64(req.GetCount()); k++ {
+					key := tsoutil.GenerateTS(&pdpb.Timestamp{
+						Physical: ts.GetPhysical(),
+						Logical:  ts.GetLogical() - k<<ts.GetSuffixBits(),
+					})
+					_, exist := tsPool[key]
+					c.Assert(exist, IsFalse)
+					tsPool[key] = struct{}{}
+				}
+				mu.Unlock()
+			}
+		}(priority)
+	}
+	wg.Wait()
+
+	// The request with count 0 is rejected like the one without batch.
+	req.Count = 0
+	tsoClient, err := grpcPDClient.Tso(s.ctx)
+	c.Assert(err, IsNil)
+	defer tsoClient.CloseSend()
+	c.Assert(tsoClient.Send(req), IsNil)
+	_, err = tsoClient.Recv()
+	c.Assert(err, NotNil)
+}
@@ -17,13 +17,17 @@ package tso_test
 
 import (
 	"context"
+	"path"
 	"strconv"
 	"time"
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/failpoint"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/pkg/etcdutil"
 	"github.com/tikv/pd/pkg/testutil"
+	"github.com/tikv/pd/pkg/typeutil"
 	"github.com/tikv/pd/server"
 	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/tso"
@@ -196,3 +200,91 @@ func (s *testManagerSuite) TestNextLeaderKey(c *C) {
 		return
 	}
 }
+
+func (s *testManagerSuite) TestDCLocationLifecycle(c *C) {
+	dcLocationConfig := map[string]string{
+		"pd1": "dc-1",
+		"pd2": "dc-1",
+		"pd3": "dc-2",
+	}
+	cluster, err := tests.NewTestCluster(s.ctx, len(dcLocationConfig), func(conf *config.Config, serverName string) {
+		conf.EnableLocalTSO = true
+		conf.Labels[config.ZoneLabel] = dcLocationConfig[serverName]
+		conf.TSOSaveInterval = typeutil.NewDuration(time.Second)
+	})
+	defer cluster.Destroy()
+	c.Assert(err, IsNil)
+	err = cluster.RunInitialServers()
+	c.Assert(err, IsNil)
+	cluster.WaitAllLeaders(c, dcLocationConfig)
+
+	leaderServer := cluster.GetServer(cluster.GetLeader())
+	am := leaderServer.GetTSOAllocatorManager()
+	details, err := am.GetDCLocationDetails()
+	c.Assert(err, IsNil)
+	c.Assert(details, HasLen, 2)
+	c.Assert(details[0].DCLocation, Equals, "dc-1")
+	c.Assert(details[0].ServerIDs, HasLen, 2)
+	c.Assert(details[1].DCLocation, Equals, "dc-2")
+	c.Assert(details[1].ServerIDs, DeepEquals, []uint64{cluster.GetServer("pd3").GetServerID()})
+	dc2Suffix := details[1].Suffix
+	c.Assert(dc2Suffix, Greater, int32(0))
+
+	// A dc-location with members can not be removed.
+	c.Assert(am.RemoveDCLocation("dc-2"), NotNil)
+	c.Assert(am.MigrateDCLocation("dc-2", "dc-2", nil), NotNil)
+	c.Assert(am.MigrateDCLocation("dc-2", "dc-1", []uint64{cluster.GetServer("pd1").GetServerID()}), NotNil)
+	c.Assert(am.MigrateDCLocation("dc-2", "dc-1", nil), IsNil)
+	cluster.CheckClusterDCLocation()
+	details, err = am.GetDCLocationDetails()
+	c.Assert(err, IsNil)
+	c.Assert(details, HasLen, 2)
+	c.Assert(details[0].ServerIDs, HasLen, 3)
+	c.Assert(details[1].ServerIDs, HasLen, 0)
+	c.Assert(details[1].Suffix, Equals, dc2Suffix)
+
+	// The drained dc-location can't be removed while its allocator leader is still held.
+	leaderKey := path.Join(leaderServer.GetServer().GetServerRootPath(), "dc-2")
+	testutil.WaitUntil(c, func(c *C) bool {
+		cluster.CheckClusterDCLocation()
+		resp, err := etcdutil.EtcdKVGet(cluster.GetEtcdClient(), leaderKey)
+		c.Assert(err, IsNil)
+		return len(resp.Kvs) == 0
+	}, testutil.WithSleepInterval(time.Second))
+	staleLeader := &pdpb.Member{MemberId: cluster.GetServer("pd3").GetServerID(), Name: "pd3"}
+	value, err := staleLeader.Marshal()
+	c.Assert(err, IsNil)
+	_, err = cluster.GetEtcdClient().Put(s.ctx, leaderKey, string(value))
+	c.Assert(err, IsNil)
+	err = am.RemoveDCLocation("dc-2")
+	c.Assert(errs.ErrDCLocationHasLeader.Equal(err), IsTrue)
+	details, err = am.GetDCLocationDetails()
+	c.Assert(err, IsNil)
+	c.Assert(details, HasLen, 2)
+	c.Assert(details[1].Suffix, Equals, dc2Suffix)
+	_, err = cluster.GetEtcdClient().Delete(s.ctx, leaderKey)
+	c.Assert(err, IsNil)
+
+	// Remove the drained dc-location, the Global TSO should catch up with its time window.
+	window, err := etcdutil.GetValue(cluster.GetEtcdClient(), path.Join(leaderServer.GetServer().GetServerRootPath(), "dc-2", "timestamp"))
+	c.Assert(err, IsNil)
+	windowTime, err := typeutil.ParseTimestamp(window)
+	c.Assert(err, IsNil)
+	c.Assert(am.RemoveDCLocation("dc-2"), IsNil)
+	globalTSO, err := am.HandleTSORequest(tso.GlobalDCLocation, 1)
+	c.Assert(err, IsNil)
+	c.Assert(globalTSO.GetPhysical(), GreaterEqual, windowTime.UnixNano()/int64(time.Millisecond))
+	details, err = am.GetDCLocationDetails()
+	c.Assert(err, IsNil)
+	c.Assert(details, HasLen, 1)
+	c.Assert(am.RemoveDCLocation("dc-2"), NotNil)
+
+	// The reclaimed suffix will be reused by a new dc-location after its time window.
+	time.Sleep(time.Until(windowTime.Add(2 * time.Second)))
+	c.Assert(am.MigrateDCLocation("dc-1", "dc-3", []uint64{cluster.GetServer("pd3").GetServerID()}), IsNil)
+	testutil.WaitUntil(c, func(c *C) bool {
+		am.ClusterDCLocationChecker()
+		info, ok := am.GetDCLocationInfo("dc-3")
+		return ok && info.Suffix == dc2Suffix
+	}, testutil.WithSleepInterval(time.Second))
+}
@@ -20,17 +20,28 @@ import (
 	"strings"
 	"time"
 
+	"github.com/tikv/pd/server/versioninfo"
 	"github.com/tikv/pd/tools/pd-backup/pdbackup"
 	"go.etcd.io/etcd/clientv3"
 	"go.etcd.io/etcd/pkg/transport"
 )
 
 var (
-	pdAddr   = flag.String("pd", "http://127.0.0.1:2379", "pd address")
-	filePath = flag.String("file", "backup.json", "backup file path and name")
+	mode     = flag.String("mode", "info", "backup mode, info: dump the basic cluster info in JSON, backup: back up all metadata into an archive, restore: restore an archive")
+	pdAddr   = flag.String("pd", "http://127.0.0.1:2379", "pd address, it's the target etcd address when restoring")
+	filePath = flag.String("file", "", "backup file path and name, default: backup.json for info mode, backup.pdb for backup and restore mode")
 	caPath   = flag.String("cacert", "", "path of file that contains list of trusted SSL CAs")
 	certPath = flag.String("cert", "", "path of file that contains X509 certificate in PEM format")
 	keyPath  = flag.String("key", "", "path of file that contains X509 key in PEM format")
+
+	regionStoragePath  = flag.String("region-storage", "", "path of the region storage to back up, or to restore into when the data dir is not specified")
+	dataDir            = flag.String("data-dir", "", "restore into a fresh PD data dir instead of the etcd specified by --pd")
+	name               = flag.String("name", "pd", "name of the PD which will use the restored data dir")
+	peerURL            = flag.String("peer-url", "http://127.0.0.1:2380", "peer URL of the PD which will use the restored data dir")
+	clientURL          = flag.String("client-url", "http://127.0.0.1:2379", "client URL of the embedded etcd used to restore the data dir")
+	targetVersion      = flag.String("target-version", versioninfo.PDReleaseVersion, "version of the target PD, used to check the compatibility when restoring")
+	force              = flag.Bool("force", false, "restore even if the target etcd already has a PD cluster or the versions are incompatible, the keys of the archived cluster missing from the archive are deleted")
+	removeOtherCluster = flag.Bool("remove-other-cluster", false, "with --force, also delete the keys of the existing cluster if its cluster ID differs from the archive")
 )
 
 const (
@@ -39,11 +50,20 @@ const (
 
 func main() {
 	flag.Parse()
-	f, err := os.Create(*filePath)
-	checkErr(err)
-	defer f.Close()
-	urls := strings.Split(*pdAddr, ",")
+	switch *mode {
+	case "info":
+		dumpInfo()
+	case "backup":
+		backup()
+	case "restore":
+		restore()
+	default:
+		checkErr(fmt.Errorf("unknown mode %s", *mode))
+	}
+}
 
+func newClient() *clientv3.Client {
+	urls := strings.Split(*pdAddr, ",")
 	tlsInfo := transport.TLSInfo{
 		CertFile:      *certPath,
 		KeyFile:       *keyPath,
@@ -58,11 +78,73 @@ func main() {
 		TLS:         tlsConfig,
 	})
 	checkErr(err)
+	return client
+}
+
+func getFilePath(defaultPath string) string {
+	if *filePath == "" {
+		return defaultPath
+	}
+	return *filePath
+}
+
+func dumpInfo() {
+	file := getFilePath("backup.json")
+	f, err := os.Create(file)
+	checkErr(err)
+	defer f.Close()
+	client := newClient()
+	defer client.Close()
 
 	backInfo, err := pdbackup.GetBackupInfo(client, *pdAddr)
 	checkErr(err)
-	pdbackup.OutputToFile(backInfo, f)
-	fmt.Println("pd backup successful! dump file is:", *filePath)
+	checkErr(pdbackup.OutputToFile(backInfo, f))
+	fmt.Println("pd backup successful! dump file is:", file)
+}
+
+func backup() {
+	file := getFilePath("backup.pdb")
+	client := newClient()
+	defer client.Close()
+
+	archive, err := pdbackup.CreateArchive(client, *regionStoragePath)
+	checkErr(err)
+	f, err := os.Create(file)
+	checkErr(err)
+	defer f.Close()
+	checkErr(pdbackup.WriteArchive(archive, f))
+	for _, section := range archive.Sections {
+		fmt.Printf("%-20s %8d entries, checksum: %s\n", section.Name, len(section.Entries), section.Checksum)
+	}
+	fmt.Printf("pd backup successful! cluster id: %d, revision: %d, archive file is: %s\n", archive.ClusterID, archive.Revision, file)
+}
+
+func restore() {
+	file := getFilePath("backup.pdb")
+	f, err := os.Open(file)
+	checkErr(err)
+	defer f.Close()
+	archive, err := pdbackup.ReadArchive(f)
+	checkErr(err)
+	if err := archive.CheckCompatibility(*targetVersion); err != nil {
+		if !*force {
+			checkErr(err)
+		}
+		fmt.Println("ignore the incompatible version:", err)
+	}
+
+	if *dataDir != "" {
+		checkErr(pdbackup.RestoreToDataDir(archive, *dataDir, *name, *peerURL, *clientURL))
+		fmt.Printf("pd restore successful! cluster id: %d, data dir: %s\n", archive.ClusterID, *dataDir)
+		return
+	}
+	client := newClient()
+	defer client.Close()
+	checkErr(pdbackup.RestoreToEtcd(client, archive, *force, *removeOtherCluster))
+	if *regionStoragePath != "" {
+		checkErr(pdbackup.RestoreRegionStorage(archive, *regionStoragePath))
+	}
+	fmt.Printf("pd restore successful! cluster id: %d\n", archive.ClusterID)
 }
 
 func checkErr(err error) {
@@ -0,0 +1,351 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pdbackup
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/server/kv"
+	"github.com/tikv/pd/server/versioninfo"
+	"go.etcd.io/etcd/clientv3"
+)
+
+// ArchiveVersion is the version of the archive format, it should be increased
+// once the format is changed incompatibly.
+const ArchiveVersion = 1
+
+// The sections of an archive, which are classified by the storage paths of PD.
+const (
+	SectionMeta             = "meta"
+	SectionStores           = "stores"
+	SectionRegions          = "regions"
+	SectionRegionStorage    = "region-storage"
+	SectionConfig           = "config"
+	SectionSchedule         = "schedule"
+	SectionSchedulerConfigs = "scheduler-configs"
+	SectionPlacement        = "placement"
+	SectionReplication      = "replication"
+	SectionGC               = "gc"
+	SectionComponent        = "component"
+	SectionEncryption       = "encryption"
+	SectionAllocID          = "alloc-id"
+	SectionTSO              = "tso"
+	SectionOthers           = "others"
+)
+
+const (
+	etcdRequestTimeout = 10 * time.Second
+	etcdRangeLimit     = 10000
+)
+
+// Entry is a key-value pair persisted by PD, the key is relative to the root path of the cluster.
+type Entry struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// Section is a group of entries with their checksum.
+type Section struct {
+	Name     string   `json:"name"`
+	Checksum string   `json:"checksum"`
+	Entries  []*Entry `json:"entries"`
+}
+
+// Archive is a consistent snapshot of the metadata persisted by PD.
+type Archive struct {
+	Version int `json:"version"`
+	// ToolVersion is the release version of the tool which creates the archive.
+	ToolVersion string `json:"tool-version"`
+	// ClusterVersion is the cluster version of the backed up cluster.
+	ClusterVersion string     `json:"cluster-version"`
+	ClusterID      uint64     `json:"cluster-id"`
+	Revision       int64      `json:"revision"`
+	CreatedAt      time.Time  `json:"created-at"`
+	Sections       []*Section `json:"sections"`
+}
+
+// GetSection returns the section with the given name.
+func (a *Archive) GetSection(name string) *Section {
+	for _, section := range a.Sections {
+		if section.Name == name {
+			return section
+		}
+	}
+	return nil
+}
+
+// Verify checks whether the checksums of all sections are matched.
+func (a *Archive) Verify() error {
+	if a.Version < 1 || a.Version > ArchiveVersion {
+		return errors.Errorf("unsupported archive version %d, the supported version is %d", a.Version, ArchiveVersion)
+	}
+	for _, section := range a.Sections {
+		if checksum := calcChecksum(section.Entries); checksum != section.Checksum {
+			return errors.Errorf("checksum mismatch in section %s, expect %s but got %s", section.Name, section.Checksum, checksum)
+		}
+	}
+	return nil
+}
+
+// CheckCompatibility checks whether the archive can be restored into a PD with the target version.
+// The check is skipped if either of the versions is unknown.
+func (a *Archive) CheckCompatibility(targetVersion string) error {
+	if a.ClusterVersion == "" || targetVersion == "" || targetVersion == "None" {
+		return nil
+	}
+	source, err := versioninfo.ParseVersion(a.ClusterVersion)
+	if err != nil {
+		return err
+	}
+	target, err := versioninfo.ParseVersion(targetVersion)
+	if err != nil {
+		return err
+	}
+	if !versioninfo.IsCompatible(*source, *target) {
+		return errors.Errorf("the archive is backed up from cluster version %s, which is incompatible with the target version %s", source, target)
+	}
+	return nil
+}
+
+// CreateArchive takes a consistent snapshot of all the keys under the cluster root path at a single etcd revision.
+// The keys attached to a lease, such as the leader keys, are skipped since they are meaningless after restoring.
+// If regionStoragePath is not empty, the region storage will be backed up as well, it must not be opened by
+// a running PD, so back up a copy or stop the PD first.
+func CreateArchive(client *clientv3.Client, regionStoragePath string) (*Archive, error) {
+	clusterID, err := getClusterID(client)
+	if err != nil {
+		return nil, err
+	}
+	archive := &Archive{
+		Version:     ArchiveVersion,
+		ToolVersion: versioninfo.PDReleaseVersion,
+		ClusterID:   clusterID,
+		CreatedAt:   time.Now(),
+	}
+	rootPath := getRootPath(clusterID)
+	sections := make(map[string]*Section)
+	addEntry := func(name string, entry *Entry) {
+		if _, ok := sections[name]; !ok {
+			sections[name] = &Section{Name: name}
+		}
+		sections[name].Entries = append(sections[name].Entries, entry)
+	}
+	var (
+		prefix = rootPath + "/"
+		end    = clientv3.GetPrefixRangeEnd(prefix)
+		key    = prefix
+	)
+	for {
+		opts := []clientv3.OpOption{clientv3.WithRange(end), clientv3.WithLimit(etcdRangeLimit)}
+		// All the pages should be read at the same revision to make the snapshot consistent.
+		if archive.Revision != 0 {
+			opts = append(opts, clientv3.WithRev(archive.Revision))
+		}
+		ctx, cancel := context.WithTimeout(client.Ctx(), etcdRequestTimeout)
+		resp, err := client.Get(ctx, key, opts...)
+		cancel()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if archive.Revision == 0 {
+			archive.Revision = resp.Header.GetRevision()
+		}
+		for _, kv := range resp.Kvs {
+			if kv.Lease != 0 {
+				continue
+			}
+			entry := &Entry{Key: strings.TrimPrefix(string(kv.Key), prefix), Value: kv.Value}
+			addEntry(classify(entry.Key), entry)
+			if entry.Key == configPath {
+				archive.ClusterVersion = parseClusterVersion(entry.Value)
+			}
+		}
+		if !resp.More || len(resp.Kvs) == 0 {
+			break
+		}
+		key = string(append(resp.Kvs[len(resp.Kvs)-1].Key, 0))
+	}
+	if regionStoragePath != "" {
+		entries, err := loadRegionStorage(regionStoragePath)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			addEntry(SectionRegionStorage, entry)
+		}
+	}
+	for _, section := range sections {
+		sort.Slice(section.Entries, func(i, j int) bool { return section.Entries[i].Key < section.Entries[j].Key })
+		section.Checksum = calcChecksum(section.Entries)
+		archive.Sections = append(archive.Sections, section)
+	}
+	sort.Slice(archive.Sections, func(i, j int) bool { return archive.Sections[i].Name < archive.Sections[j].Name })
+	return archive, nil
+}
+
+// WriteArchive writes the archive in the gzipped JSON format.
+func WriteArchive(archive *Archive, w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	if err := json.NewEncoder(gw).Encode(archive); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(gw.Close())
+}
+
+// ReadArchive reads an archive written by WriteArchive and verifies its checksums.
+func ReadArchive(r io.Reader) (*Archive, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer gr.Close()
+	archive := &Archive{}
+	if err := json.NewDecoder(gr).Decode(archive); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := archive.Verify(); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+func getClusterID(client *clientv3.Client) (uint64, error) {
+	ctx, cancel := context.WithTimeout(client.Ctx(), etcdRequestTimeout)
+	defer cancel()
+	resp, err := client.Get(ctx, pdClusterIDPath)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if len(resp.Kvs) == 0 {
+		return 0, errors.Errorf("cluster id not found in %s", pdClusterIDPath)
+	}
+	return typeutil.BytesToUint64(resp.Kvs[0].Value)
+}
+
+func getRootPath(clusterID uint64) string {
+	return path.Join(pdRootPath, strconv.FormatUint(clusterID, 10))
+}
+
+// The storage paths of PD, see server/core/storage.go.
+const (
+	clusterPath              = "raft"
+	configPath               = "config"
+	schedulePath             = "schedule"
+	gcPath                   = "gc"
+	rulesPath                = "rules"
+	ruleGroupPath            = "rule_group"
+	replicationPath          = "replication_mode"
+	componentPath            = "component"
+	customScheduleConfigPath = "scheduler_config"
+	encryptionKeysPath       = "encryption_keys"
+	allocIDPath              = "alloc_id"
+)
+
+func classify(key string) string {
+	hasPrefix := func(prefix string) bool {
+		return key == prefix || strings.HasPrefix(key, prefix+"/")
+	}
+	switch {
+	case hasPrefix(path.Join(clusterPath, "s")):
+		return SectionStores
+	case hasPrefix(path.Join(clusterPath, "r")):
+		return SectionRegions
+	case hasPrefix(clusterPath):
+		return SectionMeta
+	case key == configPath:
+		return SectionConfig
+	case hasPrefix(schedulePath):
+		return SectionSchedule
+	case hasPrefix(customScheduleConfigPath):
+		return SectionSchedulerConfigs
+	case hasPrefix(rulesPath), hasPrefix(ruleGroupPath):
+		return SectionPlacement
+	case hasPrefix(replicationPath):
+		return SectionReplication
+	case hasPrefix(gcPath):
+		return SectionGC
+	case hasPrefix(componentPath):
+		return SectionComponent
+	case hasPrefix(encryptionKeysPath):
+		return SectionEncryption
+	case key == allocIDPath:
+		return SectionAllocID
+	case strings.HasSuffix(key, "timestamp"), strings.Contains(key, "local-tso-suffix"):
+		return SectionTSO
+	default:
+		return SectionOthers
+	}
+}
+
+func parseClusterVersion(value []byte) string {
+	cfg := struct {
+		ClusterVersion string `json:"cluster-version"`
+	}{}
+	if err := json.Unmarshal(value, &cfg); err != nil {
+		return ""
+	}
+	return cfg.ClusterVersion
+}
+
+// calcChecksum calculates the SHA-256 checksum of the entries, each entry is
+// encoded as the length-prefixed key and value.
+func calcChecksum(entries []*Entry) string {
+	h := sha256.New()
+	var buf [8]byte
+	for _, entry := range entries {
+		binary.BigEndian.PutUint64(buf[:], uint64(len(entry.Key)))
+		h.Write(buf[:])
+		h.Write([]byte(entry.Key))
+		binary.BigEndian.PutUint64(buf[:], uint64(len(entry.Value)))
+		h.Write(buf[:])
+		h.Write(entry.Value)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func loadRegionStorage(regionStoragePath string) ([]*Entry, error) {
+	// Opening a leveldb will create it if it doesn't exist.
+	if _, err := os.Stat(regionStoragePath); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	levelDB, err := kv.NewLeveldbKV(regionStoragePath)
+	if err != nil {
+		return nil, err
+	}
+	defer levelDB.Close()
+	iter := levelDB.NewIterator(nil, nil)
+	defer iter.Release()
+	var entries []*Entry
+	for iter.Next() {
+		entries = append(entries, &Entry{
+			Key:   string(iter.Key()),
+			Value: append([]byte(nil), iter.Value()...),
+		})
+	}
+	return entries, errors.WithStack(iter.Error())
+}
@@ -0,0 +1,255 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pdbackup
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/server/kv"
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/embed"
+	"go.etcd.io/etcd/mvcc/mvccpb"
+)
+
+const (
+	// maxTxnOps is less than the default max-txn-ops of etcd.
+	maxTxnOps = 64
+	// regionStorageDir is the directory of the region storage in the PD data dir.
+	regionStorageDir   = "region-meta"
+	embedEtcdReadyTime = time.Minute
+	// tsoSafeMargin is added to the restored timestamp, the same as pd-recover.
+	tsoSafeMargin = time.Second
+)
+
+// RestoreToEtcd writes the archive into etcd. The target etcd should not have any PD cluster
+// unless force is true, in which case the existing cluster is replaced: its cluster ID is removed
+// first, then the keys under the root path of the archived cluster missing from the archive are
+// deleted and the others are overwritten. If the existing cluster has a different cluster ID, its
+// keys are kept unless removeOtherCluster is true.
+// The cluster ID is written at last, so a PD won't start with a partially restored cluster,
+// just run the restore again if it fails halfway.
+// The restored timestamps are bumped to max(archived, existing, now)+tsoSafeMargin, so the
+// TSO won't fall back after restoring.
+// Note that the region storage section is not restored into etcd, use RestoreRegionStorage instead.
+func RestoreToEtcd(client *clientv3.Client, archive *Archive, force, removeOtherCluster bool) error {
+	if err := archive.Verify(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(client.Ctx(), etcdRequestTimeout)
+	resp, err := client.Get(ctx, pdClusterIDPath)
+	cancel()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(resp.Kvs) > 0 && !force {
+		return errors.New("the target etcd already has a PD cluster")
+	}
+	rootPath := getRootPath(archive.ClusterID)
+	ops := make([]clientv3.Op, 0, maxTxnOps)
+	flush := func() error {
+		if len(ops) == 0 {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(client.Ctx(), etcdRequestTimeout)
+		defer cancel()
+		if _, err := client.Txn(ctx).Then(ops...).Commit(); err != nil {
+			return errors.WithStack(err)
+		}
+		ops = ops[:0]
+		return nil
+	}
+	add := func(op clientv3.Op) error {
+		ops = append(ops, op)
+		if len(ops) == maxTxnOps {
+			return flush()
+		}
+		return nil
+	}
+
+	keys := make(map[string]struct{})
+	var maxTS time.Time
+	for _, section := range archive.Sections {
+		if section.Name == SectionRegionStorage {
+			continue
+		}
+		for _, entry := range section.Entries {
+			keys[path.Join(rootPath, entry.Key)] = struct{}{}
+			if isTimestampEntry(section.Name, entry.Key) {
+				ts, err := typeutil.ParseTimestamp(entry.Value)
+				if err != nil {
+					return err
+				}
+				maxTS = maxTime(maxTS, ts)
+			}
+		}
+	}
+	if len(resp.Kvs) > 0 {
+		rootPaths := []string{rootPath}
+		if clusterID, err := typeutil.BytesToUint64(resp.Kvs[0].Value); err == nil && clusterID != archive.ClusterID && removeOtherCluster {
+			rootPaths = append(rootPaths, getRootPath(clusterID))
+		}
+		if err := add(clientv3.OpDelete(pdClusterIDPath)); err != nil {
+			return err
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		for _, p := range rootPaths {
+			err := rangeKeys(client, p+"/", func(kv *mvccpb.KeyValue) error {
+				key := string(kv.Key)
+				if strings.HasSuffix(key, "timestamp") {
+					if ts, err := typeutil.ParseTimestamp(kv.Value); err == nil {
+						maxTS = maxTime(maxTS, ts)
+					}
+				}
+				// The keys attached to a lease belong to the running PDs.
+				if _, ok := keys[key]; ok || kv.Lease != 0 {
+					return nil
+				}
+				return add(clientv3.OpDelete(key))
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	timestamp := maxTime(maxTS, time.Now()).Add(tsoSafeMargin)
+	for _, section := range archive.Sections {
+		if section.Name == SectionRegionStorage {
+			continue
+		}
+		for _, entry := range section.Entries {
+			value := entry.Value
+			if isTimestampEntry(section.Name, entry.Key) {
+				value = typeutil.Uint64ToBytes(uint64(timestamp.UnixNano()))
+			}
+			if err := add(clientv3.OpPut(path.Join(rootPath, entry.Key), string(value))); err != nil {
+				return err
+			}
+		}
+	}
+	ops = append(ops, clientv3.OpPut(pdClusterIDPath, string(typeutil.Uint64ToBytes(archive.ClusterID))))
+	return flush()
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func isTimestampEntry(section, key string) bool {
+	return section == SectionTSO && strings.HasSuffix(key, "timestamp")
+}
+
+// rangeKeys calls f with every key-value pair under the prefix.
+func rangeKeys(client *clientv3.Client, prefix string, f func(*mvccpb.KeyValue) error) error {
+	end := clientv3.GetPrefixRangeEnd(prefix)
+	key := prefix
+	for {
+		ctx, cancel := context.WithTimeout(client.Ctx(), etcdRequestTimeout)
+		resp, err := client.Get(ctx, key, clientv3.WithRange(end), clientv3.WithLimit(etcdRangeLimit))
+		cancel()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for _, kv := range resp.Kvs {
+			if err := f(kv); err != nil {
+				return err
+			}
+		}
+		if !resp.More || len(resp.Kvs) == 0 {
+			return nil
+		}
+		key = string(append(resp.Kvs[len(resp.Kvs)-1].Key, 0))
+	}
+}
+
+// RestoreRegionStorage writes the region storage section of the archive into the region storage at the given path.
+func RestoreRegionStorage(archive *Archive, regionStoragePath string) error {
+	section := archive.GetSection(SectionRegionStorage)
+	if section == nil {
+		return nil
+	}
+	levelDB, err := kv.NewLeveldbKV(regionStoragePath)
+	if err != nil {
+		return err
+	}
+	defer levelDB.Close()
+	for _, entry := range section.Entries {
+		if err := levelDB.Save(entry.Key, string(entry.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreToDataDir restores the archive into a fresh PD data dir. It starts an embedded etcd in the
+// data dir to write the archive and the region storage, the PD should be started with the same name
+// and peer URL later. The client URL is only used during the restoring.
+func RestoreToDataDir(archive *Archive, dataDir, name, peerURL, clientURL string) error {
+	if files, err := os.ReadDir(dataDir); err == nil && len(files) > 0 {
+		return errors.Errorf("the data dir %s is not empty", dataDir)
+	}
+	cfg := embed.NewConfig()
+	cfg.Name = name
+	cfg.Dir = dataDir
+	cfg.Logger = "zap"
+	cfg.LogOutputs = []string{"stderr"}
+	pu, err := url.Parse(peerURL)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	cu, err := url.Parse(clientURL)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	cfg.LPUrls, cfg.APUrls = []url.URL{*pu}, []url.URL{*pu}
+	cfg.LCUrls, cfg.ACUrls = []url.URL{*cu}, []url.URL{*cu}
+	cfg.InitialCluster = fmt.Sprintf("%s=%s", name, peerURL)
+	cfg.ClusterState = embed.ClusterStateFlagNew
+	etcd, err := embed.StartEtcd(cfg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer etcd.Close()
+	select {
+	case <-etcd.Server.ReadyNotify():
+	case <-time.After(embedEtcdReadyTime):
+		return errors.New("the embedded etcd is not ready in time")
+	}
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{clientURL},
+		DialTimeout: etcdRequestTimeout,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer client.Close()
+	if err := RestoreToEtcd(client, archive, false, false); err != nil {
+		return err
+	}
+	return RestoreRegionStorage(archive, filepath.Join(dataDir, regionStorageDir))
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	diagnosePrefix = "pd/api/v1/diagnose"
+)
+
+// NewDiagnoseCommand return a diagnose subcommand of rootCmd
+func NewDiagnoseCommand() *cobra.Command {
+	d := &cobra.Command{
+		Use:   "diagnose [<check>...]",
+		Short: "diagnose the cluster with the given checks, or all the checks if no check is given",
+		Run:   diagnoseCommandFunc,
+	}
+	d.AddCommand(NewDiagnoseChecksCommand())
+	return d
+}
+
+// NewDiagnoseChecksCommand return a subcommand to list all the diagnose checks
+func NewDiagnoseChecksCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "checks",
+		Short: "list all the diagnose checks",
+		Run:   showDiagnoseChecksCommandFunc,
+	}
+}
+
+func diagnoseCommandFunc(cmd *cobra.Command, args []string) {
+	prefix := diagnosePrefix
+	if len(args) > 0 {
+		prefix += "?checks=" + url.QueryEscape(strings.Join(args, ","))
+	}
+	r, err := doRequest(cmd, prefix, http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to diagnose: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
+
+func showDiagnoseChecksCommandFunc(cmd *cobra.Command, args []string) {
+	r, err := doRequest(cmd, diagnosePrefix+"/checks", http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to get diagnose checks: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
@@ -14,6 +14,8 @@
 package command
 
 import (
+	"bytes"
+	"encoding/json"
 	"net/http"
 
 	"github.com/spf13/cobra"
@@ -31,6 +33,40 @@ func NewServiceGCSafepointCommand() *cobra.Command {
 		Run:   showSSPs,
 	}
 	l.AddCommand(NewDeleteServiceGCSafepointCommand())
+	l.AddCommand(NewShowServiceGCSafepointBlockerCommand())
+	l.AddCommand(NewShowServiceGCSafepointStatusCommand())
+	l.AddCommand(NewExpireServiceGCSafepointCommand())
+	return l
+}
+
+// NewShowServiceGCSafepointBlockerCommand return a subcommand to show the service which is blocking GC
+func NewShowServiceGCSafepointBlockerCommand() *cobra.Command {
+	l := &cobra.Command{
+		Use:   "blocker",
+		Short: "show the service with the minimum gc safepoint, which is blocking GC",
+		Run:   showSSPBlocker,
+	}
+	return l
+}
+
+// NewShowServiceGCSafepointStatusCommand return a subcommand to show the status and history of a service gc safepoint
+func NewShowServiceGCSafepointStatusCommand() *cobra.Command {
+	l := &cobra.Command{
+		Use:   "show <service ID>",
+		Short: "show the status and update history of a service gc safepoint",
+		Run:   showSSPStatus,
+	}
+	return l
+}
+
+// NewExpireServiceGCSafepointCommand return a subcommand to expire service gc safepoints
+func NewExpireServiceGCSafepointCommand() *cobra.Command {
+	l := &cobra.Command{
+		Use:   "expire [<service ID>|--stale]",
+		Short: "forcibly expire a service gc safepoint, or all the stale ones which lag more than the max lag",
+		Run:   expireSSP,
+	}
+	l.Flags().Bool("stale", false, "expire all the stale service gc safepoints")
 	return l
 }
 
@@ -68,3 +104,59 @@ func deleteSSP(cmd *cobra.Command, args []string) {
 	}
 	cmd.Println(r)
 }
+
+func showSSPBlocker(cmd *cobra.Command, args []string) {
+	r, err := doRequest(cmd, serviceGCSafepointPrefix, http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to get service GC safepoint: %s\n", err)
+		return
+	}
+	list := struct {
+		Min json.RawMessage `json:"min_service_gc_safe_point"`
+	}{}
+	if err := json.Unmarshal([]byte(r), &list); err != nil {
+		cmd.Printf("Failed to unmarshal service GC safepoint: %s\n", err)
+		return
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, list.Min, "", "  "); err != nil {
+		cmd.Printf("Failed to format service GC safepoint: %s\n", err)
+		return
+	}
+	cmd.Println(out.String())
+}
+
+func showSSPStatus(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		return
+	}
+	r, err := doRequest(cmd, serviceGCSafepointPrefix+"/"+args[0], http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to get service GC safepoint: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
+
+func expireSSP(cmd *cobra.Command, args []string) {
+	stale, err := cmd.Flags().GetBool("stale")
+	if err != nil {
+		cmd.Println(err)
+		return
+	}
+	if stale == (len(args) == 1) || len(args) > 1 {
+		cmd.Usage()
+		return
+	}
+	if stale {
+		r, err := doRequest(cmd, serviceGCSafepointPrefix+"/expire-stale", http.MethodPost)
+		if err != nil {
+			cmd.Printf("Failed to expire stale service GC safepoints: %s\n", err)
+			return
+		}
+		cmd.Println(r)
+		return
+	}
+	deleteSSP(cmd, args)
+}
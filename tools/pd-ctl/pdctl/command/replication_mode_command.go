@@ -0,0 +1,90 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	replicationModeAPIPrefix = "pd/api/v1/replication_mode"
+)
+
+// NewReplicationModeCommand return a replication mode subcommand of rootCmd
+func NewReplicationModeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replication-mode",
+		Short: "show the replication mode status",
+		Run:   showReplicationModeStatusCommandFunc,
+	}
+	cmd.AddCommand(NewReplicationModeHistoryCommand())
+	cmd.AddCommand(NewSwitchReplicationStateCommand())
+	return cmd
+}
+
+// NewReplicationModeHistoryCommand return a subcommand to show the state transition history
+func NewReplicationModeHistoryCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "history",
+		Short: "show the state transition history of the replication mode",
+		Run:   showReplicationModeHistoryCommandFunc,
+	}
+}
+
+// NewSwitchReplicationStateCommand return a subcommand to force switching the replication state
+func NewSwitchReplicationStateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "switch-state <sync|async|sync_recover> <reason>",
+		Short: "force the replication mode to switch to the state",
+		Run:   switchReplicationStateCommandFunc,
+	}
+	cmd.Flags().String("hold", "", "pause the automatic state switching for a duration, such as 30m")
+	return cmd
+}
+
+func showReplicationModeStatusCommandFunc(cmd *cobra.Command, args []string) {
+	r, err := doRequest(cmd, replicationModeAPIPrefix+"/status", http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to get replication mode status: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
+
+func showReplicationModeHistoryCommandFunc(cmd *cobra.Command, args []string) {
+	r, err := doRequest(cmd, replicationModeAPIPrefix+"/history", http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to get replication mode history: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
+
+func switchReplicationStateCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) < 2 {
+		cmd.Usage()
+		return
+	}
+	input := map[string]interface{}{
+		"state":  args[0],
+		"reason": strings.Join(args[1:], " "),
+	}
+	if hold, _ := cmd.Flags().GetString("hold"); hold != "" {
+		input["hold"] = hold
+	}
+	postJSON(cmd, replicationModeAPIPrefix+"/state", input)
+}
@@ -414,6 +414,8 @@ func NewConfigSchedulerCommand() *cobra.Command {
 		newConfigGrantLeaderCommand(),
 		newConfigHotRegionCommand(),
 		newConfigShuffleRegionCommand(),
+		newConfigBalanceGroupCommand("balance-leader-scheduler"),
+		newConfigBalanceGroupCommand("balance-region-scheduler"),
 	)
 	return c
 }
@@ -489,6 +491,64 @@ func newConfigShuffleRegionCommand() *cobra.Command {
 	return c
 }
 
+func newConfigBalanceGroupCommand(schedulerName string) *cobra.Command {
+	c := &cobra.Command{
+		Use:   schedulerName,
+		Short: schedulerName + " config",
+		Run:   listSchedulerConfigCommandFunc,
+	}
+	c.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "list the config item",
+		Run:   listSchedulerConfigCommandFunc,
+	}, &cobra.Command{
+		Use:   "set-label-key <key>",
+		Short: "set the label key to group the stores, the stores are balanced within each group",
+		Run:   func(cmd *cobra.Command, args []string) { setBalanceGroupLabelKeyCommandFunc(cmd, schedulerName, args) },
+	}, &cobra.Command{
+		Use:   "set-target-ratios [<label-value>=<ratio>[,<label-value>=<ratio>]]",
+		Short: "set the target ratios of the groups which are balanced together, clear them if no ratio is given",
+		Run: func(cmd *cobra.Command, args []string) {
+			setBalanceGroupTargetRatiosCommandFunc(cmd, schedulerName, args)
+		},
+	})
+	return c
+}
+
+func setBalanceGroupLabelKeyCommandFunc(cmd *cobra.Command, schedulerName string, args []string) {
+	if len(args) != 1 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	input := map[string]interface{}{"label-key": args[0]}
+	postJSON(cmd, path.Join(schedulerConfigPrefix, schedulerName, "config"), input)
+}
+
+func setBalanceGroupTargetRatiosCommandFunc(cmd *cobra.Command, schedulerName string, args []string) {
+	if len(args) > 1 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	ratios := make(map[string]float64)
+	if len(args) == 1 {
+		for _, item := range strings.Split(args[0], ",") {
+			kv := strings.SplitN(item, "=", 2)
+			if len(kv) != 2 {
+				cmd.Printf("invalid target ratio: %s\n", item)
+				return
+			}
+			ratio, err := strconv.ParseFloat(kv[1], 64)
+			if err != nil || ratio <= 0 {
+				cmd.Printf("invalid target ratio: %s\n", item)
+				return
+			}
+			ratios[kv[0]] = ratio
+		}
+	}
+	input := map[string]interface{}{"target-ratios": ratios}
+	postJSON(cmd, path.Join(schedulerConfigPrefix, schedulerName, "config"), input)
+}
+
 func addStoreToSchedulerConfig(cmd *cobra.Command, schedulerName string, args []string) {
 	if len(args) != 1 {
 		cmd.Println(cmd.UsageString())
@@ -17,6 +17,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"path"
 	"strconv"
 	"strings"
@@ -45,6 +46,7 @@ func NewStoreCommand() *cobra.Command {
 	s.AddCommand(NewRemoveTombStoneCommand())
 	s.AddCommand(NewStoreLimitSceneCommand())
 	s.AddCommand(NewStoreCheckCommand())
+	s.AddCommand(NewStoreCapacityForecastCommand())
 	s.Flags().String("jq", "", "jq query")
 	s.Flags().StringSlice("state", nil, "state filter")
 	return s
@@ -112,6 +114,18 @@ func NewStoreCheckCommand() *cobra.Command {
 	return d
 }
 
+// NewStoreCapacityForecastCommand returns a capacity-forecast subcommand of storeCmd.
+func NewStoreCapacityForecastCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "capacity-forecast [--label-key=<key>]",
+		Short: "forecast when the stores run out of space, optionally grouped by a label",
+		Run:   storeCapacityForecastCommandFunc,
+	}
+	c.Flags().String("label-key", "", "group the stores by the value of the label")
+	c.Flags().String("jq", "", "jq query")
+	return c
+}
+
 // NewStoresCommand returns a store subcommand of rootCmd
 func NewStoresCommand() *cobra.Command {
 	s := &cobra.Command{
@@ -511,6 +525,27 @@ func showAllStoresLimitCommandFunc(cmd *cobra.Command, args []string) {
 	cmd.Println(r)
 }
 
+func storeCapacityForecastCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Usage()
+		return
+	}
+	prefix := path.Join(storesPrefix, "capacity-forecast")
+	if labelKey, _ := cmd.Flags().GetString("label-key"); labelKey != "" {
+		prefix += "?label-key=" + url.QueryEscape(labelKey)
+	}
+	r, err := doRequest(cmd, prefix, http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to get capacity forecast: %s\n", err)
+		return
+	}
+	if flag := cmd.Flag("jq"); flag != nil && flag.Value.String() != "" {
+		printWithJQFilter(r, flag.Value.String())
+		return
+	}
+	cmd.Println(r)
+}
+
 func removeTombStoneCommandFunc(cmd *cobra.Command, args []string) {
 	prefix := path.Join(storesPrefix, "remove-tombstone")
 	_, err := doRequest(cmd, prefix, http.MethodDelete)
@@ -14,12 +14,19 @@
 package command
 
 import (
+	"fmt"
+	"net/http"
 	"strconv"
 
 	"github.com/spf13/cobra"
 	"github.com/tikv/pd/pkg/tsoutil"
 )
 
+var (
+	dcLocationsPrefix = "pd/api/v1/tso/dc-locations"
+	dcLocationPrefix  = "pd/api/v1/tso/dc-location/%s"
+)
+
 // NewTSOCommand return a ping subcommand of rootCmd
 func NewTSOCommand() *cobra.Command {
 	cmd := &cobra.Command{
@@ -27,6 +34,31 @@ func NewTSOCommand() *cobra.Command {
 		Short: "parse TSO to the system and logic time",
 		Run:   showTSOCommandFunc,
 	}
+	cmd.AddCommand(NewDCLocationCommand())
+	return cmd
+}
+
+// NewDCLocationCommand return a dc-location subcommand of tsoCmd
+func NewDCLocationCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dc-location <subcommand>",
+		Short: "manage the dc-locations of Local TSO",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show",
+		Short: "show all dc-locations with their members and Local TSO suffixes",
+		Run:   showDCLocationsCommandFunc,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "migrate <source_dc_location> <target_dc_location> [<member_name>...]",
+		Short: "migrate the members of a dc-location to another one, all members will be migrated if no member is specified",
+		Run:   migrateDCLocationCommandFunc,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "remove <dc_location>",
+		Short: "remove a dc-location without any member and reclaim its Local TSO suffix",
+		Run:   removeDCLocationCommandFunc,
+	})
 	return cmd
 }
 
@@ -45,3 +77,37 @@ func showTSOCommandFunc(cmd *cobra.Command, args []string) {
 	cmd.Println("system: ", physicalTime)
 	cmd.Println("logic:  ", logical)
 }
+
+func showDCLocationsCommandFunc(cmd *cobra.Command, args []string) {
+	r, err := doRequest(cmd, dcLocationsPrefix, http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to get dc-locations: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
+
+func migrateDCLocationCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) < 2 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	input := map[string]interface{}{
+		"target":  args[1],
+		"members": args[2:],
+	}
+	postJSON(cmd, fmt.Sprintf(dcLocationPrefix, args[0])+"/migrate", input)
+}
+
+func removeDCLocationCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	_, err := doRequest(cmd, fmt.Sprintf(dcLocationPrefix, args[0]), http.MethodDelete)
+	if err != nil {
+		cmd.Printf("Failed to remove dc-location: %s\n", err)
+		return
+	}
+	cmd.Println("Success!")
+}
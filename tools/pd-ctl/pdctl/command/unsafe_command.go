@@ -0,0 +1,112 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var unsafePrefix = "pd/api/v1/admin/unsafe"
+
+// NewUnsafeCommand returns the unsafe subcommand of rootCmd.
+func NewUnsafeCommand() *cobra.Command {
+	unsafeCmd := &cobra.Command{
+		Use:   "unsafe [command]",
+		Short: "Unsafe operations",
+	}
+	unsafeCmd.AddCommand(NewRemoveFailedStoresCommand())
+	unsafeCmd.AddCommand(NewStoreRecoveryPlanCommand())
+	return unsafeCmd
+}
+
+// NewRemoveFailedStoresCommand returns the unsafe remove-failed-stores command.
+func NewRemoveFailedStoresCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remove-failed-stores <store_id1>[,<store_id2>,...]",
+		Short: "Remove the permanently failed stores and recover the regions which lose the majority of their peers",
+		Run:   removeFailedStoresCommandFunc,
+	}
+	cmd.Flags().Int64("timeout", 0, "timeout of the recovery in seconds, 600 if not set")
+	cmd.AddCommand(NewRemoveFailedStoresShowCommand())
+	return cmd
+}
+
+// NewRemoveFailedStoresShowCommand returns the unsafe remove-failed-stores show command.
+func NewRemoveFailedStoresShowCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show",
+		Short: "Show the status of the unsafe recovery",
+		Run:   removeFailedStoresShowCommandFunc,
+	}
+}
+
+// NewStoreRecoveryPlanCommand returns the unsafe plan command.
+func NewStoreRecoveryPlanCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "plan <store_id>",
+		Short: "Show the recovery plan the store needs to apply",
+		Run:   showStoreRecoveryPlanCommandFunc,
+	}
+}
+
+func removeFailedStoresCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		return
+	}
+	var stores []uint64
+	for _, s := range strings.Split(args[0], ",") {
+		storeID, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			cmd.Printf("Invalid store id %s: %s\n", s, err)
+			return
+		}
+		stores = append(stores, storeID)
+	}
+	input := map[string]interface{}{"stores": stores}
+	if timeout, _ := cmd.Flags().GetInt64("timeout"); timeout != 0 {
+		input["timeout"] = timeout
+	}
+	postJSON(cmd, unsafePrefix+"/remove-failed-stores", input)
+}
+
+func removeFailedStoresShowCommandFunc(cmd *cobra.Command, args []string) {
+	r, err := doRequest(cmd, unsafePrefix+"/remove-failed-stores/show", http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to get the unsafe recovery status: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
+
+func showStoreRecoveryPlanCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		return
+	}
+	if _, err := strconv.ParseUint(args[0], 10, 64); err != nil {
+		cmd.Printf("Invalid store id %s: %s\n", args[0], err)
+		return
+	}
+	r, err := doRequest(cmd, unsafePrefix+"/plan/"+args[0], http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to get the recovery plan: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
@@ -58,9 +58,12 @@ func GetRootCmd() *cobra.Command {
 		command.NewHotSpotCommand(),
 		command.NewClusterCommand(),
 		command.NewHealthCommand(),
+		command.NewDiagnoseCommand(),
 		command.NewLogCommand(),
 		command.NewPluginCommand(),
 		command.NewServiceGCSafepointCommand(),
+		command.NewReplicationModeCommand(),
+		command.NewUnsafeCommand(),
 		command.NewCompletionCommand(),
 	)
 
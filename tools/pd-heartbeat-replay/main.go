@@ -0,0 +1,504 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/server/hbcapture"
+	"go.etcd.io/etcd/pkg/report"
+	"google.golang.org/grpc"
+)
+
+var (
+	pdAddr       = flag.String("pd", "127.0.0.1:2379", "pd address")
+	captureFile  = flag.String("file", "", "the heartbeat capture file to replay")
+	speed        = flag.Float64("speed", 1, "the replay speed relative to the capture, 0 means as fast as possible")
+	storeVersion = flag.String("store-version", "5.0.0", "the version of the stores whose metas are not captured")
+	output       = flag.String("output", "", "the file to write the scheduling decisions as json lines")
+	wait         = flag.Duration("wait", 3*time.Second, "the time to wait for the scheduling decisions after the replay")
+)
+
+var clusterID uint64
+
+func newClient() pdpb.PDClient {
+	cc, err := grpc.Dial(*pdAddr, grpc.WithInsecure())
+	if err != nil {
+		log.Fatal(err)
+	}
+	return pdpb.NewPDClient(cc)
+}
+
+func initClusterID(cli pdpb.PDClient) {
+	res, err := cli.GetMembers(context.TODO(), &pdpb.GetMembersRequest{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	clusterID = res.GetHeader().GetClusterId()
+	log.Println("ClusterID:", clusterID)
+}
+
+func header() *pdpb.RequestHeader {
+	return &pdpb.RequestHeader{
+		ClusterId: clusterID,
+	}
+}
+
+func openCapture() (*os.File, *hbcapture.Reader) {
+	f, err := os.Open(*captureFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r, err := hbcapture.NewReader(bufio.NewReader(f))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return f, r
+}
+
+// next reads the next record, it returns nil at the end of the capture. A
+// truncated capture is replayed until the last complete record.
+func next(r *hbcapture.Reader) *hbcapture.Record {
+	record, err := r.Next()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		log.Printf("stop reading the capture: %v", err)
+		return nil
+	}
+	return record
+}
+
+// captureInfo is collected by scanning the capture before the replay.
+type captureInfo struct {
+	stores      map[uint64]*metapb.Store
+	maxRegionID uint64
+	maxPeerID   uint64
+	regions     int
+	storeHBs    int
+	start, end  time.Time
+}
+
+func scan() *captureInfo {
+	f, r := openCapture()
+	defer f.Close()
+	info := &captureInfo{stores: make(map[uint64]*metapb.Store)}
+	addStore := func(id uint64) {
+		if _, ok := info.stores[id]; !ok && id != 0 {
+			info.stores[id] = nil
+		}
+	}
+	for record := next(r); record != nil; record = next(r) {
+		switch record.Type() {
+		case hbcapture.StoreMeta:
+			info.stores[record.StoreMeta.GetId()] = record.StoreMeta
+			continue
+		case hbcapture.StoreHeartbeat:
+			info.storeHBs++
+			addStore(record.Store.GetStats().GetStoreId())
+		case hbcapture.RegionHeartbeat:
+			info.regions++
+			region := record.Region.GetRegion()
+			if region.GetId() > info.maxRegionID {
+				info.maxRegionID = region.GetId()
+			}
+			for _, peer := range region.GetPeers() {
+				addStore(peer.GetStoreId())
+				if peer.GetId() > info.maxPeerID {
+					info.maxPeerID = peer.GetId()
+				}
+			}
+		}
+		if info.start.IsZero() {
+			info.start = record.Time
+		}
+		info.end = record.Time
+	}
+	return info
+}
+
+func storeMeta(info *captureInfo, id uint64) *metapb.Store {
+	if store := info.stores[id]; store != nil {
+		return store
+	}
+	return &metapb.Store{
+		Id:      id,
+		Address: fmt.Sprintf("replay-store-%d:20160", id),
+		Version: *storeVersion,
+	}
+}
+
+func sortedStoreIDs(info *captureInfo) []uint64 {
+	ids := make([]uint64, 0, len(info.stores))
+	for id := range info.stores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// bootstrap bootstraps the cluster with a placeholder region which covers the
+// whole key space, it is replaced by the captured regions with the newer epochs.
+func bootstrap(cli pdpb.PDClient, info *captureInfo) {
+	isBootstrapped, err := cli.IsBootstrapped(context.TODO(), &pdpb.IsBootstrappedRequest{Header: header()})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if isBootstrapped.GetBootstrapped() {
+		log.Println("already bootstrapped")
+		return
+	}
+	ids := sortedStoreIDs(info)
+	if len(ids) == 0 {
+		log.Fatal("no store is found in the capture")
+	}
+	store := storeMeta(info, ids[0])
+	region := &metapb.Region{
+		Id:          info.maxRegionID + 1,
+		Peers:       []*metapb.Peer{{Id: info.maxPeerID + 1, StoreId: store.GetId()}},
+		RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 1},
+	}
+	req := &pdpb.BootstrapRequest{
+		Header: header(),
+		Store:  store,
+		Region: region,
+	}
+	if _, err = cli.Bootstrap(context.TODO(), req); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("bootstrapped")
+}
+
+func putStores(cli pdpb.PDClient, info *captureInfo) {
+	for _, id := range sortedStoreIDs(info) {
+		store := storeMeta(info, id)
+		if store.GetState() == metapb.StoreState_Tombstone {
+			continue
+		}
+		resp, err := cli.PutStore(context.TODO(), &pdpb.PutStoreRequest{Header: header(), Store: store})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if resp.GetHeader().GetError() != nil {
+			log.Printf("failed to put store %d: %v", id, resp.GetHeader().GetError())
+		}
+	}
+}
+
+// decision is a scheduling decision sent by PD in the region heartbeat response.
+type decision struct {
+	Time     time.Time `json:"time"`
+	RegionID uint64    `json:"region-id"`
+	Kind     string    `json:"kind"`
+	Detail   string    `json:"detail"`
+}
+
+func decisionKind(resp *pdpb.RegionHeartbeatResponse) string {
+	switch {
+	case resp.GetHeader().GetError() != nil:
+		return "error"
+	case resp.GetChangePeer() != nil:
+		return "change-peer"
+	case resp.GetChangePeerV2() != nil:
+		return "change-peer-v2"
+	case resp.GetTransferLeader() != nil:
+		return "transfer-leader"
+	case resp.GetMerge() != nil:
+		return "merge"
+	case resp.GetSplitRegion() != nil:
+		return "split-region"
+	default:
+		return ""
+	}
+}
+
+// decisionRecorder records the decisions. The operators are never finished as
+// there is no TiKV to execute them, so PD keeps sending the same step in every
+// heartbeat, and only the changed decisions of a region are recorded.
+type decisionRecorder struct {
+	sync.Mutex
+	counts map[string]int
+	last   map[uint64]string
+	out    *json.Encoder
+	// err is the first error of writing the output, the decisions are no longer
+	// written after it.
+	err error
+}
+
+func (d *decisionRecorder) record(resp *pdpb.RegionHeartbeatResponse) {
+	kind := decisionKind(resp)
+	if kind == "" {
+		return
+	}
+	detail := resp.String()
+	d.Lock()
+	defer d.Unlock()
+	if d.last[resp.GetRegionId()] == detail {
+		return
+	}
+	d.last[resp.GetRegionId()] = detail
+	d.counts[kind]++
+	if d.out != nil && d.err == nil {
+		if err := d.out.Encode(&decision{Time: time.Now(), RegionID: resp.GetRegionId(), Kind: kind, Detail: detail}); err != nil {
+			log.Printf("failed to write the decision, stop writing the output: %v", err)
+			d.err = err
+		}
+	}
+}
+
+func (d *decisionRecorder) print() {
+	d.Lock()
+	defer d.Unlock()
+	kinds := make([]string, 0, len(d.counts))
+	for kind := range d.counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	log.Println("Scheduling decisions:")
+	for _, kind := range kinds {
+		log.Printf("  %-16s %d", kind, d.counts[kind])
+	}
+}
+
+// replayer sends the captured heartbeats to PD. The region heartbeats are sent
+// by the streams of their leader stores like TiKV.
+type replayer struct {
+	cli       pdpb.PDClient
+	ctx       context.Context
+	streams   map[uint64]pdpb.PD_RegionHeartbeatClient
+	decisions *decisionRecorder
+	// sendReport only measures the time to send the region heartbeats into the
+	// streams, PD responds to a region heartbeat only if it has a decision, which
+	// is measured by decisionReport from the last heartbeat of the region.
+	sendReport     report.Report
+	decisionReport report.Report
+	storeReport    report.Report
+	errors         int
+	wg             sync.WaitGroup
+
+	sentMu sync.Mutex
+	sent   map[uint64]time.Time
+}
+
+func (r *replayer) getStream(storeID uint64) pdpb.PD_RegionHeartbeatClient {
+	if stream, ok := r.streams[storeID]; ok {
+		return stream
+	}
+	stream, err := r.cli.RegionHeartbeat(r.ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r.streams[storeID] = stream
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			r.sentMu.Lock()
+			start, ok := r.sent[resp.GetRegionId()]
+			r.sentMu.Unlock()
+			if ok {
+				r.decisionReport.Results() <- report.Result{Start: start, End: time.Now()}
+			}
+			r.decisions.record(resp)
+		}
+	}()
+	return stream
+}
+
+// shiftInterval moves the interval to end at now and keeps its length, so that
+// the flow is calculated in the same way as the capture.
+func shiftInterval(interval *pdpb.TimeInterval, now uint64) {
+	if interval == nil || interval.GetEndTimestamp() < interval.GetStartTimestamp() {
+		return
+	}
+	length := interval.GetEndTimestamp() - interval.GetStartTimestamp()
+	interval.EndTimestamp = now
+	interval.StartTimestamp = now - length
+}
+
+func (r *replayer) replayRegionHeartbeat(req *pdpb.RegionHeartbeatRequest) {
+	req.Header = header()
+	shiftInterval(req.GetInterval(), uint64(time.Now().Unix()))
+	storeID := req.GetLeader().GetStoreId()
+	stream := r.getStream(storeID)
+	start := time.Now()
+	r.sentMu.Lock()
+	r.sent[req.GetRegion().GetId()] = start
+	r.sentMu.Unlock()
+	err := stream.Send(req)
+	r.sendReport.Results() <- report.Result{Start: start, End: time.Now(), Err: err}
+	if err != nil {
+		r.errors++
+		log.Printf("failed to send region heartbeat of store %d: %v", storeID, err)
+		// The stream is recreated by the next heartbeat.
+		delete(r.streams, storeID)
+	}
+}
+
+func (r *replayer) replayStoreHeartbeat(req *pdpb.StoreHeartbeatRequest) {
+	req.Header = header()
+	shiftInterval(req.GetStats().GetInterval(), uint64(time.Now().Unix()))
+	start := time.Now()
+	resp, err := r.cli.StoreHeartbeat(r.ctx, req)
+	if err == nil && resp.GetHeader().GetError() != nil {
+		err = fmt.Errorf("%v", resp.GetHeader().GetError())
+	}
+	r.storeReport.Results() <- report.Result{Start: start, End: time.Now(), Err: err}
+	if err != nil {
+		r.errors++
+	}
+}
+
+func (r *replayer) close() {
+	for _, stream := range r.streams {
+		if err := stream.CloseSend(); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func replay(cli pdpb.PDClient, info *captureInfo, decisions *decisionRecorder) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &replayer{
+		cli:            cli,
+		ctx:            ctx,
+		streams:        make(map[uint64]pdpb.PD_RegionHeartbeatClient),
+		decisions:      decisions,
+		sendReport:     report.NewReport("%4.4f"),
+		decisionReport: report.NewReport("%4.4f"),
+		storeReport:    report.NewReport("%4.4f"),
+		sent:           make(map[uint64]time.Time),
+	}
+	sendStats := r.sendReport.Run()
+	decisionStats := r.decisionReport.Run()
+	storeStats := r.storeReport.Run()
+
+	f, reader := openCapture()
+	defer f.Close()
+	var (
+		start  = time.Now()
+		maxLag time.Duration
+	)
+	for record := next(reader); record != nil; record = next(reader) {
+		if record.Type() == hbcapture.StoreMeta {
+			continue
+		}
+		if *speed > 0 {
+			due := start.Add(time.Duration(float64(record.Time.Sub(info.start)) / *speed))
+			if d := time.Until(due); d > 0 {
+				time.Sleep(d)
+			} else if -d > maxLag {
+				maxLag = -d
+			}
+		}
+		if record.Type() == hbcapture.RegionHeartbeat {
+			r.replayRegionHeartbeat(record.Region)
+		} else {
+			r.replayStoreHeartbeat(record.Store)
+		}
+	}
+	elapsed := time.Since(start)
+
+	// Wait for the decisions of the last heartbeats.
+	time.Sleep(*wait)
+	r.close()
+	cancel()
+	r.wg.Wait()
+
+	close(r.sendReport.Results())
+	close(r.decisionReport.Results())
+	close(r.storeReport.Results())
+	log.Printf("Replayed %d region heartbeats and %d store heartbeats captured in %v within %v, max lag %v, %d errors",
+		info.regions, info.storeHBs, info.end.Sub(info.start), elapsed, maxLag, r.errors)
+	log.Println("\n--------- Region heartbeat send latency ----------")
+	log.Println(<-sendStats)
+	log.Println("\n--------- Region heartbeat decision latency ----------")
+	log.Println(<-decisionStats)
+	log.Println("\n--------- Store heartbeat latency ----------")
+	log.Println(<-storeStats)
+}
+
+func main() {
+	log.SetFlags(0)
+	flag.Parse()
+	if *captureFile == "" {
+		log.Fatal("the capture file is required")
+	}
+	if *speed < 0 {
+		log.Fatal("the speed should not be negative")
+	}
+
+	info := scan()
+	log.Printf("capture: %d stores, %d region heartbeats, %d store heartbeats, from %v to %v",
+		len(info.stores), info.regions, info.storeHBs, info.start, info.end)
+
+	cli := newClient()
+	initClusterID(cli)
+	bootstrap(cli, info)
+	putStores(cli, info)
+
+	decisions := &decisionRecorder{
+		counts: make(map[string]int),
+		last:   make(map[uint64]string),
+	}
+	var (
+		out *os.File
+		w   *bufio.Writer
+	)
+	if *output != "" {
+		var err error
+		out, err = os.Create(*output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		w = bufio.NewWriter(out)
+		decisions.out = json.NewEncoder(w)
+	}
+
+	replay(cli, info, decisions)
+	decisions.print()
+
+	// The output is flushed before exiting even if it fails to be written, so the
+	// decisions written before the failure are kept.
+	if out != nil {
+		err := w.Flush()
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = decisions.err
+		}
+		if err != nil {
+			log.Fatalf("failed to write the decisions to %s: %v", *output, err)
+		}
+	}
+}
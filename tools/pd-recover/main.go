@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/pingcap/errors"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/tikv/pd/pkg/typeutil"
 	"github.com/tikv/pd/server"
@@ -25,6 +26,11 @@ var (
 	caPath    string
 	certPath  string
 	keyPath   string
+
+	backupFile    string
+	storeDumps    string
+	allocIDMargin uint64
+	dryRun        bool
 )
 
 const (
@@ -49,6 +55,10 @@ func main() {
 	fs.StringVar(&caPath, "cacert", "", "path of file that contains list of trusted SSL CAs")
 	fs.StringVar(&certPath, "cert", "", "path of file that contains list of trusted SSL CAs")
 	fs.StringVar(&keyPath, "key", "", "path of file that contains X509 key in PEM format")
+	fs.StringVar(&backupFile, "backup-file", "", "derive the cluster ID, alloc ID and timestamp from the file produced by pd-backup")
+	fs.StringVar(&storeDumps, "store-dumps", "", "derive the cluster ID, alloc ID and timestamp from the comma separated TiKV store metadata dump files")
+	fs.Uint64Var(&allocIDMargin, "alloc-id-margin", 1000000, "safety margin added to the max seen ID when deriving the alloc ID")
+	fs.BoolVar(&dryRun, "dry-run", false, "only print the recovery plan without writing it")
 
 	if len(os.Args[1:]) == 0 {
 		fs.Usage()
@@ -61,14 +71,32 @@ func main() {
 		server.PrintPDInfo()
 		return
 	}
-	if clusterID == 0 {
-		fmt.Println("please specify safe cluster-id")
-		return
+	var sources []*recoverSource
+	if backupFile != "" {
+		source, err := loadBackupFile(backupFile)
+		if err != nil {
+			exitErr(err)
+		}
+		sources = append(sources, source)
+	}
+	if storeDumps != "" {
+		for _, file := range strings.Split(storeDumps, ",") {
+			source, err := loadStoreDump(file)
+			if err != nil {
+				exitErr(err)
+			}
+			sources = append(sources, source)
+		}
+	}
+	plan, err := newRecoverPlan(sources, clusterID, allocID, allocIDMargin, time.Now())
+	if err != nil {
+		exitErr(err)
 	}
-	if allocID == 0 {
-		fmt.Println("please specify safe alloc-id")
+	fmt.Print(plan)
+	if dryRun {
 		return
 	}
+	clusterID, allocID = plan.clusterID, plan.allocID
 
 	rootPath := path.Join(pdRootPath, strconv.FormatUint(clusterID, 10))
 	clusterRootPath := path.Join(rootPath, "raft")
@@ -114,6 +142,10 @@ func main() {
 	}
 	ops = append(ops, clientv3.OpPut(clusterRootPath, string(clusterValue)))
 
+	// recover the timestamp, which is larger than the max seen TSO
+	timestampPath := path.Join(rootPath, "timestamp")
+	ops = append(ops, clientv3.OpPut(timestampPath, string(typeutil.Uint64ToBytes(uint64(plan.timestamp.UnixNano())))))
+
 	// set raft bootstrap time
 	nano := time.Now().UnixNano()
 	timeData := typeutil.Uint64ToBytes(uint64(nano))
@@ -129,5 +161,20 @@ func main() {
 		fmt.Println("failed to recover: the cluster is already bootstrapped")
 		return
 	}
+	// verify the persisted timestamp
+	tsResp, err := client.Get(ctx, timestampPath)
+	if err != nil {
+		exitErr(err)
+	}
+	if len(tsResp.Kvs) == 0 {
+		exitErr(errors.New("failed to recover: the timestamp is not persisted"))
+	}
+	persisted, err := typeutil.ParseTimestamp(tsResp.Kvs[0].Value)
+	if err != nil {
+		exitErr(err)
+	}
+	if !persisted.After(plan.maxSeenTS) {
+		exitErr(errors.Errorf("failed to recover: the persisted timestamp %s is not larger than the max seen tso %s", persisted, plan.maxSeenTS))
+	}
 	fmt.Println("recover success! please restart the PD cluster")
 }
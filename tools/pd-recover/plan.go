@@ -0,0 +1,219 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/pkg/tsoutil"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/tools/pd-backup/pdbackup"
+)
+
+// tsoSafeMargin is added to the max seen TSO if it's not less than the current time.
+const tsoSafeMargin = time.Second
+
+// recoverSource is the metadata collected from a store dump or a backup file.
+type recoverSource struct {
+	name      string
+	clusterID uint64
+	maxID     uint64
+	maxTS     time.Time
+}
+
+func (s *recoverSource) observeID(id uint64) {
+	if id > s.maxID {
+		s.maxID = id
+	}
+}
+
+func (s *recoverSource) observeRegion(region *metapb.Region) {
+	s.observeID(region.GetId())
+	for _, peer := range region.GetPeers() {
+		s.observeID(peer.GetId())
+		s.observeID(peer.GetStoreId())
+	}
+}
+
+func (s *recoverSource) observeTS(ts time.Time) {
+	if ts.After(s.maxTS) {
+		s.maxTS = ts
+	}
+}
+
+// storeDump is the metadata dumped from a TiKV store.
+type storeDump struct {
+	ClusterID uint64           `json:"cluster_id"`
+	Store     *metapb.Store    `json:"store"`
+	Regions   []*metapb.Region `json:"regions"`
+	// MaxTS is the max TSO seen by the store, such as the max commit ts.
+	MaxTS uint64 `json:"max_ts"`
+}
+
+func loadStoreDump(file string) (*recoverSource, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	dump := &storeDump{}
+	if err := json.Unmarshal(data, dump); err != nil {
+		return nil, errors.Annotatef(err, "failed to parse store dump %s", file)
+	}
+	source := &recoverSource{name: "store dump " + file, clusterID: dump.ClusterID}
+	source.observeID(dump.Store.GetId())
+	for _, region := range dump.Regions {
+		source.observeRegion(region)
+	}
+	if dump.MaxTS != 0 {
+		ts, _ := tsoutil.ParseTS(dump.MaxTS)
+		source.observeTS(ts)
+	}
+	return source, nil
+}
+
+// loadBackupFile loads an archive or a basic cluster info file produced by pd-backup.
+func loadBackupFile(file string) (*recoverSource, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	// The archive is gzipped while the basic cluster info is in JSON.
+	if magic, err := r.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		archive, err := pdbackup.ReadArchive(r)
+		if err != nil {
+			return nil, err
+		}
+		return sourceFromArchive(file, archive)
+	}
+	info := &pdbackup.BackupInfo{}
+	if err := json.NewDecoder(r).Decode(info); err != nil {
+		return nil, errors.Annotatef(err, "failed to parse backup file %s", file)
+	}
+	source := &recoverSource{name: "backup " + file, clusterID: info.ClusterID, maxID: info.AllocIDMax}
+	// The timestamp is saved as the nanoseconds of the time window.
+	source.observeTS(time.Unix(0, int64(info.AllocTimestampMax)))
+	return source, nil
+}
+
+func sourceFromArchive(file string, archive *pdbackup.Archive) (*recoverSource, error) {
+	source := &recoverSource{name: "backup " + file, clusterID: archive.ClusterID}
+	for _, section := range archive.Sections {
+		for _, entry := range section.Entries {
+			switch section.Name {
+			case pdbackup.SectionAllocID:
+				id, err := typeutil.BytesToUint64(entry.Value)
+				if err != nil {
+					return nil, err
+				}
+				source.observeID(id)
+			case pdbackup.SectionStores:
+				store := &metapb.Store{}
+				if err := store.Unmarshal(entry.Value); err != nil {
+					return nil, errors.WithStack(err)
+				}
+				source.observeID(store.GetId())
+			case pdbackup.SectionRegions, pdbackup.SectionRegionStorage:
+				region := &metapb.Region{}
+				if err := region.Unmarshal(entry.Value); err != nil {
+					return nil, errors.WithStack(err)
+				}
+				source.observeRegion(region)
+			case pdbackup.SectionTSO:
+				if !strings.HasSuffix(entry.Key, "timestamp") {
+					continue
+				}
+				ts, err := typeutil.ParseTimestamp(entry.Value)
+				if err != nil {
+					return nil, err
+				}
+				source.observeTS(ts)
+			}
+		}
+	}
+	return source, nil
+}
+
+// recoverPlan is what pd-recover will write into etcd.
+type recoverPlan struct {
+	sources   []string
+	clusterID uint64
+	maxSeenID uint64
+	allocID   uint64
+	maxSeenTS time.Time
+	timestamp time.Time
+}
+
+// newRecoverPlan derives the cluster ID, alloc ID and timestamp from the sources. The cluster ID
+// and alloc ID specified by the operator take precedence but must be consistent with the sources.
+func newRecoverPlan(sources []*recoverSource, clusterID, allocID, allocIDMargin uint64, now time.Time) (*recoverPlan, error) {
+	plan := &recoverPlan{clusterID: clusterID, allocID: allocID}
+	for _, source := range sources {
+		plan.sources = append(plan.sources, source.name)
+		if source.clusterID != 0 {
+			if plan.clusterID == 0 {
+				plan.clusterID = source.clusterID
+			} else if plan.clusterID != source.clusterID {
+				return nil, errors.Errorf("cluster id %d from %s mismatches with cluster id %d", source.clusterID, source.name, plan.clusterID)
+			}
+		}
+		if source.maxID > plan.maxSeenID {
+			plan.maxSeenID = source.maxID
+		}
+		if source.maxTS.After(plan.maxSeenTS) {
+			plan.maxSeenTS = source.maxTS
+		}
+	}
+	if plan.clusterID == 0 {
+		return nil, errors.New("please specify safe cluster-id")
+	}
+	if plan.allocID == 0 {
+		if len(sources) == 0 {
+			return nil, errors.New("please specify safe alloc-id")
+		}
+		plan.allocID = plan.maxSeenID + allocIDMargin
+	}
+	if plan.allocID <= plan.maxSeenID {
+		return nil, errors.Errorf("alloc-id %d is not larger than the max seen id %d", plan.allocID, plan.maxSeenID)
+	}
+	plan.timestamp = now
+	if !plan.timestamp.After(plan.maxSeenTS) {
+		plan.timestamp = plan.maxSeenTS.Add(tsoSafeMargin)
+	}
+	return plan, nil
+}
+
+func (p *recoverPlan) String() string {
+	var b strings.Builder
+	fmt.Fprintln(&b, "recovery plan:")
+	for _, source := range p.sources {
+		fmt.Fprintf(&b, "  source:     %s\n", source)
+	}
+	fmt.Fprintf(&b, "  cluster-id: %d\n", p.clusterID)
+	fmt.Fprintf(&b, "  alloc-id:   %d (max seen id: %d)\n", p.allocID, p.maxSeenID)
+	if p.maxSeenTS.IsZero() {
+		fmt.Fprintf(&b, "  timestamp:  %s (max seen tso: unknown)\n", p.timestamp)
+	} else {
+		fmt.Fprintf(&b, "  timestamp:  %s (max seen tso: %s)\n", p.timestamp, p.maxSeenTS)
+	}
+	return b.String()
+}
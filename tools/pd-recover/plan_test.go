@@ -0,0 +1,128 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/pkg/tsoutil"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/tools/pd-backup/pdbackup"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testRecoverPlanSuite{})
+
+type testRecoverPlanSuite struct{}
+
+func (s *testRecoverPlanSuite) TestStoreDump(c *C) {
+	dir := c.MkDir()
+	now := time.Now()
+	dumps := []*storeDump{
+		{
+			ClusterID: 1,
+			Store:     &metapb.Store{Id: 1},
+			Regions: []*metapb.Region{
+				{Id: 10, Peers: []*metapb.Peer{{Id: 11, StoreId: 1}, {Id: 12, StoreId: 2}}},
+			},
+			MaxTS: tsoutil.GenerateTS(tsoutil.GenerateTimestamp(now.Add(time.Hour), 0)),
+		},
+		{
+			ClusterID: 1,
+			Store:     &metapb.Store{Id: 2},
+			Regions: []*metapb.Region{
+				{Id: 20, Peers: []*metapb.Peer{{Id: 21, StoreId: 2}}},
+			},
+		},
+	}
+	var sources []*recoverSource
+	for i, dump := range dumps {
+		data, err := json.Marshal(dump)
+		c.Assert(err, IsNil)
+		file := filepath.Join(dir, string(rune('a'+i)))
+		c.Assert(os.WriteFile(file, data, 0600), IsNil)
+		source, err := loadStoreDump(file)
+		c.Assert(err, IsNil)
+		sources = append(sources, source)
+	}
+
+	plan, err := newRecoverPlan(sources, 0, 0, 100, now)
+	c.Assert(err, IsNil)
+	c.Assert(plan.clusterID, Equals, uint64(1))
+	c.Assert(plan.maxSeenID, Equals, uint64(21))
+	c.Assert(plan.allocID, Equals, uint64(121))
+	// The max seen TSO is ahead of now.
+	c.Assert(plan.timestamp.After(plan.maxSeenTS), IsTrue)
+	c.Assert(plan.timestamp.After(now), IsTrue)
+
+	// The specified values should be consistent with the sources.
+	_, err = newRecoverPlan(sources, 2, 0, 100, now)
+	c.Assert(err, NotNil)
+	_, err = newRecoverPlan(sources, 1, 21, 100, now)
+	c.Assert(err, NotNil)
+	plan, err = newRecoverPlan(sources, 1, 1000, 100, now)
+	c.Assert(err, IsNil)
+	c.Assert(plan.allocID, Equals, uint64(1000))
+
+	sources = append(sources, &recoverSource{name: "another", clusterID: 2})
+	_, err = newRecoverPlan(sources, 0, 0, 100, now)
+	c.Assert(err, NotNil)
+}
+
+func (s *testRecoverPlanSuite) TestBackupFile(c *C) {
+	dir := c.MkDir()
+	now := time.Now()
+	region := &metapb.Region{Id: 30, Peers: []*metapb.Peer{{Id: 31, StoreId: 1}}}
+	regionValue, err := region.Marshal()
+	c.Assert(err, IsNil)
+	archive := &pdbackup.Archive{
+		Version:   pdbackup.ArchiveVersion,
+		ClusterID: 3,
+		Sections: []*pdbackup.Section{
+			{Name: pdbackup.SectionAllocID, Entries: []*pdbackup.Entry{{Key: "alloc_id", Value: typeutil.Uint64ToBytes(1000)}}},
+			{Name: pdbackup.SectionRegions, Entries: []*pdbackup.Entry{{Key: "raft/r/00000000000000000030", Value: regionValue}}},
+			{Name: pdbackup.SectionTSO, Entries: []*pdbackup.Entry{{Key: "timestamp", Value: typeutil.Uint64ToBytes(uint64(now.UnixNano()))}}},
+		},
+	}
+	source, err := sourceFromArchive("archive", archive)
+	c.Assert(err, IsNil)
+	c.Assert(source.clusterID, Equals, uint64(3))
+	c.Assert(source.maxID, Equals, uint64(1000))
+	c.Assert(source.maxTS.Equal(time.Unix(0, now.UnixNano())), IsTrue)
+
+	info := &pdbackup.BackupInfo{ClusterID: 3, AllocIDMax: 2000, AllocTimestampMax: uint64(now.UnixNano())}
+	data, err := json.Marshal(info)
+	c.Assert(err, IsNil)
+	file := filepath.Join(dir, "backup.json")
+	c.Assert(os.WriteFile(file, data, 0600), IsNil)
+	source, err = loadBackupFile(file)
+	c.Assert(err, IsNil)
+	c.Assert(source.clusterID, Equals, uint64(3))
+	c.Assert(source.maxID, Equals, uint64(2000))
+
+	plan, err := newRecoverPlan([]*recoverSource{source}, 0, 0, 100, now)
+	c.Assert(err, IsNil)
+	c.Assert(plan.allocID, Equals, uint64(2100))
+	c.Assert(plan.timestamp.After(now), IsTrue)
+	c.Assert(plan.String(), Matches, "(?s).*cluster-id: 3.*alloc-id:   2100.*")
+}
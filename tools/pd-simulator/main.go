@@ -14,20 +14,17 @@
 package main
 
 import (
-	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/BurntSushi/toml"
-	"github.com/pingcap/log"
-	"github.com/tikv/pd/pkg/logutil"
-	"github.com/tikv/pd/server"
-	"github.com/tikv/pd/server/api"
-	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/server/statistics"
 	"github.com/tikv/pd/tools/pd-analysis/analysis"
 	"github.com/tikv/pd/tools/pd-simulator/simulator"
@@ -48,7 +45,14 @@ var (
 	simLogFile                  = flag.String("simLogFile", "", "simulator log file")
 	regionNum                   = flag.Int("regionNum", 0, "regionNum of one store")
 	storeNum                    = flag.Int("storeNum", 0, "storeNum")
+	pdNum                       = flag.Int("pdNum", 0, "the count of PD members started by the simulator, it is decided by the case if not set")
 	enableTransferRegionCounter = flag.Bool("enableTransferRegionCounter", false, "enableTransferRegionCounter")
+	reportDir                   = flag.String("reportDir", "", "the directory to write the time series and the HTML report of the case")
+	reportAssetsDir             = flag.String("reportAssetsDir", "", "the local directory of the ECharts assets to inline into the HTML report instead of the embedded ones")
+	reportAssetsHost            = flag.String("reportAssetsHost", "", "the host to load the ECharts assets of the HTML report remotely instead of inlining them, such as https://go-echarts.github.io/go-echarts-assets/assets/")
+	compareReports              = flag.String("compareReports", "", "the JSON reports to compare, separated by comma, the comparison is written to reportDir")
+	configItems                 = flag.String("configItems", "", "the config items in JSON to override the config file, like {\"schedule.tolerant-size-ratio\": 5}")
+	sweepFile                   = flag.String("sweep", "", "the sweep file, the case in it runs under every combination of the config items")
 )
 
 func main() {
@@ -61,6 +65,16 @@ func main() {
 		analysis.GetTransferCounter().Init(simutil.CaseConfigure.StoreNum, simutil.CaseConfigure.RegionNum)
 	}
 
+	if *sweepFile != "" {
+		sweep(*sweepFile)
+		return
+	}
+
+	if *compareReports != "" {
+		compare(strings.Split(*compareReports, ","))
+		return
+	}
+
 	if *caseName == "" {
 		if *pdAddr != "" {
 			simutil.Logger.Fatal("need to specify one config name")
@@ -73,7 +87,7 @@ func main() {
 	}
 }
 
-func run(simCase string) {
+func run(caseName string) {
 	simConfig := simulator.NewSimConfig(*serverLogLevel)
 	var meta toml.MetaData
 	var err error
@@ -85,64 +99,56 @@ func run(simCase string) {
 	if err = simConfig.Adjust(&meta); err != nil {
 		simutil.Logger.Fatal("failed to adjust simulator configuration", zap.Error(err))
 	}
-
-	if *pdAddr != "" {
-		simStart(*pdAddr, simCase, simConfig)
-	} else {
-		local, clean := NewSingleServer(context.Background(), simConfig)
-		err = local.Run()
-		if err != nil {
-			simutil.Logger.Fatal("run server error", zap.Error(err))
+	var schedulerConfigs map[string]map[string]interface{}
+	if *configItems != "" {
+		items := make(map[string]interface{})
+		if err = json.Unmarshal([]byte(*configItems), &items); err != nil {
+			simutil.Logger.Fatal("failed to parse config items", zap.Error(err))
 		}
-		for {
-			if !local.IsClosed() && local.GetMember().IsLeader() {
-				break
-			}
-			time.Sleep(100 * time.Millisecond)
+		if schedulerConfigs, err = simConfig.ApplyConfigItems(items); err != nil {
+			simutil.Logger.Fatal("failed to apply config items", zap.Error(err))
 		}
-		simStart(local.GetAddr(), simCase, simConfig, clean)
-	}
-}
-
-// NewSingleServer creates a pd server for simulator.
-func NewSingleServer(ctx context.Context, simConfig *simulator.SimConfig) (*server.Server, server.CleanupFunc) {
-	err := simConfig.ServerConfig.SetupLogger()
-	if err == nil {
-		log.ReplaceGlobals(simConfig.ServerConfig.GetZapLogger(), simConfig.ServerConfig.GetZapLogProperties())
-	} else {
-		log.Fatal("setup logger error", zap.Error(err))
 	}
 
-	err = logutil.InitLogger(&simConfig.ServerConfig.Log)
+	simCase, err := simulator.LoadCase(caseName)
 	if err != nil {
-		log.Fatal("initialize logger error", zap.Error(err))
+		simutil.Logger.Fatal("load case error", zap.Error(err))
 	}
 
-	s, err := server.CreateServer(ctx, simConfig.ServerConfig, api.NewHandler)
+	if *pdAddr != "" {
+		simStart(*pdAddr, caseName, simCase, simConfig, nil, schedulerConfigs)
+		return
+	}
+	count := *pdNum
+	if count <= 0 {
+		count = simCase.PDCount
+	}
+	if count <= 0 {
+		count = 1
+	}
+	pd, err := simulator.NewPDCluster(simConfig, count)
 	if err != nil {
-		panic("create server failed")
+		simutil.Logger.Fatal("create pd cluster error", zap.Error(err))
 	}
-
-	cleanup := func() {
-		s.Close()
-		cleanServer(simConfig.ServerConfig)
+	if err = pd.Start(); err != nil {
+		pd.Stop()
+		simutil.Logger.Fatal("run pd cluster error", zap.Error(err))
 	}
-	return s, cleanup
-}
-
-func cleanServer(cfg *config.Config) {
-	// Clean data directory
-	os.RemoveAll(cfg.DataDir)
+	simStart(pd.ClientURLs(), caseName, simCase, simConfig, pd, schedulerConfigs)
 }
 
-func simStart(pdAddr string, simCase string, simConfig *simulator.SimConfig, clean ...server.CleanupFunc) {
+// simStart runs the case, pd is nil if the simulator runs with an external PD.
+func simStart(pdAddr string, caseName string, simCase *cases.Case, simConfig *simulator.SimConfig, pd *simulator.PDCluster, schedulerConfigs map[string]map[string]interface{}) {
 	start := time.Now()
-	driver, err := simulator.NewDriver(pdAddr, simCase, simConfig)
-	if err != nil {
-		simutil.Logger.Fatal("create driver error", zap.Error(err))
+	driver := simulator.NewDriver(pdAddr, simCase, simConfig, pd)
+	if len(schedulerConfigs) > 0 {
+		driver.SetSchedulerConfigs(schedulerConfigs)
+	}
+	if *reportDir != "" {
+		driver.EnableReport(reportName(caseName))
 	}
 
-	err = driver.Prepare()
+	err := driver.Prepare()
 	if err != nil {
 		simutil.Logger.Fatal("simulator prepare error", zap.Error(err))
 	}
@@ -165,6 +171,10 @@ EXIT:
 		select {
 		case <-tick.C:
 			driver.Tick()
+			if err := driver.SchedulerConfigError(); err != nil {
+				simutil.Logger.Error("scheduler config error", zap.Error(err))
+				break EXIT
+			}
 			if driver.Check() {
 				simResult = "OK"
 				break EXIT
@@ -175,12 +185,22 @@ EXIT:
 	}
 
 	driver.Stop()
-	if len(clean) != 0 {
-		clean[0]()
+	// The result is invalid if the scheduler configurations are not set.
+	if pending := driver.PendingSchedulerConfigs(); len(pending) > 0 {
+		fmt.Printf("scheduler config items are not set: %s\n", strings.Join(pending, ","))
+		simResult = "FAIL"
+	}
+	if pd != nil {
+		pd.Stop()
 	}
 
-	fmt.Printf("%s [%s] total iteration: %d, time cost: %v\n", simResult, simCase, driver.TickCount(), time.Since(start))
+	fmt.Printf("%s [%s] total iteration: %d, time cost: %v\n", simResult, caseName, driver.TickCount(), time.Since(start))
 	driver.PrintStatistics()
+	if report := driver.Report(); report != nil {
+		if err := report.WriteFiles(*reportDir, reportAssets()); err != nil {
+			simutil.Logger.Error("write report error", zap.Error(err))
+		}
+	}
 	if analysis.GetTransferCounter().IsValid {
 		analysis.GetTransferCounter().PrintResult()
 	}
@@ -189,3 +209,43 @@ EXIT:
 		os.Exit(1)
 	}
 }
+
+// reportName returns the name of the report, it is the base name without the
+// extension for a scenario file.
+func reportName(caseName string) string {
+	if cases.IsScenarioFile(caseName) {
+		base := filepath.Base(caseName)
+		return strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	return caseName
+}
+
+func reportAssets() simulator.ReportAssets {
+	return simulator.ReportAssets{Dir: *reportAssetsDir, Host: *reportAssetsHost}
+}
+
+// compare renders the reports of several runs into one HTML page.
+func compare(paths []string) {
+	reports := make([]*simulator.Report, 0, len(paths))
+	for _, path := range paths {
+		report, err := simulator.LoadReport(path)
+		if err != nil {
+			simutil.Logger.Fatal("load report error", zap.Error(err))
+		}
+		reports = append(reports, report)
+	}
+	dir := *reportDir
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		simutil.Logger.Fatal("create report directory error", zap.Error(err))
+	}
+	path := filepath.Join(dir, "compare.html")
+	if err := writeFile(path, func(f *os.File) error {
+		return simulator.RenderReport(f, reportAssets(), reports...)
+	}); err != nil {
+		simutil.Logger.Fatal("render report error", zap.Error(err))
+	}
+	fmt.Println("comparison is written to", path)
+}
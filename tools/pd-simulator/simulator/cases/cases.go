@@ -16,6 +16,7 @@ package cases
 import (
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/placement"
 	"github.com/tikv/pd/tools/pd-simulator/simulator/info"
 	"github.com/tikv/pd/tools/pd-simulator/simulator/simutil"
 )
@@ -30,6 +31,9 @@ type Store struct {
 	LeaderWeight float32
 	RegionWeight float32
 	Version      string
+	// IORate is the IO rate of the store in MB/s, the simulator config is used if
+	// it is not set.
+	IORate int64
 }
 
 // Region is used to simulate a region.
@@ -39,6 +43,10 @@ type Region struct {
 	Leader *metapb.Peer
 	Size   int64
 	Keys   int64
+	// StartKey and EndKey are the key range of the region. The keys are generated
+	// if none of the regions in the case has the key range.
+	StartKey []byte
+	EndKey   []byte
 }
 
 // CheckerFunc checks if the scheduler is finished.
@@ -52,6 +60,11 @@ type Case struct {
 	RegionSplitKeys int64
 	Events          []EventDescriptor
 	TableNumber     int
+	// Rules are added to the placement rules before the simulation starts.
+	Rules []*placement.Rule
+	// PDCount is the count of PD members started by the simulator, it is 1 if
+	// not set.
+	PDCount int
 
 	Checker CheckerFunc // To check the schedule is finished.
 }
@@ -102,6 +115,7 @@ var CaseMap = map[string]func() *Case{
 	"hot-write":                newHotWrite,
 	"makeup-down-replicas":     newMakeupDownReplicas,
 	"import-data":              newImportData,
+	"unsafe-recovery":          newUnsafeRecovery,
 }
 
 // NewCase creates a new case.
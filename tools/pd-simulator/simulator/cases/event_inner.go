@@ -13,6 +13,12 @@
 
 package cases
 
+import (
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+)
+
 // EventDescriptor is a detail template for custom events.
 type EventDescriptor interface {
 	Type() string
@@ -51,6 +57,9 @@ func (w *ReadFlowOnRegionDescriptor) Type() string {
 // AddNodesDescriptor adds nodes.
 type AddNodesDescriptor struct {
 	Step func(tick int64) uint64
+	// Store returns the store to add, the store is created with the simulator
+	// config if it is nil.
+	Store func(id uint64) *Store
 }
 
 // Type implements the EventDescriptor interface.
@@ -67,3 +76,137 @@ type DeleteNodesDescriptor struct {
 func (w *DeleteNodesDescriptor) Type() string {
 	return "delete-nodes"
 }
+
+// RemoveFailedStoresDescriptor removes the failed stores with unsafe recovery.
+type RemoveFailedStoresDescriptor struct {
+	Step func(tick int64) []uint64
+}
+
+// Type implements the EventDescriptor interface.
+func (w *RemoveFailedStoresDescriptor) Type() string {
+	return "remove-failed-stores"
+}
+
+// WriteFlowOnRangeDescriptor writes bytes evenly in the regions of a key range.
+type WriteFlowOnRangeDescriptor struct {
+	StartKey []byte
+	EndKey   []byte
+	Step     func(tick int64) int64
+}
+
+// Type implements the EventDescriptor interface.
+func (w *WriteFlowOnRangeDescriptor) Type() string {
+	return "write-flow-on-range"
+}
+
+// ReadFlowOnRangeDescriptor reads bytes evenly in the regions of a key range.
+type ReadFlowOnRangeDescriptor struct {
+	StartKey []byte
+	EndKey   []byte
+	Step     func(tick int64) int64
+}
+
+// Type implements the EventDescriptor interface.
+func (w *ReadFlowOnRangeDescriptor) Type() string {
+	return "read-flow-on-range"
+}
+
+// StoresDownDescriptor makes stores down, the down stores stop heartbeats
+// but are not removed.
+type StoresDownDescriptor struct {
+	Step func(tick int64) []uint64
+}
+
+// Type implements the EventDescriptor interface.
+func (w *StoresDownDescriptor) Type() string {
+	return "stores-down"
+}
+
+// StoresUpDescriptor recovers the down stores.
+type StoresUpDescriptor struct {
+	Step func(tick int64) []uint64
+}
+
+// Type implements the EventDescriptor interface.
+func (w *StoresUpDescriptor) Type() string {
+	return "stores-up"
+}
+
+// StoresOfflineDescriptor makes stores offline, PD moves the peers out of the
+// offline stores and sets them to tombstone.
+type StoresOfflineDescriptor struct {
+	Step func(tick int64) []uint64
+}
+
+// Type implements the EventDescriptor interface.
+func (w *StoresOfflineDescriptor) Type() string {
+	return "stores-offline"
+}
+
+// ChangeLabelsDescriptor changes the labels of stores.
+type ChangeLabelsDescriptor struct {
+	Step func(tick int64) map[uint64][]*metapb.StoreLabel
+}
+
+// Type implements the EventDescriptor interface.
+func (w *ChangeLabelsDescriptor) Type() string {
+	return "change-labels"
+}
+
+// KillPDDescriptor kills a PD member, the leader is killed if the member is
+// empty.
+type KillPDDescriptor struct {
+	Member string
+	Step   func(tick int64) bool
+}
+
+// Type implements the EventDescriptor interface.
+func (w *KillPDDescriptor) Type() string {
+	return "kill-pd"
+}
+
+// RestartPDDescriptor restarts a killed PD member, all the killed members are
+// restarted if the member is empty.
+type RestartPDDescriptor struct {
+	Member string
+	Step   func(tick int64) bool
+}
+
+// Type implements the EventDescriptor interface.
+func (w *RestartPDDescriptor) Type() string {
+	return "restart-pd"
+}
+
+// PartitionPDDescriptor isolates a PD member from the other members, the
+// leader is isolated if the member is empty.
+type PartitionPDDescriptor struct {
+	Member string
+	Step   func(tick int64) bool
+}
+
+// Type implements the EventDescriptor interface.
+func (w *PartitionPDDescriptor) Type() string {
+	return "partition-pd"
+}
+
+// HealPDDescriptor recovers the network partitions of PD members.
+type HealPDDescriptor struct {
+	Step func(tick int64) bool
+}
+
+// Type implements the EventDescriptor interface.
+func (w *HealPDDescriptor) Type() string {
+	return "heal-pd"
+}
+
+// DelayHeartbeatsDescriptor delays the heartbeats of stores, all the stores
+// are delayed if StoreIDs is empty.
+type DelayHeartbeatsDescriptor struct {
+	StoreIDs []uint64
+	Step     func(tick int64) (time.Duration, bool)
+}
+
+// Type implements the EventDescriptor interface.
+func (w *DelayHeartbeatsDescriptor) Type() string {
+	return "delay-heartbeats"
+}
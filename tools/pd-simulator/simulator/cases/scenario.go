@@ -0,0 +1,710 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cases
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/placement"
+	"github.com/tikv/pd/tools/pd-simulator/simulator/info"
+	"github.com/tikv/pd/tools/pd-simulator/simulator/simutil"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+// The event types of the scenario.
+const (
+	ScenarioEventAddNodes       = "add-nodes"
+	ScenarioEventDeleteNodes    = "delete-nodes"
+	ScenarioEventStoreDown      = "store-down"
+	ScenarioEventStoreUp        = "store-up"
+	ScenarioEventStoreOffline   = "store-offline"
+	ScenarioEventLabelChange    = "label-change"
+	ScenarioEventHotWrite       = "hot-write"
+	ScenarioEventHotRead        = "hot-read"
+	ScenarioEventPDKill         = "pd-kill"
+	ScenarioEventPDRestart      = "pd-restart"
+	ScenarioEventPDPartition    = "pd-partition"
+	ScenarioEventPDHeal         = "pd-heal"
+	ScenarioEventHeartbeatDelay = "heartbeat-delay"
+)
+
+const (
+	defaultScenarioReplicas   = 3
+	defaultScenarioRegionSize = 96 // MB
+	defaultScenarioRegionKeys = 960000
+)
+
+// Scenario describes a simulation case in a TOML or YAML file.
+type Scenario struct {
+	Name string `toml:"name" yaml:"name"`
+	// RegionSplitSize is in MB, regions are not split by size if it is 0.
+	RegionSplitSize int64 `toml:"region-split-size" yaml:"region-split-size"`
+	RegionSplitKeys int64 `toml:"region-split-keys" yaml:"region-split-keys"`
+	// PDCount is the count of PD members started by the simulator.
+	PDCount int                `toml:"pd-count" yaml:"pd-count"`
+	Stores  []*ScenarioStore   `toml:"stores" yaml:"stores"`
+	Regions []*ScenarioRegions `toml:"regions" yaml:"regions"`
+	Rules   []*ScenarioRule    `toml:"rules" yaml:"rules"`
+	Events  []*ScenarioEvent   `toml:"events" yaml:"events"`
+	Checker ScenarioChecker    `toml:"checker" yaml:"checker"`
+}
+
+// ScenarioStore describes the stores with ID from ID to ID+Count-1. The
+// simulator config is used for the capacity, available, IO rate and version
+// if they are not set.
+type ScenarioStore struct {
+	ID     uint64            `toml:"id" yaml:"id"`
+	Count  int               `toml:"count" yaml:"count"`
+	Labels map[string]string `toml:"labels" yaml:"labels"`
+	// Capacity and Available are in GB.
+	Capacity  uint64 `toml:"capacity" yaml:"capacity"`
+	Available uint64 `toml:"available" yaml:"available"`
+	// IORate is in MB/s.
+	IORate  int64  `toml:"io-rate" yaml:"io-rate"`
+	Version string `toml:"version" yaml:"version"`
+}
+
+// ScenarioRegions describes Count regions which split the range [StartKey, EndKey)
+// evenly. The peers are placed on Stores in turn, and the leaders are placed on
+// LeaderStores in turn.
+type ScenarioRegions struct {
+	StartKey string `toml:"start-key" yaml:"start-key"`
+	EndKey   string `toml:"end-key" yaml:"end-key"`
+	Count    int    `toml:"count" yaml:"count"`
+	Replicas int    `toml:"replicas" yaml:"replicas"`
+	// Size is in MB.
+	Size         int64    `toml:"size" yaml:"size"`
+	Keys         int64    `toml:"keys" yaml:"keys"`
+	Stores       []uint64 `toml:"stores" yaml:"stores"`
+	LeaderStores []uint64 `toml:"leader-stores" yaml:"leader-stores"`
+}
+
+// ScenarioRule describes a placement rule, the keys are raw keys.
+type ScenarioRule struct {
+	GroupID          string                    `toml:"group-id" yaml:"group-id"`
+	ID               string                    `toml:"id" yaml:"id"`
+	Index            int                       `toml:"index" yaml:"index"`
+	Override         bool                      `toml:"override" yaml:"override"`
+	StartKey         string                    `toml:"start-key" yaml:"start-key"`
+	EndKey           string                    `toml:"end-key" yaml:"end-key"`
+	Role             string                    `toml:"role" yaml:"role"`
+	Count            int                       `toml:"count" yaml:"count"`
+	LabelConstraints []ScenarioLabelConstraint `toml:"label-constraints" yaml:"label-constraints"`
+	LocationLabels   []string                  `toml:"location-labels" yaml:"location-labels"`
+	IsolationLevel   string                    `toml:"isolation-level" yaml:"isolation-level"`
+}
+
+// ScenarioLabelConstraint describes a label constraint of a placement rule.
+type ScenarioLabelConstraint struct {
+	Key    string   `toml:"key" yaml:"key"`
+	Op     string   `toml:"op" yaml:"op"`
+	Values []string `toml:"values" yaml:"values"`
+}
+
+// ScenarioEvent describes an event happens at Tick.
+//   - add-nodes: adds the stores described by Store, one store per tick.
+//   - delete-nodes: deletes StoreIDs, one store per tick.
+//   - store-down: makes StoreIDs down.
+//   - store-up: recovers the down StoreIDs.
+//   - store-offline: makes StoreIDs offline, they become tombstone after PD
+//     moves all the peers out.
+//   - label-change: sets the labels of StoreIDs to Labels.
+//   - hot-write, hot-read: writes or reads Flow MB per tick evenly in the
+//     regions of [StartKey, EndKey) until EndTick.
+//   - pd-kill: kills the PD Member, the leader is killed if Member is empty
+//     or "leader".
+//   - pd-restart: restarts the killed PD Member, all the killed members are
+//     restarted if Member is empty.
+//   - pd-partition: isolates the PD Member from the other members, the leader
+//     is isolated if Member is empty or "leader".
+//   - pd-heal: recovers the network partitions of PD members.
+//   - heartbeat-delay: delays the heartbeats of StoreIDs or all the stores by
+//     Delay until EndTick.
+type ScenarioEvent struct {
+	Type     string            `toml:"type" yaml:"type"`
+	Tick     int64             `toml:"tick" yaml:"tick"`
+	EndTick  int64             `toml:"end-tick" yaml:"end-tick"`
+	Store    *ScenarioStore    `toml:"store" yaml:"store"`
+	StoreIDs []uint64          `toml:"store-ids" yaml:"store-ids"`
+	Labels   map[string]string `toml:"labels" yaml:"labels"`
+	StartKey string            `toml:"start-key" yaml:"start-key"`
+	EndKey   string            `toml:"end-key" yaml:"end-key"`
+	Flow     int64             `toml:"flow" yaml:"flow"`
+	Member   string            `toml:"member" yaml:"member"`
+	// Delay is a duration such as "500ms".
+	Delay string `toml:"delay" yaml:"delay"`
+}
+
+// ScenarioChecker describes the thresholds to finish the simulation. Only the
+// set thresholds are checked, and they are checked after all the events happen.
+type ScenarioChecker struct {
+	// RegionCountTolerance and LeaderCountTolerance are the tolerated ratios
+	// of the region and leader count of each store to the average.
+	RegionCountTolerance *float64 `toml:"region-count-tolerance" yaml:"region-count-tolerance"`
+	LeaderCountTolerance *float64 `toml:"leader-count-tolerance" yaml:"leader-count-tolerance"`
+	// The diffs are the tolerated differences between the max and min count of
+	// the hot leaders or peers in the stores.
+	HotWriteLeaderDiff *int `toml:"hot-write-leader-diff" yaml:"hot-write-leader-diff"`
+	HotWritePeerDiff   *int `toml:"hot-write-peer-diff" yaml:"hot-write-peer-diff"`
+	HotReadLeaderDiff  *int `toml:"hot-read-leader-diff" yaml:"hot-read-leader-diff"`
+	// Replicas is the expected count of the peers of each region on the alive stores.
+	Replicas *int `toml:"replicas" yaml:"replicas"`
+}
+
+// IsScenarioFile returns true if the name is a scenario file rather than a
+// built-in case name.
+func IsScenarioFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".toml", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// LoadScenario loads the scenario file and creates a case from it.
+func LoadScenario(path string) (*Case, error) {
+	s, err := ReadScenario(path)
+	if err != nil {
+		return nil, err
+	}
+	return s.NewCase()
+}
+
+// ReadScenario reads the scenario file, the format is decided by the extension.
+func ReadScenario(path string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	s := &Scenario{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		meta, err := toml.Decode(string(data), s)
+		if err != nil {
+			return nil, errors.Annotatef(err, "failed to decode scenario %s", path)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return nil, errors.Errorf("unknown items %v in scenario %s", undecoded, path)
+		}
+	case ".yaml", ".yml":
+		if err := yaml.UnmarshalStrict(data, s); err != nil {
+			return nil, errors.Annotatef(err, "failed to decode scenario %s", path)
+		}
+	default:
+		return nil, errors.Errorf("unsupported scenario file %s", path)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return s, nil
+}
+
+// NewCase creates a case from the scenario.
+func (s *Scenario) NewCase() (*Case, error) {
+	simCase := &Case{
+		RegionSplitSize: s.RegionSplitSize * MB,
+		RegionSplitKeys: s.RegionSplitKeys,
+		PDCount:         s.PDCount,
+	}
+	storeIDs := make(map[uint64]struct{})
+	var maxStoreID uint64
+	addStoreIDs := func(store *ScenarioStore) error {
+		if store.ID == 0 {
+			return errors.New("store id is required")
+		}
+		if store.Count <= 0 {
+			store.Count = 1
+		}
+		for id := store.ID; id < store.ID+uint64(store.Count); id++ {
+			if _, ok := storeIDs[id]; ok {
+				return errors.Errorf("duplicated store %d", id)
+			}
+			storeIDs[id] = struct{}{}
+			if id > maxStoreID {
+				maxStoreID = id
+			}
+		}
+		return nil
+	}
+
+	var initStoreIDs []uint64
+	for _, store := range s.Stores {
+		if err := addStoreIDs(store); err != nil {
+			return nil, err
+		}
+		for i := 0; i < store.Count; i++ {
+			simCase.Stores = append(simCase.Stores, store.newStore(store.ID+uint64(i)))
+			initStoreIDs = append(initStoreIDs, store.ID+uint64(i))
+		}
+	}
+	if len(simCase.Stores) == 0 {
+		return nil, errors.New("no store in scenario")
+	}
+	for _, e := range s.Events {
+		if e.Type == ScenarioEventAddNodes && e.Store != nil {
+			if err := addStoreIDs(e.Store); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// The region and peer IDs are allocated after the store IDs.
+	if IDAllocator.id < maxStoreID {
+		IDAllocator.id = maxStoreID
+	}
+
+	regions, err := s.newRegions(initStoreIDs)
+	if err != nil {
+		return nil, err
+	}
+	simCase.Regions = regions
+
+	for _, r := range s.Rules {
+		rule, err := r.newRule()
+		if err != nil {
+			return nil, err
+		}
+		simCase.Rules = append(simCase.Rules, rule)
+	}
+
+	var (
+		hotWriteRanges, hotReadRanges [][2][]byte
+		lastTick                      int64
+	)
+	for _, e := range s.Events {
+		descriptor, err := e.newEventDescriptor()
+		if err != nil {
+			return nil, err
+		}
+		simCase.Events = append(simCase.Events, descriptor)
+		switch e.Type {
+		case ScenarioEventHotWrite:
+			hotWriteRanges = append(hotWriteRanges, [2][]byte{[]byte(e.StartKey), []byte(e.EndKey)})
+		case ScenarioEventHotRead:
+			hotReadRanges = append(hotReadRanges, [2][]byte{[]byte(e.StartKey), []byte(e.EndKey)})
+		}
+		if e.Tick > lastTick {
+			lastTick = e.Tick
+		}
+	}
+
+	checker, err := s.Checker.newChecker(lastTick, hotWriteRanges, hotReadRanges)
+	if err != nil {
+		return nil, err
+	}
+	simCase.Checker = checker
+	return simCase, nil
+}
+
+func (s *ScenarioStore) newStore(id uint64) *Store {
+	var labels []*metapb.StoreLabel
+	for k, v := range s.Labels {
+		labels = append(labels, &metapb.StoreLabel{Key: k, Value: v})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].GetKey() < labels[j].GetKey() })
+	return &Store{
+		ID:        id,
+		Status:    metapb.StoreState_Up,
+		Labels:    labels,
+		Capacity:  s.Capacity * GB,
+		Available: s.Available * GB,
+		Version:   s.Version,
+		IORate:    s.IORate,
+	}
+}
+
+func (s *Scenario) newRegions(storeIDs []uint64) ([]Region, error) {
+	groups := make([]*ScenarioRegions, len(s.Regions))
+	copy(groups, s.Regions)
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].StartKey < groups[j].StartKey })
+	for i := 1; i < len(groups); i++ {
+		if groups[i-1].EndKey == "" || groups[i-1].EndKey > groups[i].StartKey {
+			return nil, errors.Errorf("regions [%q, %q) and [%q, %q) overlap",
+				groups[i-1].StartKey, groups[i-1].EndKey, groups[i].StartKey, groups[i].EndKey)
+		}
+	}
+
+	var regions []Region
+	for _, g := range groups {
+		if g.Count <= 0 {
+			return nil, errors.Errorf("count of regions [%q, %q) should be positive", g.StartKey, g.EndKey)
+		}
+		if g.Replicas == 0 {
+			g.Replicas = defaultScenarioReplicas
+		}
+		if g.Size == 0 {
+			g.Size = defaultScenarioRegionSize
+		}
+		if g.Keys == 0 {
+			g.Keys = defaultScenarioRegionKeys
+		}
+		stores := g.Stores
+		if len(stores) == 0 {
+			stores = storeIDs
+		}
+		leaderStores := g.LeaderStores
+		if len(leaderStores) == 0 {
+			leaderStores = stores
+		}
+		for _, id := range append(append([]uint64{}, stores...), leaderStores...) {
+			if !containsStore(storeIDs, id) {
+				return nil, errors.Errorf("store %d of regions [%q, %q) is not found", id, g.StartKey, g.EndKey)
+			}
+		}
+
+		keys := make([][]byte, 0, g.Count+1)
+		keys = append(keys, []byte(g.StartKey))
+		for i := 1; i < g.Count; i++ {
+			keys = append(keys, []byte(g.StartKey+fmt.Sprintf("%010d", i)))
+		}
+		keys = append(keys, []byte(g.EndKey))
+		if g.EndKey != "" && bytes.Compare(keys[g.Count-1], keys[g.Count]) >= 0 {
+			return nil, errors.Errorf("range [%q, %q) is too small to split to %d regions", g.StartKey, g.EndKey, g.Count)
+		}
+
+		for i := 0; i < g.Count; i++ {
+			leaderStore := leaderStores[i%len(leaderStores)]
+			peers := []*metapb.Peer{{Id: IDAllocator.nextID(), StoreId: leaderStore}}
+			for j := 0; j < len(stores) && len(peers) < g.Replicas; j++ {
+				storeID := stores[(i+j)%len(stores)]
+				if !containsPeer(peers, storeID) {
+					peers = append(peers, &metapb.Peer{Id: IDAllocator.nextID(), StoreId: storeID})
+				}
+			}
+			if len(peers) < g.Replicas {
+				return nil, errors.Errorf("regions [%q, %q) need %d replicas but only %d stores are available", g.StartKey, g.EndKey, g.Replicas, len(peers))
+			}
+			regions = append(regions, Region{
+				ID:       IDAllocator.nextID(),
+				Peers:    peers,
+				Leader:   peers[0],
+				Size:     g.Size * MB,
+				Keys:     g.Keys,
+				StartKey: keys[i],
+				EndKey:   keys[i+1],
+			})
+		}
+	}
+	return regions, nil
+}
+
+func containsStore(storeIDs []uint64, id uint64) bool {
+	for _, storeID := range storeIDs {
+		if storeID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPeer(peers []*metapb.Peer, storeID uint64) bool {
+	for _, peer := range peers {
+		if peer.GetStoreId() == storeID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ScenarioRule) newRule() (*placement.Rule, error) {
+	if r.ID == "" || r.Count <= 0 {
+		return nil, errors.Errorf("rule %q should have an id and a positive count", r.ID)
+	}
+	rule := &placement.Rule{
+		GroupID:        r.GroupID,
+		ID:             r.ID,
+		Index:          r.Index,
+		Override:       r.Override,
+		StartKey:       []byte(r.StartKey),
+		StartKeyHex:    hex.EncodeToString([]byte(r.StartKey)),
+		EndKey:         []byte(r.EndKey),
+		EndKeyHex:      hex.EncodeToString([]byte(r.EndKey)),
+		Role:           placement.PeerRoleType(r.Role),
+		Count:          r.Count,
+		LocationLabels: r.LocationLabels,
+		IsolationLevel: r.IsolationLevel,
+	}
+	if rule.GroupID == "" {
+		rule.GroupID = "pd"
+	}
+	if rule.Role == "" {
+		rule.Role = placement.Voter
+	}
+	for _, c := range r.LabelConstraints {
+		rule.LabelConstraints = append(rule.LabelConstraints, placement.LabelConstraint{
+			Key:    c.Key,
+			Op:     placement.LabelConstraintOp(c.Op),
+			Values: c.Values,
+		})
+	}
+	return rule, nil
+}
+
+func (e *ScenarioEvent) newEventDescriptor() (EventDescriptor, error) {
+	if e.Tick <= 0 {
+		return nil, errors.Errorf("tick of %s event should be positive", e.Type)
+	}
+	startTick := e.Tick
+	switch e.Type {
+	case ScenarioEventAddNodes:
+		if e.Store == nil {
+			return nil, errors.Errorf("%s event at tick %d has no store", e.Type, e.Tick)
+		}
+		store := e.Store
+		return &AddNodesDescriptor{
+			Step: func(tick int64) uint64 {
+				if tick < startTick || tick >= startTick+int64(store.Count) {
+					return 0
+				}
+				return store.ID + uint64(tick-startTick)
+			},
+			Store: store.newStore,
+		}, nil
+	case ScenarioEventDeleteNodes:
+		if len(e.StoreIDs) == 0 {
+			return nil, errors.Errorf("%s event at tick %d has no store", e.Type, e.Tick)
+		}
+		ids := e.StoreIDs
+		return &DeleteNodesDescriptor{
+			Step: func(tick int64) uint64 {
+				if tick < startTick || tick >= startTick+int64(len(ids)) {
+					return 0
+				}
+				return ids[tick-startTick]
+			},
+		}, nil
+	case ScenarioEventStoreDown, ScenarioEventStoreUp, ScenarioEventStoreOffline:
+		if len(e.StoreIDs) == 0 {
+			return nil, errors.Errorf("%s event at tick %d has no store", e.Type, e.Tick)
+		}
+		ids := e.StoreIDs
+		step := func(tick int64) []uint64 {
+			if tick != startTick {
+				return nil
+			}
+			return ids
+		}
+		switch e.Type {
+		case ScenarioEventStoreUp:
+			return &StoresUpDescriptor{Step: step}, nil
+		case ScenarioEventStoreOffline:
+			return &StoresOfflineDescriptor{Step: step}, nil
+		}
+		return &StoresDownDescriptor{Step: step}, nil
+	case ScenarioEventLabelChange:
+		if len(e.StoreIDs) == 0 {
+			return nil, errors.Errorf("%s event at tick %d has no store", e.Type, e.Tick)
+		}
+		labels := make(map[uint64][]*metapb.StoreLabel, len(e.StoreIDs))
+		for _, id := range e.StoreIDs {
+			labels[id] = (&ScenarioStore{Labels: e.Labels}).newStore(id).Labels
+		}
+		return &ChangeLabelsDescriptor{
+			Step: func(tick int64) map[uint64][]*metapb.StoreLabel {
+				if tick != startTick {
+					return nil
+				}
+				return labels
+			},
+		}, nil
+	case ScenarioEventHotWrite, ScenarioEventHotRead:
+		if e.Flow <= 0 {
+			return nil, errors.Errorf("flow of %s event at tick %d should be positive", e.Type, e.Tick)
+		}
+		if e.EndTick != 0 && e.EndTick <= e.Tick {
+			return nil, errors.Errorf("end tick of %s event at tick %d should be larger than the tick", e.Type, e.Tick)
+		}
+		endTick, flow := e.EndTick, e.Flow*MB
+		step := func(tick int64) int64 {
+			if tick < startTick || (endTick != 0 && tick >= endTick) {
+				return 0
+			}
+			return flow
+		}
+		if e.Type == ScenarioEventHotWrite {
+			return &WriteFlowOnRangeDescriptor{StartKey: []byte(e.StartKey), EndKey: []byte(e.EndKey), Step: step}, nil
+		}
+		return &ReadFlowOnRangeDescriptor{StartKey: []byte(e.StartKey), EndKey: []byte(e.EndKey), Step: step}, nil
+	case ScenarioEventPDKill, ScenarioEventPDRestart, ScenarioEventPDPartition, ScenarioEventPDHeal:
+		step := func(tick int64) bool { return tick == startTick }
+		switch e.Type {
+		case ScenarioEventPDKill:
+			return &KillPDDescriptor{Member: e.Member, Step: step}, nil
+		case ScenarioEventPDRestart:
+			return &RestartPDDescriptor{Member: e.Member, Step: step}, nil
+		case ScenarioEventPDPartition:
+			return &PartitionPDDescriptor{Member: e.Member, Step: step}, nil
+		}
+		return &HealPDDescriptor{Step: step}, nil
+	case ScenarioEventHeartbeatDelay:
+		delay, err := time.ParseDuration(e.Delay)
+		if err != nil || delay <= 0 {
+			return nil, errors.Errorf("delay of %s event at tick %d should be a positive duration", e.Type, e.Tick)
+		}
+		if e.EndTick != 0 && e.EndTick <= e.Tick {
+			return nil, errors.Errorf("end tick of %s event at tick %d should be larger than the tick", e.Type, e.Tick)
+		}
+		endTick := e.EndTick
+		return &DelayHeartbeatsDescriptor{
+			StoreIDs: e.StoreIDs,
+			Step: func(tick int64) (time.Duration, bool) {
+				switch tick {
+				case startTick:
+					return delay, true
+				case endTick:
+					return 0, true
+				}
+				return 0, false
+			},
+		}, nil
+	}
+	return nil, errors.Errorf("unknown event type %q", e.Type)
+}
+
+func (c *ScenarioChecker) newChecker(lastTick int64, hotWriteRanges, hotReadRanges [][2][]byte) (CheckerFunc, error) {
+	if c.RegionCountTolerance == nil && c.LeaderCountTolerance == nil && c.HotWriteLeaderDiff == nil &&
+		c.HotWritePeerDiff == nil && c.HotReadLeaderDiff == nil && c.Replicas == nil {
+		return nil, errors.New("no threshold in checker")
+	}
+	var tick int64
+	return func(regions *core.RegionsInfo, stats []info.StoreStats) bool {
+		tick++
+		var storeIDs []uint64
+		for _, s := range stats {
+			if s.GetStoreId() != 0 {
+				storeIDs = append(storeIDs, s.GetStoreId())
+			}
+		}
+		if len(storeIDs) == 0 {
+			return false
+		}
+		res := tick > lastTick
+
+		regionCounts := make([]int, 0, len(storeIDs))
+		leaderCounts := make([]int, 0, len(storeIDs))
+		for _, id := range storeIDs {
+			regionCounts = append(regionCounts, regions.GetStoreRegionCount(id))
+			leaderCounts = append(leaderCounts, regions.GetStoreLeaderCount(id))
+		}
+		simutil.Logger.Info("current counts",
+			zap.Uint64s("stores", storeIDs), zap.Ints("region", regionCounts), zap.Ints("leader", leaderCounts))
+		if c.RegionCountTolerance != nil {
+			res = res && isBalanced(regionCounts, *c.RegionCountTolerance)
+		}
+		if c.LeaderCountTolerance != nil {
+			res = res && isBalanced(leaderCounts, *c.LeaderCountTolerance)
+		}
+
+		if c.HotWriteLeaderDiff != nil || c.HotWritePeerDiff != nil {
+			leaders, peers := hotRegionCounts(regions, storeIDs, hotWriteRanges)
+			simutil.Logger.Info("current hot write region counts", zap.Ints("leader", leaders), zap.Ints("peer", peers))
+			if c.HotWriteLeaderDiff != nil {
+				res = res && maxDiff(leaders) <= *c.HotWriteLeaderDiff
+			}
+			if c.HotWritePeerDiff != nil {
+				res = res && maxDiff(peers) <= *c.HotWritePeerDiff
+			}
+		}
+		if c.HotReadLeaderDiff != nil {
+			leaders, _ := hotRegionCounts(regions, storeIDs, hotReadRanges)
+			simutil.Logger.Info("current hot read region counts", zap.Ints("leader", leaders))
+			res = res && maxDiff(leaders) <= *c.HotReadLeaderDiff
+		}
+
+		if c.Replicas != nil {
+			alive := make(map[uint64]struct{}, len(storeIDs))
+			for _, id := range storeIDs {
+				alive[id] = struct{}{}
+			}
+			var unexpected int
+			for _, region := range regions.GetRegions() {
+				count := 0
+				for _, peer := range region.GetPeers() {
+					if _, ok := alive[peer.GetStoreId()]; ok {
+						count++
+					}
+				}
+				if count != *c.Replicas || count != len(region.GetPeers()) {
+					unexpected++
+				}
+			}
+			simutil.Logger.Info("current unexpected replicas", zap.Int("region", unexpected))
+			res = res && unexpected == 0
+		}
+		return res
+	}, nil
+}
+
+func isBalanced(counts []int, tolerance float64) bool {
+	var sum int
+	for _, count := range counts {
+		sum += count
+	}
+	mean := float64(sum) / float64(len(counts))
+	for _, count := range counts {
+		if math.Abs(float64(count)-mean) > mean*tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func hotRegionCounts(regions *core.RegionsInfo, storeIDs []uint64, ranges [][2][]byte) (leaders, peers []int) {
+	index := make(map[uint64]int, len(storeIDs))
+	for i, id := range storeIDs {
+		index[id] = i
+	}
+	leaders, peers = make([]int, len(storeIDs)), make([]int, len(storeIDs))
+	for _, r := range ranges {
+		for _, region := range regions.ScanRange(r[0], r[1], 0) {
+			if i, ok := index[region.GetLeader().GetStoreId()]; ok {
+				leaders[i]++
+			}
+			for _, peer := range region.GetPeers() {
+				if i, ok := index[peer.GetStoreId()]; ok {
+					peers[i]++
+				}
+			}
+		}
+	}
+	return
+}
+
+func maxDiff(counts []int) int {
+	if len(counts) == 0 {
+		return 0
+	}
+	min, max := counts[0], counts[0]
+	for _, count := range counts {
+		if count < min {
+			min = count
+		}
+		if count > max {
+			max = count
+		}
+	}
+	return max - min
+}
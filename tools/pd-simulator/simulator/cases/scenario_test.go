@@ -0,0 +1,380 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cases
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/placement"
+	"github.com/tikv/pd/tools/pd-simulator/simulator/info"
+	"github.com/tikv/pd/tools/pd-simulator/simulator/simutil"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testScenarioSuite{})
+
+type testScenarioSuite struct {
+	dir string
+}
+
+func (s *testScenarioSuite) SetUpSuite(c *C) {
+	simutil.InitLogger("fatal", "")
+}
+
+func (s *testScenarioSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	IDAllocator.ResetID()
+}
+
+func (s *testScenarioSuite) writeFile(c *C, name, content string) string {
+	path := filepath.Join(s.dir, name)
+	c.Assert(os.WriteFile(path, []byte(content), 0644), IsNil)
+	return path
+}
+
+func (s *testScenarioSuite) TestLoadExamples(c *C) {
+	files, err := filepath.Glob("../../scenarios/*")
+	c.Assert(err, IsNil)
+	c.Assert(files, Not(HasLen), 0)
+	for _, file := range files {
+		c.Assert(IsScenarioFile(file), IsTrue)
+		IDAllocator.ResetID()
+		simCase, err := LoadScenario(file)
+		c.Assert(err, IsNil, Commentf("file %s", file))
+		c.Assert(simCase.Stores, Not(HasLen), 0)
+		c.Assert(simCase.Regions, Not(HasLen), 0)
+		c.Assert(simCase.Checker, NotNil)
+	}
+}
+
+func (s *testScenarioSuite) TestScenario(c *C) {
+	toml := `
+region-split-size = 64
+[[stores]]
+id = 1
+count = 3
+labels = { zone = "z1" }
+capacity = 100
+[[stores]]
+id = 10
+labels = { zone = "z2", host = "h1" }
+[[regions]]
+start-key = "b"
+count = 4
+stores = [1, 2, 3]
+leader-stores = [3]
+[[regions]]
+end-key = "b"
+count = 2
+replicas = 1
+[[rules]]
+id = "r1"
+start-key = "b"
+count = 2
+label-constraints = [{ key = "zone", op = "in", values = ["z1"] }]
+[[events]]
+type = "add-nodes"
+tick = 5
+store = { id = 20, count = 2 }
+[[events]]
+type = "hot-read"
+tick = 3
+end-tick = 10
+start-key = "b"
+flow = 2
+[checker]
+replicas = 3
+`
+	yaml := `
+region-split-size: 64
+stores:
+  - id: 1
+    count: 3
+    labels: {zone: z1}
+    capacity: 100
+  - id: 10
+    labels: {zone: z2, host: h1}
+regions:
+  - start-key: b
+    count: 4
+    stores: [1, 2, 3]
+    leader-stores: [3]
+  - end-key: b
+    count: 2
+    replicas: 1
+rules:
+  - id: r1
+    start-key: b
+    count: 2
+    label-constraints:
+      - {key: zone, op: in, values: [z1]}
+events:
+  - type: add-nodes
+    tick: 5
+    store: {id: 20, count: 2}
+  - type: hot-read
+    tick: 3
+    end-tick: 10
+    start-key: b
+    flow: 2
+checker:
+  replicas: 3
+`
+	for _, path := range []string{s.writeFile(c, "scenario.toml", toml), s.writeFile(c, "scenario.yaml", yaml)} {
+		IDAllocator.ResetID()
+		simCase, err := LoadScenario(path)
+		c.Assert(err, IsNil)
+		c.Assert(simCase.RegionSplitSize, Equals, int64(64*MB))
+
+		c.Assert(simCase.Stores, HasLen, 4)
+		c.Assert(simCase.Stores[2].ID, Equals, uint64(3))
+		c.Assert(simCase.Stores[2].Capacity, Equals, uint64(100*GB))
+		c.Assert(simCase.Stores[3].ID, Equals, uint64(10))
+		c.Assert(simCase.Stores[3].Labels, DeepEquals, []*metapb.StoreLabel{{Key: "host", Value: "h1"}, {Key: "zone", Value: "z2"}})
+
+		// The regions are sorted by keys and the IDs are allocated after the stores.
+		c.Assert(simCase.Regions, HasLen, 6)
+		var lastEnd []byte
+		for i, region := range simCase.Regions {
+			c.Assert(region.ID, Greater, uint64(21))
+			c.Assert(string(region.StartKey), Equals, string(lastEnd))
+			lastEnd = region.EndKey
+			if i < 2 {
+				c.Assert(region.Peers, HasLen, 1)
+				continue
+			}
+			c.Assert(region.Peers, HasLen, 3)
+			c.Assert(region.Leader.GetStoreId(), Equals, uint64(3))
+			c.Assert(region.Size, Equals, int64(defaultScenarioRegionSize*MB))
+		}
+		c.Assert(string(simCase.Regions[1].EndKey), Equals, "b")
+		c.Assert(lastEnd, HasLen, 0)
+
+		c.Assert(simCase.Rules, HasLen, 1)
+		rule := simCase.Rules[0]
+		c.Assert(rule.GroupID, Equals, "pd")
+		c.Assert(rule.Role, Equals, placement.Voter)
+		c.Assert(rule.StartKeyHex, Equals, "62")
+		c.Assert(rule.LabelConstraints, DeepEquals, []placement.LabelConstraint{{Key: "zone", Op: placement.In, Values: []string{"z1"}}})
+
+		c.Assert(simCase.Events, HasLen, 2)
+		addNodes := simCase.Events[0].(*AddNodesDescriptor)
+		c.Assert(addNodes.Step(4), Equals, uint64(0))
+		c.Assert(addNodes.Step(5), Equals, uint64(20))
+		c.Assert(addNodes.Step(6), Equals, uint64(21))
+		c.Assert(addNodes.Step(7), Equals, uint64(0))
+		c.Assert(addNodes.Store(21).ID, Equals, uint64(21))
+		hotRead := simCase.Events[1].(*ReadFlowOnRangeDescriptor)
+		c.Assert(string(hotRead.StartKey), Equals, "b")
+		c.Assert(hotRead.Step(2), Equals, int64(0))
+		c.Assert(hotRead.Step(3), Equals, int64(2*MB))
+		c.Assert(hotRead.Step(10), Equals, int64(0))
+	}
+}
+
+func (s *testScenarioSuite) TestStoreEvents(c *C) {
+	path := s.writeFile(c, "store-events.toml", `
+[[stores]]
+id = 1
+count = 4
+[[regions]]
+count = 1
+[[events]]
+type = "store-down"
+tick = 5
+store-ids = [1]
+[[events]]
+type = "store-up"
+tick = 10
+store-ids = [1]
+[[events]]
+type = "store-offline"
+tick = 15
+store-ids = [2, 3]
+[checker]
+replicas = 3
+`)
+	simCase, err := LoadScenario(path)
+	c.Assert(err, IsNil)
+	c.Assert(simCase.Events, HasLen, 3)
+	down := simCase.Events[0].(*StoresDownDescriptor)
+	c.Assert(down.Step(4), HasLen, 0)
+	c.Assert(down.Step(5), DeepEquals, []uint64{1})
+	up := simCase.Events[1].(*StoresUpDescriptor)
+	c.Assert(up.Step(10), DeepEquals, []uint64{1})
+	c.Assert(up.Step(11), HasLen, 0)
+	offline := simCase.Events[2].(*StoresOfflineDescriptor)
+	c.Assert(offline.Step(15), DeepEquals, []uint64{2, 3})
+}
+
+func (s *testScenarioSuite) TestPDEvents(c *C) {
+	path := s.writeFile(c, "pd-events.yaml", `
+pd-count: 3
+stores:
+  - id: 1
+    count: 3
+regions:
+  - count: 1
+events:
+  - type: pd-kill
+    tick: 5
+  - type: pd-restart
+    tick: 10
+  - type: pd-partition
+    tick: 15
+    member: pd-2
+  - type: pd-heal
+    tick: 20
+  - type: heartbeat-delay
+    tick: 25
+    end-tick: 30
+    delay: 500ms
+    store-ids: [1]
+checker:
+  replicas: 3
+`)
+	simCase, err := LoadScenario(path)
+	c.Assert(err, IsNil)
+	c.Assert(simCase.PDCount, Equals, 3)
+	c.Assert(simCase.Events, HasLen, 5)
+	kill := simCase.Events[0].(*KillPDDescriptor)
+	c.Assert(kill.Member, Equals, "")
+	c.Assert(kill.Step(4), IsFalse)
+	c.Assert(kill.Step(5), IsTrue)
+	c.Assert(simCase.Events[1].(*RestartPDDescriptor).Step(10), IsTrue)
+	partition := simCase.Events[2].(*PartitionPDDescriptor)
+	c.Assert(partition.Member, Equals, "pd-2")
+	c.Assert(partition.Step(15), IsTrue)
+	c.Assert(simCase.Events[3].(*HealPDDescriptor).Step(20), IsTrue)
+
+	delay := simCase.Events[4].(*DelayHeartbeatsDescriptor)
+	c.Assert(delay.StoreIDs, DeepEquals, []uint64{1})
+	d, ok := delay.Step(25)
+	c.Assert(ok, IsTrue)
+	c.Assert(d, Equals, 500*time.Millisecond)
+	_, ok = delay.Step(26)
+	c.Assert(ok, IsFalse)
+	d, ok = delay.Step(30)
+	c.Assert(ok, IsTrue)
+	c.Assert(d, Equals, time.Duration(0))
+}
+
+func (s *testScenarioSuite) TestInvalidScenario(c *C) {
+	testCases := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"unknown.toml", "[[stores]]\nid = 1\nunknown = 1\n", ".*unknown items.*"},
+		{"unknown.yaml", "stores:\n  - id: 1\n    unknown: 1\n", "(?s).*field unknown not found.*"},
+		{"no-store.toml", "[checker]\nreplicas = 3\n", "no store in scenario"},
+		{"duplicated.toml", "[[stores]]\nid = 1\ncount = 2\n[[stores]]\nid = 2\n", "duplicated store 2"},
+		{"overlap.toml", `
+[[stores]]
+id = 1
+count = 3
+[[regions]]
+end-key = "c"
+count = 1
+[[regions]]
+start-key = "b"
+count = 1
+`, ".*overlap"},
+		{"replicas.toml", `
+[[stores]]
+id = 1
+count = 2
+[[regions]]
+count = 1
+`, ".*need 3 replicas but only 2 stores are available"},
+		{"event.toml", `
+[[stores]]
+id = 1
+count = 3
+[[regions]]
+count = 1
+[[events]]
+type = "unknown"
+tick = 1
+`, `unknown event type "unknown"`},
+		{"checker.toml", `
+[[stores]]
+id = 1
+count = 3
+[[regions]]
+count = 1
+`, "no threshold in checker"},
+		{"delay.toml", `
+[[stores]]
+id = 1
+count = 3
+[[regions]]
+count = 1
+[[events]]
+type = "heartbeat-delay"
+tick = 1
+`, ".*should be a positive duration"},
+	}
+	for _, t := range testCases {
+		_, err := LoadScenario(s.writeFile(c, t.name, t.content))
+		c.Assert(err, ErrorMatches, t.err, Commentf("file %s", t.name))
+	}
+}
+
+func (s *testScenarioSuite) TestChecker(c *C) {
+	tolerance, diff := 0.1, 0
+	checker := &ScenarioChecker{LeaderCountTolerance: &tolerance, HotWriteLeaderDiff: &diff}
+	check, err := checker.newChecker(2, [][2][]byte{{[]byte("a"), []byte("c")}}, nil)
+	c.Assert(err, IsNil)
+
+	regions := core.NewRegionsInfo()
+	for i, key := range []string{"", "a", "b", "c"} {
+		storeID := uint64(i%2 + 1)
+		peer := &metapb.Peer{Id: uint64(i + 10), StoreId: storeID}
+		meta := &metapb.Region{Id: uint64(i + 1), StartKey: []byte(key), Peers: []*metapb.Peer{peer}}
+		if i < 3 {
+			meta.EndKey = []byte([]string{"a", "b", "c"}[i])
+		}
+		regions.SetRegion(core.NewRegionInfo(meta, peer))
+	}
+	stats := []info.StoreStats{{}, {}, {}}
+	stats[1].StoreId, stats[2].StoreId = 1, 2
+
+	// The checker is not satisfied before all the events happen.
+	c.Assert(check(regions, stats), IsFalse)
+	c.Assert(check(regions, stats), IsFalse)
+	c.Assert(check(regions, stats), IsTrue)
+
+	// Both the hot regions in [a, c) are on store 1 after region 2 moves.
+	tolerance, diff = 1, 1
+	peer := &metapb.Peer{Id: 11, StoreId: 1}
+	regions.SetRegion(core.NewRegionInfo(&metapb.Region{Id: 2, StartKey: []byte("a"), EndKey: []byte("b"),
+		Peers: []*metapb.Peer{peer}}, peer))
+	c.Assert(check(regions, stats), IsFalse)
+	diff = 2
+	c.Assert(check(regions, stats), IsTrue)
+
+	// The deleted or down stores are not counted.
+	stats[2].StoreId = 0
+	c.Assert(check(regions, stats), IsTrue)
+}
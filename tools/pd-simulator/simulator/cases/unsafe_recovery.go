@@ -0,0 +1,118 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cases
+
+import (
+	"bytes"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/tools/pd-simulator/simulator/info"
+	"github.com/tikv/pd/tools/pd-simulator/simulator/simutil"
+	"go.uber.org/zap"
+)
+
+// newUnsafeRecovery fails the minority of the stores one by one, so some regions lose
+// the majority of their peers, and then removes them with unsafe recovery.
+func newUnsafeRecovery() *Case {
+	var simCase Case
+
+	storeNum, regionNum := getStoreNum(), getRegionNum()
+	for i := 1; i <= storeNum; i++ {
+		simCase.Stores = append(simCase.Stores, &Store{
+			ID:        IDAllocator.nextID(),
+			Status:    metapb.StoreState_Up,
+			Capacity:  1 * TB,
+			Available: 900 * GB,
+			Version:   "2.1.0",
+		})
+	}
+
+	for i := 0; i < regionNum*storeNum/3; i++ {
+		peers := []*metapb.Peer{
+			{Id: IDAllocator.nextID(), StoreId: uint64(i%storeNum) + 1},
+			{Id: IDAllocator.nextID(), StoreId: uint64((i+1)%storeNum) + 1},
+			{Id: IDAllocator.nextID(), StoreId: uint64((i+2)%storeNum) + 1},
+		}
+		simCase.Regions = append(simCase.Regions, Region{
+			ID:     IDAllocator.nextID(),
+			Peers:  peers,
+			Leader: peers[0],
+			Size:   96 * MB,
+			Keys:   960000,
+		})
+	}
+
+	failedNum := storeNum / 2
+	failed := make(map[uint64]struct{}, failedNum)
+	failedIDs := make([]uint64, 0, failedNum)
+	deleteEvent := &DeleteNodesDescriptor{}
+	deleteEvent.Step = func(tick int64) uint64 {
+		if len(failedIDs) < failedNum && tick >= 100 && tick%10 == 0 {
+			id := simCase.Stores[len(failedIDs)].ID
+			failed[id] = struct{}{}
+			failedIDs = append(failedIDs, id)
+			return id
+		}
+		return 0
+	}
+
+	removed := false
+	removeEvent := &RemoveFailedStoresDescriptor{}
+	removeEvent.Step = func(tick int64) []uint64 {
+		if !removed && len(failedIDs) == failedNum && tick >= 200 {
+			removed = true
+			return failedIDs
+		}
+		return nil
+	}
+	simCase.Events = []EventDescriptor{deleteEvent, removeEvent}
+
+	simCase.Checker = func(regions *core.RegionsInfo, stats []info.StoreStats) bool {
+		if !removed {
+			return false
+		}
+		var noLeader, lostQuorum, holes int
+		lastEndKey := []byte("")
+		for _, region := range regions.ScanRange(nil, nil, -1) {
+			if !bytes.Equal(region.GetStartKey(), lastEndKey) {
+				holes++
+			}
+			lastEndKey = region.GetEndKey()
+			if region.GetLeader() == nil {
+				noLeader++
+			}
+			var lost int
+			for _, peer := range region.GetVoters() {
+				if _, ok := failed[peer.GetStoreId()]; ok {
+					lost++
+				}
+			}
+			if lost*2 >= len(region.GetVoters()) {
+				lostQuorum++
+			}
+		}
+		if len(lastEndKey) != 0 {
+			holes++
+		}
+
+		simutil.Logger.Info("current recovery state",
+			zap.Int("region-count", regions.GetRegionCount()),
+			zap.Int("no-leader", noLeader),
+			zap.Int("lost-quorum", lostQuorum),
+			zap.Int("holes", holes))
+		return noLeader == 0 && lostQuorum == 0 && holes == 0
+	}
+	return &simCase
+}
@@ -14,15 +14,24 @@
 package simulator
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/server/cluster"
 	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/server/schedule/placement"
 	"github.com/tikv/pd/tools/pd-simulator/simulator/simutil"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
@@ -35,14 +44,31 @@ type Client interface {
 	AllocID(ctx context.Context) (uint64, error)
 	Bootstrap(ctx context.Context, store *metapb.Store, region *metapb.Region) error
 	PutStore(ctx context.Context, store *metapb.Store) error
+	GetStore(ctx context.Context, storeID uint64) (*metapb.Store, error)
+	DeleteStore(ctx context.Context, storeID uint64) error
 	StoreHeartbeat(ctx context.Context, stats *pdpb.StoreStats) error
 	RegionHeartbeat(ctx context.Context, region *core.RegionInfo) error
+	RemoveFailedStores(ctx context.Context, storeIDs []uint64) error
+	GetStoreRecoveryPlan(ctx context.Context, storeID uint64) (*cluster.StoreRecoveryPlan, error)
+	SetPlacementRules(ctx context.Context, rules []*placement.Rule) error
+	SetConfig(ctx context.Context, cfg map[string]interface{}) error
+	GetSchedulers(ctx context.Context) ([]string, error)
+	SetSchedulerConfig(ctx context.Context, name string, cfg map[string]interface{}) error
+	SetHeartbeatDelay(delay time.Duration)
 	Close()
 }
 
 const (
 	pdTimeout             = time.Second
 	maxInitClusterRetries = 100
+	updateLeaderInterval  = time.Second
+
+	storePrefix           = "pd/api/v1/store"
+	unsafeRecoveryPrefix  = "pd/api/v1/admin/unsafe"
+	configPrefix          = "pd/api/v1/config"
+	rulesPrefix           = "pd/api/v1/config/rules"
+	schedulersPrefix      = "pd/api/v1/schedulers"
+	schedulerConfigPrefix = "pd/api/v1/scheduler-config"
 )
 
 var (
@@ -51,82 +77,183 @@ var (
 )
 
 type client struct {
-	url        string
-	tag        string
-	clusterID  uint64
-	clientConn *grpc.ClientConn
+	urls      []string
+	tag       string
+	clusterID uint64
+
+	// The client connects to the PD leader and follows it when the leader changes.
+	leaderMu struct {
+		sync.RWMutex
+		url        string
+		clientConn *grpc.ClientConn
+	}
+	updateLeaderCh chan struct{}
+	// heartbeatDelay is the nanoseconds to delay the heartbeats.
+	heartbeatDelay int64
 
 	reportRegionHeartbeatCh  chan *core.RegionInfo
 	receiveRegionHeartbeatCh chan *pdpb.RegionHeartbeatResponse
+	delayedHeartbeatCh       chan *pdpb.RegionHeartbeatRequest
 
 	wg     sync.WaitGroup
 	ctx    context.Context
 	cancel context.CancelFunc
 }
 
-// NewClient creates a PD client.
+// NewClient creates a PD client. The pdAddr can be a comma separated list of
+// the PD members.
 func NewClient(pdAddr string, tag string) (Client, <-chan *pdpb.RegionHeartbeatResponse, error) {
 	simutil.Logger.Info("create pd client with endpoints", zap.String("tag", tag), zap.String("pd-address", pdAddr))
 	ctx, cancel := context.WithCancel(context.Background())
 	c := &client{
-		url:                      pdAddr,
+		urls:                     strings.Split(pdAddr, ","),
+		updateLeaderCh:           make(chan struct{}, 1),
 		reportRegionHeartbeatCh:  make(chan *core.RegionInfo, 1),
 		receiveRegionHeartbeatCh: make(chan *pdpb.RegionHeartbeatResponse, 1),
+		delayedHeartbeatCh:       make(chan *pdpb.RegionHeartbeatRequest, 1024),
 		ctx:                      ctx,
 		cancel:                   cancel,
 		tag:                      tag,
 	}
-	cc, err := c.createConn()
-	if err != nil {
-		return nil, nil, err
-	}
-	c.clientConn = cc
 	if err := c.initClusterID(); err != nil {
 		return nil, nil, err
 	}
 	simutil.Logger.Info("init cluster id", zap.String("tag", c.tag), zap.Uint64("cluster-id", c.clusterID))
-	c.wg.Add(1)
+	c.wg.Add(2)
+	go c.leaderLoop()
 	go c.heartbeatStreamLoop()
 
 	return c, c.receiveRegionHeartbeatCh, nil
 }
 
 func (c *client) pdClient() pdpb.PDClient {
-	return pdpb.NewPDClient(c.clientConn)
+	c.leaderMu.RLock()
+	defer c.leaderMu.RUnlock()
+	return pdpb.NewPDClient(c.leaderMu.clientConn)
+}
+
+func (c *client) leaderURL() string {
+	c.leaderMu.RLock()
+	defer c.leaderMu.RUnlock()
+	return c.leaderMu.url
 }
 
 func (c *client) initClusterID() error {
 	ctx, cancel := context.WithCancel(c.ctx)
 	defer cancel()
 	for i := 0; i < maxInitClusterRetries; i++ {
-		members, err := c.getMembers(ctx)
-		if err != nil || members.GetHeader() == nil {
+		if err := c.updateLeader(ctx); err != nil {
 			simutil.Logger.Error("failed to get cluster id", zap.String("tag", c.tag), zap.Error(err))
+			time.Sleep(100 * time.Millisecond)
 			continue
 		}
-		c.clusterID = members.GetHeader().GetClusterId()
 		return nil
 	}
 
 	return errors.WithStack(errFailInitClusterID)
 }
 
-func (c *client) getMembers(ctx context.Context) (*pdpb.GetMembersResponse, error) {
-	members, err := c.pdClient().GetMembers(ctx, &pdpb.GetMembersRequest{})
+// updateLeader gets the members from the PD members in turn and connects to
+// the leader.
+func (c *client) updateLeader(ctx context.Context) error {
+	var lastErr error
+	for _, url := range c.urls {
+		members, err := c.getMembers(ctx, url)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		leader := members.GetLeader()
+		if members.GetHeader() == nil || len(leader.GetClientUrls()) == 0 {
+			lastErr = errors.Errorf("no leader found from %s", url)
+			continue
+		}
+		if c.clusterID == 0 {
+			c.clusterID = members.GetHeader().GetClusterId()
+		}
+		leaderURL := leader.GetClientUrls()[0]
+		if leaderURL == c.leaderURL() {
+			return nil
+		}
+		cc, err := c.createConn(leaderURL)
+		if err != nil {
+			return err
+		}
+		c.leaderMu.Lock()
+		old := c.leaderMu.clientConn
+		c.leaderMu.url, c.leaderMu.clientConn = leaderURL, cc
+		c.leaderMu.Unlock()
+		if old != nil {
+			old.Close()
+		}
+		simutil.Logger.Info("switch pd leader", zap.String("tag", c.tag), zap.String("leader", leader.GetName()), zap.String("url", leaderURL))
+		return nil
+	}
+	return lastErr
+}
+
+// leaderLoop updates the leader when the requests fail.
+func (c *client) leaderLoop() {
+	defer c.wg.Done()
+	for {
+		select {
+		case <-c.updateLeaderCh:
+			ctx, cancel := context.WithTimeout(c.ctx, pdTimeout)
+			if err := c.updateLeader(ctx); err != nil {
+				simutil.Logger.Error("update pd leader error", zap.String("tag", c.tag), zap.Error(err))
+			}
+			cancel()
+			select {
+			case <-time.After(updateLeaderInterval):
+			case <-c.ctx.Done():
+				return
+			}
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *client) scheduleUpdateLeader() {
+	select {
+	case c.updateLeaderCh <- struct{}{}:
+	default:
+	}
+}
+
+func (c *client) getMembers(ctx context.Context, url string) (*pdpb.GetMembersResponse, error) {
+	cc, err := c.createConn(url)
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+	ctx, cancel := context.WithTimeout(ctx, pdTimeout)
+	defer cancel()
+	members, err := pdpb.NewPDClient(cc).GetMembers(ctx, &pdpb.GetMembersRequest{})
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 	return members, nil
 }
 
-func (c *client) createConn() (*grpc.ClientConn, error) {
-	cc, err := grpc.Dial(strings.TrimPrefix(c.url, "http://"), grpc.WithInsecure())
+func (c *client) createConn(url string) (*grpc.ClientConn, error) {
+	cc, err := grpc.Dial(strings.TrimPrefix(url, "http://"), grpc.WithInsecure(), grpc.WithUnaryInterceptor(c.checkLeader))
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 	return cc, nil
 }
 
+// checkLeader updates the leader in the background if the request fails, the
+// leader may have changed.
+func (c *client) checkLeader(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err != nil && !strings.HasSuffix(method, "/GetMembers") {
+		c.scheduleUpdateLeader()
+	}
+	return err
+}
+
 func (c *client) createHeartbeatStream() (pdpb.PD_RegionHeartbeatClient, context.Context, context.CancelFunc) {
 	var (
 		stream pdpb.PD_RegionHeartbeatClient
@@ -140,6 +267,7 @@ func (c *client) createHeartbeatStream() (pdpb.PD_RegionHeartbeatClient, context
 		if err != nil {
 			simutil.Logger.Error("create region heartbeat stream error", zap.String("tag", c.tag), zap.Error(err))
 			cancel()
+			c.scheduleUpdateLeader()
 			select {
 			case <-time.After(time.Second):
 				continue
@@ -169,6 +297,7 @@ func (c *client) heartbeatStreamLoop() {
 		case err := <-errCh:
 			simutil.Logger.Error("heartbeat stream get error", zap.String("tag", c.tag), zap.Error(err))
 			cancel()
+			c.scheduleUpdateLeader()
 		case <-c.ctx.Done():
 			simutil.Logger.Info("cancel heartbeat stream loop")
 			return
@@ -182,7 +311,7 @@ func (c *client) receiveRegionHeartbeat(ctx context.Context, stream pdpb.PD_Regi
 	for {
 		resp, err := stream.Recv()
 		if err != nil {
-			errCh <- err
+			reportStreamError(errCh, err)
 			return
 		}
 		select {
@@ -209,10 +338,20 @@ func (c *client) reportRegionHeartbeat(ctx context.Context, stream pdpb.PD_Regio
 				ApproximateSize: uint64(region.GetApproximateSize()),
 				ApproximateKeys: uint64(region.GetApproximateKeys()),
 			}
-			err := stream.Send(request)
-			if err != nil {
-				errCh <- err
+			if delay := c.getHeartbeatDelay(); delay > 0 {
+				go c.delayHeartbeat(ctx, request, delay)
+				continue
+			}
+			if err := stream.Send(request); err != nil {
+				simutil.Logger.Error("report regionHeartbeat error", zap.String("tag", c.tag), zap.Error(err))
+				reportStreamError(errCh, err)
+				return
+			}
+		case request := <-c.delayedHeartbeatCh:
+			if err := stream.Send(request); err != nil {
 				simutil.Logger.Error("report regionHeartbeat error", zap.String("tag", c.tag), zap.Error(err))
+				reportStreamError(errCh, err)
+				return
 			}
 		case <-ctx.Done():
 			return
@@ -220,11 +359,43 @@ func (c *client) reportRegionHeartbeat(ctx context.Context, stream pdpb.PD_Regio
 	}
 }
 
+// reportStreamError reports the first error of the stream, the later errors
+// are dropped so the goroutines of the stream can exit.
+func reportStreamError(errCh chan error, err error) {
+	select {
+	case errCh <- err:
+	default:
+	}
+}
+
+func (c *client) delayHeartbeat(ctx context.Context, request *pdpb.RegionHeartbeatRequest, delay time.Duration) {
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return
+	}
+	select {
+	case c.delayedHeartbeatCh <- request:
+	case <-ctx.Done():
+	}
+}
+
+// SetHeartbeatDelay delays the store and region heartbeats, zero means no delay.
+func (c *client) SetHeartbeatDelay(delay time.Duration) {
+	atomic.StoreInt64(&c.heartbeatDelay, int64(delay))
+}
+
+func (c *client) getHeartbeatDelay() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.heartbeatDelay))
+}
+
 func (c *client) Close() {
 	c.cancel()
 	c.wg.Wait()
 
-	if err := c.clientConn.Close(); err != nil {
+	c.leaderMu.Lock()
+	defer c.leaderMu.Unlock()
+	if err := c.leaderMu.clientConn.Close(); err != nil {
 		simutil.Logger.Error("failed to close grpc client connection", zap.String("tag", c.tag), zap.Error(err))
 	}
 }
@@ -288,7 +459,54 @@ func (c *client) PutStore(ctx context.Context, store *metapb.Store) error {
 	return nil
 }
 
+func (c *client) GetStore(ctx context.Context, storeID uint64) (*metapb.Store, error) {
+	ctx, cancel := context.WithTimeout(ctx, pdTimeout)
+	resp, err := c.pdClient().GetStore(ctx, &pdpb.GetStoreRequest{
+		Header:  c.requestHeader(),
+		StoreId: storeID,
+	})
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	if resp.Header.GetError() != nil {
+		return nil, errors.Errorf("get store error: %s", resp.Header.GetError().String())
+	}
+	return resp.GetStore(), nil
+}
+
+// DeleteStore makes the store offline, PD removes all its peers and sets it
+// to tombstone at last.
+func (c *client) DeleteStore(ctx context.Context, storeID uint64) error {
+	code, res, err := c.doHTTPRequest(ctx, http.MethodDelete, fmt.Sprintf("%s/%d", storePrefix, storeID), nil)
+	if err != nil {
+		return err
+	}
+	if code != http.StatusOK {
+		return errors.Errorf("[%d] %s", code, res)
+	}
+	return nil
+}
+
 func (c *client) StoreHeartbeat(ctx context.Context, stats *pdpb.StoreStats) error {
+	if delay := c.getHeartbeatDelay(); delay > 0 {
+		stats = proto.Clone(stats).(*pdpb.StoreStats)
+		go func() {
+			select {
+			case <-time.After(delay):
+			case <-c.ctx.Done():
+				return
+			}
+			if err := c.storeHeartbeat(c.ctx, stats); err != nil {
+				simutil.Logger.Info("report delayed heartbeat error", zap.String("tag", c.tag), zap.Error(err))
+			}
+		}()
+		return nil
+	}
+	return c.storeHeartbeat(ctx, stats)
+}
+
+func (c *client) storeHeartbeat(ctx context.Context, stats *pdpb.StoreStats) error {
 	ctx, cancel := context.WithTimeout(ctx, pdTimeout)
 	resp, err := c.pdClient().StoreHeartbeat(ctx, &pdpb.StoreHeartbeatRequest{
 		Header: c.requestHeader(),
@@ -310,6 +528,131 @@ func (c *client) RegionHeartbeat(ctx context.Context, region *core.RegionInfo) e
 	return nil
 }
 
+// The recovery plans can't be carried by the store heartbeat responses, so they are
+// fetched with the HTTP API after the store heartbeats.
+func (c *client) httpURL(path string) string {
+	addr := c.leaderURL()
+	if !strings.HasPrefix(addr, "http://") && !strings.HasPrefix(addr, "https://") {
+		addr = "http://" + addr
+	}
+	return strings.TrimSuffix(addr, "/") + "/" + path
+}
+
+func (c *client) doHTTPRequest(ctx context.Context, method, path string, body []byte) (int, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, pdTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, c.httpURL(path), bytes.NewBuffer(body))
+	if err != nil {
+		return 0, nil, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	res, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, errors.WithStack(err)
+	}
+	return resp.StatusCode, res, nil
+}
+
+func (c *client) RemoveFailedStores(ctx context.Context, storeIDs []uint64) error {
+	data, err := json.Marshal(map[string]interface{}{"stores": storeIDs})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	code, res, err := c.doHTTPRequest(ctx, http.MethodPost, unsafeRecoveryPrefix+"/remove-failed-stores", data)
+	if err != nil {
+		return err
+	}
+	if code != http.StatusOK {
+		return errors.Errorf("[%d] %s", code, res)
+	}
+	return nil
+}
+
+// GetStoreRecoveryPlan fetches the plan with POST, which marks it delivered.
+func (c *client) GetStoreRecoveryPlan(ctx context.Context, storeID uint64) (*cluster.StoreRecoveryPlan, error) {
+	code, res, err := c.doHTTPRequest(ctx, http.MethodPost, fmt.Sprintf("%s/plan/%d", unsafeRecoveryPrefix, storeID), nil)
+	if err != nil {
+		return nil, err
+	}
+	switch code {
+	case http.StatusOK:
+		plan := &cluster.StoreRecoveryPlan{}
+		if err := json.Unmarshal(res, plan); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return plan, nil
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, errors.Errorf("[%d] %s", code, res)
+	}
+}
+
+func (c *client) SetPlacementRules(ctx context.Context, rules []*placement.Rule) error {
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	code, res, err := c.doHTTPRequest(ctx, http.MethodPost, rulesPrefix, data)
+	if err != nil {
+		return err
+	}
+	if code != http.StatusOK {
+		return errors.Errorf("[%d] %s", code, res)
+	}
+	return nil
+}
+
+func (c *client) SetConfig(ctx context.Context, cfg map[string]interface{}) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	code, res, err := c.doHTTPRequest(ctx, http.MethodPost, configPrefix, data)
+	if err != nil {
+		return err
+	}
+	if code != http.StatusOK {
+		return errors.Errorf("[%d] %s", code, res)
+	}
+	return nil
+}
+
+func (c *client) GetSchedulers(ctx context.Context) ([]string, error) {
+	code, res, err := c.doHTTPRequest(ctx, http.MethodGet, schedulersPrefix, nil)
+	if err != nil {
+		return nil, err
+	}
+	if code != http.StatusOK {
+		return nil, errors.Errorf("[%d] %s", code, res)
+	}
+	var names []string
+	if err := json.Unmarshal(res, &names); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return names, nil
+}
+
+func (c *client) SetSchedulerConfig(ctx context.Context, name string, cfg map[string]interface{}) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	code, res, err := c.doHTTPRequest(ctx, http.MethodPost, fmt.Sprintf("%s/%s/config", schedulerConfigPrefix, name), data)
+	if err != nil {
+		return err
+	}
+	if code != http.StatusOK {
+		return errors.Errorf("[%d] %s", code, res)
+	}
+	return nil
+}
+
 func (c *client) requestHeader() *pdpb.RequestHeader {
 	return &pdpb.RequestHeader{
 		ClusterId: c.clusterID,
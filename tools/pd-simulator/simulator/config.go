@@ -1,14 +1,18 @@
 package simulator
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/pingcap/errors"
 	"github.com/tikv/pd/pkg/tempurl"
 	"github.com/tikv/pd/pkg/typeutil"
 	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/tools/pd-simulator/simulator/cases"
 )
 
 const (
@@ -96,3 +100,76 @@ func (sc *SimConfig) Adjust(meta *toml.MetaData) error {
 
 	return sc.ServerConfig.Adjust(meta, false)
 }
+
+// adjustStore uses the store configurations for the unset fields of the store.
+func (sc *SimConfig) adjustStore(s *cases.Store) *cases.Store {
+	adjustUint64(&s.Capacity, sc.StoreCapacityGB*cases.GB)
+	adjustUint64(&s.Available, sc.StoreAvailableGB*cases.GB)
+	adjustInt64(&s.IORate, sc.StoreIOMBPerSecond)
+	adjustString(&s.Version, sc.StoreVersion)
+	return s
+}
+
+// ApplyConfigItems sets the items of the server configuration, the keys are
+// in the form of the PD config API, like "schedule.tolerant-size-ratio". The
+// items of the schedulers, like "balance-hot-region-scheduler.src-tolerance-ratio",
+// are returned by the scheduler name, they can only be set after the
+// schedulers are added.
+func (sc *SimConfig) ApplyConfigItems(items map[string]interface{}) (map[string]map[string]interface{}, error) {
+	schedulerItems := make(map[string]map[string]interface{})
+	for key, value := range items {
+		kp := strings.SplitN(key, ".", 2)
+		if len(kp) != 2 {
+			return nil, errors.Errorf("invalid config item %s", key)
+		}
+		var section interface{}
+		switch kp[0] {
+		case "schedule":
+			section = &sc.ServerConfig.Schedule
+		case "replication":
+			section = &sc.ServerConfig.Replication
+		case "pd-server":
+			section = &sc.ServerConfig.PDServerCfg
+		default:
+			if !strings.HasSuffix(kp[0], "-scheduler") {
+				return nil, errors.Errorf("config prefix %s not found", kp[0])
+			}
+			if schedulerItems[kp[0]] == nil {
+				schedulerItems[kp[0]] = make(map[string]interface{})
+			}
+			schedulerItems[kp[0]][kp[1]] = value
+			continue
+		}
+		if err := setConfigItem(section, kp[1], value); err != nil {
+			return nil, errors.Annotatef(err, "failed to set config item %s", key)
+		}
+	}
+	if err := sc.ServerConfig.Schedule.Validate(); err != nil {
+		return nil, err
+	}
+	if err := sc.ServerConfig.Replication.Validate(); err != nil {
+		return nil, err
+	}
+	return schedulerItems, nil
+}
+
+// setConfigItem sets the item of the config section by its JSON name like
+// the PD config API.
+func setConfigItem(section interface{}, item string, value interface{}) error {
+	data, err := json.Marshal(section)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	m := make(map[string]interface{})
+	if err = json.Unmarshal(data, &m); err != nil {
+		return errors.WithStack(err)
+	}
+	if _, ok := m[item]; !ok {
+		return errors.Errorf("item %s not found", item)
+	}
+	m[item] = value
+	if data, err = json.Marshal(m); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(json.Unmarshal(data, section))
+}
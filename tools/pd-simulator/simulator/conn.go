@@ -32,7 +32,7 @@ func NewConnection(simCase *cases.Case, pdAddr string, storeConfig *SimConfig) (
 	}
 
 	for _, store := range simCase.Stores {
-		node, err := NewNode(store, pdAddr, storeConfig.StoreIOMBPerSecond)
+		node, err := NewNode(storeConfig.adjustStore(store), pdAddr, store.IORate)
 		if err != nil {
 			return nil, err
 		}
@@ -48,5 +48,5 @@ func (c *Connection) nodeHealth(storeID uint64) bool {
 		return false
 	}
 
-	return n.GetState() == metapb.StoreState_Up
+	return n.GetState() != metapb.StoreState_Tombstone && !n.IsDown()
 }
@@ -15,6 +15,7 @@ package simulator
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"github.com/pingcap/errors"
@@ -37,19 +38,52 @@ type Driver struct {
 	raftEngine  *RaftEngine
 	conn        *Connection
 	simConfig   *SimConfig
+	// pd is nil if the simulator runs with an external PD.
+	pd *PDCluster
+	// report is nil if the time series is not recorded.
+	report *Report
+	// schedulerConfigs are the scheduler configurations which are not set yet.
+	schedulerConfigs map[string]map[string]interface{}
+	// schedulerConfigTicks is the tick when each scheduler config item is set,
+	// the key is in the form of "<scheduler>.<item>".
+	schedulerConfigTicks map[string]int64
+	// schedulerConfigFailures is the count of the failures to set the
+	// configuration after the scheduler is added.
+	schedulerConfigFailures map[string]int
+	schedulerConfigErr      error
 }
 
-// NewDriver returns a driver.
-func NewDriver(pdAddr string, caseName string, simConfig *SimConfig) (*Driver, error) {
+const (
+	// schedulerConfigPeriod is the period to try to set the scheduler
+	// configurations, the schedulers are added after PD collects the regions.
+	schedulerConfigPeriod = 10
+	// maxSchedulerConfigFailures is the max count of the failures to set the
+	// configuration after the scheduler is added, the retries cover the PD
+	// leader changes.
+	maxSchedulerConfigFailures = 3
+)
+
+// LoadCase returns the case, it is loaded from the file if caseName is a
+// scenario file.
+func LoadCase(caseName string) (*cases.Case, error) {
+	if cases.IsScenarioFile(caseName) {
+		return cases.LoadScenario(caseName)
+	}
 	simCase := cases.NewCase(caseName)
 	if simCase == nil {
 		return nil, errors.Errorf("failed to create case %s", caseName)
 	}
+	return simCase, nil
+}
+
+// NewDriver returns a driver.
+func NewDriver(pdAddr string, simCase *cases.Case, simConfig *SimConfig, pd *PDCluster) *Driver {
 	return &Driver{
 		pdAddr:    pdAddr,
 		simCase:   simCase,
 		simConfig: simConfig,
-	}, nil
+		pd:        pd,
+	}
 }
 
 // Prepare initializes cluster information, bootstraps cluster and starts nodes.
@@ -61,7 +95,7 @@ func (d *Driver) Prepare() error {
 	d.conn = conn
 
 	d.raftEngine = NewRaftEngine(d.simCase, d.conn, d.simConfig)
-	d.eventRunner = NewEventRunner(d.simCase.Events, d.raftEngine)
+	d.eventRunner = NewEventRunner(d.simCase.Events, d.raftEngine, d.pd)
 
 	// Bootstrap.
 	store, region, err := d.GetBootstrapInfo(d.raftEngine)
@@ -98,6 +132,17 @@ func (d *Driver) Prepare() error {
 		return err
 	}
 
+	// The rules are set after the stores are put, PD rejects the rules that
+	// can't match any store. The keys of the rules are raw keys.
+	if len(d.simCase.Rules) > 0 {
+		if err = d.client.SetConfig(context.Background(), map[string]interface{}{"pd-server.key-type": "raw"}); err != nil {
+			return err
+		}
+		if err = d.client.SetPlacementRules(context.Background(), d.simCase.Rules); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -106,12 +151,22 @@ func (d *Driver) Tick() {
 	d.tickCount++
 	d.raftEngine.stepRegions()
 	d.eventRunner.Tick(d.tickCount)
+	if len(d.schedulerConfigs) > 0 && d.tickCount%schedulerConfigPeriod == 0 {
+		d.applySchedulerConfigs()
+	}
 	for _, n := range d.conn.Nodes {
 		n.reportRegionChange()
 		d.wg.Add(1)
 		go n.Tick(&d.wg)
 	}
 	d.wg.Wait()
+	d.raftEngine.schedulerStats.pdStats.tick(d.tickCount)
+	if d.pd != nil {
+		d.pd.tick(d.tickCount, d.raftEngine.schedulerStats.pdStats)
+	}
+	if d.report != nil {
+		d.report.record(d.tickCount, d.raftEngine, d.conn.Nodes)
+	}
 }
 
 // Check checks if the simulation is completed.
@@ -124,6 +179,10 @@ func (d *Driver) Check() bool {
 	}
 	stats := make([]info.StoreStats, length)
 	for index, node := range d.conn.Nodes {
+		// The down, offline and tombstone stores are not checked.
+		if node.IsDown() || node.GetState() != metapb.StoreState_Up {
+			continue
+		}
 		stats[index] = *node.stats
 	}
 	return d.simCase.Checker(d.raftEngine.regionsInfo, stats)
@@ -150,6 +209,81 @@ func (d *Driver) Stop() {
 	for _, n := range d.conn.Nodes {
 		n.Stop()
 	}
+	if d.report != nil {
+		d.report.PendingSchedulerConfigs = d.PendingSchedulerConfigs()
+	}
+}
+
+// SetSchedulerConfigs sets the scheduler configurations once the schedulers
+// are added, the configurations are indexed by the scheduler name.
+func (d *Driver) SetSchedulerConfigs(cfgs map[string]map[string]interface{}) {
+	d.schedulerConfigs = cfgs
+	d.schedulerConfigTicks = make(map[string]int64)
+	d.schedulerConfigFailures = make(map[string]int)
+}
+
+func (d *Driver) applySchedulerConfigs() {
+	names, err := d.client.GetSchedulers(context.Background())
+	if err != nil {
+		simutil.Logger.Debug("get schedulers failed", zap.Error(err))
+		return
+	}
+	added := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		added[name] = struct{}{}
+	}
+	for name, cfg := range d.schedulerConfigs {
+		if _, ok := added[name]; !ok {
+			continue
+		}
+		if err := d.client.SetSchedulerConfig(context.Background(), name, cfg); err != nil {
+			simutil.Logger.Warn("set scheduler config failed", zap.String("scheduler", name), zap.Error(err))
+			d.schedulerConfigFailures[name]++
+			if d.schedulerConfigFailures[name] >= maxSchedulerConfigFailures && d.schedulerConfigErr == nil {
+				d.schedulerConfigErr = errors.Annotatef(err, "failed to set the config of %s", name)
+			}
+			continue
+		}
+		simutil.Logger.Info("set scheduler config", zap.String("scheduler", name), zap.Int64("tick", d.tickCount), zap.Any("config", cfg))
+		for key := range cfg {
+			d.schedulerConfigTicks[name+"."+key] = d.tickCount
+		}
+		delete(d.schedulerConfigs, name)
+	}
+	if d.report != nil {
+		d.report.SchedulerConfigs = d.schedulerConfigTicks
+	}
+}
+
+// SchedulerConfigError returns the error if a scheduler configuration still
+// can't be set after the scheduler is added, the result of the case is
+// invalid.
+func (d *Driver) SchedulerConfigError() error {
+	return d.schedulerConfigErr
+}
+
+// PendingSchedulerConfigs returns the scheduler config items which are not
+// set yet, in the form of "<scheduler>.<item>".
+func (d *Driver) PendingSchedulerConfigs() []string {
+	var items []string
+	for name, cfg := range d.schedulerConfigs {
+		for key := range cfg {
+			items = append(items, name+"."+key)
+		}
+	}
+	sort.Strings(items)
+	return items
+}
+
+// EnableReport records the status of the cluster at the end of every tick.
+func (d *Driver) EnableReport(name string) {
+	d.report = NewReport(name)
+}
+
+// Report returns the recorded time series, it is nil if the report is not
+// enabled.
+func (d *Driver) Report() *Report {
+	return d.report
 }
 
 // TickCount returns the simulation's tick count.
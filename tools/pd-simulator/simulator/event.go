@@ -14,9 +14,9 @@
 package simulator
 
 import (
+	"context"
+
 	"github.com/pingcap/kvproto/pkg/metapb"
-	"github.com/pingcap/kvproto/pkg/pdpb"
-	"github.com/tikv/pd/server/core"
 	"github.com/tikv/pd/tools/pd-simulator/simulator/cases"
 	"github.com/tikv/pd/tools/pd-simulator/simulator/simutil"
 	"go.uber.org/zap"
@@ -33,11 +33,11 @@ type EventRunner struct {
 	raftEngine *RaftEngine
 }
 
-// NewEventRunner creates an event runner.
-func NewEventRunner(events []cases.EventDescriptor, raftEngine *RaftEngine) *EventRunner {
+// NewEventRunner creates an event runner, pd is used by the PD events.
+func NewEventRunner(events []cases.EventDescriptor, raftEngine *RaftEngine, pd *PDCluster) *EventRunner {
 	er := &EventRunner{events: make([]Event, 0, len(events)), raftEngine: raftEngine}
 	for _, e := range events {
-		event := parserEvent(e)
+		event := parserEvent(e, pd)
 		if event != nil {
 			er.events = append(er.events, event)
 		}
@@ -45,7 +45,7 @@ func NewEventRunner(events []cases.EventDescriptor, raftEngine *RaftEngine) *Eve
 	return er
 }
 
-func parserEvent(e cases.EventDescriptor) Event {
+func parserEvent(e cases.EventDescriptor, pd *PDCluster) Event {
 	switch t := e.(type) {
 	case *cases.WriteFlowOnSpotDescriptor:
 		return &WriteFlowOnSpot{descriptor: t}
@@ -57,6 +57,30 @@ func parserEvent(e cases.EventDescriptor) Event {
 		return &AddNodes{descriptor: t}
 	case *cases.DeleteNodesDescriptor:
 		return &DeleteNodes{descriptor: t}
+	case *cases.RemoveFailedStoresDescriptor:
+		return &RemoveFailedStores{descriptor: t}
+	case *cases.WriteFlowOnRangeDescriptor:
+		return &WriteFlowOnRange{descriptor: t}
+	case *cases.ReadFlowOnRangeDescriptor:
+		return &ReadFlowOnRange{descriptor: t}
+	case *cases.StoresDownDescriptor:
+		return &StoresDown{descriptor: t}
+	case *cases.StoresUpDescriptor:
+		return &StoresUp{descriptor: t}
+	case *cases.StoresOfflineDescriptor:
+		return &StoresOffline{descriptor: t}
+	case *cases.ChangeLabelsDescriptor:
+		return &ChangeLabels{descriptor: t}
+	case *cases.KillPDDescriptor:
+		return &KillPD{descriptor: t, pd: pd}
+	case *cases.RestartPDDescriptor:
+		return &RestartPD{descriptor: t, pd: pd}
+	case *cases.PartitionPDDescriptor:
+		return &PartitionPD{descriptor: t, pd: pd}
+	case *cases.HealPDDescriptor:
+		return &HealPD{descriptor: t, pd: pd}
+	case *cases.DelayHeartbeatsDescriptor:
+		return &DelayHeartbeats{descriptor: t}
 	}
 	return nil
 }
@@ -143,14 +167,16 @@ func (e *AddNodes) Run(raft *RaftEngine, tickCount int64) bool {
 	}
 
 	config := raft.storeConfig
-	s := &cases.Store{
-		ID:        id,
-		Status:    metapb.StoreState_Up,
-		Capacity:  config.StoreCapacityGB * cases.GB,
-		Available: config.StoreAvailableGB * cases.GB,
-		Version:   config.StoreVersion,
-	}
-	n, err := NewNode(s, raft.conn.pdAddr, config.StoreIOMBPerSecond)
+	var s *cases.Store
+	if e.descriptor.Store != nil {
+		s = e.descriptor.Store(id)
+	} else {
+		s = &cases.Store{
+			ID:     id,
+			Status: metapb.StoreState_Up,
+		}
+	}
+	n, err := NewNode(config.adjustStore(s), raft.conn.pdAddr, s.IORate)
 	if err != nil {
 		simutil.Logger.Error("add node failed", zap.Uint64("node-id", id), zap.Error(err))
 		return false
@@ -183,18 +209,278 @@ func (e *DeleteNodes) Run(raft *RaftEngine, tickCount int64) bool {
 	}
 	delete(raft.conn.Nodes, id)
 	node.Stop()
+	raft.markDownPeers(id)
+	return false
+}
+
+// RemoveFailedStores removes the failed stores with unsafe recovery.
+type RemoveFailedStores struct {
+	descriptor *cases.RemoveFailedStoresDescriptor
+}
+
+// Run implements the event interface.
+func (e *RemoveFailedStores) Run(raft *RaftEngine, tickCount int64) bool {
+	ids := e.descriptor.Step(tickCount)
+	if len(ids) == 0 {
+		return false
+	}
+	for _, node := range raft.conn.Nodes {
+		if err := node.client.RemoveFailedStores(context.Background(), ids); err != nil {
+			simutil.Logger.Error("remove failed stores failed", zap.Uint64s("store-ids", ids), zap.Error(err))
+			return false
+		}
+		simutil.Logger.Info("remove failed stores", zap.Uint64s("store-ids", ids))
+		return true
+	}
+	simutil.Logger.Error("no node to remove failed stores", zap.Uint64s("store-ids", ids))
+	return false
+}
+
+// WriteFlowOnRange writes bytes evenly in the regions of a key range.
+type WriteFlowOnRange struct {
+	descriptor *cases.WriteFlowOnRangeDescriptor
+}
 
-	regions := raft.GetRegions()
+// Run implements the event interface.
+func (e *WriteFlowOnRange) Run(raft *RaftEngine, tickCount int64) bool {
+	bytes := e.descriptor.Step(tickCount)
+	regions := raft.ScanRange(e.descriptor.StartKey, e.descriptor.EndKey)
+	if bytes == 0 || len(regions) == 0 {
+		return false
+	}
+	for _, region := range regions {
+		raft.updateRegionStore(region, bytes/int64(len(regions)))
+	}
+	return false
+}
+
+// ReadFlowOnRange reads bytes evenly in the regions of a key range.
+type ReadFlowOnRange struct {
+	descriptor *cases.ReadFlowOnRangeDescriptor
+}
+
+// Run implements the event interface.
+func (e *ReadFlowOnRange) Run(raft *RaftEngine, tickCount int64) bool {
+	bytes := e.descriptor.Step(tickCount)
+	regions := raft.ScanRange(e.descriptor.StartKey, e.descriptor.EndKey)
+	if bytes == 0 || len(regions) == 0 {
+		return false
+	}
+	readBytes := make(map[uint64]int64, len(regions))
 	for _, region := range regions {
-		storeIDs := region.GetStoreIds()
-		if _, ok := storeIDs[id]; ok {
-			downPeer := &pdpb.PeerStats{
-				Peer:        region.GetStorePeer(id),
-				DownSeconds: 24 * 60 * 60,
-			}
-			region = region.Clone(core.WithDownPeers(append(region.GetDownPeers(), downPeer)))
-			raft.SetRegion(region)
+		readBytes[region.GetID()] = bytes / int64(len(regions))
+	}
+	raft.updateRegionReadBytes(readBytes)
+	return false
+}
+
+// StoresDown makes stores down.
+type StoresDown struct {
+	descriptor *cases.StoresDownDescriptor
+}
+
+// Run implements the event interface.
+func (e *StoresDown) Run(raft *RaftEngine, tickCount int64) bool {
+	ids := e.descriptor.Step(tickCount)
+	for _, id := range ids {
+		node := raft.conn.Nodes[id]
+		if node == nil {
+			simutil.Logger.Error("node is not existed", zap.Uint64("node-id", id))
+			continue
+		}
+		node.SetDown()
+		raft.markDownPeers(id)
+		simutil.Logger.Info("node is down", zap.Uint64("node-id", id))
+	}
+	return false
+}
+
+// StoresUp recovers the down stores.
+type StoresUp struct {
+	descriptor *cases.StoresUpDescriptor
+}
+
+// Run implements the event interface.
+func (e *StoresUp) Run(raft *RaftEngine, tickCount int64) bool {
+	ids := e.descriptor.Step(tickCount)
+	for _, id := range ids {
+		node := raft.conn.Nodes[id]
+		if node == nil {
+			simutil.Logger.Error("node is not existed", zap.Uint64("node-id", id))
+			continue
+		}
+		node.SetUp()
+		raft.clearDownPeers(id)
+		simutil.Logger.Info("node is up", zap.Uint64("node-id", id))
+	}
+	return false
+}
+
+// StoresOffline makes stores offline.
+type StoresOffline struct {
+	descriptor *cases.StoresOfflineDescriptor
+}
+
+// Run implements the event interface.
+func (e *StoresOffline) Run(raft *RaftEngine, tickCount int64) bool {
+	ids := e.descriptor.Step(tickCount)
+	for _, id := range ids {
+		node := raft.conn.Nodes[id]
+		if node == nil {
+			simutil.Logger.Error("node is not existed", zap.Uint64("node-id", id))
+			continue
+		}
+		if err := node.SetOffline(); err != nil {
+			simutil.Logger.Error("make node offline failed", zap.Uint64("node-id", id), zap.Error(err))
+			continue
+		}
+		simutil.Logger.Info("node is offline", zap.Uint64("node-id", id))
+	}
+	return false
+}
+
+// ChangeLabels changes the labels of stores.
+type ChangeLabels struct {
+	descriptor *cases.ChangeLabelsDescriptor
+}
+
+// Run implements the event interface.
+func (e *ChangeLabels) Run(raft *RaftEngine, tickCount int64) bool {
+	labels := e.descriptor.Step(tickCount)
+	for id, storeLabels := range labels {
+		node := raft.conn.Nodes[id]
+		if node == nil {
+			simutil.Logger.Error("node is not existed", zap.Uint64("node-id", id))
+			continue
+		}
+		if err := node.SetLabels(storeLabels); err != nil {
+			simutil.Logger.Error("change labels failed", zap.Uint64("node-id", id), zap.Error(err))
+			continue
+		}
+		simutil.Logger.Info("node labels changed", zap.Uint64("node-id", id), zap.Reflect("labels", storeLabels))
+	}
+	return false
+}
+
+// KillPD kills a PD member.
+type KillPD struct {
+	descriptor *cases.KillPDDescriptor
+	pd         *PDCluster
+}
+
+// Run implements the event interface.
+func (e *KillPD) Run(raft *RaftEngine, tickCount int64) bool {
+	if !e.descriptor.Step(tickCount) {
+		return false
+	}
+	if e.pd == nil {
+		simutil.Logger.Error("no pd cluster to kill")
+		return false
+	}
+	name, isLeader, lost, err := e.pd.Kill(e.descriptor.Member)
+	if err != nil {
+		simutil.Logger.Error("kill pd failed", zap.String("member", e.descriptor.Member), zap.Error(err))
+		return false
+	}
+	if isLeader {
+		raft.schedulerStats.pdStats.fault(tickCount, lost)
+	}
+	simutil.Logger.Info("pd is killed", zap.String("member", name), zap.Int("lost-operators", lost))
+	return false
+}
+
+// RestartPD restarts the killed PD members.
+type RestartPD struct {
+	descriptor *cases.RestartPDDescriptor
+	pd         *PDCluster
+}
+
+// Run implements the event interface.
+func (e *RestartPD) Run(raft *RaftEngine, tickCount int64) bool {
+	if !e.descriptor.Step(tickCount) {
+		return false
+	}
+	if e.pd == nil {
+		simutil.Logger.Error("no pd cluster to restart")
+		return false
+	}
+	names := e.pd.Restart(e.descriptor.Member)
+	simutil.Logger.Info("pd is restarting", zap.Strings("members", names))
+	return false
+}
+
+// PartitionPD isolates a PD member from the other members.
+type PartitionPD struct {
+	descriptor *cases.PartitionPDDescriptor
+	pd         *PDCluster
+}
+
+// Run implements the event interface.
+func (e *PartitionPD) Run(raft *RaftEngine, tickCount int64) bool {
+	if !e.descriptor.Step(tickCount) {
+		return false
+	}
+	if e.pd == nil {
+		simutil.Logger.Error("no pd cluster to partition")
+		return false
+	}
+	name, isLeader, lost, err := e.pd.Isolate(e.descriptor.Member)
+	if err != nil {
+		simutil.Logger.Error("partition pd failed", zap.String("member", e.descriptor.Member), zap.Error(err))
+		return false
+	}
+	if isLeader {
+		raft.schedulerStats.pdStats.fault(tickCount, lost)
+	}
+	simutil.Logger.Info("pd is isolated", zap.String("member", name), zap.Int("lost-operators", lost))
+	return false
+}
+
+// HealPD recovers the network partitions of PD members.
+type HealPD struct {
+	descriptor *cases.HealPDDescriptor
+	pd         *PDCluster
+}
+
+// Run implements the event interface.
+func (e *HealPD) Run(raft *RaftEngine, tickCount int64) bool {
+	if !e.descriptor.Step(tickCount) {
+		return false
+	}
+	if e.pd == nil {
+		simutil.Logger.Error("no pd cluster to heal")
+		return false
+	}
+	e.pd.Heal()
+	simutil.Logger.Info("pd partitions are healed")
+	return false
+}
+
+// DelayHeartbeats delays the heartbeats of stores.
+type DelayHeartbeats struct {
+	descriptor *cases.DelayHeartbeatsDescriptor
+}
+
+// Run implements the event interface.
+func (e *DelayHeartbeats) Run(raft *RaftEngine, tickCount int64) bool {
+	delay, ok := e.descriptor.Step(tickCount)
+	if !ok {
+		return false
+	}
+	ids := e.descriptor.StoreIDs
+	if len(ids) == 0 {
+		for id := range raft.conn.Nodes {
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range ids {
+		node := raft.conn.Nodes[id]
+		if node == nil {
+			simutil.Logger.Error("node is not existed", zap.Uint64("node-id", id))
+			continue
 		}
+		node.SetHeartbeatDelay(delay)
 	}
+	simutil.Logger.Info("heartbeats are delayed", zap.Uint64s("node-ids", ids), zap.Duration("delay", delay))
 	return false
 }
@@ -50,6 +50,9 @@ type Node struct {
 	raftEngine               *RaftEngine
 	ioRate                   int64
 	sizeMutex                sync.Mutex
+	// down is set when the store is down, a down store stops working but
+	// keeps its peers.
+	down bool
 }
 
 // NewNode returns a Node.
@@ -110,6 +113,7 @@ func (n *Node) receiveRegionHeartbeat() {
 		case resp := <-n.receiveRegionHeartbeatCh:
 			task := responseToTask(resp, n.raftEngine)
 			if task != nil {
+				n.raftEngine.schedulerStats.pdStats.incCommand()
 				n.AddTask(task)
 			}
 		case <-n.ctx.Done():
@@ -121,7 +125,7 @@ func (n *Node) receiveRegionHeartbeat() {
 // Tick steps node status change.
 func (n *Node) Tick(wg *sync.WaitGroup) {
 	defer wg.Done()
-	if n.GetState() != metapb.StoreState_Up {
+	if n.GetState() == metapb.StoreState_Tombstone || n.IsDown() {
 		return
 	}
 	n.stepHeartBeat()
@@ -135,6 +139,52 @@ func (n *Node) GetState() metapb.StoreState {
 	return n.Store.State
 }
 
+// SetDown makes the node down, it stops heartbeats and tasks.
+func (n *Node) SetDown() {
+	n.Lock()
+	defer n.Unlock()
+	n.down = true
+}
+
+// IsDown returns true if the node is down.
+func (n *Node) IsDown() bool {
+	n.RLock()
+	defer n.RUnlock()
+	return n.down
+}
+
+// SetUp recovers the down node.
+func (n *Node) SetUp() {
+	n.Lock()
+	defer n.Unlock()
+	n.down = false
+}
+
+// SetOffline asks PD to make the store offline. The offline node keeps
+// working until PD sets it to tombstone.
+func (n *Node) SetOffline() error {
+	ctx, cancel := context.WithTimeout(n.ctx, pdTimeout)
+	defer cancel()
+	if err := n.client.DeleteStore(ctx, n.GetId()); err != nil {
+		return err
+	}
+	n.Store.State = metapb.StoreState_Offline
+	return nil
+}
+
+// SetHeartbeatDelay delays the heartbeats of the node, zero means no delay.
+func (n *Node) SetHeartbeatDelay(delay time.Duration) {
+	n.client.SetHeartbeatDelay(delay)
+}
+
+// SetLabels sets the labels of the node and puts the store to PD.
+func (n *Node) SetLabels(labels []*metapb.StoreLabel) error {
+	n.Store.Labels = labels
+	ctx, cancel := context.WithTimeout(n.ctx, pdTimeout)
+	defer cancel()
+	return n.client.PutStore(ctx, n.Store)
+}
+
 func (n *Node) stepTask() {
 	n.Lock()
 	defer n.Unlock()
@@ -166,7 +216,8 @@ func (n *Node) stepCompaction() {
 }
 
 func (n *Node) storeHeartBeat() {
-	if n.GetState() != metapb.StoreState_Up {
+	n.refreshState()
+	if n.GetState() == metapb.StoreState_Tombstone {
 		return
 	}
 	ctx, cancel := context.WithTimeout(n.ctx, pdTimeout)
@@ -177,6 +228,40 @@ func (n *Node) storeHeartBeat() {
 			zap.Error(err))
 	}
 	cancel()
+	n.applyRecoveryPlan()
+}
+
+// refreshState syncs the store state with PD, the node stops working after
+// the store becomes tombstone.
+func (n *Node) refreshState() {
+	store, err := n.client.GetStore(n.ctx, n.GetId())
+	if err != nil {
+		simutil.Logger.Info("get store error",
+			zap.Uint64("node-id", n.GetId()),
+			zap.Error(err))
+		return
+	}
+	if state := store.GetState(); state != n.GetState() {
+		simutil.Logger.Info("node state changed",
+			zap.Uint64("node-id", n.GetId()),
+			zap.Stringer("old-state", n.GetState()),
+			zap.Stringer("new-state", state))
+		n.Store.State = state
+	}
+}
+
+// applyRecoveryPlan fetches the unsafe recovery plan of the store and applies it.
+func (n *Node) applyRecoveryPlan() {
+	plan, err := n.client.GetStoreRecoveryPlan(n.ctx, n.GetId())
+	if err != nil {
+		simutil.Logger.Info("get recovery plan error",
+			zap.Uint64("node-id", n.GetId()),
+			zap.Error(err))
+		return
+	}
+	if plan != nil {
+		n.raftEngine.ApplyRecoveryPlan(plan)
+	}
 }
 
 func (n *Node) compaction() {
@@ -188,7 +273,7 @@ func (n *Node) compaction() {
 }
 
 func (n *Node) regionHeartBeat() {
-	if n.GetState() != metapb.StoreState_Up {
+	if n.GetState() == metapb.StoreState_Tombstone {
 		return
 	}
 	regions := n.raftEngine.GetRegions()
@@ -258,3 +343,23 @@ func (n *Node) decUsedSize(size uint64) {
 	defer n.sizeMutex.Unlock()
 	n.stats.ToCompactionSize += size
 }
+
+func (n *Node) incSnapshotCount(kind string) {
+	n.sizeMutex.Lock()
+	defer n.sizeMutex.Unlock()
+	if kind == "sending" {
+		n.stats.SendingSnapCount++
+	} else {
+		n.stats.ReceivingSnapCount++
+	}
+}
+
+func (n *Node) decSnapshotCount(kind string) {
+	n.sizeMutex.Lock()
+	defer n.sizeMutex.Unlock()
+	if kind == "sending" {
+		n.stats.SendingSnapCount--
+	} else {
+		n.stats.ReceivingSnapCount--
+	}
+}
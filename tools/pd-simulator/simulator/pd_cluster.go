@@ -0,0 +1,447 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	stdlog "log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/logutil"
+	"github.com/tikv/pd/pkg/tempurl"
+	"github.com/tikv/pd/server"
+	"github.com/tikv/pd/server/api"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/tools/pd-simulator/simulator/simutil"
+	"go.uber.org/zap"
+)
+
+const (
+	pdStartTimeout = 30 * time.Second
+	// syncCheckPeriod is the ticks to check the regions synced to the followers.
+	syncCheckPeriod = 10
+	// leaderMember means the current leader in the PD events.
+	leaderMember = "leader"
+)
+
+// PDCluster runs the PD members inside the simulator. The members can be
+// killed, restarted and isolated from the others to simulate PD failures.
+type PDCluster struct {
+	sync.RWMutex
+	ctx       context.Context
+	cancel    context.CancelFunc
+	configs   []*config.Config
+	servers   []*server.Server // nil if the member is not running
+	proxies   []*peerProxy
+	partition *networkPartition
+	leader    string
+}
+
+// NewPDCluster creates a PD cluster with count members by the server
+// configuration of the simulator.
+func NewPDCluster(simConfig *SimConfig, count int) (*PDCluster, error) {
+	base := simConfig.ServerConfig
+	if err := base.SetupLogger(); err != nil {
+		return nil, err
+	}
+	log.ReplaceGlobals(base.GetZapLogger(), base.GetZapLogProperties())
+	if err := logutil.InitLogger(&base.Log); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &PDCluster{
+		ctx:       ctx,
+		cancel:    cancel,
+		servers:   make([]*server.Server, count),
+		partition: newNetworkPartition(),
+	}
+	initialCluster := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		cfg := base.Clone()
+		if i > 0 {
+			cfg.ClientUrls, cfg.PeerUrls = tempurl.Alloc(), tempurl.Alloc()
+			cfg.AdvertiseClientUrls = cfg.ClientUrls
+			dataDir, err := os.MkdirTemp("/tmp", "test_pd")
+			if err != nil {
+				c.Stop()
+				return nil, errors.WithStack(err)
+			}
+			cfg.DataDir = dataDir
+		}
+		cfg.AdvertisePeerUrls = cfg.PeerUrls
+		// The peers talk to each other through the proxies, so the members can
+		// be isolated by the proxies.
+		if count > 1 {
+			cfg.Name = fmt.Sprintf("%s-%d", base.Name, i+1)
+			proxy, err := newPeerProxy(cfg.Name, cfg.PeerUrls, c.partition)
+			if err != nil {
+				c.Stop()
+				return nil, err
+			}
+			c.proxies = append(c.proxies, proxy)
+			cfg.AdvertisePeerUrls = proxy.url
+		}
+		c.configs = append(c.configs, cfg)
+		initialCluster = append(initialCluster, fmt.Sprintf("%s=%s", cfg.Name, cfg.AdvertisePeerUrls))
+	}
+	for _, cfg := range c.configs {
+		cfg.InitialCluster = strings.Join(initialCluster, ",")
+	}
+	return c, nil
+}
+
+// Start starts all the members and waits for the leader.
+func (c *PDCluster) Start() error {
+	errCh := make(chan error, len(c.configs))
+	for i := range c.configs {
+		go func(i int) {
+			errCh <- c.startMember(i)
+		}(i)
+	}
+	for range c.configs {
+		if err := <-errCh; err != nil {
+			return err
+		}
+	}
+	timeout := time.After(pdStartTimeout)
+	for c.GetLeader() == nil {
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-timeout:
+			return errors.New("no pd leader is elected")
+		}
+	}
+	return nil
+}
+
+func (c *PDCluster) startMember(i int) error {
+	s, err := server.CreateServer(c.ctx, c.configs[i], api.NewHandler)
+	if err != nil {
+		return err
+	}
+	if err = s.Run(); err != nil {
+		return err
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.servers[i] = s
+	c.partition.setMemberID(s.Name(), s.GetMember().ID())
+	return nil
+}
+
+// ClientURLs returns the comma separated client URLs of the members.
+func (c *PDCluster) ClientURLs() string {
+	urls := make([]string, 0, len(c.configs))
+	for _, cfg := range c.configs {
+		urls = append(urls, cfg.AdvertiseClientUrls)
+	}
+	return strings.Join(urls, ",")
+}
+
+// GetLeader returns the running leader, the isolated member is not preferred
+// because it is going to lose the leadership.
+func (c *PDCluster) GetLeader() *server.Server {
+	c.RLock()
+	defer c.RUnlock()
+	var leader *server.Server
+	for _, s := range c.servers {
+		if s == nil || s.IsClosed() || !s.GetMember().IsLeader() {
+			continue
+		}
+		if leader == nil || c.partition.isIsolated(leader.Name()) {
+			leader = s
+		}
+	}
+	return leader
+}
+
+// memberIndex returns the index of the member, the leader is used if the
+// name is "leader" or empty.
+func (c *PDCluster) memberIndex(name string) (int, error) {
+	if name == "" || name == leaderMember {
+		leader := c.GetLeader()
+		if leader == nil {
+			return 0, errors.New("no pd leader")
+		}
+		name = leader.Name()
+	}
+	for i, cfg := range c.configs {
+		if cfg.Name == name {
+			return i, nil
+		}
+	}
+	return 0, errors.Errorf("pd member %s not found", name)
+}
+
+// Kill stops the member. It returns whether the member is the leader and
+// the count of the operators lost with it.
+func (c *PDCluster) Kill(name string) (string, bool, int, error) {
+	i, err := c.memberIndex(name)
+	if err != nil {
+		return "", false, 0, err
+	}
+	leader := c.GetLeader()
+	c.Lock()
+	s := c.servers[i]
+	c.servers[i] = nil
+	c.Unlock()
+	if s == nil {
+		return c.configs[i].Name, false, 0, errors.Errorf("pd member %s is not running", c.configs[i].Name)
+	}
+	lost := operatorCount(s)
+	s.Close()
+	return s.Name(), s == leader, lost, nil
+}
+
+// Restart starts the killed members in the background, all the killed
+// members are started if the name is empty.
+func (c *PDCluster) Restart(name string) []string {
+	c.RLock()
+	var restarted []string
+	for i, s := range c.servers {
+		if s != nil || (name != "" && c.configs[i].Name != name) {
+			continue
+		}
+		restarted = append(restarted, c.configs[i].Name)
+		go func(i int) {
+			if err := c.startMember(i); err != nil {
+				simutil.Logger.Error("restart pd member failed", zap.String("member", c.configs[i].Name), zap.Error(err))
+			}
+		}(i)
+	}
+	c.RUnlock()
+	return restarted
+}
+
+// Isolate isolates the member from the other members. It returns whether
+// the member is the leader and the count of the operators lost with it.
+func (c *PDCluster) Isolate(name string) (string, bool, int, error) {
+	i, err := c.memberIndex(name)
+	if err != nil {
+		return "", false, 0, err
+	}
+	c.RLock()
+	s := c.servers[i]
+	c.RUnlock()
+	var lost int
+	isLeader := s != nil && s == c.GetLeader()
+	if isLeader {
+		lost = operatorCount(s)
+	}
+	c.partition.isolate(c.configs[i].Name)
+	return c.configs[i].Name, isLeader, lost, nil
+}
+
+// Heal recovers the network partitions.
+func (c *PDCluster) Heal() {
+	c.partition.heal()
+}
+
+// tick records the leader changes and the regions not synced to the followers.
+func (c *PDCluster) tick(tickCount int64, stats *pdStatistics) {
+	leader := c.GetLeader()
+	if leader == nil {
+		stats.observeLeader(tickCount, false, true)
+		return
+	}
+	stats.observeLeader(tickCount, c.leader != "" && c.leader != leader.Name(), false)
+	c.leader = leader.Name()
+	if tickCount%syncCheckPeriod != 0 {
+		return
+	}
+	regions := leader.GetBasicCluster().GetRegions()
+	c.RLock()
+	defer c.RUnlock()
+	for _, s := range c.servers {
+		if s == nil || s == leader || s.IsClosed() {
+			continue
+		}
+		stats.observeSyncLag(s.Name(), staleRegionCount(regions, s.GetBasicCluster()))
+	}
+}
+
+// Stop stops all the members and removes the data.
+func (c *PDCluster) Stop() {
+	c.Lock()
+	defer c.Unlock()
+	for i, s := range c.servers {
+		if s != nil {
+			s.Close()
+			c.servers[i] = nil
+		}
+	}
+	for _, proxy := range c.proxies {
+		proxy.close()
+	}
+	c.cancel()
+	for _, cfg := range c.configs {
+		os.RemoveAll(cfg.DataDir)
+	}
+}
+
+func operatorCount(s *server.Server) int {
+	rc := s.GetRaftCluster()
+	if rc == nil {
+		return 0
+	}
+	return len(rc.GetOperatorController().GetOperators())
+}
+
+// staleRegionCount counts the regions of the leader which are missing or
+// stale on the follower.
+func staleRegionCount(regions []*core.RegionInfo, follower *core.BasicCluster) int {
+	var stale int
+	for _, region := range regions {
+		origin := follower.GetRegion(region.GetID())
+		if origin == nil ||
+			origin.GetRegionEpoch().GetVersion() < region.GetRegionEpoch().GetVersion() ||
+			origin.GetRegionEpoch().GetConfVer() < region.GetRegionEpoch().GetConfVer() ||
+			origin.GetLeader().GetStoreId() != region.GetLeader().GetStoreId() {
+			stale++
+		}
+	}
+	return stale
+}
+
+// networkPartition records the isolated members. The members are identified
+// by the sender ID in the etcd peer requests.
+type networkPartition struct {
+	sync.RWMutex
+	names    map[string]string
+	isolated map[string]struct{}
+	// changed is closed when the partition changes, the connections are
+	// broken to apply the new partition.
+	changed chan struct{}
+}
+
+func newNetworkPartition() *networkPartition {
+	return &networkPartition{
+		names:    make(map[string]string),
+		isolated: make(map[string]struct{}),
+		changed:  make(chan struct{}),
+	}
+}
+
+func (p *networkPartition) setMemberID(name string, id uint64) {
+	p.Lock()
+	defer p.Unlock()
+	// It is the same as the ID string in the etcd peer requests.
+	p.names[fmt.Sprintf("%x", id)] = name
+}
+
+func (p *networkPartition) isolate(name string) {
+	p.Lock()
+	defer p.Unlock()
+	p.isolated[name] = struct{}{}
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+func (p *networkPartition) heal() {
+	p.Lock()
+	defer p.Unlock()
+	p.isolated = make(map[string]struct{})
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+func (p *networkPartition) isIsolated(name string) bool {
+	p.RLock()
+	defer p.RUnlock()
+	_, ok := p.isolated[name]
+	return ok
+}
+
+// check returns true if the request from the member with fromID to the
+// target member is blocked.
+func (p *networkPartition) check(target, fromID string) (<-chan struct{}, bool) {
+	p.RLock()
+	defer p.RUnlock()
+	_, targetIsolated := p.isolated[target]
+	_, fromIsolated := p.isolated[p.names[fromID]]
+	return p.changed, targetIsolated || fromIsolated
+}
+
+// peerProxy forwards the etcd peer requests to a PD member, it rejects the
+// requests from or to the isolated members.
+type peerProxy struct {
+	member    string
+	url       string
+	server    *http.Server
+	proxy     *httputil.ReverseProxy
+	partition *networkPartition
+}
+
+func newPeerProxy(member, target string, partition *networkPartition) (*peerProxy, error) {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	addr := tempurl.Alloc()
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	l, err := net.Listen("tcp", u.Host)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	p := &peerProxy{
+		member:    member,
+		url:       addr,
+		proxy:     httputil.NewSingleHostReverseProxy(targetURL),
+		partition: partition,
+	}
+	// The raft messages are streamed, so they are flushed immediately.
+	p.proxy.FlushInterval = -1
+	p.proxy.ErrorLog = stdlog.New(io.Discard, "", 0)
+	p.server = &http.Server{Handler: p}
+	go p.server.Serve(l)
+	return p, nil
+}
+
+func (p *peerProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	changed, blocked := p.partition.check(p.member, r.Header.Get("X-Server-From"))
+	if blocked {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-changed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	p.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func (p *peerProxy) close() {
+	p.server.Close()
+}
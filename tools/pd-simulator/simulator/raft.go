@@ -14,11 +14,14 @@
 package simulator
 
 import (
+	"bytes"
 	"context"
 	"sync"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/tikv/pd/server/cluster"
 	"github.com/tikv/pd/server/core"
 	"github.com/tikv/pd/tools/pd-simulator/simulator/cases"
 	"github.com/tikv/pd/tools/pd-simulator/simulator/simutil"
@@ -50,10 +53,14 @@ func NewRaftEngine(conf *cases.Case, conn *Connection, storeConfig *SimConfig) *
 		storeConfig:     storeConfig,
 	}
 	var splitKeys []string
-	if conf.TableNumber > 0 {
+	useRegionKeys := hasRegionKeys(conf.Regions)
+	switch {
+	case useRegionKeys:
+		// The regions are initialized with their own key ranges.
+	case conf.TableNumber > 0:
 		splitKeys = simutil.GenerateTableKeys(conf.TableNumber, len(conf.Regions)-1)
 		r.useTiDBEncodedKey = true
-	} else {
+	default:
 		splitKeys = simutil.GenerateKeys(len(conf.Regions) - 1)
 	}
 
@@ -63,11 +70,15 @@ func NewRaftEngine(conf *cases.Case, conn *Connection, storeConfig *SimConfig) *
 			Peers:       region.Peers,
 			RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 1},
 		}
-		if i > 0 {
-			meta.StartKey = []byte(splitKeys[i-1])
-		}
-		if i < len(conf.Regions)-1 {
-			meta.EndKey = []byte(splitKeys[i])
+		if useRegionKeys {
+			meta.StartKey, meta.EndKey = region.StartKey, region.EndKey
+		} else {
+			if i > 0 {
+				meta.StartKey = []byte(splitKeys[i-1])
+			}
+			if i < len(conf.Regions)-1 {
+				meta.EndKey = []byte(splitKeys[i])
+			}
 		}
 		regionInfo := core.NewRegionInfo(
 			meta,
@@ -90,6 +101,15 @@ func NewRaftEngine(conf *cases.Case, conn *Connection, storeConfig *SimConfig) *
 	return r
 }
 
+func hasRegionKeys(regions []cases.Region) bool {
+	for _, region := range regions {
+		if len(region.StartKey) > 0 || len(region.EndKey) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *RaftEngine) stepRegions() {
 	regions := r.GetRegions()
 	for _, region := range regions {
@@ -124,18 +144,22 @@ func (r *RaftEngine) stepSplit(region *core.RegionInfo) {
 	if !r.NeedSplit(region.GetApproximateSize(), region.GetApproximateKeys()) {
 		return
 	}
-	var err error
-	ids := make([]uint64, 1+len(region.GetPeers()))
-	for i := range ids {
-		ids[i], err = r.allocID(region.GetLeader().GetStoreId())
-		if err != nil {
-			simutil.Logger.Error("alloc id failed", zap.Error(err))
-			return
-		}
+	splitKey := r.generateSplitKey(region)
+	if splitKey == nil {
+		simutil.Logger.Debug("region can not be split", zap.Uint64("region-id", region.GetID()))
+		return
+	}
+	if _, err := r.splitRegion(region, [][]byte{splitKey}); err != nil {
+		simutil.Logger.Error("split region failed", zap.Uint64("region-id", region.GetID()), zap.Error(err))
 	}
+}
 
+// generateSplitKey returns a key in the middle of the region, it returns nil
+// if the region can not be split.
+func (r *RaftEngine) generateSplitKey(region *core.RegionInfo) []byte {
 	var splitKey []byte
 	if r.useTiDBEncodedKey {
+		var err error
 		splitKey, err = simutil.GenerateTiDBEncodedSplitKey(region.GetStartKey(), region.GetEndKey())
 		if err != nil {
 			simutil.Logger.Fatal("generate TiDB encoded split key failed", zap.Error(err))
@@ -143,32 +167,60 @@ func (r *RaftEngine) stepSplit(region *core.RegionInfo) {
 	} else {
 		splitKey = simutil.GenerateSplitKey(region.GetStartKey(), region.GetEndKey())
 	}
-	left := region.Clone(
-		core.WithNewRegionID(ids[len(ids)-1]),
-		core.WithNewPeerIds(ids[0:len(ids)-1]...),
-		core.WithIncVersion(),
-		core.SetApproximateKeys(region.GetApproximateKeys()/2),
-		core.SetApproximateSize(region.GetApproximateSize()/2),
-		core.WithPendingPeers(nil),
-		core.WithDownPeers(nil),
-		core.WithEndKey(splitKey),
-	)
-	right := region.Clone(
-		core.WithIncVersion(),
-		core.SetApproximateKeys(region.GetApproximateKeys()/2),
-		core.SetApproximateSize(region.GetApproximateSize()/2),
-		core.WithStartKey(splitKey),
-	)
+	if bytes.Compare(splitKey, region.GetStartKey()) <= 0 {
+		return nil
+	}
+	return splitKey
+}
+
+// splitRegion splits the region by the sorted split keys. Like TiKV, the
+// origin region keeps the last range and the others are created with new
+// IDs. The size and keys are divided evenly by the new regions.
+func (r *RaftEngine) splitRegion(region *core.RegionInfo, splitKeys [][]byte) ([]*core.RegionInfo, error) {
+	count := int64(len(splitKeys) + 1)
+	newRegions := make([]*core.RegionInfo, 0, count)
+	startKey := region.GetStartKey()
+	for _, splitKey := range splitKeys {
+		ids := make([]uint64, 1+len(region.GetPeers()))
+		for i := range ids {
+			var err error
+			ids[i], err = r.allocID(region.GetLeader().GetStoreId())
+			if err != nil {
+				return nil, err
+			}
+		}
+		newRegions = append(newRegions, region.Clone(
+			core.WithNewRegionID(ids[len(ids)-1]),
+			core.WithNewPeerIds(ids[0:len(ids)-1]...),
+			core.SetRegionVersion(region.GetRegionEpoch().GetVersion()+uint64(len(splitKeys))),
+			core.SetApproximateKeys(region.GetApproximateKeys()/count),
+			core.SetApproximateSize(region.GetApproximateSize()/count),
+			core.WithPendingPeers(nil),
+			core.WithDownPeers(nil),
+			core.WithStartKey(startKey),
+			core.WithEndKey(splitKey),
+		))
+		startKey = splitKey
+	}
+	newRegions = append(newRegions, region.Clone(
+		core.SetRegionVersion(region.GetRegionEpoch().GetVersion()+uint64(len(splitKeys))),
+		core.SetApproximateKeys(region.GetApproximateKeys()/count),
+		core.SetApproximateSize(region.GetApproximateSize()/count),
+		core.WithStartKey(startKey),
+	))
 
-	r.SetRegion(right)
-	r.SetRegion(left)
+	// The origin region is updated first to avoid overlapping with the new ones.
+	for i := len(newRegions) - 1; i >= 0; i-- {
+		r.SetRegion(newRegions[i])
+	}
 	simutil.Logger.Debug("region split",
 		zap.Uint64("region-id", region.GetID()),
 		zap.Reflect("origin", region.GetMeta()),
-		zap.Reflect("left", left.GetMeta()),
-		zap.Reflect("right", right.GetMeta()))
-	r.recordRegionChange(left)
-	r.recordRegionChange(right)
+		zap.Int("count", len(newRegions)))
+	for _, newRegion := range newRegions {
+		r.recordRegionChange(newRegion)
+	}
+	return newRegions, nil
 }
 
 // NeedSplit checks whether the region needs to split according its size
@@ -214,28 +266,94 @@ func (r *RaftEngine) updateRegionReadBytes(readBytes map[uint64]int64) {
 	}
 }
 
+// markDownPeers marks the peers on the store as down peers.
+func (r *RaftEngine) markDownPeers(storeID uint64) {
+	for _, region := range r.GetRegions() {
+		peer := region.GetStorePeer(storeID)
+		if peer == nil {
+			continue
+		}
+		downPeer := &pdpb.PeerStats{
+			Peer:        peer,
+			DownSeconds: 24 * 60 * 60,
+		}
+		r.SetRegion(region.Clone(core.WithDownPeers(append(region.GetDownPeers(), downPeer))))
+	}
+}
+
+// clearDownPeers clears the down peers on the store after it recovers.
+func (r *RaftEngine) clearDownPeers(storeID uint64) {
+	for _, region := range r.GetRegions() {
+		if region.GetDownPeer(region.GetStorePeer(storeID).GetId()) == nil {
+			continue
+		}
+		var downPeers []*pdpb.PeerStats
+		for _, downPeer := range region.GetDownPeers() {
+			if downPeer.GetPeer().GetStoreId() != storeID {
+				downPeers = append(downPeers, downPeer)
+			}
+		}
+		r.SetRegion(region.Clone(core.WithDownPeers(downPeers)))
+	}
+}
+
 func (r *RaftEngine) electNewLeader(region *core.RegionInfo) *metapb.Peer {
 	var (
-		unhealthy        int
-		newLeaderStoreID uint64
+		voters, unhealthy int
+		newLeader         *metapb.Peer
 	)
-	ids := region.GetStoreIds()
-	for id := range ids {
-		if r.conn.nodeHealth(id) {
-			newLeaderStoreID = id
-		} else {
+	// The learners can't vote. The demoting voters still vote in the joint
+	// state, but they can't become the leader.
+	for _, peer := range region.GetPeers() {
+		if core.IsLearner(peer) {
+			continue
+		}
+		voters++
+		if !r.conn.nodeHealth(peer.GetStoreId()) {
 			unhealthy++
+			continue
+		}
+		if core.IsVoterOrIncomingVoter(peer) {
+			newLeader = peer
 		}
 	}
-	if unhealthy > len(ids)/2 {
+	if unhealthy > voters/2 {
 		return nil
 	}
-	for _, peer := range region.GetPeers() {
-		if peer.GetStoreId() == newLeaderStoreID {
-			return peer
+	return newLeader
+}
+
+// ApplyRecoveryPlan applies the unsafe recovery plan, the updated regions elect new
+// leaders in the next step and the created regions are reported immediately.
+func (r *RaftEngine) ApplyRecoveryPlan(plan *cluster.StoreRecoveryPlan) {
+	for _, meta := range plan.Updates {
+		region := r.GetRegion(meta.GetId())
+		if region == nil || region.GetRegionEpoch().GetConfVer() >= meta.GetRegionEpoch().GetConfVer() {
+			continue
+		}
+		newRegion := region.Clone(
+			core.SetPeers(meta.GetPeers()),
+			core.SetRegionConfVer(meta.GetRegionEpoch().GetConfVer()),
+			core.WithLeader(nil),
+			core.WithDownPeers(nil),
+			core.WithPendingPeers(nil),
+		)
+		r.SetRegion(newRegion)
+		simutil.Logger.Info("region recovered by removing failed peers",
+			zap.Uint64("region-id", region.GetID()),
+			zap.Reflect("peers", meta.GetPeers()))
+	}
+	for _, meta := range plan.Creates {
+		if r.GetRegion(meta.GetId()) != nil {
+			continue
 		}
+		region := core.NewRegionInfo(meta, meta.GetPeers()[0])
+		r.SetRegion(region)
+		r.recordRegionChange(region)
+		simutil.Logger.Info("empty region created for recovery",
+			zap.Uint64("region-id", region.GetID()),
+			zap.Uint64("store-id", meta.GetPeers()[0].GetStoreId()))
 	}
-	return nil
 }
 
 // GetRegion returns the RegionInfo with regionID.
@@ -286,6 +404,13 @@ func (r *RaftEngine) SearchRegion(regionKey []byte) *core.RegionInfo {
 	return r.regionsInfo.SearchRegion(regionKey)
 }
 
+// ScanRange scans the regions in the key range.
+func (r *RaftEngine) ScanRange(startKey, endKey []byte) []*core.RegionInfo {
+	r.RLock()
+	defer r.RUnlock()
+	return r.regionsInfo.ScanRange(startKey, endKey, 0)
+}
+
 // BootstrapRegion gets a region to construct bootstrap info.
 func (r *RaftEngine) BootstrapRegion() *core.RegionInfo {
 	r.RLock()
@@ -297,6 +422,15 @@ func (r *RaftEngine) BootstrapRegion() *core.RegionInfo {
 	return nil
 }
 
+// storeIORate returns the IO rate of the store, it is used to decide how
+// long it takes to handle the data of a region.
+func (r *RaftEngine) storeIORate(storeID uint64) int64 {
+	if node, ok := r.conn.Nodes[storeID]; ok && node.ioRate > 0 {
+		return node.ioRate
+	}
+	return r.storeConfig.StoreIOMBPerSecond * cases.MB
+}
+
 func (r *RaftEngine) allocID(storeID uint64) (uint64, error) {
 	node, ok := r.conn.Nodes[storeID]
 	if !ok {
@@ -0,0 +1,474 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bytes"
+	"embed"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-echarts/go-echarts/charts"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/server/core"
+	"github.com/tikv/pd/tools/pd-simulator/simulator/cases"
+)
+
+// Report is the time series recorded by the simulator, one item per tick.
+type Report struct {
+	Name  string      `json:"name"`
+	Ticks []TickStats `json:"ticks"`
+	// SchedulerConfigs is the tick when each scheduler config item is set.
+	SchedulerConfigs map[string]int64 `json:"scheduler-configs,omitempty"`
+	// PendingSchedulerConfigs are the scheduler config items which are not
+	// set when the case finishes, the result is invalid if there are any.
+	PendingSchedulerConfigs []string `json:"pending-scheduler-configs,omitempty"`
+}
+
+// TickStats is the cluster status at the end of a tick.
+type TickStats struct {
+	Tick   int64            `json:"tick"`
+	Stores []StoreTickStats `json:"stores"`
+	// Operators is the cumulative count of the finished tasks by kind.
+	Operators map[string]int `json:"operators"`
+}
+
+// StoreTickStats is the status of a store at the end of a tick.
+type StoreTickStats struct {
+	StoreID uint64 `json:"store-id"`
+	// Up is false if the store is down, offline or tombstone.
+	Up          bool    `json:"up"`
+	LeaderCount int     `json:"leader-count"`
+	RegionCount int     `json:"region-count"`
+	LeaderScore float64 `json:"leader-score"`
+	RegionScore float64 `json:"region-score"`
+	// WrittenBytes and ReadBytes are the flow of the leaders on the store
+	// reported in the last region heartbeat.
+	WrittenBytes uint64 `json:"written-bytes"`
+	ReadBytes    uint64 `json:"read-bytes"`
+	// PendingPeers is the count of the peers which are receiving snapshots.
+	PendingPeers uint32 `json:"pending-peers"`
+}
+
+// NewReport creates an empty report.
+func NewReport(name string) *Report {
+	return &Report{Name: name}
+}
+
+// record appends the status of the cluster at the end of the tick.
+func (r *Report) record(tickCount int64, raft *RaftEngine, nodes map[uint64]*Node) {
+	opt := raft.storeConfig.ServerConfig.Schedule
+	policy := core.StringToSchedulePolicy(opt.LeaderSchedulePolicy)
+	written := make(map[uint64]uint64)
+	read := make(map[uint64]uint64)
+	for _, region := range raft.GetRegions() {
+		storeID := region.GetLeader().GetStoreId()
+		written[storeID] += region.GetBytesWritten()
+		read[storeID] += region.GetBytesRead()
+	}
+
+	stats := TickStats{
+		Tick:      tickCount,
+		Operators: make(map[string]int),
+	}
+	for kind, count := range raft.schedulerStats.taskStats.getStatistics() {
+		stats.Operators[strings.TrimSuffix(kind, " (task)")] = count
+	}
+	for id, n := range nodes {
+		regions := raft.regionsInfo
+		store := core.NewStoreInfo(n.Store,
+			core.SetStoreStats(&n.stats.StoreStats),
+			core.SetLeaderCount(regions.GetStoreLeaderCount(id)),
+			core.SetLeaderSize(regions.GetStoreLeaderRegionSize(id)/cases.MB),
+			core.SetRegionCount(regions.GetStoreRegionCount(id)),
+			core.SetRegionSize(regions.GetStoreRegionSize(id)/cases.MB),
+		)
+		stats.Stores = append(stats.Stores, StoreTickStats{
+			StoreID:      id,
+			Up:           n.GetState() == metapb.StoreState_Up && !n.IsDown(),
+			LeaderCount:  store.GetLeaderCount(),
+			RegionCount:  store.GetRegionCount(),
+			LeaderScore:  store.LeaderScore(policy, 0),
+			RegionScore:  store.RegionScore(opt.RegionScoreFormulaVersion, opt.HighSpaceRatio, opt.LowSpaceRatio, 0),
+			WrittenBytes: written[id],
+			ReadBytes:    read[id],
+			PendingPeers: n.stats.GetReceivingSnapCount(),
+		})
+	}
+	sort.Slice(stats.Stores, func(i, j int) bool { return stats.Stores[i].StoreID < stats.Stores[j].StoreID })
+	r.Ticks = append(r.Ticks, stats)
+}
+
+// LoadReport loads the report written by WriteJSON.
+func LoadReport(path string) (*Report, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	r := &Report{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, errors.Annotatef(err, "failed to parse report %s", path)
+	}
+	return r, nil
+}
+
+// WriteFiles writes the report to dir as <name>.json, <name>-stores.csv,
+// <name>-operators.csv and <name>.html.
+func (r *Report) WriteFiles(dir string, assets ReportAssets) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	base := filepath.Join(dir, r.Name)
+	writers := []struct {
+		path  string
+		write func(io.Writer) error
+	}{
+		{base + ".json", r.WriteJSON},
+		{base + "-stores.csv", r.WriteStoresCSV},
+		{base + "-operators.csv", r.WriteOperatorsCSV},
+		{base + ".html", func(w io.Writer) error { return RenderReport(w, assets, r) }},
+	}
+	for _, w := range writers {
+		if err := writeFile(w.path, w.write); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err = write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return errors.WithStack(f.Close())
+}
+
+// WriteJSON writes the report as JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	return errors.WithStack(json.NewEncoder(w).Encode(r))
+}
+
+// WriteStoresCSV writes the per-store series as CSV, one row per store and tick.
+func (r *Report) WriteStoresCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"tick", "store-id", "up", "leader-count", "region-count", "leader-score", "region-score", "written-bytes", "read-bytes", "pending-peers"})
+	for _, t := range r.Ticks {
+		for _, s := range t.Stores {
+			cw.Write([]string{
+				strconv.FormatInt(t.Tick, 10),
+				strconv.FormatUint(s.StoreID, 10),
+				strconv.FormatBool(s.Up),
+				strconv.Itoa(s.LeaderCount),
+				strconv.Itoa(s.RegionCount),
+				strconv.FormatFloat(s.LeaderScore, 'f', -1, 64),
+				strconv.FormatFloat(s.RegionScore, 'f', -1, 64),
+				strconv.FormatUint(s.WrittenBytes, 10),
+				strconv.FormatUint(s.ReadBytes, 10),
+				strconv.FormatUint(uint64(s.PendingPeers), 10),
+			})
+		}
+	}
+	cw.Flush()
+	return errors.WithStack(cw.Error())
+}
+
+// WriteOperatorsCSV writes the cumulative operator counts as CSV, one column
+// per kind.
+func (r *Report) WriteOperatorsCSV(w io.Writer) error {
+	kinds := r.operatorKinds()
+	cw := csv.NewWriter(w)
+	cw.Write(append([]string{"tick"}, kinds...))
+	for _, t := range r.Ticks {
+		row := []string{strconv.FormatInt(t.Tick, 10)}
+		for _, kind := range kinds {
+			row = append(row, strconv.Itoa(t.Operators[kind]))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return errors.WithStack(cw.Error())
+}
+
+func (r *Report) operatorKinds() []string {
+	set := make(map[string]struct{})
+	for _, t := range r.Ticks {
+		for kind := range t.Operators {
+			set[kind] = struct{}{}
+		}
+	}
+	kinds := make([]string, 0, len(set))
+	for kind := range set {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func (r *Report) storeIDs() []uint64 {
+	set := make(map[uint64]struct{})
+	for _, t := range r.Ticks {
+		for _, s := range t.Stores {
+			set[s.StoreID] = struct{}{}
+		}
+	}
+	ids := make([]uint64, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (r *Report) tickAxis() []int64 {
+	ticks := make([]int64, 0, len(r.Ticks))
+	for _, t := range r.Ticks {
+		ticks = append(ticks, t.Tick)
+	}
+	return ticks
+}
+
+// storeSeries returns the value of every store per tick, the value is nil
+// before the store is added.
+func (r *Report) storeSeries(value func(StoreTickStats) float64) map[uint64][]interface{} {
+	series := make(map[uint64][]interface{})
+	for _, id := range r.storeIDs() {
+		series[id] = make([]interface{}, len(r.Ticks))
+	}
+	for i, t := range r.Ticks {
+		for _, s := range t.Stores {
+			series[s.StoreID][i] = value(s)
+		}
+	}
+	return series
+}
+
+// Spread returns the difference between the max and min value of the stores
+// per tick.
+func (r *Report) Spread(value func(StoreTickStats) float64) []float64 {
+	spread := make([]float64, 0, len(r.Ticks))
+	for _, t := range r.Ticks {
+		var min, max float64
+		for i, s := range t.Stores {
+			v := value(s)
+			if i == 0 || v < min {
+				min = v
+			}
+			if i == 0 || v > max {
+				max = v
+			}
+		}
+		spread = append(spread, max-min)
+	}
+	return spread
+}
+
+// TotalOperators returns the cumulative count of all operators per tick.
+func (r *Report) TotalOperators() []int {
+	total := make([]int, 0, len(r.Ticks))
+	for _, t := range r.Ticks {
+		var sum int
+		for _, count := range t.Operators {
+			sum += count
+		}
+		total = append(total, sum)
+	}
+	return total
+}
+
+var (
+	regionCountValue  = func(s StoreTickStats) float64 { return float64(s.RegionCount) }
+	leaderCountValue  = func(s StoreTickStats) float64 { return float64(s.LeaderCount) }
+	regionScoreValue  = func(s StoreTickStats) float64 { return s.RegionScore }
+	leaderScoreValue  = func(s StoreTickStats) float64 { return s.LeaderScore }
+	writtenBytesValue = func(s StoreTickStats) float64 { return float64(s.WrittenBytes) / cases.MB }
+	readBytesValue    = func(s StoreTickStats) float64 { return float64(s.ReadBytes) / cases.MB }
+	pendingPeersValue = func(s StoreTickStats) float64 { return float64(s.PendingPeers) }
+)
+
+// defaultAssetsHost is the host of go-echarts to load the ECharts assets, the
+// asset URLs of the rendered page are prefixed with it.
+const defaultAssetsHost = "https://go-echarts.github.io/go-echarts-assets/assets/"
+
+// embeddedFS holds the ECharts assets vendored in the assets directory, see its
+// README.md for how to update them.
+//
+//go:embed assets
+var embeddedFS embed.FS
+
+// embeddedAssets is the assets directory in embeddedFS, tests may replace it.
+var embeddedAssets, _ = fs.Sub(embeddedFS, "assets")
+
+// ReportAssets decides where the HTML report gets the ECharts assets. They are
+// inlined from the embedded assets by default, so the report is self-contained.
+type ReportAssets struct {
+	// Dir is a local directory to inline the assets from instead of the embedded
+	// ones, such as a copy of the assets directory of go-echarts-assets.
+	Dir string
+	// Host is the URL prefix to load the assets remotely if Dir is empty, the
+	// assets are not inlined then.
+	Host string
+}
+
+func (a ReportAssets) host() string {
+	if a.Dir == "" && a.Host != "" {
+		return a.Host
+	}
+	return defaultAssetsHost
+}
+
+func (a ReportAssets) source() (fs.FS, string) {
+	if a.Dir != "" {
+		return os.DirFS(a.Dir), a.Dir
+	}
+	return embeddedAssets, "the embedded assets"
+}
+
+var (
+	scriptTagRegexp = regexp.MustCompile(`<script src="([^"]*)"></script>`)
+	linkTagRegexp   = regexp.MustCompile(`<link href="([^"]*)" rel="stylesheet">`)
+)
+
+// inline replaces the script and stylesheet tags of the page with the assets.
+func (a ReportAssets) inline(page []byte) ([]byte, error) {
+	var err error
+	assets, from := a.source()
+	replace := func(tagRegexp *regexp.Regexp, open, name string) func([]byte) []byte {
+		return func(tag []byte) []byte {
+			src := string(tagRegexp.FindSubmatch(tag)[1])
+			data, e := fs.ReadFile(assets, strings.TrimPrefix(src, defaultAssetsHost))
+			if e != nil {
+				if err == nil {
+					err = errors.Annotatef(e, "failed to inline %s from %s", src, from)
+				}
+				return tag
+			}
+			// The asset can't end the tag early.
+			data = bytes.ReplaceAll(data, []byte("</"+name), []byte(`<\/`+name))
+			return append(append([]byte(open), data...), "</"+name+">"...)
+		}
+	}
+	page = scriptTagRegexp.ReplaceAllFunc(page, replace(scriptTagRegexp, `<script type="text/javascript">`, "script"))
+	page = linkTagRegexp.ReplaceAllFunc(page, replace(linkTagRegexp, "<style>", "style"))
+	return page, err
+}
+
+// RenderReport renders the reports as an HTML page. The charts of every run
+// come first, the comparison charts are added if there are more than one
+// report. See ReportAssets for the ECharts assets.
+func RenderReport(w io.Writer, assets ReportAssets, reports ...*Report) error {
+	assetsHost := assets.host()
+	page := charts.NewPage()
+	page.PageTitle = "PD Simulator Report"
+	for _, r := range reports {
+		page.Add(
+			r.storeChart("Region Count", "region", regionCountValue, assetsHost),
+			r.storeChart("Leader Count", "leader", leaderCountValue, assetsHost),
+			r.storeChart("Region Score", "score", regionScoreValue, assetsHost),
+			r.storeChart("Leader Score", "score", leaderScoreValue, assetsHost),
+			r.storeChart("Written Flow", "MB", writtenBytesValue, assetsHost),
+			r.storeChart("Read Flow", "MB", readBytesValue, assetsHost),
+			r.storeChart("Pending Peers", "peer", pendingPeersValue, assetsHost),
+			r.operatorChart(assetsHost),
+		)
+	}
+	if len(reports) > 1 {
+		page.Add(
+			compareChart("Region Count Spread", "region", reports, assetsHost, func(r *Report) interface{} { return r.Spread(regionCountValue) }),
+			compareChart("Leader Count Spread", "leader", reports, assetsHost, func(r *Report) interface{} { return r.Spread(leaderCountValue) }),
+			compareChart("Region Score Spread", "score", reports, assetsHost, func(r *Report) interface{} { return r.Spread(regionScoreValue) }),
+			compareChart("Operators", "operator", reports, assetsHost, func(r *Report) interface{} { return r.TotalOperators() }),
+		)
+	}
+	if assets.Dir == "" && assets.Host != "" {
+		return errors.WithStack(page.Render(w))
+	}
+	var buf bytes.Buffer
+	if err := page.Render(&buf); err != nil {
+		return errors.WithStack(err)
+	}
+	data, err := assets.inline(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return errors.WithStack(err)
+}
+
+func newLineChart(title, unit, assetsHost string) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.TitleOpts{Title: title},
+		charts.InitOpts{Width: "1200px", Height: "400px", AssetsHost: assetsHost},
+		charts.TooltipOpts{Show: true, Trigger: "axis"},
+		charts.LegendOpts{Right: "5%"},
+		charts.YAxisOpts{Name: unit},
+		charts.DataZoomOpts{Type: "slider"},
+	)
+	return line
+}
+
+func (r *Report) storeChart(title, unit string, value func(StoreTickStats) float64, assetsHost string) *charts.Line {
+	line := newLineChart(fmt.Sprintf("%s (%s)", title, r.Name), unit, assetsHost)
+	line.AddXAxis(r.tickAxis())
+	series := r.storeSeries(value)
+	for _, id := range r.storeIDs() {
+		line.AddYAxis(fmt.Sprintf("store %d", id), series[id])
+	}
+	return line
+}
+
+func (r *Report) operatorChart(assetsHost string) *charts.Line {
+	line := newLineChart(fmt.Sprintf("Operators (%s)", r.Name), "operator", assetsHost)
+	line.AddXAxis(r.tickAxis())
+	for _, kind := range r.operatorKinds() {
+		counts := make([]int, 0, len(r.Ticks))
+		for _, t := range r.Ticks {
+			counts = append(counts, t.Operators[kind])
+		}
+		line.AddYAxis(kind, counts)
+	}
+	return line
+}
+
+// compareChart draws one series per report, the x axis is the longest run.
+func compareChart(title, unit string, reports []*Report, assetsHost string, series func(*Report) interface{}) *charts.Line {
+	line := newLineChart(title, unit, assetsHost)
+	var longest *Report
+	for _, r := range reports {
+		if longest == nil || len(r.Ticks) > len(longest.Ticks) {
+			longest = r
+		}
+	}
+	line.AddXAxis(longest.tickAxis())
+	for _, r := range reports {
+		line.AddYAxis(r.Name, series(r))
+	}
+	return line
+}
@@ -0,0 +1,142 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing/fstest"
+
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&testReportSuite{})
+
+type testReportSuite struct{}
+
+func newTestReport() *Report {
+	return &Report{
+		Name: "test",
+		Ticks: []TickStats{
+			{
+				Tick: 1,
+				Stores: []StoreTickStats{
+					{StoreID: 1, Up: true, LeaderCount: 2, RegionCount: 3, LeaderScore: 2, RegionScore: 3.5, WrittenBytes: 1024, ReadBytes: 512, PendingPeers: 1},
+					{StoreID: 2, Up: false, LeaderCount: 1, RegionCount: 3, LeaderScore: 1, RegionScore: 3.25},
+				},
+				Operators: map[string]int{},
+			},
+			{
+				Tick: 2,
+				Stores: []StoreTickStats{
+					{StoreID: 1, Up: true, LeaderCount: 1, RegionCount: 3, LeaderScore: 1, RegionScore: 3.5, WrittenBytes: 2048},
+					{StoreID: 2, Up: true, LeaderCount: 2, RegionCount: 3, LeaderScore: 2, RegionScore: 3.25, ReadBytes: 256},
+				},
+				Operators: map[string]int{"transfer-leader": 1, "add-peer": 2},
+			},
+		},
+		SchedulerConfigs: map[string]int64{"balance-hot-region-scheduler.src-tolerance-ratio": 2},
+	}
+}
+
+func readCSV(c *C, path string) [][]string {
+	f, err := os.Open(path)
+	c.Assert(err, IsNil)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	c.Assert(err, IsNil)
+	return records
+}
+
+func (s *testReportSuite) TestRoundTrip(c *C) {
+	dir := c.MkDir()
+	report := newTestReport()
+	c.Assert(report.WriteFiles(dir, ReportAssets{Host: defaultAssetsHost}), IsNil)
+
+	loaded, err := LoadReport(filepath.Join(dir, "test.json"))
+	c.Assert(err, IsNil)
+	c.Assert(loaded, DeepEquals, report)
+
+	// The stores CSV has one row per store and tick.
+	records := readCSV(c, filepath.Join(dir, "test-stores.csv"))
+	c.Assert(records[0], DeepEquals, []string{"tick", "store-id", "up", "leader-count", "region-count", "leader-score", "region-score", "written-bytes", "read-bytes", "pending-peers"})
+	c.Assert(records[1:], HasLen, 4)
+	for i, record := range records[1:] {
+		tick := report.Ticks[i/2]
+		store := tick.Stores[i%2]
+		c.Assert(record[0], Equals, strconv.FormatInt(tick.Tick, 10))
+		up, err := strconv.ParseBool(record[2])
+		c.Assert(err, IsNil)
+		regionScore, err := strconv.ParseFloat(record[6], 64)
+		c.Assert(err, IsNil)
+		written, err := strconv.ParseUint(record[7], 10, 64)
+		c.Assert(err, IsNil)
+		pending, err := strconv.ParseUint(record[9], 10, 32)
+		c.Assert(err, IsNil)
+		c.Assert(record[1], Equals, strconv.FormatUint(store.StoreID, 10))
+		c.Assert(up, Equals, store.Up)
+		c.Assert(regionScore, Equals, store.RegionScore)
+		c.Assert(written, Equals, store.WrittenBytes)
+		c.Assert(uint32(pending), Equals, store.PendingPeers)
+	}
+
+	// The operators CSV has one column per kind, the missing counts are 0.
+	records = readCSV(c, filepath.Join(dir, "test-operators.csv"))
+	c.Assert(records, DeepEquals, [][]string{
+		{"tick", "add-peer", "transfer-leader"},
+		{"1", "0", "0"},
+		{"2", "2", "1"},
+	})
+
+	// The loaded reports can be rendered and compared.
+	var buf bytes.Buffer
+	c.Assert(RenderReport(&buf, ReportAssets{Host: defaultAssetsHost}, report, loaded), IsNil)
+	c.Assert(bytes.Contains(buf.Bytes(), []byte(defaultAssetsHost+"echarts.min.js")), IsTrue)
+}
+
+func (s *testReportSuite) TestInlineAssets(c *C) {
+	assets := fstest.MapFS{
+		"echarts.min.js": &fstest.MapFile{Data: []byte("var echarts = {}; // </script>")},
+		"bulma.min.css":  &fstest.MapFile{Data: []byte("body {}")},
+	}
+	defer func(embedded fs.FS) { embeddedAssets = embedded }(embeddedAssets)
+	embeddedAssets = assets
+
+	// The embedded assets are inlined by default.
+	var buf bytes.Buffer
+	c.Assert(RenderReport(&buf, ReportAssets{}, newTestReport()), IsNil)
+	page := buf.Bytes()
+	c.Assert(bytes.Contains(page, []byte("<script src=")), IsFalse)
+	c.Assert(bytes.Contains(page, []byte("<link href=")), IsFalse)
+	c.Assert(bytes.Contains(page, []byte(`var echarts = {}; // <\/script>`)), IsTrue)
+	c.Assert(bytes.Contains(page, []byte("<style>body {}</style>")), IsTrue)
+
+	// The assets in the directory are inlined instead.
+	dir := c.MkDir()
+	for name, file := range assets {
+		c.Assert(os.WriteFile(filepath.Join(dir, name), append([]byte("/* local */"), file.Data...), 0644), IsNil)
+	}
+	buf.Reset()
+	c.Assert(RenderReport(&buf, ReportAssets{Dir: dir, Host: defaultAssetsHost}, newTestReport()), IsNil)
+	c.Assert(bytes.Contains(buf.Bytes(), []byte("<style>/* local */body {}</style>")), IsTrue)
+
+	// It fails if the assets are missing.
+	c.Assert(RenderReport(&buf, ReportAssets{Dir: c.MkDir()}, newTestReport()), NotNil)
+	embeddedAssets = fstest.MapFS{}
+	c.Assert(RenderReport(&buf, ReportAssets{}, newTestReport()), NotNil)
+}
@@ -75,7 +75,7 @@ func GenerateTableKeys(tableCount, size int) []string {
 
 // GenerateSplitKey generate the split key.
 func GenerateSplitKey(start, end []byte) []byte {
-	key := make([]byte, 0, len(start))
+	key := make([]byte, 0, len(start)+1)
 	// lessThanEnd is set as true when the key is already less than end key.
 	lessThanEnd := len(end) == 0
 	for i, s := range start {
@@ -83,18 +83,33 @@ func GenerateSplitKey(start, end []byte) []byte {
 		if !lessThanEnd {
 			e = end[i]
 		}
-		c := (s + e) / 2
+		// s = e when the key is equal to end so far, or s >= 'z' when it is
+		// already less than end. Keep the byte of start and continue.
+		if s >= e {
+			key = append(key, s)
+			continue
+		}
+		c := byte((int(s) + int(e)) / 2)
 		key = append(key, c)
-		// case1: s = c < e. Continue with lessThanEnd=true.
-		// case2: s < c < e. return key.
-		// case3: s = c = e. Continue with lessThanEnd=false.
-		lessThanEnd = c < e
-		if c > s && c < e {
+		// case1: s < c < e. return key.
+		// case2: s = c < e. Continue with lessThanEnd=true.
+		if c > s {
 			return key
 		}
+		lessThanEnd = true
+	}
+	if lessThanEnd || end[len(start)] > ('a'+'z')/2 {
+		return append(key, ('a'+'z')/2)
+	}
+	// start is a prefix of end, the key should be less than the rest of end.
+	// The key is equal to start if there is no key between start and end.
+	rest := end[len(start):]
+	for i, e := range rest {
+		if e > 0 {
+			return append(append(key, rest[:i]...), e-1, ('a'+'z')/2)
+		}
 	}
-	key = append(key, ('a'+'z')/2)
-	return key
+	return append(key, rest[:len(rest)-1]...)
 }
 
 func mustDecodeMvccKey(key []byte) ([]byte, error) {
@@ -49,6 +49,40 @@ func (t *testTableKeySuite) TestGenerateTableKeys(c *C) {
 
 }
 
+func (t *testTableKeySuite) TestGenerateRawSplitKey(c *C) {
+	testCases := []struct {
+		start, end string
+	}{
+		{"", ""},
+		{"abc", ""},
+		{"", "abc"},
+		{"abc", "abd"},
+		{"ab", "abz"},
+		{"t1", "t10000000001"},
+		{"t1", "t1\x00\x00\x01"},
+		{"t1", "t1\x01"},
+		{"zzz", ""},
+		{"\xff\xfe", ""},
+		{"\xf0", "\xff"},
+		{"a0000000059", "t1"},
+	}
+	for _, t := range testCases {
+		s, e := []byte(t.start), []byte(t.end)
+		for i := 0; i < 20; i++ {
+			key := GenerateSplitKey(s, e)
+			c.Assert(s, Less, key, Commentf("start %q end %q", s, e))
+			if len(e) > 0 {
+				c.Assert(key, Less, e, Commentf("start %q end %q", s, e))
+			}
+			if i%2 == 0 {
+				s = key
+			} else {
+				e = key
+			}
+		}
+	}
+}
+
 func (t *testTableKeySuite) TestGenerateSplitKey(c *C) {
 	s := []byte(codec.EncodeBytes([]byte("a")))
 	e := []byte(codec.EncodeBytes([]byte("ab")))
@@ -17,6 +17,7 @@ import (
 	"fmt"
 	"math"
 	"sync"
+	"sync/atomic"
 )
 
 type taskStatistics struct {
@@ -25,8 +26,11 @@ type taskStatistics struct {
 	removePeer     map[uint64]int
 	addLearner     map[uint64]int
 	promoteLeaner  map[uint64]int
+	demoteVoter    map[uint64]int
+	jointConsensus map[uint64]int
 	transferLeader map[uint64]map[uint64]int
 	mergeRegion    int
+	splitRegion    int
 }
 
 func newTaskStatistics() *taskStatistics {
@@ -35,6 +39,8 @@ func newTaskStatistics() *taskStatistics {
 		removePeer:     make(map[uint64]int),
 		addLearner:     make(map[uint64]int),
 		promoteLeaner:  make(map[uint64]int),
+		demoteVoter:    make(map[uint64]int),
+		jointConsensus: make(map[uint64]int),
 		transferLeader: make(map[uint64]map[uint64]int),
 	}
 }
@@ -47,6 +53,8 @@ func (t *taskStatistics) getStatistics() map[string]int {
 	removePeer := getSum(t.removePeer)
 	addLearner := getSum(t.addLearner)
 	promoteLeaner := getSum(t.promoteLeaner)
+	demoteVoter := getSum(t.demoteVoter)
+	jointConsensus := getSum(t.jointConsensus)
 
 	var transferLeader int
 	for _, to := range t.transferLeader {
@@ -59,8 +67,11 @@ func (t *taskStatistics) getStatistics() map[string]int {
 	stats["Remove Peer (task)"] = removePeer
 	stats["Add Learner (task)"] = addLearner
 	stats["Promote Learner (task)"] = promoteLeaner
+	stats["Demote Voter (task)"] = demoteVoter
+	stats["Joint Consensus (task)"] = jointConsensus
 	stats["Transfer Leader (task)"] = transferLeader
 	stats["Merge Region (task)"] = t.mergeRegion
+	stats["Split Region (task)"] = t.splitRegion
 
 	return stats
 }
@@ -83,6 +94,18 @@ func (t *taskStatistics) incPromoteLeaner(regionID uint64) {
 	t.promoteLeaner[regionID]++
 }
 
+func (t *taskStatistics) incDemoteVoter(regionID uint64) {
+	t.Lock()
+	defer t.Unlock()
+	t.demoteVoter[regionID]++
+}
+
+func (t *taskStatistics) incJointConsensus(regionID uint64) {
+	t.Lock()
+	defer t.Unlock()
+	t.jointConsensus[regionID]++
+}
+
 func (t *taskStatistics) incRemovePeer(regionID uint64) {
 	t.Lock()
 	defer t.Unlock()
@@ -95,6 +118,12 @@ func (t *taskStatistics) incMergeRegion() {
 	t.mergeRegion++
 }
 
+func (t *taskStatistics) incSplitRegion() {
+	t.Lock()
+	defer t.Unlock()
+	t.splitRegion++
+}
+
 func (t *taskStatistics) incTransferLeader(fromPeerID, toPeerID uint64) {
 	t.Lock()
 	defer t.Unlock()
@@ -121,15 +150,110 @@ func newSnapshotStatistics() *snapshotStatistics {
 	}
 }
 
+// pdStatistics records the continuity of scheduling when PD fails.
+type pdStatistics struct {
+	sync.RWMutex
+	// commands is the count of the commands received in the current tick.
+	commands        int64
+	lastCommandTick int64
+	// maxIdleTicks is the max ticks between two ticks receiving commands.
+	maxIdleTicks int64
+	// faultTick is the tick of the last unrecovered failure of the PD leader.
+	faultTick        int64
+	maxRecoveryTicks int64
+	leaderChangeTick int64
+	leaderChanges    int
+	noLeaderTicks    int64
+	lostOperators    int
+	// maxSyncLag is the max count of the regions that are not synced to each follower.
+	maxSyncLag map[string]int
+}
+
+func newPDStatistics() *pdStatistics {
+	return &pdStatistics{maxSyncLag: make(map[string]int)}
+}
+
+func (s *pdStatistics) incCommand() {
+	atomic.AddInt64(&s.commands, 1)
+}
+
+// tick checks whether any command is received in the tick.
+func (s *pdStatistics) tick(tickCount int64) {
+	if atomic.SwapInt64(&s.commands, 0) == 0 {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.lastCommandTick > 0 && tickCount-s.lastCommandTick > s.maxIdleTicks {
+		s.maxIdleTicks = tickCount - s.lastCommandTick
+	}
+	s.lastCommandTick = tickCount
+	// The failure is recovered when the new leader sends commands, the old
+	// leader may still send commands after it is isolated.
+	if s.faultTick > 0 && s.leaderChangeTick > s.faultTick {
+		if tickCount-s.faultTick > s.maxRecoveryTicks {
+			s.maxRecoveryTicks = tickCount - s.faultTick
+		}
+		s.faultTick = 0
+	}
+}
+
+// fault records a failure of the PD leader and the operators lost with it.
+func (s *pdStatistics) fault(tickCount int64, lostOperators int) {
+	s.Lock()
+	defer s.Unlock()
+	if s.faultTick == 0 {
+		s.faultTick = tickCount
+	}
+	s.lostOperators += lostOperators
+}
+
+func (s *pdStatistics) observeLeader(tickCount int64, changed, noLeader bool) {
+	s.Lock()
+	defer s.Unlock()
+	if changed {
+		s.leaderChanges++
+		s.leaderChangeTick = tickCount
+	}
+	if noLeader {
+		s.noLeaderTicks++
+	}
+}
+
+func (s *pdStatistics) observeSyncLag(member string, lag int) {
+	s.Lock()
+	defer s.Unlock()
+	if lag > s.maxSyncLag[member] {
+		s.maxSyncLag[member] = lag
+	}
+}
+
+func (s *pdStatistics) getStatistics() map[string]int {
+	s.RLock()
+	defer s.RUnlock()
+	stats := make(map[string]int)
+	stats["Max Time Without Scheduling (tick)"] = int(s.maxIdleTicks)
+	stats["Max Recovery After PD Failure (tick)"] = int(s.maxRecoveryTicks)
+	stats["Leader Change (pd)"] = s.leaderChanges
+	stats["No Leader (tick)"] = int(s.noLeaderTicks)
+	stats["Operator Loss (pd)"] = s.lostOperators
+	for member, lag := range s.maxSyncLag {
+		stats[fmt.Sprintf("Max Region Sync Lag of %s (region)", member)] = lag
+	}
+	return stats
+}
+
 type schedulerStatistics struct {
 	taskStats     *taskStatistics
 	snapshotStats *snapshotStatistics
+	pdStats       *pdStatistics
 }
 
 func newSchedulerStatistics() *schedulerStatistics {
 	return &schedulerStatistics{
 		taskStats:     newTaskStatistics(),
 		snapshotStats: newSnapshotStatistics(),
+		pdStats:       newPDStatistics(),
 	}
 }
 
@@ -170,12 +294,16 @@ func (s *snapshotStatistics) incReceiveSnapshot(storeID uint64) {
 func (s *schedulerStatistics) PrintStatistics() {
 	task := s.taskStats.getStatistics()
 	snap := s.snapshotStats.getStatistics()
+	pd := s.pdStats.getStatistics()
 	for t, count := range task {
 		fmt.Println(t, count)
 	}
 	for s, count := range snap {
 		fmt.Println(s, count)
 	}
+	for p, count := range pd {
+		fmt.Println(p, count)
+	}
 }
 
 func getMax(m map[uint64]int) int {
@@ -0,0 +1,273 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pingcap/errors"
+	"github.com/tikv/pd/pkg/typeutil"
+	"github.com/tikv/pd/tools/pd-simulator/simulator/cases"
+)
+
+const (
+	defaultSweepParallel = 2
+	defaultSweepTimeout  = 30 * time.Minute
+)
+
+// SweepConfig is the configuration of a sweep, the case runs under every
+// combination of the config items in the matrix.
+type SweepConfig struct {
+	Case string `toml:"case"`
+	// Parallel is the count of the simulator processes running at the same time.
+	Parallel int `toml:"parallel"`
+	// Timeout is the max time of a run, the run is stopped and regarded as
+	// unfinished after it.
+	Timeout typeutil.Duration `toml:"timeout"`
+	// Matrix is the values of the config items, the keys are in the form of
+	// the -configItems flag.
+	Matrix  map[string][]interface{} `toml:"matrix"`
+	Weights SweepWeights             `toml:"weights"`
+}
+
+// SweepWeights is the weights of the metrics when ranking the runs.
+type SweepWeights struct {
+	Convergence float64 `toml:"convergence"`
+	Operators   float64 `toml:"operators"`
+	Balance     float64 `toml:"balance"`
+}
+
+// LoadSweep loads the sweep file, the scenario file of the case is relative
+// to the sweep file.
+func LoadSweep(path string) (*SweepConfig, error) {
+	cfg := &SweepConfig{}
+	meta, err := toml.DecodeFile(path, cfg)
+	if err != nil {
+		return nil, errors.Annotatef(err, "failed to decode sweep %s", path)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return nil, errors.Errorf("unknown items %v in sweep %s", undecoded, path)
+	}
+	if cfg.Case == "" {
+		return nil, errors.Errorf("no case in sweep %s", path)
+	}
+	if len(cfg.Matrix) == 0 {
+		return nil, errors.Errorf("no matrix in sweep %s", path)
+	}
+	for key, values := range cfg.Matrix {
+		if len(values) == 0 {
+			return nil, errors.Errorf("no value of %s in sweep %s", key, path)
+		}
+	}
+	if cases.IsScenarioFile(cfg.Case) && !filepath.IsAbs(cfg.Case) {
+		cfg.Case = filepath.Join(filepath.Dir(path), cfg.Case)
+	}
+	if cfg.Parallel <= 0 {
+		cfg.Parallel = defaultSweepParallel
+	}
+	adjustDuration(&cfg.Timeout, defaultSweepTimeout)
+	if cfg.Weights == (SweepWeights{}) {
+		cfg.Weights = SweepWeights{Convergence: 1, Operators: 1, Balance: 1}
+	}
+	return cfg, nil
+}
+
+// Combinations returns every combination of the config items in the matrix.
+func (c *SweepConfig) Combinations() []map[string]interface{} {
+	keys := make([]string, 0, len(c.Matrix))
+	for key := range c.Matrix {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	combinations := []map[string]interface{}{{}}
+	for _, key := range keys {
+		next := make([]map[string]interface{}, 0, len(combinations)*len(c.Matrix[key]))
+		for _, items := range combinations {
+			for _, value := range c.Matrix[key] {
+				m := make(map[string]interface{}, len(items)+1)
+				for k, v := range items {
+					m[k] = v
+				}
+				m[key] = value
+				next = append(next, m)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+// SweepResult is the result of a run in the sweep.
+type SweepResult struct {
+	Run   string                 `json:"run"`
+	Items map[string]interface{} `json:"items"`
+	// Finished is true if the check of the case passed.
+	Finished bool  `json:"finished"`
+	Ticks    int64 `json:"ticks"`
+	// Convergence is the tick when the last operator finished.
+	Convergence int64 `json:"convergence"`
+	Operators   int   `json:"operators"`
+	// The imbalances are (max-min)/mean of the up stores at the last tick.
+	RegionImbalance float64 `json:"region-imbalance"`
+	LeaderImbalance float64 `json:"leader-imbalance"`
+	FlowImbalance   float64 `json:"flow-imbalance"`
+	// Cost is the weighted sum of the normalized metrics, the lower the better.
+	Cost float64 `json:"cost"`
+	// Error is set if the run fails to produce a report.
+	Error string `json:"error,omitempty"`
+}
+
+// ScoreReport returns the result of the run from its report.
+func ScoreReport(run string, items map[string]interface{}, report *Report, finished bool) *SweepResult {
+	result := &SweepResult{Run: run, Items: items, Finished: finished}
+	if len(report.Ticks) == 0 {
+		return result
+	}
+	last := report.Ticks[len(report.Ticks)-1]
+	result.Ticks = last.Tick
+	total := report.TotalOperators()
+	result.Operators = total[len(total)-1]
+	for i := len(total) - 1; i > 0; i-- {
+		if total[i] != total[i-1] {
+			result.Convergence = report.Ticks[i].Tick
+			break
+		}
+	}
+	result.RegionImbalance = imbalance(last.Stores, regionScoreValue)
+	result.LeaderImbalance = imbalance(last.Stores, leaderScoreValue)
+	result.FlowImbalance = imbalance(last.Stores, func(s StoreTickStats) float64 {
+		return float64(s.WrittenBytes + s.ReadBytes)
+	})
+	return result
+}
+
+// balance is the mean of the imbalances, the flow is ignored if there is
+// no flow.
+func (r *SweepResult) balance() float64 {
+	if r.FlowImbalance == 0 {
+		return (r.RegionImbalance + r.LeaderImbalance) / 2
+	}
+	return (r.RegionImbalance + r.LeaderImbalance + r.FlowImbalance) / 3
+}
+
+func imbalance(stores []StoreTickStats, value func(StoreTickStats) float64) float64 {
+	var min, max, sum float64
+	var count int
+	for _, s := range stores {
+		if !s.Up {
+			continue
+		}
+		v := value(s)
+		if count == 0 || v < min {
+			min = v
+		}
+		if count == 0 || v > max {
+			max = v
+		}
+		sum += v
+		count++
+	}
+	if count == 0 || sum == 0 {
+		return 0
+	}
+	return (max - min) / (sum / float64(count))
+}
+
+// RankSweepResults calculates the costs and sorts the results. Every metric
+// is normalized to [0, 1] among the runs before it is weighted. The
+// unfinished runs are placed after the finished ones, and the failed runs
+// are the last.
+func RankSweepResults(results []*SweepResult, weights SweepWeights) {
+	metrics := []struct {
+		weight float64
+		value  func(*SweepResult) float64
+	}{
+		{weights.Convergence, func(r *SweepResult) float64 { return float64(r.Convergence) }},
+		{weights.Operators, func(r *SweepResult) float64 { return float64(r.Operators) }},
+		{weights.Balance, (*SweepResult).balance},
+	}
+	for _, r := range results {
+		r.Cost = 0
+	}
+	for _, m := range metrics {
+		var min, max float64
+		first := true
+		for _, r := range results {
+			if r.Error != "" {
+				continue
+			}
+			v := m.value(r)
+			if first || v < min {
+				min = v
+			}
+			if first || v > max {
+				max = v
+			}
+			first = false
+		}
+		if max == min {
+			continue
+		}
+		for _, r := range results {
+			if r.Error == "" {
+				r.Cost += m.weight * (m.value(r) - min) / (max - min)
+			}
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if (a.Error == "") != (b.Error == "") {
+			return a.Error == ""
+		}
+		if a.Finished != b.Finished {
+			return a.Finished
+		}
+		return a.Cost < b.Cost
+	})
+}
+
+// WriteSweepTable writes the ranked results as a table.
+func WriteSweepTable(w io.Writer, results []*SweepResult) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RANK\tRUN\tITEMS\tRESULT\tCONVERGENCE\tOPERATORS\tREGION-IMBALANCE\tLEADER-IMBALANCE\tFLOW-IMBALANCE\tCOST")
+	for i, r := range results {
+		if r.Error != "" {
+			fmt.Fprintf(tw, "%d\t%s\t%s\tERROR\t-\t-\t-\t-\t-\t-\n", i+1, r.Run, formatItems(r.Items))
+			continue
+		}
+		result := "FAIL"
+		if r.Finished {
+			result = "OK"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\t%d\t%.3f\t%.3f\t%.3f\t%.3f\n", i+1, r.Run, formatItems(r.Items), result,
+			r.Convergence, r.Operators, r.RegionImbalance, r.LeaderImbalance, r.FlowImbalance, r.Cost)
+	}
+	return errors.WithStack(tw.Flush())
+}
+
+func formatItems(items map[string]interface{}) string {
+	s := make([]string, 0, len(items))
+	for key, value := range items {
+		s = append(s, fmt.Sprintf("%s=%v", key, value))
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	. "github.com/pingcap/check"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testSweepSuite{})
+
+type testSweepSuite struct{}
+
+func (s *testSweepSuite) TestLoadSweep(c *C) {
+	dir := c.MkDir()
+	path := filepath.Join(dir, "sweep.toml")
+	data := `
+case = "../scenarios/hot-write.toml"
+
+[matrix]
+"schedule.tolerant-size-ratio" = [0.0, 5.0, 20.0]
+"schedule.region-score-formula-version" = ["v1", "v2"]
+`
+	c.Assert(os.WriteFile(path, []byte(data), 0644), IsNil)
+	cfg, err := LoadSweep(path)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Case, Equals, filepath.Join(dir, "../scenarios/hot-write.toml"))
+	c.Assert(cfg.Parallel, Equals, defaultSweepParallel)
+	c.Assert(cfg.Timeout.Duration, Equals, defaultSweepTimeout)
+	c.Assert(cfg.Weights, Equals, SweepWeights{Convergence: 1, Operators: 1, Balance: 1})
+	combinations := cfg.Combinations()
+	c.Assert(combinations, HasLen, 6)
+	c.Assert(combinations[0], DeepEquals, map[string]interface{}{
+		"schedule.region-score-formula-version": "v1",
+		"schedule.tolerant-size-ratio":          0.0,
+	})
+	c.Assert(combinations[5], DeepEquals, map[string]interface{}{
+		"schedule.region-score-formula-version": "v2",
+		"schedule.tolerant-size-ratio":          20.0,
+	})
+
+	c.Assert(os.WriteFile(path, []byte(data+"unknown = 1\n"), 0644), IsNil)
+	_, err = LoadSweep(path)
+	c.Assert(err, NotNil)
+}
+
+func (s *testSweepSuite) TestApplyConfigItems(c *C) {
+	cfg := NewSimConfig("fatal")
+	defer os.RemoveAll(cfg.ServerConfig.DataDir)
+	var meta toml.MetaData
+	c.Assert(cfg.Adjust(&meta), IsNil)
+	schedulerItems, err := cfg.ApplyConfigItems(map[string]interface{}{
+		"schedule.tolerant-size-ratio":                     5,
+		"schedule.region-score-formula-version":            "v1",
+		"balance-hot-region-scheduler.src-tolerance-ratio": 1.2,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(cfg.ServerConfig.Schedule.TolerantSizeRatio, Equals, 5.0)
+	c.Assert(cfg.ServerConfig.Schedule.RegionScoreFormulaVersion, Equals, "v1")
+	c.Assert(schedulerItems, DeepEquals, map[string]map[string]interface{}{
+		"balance-hot-region-scheduler": {"src-tolerance-ratio": 1.2},
+	})
+
+	_, err = cfg.ApplyConfigItems(map[string]interface{}{"schedule.unknown": 1})
+	c.Assert(err, NotNil)
+	_, err = cfg.ApplyConfigItems(map[string]interface{}{"unknown.item": 1})
+	c.Assert(err, NotNil)
+}
+
+func (s *testSweepSuite) TestRankSweepResults(c *C) {
+	newReport := func(operators []int, regionCounts ...int) *Report {
+		r := NewReport("test")
+		for i, count := range operators {
+			t := TickStats{Tick: int64(i + 1), Operators: map[string]int{"Add Peer": count}}
+			for j, regionCount := range regionCounts {
+				t.Stores = append(t.Stores, StoreTickStats{StoreID: uint64(j + 1), Up: true, RegionCount: regionCount, RegionScore: float64(regionCount)})
+			}
+			r.Ticks = append(r.Ticks, t)
+		}
+		return r
+	}
+	slow := ScoreReport("slow", nil, newReport([]int{1, 2, 3, 4, 4}, 10, 10), true)
+	c.Assert(slow.Convergence, Equals, int64(4))
+	c.Assert(slow.Operators, Equals, 4)
+	c.Assert(slow.RegionImbalance, Equals, 0.0)
+	fast := ScoreReport("fast", nil, newReport([]int{1, 2, 2, 2, 2}, 10, 10), true)
+	c.Assert(fast.Convergence, Equals, int64(2))
+	unbalanced := ScoreReport("unbalanced", nil, newReport([]int{1, 1, 1, 1, 1}, 5, 15), false)
+	c.Assert(unbalanced.RegionImbalance, Equals, 1.0)
+	failed := &SweepResult{Run: "failed", Error: "exit status 1"}
+
+	results := []*SweepResult{failed, unbalanced, slow, fast}
+	RankSweepResults(results, SweepWeights{Convergence: 1, Operators: 1, Balance: 1})
+	c.Assert(results[0].Run, Equals, "fast")
+	c.Assert(results[1].Run, Equals, "slow")
+	c.Assert(results[2].Run, Equals, "unbalanced")
+	c.Assert(results[3].Run, Equals, "failed")
+	c.Assert(results[0].Cost, Less, results[1].Cost)
+
+	var buf bytes.Buffer
+	c.Assert(WriteSweepTable(&buf, results), IsNil)
+	c.Assert(bytes.Count(buf.Bytes(), []byte("\n")), Equals, 5)
+}
@@ -16,12 +16,15 @@ package simulator
 import (
 	"bytes"
 	"fmt"
+	"sort"
 
 	"github.com/pingcap/kvproto/pkg/eraftpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/tikv/pd/server/core"
 	"github.com/tikv/pd/tools/pd-analysis/analysis"
+	"github.com/tikv/pd/tools/pd-simulator/simulator/simutil"
+	"go.uber.org/zap"
 )
 
 // Task running in node.
@@ -35,43 +38,77 @@ type Task interface {
 func responseToTask(resp *pdpb.RegionHeartbeatResponse, r *RaftEngine) Task {
 	regionID := resp.GetRegionId()
 	region := r.GetRegion(regionID)
+	if region == nil {
+		return nil
+	}
 	epoch := resp.GetRegionEpoch()
 
 	//  change peer
 	if resp.GetChangePeer() != nil {
 		changePeer := resp.GetChangePeer()
+		peer := changePeer.GetPeer()
 		switch changePeer.GetChangeType() {
 		case eraftpb.ConfChangeType_AddNode:
+			// PD promotes a learner by adding it as a voter again.
+			if core.IsLearner(region.GetPeer(peer.GetId())) {
+				return &promoteLearner{
+					regionID: regionID,
+					epoch:    epoch,
+					peer:     peer,
+				}
+			}
 			return &addPeer{
 				regionID: regionID,
-				size:     region.GetApproximateSize(),
-				keys:     region.GetApproximateKeys(),
-				speed:    100 * 1000 * 1000,
 				epoch:    epoch,
-				peer:     changePeer.GetPeer(),
-				// This two variables are used to simulate sending and receiving snapshot processes.
-				sendingStat:   &snapshotStat{"sending", region.GetApproximateSize(), false},
-				receivingStat: &snapshotStat{"receiving", region.GetApproximateSize(), false},
+				peer:     peer,
+				snapshot: newSnapshot(region.GetApproximateSize()),
 			}
 		case eraftpb.ConfChangeType_RemoveNode:
 			return &removePeer{
 				regionID: regionID,
 				size:     region.GetApproximateSize(),
 				keys:     region.GetApproximateKeys(),
-				speed:    100 * 1000 * 1000,
+				speed:    r.storeIORate(peer.GetStoreId()),
 				epoch:    epoch,
-				peer:     changePeer.GetPeer(),
+				peer:     peer,
 			}
 		case eraftpb.ConfChangeType_AddLearnerNode:
+			// PD demotes a voter by adding it as a learner again.
+			if region.GetPeer(peer.GetId()) != nil {
+				return &demoteVoter{
+					regionID: regionID,
+					epoch:    epoch,
+					peer:     peer,
+				}
+			}
 			return &addLearner{
 				regionID: regionID,
-				size:     region.GetApproximateSize(),
-				keys:     region.GetApproximateKeys(),
-				speed:    100 * 1000 * 1000,
 				epoch:    epoch,
-				peer:     changePeer.GetPeer(),
+				peer:     peer,
+				snapshot: newSnapshot(region.GetApproximateSize()),
+			}
+		}
+	} else if resp.GetChangePeerV2() != nil {
+		changes := resp.GetChangePeerV2().GetChanges()
+		// An empty request means leaving the joint state.
+		if len(changes) == 0 {
+			return &leaveJointState{
+				regionID: regionID,
+				epoch:    epoch,
 			}
 		}
+		return &enterJointState{
+			regionID: regionID,
+			epoch:    epoch,
+			changes:  changes,
+		}
+	} else if resp.GetSplitRegion() != nil {
+		return &splitRegion{
+			regionID: regionID,
+			epoch:    epoch,
+			policy:   resp.GetSplitRegion().GetPolicy(),
+			keys:     resp.GetSplitRegion().GetKeys(),
+		}
 	} else if resp.GetTransferLeader() != nil {
 		changePeer := resp.GetTransferLeader().GetPeer()
 		fromPeer := region.GetLeader()
@@ -92,12 +129,83 @@ func responseToTask(resp *pdpb.RegionHeartbeatResponse, r *RaftEngine) Task {
 	return nil
 }
 
+// isStaleEpoch returns true if the region has changed after PD sent the
+// command, the command is ignored in this case like TiKV does.
+func isStaleEpoch(region *core.RegionInfo, epoch *metapb.RegionEpoch) bool {
+	return region == nil || region.GetRegionEpoch().GetVersion() > epoch.GetVersion() ||
+		region.GetRegionEpoch().GetConfVer() > epoch.GetConfVer()
+}
+
+// withPeerRoles changes the roles of the peers, including the leader.
+func withPeerRoles(roles map[uint64]metapb.PeerRole) core.RegionCreateOption {
+	return func(region *core.RegionInfo) {
+		for _, peer := range region.GetPeers() {
+			if role, ok := roles[peer.GetId()]; ok {
+				peer.Role = role
+			}
+		}
+		if leader := region.GetLeader(); leader != nil {
+			if role, ok := roles[leader.GetId()]; ok {
+				leader.Role = role
+			}
+		}
+	}
+}
+
 type snapshotStat struct {
 	kind       string
 	remainSize int64
+	node       *Node
+	started    bool
 	finished   bool
 }
 
+// snapshot simulates sending a snapshot from the leader to a new peer. The
+// duration depends on the region size and the IO rates of both stores.
+type snapshot struct {
+	sendingStat   *snapshotStat
+	receivingStat *snapshotStat
+}
+
+func newSnapshot(size int64) *snapshot {
+	return &snapshot{
+		sendingStat:   &snapshotStat{kind: "sending", remainSize: size},
+		receivingStat: &snapshotStat{kind: "receiving", remainSize: size},
+	}
+}
+
+// step steps the snapshot and returns true if the process is done. The
+// receiving node is nil if the snapshot can't be sent.
+func (s *snapshot) step(r *RaftEngine, region *core.RegionInfo, storeID uint64) (*Node, bool) {
+	sendNode := r.conn.Nodes[region.GetLeader().GetStoreId()]
+	if sendNode == nil {
+		s.abort()
+		return nil, true
+	}
+	if !processSnapshot(r, sendNode, s.sendingStat) {
+		return nil, false
+	}
+	recvNode := r.conn.Nodes[storeID]
+	if recvNode == nil {
+		s.abort()
+		return nil, true
+	}
+	if !processSnapshot(r, recvNode, s.receivingStat) {
+		return nil, false
+	}
+	return recvNode, true
+}
+
+// abort stops the unfinished snapshot.
+func (s *snapshot) abort() {
+	for _, stat := range []*snapshotStat{s.sendingStat, s.receivingStat} {
+		if stat.started && !stat.finished {
+			stat.finished = true
+			stat.node.decSnapshotCount(stat.kind)
+		}
+	}
+}
+
 type mergeRegion struct {
 	regionID     uint64
 	epoch        *metapb.RegionEpoch
@@ -208,15 +316,11 @@ func (t *transferLeader) IsFinished() bool {
 }
 
 type addPeer struct {
-	regionID      uint64
-	size          int64
-	keys          int64
-	speed         int64
-	epoch         *metapb.RegionEpoch
-	peer          *metapb.Peer
-	finished      bool
-	sendingStat   *snapshotStat
-	receivingStat *snapshotStat
+	regionID uint64
+	epoch    *metapb.RegionEpoch
+	peer     *metapb.Peer
+	snapshot *snapshot
+	finished bool
 }
 
 func (a *addPeer) Desc() string {
@@ -228,49 +332,25 @@ func (a *addPeer) Step(r *RaftEngine) {
 		return
 	}
 	region := r.GetRegion(a.regionID)
-	if region.GetRegionEpoch().GetVersion() > a.epoch.Version || region.GetRegionEpoch().GetConfVer() > a.epoch.ConfVer {
+	if isStaleEpoch(region, a.epoch) || region.GetPeer(a.peer.GetId()) != nil {
+		a.snapshot.abort()
 		a.finished = true
 		return
 	}
 
-	snapshotSize := region.GetApproximateSize()
-	sendNode := r.conn.Nodes[region.GetLeader().GetStoreId()]
-	if sendNode == nil {
-		a.finished = true
+	recvNode, done := a.snapshot.step(r, region, a.peer.GetStoreId())
+	if !done {
 		return
 	}
-	if !processSnapshot(sendNode, a.sendingStat, snapshotSize) {
-		return
-	}
-	r.schedulerStats.snapshotStats.incSendSnapshot(sendNode.Id)
-
-	recvNode := r.conn.Nodes[a.peer.GetStoreId()]
+	a.finished = true
 	if recvNode == nil {
-		a.finished = true
 		return
 	}
-	if !processSnapshot(recvNode, a.receivingStat, snapshotSize) {
-		return
-	}
-	r.schedulerStats.snapshotStats.incReceiveSnapshot(recvNode.Id)
-
-	a.size -= a.speed
-	if a.size < 0 {
-		var opts []core.RegionCreateOption
-		if region.GetPeer(a.peer.GetId()) == nil {
-			opts = append(opts, core.WithAddPeer(a.peer))
-			r.schedulerStats.taskStats.incAddPeer(region.GetID())
-		} else {
-			opts = append(opts, core.WithPromoteLearner(a.peer.GetId()))
-			r.schedulerStats.taskStats.incPromoteLeaner(region.GetID())
-		}
-		opts = append(opts, core.WithIncConfVer())
-		newRegion := region.Clone(opts...)
-		r.SetRegion(newRegion)
-		r.recordRegionChange(newRegion)
-		recvNode.incUsedSize(uint64(snapshotSize))
-		a.finished = true
-	}
+	newRegion := region.Clone(core.WithAddPeer(a.peer), core.WithIncConfVer())
+	r.SetRegion(newRegion)
+	r.recordRegionChange(newRegion)
+	r.schedulerStats.taskStats.incAddPeer(region.GetID())
+	recvNode.incUsedSize(uint64(region.GetApproximateSize()))
 }
 
 func (a *addPeer) RegionID() uint64 {
@@ -300,7 +380,7 @@ func (a *removePeer) Step(r *RaftEngine) {
 		return
 	}
 	region := r.GetRegion(a.regionID)
-	if region.GetRegionEpoch().GetVersion() > a.epoch.Version || region.GetRegionEpoch().GetConfVer() > a.epoch.ConfVer {
+	if isStaleEpoch(region, a.epoch) {
 		a.finished = true
 		return
 	}
@@ -352,11 +432,9 @@ func (a *removePeer) IsFinished() bool {
 
 type addLearner struct {
 	regionID uint64
-	size     int64
-	keys     int64
-	speed    int64
 	epoch    *metapb.RegionEpoch
 	peer     *metapb.Peer
+	snapshot *snapshot
 	finished bool
 }
 
@@ -369,26 +447,27 @@ func (a *addLearner) Step(r *RaftEngine) {
 		return
 	}
 	region := r.GetRegion(a.regionID)
-	if region.GetRegionEpoch().GetVersion() > a.epoch.Version || region.GetRegionEpoch().GetConfVer() > a.epoch.ConfVer {
+	if isStaleEpoch(region, a.epoch) || region.GetPeer(a.peer.GetId()) != nil {
+		a.snapshot.abort()
 		a.finished = true
 		return
 	}
 
-	a.size -= a.speed
-	if a.size < 0 {
-		if region.GetPeer(a.peer.GetId()) == nil {
-			newRegion := region.Clone(
-				core.WithAddPeer(a.peer),
-				core.WithIncConfVer(),
-			)
-			r.SetRegion(newRegion)
-			r.recordRegionChange(newRegion)
-			r.schedulerStats.taskStats.incAddLeaner(region.GetID())
-		}
-		a.finished = true
-		if analysis.GetTransferCounter().IsValid {
-			analysis.GetTransferCounter().AddTarget(a.regionID, a.peer.StoreId)
-		}
+	recvNode, done := a.snapshot.step(r, region, a.peer.GetStoreId())
+	if !done {
+		return
+	}
+	a.finished = true
+	if recvNode == nil {
+		return
+	}
+	newRegion := region.Clone(core.WithAddPeer(a.peer), core.WithIncConfVer())
+	r.SetRegion(newRegion)
+	r.recordRegionChange(newRegion)
+	r.schedulerStats.taskStats.incAddLeaner(region.GetID())
+	recvNode.incUsedSize(uint64(region.GetApproximateSize()))
+	if analysis.GetTransferCounter().IsValid {
+		analysis.GetTransferCounter().AddTarget(a.regionID, a.peer.StoreId)
 	}
 }
 
@@ -400,15 +479,261 @@ func (a *addLearner) IsFinished() bool {
 	return a.finished
 }
 
-func processSnapshot(n *Node, stat *snapshotStat, snapshotSize int64) bool {
-	// If the statement is true, it will start to send or receive the snapshot.
-	if stat.remainSize == snapshotSize {
-		if stat.kind == "sending" {
-			n.stats.SendingSnapCount++
-		} else {
-			n.stats.ReceivingSnapCount++
+// promoteLearner promotes a learner to voter, no snapshot is needed.
+type promoteLearner struct {
+	regionID uint64
+	epoch    *metapb.RegionEpoch
+	peer     *metapb.Peer
+	finished bool
+}
+
+func (p *promoteLearner) Desc() string {
+	return fmt.Sprintf("promote learner %+v for region %d", p.peer, p.regionID)
+}
+
+func (p *promoteLearner) Step(r *RaftEngine) {
+	if p.finished {
+		return
+	}
+	p.finished = true
+	region := r.GetRegion(p.regionID)
+	if isStaleEpoch(region, p.epoch) || !core.IsLearner(region.GetPeer(p.peer.GetId())) {
+		return
+	}
+	newRegion := region.Clone(core.WithPromoteLearner(p.peer.GetId()), core.WithIncConfVer())
+	r.SetRegion(newRegion)
+	r.recordRegionChange(newRegion)
+	r.schedulerStats.taskStats.incPromoteLeaner(region.GetID())
+}
+
+func (p *promoteLearner) RegionID() uint64 {
+	return p.regionID
+}
+
+func (p *promoteLearner) IsFinished() bool {
+	return p.finished
+}
+
+// demoteVoter demotes a voter to learner, the leader can't be demoted.
+type demoteVoter struct {
+	regionID uint64
+	epoch    *metapb.RegionEpoch
+	peer     *metapb.Peer
+	finished bool
+}
+
+func (d *demoteVoter) Desc() string {
+	return fmt.Sprintf("demote voter %+v for region %d", d.peer, d.regionID)
+}
+
+func (d *demoteVoter) Step(r *RaftEngine) {
+	if d.finished {
+		return
+	}
+	d.finished = true
+	region := r.GetRegion(d.regionID)
+	if isStaleEpoch(region, d.epoch) || region.GetLeader().GetId() == d.peer.GetId() ||
+		!core.IsVoterOrIncomingVoter(region.GetPeer(d.peer.GetId())) {
+		return
+	}
+	newRegion := region.Clone(
+		withPeerRoles(map[uint64]metapb.PeerRole{d.peer.GetId(): metapb.PeerRole_Learner}),
+		core.WithIncConfVer(),
+	)
+	r.SetRegion(newRegion)
+	r.recordRegionChange(newRegion)
+	r.schedulerStats.taskStats.incDemoteVoter(region.GetID())
+}
+
+func (d *demoteVoter) RegionID() uint64 {
+	return d.regionID
+}
+
+func (d *demoteVoter) IsFinished() bool {
+	return d.finished
+}
+
+// enterJointState promotes learners and demotes voters at the same time with
+// joint consensus. The promoted peers become incoming voters and the demoted
+// ones become demoting voters until the region leaves the joint state.
+type enterJointState struct {
+	regionID uint64
+	epoch    *metapb.RegionEpoch
+	changes  []*pdpb.ChangePeer
+	finished bool
+}
+
+func (e *enterJointState) Desc() string {
+	return fmt.Sprintf("enter joint state with %d changes for region %d", len(e.changes), e.regionID)
+}
+
+func (e *enterJointState) Step(r *RaftEngine) {
+	if e.finished {
+		return
+	}
+	e.finished = true
+	region := r.GetRegion(e.regionID)
+	if isStaleEpoch(region, e.epoch) || core.IsInJointState(region.GetPeers()...) {
+		return
+	}
+	roles := make(map[uint64]metapb.PeerRole, len(e.changes))
+	for _, change := range e.changes {
+		peer := region.GetPeer(change.GetPeer().GetId())
+		switch {
+		case change.GetChangeType() == eraftpb.ConfChangeType_AddNode && core.IsLearner(peer):
+			roles[peer.GetId()] = metapb.PeerRole_IncomingVoter
+		case change.GetChangeType() == eraftpb.ConfChangeType_AddLearnerNode && peer.GetRole() == metapb.PeerRole_Voter &&
+			peer.GetId() != region.GetLeader().GetId():
+			roles[peer.GetId()] = metapb.PeerRole_DemotingVoter
+		default:
+			simutil.Logger.Error("invalid change in joint consensus",
+				zap.Uint64("region-id", e.regionID),
+				zap.Stringer("change", change))
+			return
+		}
+	}
+	newRegion := region.Clone(
+		withPeerRoles(roles),
+		core.SetRegionConfVer(region.GetRegionEpoch().GetConfVer()+uint64(len(roles))),
+	)
+	r.SetRegion(newRegion)
+	r.recordRegionChange(newRegion)
+	r.schedulerStats.taskStats.incJointConsensus(region.GetID())
+}
+
+func (e *enterJointState) RegionID() uint64 {
+	return e.regionID
+}
+
+func (e *enterJointState) IsFinished() bool {
+	return e.finished
+}
+
+// leaveJointState finishes the changes of the joint state.
+type leaveJointState struct {
+	regionID uint64
+	epoch    *metapb.RegionEpoch
+	finished bool
+}
+
+func (l *leaveJointState) Desc() string {
+	return fmt.Sprintf("leave joint state for region %d", l.regionID)
+}
+
+func (l *leaveJointState) Step(r *RaftEngine) {
+	if l.finished {
+		return
+	}
+	l.finished = true
+	region := r.GetRegion(l.regionID)
+	if isStaleEpoch(region, l.epoch) {
+		return
+	}
+	roles := make(map[uint64]metapb.PeerRole)
+	for _, peer := range region.GetPeers() {
+		switch peer.GetRole() {
+		case metapb.PeerRole_IncomingVoter:
+			roles[peer.GetId()] = metapb.PeerRole_Voter
+		case metapb.PeerRole_DemotingVoter:
+			roles[peer.GetId()] = metapb.PeerRole_Learner
 		}
 	}
+	if len(roles) == 0 {
+		return
+	}
+	newRegion := region.Clone(
+		withPeerRoles(roles),
+		core.SetRegionConfVer(region.GetRegionEpoch().GetConfVer()+uint64(len(roles))),
+	)
+	r.SetRegion(newRegion)
+	r.recordRegionChange(newRegion)
+}
+
+func (l *leaveJointState) RegionID() uint64 {
+	return l.regionID
+}
+
+func (l *leaveJointState) IsFinished() bool {
+	return l.finished
+}
+
+// splitRegion splits the region by the keys from PD. The split key is
+// generated in the middle of the region if the policy doesn't use keys.
+type splitRegion struct {
+	regionID uint64
+	epoch    *metapb.RegionEpoch
+	policy   pdpb.CheckPolicy
+	keys     [][]byte
+	finished bool
+}
+
+func (s *splitRegion) Desc() string {
+	return fmt.Sprintf("split region %d by %s policy", s.regionID, s.policy)
+}
+
+func (s *splitRegion) Step(r *RaftEngine) {
+	if s.finished {
+		return
+	}
+	s.finished = true
+	region := r.GetRegion(s.regionID)
+	if isStaleEpoch(region, s.epoch) || region.GetLeader() == nil {
+		return
+	}
+	var splitKeys [][]byte
+	if s.policy == pdpb.CheckPolicy_USEKEY {
+		splitKeys = validSplitKeys(region, s.keys)
+	} else if splitKey := r.generateSplitKey(region); splitKey != nil {
+		splitKeys = [][]byte{splitKey}
+	}
+	if len(splitKeys) == 0 {
+		simutil.Logger.Debug("region can not be split", zap.Uint64("region-id", s.regionID))
+		return
+	}
+	if _, err := r.splitRegion(region, splitKeys); err != nil {
+		simutil.Logger.Error("split region failed", zap.Uint64("region-id", s.regionID), zap.Error(err))
+		return
+	}
+	r.schedulerStats.taskStats.incSplitRegion()
+}
+
+func (s *splitRegion) RegionID() uint64 {
+	return s.regionID
+}
+
+func (s *splitRegion) IsFinished() bool {
+	return s.finished
+}
+
+// validSplitKeys returns the sorted and deduplicated keys inside the region.
+func validSplitKeys(region *core.RegionInfo, keys [][]byte) [][]byte {
+	splitKeys := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		if bytes.Compare(key, region.GetStartKey()) <= 0 ||
+			(len(region.GetEndKey()) > 0 && bytes.Compare(key, region.GetEndKey()) >= 0) {
+			continue
+		}
+		splitKeys = append(splitKeys, key)
+	}
+	sort.Slice(splitKeys, func(i, j int) bool { return bytes.Compare(splitKeys[i], splitKeys[j]) < 0 })
+	var n int
+	for i, key := range splitKeys {
+		if i == 0 || !bytes.Equal(key, splitKeys[n-1]) {
+			splitKeys[n] = key
+			n++
+		}
+	}
+	return splitKeys[:n]
+}
+
+func processSnapshot(r *RaftEngine, n *Node, stat *snapshotStat) bool {
+	// It starts to send or receive the snapshot at the first step. The region
+	// size may change during the process, so it can't be decided by the size.
+	if !stat.started {
+		stat.started = true
+		stat.node = n
+		n.incSnapshotCount(stat.kind)
+	}
 	stat.remainSize -= n.ioRate
 	// The sending or receiving process has not finished yet.
 	if stat.remainSize > 0 {
@@ -416,10 +741,11 @@ func processSnapshot(n *Node, stat *snapshotStat, snapshotSize int64) bool {
 	}
 	if !stat.finished {
 		stat.finished = true
+		n.decSnapshotCount(stat.kind)
 		if stat.kind == "sending" {
-			n.stats.SendingSnapCount--
+			r.schedulerStats.snapshotStats.incSendSnapshot(n.Id)
 		} else {
-			n.stats.ReceivingSnapCount--
+			r.schedulerStats.snapshotStats.incReceiveSnapshot(n.Id)
 		}
 	}
 	return true
@@ -0,0 +1,191 @@
+// Copyright 2021 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/tikv/pd/tools/pd-simulator/simulator"
+	"github.com/tikv/pd/tools/pd-simulator/simulator/simutil"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSweepDir = "sweep"
+	// sweepKillGracePeriod is the time to wait for the simulator to write the
+	// report after SIGTERM before it is killed.
+	sweepKillGracePeriod = 30 * time.Second
+)
+
+// sweep runs the case of the sweep file under every combination of the config
+// items, each run is a simulator process. The results are ranked and printed,
+// the reports of the runs are written to reportDir.
+func sweep(path string) {
+	cfg, err := simulator.LoadSweep(path)
+	if err != nil {
+		simutil.Logger.Fatal("load sweep error", zap.Error(err))
+	}
+	dir := *reportDir
+	if dir == "" {
+		dir = defaultSweepDir
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		simutil.Logger.Fatal("create report directory error", zap.Error(err))
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		simutil.Logger.Fatal("get executable error", zap.Error(err))
+	}
+
+	combinations := cfg.Combinations()
+	fmt.Printf("sweep [%s] with %d runs, %d in parallel\n", cfg.Case, len(combinations), cfg.Parallel)
+	results := make([]*simulator.SweepResult, len(combinations))
+	reports := make([]*simulator.Report, len(combinations))
+	var wg sync.WaitGroup
+	limiter := make(chan struct{}, cfg.Parallel)
+	for i, items := range combinations {
+		wg.Add(1)
+		go func(i int, items map[string]interface{}) {
+			defer wg.Done()
+			limiter <- struct{}{}
+			defer func() { <-limiter }()
+			run := "run-" + strconv.Itoa(i+1)
+			start := time.Now()
+			report, finished, err := sweepRun(exe, cfg, filepath.Join(dir, run), items)
+			if err != nil {
+				results[i] = &simulator.SweepResult{Run: run, Items: items, Error: err.Error()}
+				fmt.Printf("%s error: %v\n", run, err)
+				return
+			}
+			report.Name = run
+			reports[i] = report
+			results[i] = simulator.ScoreReport(run, items, report, finished)
+			fmt.Printf("%s finished: %v, time cost: %v\n", run, finished, time.Since(start))
+		}(i, items)
+	}
+	wg.Wait()
+
+	simulator.RankSweepResults(results, cfg.Weights)
+	fmt.Println()
+	if err = simulator.WriteSweepTable(os.Stdout, results); err != nil {
+		simutil.Logger.Fatal("write sweep table error", zap.Error(err))
+	}
+	if err = writeFile(filepath.Join(dir, "sweep.json"), func(f *os.File) error {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}); err != nil {
+		simutil.Logger.Fatal("write sweep result error", zap.Error(err))
+	}
+	var finished []*simulator.Report
+	for _, r := range reports {
+		if r != nil {
+			finished = append(finished, r)
+		}
+	}
+	if len(finished) > 0 {
+		if err = writeFile(filepath.Join(dir, "compare.html"), func(f *os.File) error {
+			return simulator.RenderReport(f, reportAssets(), finished...)
+		}); err != nil {
+			simutil.Logger.Fatal("write sweep report error", zap.Error(err))
+		}
+	}
+	fmt.Println("the results and reports are written to", dir)
+}
+
+// sweepRun runs the case in a simulator process with the config items, it
+// returns the report and whether the check of the case passed.
+func sweepRun(exe string, cfg *simulator.SweepConfig, dir string, items map[string]interface{}) (*simulator.Report, bool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+	args := []string{
+		"-config=" + *configFile,
+		"-case=" + cfg.Case,
+		"-configItems=" + string(data),
+		"-reportDir=" + dir,
+		"-reportAssetsHost=" + *reportAssetsHost,
+		"-serverLog=" + *serverLogLevel,
+		"-simLog=" + *simLogLevel,
+		"-simLogFile=" + filepath.Join(dir, "simulator.log"),
+	}
+	if *pdNum > 0 {
+		args = append(args, "-pdNum="+strconv.Itoa(*pdNum))
+	}
+	output, err := os.Create(filepath.Join(dir, "output.log"))
+	if err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+	defer output.Close()
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout, cmd.Stderr = output, output
+	if err = cmd.Start(); err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+	// The simulator stops the case and writes the report on SIGTERM, it is
+	// killed if it doesn't exit in sweepKillGracePeriod.
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+			return
+		case <-time.After(cfg.Timeout.Duration):
+		}
+		cmd.Process.Signal(syscall.SIGTERM)
+		select {
+		case <-done:
+		case <-time.After(sweepKillGracePeriod):
+			cmd.Process.Kill()
+		}
+	}()
+	err = cmd.Wait()
+	close(done)
+	report, loadErr := simulator.LoadReport(filepath.Join(dir, reportName(cfg.Case)+".json"))
+	if loadErr != nil {
+		if err != nil {
+			return nil, false, errors.Annotatef(err, "see %s", output.Name())
+		}
+		return nil, false, loadErr
+	}
+	// The run doesn't reflect the config items if some of them are not set.
+	if len(report.PendingSchedulerConfigs) > 0 {
+		return nil, false, errors.Errorf("scheduler config items %v are not set, see %s", report.PendingSchedulerConfigs, output.Name())
+	}
+	return report, err == nil, nil
+}
+
+func writeFile(path string, write func(*os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err = write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return errors.WithStack(f.Close())
+}
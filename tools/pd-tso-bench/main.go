@@ -40,6 +40,7 @@ var (
 	count        = flag.Int("count", 1, "the count number that the test will run")
 	duration     = flag.Duration("duration", 60*time.Second, "how many seconds the test will last")
 	dcLocation   = flag.String("dc", "global", "which dc-location this bench will request")
+	priority     = flag.String("priority", "", "the priority of the TSO requests, it can be normal or low")
 	verbose      = flag.Bool("v", false, "output statistics info every interval and output metrics info at the end")
 	interval     = flag.Duration("interval", time.Second, "interval to output the statistics")
 	caPath       = flag.String("cacert", "", "path of file that contains list of trusted SSL CAs")
@@ -90,7 +91,7 @@ func bench(mainCtx context.Context) {
 			CAPath:   *caPath,
 			CertPath: *certPath,
 			KeyPath:  *keyPath,
-		})
+		}, pd.WithTSOPriority(*priority))
 		if err != nil {
 			log.Fatal(fmt.Sprintf("create pd client #%d failed: %v", idx, err))
 		}